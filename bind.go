@@ -0,0 +1,79 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import "fmt"
+
+// binding is the cipher of an FPEString or EncryptedValue, given directly or by
+// its name in the registry of the application.
+type binding struct {
+	cipher Cipher
+	name   string
+}
+
+// Bind makes the value use c, replacing any earlier binding.
+func (b *binding) Bind(c Cipher) {
+	b.cipher, b.name = c, ""
+}
+
+// BindName makes the value use the cipher registered under name, replacing any
+// earlier binding. The name is looked up on every use.
+func (b *binding) BindName(name string) {
+	b.cipher, b.name = nil, name
+}
+
+// resolve returns the cipher b refers to.
+func (b *binding) resolve() (Cipher, error) {
+	if b.cipher != nil {
+		return b.cipher, nil
+	}
+	if b.name == "" {
+		return nil, ErrUnbound
+	}
+	c, ok := Get(b.name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotRegistered, b.name)
+	}
+	return c, nil
+}
+
+// cryptValue encrypts or decrypts X with c. The empty string is returned as it is,
+// and other values shorter than the MinLen of c, if it has that method, fail with
+// ErrValueTooShort.
+func cryptValue(c Cipher, X []byte, encrypt bool) (string, error) {
+	if len(X) == 0 {
+		return "", nil
+	}
+	if m, ok := c.(interface{ MinLen() int }); ok && len(X) < m.MinLen() {
+		return "", fmt.Errorf("%w: %d bytes, cipher needs at least %d", ErrValueTooShort, len(X), m.MinLen())
+	}
+	if encrypt {
+		Y, err := c.Encrypt(X)
+		if err != nil {
+			return "", fmt.Errorf("fpe: encrypting value: %w", err)
+		}
+		return string(Y), nil
+	}
+	Y, err := c.Decrypt(X)
+	if err != nil {
+		return "", fmt.Errorf("fpe: decrypting value: %w", err)
+	}
+	return string(Y), nil
+}
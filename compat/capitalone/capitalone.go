@@ -0,0 +1,129 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package capitalone provides the string API of github.com/capitalone/fpe/ff1 on
+// top of this module, so that code using it can change the import path first and
+// move to the byte API of package ff1 one call site at a time:
+//
+//	import ff1 "github.com/Tensai75/go-fpe-bytes/compat/capitalone"
+//
+// As upstream, the numerals of a radix are the first radix characters of
+// 0-9, a-z and A-Z, the order of math/big, and for radices up to 36 upper case
+// letters are accepted as their lower case equivalents. The ciphertexts are the
+// same as those of upstream for the same key, tweak and plaintext.
+//
+// Unlike upstream, a leading sign, which math/big parsed as part of the first
+// half of the message, is rejected with ErrStringNotInRadix.
+package capitalone
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// The errors of upstream, which Encrypt and Decrypt return for the same inputs.
+var (
+	// ErrStringNotInRadix is returned if input or intermediate strings cannot be parsed in the given radix
+	ErrStringNotInRadix = ff1.ErrStringNotInRadix
+
+	// ErrTweakLengthInvalid is returned if the tweak length is not in the given range
+	ErrTweakLengthInvalid = ff1.ErrTweakLengthInvalid
+)
+
+// digits are the numerals of math/big, in the order of their values
+const digits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// A Cipher is an instance of the FF1 mode of format preserving encryption
+// using a particular key, radix, and tweak
+type Cipher struct {
+	c     ff1.Cipher
+	radix int
+}
+
+// NewCipher initializes a new FF1 Cipher for encryption or decryption use
+// based on the radix, max tweak length, key and tweak parameters. The radix
+// must be between 2 and 62.
+func NewCipher(radix int, maxTLen int, key []byte, tweak []byte) (Cipher, error) {
+	if radix < 2 || radix > big.MaxBase {
+		return Cipher{}, fmt.Errorf("%w: %d not in [2..%d]", fpeUtils.ErrRadixOutOfRange, radix, big.MaxBase)
+	}
+	c, err := ff1.NewCipherWithAlphabet([]byte(digits[:radix]), maxTLen, key, tweak)
+	if err != nil {
+		return Cipher{}, err
+	}
+	return Cipher{c: c, radix: radix}, nil
+}
+
+// Encrypt encrypts the string X over the current FF1 parameters
+// and returns the ciphertext of the same length and format
+func (c Cipher) Encrypt(X string) (string, error) {
+	Y, err := c.c.Encrypt(c.numerals(X))
+	return string(Y), err
+}
+
+// EncryptWithTweak is the same as Encrypt except it uses the
+// tweak from the parameter rather than the current Cipher's tweak
+// This allows you to re-use a single Cipher (for a given key) and simply
+// override the tweak for each unique data input, which is a practical
+// use-case of FPE for things like credit card numbers.
+func (c Cipher) EncryptWithTweak(X string, tweak []byte) (string, error) {
+	Y, err := c.c.EncryptWithTweak(c.numerals(X), tweak)
+	return string(Y), err
+}
+
+// Decrypt decrypts the string X over the current FF1 parameters
+// and returns the plaintext of the same length and format
+func (c Cipher) Decrypt(X string) (string, error) {
+	Y, err := c.c.Decrypt(c.numerals(X))
+	return string(Y), err
+}
+
+// DecryptWithTweak is the same as Decrypt except it uses the
+// tweak from the parameter rather than the current Cipher's tweak
+// This allows you to re-use a single Cipher (for a given key) and simply
+// override the tweak for each unique data input, which is a practical
+// use-case of FPE for things like credit card numbers.
+func (c Cipher) DecryptWithTweak(X string, tweak []byte) (string, error) {
+	Y, err := c.c.DecryptWithTweak(c.numerals(X), tweak)
+	return string(Y), err
+}
+
+// Unwrap returns the Cipher of package ff1 that c uses, for moving call sites
+// to the byte API. It encrypts the same strings as c, as bytes, apart from upper
+// case letters for radices up to 36, which it rejects.
+func (c Cipher) Unwrap() ff1.Cipher {
+	return c.c
+}
+
+// numerals returns X as bytes, with upper case letters in lower case if the
+// radix has no upper case numerals, as math/big parses them
+func (c Cipher) numerals(X string) []byte {
+	b := []byte(X)
+	if c.radix <= 36 {
+		for i, v := range b {
+			if 'A' <= v && v <= 'Z' {
+				b[i] = v + 'a' - 'A'
+			}
+		}
+	}
+	return b
+}
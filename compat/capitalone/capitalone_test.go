@@ -0,0 +1,282 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package capitalone
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// upstreamFF1 follows FF1 as github.com/capitalone/fpe/ff1 implements it: on the
+// string itself, parsing and formatting the halves with math/big in the radix,
+// and with the lengths b and d computed in floating point.
+func upstreamFF1(key, tweak []byte, radix int, X string, encrypt bool) string {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	prf := func(in []byte) []byte {
+		y := make([]byte, aes.BlockSize)
+		for i := 0; i < len(in); i += aes.BlockSize {
+			for j := range y {
+				y[j] ^= in[i+j]
+			}
+			block.Encrypt(y, y)
+		}
+		return y
+	}
+	num := func(s string) *big.Int {
+		x, ok := new(big.Int).SetString(s, radix)
+		if !ok {
+			panic("not in radix: " + s)
+		}
+		return x
+	}
+	str := func(x *big.Int, m int) string {
+		s := x.Text(radix)
+		return strings.Repeat("0", m-len(s)) + s
+	}
+
+	n, t := len(X), len(tweak)
+	u := n / 2
+	v := n - u
+	A, B := X[:u], X[u:]
+	b := int(math.Ceil(math.Ceil(float64(v)*math.Log2(float64(radix))) / 8))
+	d := int(4*math.Ceil(float64(b)/4) + 4)
+
+	P := []byte{1, 2, 1, 0, 0, 0, 10, byte(u), 0, 0, 0, 0, 0, 0, 0, 0}
+	P[3], P[4], P[5] = byte(radix>>16), byte(radix>>8), byte(radix)
+	binary.BigEndian.PutUint32(P[8:], uint32(n))
+	binary.BigEndian.PutUint32(P[12:], uint32(t))
+
+	round := func(i int, half string) *big.Int {
+		Q := append([]byte{}, tweak...)
+		Q = append(Q, make([]byte, (16-(t+b+1)%16)%16)...)
+		Q = append(Q, byte(i))
+		numB := num(half).Bytes()
+		Q = append(Q, make([]byte, b-len(numB))...)
+		Q = append(Q, numB...)
+		R := prf(append(append([]byte{}, P...), Q...))
+		S := append([]byte{}, R...)
+		for j := 1; len(S) < d; j++ {
+			x := make([]byte, aes.BlockSize)
+			binary.BigEndian.PutUint64(x[8:], uint64(j))
+			for k := range x {
+				x[k] ^= R[k]
+			}
+			block.Encrypt(x, x)
+			S = append(S, x...)
+		}
+		return new(big.Int).SetBytes(S[:d])
+	}
+
+	radixBig := big.NewInt(int64(radix))
+	for r := 0; r < 10; r++ {
+		i := r
+		if !encrypt {
+			i = 9 - r
+		}
+		m := u
+		if i%2 == 1 {
+			m = v
+		}
+		mod := new(big.Int).Exp(radixBig, big.NewInt(int64(m)), nil)
+		if encrypt {
+			c := new(big.Int).Add(num(A), round(i, B))
+			A, B = B, str(c.Mod(c, mod), m)
+		} else {
+			c := new(big.Int).Sub(num(B), round(i, A))
+			A, B = str(c.Mod(c, mod), m), A
+		}
+	}
+	return A + B
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// The NIST samples, with the strings as upstream tests them
+var nistVectors = []struct {
+	radix      int
+	key        string
+	tweak      string
+	plaintext  string
+	ciphertext string
+}{
+	{10, "2B7E151628AED2A6ABF7158809CF4F3C", "", "0123456789", "2433477484"},
+	{10, "2B7E151628AED2A6ABF7158809CF4F3C", "39383736353433323130", "0123456789", "6124200773"},
+	{36, "2B7E151628AED2A6ABF7158809CF4F3C", "3737373770717273373737", "0123456789abcdefghi", "a9tv40mll9kdu509eum"},
+	{10, "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F", "", "0123456789", "2830668132"},
+	{10, "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F", "39383736353433323130", "0123456789", "2496655549"},
+	{36, "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F", "3737373770717273373737", "0123456789abcdefghi", "xbj3kv35jrawxv32ysr"},
+	{10, "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "", "0123456789", "6657667009"},
+	{10, "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "39383736353433323130", "0123456789", "1001623463"},
+	{36, "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "3737373770717273373737", "0123456789abcdefghi", "xs8a0azh2avyalyzuwd"},
+}
+
+func TestNISTVectors(t *testing.T) {
+	for idx, testVector := range nistVectors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			key, tweak := mustHex(testVector.key), mustHex(testVector.tweak)
+			c, err := NewCipher(testVector.radix, len(tweak), key, tweak)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			ciphertext, err := c.Encrypt(testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testVector.ciphertext {
+				t.Fatalf("Encrypt: got %s, expected %s", ciphertext, testVector.ciphertext)
+			}
+			if upstream := upstreamFF1(key, tweak, testVector.radix, testVector.plaintext, true); upstream != ciphertext {
+				t.Fatalf("The transcription of upstream gives %s, expected %s", upstream, ciphertext)
+			}
+			plaintext, err := c.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testVector.plaintext {
+				t.Fatalf("Decrypt: got %s, expected %s", plaintext, testVector.plaintext)
+			}
+		})
+	}
+}
+
+// Random messages of every radix from 2 to 36, and some up to 62, give the
+// ciphertexts of upstream
+func TestUpstreamCompatibility(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	key := mustHex("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F")
+	radices := []int{37, 52, 53, 54, 55, 61, 62}
+	for radix := 2; radix <= 36; radix++ {
+		radices = append(radices, radix)
+	}
+	for _, radix := range radices {
+		t.Run(fmt.Sprintf("Radix%d", radix), func(t *testing.T) {
+			c, err := NewCipher(radix, 32, key, nil)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			for i := 0; i < 20; i++ {
+				n := 7 + rng.Intn(60)
+				X := make([]byte, n)
+				for j := range X {
+					X[j] = digits[rng.Intn(radix)]
+				}
+				tweak := make([]byte, rng.Intn(33))
+				rng.Read(tweak)
+
+				expected := upstreamFF1(key, tweak, radix, string(X), true)
+				ciphertext, err := c.EncryptWithTweak(string(X), tweak)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if ciphertext != expected {
+					t.Fatalf("EncryptWithTweak(%s): got %s, expected %s", X, ciphertext, expected)
+				}
+				if back := upstreamFF1(key, tweak, radix, ciphertext, false); back != string(X) {
+					t.Fatalf("The transcription of upstream decrypts %s to %s, expected %s", ciphertext, back, X)
+				}
+				plaintext, err := c.DecryptWithTweak(ciphertext, tweak)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if plaintext != string(X) {
+					t.Fatalf("DecryptWithTweak(%s): got %s, expected %s", ciphertext, plaintext, X)
+				}
+			}
+		})
+	}
+}
+
+func TestUpperCase(t *testing.T) {
+	key := mustHex(nistVectors[2].key)
+	tweak := mustHex(nistVectors[2].tweak)
+	c, err := NewCipher(36, len(tweak), key, tweak)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	ciphertext, err := c.Encrypt("0123456789ABCDEFGHI")
+	if err != nil || ciphertext != nistVectors[2].ciphertext {
+		t.Fatalf("Encrypt: got %s, %v expected %s", ciphertext, err, nistVectors[2].ciphertext)
+	}
+	if _, err := c.Unwrap().Encrypt([]byte("0123456789ABCDEFGHI")); !errors.Is(err, ErrStringNotInRadix) {
+		t.Fatalf("Unwrapped: got %v, expected ErrStringNotInRadix", err)
+	}
+
+	// Upper case letters are numerals above radix 36
+	c, err = NewCipher(62, 0, key, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	lower, _ := c.Encrypt("abcdefgh")
+	upper, _ := c.Encrypt("ABCDEFGH")
+	if lower == upper {
+		t.Fatalf("Upper and lower case messages give the same ciphertext in radix 62")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	key := mustHex(nistVectors[0].key)
+	for idx, testCase := range []struct {
+		radix    int
+		maxTLen  int
+		key      []byte
+		tweak    []byte
+		input    string
+		expected error
+	}{
+		{10, 0, key, nil, "12345678a0", ErrStringNotInRadix},
+		{10, 0, key, nil, "-123456789", ErrStringNotInRadix},
+		{2, 0, key, nil, "0101012", ErrStringNotInRadix},
+		{36, 0, key, nil, "hello_world", ErrStringNotInRadix},
+		{10, 2, key, []byte("tweak"), "", ErrTweakLengthInvalid},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := NewCipher(testCase.radix, testCase.maxTLen, testCase.key, testCase.tweak)
+			if err == nil {
+				_, err = c.Encrypt(testCase.input)
+			}
+			if !errors.Is(err, testCase.expected) {
+				t.Fatalf("Got %v, expected %v", err, testCase.expected)
+			}
+		})
+	}
+
+	for _, radix := range []int{0, 1, 63, 256} {
+		if _, err := NewCipher(radix, 0, key, nil); err == nil {
+			t.Errorf("Radix %d was accepted", radix)
+		}
+	}
+}
@@ -3,6 +3,8 @@ Package fpe implements the NIST recommended Format Preserving Encryption (FPE) F
 
 NIST Recommendation SP 800-38G: http://nvlpubs.nist.gov/nistpubs/SpecialPublications/NIST.SP.800-38G.pdf
 
-This package itself has nothing, the ff1 sub-package contains the API.
+This package itself only defines the Cipher interface, a registry of named
+ciphers, FPEString and EncryptedValue for values that are stored encrypted, and
+EncryptStruct for the fields of structs tagged with the cipher to use, the ff1 sub-package contains the API.
 */
 package fpe
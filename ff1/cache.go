@@ -0,0 +1,107 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/cipher"
+	"math/big"
+	"sync"
+)
+
+// maxCachedLengths bounds the number of distinct message lengths for which values
+// are cached, so callers with many different lengths cannot grow the cache without limit.
+// Lengths seen after the cache is full are simply computed on every call.
+const maxCachedLengths = 64
+
+// lengthEntry holds values that only depend on the message length, and on the key
+// for the CBC-MAC state. Entries are read concurrently and must not be modified once built.
+type lengthEntry struct {
+	// Split point: A is u numerals long, B is v numerals long
+	u, v uint32
+
+	// The moduli radix^u and radix^v for the even and odd rounds, shared with
+	// the Cipher's power table
+	modU, modV *big.Int
+
+	// The moduli as uint64, set when both fit and the rounds use machine words
+	fits64         bool
+	modU64, modV64 uint64
+
+	// Byte lengths b and d, and the number of PRF output blocks per round
+	b, d, maxJ int
+
+	// CBC-MAC chaining state after absorbing P and the tweak-only blocks of Q
+	// for the Cipher's default tweak, under the key of block. An entry for a key
+	// replaced by SwapKey is not used.
+	block cipher.Block
+	state [blockSize]byte
+}
+
+// lengthCache maps message lengths to their lengthEntry. It is safe for concurrent use.
+type lengthCache struct {
+	mu      sync.RWMutex
+	entries map[uint32]*lengthEntry
+}
+
+func newLengthCache() *lengthCache {
+	return &lengthCache{entries: make(map[uint32]*lengthEntry)}
+}
+
+// get returns the cached entry for messages of n numerals, if any.
+func (lc *lengthCache) get(n uint32) (*lengthEntry, bool) {
+	lc.mu.RLock()
+	e, ok := lc.entries[n]
+	lc.mu.RUnlock()
+	return e, ok
+}
+
+// put stores the entry for messages of n numerals, replacing an earlier one, unless
+// the cache is full. Entries are never modified after being stored.
+func (lc *lengthCache) put(n uint32, e *lengthEntry) {
+	lc.mu.Lock()
+	if _, ok := lc.entries[n]; ok || len(lc.entries) < maxCachedLengths {
+		lc.entries[n] = e
+	}
+	lc.mu.Unlock()
+}
+
+// wipe overwrites the key and tweak dependent state of all entries and removes them.
+// It must not be called while entries are in use.
+func (lc *lengthCache) wipe() {
+	lc.mu.Lock()
+	for n, e := range lc.entries {
+		e.state = [blockSize]byte{}
+		delete(lc.entries, n)
+	}
+	lc.mu.Unlock()
+}
+
+// wipeKey overwrites and removes the entries for the key of block. It must not be
+// called while they are in use.
+func (lc *lengthCache) wipeKey(block cipher.Block) {
+	lc.mu.Lock()
+	for n, e := range lc.entries {
+		if e.block == block {
+			e.state = [blockSize]byte{}
+			delete(lc.entries, n)
+		}
+	}
+	lc.mu.Unlock()
+}
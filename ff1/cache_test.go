@@ -0,0 +1,220 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// countingBlock counts AES block operations.
+type countingBlock struct {
+	cipher.Block
+	count *int
+}
+
+func (b countingBlock) Encrypt(dst, src []byte) {
+	*b.count++
+	b.Block.Encrypt(dst, src)
+}
+
+// newCountingCipher returns a radix 10 cipher for the first NIST key along with
+// a pointer to its AES block operation counter.
+func newCountingCipher(t testing.TB, tweak []byte) (Cipher, *int) {
+	key := mustHex("2B7E151628AED2A6ABF7158809CF4F3C")
+	c, err := NewCipher(10, 32, key, tweak)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	block, _ := aes.NewCipher(key)
+	count := new(int)
+	c.setBlock(countingBlock{block, count})
+	return c, count
+}
+
+func TestPrefixBlockCount(t *testing.T) {
+	c, count := newCountingCipher(t, nil)
+	plaintext := []byte("0123456789")
+
+	// The first call computes CIPH(P) once for the length
+	if _, err := c.Encrypt(plaintext); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if *count != numRounds+1 {
+		t.Fatalf("first Encrypt used %d AES blocks, expected %d", *count, numRounds+1)
+	}
+
+	// Afterwards each round only processes the single block of Q
+	*count = 0
+	ciphertext, err := c.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if *count != numRounds {
+		t.Fatalf("cached Encrypt used %d AES blocks, expected %d", *count, numRounds)
+	}
+	if string(ciphertext) != "2433477484" {
+		t.Fatalf("unexpected ciphertext %s", ciphertext)
+	}
+}
+
+func TestPrefixTweakBlocks(t *testing.T) {
+	// A 20 byte default tweak fills one full block of Q that is absorbed once
+	tweak := []byte("0123456789abcdefghij")
+	c, count := newCountingCipher(t, tweak)
+	plaintext := []byte("0123456789")
+
+	reference := referenceFF1(mustHex("2B7E151628AED2A6ABF7158809CF4F3C"), tweak, 10, []uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, true)
+
+	for iter := 0; iter < 2; iter++ {
+		*count = 0
+		ciphertext, err := c.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for i, ch := range ciphertext {
+			if ch-'0' != reference[i] {
+				t.Fatalf("ciphertext %s differs from reference %v", ciphertext, reference)
+			}
+		}
+	}
+	if *count != numRounds {
+		t.Fatalf("cached Encrypt used %d AES blocks, expected %d", *count, numRounds)
+	}
+
+	// Other tweaks of the same length are not cached, but still only absorb the prefix once per call
+	*count = 0
+	if _, err := c.EncryptWithTweak(plaintext, []byte("jihgfedcba9876543210")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if *count != numRounds+2 {
+		t.Fatalf("Encrypt with other tweak used %d AES blocks, expected %d", *count, numRounds+2)
+	}
+}
+
+func TestLengthCacheBounded(t *testing.T) {
+	c, _ := newCountingCipher(t, nil)
+	for n := 2; n < 2+2*maxCachedLengths; n++ {
+		plaintext := make([]byte, n)
+		for i := range plaintext {
+			plaintext[i] = '0' + byte(i%10)
+		}
+		ciphertext, err := c.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		decrypted, err := c.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		if string(decrypted) != string(plaintext) {
+			t.Fatalf("length %d: round-trip failed", n)
+		}
+	}
+	if len(c.cache.entries) != maxCachedLengths {
+		t.Fatalf("cache holds %d entries, expected %d", len(c.cache.entries), maxCachedLengths)
+	}
+}
+
+func BenchmarkBlocksPerOp(b *testing.B) {
+	for _, length := range []int{6, 10, 16} {
+		b.Run(fmt.Sprintf("Len%d", length), func(b *testing.B) {
+			c, count := newCountingCipher(b, nil)
+			plaintext := []byte("0123456789012345")[:length]
+			*count = 0
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				c.Encrypt(plaintext)
+			}
+			b.ReportMetric(float64(*count)/float64(b.N), "aes-blocks/op")
+		})
+	}
+}
+
+func TestLengthCacheEquivalence(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	key := mustHex("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F")
+
+	for _, radix := range []int{2, 10, 36, 62} {
+		cached, err := NewCipher(radix, 16, key, mustHex("39383736353433323130"))
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		uncached := cached
+		uncached.cache = nil
+
+		for iter := 0; iter < 200; iter++ {
+			length := 8 + rng.Intn(2*maxCachedLengths)
+			plaintext := make([]byte, length)
+			for i := range plaintext {
+				plaintext[i] = legacyAlphabet[rng.Intn(radix)]
+			}
+
+			c1, err := cached.Encrypt(plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			c2, err := uncached.Encrypt(plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(c1, c2) {
+				t.Fatalf("radix %d length %d: cached %s, uncached %s", radix, length, c1, c2)
+			}
+
+			p1, err := cached.Decrypt(c1)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(p1, plaintext) {
+				t.Fatalf("radix %d length %d: round-trip failed", radix, length)
+			}
+		}
+	}
+}
+
+func BenchmarkLengthCache(b *testing.B) {
+	key := mustHex("2B7E151628AED2A6ABF7158809CF4F3C")
+	plaintext := []byte("0123456789012345")
+
+	cached, err := NewCipher(10, 16, key, nil)
+	if err != nil {
+		b.Fatalf("Unable to create cipher: %v", err)
+	}
+	uncached := cached
+	uncached.cache = nil
+
+	b.Run("Cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			cached.Encrypt(plaintext)
+		}
+	})
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			uncached.Encrypt(plaintext)
+		}
+	})
+}
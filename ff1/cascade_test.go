@@ -0,0 +1,189 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	fpe "github.com/Tensai75/go-fpe-bytes"
+)
+
+var (
+	_ fpe.Cipher = Cipher{}
+	_ fpe.Cipher = (*Cascade)(nil)
+)
+
+func newCascadeLayers(t *testing.T, alphabet1, alphabet2 []byte) (*Cipher, *Cipher) {
+	t.Helper()
+	c1, err := NewCipherWithAlphabet(alphabet1, 8, mustHex("2B7E151628AED2A6ABF7158809CF4F3C"), []byte("layer1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewCipherWithAlphabet(alphabet2, 8, mustHex("EF4359D8D580AA4F7F036D6F04FC6A94"), []byte("layer2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &c1, &c2
+}
+
+func TestCascade(t *testing.T) {
+	alphabet := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	c1, c2 := newCascadeLayers(t, alphabet, alphabet)
+	cascade, err := NewCascadeCipher(c1, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := [][]byte{
+		[]byte("0123456789"),
+		[]byte("hello"),
+		[]byte("0123456789abcdefghi"),
+		bytes.Repeat([]byte("z"), 200),
+	}
+	for idx, input := range inputs {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := cascade.Encrypt(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ciphertext) != len(input) {
+				t.Fatalf("ciphertext length %d, want %d", len(ciphertext), len(input))
+			}
+			for _, b := range ciphertext {
+				if bytes.IndexByte(alphabet, b) < 0 {
+					t.Fatalf("ciphertext %q is not in the alphabet", ciphertext)
+				}
+			}
+
+			// The same as the two layers applied by hand
+			mid, err := c1.Encrypt(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := c2.Encrypt(mid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ciphertext, want) {
+				t.Fatalf("ciphertext %q, want %q", ciphertext, want)
+			}
+
+			plaintext, err := cascade.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plaintext, input) {
+				t.Fatalf("plaintext %q, want %q", plaintext, input)
+			}
+
+			// Either layer alone does not give the plaintext back
+			for i, layer := range []*Cipher{c1, c2} {
+				partial, err := layer.Decrypt(ciphertext)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bytes.Equal(partial, input) {
+					t.Fatalf("layer %d alone recovered the plaintext", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestCascadeTweaks(t *testing.T) {
+	alphabet := []byte("0123456789")
+	c1, c2 := newCascadeLayers(t, alphabet, alphabet)
+	cascade, err := NewCascadeCipher(c1, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []byte("0123456789")
+
+	def, err := cascade.Encrypt(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := cascade.EncryptWithTweaks(input, []byte("layer1"), []byte("layer2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(def, same) {
+		t.Fatalf("EncryptWithTweaks with the own tweaks = %s, want %s", same, def)
+	}
+
+	swapped, err := cascade.EncryptWithTweaks(input, []byte("layer2"), []byte("layer1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(def, swapped) {
+		t.Fatal("swapping the tweaks of the layers does not change the ciphertext")
+	}
+	plaintext, err := cascade.DecryptWithTweaks(swapped, []byte("layer2"), []byte("layer1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, input) {
+		t.Fatalf("plaintext %s, want %s", plaintext, input)
+	}
+}
+
+func TestCascadeError(t *testing.T) {
+	c1, c2 := newCascadeLayers(t, []byte("0123456789"), []byte("0123456789abcdef"))
+	if _, err := NewCascadeCipher(c1, c2); err == nil {
+		t.Fatal("ciphers with different alphabets accepted")
+	}
+
+	c1, c2 = newCascadeLayers(t, []byte("0123456789"), []byte("9876543210"))
+	if _, err := NewCascadeCipher(c1, c2); err == nil {
+		t.Fatal("ciphers with differently ordered alphabets accepted")
+	}
+
+	copied := *c1
+	if _, err := NewCascadeCipher(c1, &copied); err == nil {
+		t.Fatal("copies of one cipher accepted")
+	}
+
+	family, err := NewCipherFamily(mustHex("2B7E151628AED2A6ABF7158809CF4F3C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f1, err := family.Cipher(10, 1, []byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := family.Cipher(10, 1, []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCascadeCipher(f1, f2); err == nil {
+		t.Fatal("ciphers of one Family accepted")
+	}
+
+	if _, err := NewCascadeCipher(c1, nil); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("got %v, want %v", err, ErrNotInitialized)
+	}
+	c2.Wipe()
+	if _, err := NewCascadeCipher(c1, c2); !errors.Is(err, ErrWiped) {
+		t.Fatalf("got %v, want %v", err, ErrWiped)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"container/list"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"hash/maphash"
+	"sync"
+)
+
+// DecryptCacheStats reports on the cache enabled with WithDecryptCache.
+type DecryptCacheStats struct {
+	// Hits and Misses count the decryptions answered from the cache and those
+	// that were computed, since the Cipher was created.
+	Hits, Misses uint64
+
+	// Entries is the number of plaintexts held now.
+	Entries int
+}
+
+// decryptCache is a least recently used cache of plaintexts, keyed by tweak and
+// ciphertext, shared by all copies of a Cipher. It is safe for concurrent use.
+//
+// Entries are found by a hash with a random seed, so the position of a key in the
+// map reveals nothing to an observer who does not know the seed, and the stored key
+// is then compared in constant time. Each entry records the AES block it was computed
+// with, so results computed under a key replaced by SwapKey are never returned.
+type decryptCache struct {
+	mu      sync.Mutex
+	max     int
+	seed    maphash.Seed
+	order   *list.List // of *decryptEntry, most recently used first
+	entries map[uint64]*list.Element
+
+	hits, misses uint64
+}
+
+type decryptEntry struct {
+	hash      uint64
+	block     cipher.Block
+	key       []byte // length of the tweak, tweak and ciphertext
+	plaintext []byte
+}
+
+func newDecryptCache(maxEntries int) *decryptCache {
+	return &decryptCache{
+		max:     maxEntries,
+		seed:    maphash.MakeSeed(),
+		order:   list.New(),
+		entries: make(map[uint64]*list.Element),
+	}
+}
+
+// cacheKey encodes tweak and ciphertext without ambiguity about where one ends.
+func cacheKey(tweak, X []byte) []byte {
+	key := make([]byte, 4, 4+len(tweak)+len(X))
+	binary.BigEndian.PutUint32(key, uint32(len(tweak)))
+	key = append(key, tweak...)
+	return append(key, X...)
+}
+
+func (dc *decryptCache) hash(key []byte) uint64 {
+	var h maphash.Hash
+	h.SetSeed(dc.seed)
+	h.Write(key)
+	return h.Sum64()
+}
+
+// get appends the plaintext cached for key under block to dst, if there is one.
+func (dc *decryptCache) get(block cipher.Block, key []byte, dst []byte) ([]byte, bool) {
+	h := dc.hash(key)
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if el, ok := dc.entries[h]; ok {
+		e := el.Value.(*decryptEntry)
+		if e.block == block && subtle.ConstantTimeCompare(e.key, key) == 1 {
+			dc.order.MoveToFront(el)
+			dc.hits++
+			return append(dst, e.plaintext...), true
+		}
+	}
+	dc.misses++
+	return dst, false
+}
+
+// put stores a copy of plaintext for key under block, replacing an entry with the
+// same hash and evicting the least recently used entry if the cache is full.
+func (dc *decryptCache) put(block cipher.Block, key []byte, plaintext []byte) {
+	h := dc.hash(key)
+	e := &decryptEntry{
+		hash:      h,
+		block:     block,
+		key:       key,
+		plaintext: append([]byte{}, plaintext...),
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if el, ok := dc.entries[h]; ok {
+		dc.remove(el)
+	}
+	for dc.order.Len() >= dc.max {
+		dc.remove(dc.order.Back())
+	}
+	dc.entries[h] = dc.order.PushFront(e)
+}
+
+// remove drops an entry and overwrites its plaintext. dc.mu must be held.
+func (dc *decryptCache) remove(el *list.Element) {
+	e := dc.order.Remove(el).(*decryptEntry)
+	delete(dc.entries, e.hash)
+	for i := range e.plaintext {
+		e.plaintext[i] = 0
+	}
+}
+
+// purge drops all entries and overwrites their plaintexts.
+func (dc *decryptCache) purge() {
+	dc.mu.Lock()
+	for dc.order.Len() > 0 {
+		dc.remove(dc.order.Back())
+	}
+	dc.mu.Unlock()
+}
+
+func (dc *decryptCache) stats() DecryptCacheStats {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return DecryptCacheStats{Hits: dc.hits, Misses: dc.misses, Entries: dc.order.Len()}
+}
+
+// decryptCached is the decryption of crypt with WithDecryptCache.
+func (c Cipher) decryptCached(sc *scratch, dst []byte, X []byte, tweak []byte) ([]byte, error) {
+	key := cacheKey(tweak, X)
+	if ret, ok := c.dcache.get(c.block, key, dst[:0]); ok {
+		return ret, nil
+	}
+	ret, err := c.cryptOnce(sc, dst, X, tweak, false)
+	if err != nil {
+		return ret, err
+	}
+	c.dcache.put(c.block, key, ret)
+	return ret, nil
+}
+
+// PurgeCache drops and overwrites all plaintexts held by the cache enabled with
+// WithDecryptCache, for the Cipher and all copies of it. It does nothing without it.
+func (c Cipher) PurgeCache() {
+	if c.dcache != nil {
+		c.dcache.purge()
+	}
+}
+
+// DecryptCacheStats returns the statistics of the cache enabled with WithDecryptCache,
+// shared by the Cipher and all copies of it. Without it they are all zero.
+func (c Cipher) DecryptCacheStats() DecryptCacheStats {
+	if c.dcache == nil {
+		return DecryptCacheStats{}
+	}
+	return c.dcache.stats()
+}
@@ -0,0 +1,236 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestDecryptCache(t *testing.T) {
+	testVector := testVectors[1]
+	key, tweak := mustHex(testVector.key), mustHex(testVector.tweak)
+	c, err := NewCipher(testVector.radix, 16, key, tweak, WithDecryptCache(8))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	wantStats := func(hits, misses uint64, entries int) {
+		t.Helper()
+		want := DecryptCacheStats{Hits: hits, Misses: misses, Entries: entries}
+		if got := c.DecryptCacheStats(); got != want {
+			t.Fatalf("stats %+v, want %+v", got, want)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		plaintext, err := c.Decrypt(testVector.ciphertext)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !bytes.Equal(plaintext, testVector.plaintext) {
+			t.Fatalf("Decrypt = %s, want %s", plaintext, testVector.plaintext)
+		}
+		// The caller owns the result, changing it does not change the cache
+		plaintext[0] ^= 1
+	}
+	wantStats(2, 1, 1)
+
+	// The same ciphertext under another tweak is another entry
+	other, err := c.DecryptWithTweak(testVector.ciphertext, nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if bytes.Equal(other, testVector.plaintext) {
+		t.Fatal("cached plaintext returned for another tweak")
+	}
+	wantStats(2, 2, 2)
+
+	// DecryptInto writes cached results into dst too
+	dst := make([]byte, 0, 64)
+	plaintext, err := c.DecryptInto(dst, testVector.ciphertext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if &plaintext[0] != &dst[:1][0] || !bytes.Equal(plaintext, testVector.plaintext) {
+		t.Fatalf("DecryptInto = %s, want %s in dst", plaintext, testVector.plaintext)
+	}
+	wantStats(3, 2, 2)
+
+	// Invalid input is not cached, and encryption does not touch the cache
+	if _, err := c.Decrypt([]byte("12345abc")); err == nil {
+		t.Fatal("invalid input accepted")
+	}
+	if _, err := c.Encrypt(testVector.plaintext); err != nil {
+		t.Fatalf("%v", err)
+	}
+	wantStats(3, 3, 2)
+
+	c.PurgeCache()
+	wantStats(3, 3, 0)
+
+	uncached, err := NewCipher(testVector.radix, 16, key, tweak, WithDecryptCache(0))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	if _, err := uncached.Decrypt(testVector.ciphertext); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if stats := uncached.DecryptCacheStats(); stats != (DecryptCacheStats{}) {
+		t.Fatalf("stats %+v without cache", stats)
+	}
+	uncached.PurgeCache()
+}
+
+func TestDecryptCacheEviction(t *testing.T) {
+	c, err := NewCipher(10, 0, mustHex(testVectors[0].key), nil, WithDecryptCache(2))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	a, b, d := []byte("1111111111"), []byte("2222222222"), []byte("3333333333")
+
+	steps := []struct {
+		X   []byte
+		hit bool
+	}{
+		{a, false},
+		{b, false},
+		{a, true},  // a is now the most recently used
+		{d, false}, // evicts b
+		{a, true},
+		{b, false}, // evicts d
+		{d, false}, // evicts a
+		{b, true},
+		{a, false},
+	}
+	for idx, step := range steps {
+		before := c.DecryptCacheStats()
+		if _, err := c.Decrypt(step.X); err != nil {
+			t.Fatalf("%v", err)
+		}
+		after := c.DecryptCacheStats()
+		if hit := after.Hits > before.Hits; hit != step.hit {
+			t.Fatalf("step %d, %s: hit %v, want %v", idx+1, step.X, hit, step.hit)
+		}
+		if after.Entries > 2 {
+			t.Fatalf("step %d: %d entries, limit is 2", idx+1, after.Entries)
+		}
+	}
+}
+
+func TestDecryptCacheRotation(t *testing.T) {
+	oldKey, newKey := mustHex(testVectors[0].key), mustHex(testVectors[3].key)
+	c, err := NewCipher(10, 0, oldKey, nil, WithDecryptCache(8))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	ciphertext := []byte("0123456789")
+	before, err := c.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := c.SwapKey(newKey); err != nil {
+		t.Fatalf("SwapKey: %v", err)
+	}
+	if stats := c.DecryptCacheStats(); stats.Entries != 0 {
+		t.Fatalf("%d entries after SwapKey", stats.Entries)
+	}
+	fresh, err := NewCipher(10, 0, newKey, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	want, err := fresh.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	got, err := c.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(got, want) || bytes.Equal(got, before) {
+		t.Fatalf("after SwapKey: got %s, want %s", got, want)
+	}
+
+	c.Wipe()
+	if stats := c.DecryptCacheStats(); stats.Entries != 0 {
+		t.Fatalf("%d entries after Wipe", stats.Entries)
+	}
+}
+
+// Many goroutines decrypt a set of ciphertexts larger than the cache; run with -race
+func TestDecryptCacheConcurrent(t *testing.T) {
+	key := mustHex(testVectors[0].key)
+	cached, err := NewCipher(36, 0, key, nil, WithDecryptCache(16))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	reference, err := NewCipher(36, 0, key, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	ciphertexts := make([][]byte, 40)
+	plaintexts := make([][]byte, len(ciphertexts))
+	for i := range ciphertexts {
+		ciphertexts[i] = make([]byte, 6+rng.Intn(10))
+		for j := range ciphertexts[i] {
+			ciphertexts[i][j] = legacyAlphabet[rng.Intn(36)]
+		}
+		if plaintexts[i], err = reference.Decrypt(ciphertexts[i]); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			for iter := 0; iter < 500; iter++ {
+				i := rng.Intn(len(ciphertexts))
+				plaintext, err := cached.Decrypt(ciphertexts[i])
+				if err == nil && !bytes.Equal(plaintext, plaintexts[i]) {
+					err = fmt.Errorf("Decrypt(%s) = %s, want %s", ciphertexts[i], plaintext, plaintexts[i])
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	stats := cached.DecryptCacheStats()
+	if stats.Hits+stats.Misses != 8*500 || stats.Hits == 0 || stats.Entries != 16 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import "context"
+
+// An Encryptor encrypts and decrypts with the key, tweak and options of the Cipher
+// it was created from, keeping its working memory from one call to the next.
+//
+// An Encryptor is not safe for concurrent use, create one per goroutine. The Cipher's
+// own methods take their working memory from a pool instead and may be shared freely.
+type Encryptor struct {
+	c  Cipher
+	sc scratch
+}
+
+// NewEncryptor returns an Encryptor for c. Once it has seen a message length,
+// further messages of that length are handled without allocating, apart from
+// the small temporaries math/big makes when dividing by moduli of two to four words.
+func (c Cipher) NewEncryptor() *Encryptor {
+	return &Encryptor{c: c}
+}
+
+// Encrypt encrypts src and writes the ciphertext to dst, which is grown only if its
+// capacity is less than len(src). dst may be src itself to encrypt in place.
+func (e *Encryptor) Encrypt(dst, src []byte) ([]byte, error) {
+	return e.c.crypt(context.Background(), &e.sc, dst, src, e.c.tweak, true)
+}
+
+// Decrypt is the same as Encrypt, for decryption.
+func (e *Encryptor) Decrypt(dst, src []byte) ([]byte, error) {
+	return e.c.crypt(context.Background(), &e.sc, dst, src, e.c.tweak, false)
+}
@@ -0,0 +1,117 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestEncryptor(t *testing.T) {
+	for idx, testVector := range testVectors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak), WithZeroize())
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			e := c.NewEncryptor()
+
+			// Twice, so the second call reuses the memory of the first
+			for i := 0; i < 2; i++ {
+				ciphertext, err := e.Encrypt(nil, testVector.plaintext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+					t.Fatalf("Encrypt: got %s expected %s", ciphertext, testVector.ciphertext)
+				}
+				plaintext, err := e.Decrypt(ciphertext, ciphertext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+					t.Fatalf("Decrypt: got %s expected %s", plaintext, testVector.plaintext)
+				}
+			}
+		})
+	}
+}
+
+// After a warm-up call, an Encryptor does not allocate
+func TestEncryptorAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not meaningful under the race detector")
+	}
+
+	tests := []struct {
+		radix int
+		input []byte
+	}{
+		{10, testVectors[0].plaintext},
+		{36, testVectors[2].plaintext},
+		{36, []byte("xs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwd")},
+	}
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := NewCipher(test.radix, 16, mustHex(testVectors[0].key), nil)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			e := c.NewEncryptor()
+			dst := make([]byte, len(test.input))
+			if _, err := e.Encrypt(dst, test.input); err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			if n := testing.AllocsPerRun(100, func() { e.Encrypt(dst, test.input) }); n != 0 {
+				t.Fatalf("Encrypt: %v allocs per call", n)
+			}
+			if n := testing.AllocsPerRun(100, func() { e.Decrypt(dst, test.input) }); n != 0 {
+				t.Fatalf("Decrypt: %v allocs per call", n)
+			}
+		})
+	}
+}
+
+func BenchmarkEncryptor(b *testing.B) {
+	for _, idx := range []int{0, 2} {
+		testVector := testVectors[idx]
+		c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak))
+		if err != nil {
+			b.Fatalf("Unable to create cipher: %v", err)
+		}
+		dst := make([]byte, len(testVector.plaintext))
+
+		b.Run(fmt.Sprintf("Sample%d/Cipher", idx+1), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				c.Encrypt(testVector.plaintext)
+			}
+		})
+		b.Run(fmt.Sprintf("Sample%d/Encryptor", idx+1), func(b *testing.B) {
+			e := c.NewEncryptor()
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				e.Encrypt(dst, testVector.plaintext)
+			}
+		})
+	}
+}
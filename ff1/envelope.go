@@ -0,0 +1,141 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// envelopeVersion is the version SealEnvelope writes and the only one
+// OpenEnvelope reads.
+const envelopeVersion = "1"
+
+// MaxKeyIDLen is the length limit of the key IDs of envelopes.
+const MaxKeyIDLen = 64
+
+var (
+	// ErrEnvelopeMalformed is returned, wrapped with the reason, by OpenEnvelope for
+	// a string that is not an envelope
+	ErrEnvelopeMalformed = errors.New("malformed envelope")
+
+	// ErrKeyIDInvalid is returned for a key ID that is empty, longer than
+	// MaxKeyIDLen or has other bytes than ASCII letters, digits, '.', '_' and '-'
+	ErrKeyIDInvalid = errors.New("invalid key ID")
+)
+
+// An EnvelopeVersionError is returned by OpenEnvelope for an envelope of a version
+// this package does not know, possibly written by a later one.
+type EnvelopeVersionError struct {
+	Version string
+}
+
+func (e *EnvelopeVersionError) Error() string {
+	return fmt.Sprintf("envelope has unknown version %q", e.Version)
+}
+
+// An UnknownKeyError is returned by OpenEnvelope if the resolver has no cipher for
+// the key ID of an envelope. Err is the error of the resolver, nil if it returned
+// no cipher and no error.
+type UnknownKeyError struct {
+	KeyID string
+	Err   error
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("no cipher for key ID %q", e.KeyID)
+	}
+	return fmt.Sprintf("no cipher for key ID %q: %v", e.KeyID, e.Err)
+}
+
+func (e *UnknownKeyError) Unwrap() error {
+	return e.Err
+}
+
+// A KeyResolver returns the cipher of a key ID, typically from a table of the keys
+// an application has had over time.
+type KeyResolver func(keyID string) (*Cipher, error)
+
+// validKeyID reports whether keyID may be used in an envelope.
+func validKeyID(keyID string) bool {
+	if keyID == "" || len(keyID) > MaxKeyIDLen {
+		return false
+	}
+	for i := 0; i < len(keyID); i++ {
+		switch b := keyID[i]; {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', b == '.', b == '_', b == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SealEnvelope encrypts plaintext with the default tweak of c and prefixes the
+// ciphertext with the envelope version and keyID, separated by colons, as in
+// "1:k7:4829301847". The result no longer has the format of the plaintext, but
+// tells which key encrypted it, so that keys can be rotated without tracking
+// which values use which.
+func SealEnvelope(keyID string, c *Cipher, plaintext []byte) (string, error) {
+	if !validKeyID(keyID) {
+		return "", fmt.Errorf("%w: %q", ErrKeyIDInvalid, keyID)
+	}
+	if c == nil || c.codec == nil {
+		return "", ErrNotInitialized
+	}
+	Y, err := c.Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	}
+	return envelopeVersion + ":" + keyID + ":" + string(Y), nil
+}
+
+// OpenEnvelope decrypts an envelope of SealEnvelope with the cipher resolve returns
+// for its key ID. It returns an error wrapping ErrEnvelopeMalformed if sealed is
+// not an envelope, an *EnvelopeVersionError for a version other than 1 and an
+// *UnknownKeyError if resolve fails or returns no cipher. The payload may contain
+// colons, only the first two separate the fields.
+func OpenEnvelope(resolve KeyResolver, sealed string) ([]byte, error) {
+	version, rest, ok := strings.Cut(sealed, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: no version", ErrEnvelopeMalformed)
+	}
+	if version == "" || strings.Trim(version, "0123456789") != "" || (len(version) > 1 && version[0] == '0') {
+		return nil, fmt.Errorf("%w: version %q is not a number", ErrEnvelopeMalformed, version)
+	}
+	if version != envelopeVersion {
+		return nil, &EnvelopeVersionError{Version: version}
+	}
+	keyID, payload, ok := strings.Cut(rest, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: no key ID", ErrEnvelopeMalformed)
+	}
+	if !validKeyID(keyID) {
+		return nil, fmt.Errorf("%w: invalid key ID %q", ErrEnvelopeMalformed, keyID)
+	}
+
+	c, err := resolve(keyID)
+	if err != nil || c == nil {
+		return nil, &UnknownKeyError{KeyID: keyID, Err: err}
+	}
+	return c.Decrypt([]byte(payload))
+}
@@ -0,0 +1,182 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// envelopeKeys returns ciphers for the key IDs k1 to k3, all of radix 10
+func envelopeKeys(t *testing.T) map[string]*Cipher {
+	t.Helper()
+	keys := make(map[string]*Cipher)
+	for idx, key := range []string{
+		"2B7E151628AED2A6ABF7158809CF4F3C",
+		"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F",
+		"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94",
+	} {
+		c, err := NewCipher(10, 0, mustHex(key), nil)
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		keys[fmt.Sprintf("k%d", idx+1)] = &c
+	}
+	return keys
+}
+
+func TestEnvelope(t *testing.T) {
+	keys := envelopeKeys(t)
+	resolve := func(keyID string) (*Cipher, error) {
+		return keys[keyID], nil
+	}
+
+	plaintext := []byte("0123456789")
+	seen := make(map[string]bool)
+	for _, keyID := range []string{"k1", "k2", "k3"} {
+		sealed, err := SealEnvelope(keyID, keys[keyID], plaintext)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !strings.HasPrefix(sealed, "1:"+keyID+":") || len(sealed) != len("1:"+keyID+":")+len(plaintext) {
+			t.Fatalf("Sealed with %s: got %s", keyID, sealed)
+		}
+		// Each key gives another payload
+		payload := strings.TrimPrefix(sealed, "1:"+keyID+":")
+		if seen[payload] {
+			t.Fatalf("Payload %s of %s seen before", payload, keyID)
+		}
+		seen[payload] = true
+
+		opened, err := OpenEnvelope(resolve, sealed)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if string(opened) != string(plaintext) {
+			t.Fatalf("Opened %s: got %s, expected %s", sealed, opened, plaintext)
+		}
+	}
+
+	// The NIST sample
+	sealed, _ := SealEnvelope("k1", keys["k1"], plaintext)
+	if sealed != "1:k1:2433477484" {
+		t.Fatalf("Got %s, expected 1:k1:2433477484", sealed)
+	}
+
+	// Payloads may contain colons
+	colon, err := NewCipherWithAlphabet([]byte("0123456789:"), 0, mustHex(testVectors[0].key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	sealed, err = SealEnvelope("colon", &colon, []byte("12:34:56"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	opened, err := OpenEnvelope(func(string) (*Cipher, error) { return &colon, nil }, sealed)
+	if err != nil || string(opened) != "12:34:56" {
+		t.Fatalf("Opened %s: got %s, %v", sealed, opened, err)
+	}
+}
+
+func TestSealEnvelopeErrors(t *testing.T) {
+	keys := envelopeKeys(t)
+	for idx, keyID := range []string{"", "k:1", "k 1", "kü", strings.Repeat("k", MaxKeyIDLen+1)} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := SealEnvelope(keyID, keys["k1"], []byte("0123456789")); !errors.Is(err, ErrKeyIDInvalid) {
+				t.Fatalf("Got %v, expected ErrKeyIDInvalid", err)
+			}
+		})
+	}
+	if _, err := SealEnvelope(strings.Repeat("k", MaxKeyIDLen), keys["k1"], []byte("0123456789")); err != nil {
+		t.Fatalf("Longest key ID: %v", err)
+	}
+	if _, err := SealEnvelope("k1", nil, []byte("0123456789")); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("No cipher: got %v, expected ErrNotInitialized", err)
+	}
+	if _, err := SealEnvelope("k1", keys["k1"], []byte("01234a6789")); !errors.Is(err, ErrStringNotInRadix) {
+		t.Fatalf("Invalid plaintext: got %v, expected ErrStringNotInRadix", err)
+	}
+}
+
+func TestOpenEnvelopeErrors(t *testing.T) {
+	keys := envelopeKeys(t)
+	errResolver := errors.New("key store unavailable")
+	resolve := func(keyID string) (*Cipher, error) {
+		if keyID == "down" {
+			return nil, errResolver
+		}
+		return keys[keyID], nil
+	}
+
+	var malformed, version, unknown = ErrEnvelopeMalformed, &EnvelopeVersionError{}, &UnknownKeyError{}
+	for idx, testCase := range []struct {
+		sealed   string
+		expected interface{}
+	}{
+		{"", malformed},
+		{"2433477484", malformed},
+		{"1", malformed},
+		{"1:k1", malformed},
+		{":k1:2433477484", malformed},
+		{"01:k1:2433477484", malformed},
+		{"+1:k1:2433477484", malformed},
+		{"v1:k1:2433477484", malformed},
+		{"1::2433477484", malformed},
+		{"1:k 1:2433477484", malformed},
+		{"2:k1:2433477484", version},
+		{"10:k1:2433477484", version},
+		{"1:k4:2433477484", unknown},
+		{"1:down:2433477484", unknown},
+		{"1:k1:24334774a4", ErrStringNotInRadix},
+		{"1:k1:2", errLengthBounds},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := OpenEnvelope(resolve, testCase.sealed)
+			switch expected := testCase.expected.(type) {
+			case *EnvelopeVersionError:
+				if !errors.As(err, &expected) {
+					t.Fatalf("Got %v, expected an EnvelopeVersionError", err)
+				}
+			case *UnknownKeyError:
+				if !errors.As(err, &expected) {
+					t.Fatalf("Got %v, expected an UnknownKeyError", err)
+				}
+			case error:
+				if !errors.Is(err, expected) {
+					t.Fatalf("Got %v, expected %v", err, expected)
+				}
+			}
+		})
+	}
+
+	// The error of the resolver is kept
+	_, err := OpenEnvelope(resolve, "1:down:2433477484")
+	var keyErr *UnknownKeyError
+	if !errors.As(err, &keyErr) || keyErr.KeyID != "down" || !errors.Is(err, errResolver) {
+		t.Fatalf("Got %v, expected an UnknownKeyError for down wrapping the resolver's error", err)
+	}
+	_, err = OpenEnvelope(resolve, "2:k1:2433477484")
+	var versionErr *EnvelopeVersionError
+	if !errors.As(err, &versionErr) || versionErr.Version != "2" {
+		t.Fatalf("Got %v, expected an EnvelopeVersionError for version 2", err)
+	}
+}
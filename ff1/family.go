@@ -0,0 +1,258 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrWiped is returned by a Cipher, or a Family, after Wipe has been called on it.
+var ErrWiped = errors.New("cipher has been wiped")
+
+// keyRef holds the AES block of a Cipher. It is shared by all copies of the Cipher,
+// so that Wipe and SwapKey reach each of them.
+type keyRef struct {
+	v atomic.Value // *keyState
+
+	// Serializes SwapKey and Wipe
+	mu sync.Mutex
+}
+
+// keyState is the key of a keyRef and what is derived from it, replaced as a
+// whole by SwapKey.
+type keyState struct {
+	// Number of calls using the key, first for 64-bit alignment on 32-bit platforms
+	active int64
+
+	// Set once SwapKey has replaced the key, idle is closed when no call uses it anymore
+	retired  int32
+	idle     chan struct{}
+	idleOnce sync.Once
+
+	block cipher.Block
+
+	// Key of the tags of EncryptWithTag, derived from the block on first use
+	tagOnce sync.Once
+	tag     []byte
+}
+
+func newKeyRef(block cipher.Block) *keyRef {
+	k := new(keyRef)
+	k.v.Store(&keyState{block: block})
+	return k
+}
+
+func (k *keyRef) current() *keyState {
+	st, _ := k.v.Load().(*keyState)
+	return st
+}
+
+// load returns the block, or nil after wipe.
+func (k *keyRef) load() cipher.Block {
+	if st := k.current(); st != nil {
+		return st.block
+	}
+	return nil
+}
+
+// acquire returns the current key, or nil after wipe. SwapKey does not wipe what
+// is derived from it until release has been called.
+func (k *keyRef) acquire() *keyState {
+	for {
+		st := k.current()
+		if st == nil || st.block == nil {
+			return nil
+		}
+		atomic.AddInt64(&st.active, 1)
+		if k.current() == st {
+			return st
+		}
+		// The key was replaced in between, and SwapKey may have seen no calls
+		atomic.AddInt64(&st.active, -1)
+	}
+}
+
+func (st *keyState) release() {
+	if atomic.AddInt64(&st.active, -1) == 0 && atomic.LoadInt32(&st.retired) != 0 {
+		st.idleOnce.Do(func() { close(st.idle) })
+	}
+}
+
+// replace makes block the key and returns the previous one once no call uses it
+// anymore. It returns nil after wipe.
+func (k *keyRef) replace(block cipher.Block) *keyState {
+	k.mu.Lock()
+	old := k.current()
+	if old == nil || old.block == nil {
+		k.mu.Unlock()
+		return nil
+	}
+	k.v.Store(&keyState{block: block})
+	k.mu.Unlock()
+
+	old.idle = make(chan struct{})
+	atomic.StoreInt32(&old.retired, 1)
+	if atomic.LoadInt64(&old.active) == 0 {
+		old.idleOnce.Do(func() { close(old.idle) })
+	}
+	<-old.idle
+	return old
+}
+
+func (k *keyRef) wipe() {
+	k.mu.Lock()
+	old := k.current()
+	k.v.Store(&keyState{})
+	k.mu.Unlock()
+	if old != nil {
+		old.wipe()
+	}
+}
+
+// wipe overwrites the values derived from the key.
+func (st *keyState) wipe() {
+	for i := range st.tag {
+		st.tag[i] = 0
+	}
+}
+
+// Wipe makes the Cipher and every copy of it unusable, later calls return ErrWiped.
+// It drops the reference to the AES block and overwrites the tweak, the values
+// cached per message length, which are derived from the key and tweak, and the
+// plaintexts of WithDecryptCache.
+//
+// Wipe must not be called while the Cipher, or a copy of it, is in use. The AES key
+// schedule lives inside crypto/aes and cannot be overwritten, it is left to the
+// garbage collector once nothing refers to the block. For a Cipher created by a
+// Family that is only once the Family and all of its other ciphers are gone.
+func (c Cipher) Wipe() {
+	if c.logger != nil {
+		c.logger.Log(EventWiped, c.fingerprintField())
+	}
+	if c.key != nil {
+		c.key.wipe()
+	}
+	if c.cache != nil {
+		c.cache.wipe()
+	}
+	if c.dcache != nil {
+		c.dcache.purge()
+	}
+	for i := range c.tweak {
+		c.tweak[i] = 0
+	}
+}
+
+// SwapKey replaces the key of the Cipher and every copy of it with newKey, which
+// must be 128, 192, or 256 bits long. Calls that started before keep the old key
+// until they finish, later calls use the new one; EncryptWithTag and the checks of
+// WithVerifyRoundTrip see one key throughout. The new key is expanded before any
+// call can see it, and once the last call with the old key has returned, SwapKey
+// overwrites the values derived from it, empties the cache of WithDecryptCache
+// and returns. As for Wipe, the old AES key
+// schedule is left to the garbage collector.
+//
+// The tweak, options and usage count are kept. For a Cipher created by a Family
+// only that Cipher, and its copies, change keys.
+func (c Cipher) SwapKey(newKey []byte) (err error) {
+	if c.key == nil {
+		return ErrNotInitialized
+	}
+	if c.logger != nil {
+		defer c.logSwap(len(newKey), &err)
+	}
+	block, err := newAESBlock(newKey)
+	if err != nil {
+		return err
+	}
+	old := c.key.replace(block)
+	if old == nil {
+		return ErrWiped
+	}
+	if c.cache != nil {
+		c.cache.wipeKey(old.block)
+	}
+	if c.dcache != nil {
+		c.dcache.purge()
+	}
+	old.wipe()
+	return nil
+}
+
+// A Family creates ciphers that share one AES key, and so its key schedule, but
+// each have their own alphabet, tweak and options. It is safe for concurrent use.
+type Family struct {
+	key *keyRef
+}
+
+// NewCipherFamily expands the AES key for use by the ciphers of the Family.
+// The key must be 128, 192, or 256 bits long. As for NewCipherWithAlphabet, the
+// key slice is not kept, and the ciphers of the Family copy their tweak and alphabet.
+func NewCipherFamily(key []byte) (*Family, error) {
+	aesBlock, err := newAESBlock(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Family{key: newKeyRef(aesBlock)}, nil
+}
+
+// Cipher is NewCipher with the key of the Family.
+func (f *Family) Cipher(radix int, maxTLen int, tweak []byte, opts ...Option) (*Cipher, error) {
+	alphabet, err := legacyAlphabetFor(radix)
+	if err != nil {
+		return nil, err
+	}
+	return f.CipherWithAlphabet(alphabet, maxTLen, tweak, opts...)
+}
+
+// CipherWithAlphabet is NewCipherWithAlphabet with the key of the Family.
+//
+// The ciphers of a Family are independent of each other: wiping one does not
+// affect the others or the Family.
+func (f *Family) CipherWithAlphabet(alphabet []byte, maxTLen int, tweak []byte, opts ...Option) (*Cipher, error) {
+	if f == nil || f.key == nil {
+		return nil, ErrNotInitialized
+	}
+	block := f.key.load()
+	if block == nil {
+		return nil, ErrWiped
+	}
+
+	c, err := buildCipher(alphabet, maxTLen, tweak)
+	if err != nil {
+		return nil, err
+	}
+	c.setBlock(block)
+	if err := c.init(opts); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Wipe drops the Family's reference to the AES block, so no more ciphers can be
+// created from it. Ciphers created earlier keep working until they are wiped themselves.
+func (f *Family) Wipe() {
+	if f != nil && f.key != nil {
+		f.key.wipe()
+	}
+}
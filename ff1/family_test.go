@@ -0,0 +1,367 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFamily(t *testing.T) {
+	families := make(map[string]*Family)
+	for idx, testVector := range testVectors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			f, ok := families[testVector.key]
+			if !ok {
+				var err error
+				f, err = NewCipherFamily(mustHex(testVector.key))
+				if err != nil {
+					t.Fatalf("Unable to create family: %v", err)
+				}
+				families[testVector.key] = f
+			}
+
+			c, err := f.Cipher(testVector.radix, 16, mustHex(testVector.tweak))
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			if c.block != f.key.load() {
+				t.Fatalf("the cipher does not share the AES block of its family")
+			}
+
+			ciphertext, err := c.Encrypt(testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+				t.Fatalf("Encrypt: got %s expected %s", ciphertext, testVector.ciphertext)
+			}
+			plaintext, err := c.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+				t.Fatalf("Decrypt: got %s expected %s", plaintext, testVector.plaintext)
+			}
+		})
+	}
+}
+
+func TestFamilyError(t *testing.T) {
+	if _, err := NewCipherFamily(make([]byte, 15)); err == nil {
+		t.Fatalf("expected an error for a 15 byte key")
+	}
+
+	f, err := NewCipherFamily(make([]byte, 16))
+	if err != nil {
+		t.Fatalf("Unable to create family: %v", err)
+	}
+	if _, err := f.Cipher(10, 4, make([]byte, 5)); err != ErrTweakLengthInvalid {
+		t.Fatalf("got %v, expected %v", err, ErrTweakLengthInvalid)
+	}
+	if _, err := f.CipherWithAlphabet([]byte("a"), 4, nil); err == nil {
+		t.Fatalf("expected an error for radix 1")
+	}
+}
+
+// Wipe reaches every copy of a Cipher
+func TestWipe(t *testing.T) {
+	tweak := mustHex(testVectors[1].tweak)
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), tweak)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	if _, err := c.Encrypt(testVectors[1].plaintext); err != nil {
+		t.Fatalf("%v", err)
+	}
+	copied := c
+
+	c.Wipe()
+	for _, x := range []Cipher{c, copied} {
+		if _, err := x.Encrypt(testVectors[1].plaintext); !errors.Is(err, ErrWiped) {
+			t.Fatalf("Encrypt after Wipe: got %v, expected %v", err, ErrWiped)
+		}
+		if _, err := x.Decrypt(testVectors[1].ciphertext); !errors.Is(err, ErrWiped) {
+			t.Fatalf("Decrypt after Wipe: got %v, expected %v", err, ErrWiped)
+		}
+	}
+
+	for _, v := range c.tweak {
+		if v != 0 {
+			t.Fatalf("tweak not wiped: %x", c.tweak)
+		}
+	}
+	if len(c.cache.entries) != 0 {
+		t.Fatalf("%d cached entries left after Wipe", len(c.cache.entries))
+	}
+	// The caller's tweak is not touched
+	if !reflect.DeepEqual(tweak, mustHex(testVectors[1].tweak)) {
+		t.Fatalf("Wipe overwrote the caller's tweak")
+	}
+}
+
+// Wiping a cipher of a Family leaves its siblings and the Family working, and
+// wiping the Family leaves the ciphers created from it working
+func TestFamilyWipe(t *testing.T) {
+	f, err := NewCipherFamily(mustHex(testVectors[0].key))
+	if err != nil {
+		t.Fatalf("Unable to create family: %v", err)
+	}
+	a, err := f.Cipher(10, 16, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	b, err := f.Cipher(10, 16, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	a.Wipe()
+	if _, err := a.Encrypt(testVectors[0].plaintext); !errors.Is(err, ErrWiped) {
+		t.Fatalf("Encrypt after Wipe: got %v, expected %v", err, ErrWiped)
+	}
+	if ciphertext, err := b.Encrypt(testVectors[0].plaintext); err != nil || !reflect.DeepEqual(ciphertext, testVectors[0].ciphertext) {
+		t.Fatalf("sibling after Wipe: got %s, %v", ciphertext, err)
+	}
+
+	c, err := f.Cipher(10, 16, nil)
+	if err != nil {
+		t.Fatalf("Family after wiping a cipher: %v", err)
+	}
+
+	f.Wipe()
+	if _, err := f.Cipher(10, 16, nil); !errors.Is(err, ErrWiped) {
+		t.Fatalf("Cipher after Wipe: got %v, expected %v", err, ErrWiped)
+	}
+	for _, x := range []*Cipher{b, c} {
+		if ciphertext, err := x.Encrypt(testVectors[0].plaintext); err != nil || !reflect.DeepEqual(ciphertext, testVectors[0].ciphertext) {
+			t.Fatalf("cipher after wiping the Family: got %s, %v", ciphertext, err)
+		}
+	}
+}
+
+// BenchmarkFamily compares creating a Cipher with NewCipher, which expands the key
+// every time, and from a Family
+// SwapKey reaches every copy of a Cipher and keeps everything but the key
+func TestSwapKey(t *testing.T) {
+	oldKey, newKey := mustHex(testVectors[0].key), mustHex(testVectors[3].key)
+	tweak := mustHex(testVectors[1].tweak)
+	plaintext := testVectors[1].plaintext
+
+	c, err := NewCipher(10, 16, oldKey, tweak, WithUsageLimit(4))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	before, err := c.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	copied := c
+
+	if err := c.SwapKey(make([]byte, 17)); err == nil {
+		t.Fatal("17-byte key accepted")
+	}
+	if got, err := copied.Encrypt(plaintext); err != nil || !bytes.Equal(got, before) {
+		t.Fatalf("after a failed SwapKey: got %s, %v, want %s", got, err, before)
+	}
+
+	if err := c.SwapKey(newKey); err != nil {
+		t.Fatalf("SwapKey: %v", err)
+	}
+	fresh, err := NewCipher(10, 16, newKey, tweak)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	want, err := fresh.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	got, err := copied.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("after SwapKey: got %s, want %s", got, want)
+	}
+	for _, e := range c.cache.entries {
+		if e.block != c.key.load() {
+			t.Fatal("cache entry for the old key left after SwapKey")
+		}
+	}
+
+	// Tags follow the key too
+	ciphertext, tag, err := c.EncryptWithTag(plaintext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := fresh.DecryptVerified(ciphertext, tag); err != nil {
+		t.Fatalf("tag after SwapKey: %v", err)
+	}
+
+	// The usage count is kept, all four encryptions are used up
+	if _, err := c.Encrypt(plaintext); !errors.Is(err, ErrKeyUsageExceeded) {
+		t.Fatalf("got %v, want %v", err, ErrKeyUsageExceeded)
+	}
+
+	c.Wipe()
+	if err := copied.SwapKey(oldKey); !errors.Is(err, ErrWiped) {
+		t.Fatalf("SwapKey after Wipe: got %v, want %v", err, ErrWiped)
+	}
+	var zero Cipher
+	if err := zero.SwapKey(oldKey); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("SwapKey of zero Cipher: got %v, want %v", err, ErrNotInitialized)
+	}
+}
+
+// Every ciphertext produced while the key is swapped back and forth is the
+// encryption under exactly one of the keys; run with -race
+func TestSwapKeyConcurrent(t *testing.T) {
+	keys := [][]byte{mustHex(testVectors[0].key), mustHex(testVectors[3].key)}
+	var refs [2]Cipher
+	for i, key := range keys {
+		var err error
+		if refs[i], err = NewCipher(10, 0, key, nil); err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+	}
+
+	c, err := NewCipher(10, 0, keys[0], nil, WithVerifyRoundTrip())
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	type result struct {
+		plaintext, ciphertext, tag []byte
+	}
+	const goroutines = 8
+	var (
+		wg      sync.WaitGroup
+		done    int32
+		ops     int64
+		mu      sync.Mutex
+		results []result
+		errs    = make(chan error, goroutines)
+	)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			var local []result
+			for atomic.LoadInt32(&done) == 0 {
+				plaintext := make([]byte, 6+rng.Intn(20))
+				for i := range plaintext {
+					plaintext[i] = '0' + byte(rng.Intn(10))
+				}
+				r := result{plaintext: plaintext}
+				var err error
+				if g%2 == 0 {
+					r.ciphertext, err = c.Encrypt(plaintext)
+				} else {
+					r.ciphertext, r.tag, err = c.EncryptWithTag(plaintext)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				local = append(local, r)
+				atomic.AddInt64(&ops, 1)
+
+				// Give SwapKey a chance on machines with few CPUs
+				runtime.Gosched()
+			}
+			mu.Lock()
+			results = append(results, local...)
+			mu.Unlock()
+		}(g)
+	}
+
+	for i := 1; i <= 100; i++ {
+		// Let some encryptions run with each key
+		for seen := atomic.LoadInt64(&ops); atomic.LoadInt64(&ops) == seen; {
+			time.Sleep(100 * time.Microsecond)
+		}
+		if err := c.SwapKey(keys[i%2]); err != nil {
+			t.Fatalf("SwapKey: %v", err)
+		}
+	}
+	atomic.StoreInt32(&done, 1)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("%v", err)
+	}
+
+	for _, r := range results {
+		matches := 0
+		for _, ref := range refs {
+			if r.tag != nil {
+				if plaintext, err := ref.DecryptVerified(r.ciphertext, r.tag); err == nil && bytes.Equal(plaintext, r.plaintext) {
+					matches++
+				}
+				continue
+			}
+			plaintext, err := ref.Decrypt(r.ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if bytes.Equal(plaintext, r.plaintext) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			t.Fatalf("%s encrypts to %s under %d of the keys, want exactly one", r.plaintext, r.ciphertext, matches)
+		}
+	}
+	if len(results) == 0 {
+		t.Fatal("no encryptions during the swaps")
+	}
+}
+
+func BenchmarkFamily(b *testing.B) {
+	key := mustHex(testVectors[0].key)
+	tweak := mustHex(testVectors[1].tweak)
+
+	b.Run("NewCipher", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			NewCipher(10, 16, key, tweak)
+		}
+	})
+	b.Run("Family", func(b *testing.B) {
+		f, err := NewCipherFamily(key)
+		if err != nil {
+			b.Fatalf("Unable to create family: %v", err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			f.Cipher(10, 16, tweak)
+		}
+	})
+}
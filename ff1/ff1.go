@@ -19,16 +19,33 @@ See the License for the specific language governing permissions and limitations
 
 // Package ff1 implements the FF1 format-preserving encryption
 // algorithm/scheme
+//
+// Input that is not in the alphabet is rejected before any work with the key,
+// in a time that only depends on its length. Past that point the implementation
+// is not constant time: math/big arithmetic for halves wider than 64 bits, the
+// hardware division of the 64-bit path and the alphabet table lookups all take
+// time or touch memory depending on the data.
+//
+// No argument to the constructors, Encrypt or Decrypt makes them panic, invalid
+// arguments are reported as errors. FuzzNewCipher and FuzzEncryptDecrypt check this.
+// The Must variants of the constructors are the exception, they panic on purpose.
 package ff1
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"math/bits"
+	"runtime"
+	"sync"
+	"time"
 
 	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
 )
@@ -40,36 +57,84 @@ const (
 	numRounds     = 10
 	blockSize     = aes.BlockSize
 	halfBlockSize = blockSize / 2
+
+	// Size of a big.Word in bytes
+	wordBytes = bits.UintSize / 8
 )
 
 var (
-	// For all AES-CBC calls, IV is always 0
-	ivZero = make([]byte, blockSize)
-
 	// ErrStringNotInRadix is returned if input or intermediate strings cannot be parsed in the given radix
 	ErrStringNotInRadix = errors.New("string is not within base/radix")
 
 	// ErrTweakLengthInvalid is returned if the tweak length is not in the given range
 	ErrTweakLengthInvalid = errors.New("tweak must be between 0 and given maxTLen, inclusive")
-)
 
-// Need this for the SetIV function which CBCEncryptor has, but cipher.BlockMode interface doesn't.
-type cbcMode interface {
-	cipher.BlockMode
-	SetIV([]byte)
-}
+	// ErrInputTooLong is returned, wrapped with the lengths, for a message longer than
+	// the limit set with WithMaxInputLen or than the 2^32-1 numerals FF1 allows
+	ErrInputTooLong = errors.New("input too long")
+
+	// ErrKeyLengthInvalid is returned for a key that is not 16, 24 or 32 bytes long
+	ErrKeyLengthInvalid = errors.New("key length must be 128, 192, or 256 bits")
+
+	// ErrNotInitialized is returned by the methods of a Cipher that was not made by
+	// one of the constructors, such as the zero value
+	ErrNotInitialized = errors.New("cipher is not initialized, use NewCipher")
+)
 
 // A Cipher is an instance of the FF1 mode of format preserving encryption
 // using a particular key, radix, and tweak
 type Cipher struct {
 	tweak   []byte
-	codec   fpeUtils.Codec
+	codec   *fpeUtils.Codec
 	minLen  uint32
 	maxLen  uint32
 	maxTLen int
 
-	// Re-usable CBC encryptor with exported SetIV function
-	cbcEncryptor cipher.BlockMode
+	// Overwrite intermediate plaintext-derived values before returning
+	zeroize bool
+
+	// AES block used for both the CBC-MAC and the PRF output expansion.
+	// cipher.Block is safe for concurrent use, all chaining state is per call.
+	// Each call reloads it from key, which is shared by all copies of the Cipher.
+	block cipher.Block
+	key   *keyRef
+
+	// Per message length values, shared by all copies of the Cipher
+	cache *lengthCache
+
+	// Powers of the radix, shared by all copies of the Cipher
+	powers *powerTable
+
+	// Numeral conversions for the uint64 rounds, specialized for some radices
+	// unless genericArithmetic is set
+	conv              radixConv
+	genericArithmetic bool
+
+	// Restrictions of NewCipherFIPS apply
+	fips bool
+
+	// Decrypt every ciphertext again before returning it
+	verifyRoundTrip bool
+
+	// Length of the tags of EncryptWithTag
+	tagLen int
+
+	// Plaintexts of recent decryptions, shared by all copies of the Cipher
+	dcache *decryptCache
+
+	// Encryptions counted against WithUsageLimit, shared by all copies of the Cipher
+	usage *usageCounter
+
+	// Number of extra PRF output blocks per round from which they are
+	// generated in parallel, 0 to never do so
+	parallelMinBlocks int
+
+	// Told about every encryption and decryption if set
+	metrics MetricsHook
+	tracer  TraceHook
+
+	// Told about the life cycle and failures of the Cipher if set
+	logger Logger
 }
 
 const (
@@ -78,25 +143,88 @@ const (
 )
 
 // NewCipher is provided for backwards compatibility for old client code.
-func NewCipher(radix int, maxTLen int, key []byte, tweak []byte) (Cipher, error) {
-	if radix > len(legacyAlphabet) {
-		return Cipher{}, fmt.Errorf("radix %d exceeds legacy alphabet length %d", radix, len(legacyAlphabet))
+func NewCipher(radix int, maxTLen int, key []byte, tweak []byte, opts ...Option) (Cipher, error) {
+	alphabet, err := legacyAlphabetFor(radix)
+	if err != nil {
+		return Cipher{}, err
 	}
-	return NewCipherWithAlphabet([]byte(legacyAlphabet[:radix]), maxTLen, key, tweak)
+	return NewCipherWithAlphabet(alphabet, maxTLen, key, tweak, opts...)
+}
+
+// legacyAlphabetFor returns the first radix characters of the legacy alphabet.
+func legacyAlphabetFor(radix int) ([]byte, error) {
+	if radix < 0 || radix > len(legacyAlphabet) {
+		return nil, fmt.Errorf("radix %d is outside the legacy alphabet length [0..%d]", radix, len(legacyAlphabet))
+	}
+	return []byte(legacyAlphabet[:radix]), nil
 }
 
 // NewCipherWithAlphabet initializes a new FF1 Cipher for encryption or decryption use
 // based on the alphabet, max tweak length, key and tweak parameters.
-func NewCipherWithAlphabet(alphabet []byte, maxTLen int, key []byte, tweak []byte) (Cipher, error) {
-	var newCipher Cipher
+// Optional behaviour can be enabled by passing one or more Options.
+//
+// The Cipher keeps its own copies of the alphabet and tweak, and does not keep
+// the key at all: it is only read by aes.NewCipher, which expands it into a key
+// schedule of its own. The caller may change or reuse all three slices afterwards.
+func NewCipherWithAlphabet(alphabet []byte, maxTLen int, key []byte, tweak []byte, opts ...Option) (Cipher, error) {
+	aesBlock, err := newAESBlock(key)
+	if err != nil {
+		return Cipher{}, err
+	}
+
+	newCipher, err := buildCipher(alphabet, maxTLen, tweak)
+	if err != nil {
+		return newCipher, err
+	}
+	newCipher.setBlock(aesBlock)
+
+	if err := newCipher.init(opts); err != nil {
+		return Cipher{}, err
+	}
+	return newCipher, nil
+}
+
+// NewBinaryCipher creates a Cipher for opaque byte strings, whose alphabet is all
+// 256 byte values in ascending order. Every byte is a numeral of its own value, so
+// the Cipher needs no translation between bytes and numerals and accepts any input.
+//
+// With radix 256, messages of one byte already reach the domain of 100 this
+// package requires. NIST SP 800-38G Rev. 1 asks for a domain of at least 1,000,000,
+// which takes 3 bytes; use NewCipherFIPS with the same alphabet to enforce it.
+func NewBinaryCipher(maxTLen int, key []byte, tweak []byte, opts ...Option) (*Cipher, error) {
+	alphabet := make([]byte, 256)
+	for i := range alphabet {
+		alphabet[i] = byte(i)
+	}
+	c, err := NewCipherWithAlphabet(alphabet, maxTLen, key, tweak, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
 
+// newAESBlock checks the key length and expands the key.
+func newAESBlock(key []byte) (cipher.Block, error) {
 	keyLen := len(key)
 
 	// Check if the key is 128, 192, or 256 bits = 16, 24, or 32 bytes
 	if (keyLen != 16) && (keyLen != 24) && (keyLen != 32) {
-		return newCipher, errors.New("key length must be 128, 192, or 256 bits")
+		return nil, ErrKeyLengthInvalid
 	}
 
+	// aes.NewCipher automatically returns the correct block based on the length of the key passed in
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("failed to create AES block")
+	}
+	return aesBlock, nil
+}
+
+// buildCipher validates the alphabet and tweak and sets up everything in a Cipher
+// apart from the AES block and the options, see init.
+func buildCipher(alphabet []byte, maxTLen int, tweak []byte) (Cipher, error) {
+	var newCipher Cipher
+
 	codec, err := fpeUtils.NewCodec(alphabet)
 	if err != nil {
 		return newCipher, fmt.Errorf("error making codec: %s", err)
@@ -105,17 +233,21 @@ func NewCipherWithAlphabet(alphabet []byte, maxTLen int, key []byte, tweak []byt
 	radix := codec.Radix()
 
 	// FF1 allows radices in [2, 256],
-	if (radix < 2) || (radix > 256) {
-		return newCipher, fmt.Errorf("radix must be between 2 and 256: %d supplied", radix)
+	if err := fpeUtils.CheckRadix(uint64(radix)); err != nil {
+		return newCipher, err
 	}
 
 	// Make sure the length of given tweak is in range
-	if len(tweak) > maxTLen {
+	if !tweakLenValid(len(tweak), maxTLen) {
 		return newCipher, ErrTweakLengthInvalid
 	}
 
-	// Calculate minLength
-	minLen := uint32(math.Ceil(math.Log(feistelMin) / math.Log(float64(radix))))
+	// Calculate minLength, the smallest length with radix^minLength >= feistelMin
+	minLength, err := fpeUtils.MinLengthForDomain(uint64(radix), big.NewInt(feistelMin))
+	if err != nil {
+		return newCipher, err
+	}
+	minLen := uint32(minLength)
 
 	var maxLen uint32 = math.MaxUint32
 
@@ -124,26 +256,61 @@ func NewCipherWithAlphabet(alphabet []byte, maxTLen int, key []byte, tweak []byt
 		return newCipher, errors.New("minLen invalid, adjust your radix")
 	}
 
-	// aes.NewCipher automatically returns the correct block based on the length of the key passed in
-	aesBlock, err := aes.NewCipher(key)
-	if err != nil {
-		return newCipher, errors.New("failed to create AES block")
-	}
-
-	cbcEncryptor := cipher.NewCBCEncrypter(aesBlock, ivZero)
-
-	newCipher.tweak = tweak
-	newCipher.codec = codec
+	// The Cipher keeps its own copy, which Wipe overwrites
+	newCipher.tweak = append([]byte{}, tweak...)
+	newCipher.codec = &codec
 	newCipher.minLen = minLen
 	newCipher.maxLen = maxLen
 	newCipher.maxTLen = maxTLen
-	newCipher.cbcEncryptor = cbcEncryptor
+	newCipher.tagLen = defaultTagLen
+	newCipher.cache = newLengthCache()
+	newCipher.powers = newPowerTable(uint64(radix))
 
 	return newCipher, nil
 }
 
+// setBlock makes block the AES block of the Cipher and of all copies made from now on.
+func (c *Cipher) setBlock(block cipher.Block) {
+	c.block = block
+	c.key = newKeyRef(block)
+}
+
+// init applies the options, once the AES block is set, and what depends on them.
+func (c *Cipher) init(opts []Option) error {
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.maxLen < c.minLen {
+		return fmt.Errorf("maximum input length %d is below the minimum length %d", c.maxLen, c.minLen)
+	}
+	if c.tagLen < minTagLen || c.tagLen > sha256.Size {
+		return fmt.Errorf("tag length %d is not within %d and %d bytes", c.tagLen, minTagLen, sha256.Size)
+	}
+	c.conv = newRadixConv(uint64(c.codec.Radix()), c.genericArithmetic)
+	if c.logger != nil {
+		c.logCreated()
+	}
+	return nil
+}
+
+// MinLen returns the length of the shortest message the Cipher accepts.
+func (c Cipher) MinLen() int {
+	return int(c.minLen)
+}
+
+// Alphabet returns the unique bytes of the alphabet of the Cipher, in the order of
+// the numerals they stand for. The returned slice is a copy.
+func (c Cipher) Alphabet() []byte {
+	if c.codec == nil {
+		return nil
+	}
+	return c.codec.Alphabet()
+}
+
 // Encrypt encrypts the byte slice X over the current FF1 parameters
-// and returns the ciphertext of the same length and format
+// and returns the ciphertext of the same length and format.
+// The returned slice is newly allocated and belongs to the caller, later
+// calls never write to it.
 func (c Cipher) Encrypt(X []byte) ([]byte, error) {
 	return c.EncryptWithTweak(X, c.tweak)
 }
@@ -154,88 +321,177 @@ func (c Cipher) Encrypt(X []byte) ([]byte, error) {
 // override the tweak for each unique data input, which is a practical
 // use-case of FPE for things like credit card numbers.
 func (c Cipher) EncryptWithTweak(X []byte, tweak []byte) ([]byte, error) {
-	var ret []byte
-	var err error
-
-	// Byte slice X contains a sequence of bytes. Convert into an array of indices into
-	// the alphabet embedded in the codec.
-	Xn, err := c.codec.Encode(X)
-	if err != nil {
-		return ret, ErrStringNotInRadix
-	}
+	return c.crypt(context.Background(), nil, nil, X, tweak, true)
+}
 
-	n := uint32(len(Xn))
-	t := len(tweak)
+// EncryptInto is the same as Encrypt except the ciphertext is written to dst,
+// which is grown only if its capacity is less than len(X), and returned resliced
+// to len(X). If dst is too small, the result is newly allocated as for Encrypt,
+// it never uses memory of the Cipher. dst may be X itself to encrypt in place. With a large enough dst,
+// EncryptInto does not allocate for inputs whose halves fit in 64 bits.
+func (c Cipher) EncryptInto(dst []byte, X []byte) ([]byte, error) {
+	return c.crypt(context.Background(), nil, dst, X, c.tweak, true)
+}
 
-	// Check if message length is within minLength and maxLength bounds
-	if (n < c.minLen) || (n > c.maxLen) {
-		return ret, errors.New("message length is not within min and max bounds")
-	}
+// Decrypt decrypts the byte slice X over the current FF1 parameters
+// and returns the plaintext of the same length and format.
+// As for Encrypt, the returned slice belongs to the caller.
+func (c Cipher) Decrypt(X []byte) ([]byte, error) {
+	return c.DecryptWithTweak(X, c.tweak)
+}
 
-	// Make sure the length of given tweak is in range
-	if len(tweak) > c.maxTLen {
-		return ret, ErrTweakLengthInvalid
-	}
+// DecryptWithTweak is the same as Decrypt except it uses the
+// tweak from the parameter rather than the current Cipher's tweak
+// This allows you to re-use a single Cipher (for a given key) and simply
+// override the tweak for each unique data input, which is a practical
+// use-case of FPE for things like credit card numbers.
+func (c Cipher) DecryptWithTweak(X []byte, tweak []byte) ([]byte, error) {
+	return c.crypt(context.Background(), nil, nil, X, tweak, false)
+}
 
-	radix := c.codec.Radix()
+// DecryptInto is the same as Decrypt except the plaintext is written to dst,
+// in the same way as for EncryptInto.
+func (c Cipher) DecryptInto(dst []byte, X []byte) ([]byte, error) {
+	return c.crypt(context.Background(), nil, dst, X, c.tweak, false)
+}
 
-	// Calculate split point
-	u := n / 2
-	v := n - u
+// crypt implements both FF1.Encrypt and FF1.Decrypt (NIST SP 800-38G, Algorithms 7 and 8).
+// The two only differ in the order of the rounds, which half is fed to the PRF,
+// and whether the PRF output is added to or subtracted from the other half.
+// The result is written to dst, which is only allocated if it is too small.
+// The working memory comes from sc, or from the scratch pool if sc is nil.
+// ctx is only passed to the TraceHook.
+func (c Cipher) crypt(ctx context.Context, sc *scratch, dst []byte, X []byte, tweak []byte, encrypt bool) (ret []byte, err error) {
+	if c.metrics != nil {
+		defer observe(c.metrics, encrypt, time.Now(), &err)
+	}
+	if c.tracer != nil {
+		defer traceEnd(c.tracer, c.tracer.StartOp(ctx, opName(encrypt), len(X)), &err)
+	}
+	if c.logger != nil {
+		defer logFailure(c.logger, encrypt, len(X), &err)
+	}
 
-	// Split the message
-	A := Xn[:u]
-	B := Xn[u:]
+	// Hold on to the current key for the whole call, as pin does
+	if c.key != nil {
+		st := c.key.acquire()
+		if st == nil {
+			return nil, ErrWiped
+		}
+		defer st.release()
+		c.block, c.key = st.block, nil
+	}
+	if encrypt && c.verifyRoundTrip {
+		return c.encryptVerified(sc, dst, X, tweak)
+	}
+	if !encrypt && c.dcache != nil {
+		return c.decryptCached(sc, dst, X, tweak)
+	}
+	return c.cryptOnce(sc, dst, X, tweak, encrypt)
+}
 
-	// Byte lengths
-	b := int(math.Ceil(math.Ceil(float64(v)*math.Log2(float64(radix))) / 8))
-	d := int(4*math.Ceil(float64(b)/4) + 4)
+// pin returns a copy of c that uses the current key of c, whatever SwapKey does,
+// and that key, which the caller must release when done. The copy does not follow
+// Wipe or SwapKey anymore.
+func (c Cipher) pin() (Cipher, *keyState, error) {
+	if c.key == nil {
+		return c, nil, ErrNotInitialized
+	}
+	st := c.key.acquire()
+	if st == nil {
+		return c, nil, ErrWiped
+	}
+	c.block, c.key = st.block, nil
+	return c, st, nil
+}
 
-	maxJ := int(math.Ceil(float64(d) / 16))
+// cryptOnce is crypt without the check of WithVerifyRoundTrip.
+func (c Cipher) cryptOnce(sc *scratch, dst []byte, X []byte, tweak []byte, encrypt bool) ([]byte, error) {
+	var ret []byte
+	var err error
 
-	numPad := (-t - b - 1) % 16
-	if numPad < 0 {
-		numPad += 16
+	if c.block == nil {
+		return ret, ErrNotInitialized
 	}
 
-	// Calculate P, doesn't change in each loop iteration
-	// P's length is always 16, so it can stay on the stack, separate from buf
-	const lenP = blockSize
-	P := make([]byte, blockSize)
+	// Check if message length is within minLength and maxLength bounds. The
+	// comparison is done before narrowing, so lengths past 2^32 cannot wrap.
+	if uint64(len(X)) < uint64(c.minLen) || uint64(len(X)) > uint64(c.maxLen) {
+		return ret, c.lengthError(len(X))
+	}
+	n := uint32(len(X))
+	t := len(tweak)
 
-	P[0] = 0x01
-	P[1] = 0x02
-	P[2] = 0x01
+	// Make sure the length of given tweak is in range
+	if !tweakLenValid(t, c.maxTLen) {
+		return ret, ErrTweakLengthInvalid
+	}
 
-	// radix must fill 3 bytes, so pad 1 zero byte
-	P[3] = 0x00
-	binary.BigEndian.PutUint16(P[4:6], uint16(radix))
+	// Reject invalid input before any work with the key. Valid takes the same time
+	// wherever the first invalid byte is, and the error carries no position.
+	if !c.codec.Valid(X) {
+		return ret, ErrStringNotInRadix
+	}
 
-	P[6] = 0x0a
-	P[7] = byte(u) // overflow automatically does the modulus
+	if encrypt && c.usage != nil && !c.usage.take() {
+		return ret, ErrKeyUsageExceeded
+	}
 
-	binary.BigEndian.PutUint32(P[8:12], n)
-	binary.BigEndian.PutUint32(P[12:lenP], uint32(t))
+	// Everything that only depends on the message length: the split point, the moduli
+	// radix^u and radix^v, and the byte lengths b and d
+	params, err := c.params(n)
+	if err != nil {
+		return ret, err
+	}
+	b, d := params.b, params.d
 
 	// Determine lengths of byte slices
-
-	// Q's length is known to always be t+b+1+numPad, to be multiple of 16
-	lenQ := t + b + 1 + numPad
-
-	// For a given input X, the size of PQ is deterministic: 16+lenQ
-	lenPQ := lenP + lenQ
-
-	// lenY := blockSize * maxJ
-
+	//
+	// Q's length is always t+b+1+numPad, to be multiple of 16
+	//
+	// The leading blocks of Q only hold the tweak and zero padding, which are the
+	// same in every round. They are absorbed into the CBC-MAC state together with P
+	// once per call (or once per message length for the default tweak), so each round
+	// only runs the CBC-MAC over Q[qOff:], which holds the round number and the numeral.
+	//
 	// buf holds multiple components that change in each loop iteration
-	// Ensure there's enough space for max(lenPQ, lenY)
-	// Q, PQ, and Y (R, xored) will share underlying memory
+	// Q and Y (R, xored) will share underlying memory
 	// The total buffer length needs space for:
 	// Q (lenQ)
-	// PQ (lenPQ)
-	// Y = R(last block of PQ) + xored blocks (maxJ - 1)
-	totalBufLen := lenQ + lenPQ + (maxJ-1)*blockSize
-	buf := make([]byte, totalBufLen)
+	// Y = R + xored blocks (maxJ - 1)
+	// the counter block used to generate the xored blocks
+	//
+	// The big.Ints of the scratch have enough room for the largest intermediate of the
+	// rounds, so none of them needs to grow in the rounds.
+	layout, err := layoutFor(t, b, d)
+	if err != nil {
+		return ret, err
+	}
+	lenQ, qOff := layout.lenQ, layout.qOff
+
+	if sc == nil {
+		sc = getScratch(layout.bufLen, layout.wordsPer)
+		defer sc.release(c.zeroize)
+	} else {
+		sc.prepare(layout.bufLen, layout.wordsPer)
+		if c.zeroize {
+			defer sc.wipe()
+		}
+	}
+	buf := sc.buf
+
+	// Byte slice X contains a sequence of bytes. Convert into an array of indices into
+	// the alphabet embedded in the codec. X was validated above, this only fails if
+	// the caller changed it in the meantime.
+	sc.numerals, err = c.codec.EncodeInto(sc.numerals, X)
+	if err != nil {
+		return ret, ErrStringNotInRadix
+	}
+	Xn := sc.numerals
+
+	// Split the message
+	A := Xn[:params.u]
+	B := Xn[params.u:]
 
 	// Q will use the first lenQ bytes of buf
 	// Only the last b+1 bytes of Q change for each loop iteration
@@ -244,188 +500,369 @@ func (c Cipher) EncryptWithTweak(X []byte, tweak []byte) ([]byte, error) {
 	// First t bytes of Q are the tweak, next numPad bytes are already zero-valued
 	copy(Q[:t], tweak)
 
-	// Use PQ as a combined storage for P||Q
-	// PQ will use the next 16+lenQ bytes of buf
-	// Important: PQ is going to be encrypted in place,
-	// so P and Q will also remain separate and copied in each iteration
-	PQ := buf[lenQ : lenQ+lenPQ]
-
-	// These are re-used in the for loop below
-	// variables names prefixed with "num" indicate big integers
-	var (
-		numA, numB, numC big.Int
-		numRadix, numY   big.Int
-		numU, numV       big.Int
-		numModU, numModV big.Int
-		numBBytes        []byte
-	)
-
-	numRadix.SetInt64(int64(radix))
+	// The state for the default tweak is part of the cached parameters
+	state := params.state
+	if !bytes.Equal(tweak, c.tweak) {
+		state, err = c.macPrefix(n, params.u, t, Q[:qOff])
+		if err != nil {
+			return ret, err
+		}
+	}
 
-	// Y starts at the start of last block of PQ, requires lenY bytes
-	// R is part of Y, Overlaps part of PQ
-	Y := buf[lenQ+lenPQ-blockSize:]
+	// Y starts right after Q, requires maxJ blocks plus the counter block
+	Y := buf[lenQ:]
 
-	// R starts at Y, requires blockSize bytes, which uses the last block of PQ
-	// R := Y[:blockSize]
+	// Xn is overwritten in place with the resulting numeral string
+	if params.fits64 {
+		err = c.rounds64(params, Q, qOff, Y, &state, A, B, encrypt)
+	} else {
+		err = c.roundsBig(params, sc, Q, qOff, Y, &state, A, B, encrypt)
+	}
+	if err != nil {
+		return ret, err
+	}
 
-	// This will only be needed if maxJ > 1, for the inner for loop
-	// xored uses the blocks after R in Y, if any
-	xored := Y[blockSize:]
+	// Only X has been read so far, so dst may share its memory. Xn lives in the
+	// scratch, which is reused by later calls, so the result must never be built
+	// in it: DecodeInto writes to dst or to a new slice.
+	return c.codec.DecodeInto(dst, Xn)
+}
 
-	// Pre-calculate the modulus since it's only one of 2 values,
-	// depending on whether i is even or odd
-	numU.SetInt64(int64(u))
-	numV.SetInt64(int64(v))
+// roundsBig runs the Feistel rounds (steps 6i to 6vi) with big.Int arithmetic,
+// replacing the numerals in A and B with the result. Q must hold the tweak and
+// padding, the round number and the numeral fed to the PRF occupy its last b+1
+// bytes and only Q[qOff:] is absorbed into the CBC-MAC state each round.
+func (c Cipher) roundsBig(params *lengthEntry, sc *scratch, Q []byte, qOff int, Y []byte, state *[blockSize]byte, A, B []uint8, encrypt bool) error {
+	radix := uint64(c.codec.Radix())
+	b, d := params.b, params.d
 
-	numModU.Exp(&numRadix, &numU, nil)
-	numModV.Exp(&numRadix, &numV, nil)
+	// variables names prefixed with "num" indicate big integers
+	// numA, numB, numC and numR rotate through the roles of A, B, the sum A+y (or
+	// difference B-y) and the reduced result, so nothing is copied between rounds.
+	numA, numB, numC, numR := &sc.nums[0], &sc.nums[1], &sc.nums[2], &sc.nums[3]
+	numY, numQ := &sc.nums[4], &sc.nums[5]
+	pw, chunk := &sc.small[0], &sc.small[1]
 
 	// Bootstrap for 1st round
-	numA, err = fpeUtils.Num(A, uint64(radix))
-	if err != nil {
-		return ret, ErrStringNotInRadix
+	if err := num(numA, numQ, pw, chunk, A, radix); err != nil {
+		return err
 	}
-
-	numB, err = fpeUtils.Num(B, uint64(radix))
-	if err != nil {
-		return ret, ErrStringNotInRadix
+	if err := num(numB, numQ, pw, chunk, B, radix); err != nil {
+		return err
 	}
 
 	// Main Feistel Round, 10 times
-	for i := 0; i < numRounds; i++ {
-		// Calculate the dynamic parts of Q
-		Q[t+numPad] = byte(i)
+	for round := 0; round < numRounds; round++ {
+		// Encryption runs the rounds forwards and feeds B to the PRF,
+		// decryption runs them backwards and feeds A
+		i := round
+		numFeed := numB
+		if !encrypt {
+			i = numRounds - 1 - round
+			numFeed = numA
+		}
 
-		numBBytes = numB.Bytes()
+		// Calculate the dynamic parts of Q
+		Q[len(Q)-b-1] = byte(i)
 
-		// Zero out the rest of Q
-		// When the second half of X is all 0s, numB is 0, so numBytes is an empty slice
-		// So, zero out the rest of Q instead of just the middle bytes, which covers the numB=0 case
+		// The numeral must take up exactly the last b bytes. FillBytes zero-extends,
+		// which also covers the case where the fed half is all 0s
 		// See https://github.com/capitalone/fpe/issues/10
-		for j := t + numPad + 1; j < lenQ; j++ {
-			Q[j] = 0x00
+		numFeed.FillBytes(Q[len(Q)-b:])
+
+		if err := c.prf(Y, Q[qOff:], state, params.maxJ); err != nil {
+			return err
 		}
 
-		// B must only take up the last b bytes
-		copy(Q[lenQ-len(numBBytes):], numBBytes)
+		numY.SetBytes(Y[:d])
 
-		// PQ = P||Q
-		// Since prf/ciph will operate in place, P and Q have to be copied into PQ,
-		// for each iteration to reset the contents
-		copy(PQ[:blockSize], P)
-		copy(PQ[blockSize:], Q)
+		if encrypt {
+			numC.Add(numA, numY)
+		} else {
+			numC.Sub(numB, numY)
+		}
 
-		// R is guaranteed to be of length 16
-		R, err := c.prf(PQ)
-		if err != nil {
-			return ret, err
+		modulus := params.modV
+		if i%2 == 0 {
+			modulus = params.modU
 		}
 
-		// Step 6iii
-		for j := 1; j < maxJ; j++ {
-			// offset is used to calculate which xored block to use in this iteration
-			offset := (j - 1) * blockSize
+		// QuoRem reuses the storage of numQ and numR, unlike Mod. Its remainder takes
+		// the sign of numC, which is negative when decrypting if y > B.
+		numQ.QuoRem(numC, modulus, numR)
+		if numR.Sign() < 0 {
+			numR.Add(numR, modulus)
+		}
 
-			// Since xorBytes operates in place, xored needs to be cleared
-			// Only need to clear the first 8 bytes since j will be put in for next 8
-			for x := 0; x < halfBlockSize; x++ {
-				xored[offset+x] = 0x00
-			}
-			binary.BigEndian.PutUint64(xored[offset+halfBlockSize:offset+blockSize], uint64(j))
+		if encrypt {
+			// A = B, B = C
+			numA, numB, numR = numB, numR, numA
+		} else {
+			// B = A, A = C
+			numA, numB, numR = numR, numA, numB
+		}
+	}
 
-			// XOR R and j in place
-			// R, xored are always 16 bytes
-			for x := 0; x < blockSize; x++ {
-				xored[offset+x] = R[x] ^ xored[offset+x]
-			}
+	// numQ is free again after the rounds
+	if err := str(numA, numQ, pw, chunk, A, radix); err != nil {
+		return err
+	}
+	return str(numB, numQ, pw, chunk, B, radix)
+}
 
-			// AES encrypt the current xored block
-			_, err = c.ciph(xored[offset : offset+blockSize])
-			if err != nil {
-				return ret, err
-			}
+// rounds64 is roundsBig for messages where radix^u and radix^v fit in a uint64.
+// The halves are then at most 8 bytes long (b <= 8, d <= 12) and all of the
+// arithmetic is done in machine words.
+func (c Cipher) rounds64(params *lengthEntry, Q []byte, qOff int, Y []byte, state *[blockSize]byte, A, B []uint8, encrypt bool) error {
+	b, d := params.b, params.d
+
+	numA, ok := c.conv.num64(A)
+	if !ok {
+		return ErrStringNotInRadix
+	}
+	numB, ok := c.conv.num64(B)
+	if !ok {
+		return ErrStringNotInRadix
+	}
+
+	for round := 0; round < numRounds; round++ {
+		i := round
+		numFeed := numB
+		if !encrypt {
+			i = numRounds - 1 - round
+			numFeed = numA
 		}
 
-		numY.SetBytes(Y[:d])
+		// The round number, then the numeral big-endian in exactly the last b bytes
+		Q[len(Q)-b-1] = byte(i)
+		for k := len(Q) - 1; k >= len(Q)-b; k-- {
+			Q[k] = byte(numFeed)
+			numFeed >>= 8
+		}
 
-		numC.Add(&numA, &numY)
+		if err := c.prf(Y, Q[qOff:], state, params.maxJ); err != nil {
+			return err
+		}
 
+		modulus := params.modV64
 		if i%2 == 0 {
-			numC.Mod(&numC, &numModU)
+			modulus = params.modU64
+		}
+
+		// y = NUM(Y[:d]) is up to 96 bits long, reduce it as the 128-bit value hi:lo
+		var hi uint64
+		for _, v := range Y[:d-8] {
+			hi = hi<<8 | uint64(v)
+		}
+		lo := binary.BigEndian.Uint64(Y[d-8 : d])
+		y := bits.Rem64(hi, lo, modulus)
+
+		// Both operands are below the modulus, so one correction is enough. An overflow
+		// of the sum means it is above the modulus, and the wrapped subtraction is exact.
+		var numC uint64
+		if encrypt {
+			sum, carry := bits.Add64(numA, y, 0)
+			if carry != 0 || sum >= modulus {
+				sum -= modulus
+			}
+			numC = sum
+		} else if numB >= y {
+			numC = numB - y
 		} else {
-			numC.Mod(&numC, &numModV)
+			numC = numB + (modulus - y)
 		}
 
-		// big.Ints use pointers behind the scenes so when numB gets updated,
-		// numA will transparently get updated to it. Hence, set the bytes explicitly
-		numA.SetBytes(numBBytes)
-		numB = numC
+		if encrypt {
+			numA, numB = numB, numC
+		} else {
+			numA, numB = numC, numA
+		}
 	}
 
-	return fpeUtils.DecodeNum(&numA, len(A), &numB, len(B), c.codec)
+	if err := c.conv.str64(numA, A); err != nil {
+		return err
+	}
+	return c.conv.str64(numB, B)
 }
 
-// Decrypt decrypts the byte slice X over the current FF1 parameters
-// and returns the plaintext of the same length and format
-func (c Cipher) Decrypt(X []byte) ([]byte, error) {
-	return c.DecryptWithTweak(X, c.tweak)
+// prf writes the output of steps 6ii and 6iii for the current Q to Y: R, the CBC-MAC
+// over P||Q continued from state with only Q[qOff:] left to absorb, followed by the
+// maxJ-1 blocks CIPH(R xor [j]^16). S is the first d bytes of Y. Y needs one more
+// block after those, which is used as the counter block.
+func (c Cipher) prf(Y []byte, qTail []byte, state *[blockSize]byte, maxJ int) error {
+	// R starts at Y, requires blockSize bytes, and doubles as the CBC-MAC chaining state
+	R := Y[:blockSize]
+	copy(R, state[:])
+	if err := c.mac(R, qTail); err != nil {
+		return err
+	}
+
+	// This will only be needed if maxJ > 1
+	// xored uses the blocks after R in Y, if any
+	xored := Y[blockSize : maxJ*blockSize]
+	ctr := Y[maxJ*blockSize : (maxJ+1)*blockSize]
+
+	// Step 6iii
+	extra := maxJ - 1
+	if c.parallelMinBlocks == 0 || extra < c.parallelMinBlocks {
+		return c.expand(R, ctr, xored, 1, maxJ)
+	}
+
+	// Each block only depends on R and j, so they can be generated in any order
+	workers := runtime.GOMAXPROCS(0)
+	if limit := extra / c.parallelMinBlocks; workers > limit {
+		workers = limit
+	}
+	if workers < 2 {
+		return c.expand(R, ctr, xored, 1, maxJ)
+	}
+	return c.expandParallel(R, xored, extra, workers)
 }
 
-// DecryptWithTweak is the same as Decrypt except it uses the
-// tweak from the parameter rather than the current Cipher's tweak
-// This allows you to re-use a single Cipher (for a given key) and simply
-// override the tweak for each unique data input, which is a practical
-// use-case of FPE for things like credit card numbers.
-func (c Cipher) DecryptWithTweak(X []byte, tweak []byte) ([]byte, error) {
-	var ret []byte
-	var err error
+// expandParallel is expand for j in [1, extra] with the range split into contiguous
+// chunks, one per goroutine. It is kept apart from prf so that the serial path does
+// not pay for the goroutine closures, which move the Cipher to the heap.
+func (c Cipher) expandParallel(R []byte, xored []byte, extra, workers int) error {
+	errs := make([]error, workers)
+	ctrs := make([]byte, workers*blockSize)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from := 1 + w*extra/workers
+		to := 1 + (w+1)*extra/workers
+		wg.Add(1)
+		go func(w, from, to int) {
+			defer wg.Done()
+			errs[w] = c.expand(R, ctrs[w*blockSize:(w+1)*blockSize], xored, from, to)
+		}(w, from, to)
+	}
+	wg.Wait()
 
-	// Byte slice X contains a sequence of bytes. Convert into an array of indices into
-	// the alphabet embedded in the codec.
-	Xn, err := c.codec.Encode(X)
-	if err != nil {
-		return ret, ErrStringNotInRadix
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	n := uint32(len(Xn))
-	t := len(tweak)
+// expand writes the blocks CIPH(R xor [j]^16) for j in [from, to) to xored,
+// block j going to offset (j-1)*16. ctr is a 16 byte buffer for the counter block.
+func (c Cipher) expand(R []byte, ctr []byte, xored []byte, from, to int) error {
+	if len(R) != blockSize || len(ctr) != blockSize || len(xored) < (to-1)*blockSize {
+		return errors.New("invalid buffer lengths for the PRF output expansion")
+	}
 
-	// Check if message length is within minLength and maxLength bounds
-	if (n < c.minLen) || (n > c.maxLen) {
-		return ret, errors.New("message length is not within min and max bounds")
+	// j < 2^64, so [j]^16 only touches the last 8 bytes of R
+	copy(ctr, R)
+	low := binary.BigEndian.Uint64(R[halfBlockSize:])
+	for j := from; j < to; j++ {
+		binary.BigEndian.PutUint64(ctr[halfBlockSize:], low^uint64(j))
+		c.block.Encrypt(xored[(j-1)*blockSize:j*blockSize], ctr)
 	}
+	return nil
+}
 
-	// Make sure the length of given tweak is in range
-	if len(tweak) > c.maxTLen {
-		return ret, ErrTweakLengthInvalid
+// params returns the values that only depend on the message length n,
+// from the Cipher's cache where possible.
+func (c Cipher) params(n uint32) (*lengthEntry, error) {
+	if c.cache != nil {
+		if e, ok := c.cache.get(n); ok && e.block == c.block {
+			return e, nil
+		}
 	}
 
-	radix := c.codec.Radix()
+	var e lengthEntry
 
 	// Calculate split point
-	u := n / 2
-	v := n - u
+	e.u = n / 2
+	e.v = n - e.u
 
-	// Split the message
-	A := Xn[:u]
-	B := Xn[u:]
+	// Pre-calculate the modulus since it's only one of 2 values,
+	// depending on whether i is even or odd
+	e.modU = c.pow(e.u)
+	e.modV = c.pow(e.v)
 
 	// Byte lengths
-	b := int(math.Ceil(math.Ceil(float64(v)*math.Log2(float64(radix))) / 8))
-	d := int(4*math.Ceil(float64(b)/4) + 4)
+	var err error
+	e.b, e.d, err = sizesForDomain(e.modV)
+	if err != nil {
+		return nil, err
+	}
+	e.maxJ = int(ceilDiv(uint64(e.d), blockSize))
+
+	// radix^u <= radix^v, so both fit if radix^v does
+	if e.modV.IsUint64() {
+		e.fits64 = true
+		e.modU64 = e.modU.Uint64()
+		e.modV64 = e.modV.Uint64()
+	}
+
+	// CBC-MAC state for the default tweak
+	t := len(c.tweak)
+	layout, err := layoutFor(t, e.b, e.d)
+	if err != nil {
+		return nil, err
+	}
+	fixedQ := make([]byte, layout.qOff)
+	copy(fixedQ, c.tweak)
+	e.block = c.block
+	e.state, err = c.macPrefix(n, e.u, t, fixedQ)
+	if err != nil {
+		return nil, err
+	}
 
-	maxJ := int(math.Ceil(float64(d) / 16))
+	if c.cache != nil {
+		c.cache.put(n, &e)
+	}
+	return &e, nil
+}
 
-	numPad := (-t - b - 1) % 16
-	if numPad < 0 {
-		numPad += 16
+// pow returns radix^e from the Cipher's power table. The result must not be modified.
+func (c Cipher) pow(e uint32) *big.Int {
+	if c.powers == nil {
+		var radix, exp big.Int
+		radix.SetInt64(int64(c.codec.Radix()))
+		exp.SetUint64(uint64(e))
+		return new(big.Int).Exp(&radix, &exp, nil)
 	}
+	return c.powers.pow(e)
+}
 
+// lengthError returns the error for a message of n numerals outside the length
+// bounds of the Cipher.
+func (c Cipher) lengthError(n int) error {
+	if c.fips && uint64(n) < uint64(c.minLen) {
+		return &FIPSError{FIPSDomainSize, fmt.Sprintf("%d numerals in radix %d, at least %d are needed for a domain of %d", n, c.codec.Radix(), c.minLen, fipsMinDomain)}
+	}
+	if uint64(n) > uint64(c.maxLen) {
+		return fmt.Errorf("%w: %d numerals, the limit is %d", ErrInputTooLong, n, c.maxLen)
+	}
+	return errLengthBounds
+}
+
+// errLengthBounds is returned for a message of a length outside the limits of the
+// Cipher, if neither ErrInputTooLong nor a FIPSError applies.
+var errLengthBounds = errors.New("message length is not within min and max bounds")
+
+// tweakLenValid reports whether a tweak of t bytes is allowed by maxTLen and by
+// FF1, which encodes the tweak length in 32 bits of P.
+func tweakLenValid(t, maxTLen int) bool {
+	return t <= maxTLen && uint64(t) <= math.MaxUint32
+}
+
+// padLen returns the number of zero bytes between the tweak and the round number in Q,
+// chosen so that the length of Q is a multiple of 16.
+// t and b are reduced first, so the sum cannot overflow.
+func padLen(t, b int) int {
+	return (blockSize - (t%blockSize+b%blockSize+1)%blockSize) % blockSize
+}
+
+// macPrefix returns the CBC-MAC chaining state after absorbing P and fixedQ, the leading
+// blocks of Q that only hold the tweak and padding, for a message of n numerals split at u.
+func (c Cipher) macPrefix(n, u uint32, t int, fixedQ []byte) ([blockSize]byte, error) {
 	// Calculate P, doesn't change in each loop iteration
-	// P's length is always 16, so it can stay on the stack, separate from buf
-	const lenP = blockSize
-	P := make([]byte, blockSize)
+	// P's length is always 16
+	var P [blockSize]byte
 
 	P[0] = 0x01
 	P[1] = 0x02
@@ -433,193 +870,119 @@ func (c Cipher) DecryptWithTweak(X []byte, tweak []byte) ([]byte, error) {
 
 	// radix must fill 3 bytes, so pad 1 zero byte
 	P[3] = 0x00
-	binary.BigEndian.PutUint16(P[4:6], uint16(radix))
+	binary.BigEndian.PutUint16(P[4:6], uint16(c.codec.Radix()))
 
 	P[6] = 0x0a
 	P[7] = byte(u) // overflow automatically does the modulus
 
 	binary.BigEndian.PutUint32(P[8:12], n)
-	binary.BigEndian.PutUint32(P[12:lenP], uint32(t))
-
-	// Determine lengths of byte slices
-
-	// Q's length is known to always be t+b+1+numPad, to be multiple of 16
-	lenQ := t + b + 1 + numPad
-
-	// For a given input X, the size of PQ is deterministic: 16+lenQ
-	lenPQ := lenP + lenQ
-
-	// lenY := blockSize * maxJ
-
-	// buf holds multiple components that change in each loop iteration
-	// Ensure there's enough space for max(lenPQ, lenY)
-	// Q, PQ, and Y (R, xored) will share underlying memory
-	// The total buffer length needs space for:
-	// Q (lenQ)
-	// PQ (lenPQ)
-	// Y = R(last block of PQ) + xored blocks (maxJ - 1)
-	totalBufLen := lenQ + lenPQ + (maxJ-1)*blockSize
-	buf := make([]byte, totalBufLen)
-
-	// Q will use the first lenQ bytes of buf
-	// Only the last b+1 bytes of Q change for each loop iteration
-	Q := buf[:lenQ]
-	// This is the fixed part of Q
-	// First t bytes of Q are the tweak, next numPad bytes are already zero-valued
-	copy(Q[:t], tweak)
-
-	// Use PQ as a combined storage for P||Q
-	// PQ will use the next 16+lenQ bytes of buf
-	// Important: PQ is going to be encrypted in place,
-	// so P and Q will also remain separate and copied in each iteration
-	PQ := buf[lenQ : lenQ+lenPQ]
-
-	// These are re-used in the for loop below
-	// variables names prefixed with "num" indicate big integers
-	var (
-		numA, numB, numC big.Int
-		numRadix, numY   big.Int
-		numU, numV       big.Int
-		numModU, numModV big.Int
-		numABytes        []byte
-	)
-
-	numRadix.SetInt64(int64(radix))
-
-	// Y starts at the start of last block of PQ, requires lenY bytes
-	// R is part of Y, Overlaps part of PQ
-	Y := buf[lenQ+lenPQ-blockSize:]
-
-	// R starts at Y, requires blockSize bytes, which uses the last block of PQ
-	// R := Y[:blockSize]
-
-	// This will only be needed if maxJ > 1, for the inner for loop
-	// xored uses the blocks after R in Y, if any
-	xored := Y[blockSize:]
-
-	// Pre-calculate the modulus since it's only one of 2 values,
-	// depending on whether i is even or odd
-	numU.SetInt64(int64(u))
-	numV.SetInt64(int64(v))
+	binary.BigEndian.PutUint32(P[12:blockSize], uint32(t))
 
-	numModU.Exp(&numRadix, &numU, nil)
-	numModV.Exp(&numRadix, &numV, nil)
-
-	// Bootstrap for 1st round
-	numA, err = fpeUtils.Num(A, uint64(radix))
-	if err != nil {
-		return ret, ErrStringNotInRadix
+	// The CBC-MAC starts from a zero IV
+	var state [blockSize]byte
+	if err := c.mac(state[:], P[:]); err != nil {
+		return state, err
 	}
-
-	numB, err = fpeUtils.Num(B, uint64(radix))
-	if err != nil {
-		return ret, ErrStringNotInRadix
+	if err := c.mac(state[:], fixedQ); err != nil {
+		return state, err
 	}
 
-	// Main Feistel Round, 10 times
-	for i := numRounds - 1; i >= 0; i-- {
-		// Calculate the dynamic parts of Q
-		Q[t+numPad] = byte(i)
-
-		numABytes = numA.Bytes()
-
-		// Zero out the rest of Q
-		// When the second half of X is all 0s, numB is 0, so numBytes is an empty slice
-		// So, zero out the rest of Q instead of just the middle bytes, which covers the numB=0 case
-		// See https://github.com/capitalone/fpe/issues/10
-		for j := t + numPad + 1; j < lenQ; j++ {
-			Q[j] = 0x00
-		}
-
-		// B must only take up the last b bytes
-		copy(Q[lenQ-len(numABytes):], numABytes)
-
-		// PQ = P||Q
-		// Since prf/ciph will operate in place, P and Q have to be copied into PQ,
-		// for each iteration to reset the contents
-		copy(PQ[:blockSize], P)
-		copy(PQ[blockSize:], Q)
+	return state, nil
+}
 
-		// R is guaranteed to be of length 16
-		R, err := c.prf(PQ)
-		if err != nil {
-			return ret, err
+// num sets x to the value of the numeral string s in the given radix, handling as many
+// numerals at a time as fit in a big.Word. tmp, pw and chunk are temporaries; as long
+// as tmp has room for x times a word, none of them grows after its first use.
+func num(x, tmp, pw, chunk *big.Int, s []uint8, radix uint64) error {
+	k, _ := wordDigits(radix)
+
+	x.SetUint64(0)
+	for len(s) > 0 {
+		// A shorter chunk first, so the rest are all k numerals long
+		n := len(s) % k
+		if n == 0 {
+			n = k
 		}
 
-		// Step 6iii
-		for j := 1; j < maxJ; j++ {
-			// offset is used to calculate which xored block to use in this iteration
-			offset := (j - 1) * blockSize
-
-			// Since xorBytes operates in place, xored needs to be cleared
-			// Only need to clear the first 8 bytes since j will be put in for next 8
-			for x := 0; x < halfBlockSize; x++ {
-				xored[offset+x] = 0x00
-			}
-			binary.BigEndian.PutUint64(xored[offset+halfBlockSize:offset+blockSize], uint64(j))
-
-			// XOR R and j in place
-			// R, xored are always 16 bytes
-			for x := 0; x < blockSize; x++ {
-				xored[offset+x] = R[x] ^ xored[offset+x]
+		v, p := uint64(0), uint64(1)
+		for _, d := range s[:n] {
+			if uint64(d) >= radix {
+				return ErrStringNotInRadix
 			}
+			v = v*radix + uint64(d)
+			p *= radix
+		}
+		pw.SetUint64(p)
+		chunk.SetUint64(v)
+		tmp.Mul(x, pw)
+		x.Add(tmp, chunk)
+		s = s[n:]
+	}
+	return nil
+}
 
-			// AES encrypt the current xored block
-			_, err = c.ciph(xored[offset : offset+blockSize])
-			if err != nil {
-				return ret, err
+// str writes x into r as a numeral string in the given radix, dividing off as many
+// numerals at a time as fit in a big.Word. x is overwritten; tmp, pw and rem are
+// temporaries that, as in num, do not grow after their first use.
+func str(x, tmp, pw, rem *big.Int, r []uint8, radix uint64) error {
+	k, pk := wordDigits(radix)
+
+	pw.SetUint64(pk)
+	for i := len(r); i > 0; {
+		n := k
+		if i < k {
+			n = i
+			p := uint64(1)
+			for j := 0; j < n; j++ {
+				p *= radix
 			}
+			pw.SetUint64(p)
 		}
 
-		numY.SetBytes(Y[:d])
-
-		numC.Sub(&numB, &numY)
+		// QuoRem cannot reuse the storage of its quotient if it is also the dividend
+		tmp.QuoRem(x, pw, rem)
+		x, tmp = tmp, x
 
-		if i%2 == 0 {
-			numC.Mod(&numC, &numModU)
-		} else {
-			numC.Mod(&numC, &numModV)
+		v := rem.Uint64()
+		for j := 0; j < n; j++ {
+			r[i-1-j] = uint8(v % radix)
+			v /= radix
 		}
-
-		// big.Ints use pointers behind the scenes so when numB gets updated,
-		// numA will transparently get updated to it. Hence, set the bytes explicitly
-		numB.SetBytes(numABytes)
-		numA = numC
+		i -= n
 	}
-
-	return fpeUtils.DecodeNum(&numA, len(A), &numB, len(B), c.codec)
+	if x.Sign() != 0 {
+		return fmt.Errorf("destination array too small: %s remains after conversion", x)
+	}
+	return nil
 }
 
-// ciph defines how the main block cipher is called.
-// When prf calls this, it will likely be a multi-block input, in which case ciph behaves as CBC mode with IV=0.
-// When called otherwise, it is guaranteed to be a single-block (16-byte) input because that's what the algorithm dictates. In this situation, ciph behaves as ECB mode
-func (c Cipher) ciph(input []byte) ([]byte, error) {
-	// These are checked here manually because the CryptBlocks function panics rather than returning an error
-	// So, catch the potential error earlier
-	if len(input)%blockSize != 0 {
-		return nil, errors.New("length of ciph input must be multiple of 16")
+// wordDigits returns the largest k with radix^k no larger than a big.Word, and radix^k.
+func wordDigits(radix uint64) (int, uint64) {
+	const maxWord = uint64(^big.Word(0))
+	k, p := 1, radix
+	for p <= maxWord/radix {
+		p *= radix
+		k++
 	}
-
-	// Some crypto engines (e.g. PKCS7) always do padding (i.e. additional block is added), we need output buffer one block bigger
-	ciphertext := make([]byte, len(input)+blockSize)
-	c.cbcEncryptor.CryptBlocks(ciphertext, input)
-	// This FF1 implementation is update buffer in-place, copy result back to input
-	copy(input, ciphertext[:len(input)])
-
-	// Reset IV to 0
-	c.cbcEncryptor.(cbcMode).SetIV(ivZero)
-
-	return input, nil
+	return k, p
 }
 
-// PRF as defined in the NIST spec is actually just AES-CBC-MAC, which is the last block of an AES-CBC encrypted ciphertext. Utilize the ciph function for the AES-CBC.
-// PRF always outputs 16 bytes (one block)
-func (c Cipher) prf(input []byte) ([]byte, error) {
-	cipher, err := c.ciph(input)
-	if err != nil {
-		return nil, err
+// mac continues an AES-CBC-MAC over src, which must be a multiple of 16 bytes long.
+// state holds the chaining value (all zeros to start a new MAC) and is updated in place,
+// so afterwards it holds the MAC of everything absorbed so far.
+// The PRF as defined in the NIST spec is exactly this CBC-MAC with a zero IV.
+func (c Cipher) mac(state []byte, src []byte) error {
+	// These are checked here manually because the block cipher panics rather than returning an error
+	// So, catch the potential error earlier
+	if len(src)%blockSize != 0 {
+		return errors.New("length of mac input must be multiple of 16")
 	}
 
-	// Only return the last block (CBC-MAC)
-	return cipher[len(cipher)-blockSize:], nil
+	for j := 0; j < len(src); j += blockSize {
+		for x := 0; x < blockSize; x++ {
+			state[x] ^= src[j+x]
+		}
+		c.block.Encrypt(state, state)
+	}
+	return nil
 }
@@ -20,11 +20,18 @@ See the License for the specific language governing permissions and limitations
 package ff1
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 	"reflect"
+	"runtime"
+	"sync"
 	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
 )
 
 // Test vectors taken from here: http://csrc.nist.gov/groups/ST/toolkit/documents/Examples/FF1samples.pdf
@@ -113,6 +120,15 @@ var testVectors = []testVector{
 	},
 }
 
+// mustHex decodes a hex string from a test vector, panicking on malformed input.
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
 func TestEncrypt(t *testing.T) {
 	for idx, testVector := range testVectors {
 		sampleNumber := idx + 1
@@ -309,6 +325,490 @@ func TestAlphabetSizes(t *testing.T) {
 	}
 }
 
+// Zeroizing intermediates must not change the results
+func TestZeroize(t *testing.T) {
+	for idx, testVector := range testVectors {
+		sampleNumber := idx + 1
+		t.Run(fmt.Sprintf("Sample%d", sampleNumber), func(t *testing.T) {
+			key, err := hex.DecodeString(testVector.key)
+			if err != nil {
+				t.Fatalf("Unable to decode hex key: %v", testVector.key)
+			}
+
+			tweak, err := hex.DecodeString(testVector.tweak)
+			if err != nil {
+				t.Fatalf("Unable to decode tweak: %v", testVector.tweak)
+			}
+
+			ff1, err := NewCipher(testVector.radix, 16, key, tweak, WithZeroize())
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+
+			ciphertext, err := ff1.Encrypt(testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+				t.Fatalf("Encrypt: got %v expected %v", ciphertext, testVector.ciphertext)
+			}
+
+			plaintext, err := ff1.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+				t.Fatalf("Decrypt: got %v expected %v", plaintext, testVector.plaintext)
+			}
+		})
+	}
+}
+
+// Generating the PRF output blocks in parallel must not change the results
+func TestParallelExpansion(t *testing.T) {
+	const radix = 36
+	key := mustHex(testVectors[2].key)
+	tweak := mustHex(testVectors[2].tweak)
+
+	serial, err := NewCipherWithAlphabet(identityAlphabet(radix), 16, key, tweak)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	// 40 numerals need 2 blocks per round, 2000 numerals 41 blocks
+	for _, n := range []int{40, 500, 2000} {
+		X := make([]uint8, n)
+		for i := range X {
+			X[i] = uint8((i * 7) % radix)
+		}
+		expected, err := serial.Encrypt(X)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		for _, minBlocks := range []int{1, 4, 16, 64} {
+			t.Run(fmt.Sprintf("Len%dMin%d", n, minBlocks), func(t *testing.T) {
+				c, err := NewCipherWithAlphabet(identityAlphabet(radix), 16, key, tweak, WithParallelExpansion(minBlocks))
+				if err != nil {
+					t.Fatalf("Unable to create cipher: %v", err)
+				}
+
+				ciphertext, err := c.Encrypt(X)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !reflect.DeepEqual(ciphertext, expected) {
+					t.Fatalf("Encrypt differs from the serial expansion")
+				}
+
+				plaintext, err := c.Decrypt(ciphertext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !reflect.DeepEqual(plaintext, X) {
+					t.Fatalf("Decrypt: got %v expected %v", plaintext, X)
+				}
+			})
+		}
+	}
+
+	t.Run("Reference", func(t *testing.T) {
+		c, err := NewCipherWithAlphabet(identityAlphabet(radix), 16, key, tweak, WithParallelExpansion(1))
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		X := make([]uint8, 2000)
+		ciphertext, err := c.Encrypt(X)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if expected := referenceFF1(key, tweak, radix, X, true); !reflect.DeepEqual(ciphertext, expected) {
+			t.Fatalf("Encrypt differs from the reference")
+		}
+	})
+}
+
+// A single Cipher must be usable from many goroutines at once; run with -race
+func TestConcurrent(t *testing.T) {
+	ciphers := make([]Cipher, len(testVectors))
+	for idx, testVector := range testVectors {
+		c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak))
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		ciphers[idx] = c
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for iter := 0; iter < 50; iter++ {
+				idx := (g + iter) % len(testVectors)
+				testVector := testVectors[idx]
+
+				ciphertext, err := ciphers[idx].Encrypt(testVector.plaintext)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+					errs <- fmt.Errorf("Sample%d: Encrypt got %s expected %s", idx+1, ciphertext, testVector.ciphertext)
+					return
+				}
+
+				plaintext, err := ciphers[idx].Decrypt(testVector.ciphertext)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+					errs <- fmt.Errorf("Sample%d: Decrypt got %s expected %s", idx+1, plaintext, testVector.plaintext)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// Changing the key, tweak and alphabet slices after construction does not change
+// the Cipher
+func TestDefensiveCopies(t *testing.T) {
+	constructors := []struct {
+		name string
+		new  func(alphabet, key, tweak []byte) (Cipher, error)
+	}{
+		{"NewCipherWithAlphabet", func(alphabet, key, tweak []byte) (Cipher, error) {
+			return NewCipherWithAlphabet(alphabet, 16, key, tweak)
+		}},
+		{"NewCipherFIPS", func(alphabet, key, tweak []byte) (Cipher, error) {
+			return NewCipherFIPS(alphabet, 16, key, tweak)
+		}},
+		{"Family", func(alphabet, key, tweak []byte) (Cipher, error) {
+			f, err := NewCipherFamily(key)
+			if err != nil {
+				return Cipher{}, err
+			}
+			c, err := f.CipherWithAlphabet(alphabet, 16, tweak)
+			if err != nil {
+				return Cipher{}, err
+			}
+			return *c, nil
+		}},
+	}
+
+	for _, constructor := range constructors {
+		for idx, testVector := range testVectors {
+			t.Run(fmt.Sprintf("%s/Sample%d", constructor.name, idx+1), func(t *testing.T) {
+				alphabet := []byte(legacyAlphabet[:testVector.radix])
+				key := mustHex(testVector.key)
+				tweak := mustHex(testVector.tweak)
+				c, err := constructor.new(alphabet, key, tweak)
+				if err != nil {
+					t.Fatalf("Unable to create cipher: %v", err)
+				}
+
+				for _, b := range [][]byte{alphabet, key, tweak} {
+					for i := range b {
+						b[i] ^= 0x5a
+					}
+				}
+
+				ciphertext, err := c.Encrypt(testVector.plaintext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+					t.Fatalf("Encrypt: got %s expected %s", ciphertext, testVector.ciphertext)
+				}
+			})
+		}
+	}
+}
+
+func TestMaxInputLen(t *testing.T) {
+	key := mustHex(testVectors[0].key)
+	c, err := NewCipher(10, 0, key, nil, WithMaxInputLen(16))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	if _, err := c.Encrypt([]byte("0123456789012345")); err != nil {
+		t.Fatalf("Encrypt at the limit: %v", err)
+	}
+	for _, f := range []func([]byte) ([]byte, error){c.Encrypt, c.Decrypt} {
+		_, err := f([]byte("01234567890123456"))
+		if !errors.Is(err, ErrInputTooLong) {
+			t.Fatalf("got %v, expected %v", err, ErrInputTooLong)
+		}
+		if expected := "input too long: 17 numerals, the limit is 16"; err.Error() != expected {
+			t.Fatalf("got %q, expected %q", err, expected)
+		}
+	}
+
+	// The limit may equal MinLen, but not be below it
+	if c.MinLen() != 2 {
+		t.Fatalf("MinLen() = %d, expected 2", c.MinLen())
+	}
+	if _, err := NewCipher(10, 0, key, nil, WithMaxInputLen(c.MinLen())); err != nil {
+		t.Fatalf("limit of MinLen: %v", err)
+	}
+	for _, n := range []int{c.MinLen() - 1, 0, -1} {
+		if _, err := NewCipher(10, 0, key, nil, WithMaxInputLen(n)); err == nil {
+			t.Fatalf("expected an error for a limit of %d", n)
+		}
+	}
+	f, err := NewCipherFamily(key)
+	if err != nil {
+		t.Fatalf("Unable to create family: %v", err)
+	}
+	if _, err := f.Cipher(10, 0, nil, WithMaxInputLen(1)); err == nil {
+		t.Fatalf("expected an error for a limit of 1")
+	}
+	// FIPS mode raises MinLen to 6 for radix 10
+	var fipsErr *FIPSError
+	if _, err := NewCipherFIPS([]byte("0123456789"), 0, key, nil, WithMaxInputLen(5)); !errors.As(err, &fipsErr) || fipsErr.Constraint != FIPSDomainSize {
+		t.Fatalf("got %v, expected a FIPSError for %s", err, FIPSDomainSize)
+	}
+}
+
+// Rejecting a long message does not touch it, so the cost does not grow with its length
+func TestMaxInputLenAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts differ with the race detector")
+	}
+	c, err := NewCipher(10, 0, mustHex(testVectors[0].key), nil, WithMaxInputLen(64))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	X := bytes.Repeat([]byte("0123456789"), 1<<20)
+
+	const runs = 100
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < runs; i++ {
+		if _, err := c.Encrypt(X); !errors.Is(err, ErrInputTooLong) {
+			t.Fatalf("got %v, expected %v", err, ErrInputTooLong)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	if perCall := (after.TotalAlloc - before.TotalAlloc) / runs; perCall > 256 {
+		t.Fatalf("rejecting %d numerals allocated %d bytes per call", len(X), perCall)
+	}
+}
+
+// Results belong to the caller: thousands of later calls through the same Cipher,
+// its pools and an Encryptor, some of them concurrent, must not change a result
+// that is still held. Run with -race.
+func TestNoAlias(t *testing.T) {
+	const radix = 36
+	rng := rand.New(rand.NewSource(128))
+	key := make([]byte, 16)
+	rng.Read(key)
+	c, err := NewCipherWithAlphabet(identityAlphabet(radix), 0, key, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	e := c.NewEncryptor()
+
+	message := func(n int) []byte {
+		m := make([]byte, n)
+		for i := range m {
+			m[i] = byte(rng.Intn(radix))
+		}
+		return m
+	}
+
+	type held struct {
+		name     string
+		result   []byte
+		expected []byte
+	}
+	var results []held
+	lengths := []int{10, 40, 133}
+	for _, n := range lengths {
+		plaintext := message(n)
+		ciphertext := referenceFF1(key, nil, radix, plaintext, true)
+
+		calls := []struct {
+			name     string
+			f        func() ([]byte, error)
+			expected []byte
+		}{
+			{"Encrypt", func() ([]byte, error) { return c.Encrypt(plaintext) }, ciphertext},
+			{"Decrypt", func() ([]byte, error) { return c.Decrypt(ciphertext) }, plaintext},
+			{"EncryptInto", func() ([]byte, error) { return c.EncryptInto(make([]byte, 1), plaintext) }, ciphertext},
+			{"DecryptInto", func() ([]byte, error) { return c.DecryptInto(nil, ciphertext) }, plaintext},
+			{"Encryptor.Encrypt", func() ([]byte, error) { return e.Encrypt(nil, plaintext) }, ciphertext},
+			{"Encryptor.Decrypt", func() ([]byte, error) { return e.Decrypt(nil, ciphertext) }, plaintext},
+		}
+		for _, call := range calls {
+			result, err := call.f()
+			if err != nil {
+				t.Fatalf("%s: %v", call.name, err)
+			}
+			results = append(results, held{fmt.Sprintf("%s(%d)", call.name, n), result, call.expected})
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		seed := rng.Int63()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for iter := 0; iter < 500; iter++ {
+				m := make([]byte, lengths[iter%len(lengths)])
+				for i := range m {
+					m[i] = byte(rng.Intn(radix))
+				}
+				var err error
+				if iter%2 == 0 {
+					_, err = c.Encrypt(m)
+				} else {
+					_, err = c.DecryptInto(m, m)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	for iter := 0; iter < 1000; iter++ {
+		m := message(lengths[iter%len(lengths)])
+		if _, err := e.Encrypt(m, m); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	for _, r := range results {
+		if !reflect.DeepEqual(r.result, r.expected) {
+			t.Fatalf("%s: result changed by later calls, got %v expected %v", r.name, r.result, r.expected)
+		}
+	}
+}
+
+// Allocation budgets for a single Encrypt or Decrypt call. These are upper
+// bounds, not exact counts: raise them only together with a reason.
+const (
+	// NIST sample sized input: radix 10, 10 numerals
+	maxShortAllocs = 8
+	// 133 numerals in radix 36, so d > 16 and the S expansion uses several blocks
+	maxLongAllocs = 32
+)
+
+func TestAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not meaningful under the race detector")
+	}
+	long := []byte("xs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwd")
+	tests := []struct {
+		name  string
+		radix int
+		input []byte
+		max   float64
+	}{
+		{"Short", 10, testVectors[0].plaintext, maxShortAllocs},
+		{"Long", 36, long, maxLongAllocs},
+	}
+
+	key := mustHex(testVectors[0].key)
+	for _, test := range tests {
+		c, err := NewCipher(test.radix, 16, key, nil)
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		ciphertext, err := c.Encrypt(test.input)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		t.Run(test.name+"Encrypt", func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				c.Encrypt(test.input)
+			})
+			if allocs > test.max {
+				t.Fatalf("Encrypt: %v allocs per call, budget is %v", allocs, test.max)
+			}
+		})
+		t.Run(test.name+"Decrypt", func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				c.Decrypt(ciphertext)
+			})
+			if allocs > test.max {
+				t.Fatalf("Decrypt: %v allocs per call, budget is %v", allocs, test.max)
+			}
+		})
+	}
+}
+
+// Encrypt and Decrypt allocate exactly the result, EncryptInto and DecryptInto
+// nothing at all for the NIST samples
+func TestOutputAllocs(t *testing.T) {
+	for idx, testVector := range testVectors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak))
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+
+			dst := make([]byte, len(testVector.plaintext))
+			ciphertext, err := c.EncryptInto(dst, testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) || &ciphertext[0] != &dst[0] {
+				t.Fatalf("EncryptInto: got %s expected %s in dst", ciphertext, testVector.ciphertext)
+			}
+
+			// In place
+			buf := append([]byte(nil), testVector.ciphertext...)
+			plaintext, err := c.DecryptInto(buf, buf)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+				t.Fatalf("DecryptInto: got %s expected %s", plaintext, testVector.plaintext)
+			}
+
+			if raceEnabled {
+				return
+			}
+			allocs := []struct {
+				name     string
+				f        func()
+				expected float64
+			}{
+				{"Encrypt", func() { c.Encrypt(testVector.plaintext) }, 1},
+				{"Decrypt", func() { c.Decrypt(testVector.ciphertext) }, 1},
+				{"EncryptInto", func() { c.EncryptInto(dst, testVector.plaintext) }, 0},
+				{"DecryptInto", func() { c.DecryptInto(dst, testVector.ciphertext) }, 0},
+			}
+			for _, a := range allocs {
+				if n := testing.AllocsPerRun(100, a.f); n != a.expected {
+					t.Fatalf("%s: %v allocs per call, expected %v", a.name, n, a.expected)
+				}
+			}
+		})
+	}
+}
+
 // Note: panic(err) is just used for example purposes.
 func ExampleCipher_Encrypt() {
 	// Key and tweak should be byte arrays. Put your key and tweak here.
@@ -492,6 +992,35 @@ func BenchmarkE2ESample7(b *testing.B) {
 	}
 }
 
+// BenchmarkEncryptVeryLong compares serial and parallel generation of the 401 PRF output
+// blocks per round needed for a 20000 character message
+func BenchmarkEncryptVeryLong(b *testing.B) {
+	key := mustHex("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94")
+	X := make([]byte, 20000)
+	for i := range X {
+		X[i] = "0123456789abcdefghijklmnopqrstuvwxyz"[(i*7)%36]
+	}
+
+	variants := []struct {
+		name string
+		opts []Option
+	}{
+		{"Serial", nil},
+		{"Parallel", []Option{WithParallelExpansion(16)}},
+	}
+	for _, variant := range variants {
+		ff1, err := NewCipher(36, 16, key, nil, variant.opts...)
+		if err != nil {
+			b.Fatalf("Unable to create cipher: %v", err)
+		}
+		b.Run(variant.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				ff1.Encrypt(X)
+			}
+		})
+	}
+}
+
 // BenchmarkEncryptLong is only for benchmarking the inner for loop code bath using a very large input to make d very large, making maxJ > 1
 func BenchmarkEncryptLong(b *testing.B) {
 	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94")
@@ -504,9 +1033,277 @@ func BenchmarkEncryptLong(b *testing.B) {
 		b.Fatalf("Unable to create cipher: %v", err)
 	}
 
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
 		ff1.Encrypt([]byte("xs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwd"))
 	}
 }
+
+// BenchmarkExpand generates the extra PRF output blocks of one round, without allocating
+func BenchmarkExpand(b *testing.B) {
+	c, err := NewCipher(10, 0, make([]byte, 16), nil)
+	if err != nil {
+		b.Fatalf("Unable to create cipher: %v", err)
+	}
+	R := make([]byte, blockSize)
+	ctr := make([]byte, blockSize)
+
+	for _, maxJ := range []int{4, 64} {
+		xored := make([]byte, (maxJ-1)*blockSize)
+		b.Run(fmt.Sprintf("Blocks%d", maxJ-1), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(xored)))
+			for n := 0; n < b.N; n++ {
+				c.expand(R, ctr, xored, 1, maxJ)
+			}
+		})
+	}
+}
+
+// BenchmarkInvalidInput rejects 32 numerals with one invalid byte at different
+// positions. The cost must not depend on the position, compare the ns/op.
+func BenchmarkInvalidInput(b *testing.B) {
+	c, err := NewCipher(10, 0, make([]byte, 16), nil)
+	if err != nil {
+		b.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	for _, invalidAt := range []int{1, 30} {
+		X := []byte("01234567890123456789012345678901")
+		X[invalidAt] = 'x'
+		b.Run(fmt.Sprintf("InvalidAt%d", invalidAt), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if _, err := c.Encrypt(X); err != ErrStringNotInRadix {
+					b.Fatalf("got %v, expected %v", err, ErrStringNotInRadix)
+				}
+			}
+		})
+	}
+}
+
+// A zero value Cipher, Family or Encryptor returns an error instead of panicking
+func TestNotInitialized(t *testing.T) {
+	var c Cipher
+	if _, err := c.Encrypt(nil); err != ErrNotInitialized {
+		t.Fatalf("Encrypt: got %v, expected %v", err, ErrNotInitialized)
+	}
+	if _, err := c.Decrypt([]byte("0123456789")); err != ErrNotInitialized {
+		t.Fatalf("Decrypt: got %v, expected %v", err, ErrNotInitialized)
+	}
+	if _, err := new(Encryptor).Encrypt(nil, []byte("0")); err != ErrNotInitialized {
+		t.Fatalf("Encryptor.Encrypt: got %v, expected %v", err, ErrNotInitialized)
+	}
+	var f Family
+	if _, err := f.Cipher(10, 0, nil); err != ErrNotInitialized {
+		t.Fatalf("Family.Cipher: got %v, expected %v", err, ErrNotInitialized)
+	}
+	c.Wipe()
+	f.Wipe()
+}
+
+func TestNewCipherRadixError(t *testing.T) {
+	for _, radix := range []int{-1, 0, 1, len(legacyAlphabet) + 1, math.MinInt32} {
+		if _, err := NewCipher(radix, 0, make([]byte, 16), nil); err == nil {
+			t.Fatalf("expected an error for radix %d", radix)
+		}
+	}
+}
+
+// checkCipher encrypts and decrypts data with c, which was made from alphabet, key
+// and tweak. It fails if anything panics, and if encryption succeeds it checks the
+// ciphertext is in the alphabet, decrypts back, and matches the reference
+// implementation for short messages.
+func checkCipher(t *testing.T, c Cipher, alphabet, key, tweak, data []byte) {
+	ciphertext, err := c.Encrypt(data)
+	if err != nil {
+		// The same input must be rejected the other way round as well
+		if _, err := c.Decrypt(data); err == nil {
+			t.Fatalf("Decrypt accepted %v, which Encrypt rejected", data)
+		}
+		return
+	}
+	codec, err := fpeUtils.NewCodec(alphabet)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(ciphertext) != len(data) || !codec.Valid(ciphertext) {
+		t.Fatalf("Encrypt(%v) = %v is not in the format of the input", data, ciphertext)
+	}
+	plaintext, err := c.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt(%v): %v", ciphertext, err)
+	}
+	if !bytes.Equal(plaintext, data) {
+		t.Fatalf("Decrypt(Encrypt(%v)) = %v", data, plaintext)
+	}
+
+	if len(data) <= 64 {
+		numerals, _ := codec.Encode(data)
+		expected, _ := codec.Decode(referenceFF1(key, tweak, codec.Radix(), numerals, true))
+		if !bytes.Equal(ciphertext, expected) {
+			t.Fatalf("Encrypt(%v) = %v, reference gives %v", data, ciphertext, expected)
+		}
+	}
+}
+
+// No key, tweak, alphabet or data makes the cipher panic
+func FuzzEncryptDecrypt(f *testing.F) {
+	for _, testVector := range testVectors {
+		f.Add(mustHex(testVector.key), mustHex(testVector.tweak), []byte(legacyAlphabet[:testVector.radix]), testVector.plaintext)
+		f.Add(mustHex(testVector.key), mustHex(testVector.tweak), []byte(legacyAlphabet[:testVector.radix]), testVector.ciphertext)
+	}
+	key := mustHex(testVectors[0].key)
+	// Radix 2 at and below its minimal length of 7
+	f.Add(key, []byte{}, []byte("01"), []byte("0110100"))
+	f.Add(key, []byte{}, []byte("01"), []byte("011010"))
+	// Radix 256 needs a single byte
+	f.Add(key, []byte{0xff}, identityAlphabet(256), []byte{0x00})
+	f.Add(key, []byte{}, identityAlphabet(256), []byte{})
+	// Bytes outside the alphabet, a single symbol alphabet, an empty one
+	f.Add(key, []byte{}, []byte("0123456789"), []byte("01234x6789"))
+	f.Add(key, []byte{}, []byte("a"), []byte("aaaaaaaa"))
+	f.Add(key, []byte{}, []byte{}, []byte{})
+	// Bad key lengths
+	f.Add([]byte{}, []byte{}, []byte("0123456789"), []byte("0123456789"))
+	f.Add(make([]byte, 33), []byte{}, []byte("0123456789"), []byte("0123456789"))
+
+	f.Fuzz(func(t *testing.T, key, tweak, alphabet, data []byte) {
+		c, err := NewCipherWithAlphabet(alphabet, len(tweak), key, tweak)
+		if err != nil {
+			return
+		}
+		checkCipher(t, c, alphabet, key, tweak, data)
+	})
+}
+
+// No arguments make the constructors panic
+func FuzzNewCipher(f *testing.F) {
+	key := mustHex(testVectors[0].key)
+	f.Add(10, 16, key, []byte{}, []byte("0123456789"))
+	f.Add(2, 0, key, []byte{}, []byte("01"))
+	f.Add(36, 4, key, []byte("tweak"), []byte("0123456789abcdefghijklmnopqrstuvwxyz"))
+	f.Add(62, -1, key, []byte{}, []byte{})
+	f.Add(-1, 0, key, []byte{}, []byte("0"))
+	f.Add(0, 0, key, []byte{}, []byte{})
+	f.Add(1, 0, key, []byte{}, []byte("a"))
+	f.Add(63, 0, key, []byte{}, identityAlphabet(256))
+	f.Add(10, 0, []byte{1, 2, 3}, []byte{}, []byte("0123456789"))
+
+	f.Fuzz(func(t *testing.T, radix, maxTLen int, key, tweak, alphabet []byte) {
+		if c, err := NewCipher(radix, maxTLen, key, tweak); err == nil {
+			data := []byte(legacyAlphabet[:radix])
+			checkCipher(t, c, data, key, tweak, data)
+		}
+		if c, err := NewCipherWithAlphabet(alphabet, maxTLen, key, tweak); err == nil {
+			checkCipher(t, c, alphabet, key, tweak, alphabet)
+		}
+		if family, err := NewCipherFamily(key); err == nil {
+			if c, err := family.Cipher(radix, maxTLen, tweak); err == nil {
+				data := []byte(legacyAlphabet[:radix])
+				checkCipher(t, *c, data, key, tweak, data)
+			}
+		}
+	})
+}
+
+func TestBinaryCipher(t *testing.T) {
+	key := mustHex("2B7E151628AED2A6ABF7158809CF4F3C")
+	c, err := NewBinaryCipher(16, key, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	if c.MinLen() != 1 {
+		t.Fatalf("MinLen is %d, expected 1", c.MinLen())
+	}
+	if c, err := NewBinaryCipher(16, key[:15], nil); err == nil || c != nil {
+		t.Fatalf("Got %v and %v for a key of 15 bytes", c, err)
+	}
+	if _, err := c.Encrypt(nil); err == nil {
+		t.Fatalf("An empty message was encrypted")
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	everyByte := make([]byte, 256)
+	for i := range everyByte {
+		everyByte[i] = byte(i)
+	}
+	blobs := [][]byte{everyByte, append(everyByte[128:], everyByte...)}
+	for _, n := range []int{1, 2, 3, 15, 16, 17, 64, 255, 1000} {
+		blob := make([]byte, n)
+		rng.Read(blob)
+		blobs = append(blobs, blob)
+	}
+	shuffled := append([]byte{}, everyByte...)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	blobs = append(blobs, shuffled)
+
+	for idx, blob := range blobs {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			tweak := blob[:len(blob)%17]
+			ciphertext, err := c.EncryptWithTweak(blob, tweak)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			// The bytes are the numerals, without the tables of the codec
+			if expected := referenceFF1(key, tweak, 256, blob, true); !bytes.Equal(ciphertext, expected) {
+				t.Fatalf("Encrypt: got %x, expected %x", ciphertext, expected)
+			}
+			plaintext, err := c.DecryptWithTweak(ciphertext, tweak)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !bytes.Equal(plaintext, blob) {
+				t.Fatalf("Decrypt: got %x, expected %x", plaintext, blob)
+			}
+		})
+	}
+}
+
+func TestBinaryCipherStatistics(t *testing.T) {
+	c, err := NewBinaryCipher(0, mustHex("2B7E151628AED2A6ABF7158809CF4F3C"), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	// A byte of a ciphertext equals the byte of the plaintext at its position
+	// with a probability of 1/256, and each value of the byte is as likely
+	const blobs, length = 2000, 16
+	rng := rand.New(rand.NewSource(1))
+	same := 0
+	var counts [256]int
+	for i := 0; i < blobs; i++ {
+		blob := make([]byte, length)
+		rng.Read(blob)
+		ciphertext, err := c.Encrypt(blob)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if bytes.Equal(ciphertext, blob) {
+			t.Fatalf("%x encrypts to itself", blob)
+		}
+		for j, b := range ciphertext {
+			if b == blob[j] {
+				same++
+			}
+			counts[b]++
+		}
+	}
+
+	// Five standard deviations around the expected values
+	expected := float64(blobs*length) / 256
+	if math.Abs(float64(same)-expected) > 5*math.Sqrt(expected) {
+		t.Errorf("%d of %d bytes are unchanged, about %.0f were expected", same, blobs*length, expected)
+	}
+	chi2 := 0.0
+	for _, n := range counts {
+		chi2 += (float64(n) - expected) * (float64(n) - expected) / expected
+	}
+	// The chi-squared distribution with 255 degrees of freedom has a mean of 255
+	// and a standard deviation of about 22.6
+	if chi2 > 255+5*22.6 {
+		t.Errorf("The bytes of the ciphertexts are not uniform, chi-squared is %.1f", chi2)
+	}
+}
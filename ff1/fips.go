@@ -0,0 +1,90 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// fipsMinDomain is the smallest domain radix^minlen allowed by NIST SP 800-38G Rev. 1.
+const fipsMinDomain = 1000000
+
+// Constraints checked by NewCipherFIPS, as reported in FIPSError.Constraint
+const (
+	FIPSKeySize     = "key size"
+	FIPSDomainSize  = "domain size"
+	FIPSTweakLength = "tweak length"
+)
+
+// A FIPSError reports a configuration or message that NewCipherFIPS, or a Cipher
+// created by it, rejects.
+type FIPSError struct {
+	// Constraint is the violated requirement, one of the FIPS constants
+	Constraint string
+
+	// Reason describes the offending value
+	Reason string
+}
+
+func (e *FIPSError) Error() string {
+	return fmt.Sprintf("FIPS mode: %s: %s", e.Constraint, e.Reason)
+}
+
+// NewCipherFIPS is NewCipherWithAlphabet restricted to configurations that follow
+// NIST SP 800-38G Rev. 1 to the letter, for use in FIPS-aligned deployments:
+//   - the key must be 128, 192, or 256 bits, and is always used with crypto/aes;
+//   - maxTLen must be between 0 and 2^32-1, and the tweak no longer than maxTLen;
+//   - messages must be at least minlen numerals long, where radix^minlen >= 1,000,000,
+//     shorter ones are rejected by Encrypt and Decrypt.
+//
+// All options are allowed, as none of them changes the output.
+//
+// Violations are reported as a *FIPSError naming the constraint.
+func NewCipherFIPS(alphabet []byte, maxTLen int, key []byte, tweak []byte, opts ...Option) (Cipher, error) {
+	if keyLen := len(key); keyLen != 16 && keyLen != 24 && keyLen != 32 {
+		return Cipher{}, &FIPSError{FIPSKeySize, fmt.Sprintf("%d bits, must be 128, 192, or 256", 8*keyLen)}
+	}
+	if maxTLen < 0 || uint64(maxTLen) > math.MaxUint32 {
+		return Cipher{}, &FIPSError{FIPSTweakLength, fmt.Sprintf("maxTLen %d is outside [0..2^32-1]", maxTLen)}
+	}
+	if len(tweak) > maxTLen {
+		return Cipher{}, &FIPSError{FIPSTweakLength, fmt.Sprintf("tweak of %d bytes exceeds maxTLen %d", len(tweak), maxTLen)}
+	}
+
+	c, err := NewCipherWithAlphabet(alphabet, maxTLen, key, tweak, opts...)
+	if err != nil {
+		return Cipher{}, err
+	}
+
+	minLength, err := fpeUtils.MinLengthForDomain(uint64(c.codec.Radix()), big.NewInt(fipsMinDomain))
+	if err != nil {
+		return Cipher{}, err
+	}
+	if uint64(minLength) > uint64(c.maxLen) {
+		return Cipher{}, &FIPSError{FIPSDomainSize, fmt.Sprintf("messages of at most %d numerals in radix %d cannot reach %d", c.maxLen, c.codec.Radix(), fipsMinDomain)}
+	}
+	c.minLen = uint32(minLength)
+	c.fips = true
+	return c, nil
+}
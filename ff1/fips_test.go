@@ -0,0 +1,140 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Options that only change how the result is computed are allowed in FIPS mode,
+// and the results still match the NIST vectors
+func TestNewCipherFIPS(t *testing.T) {
+	for idx, testVector := range testVectors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := NewCipherFIPS([]byte(legacyAlphabet[:testVector.radix]), 16, mustHex(testVector.key), mustHex(testVector.tweak),
+				WithZeroize(), WithParallelExpansion(1), WithGenericArithmetic())
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+
+			ciphertext, err := c.Encrypt(testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+				t.Fatalf("Encrypt: got %s expected %s", ciphertext, testVector.ciphertext)
+			}
+			plaintext, err := c.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+				t.Fatalf("Decrypt: got %s expected %s", plaintext, testVector.plaintext)
+			}
+		})
+	}
+}
+
+func TestNewCipherFIPSError(t *testing.T) {
+	digits := []byte("0123456789")
+	key := make([]byte, 16)
+
+	tests := []struct {
+		maxTLen    int
+		key        []byte
+		tweak      []byte
+		constraint string
+	}{
+		{8, make([]byte, 15), nil, FIPSKeySize},
+		{8, nil, nil, FIPSKeySize},
+		{8, make([]byte, 64), nil, FIPSKeySize},
+		{-1, key, nil, FIPSTweakLength},
+		{4, key, make([]byte, 5), FIPSTweakLength},
+	}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := NewCipherFIPS(digits, test.maxTLen, test.key, test.tweak)
+			var fipsErr *FIPSError
+			if !errors.As(err, &fipsErr) || fipsErr.Constraint != test.constraint {
+				t.Fatalf("got %v, expected a FIPSError for %s", err, test.constraint)
+			}
+		})
+	}
+
+	// Errors that are not about FIPS are passed on as they are
+	if _, err := NewCipherFIPS([]byte("0"), 8, key, nil); err == nil {
+		t.Fatalf("expected an error for radix 1")
+	}
+}
+
+// Messages must have a domain of at least 1,000,000, where NewCipher only needs 100
+func TestFIPSDomain(t *testing.T) {
+	tests := []struct {
+		alphabet []byte
+		minLen   int
+	}{
+		{[]byte("01"), 20},
+		{[]byte("0123456789"), 6},
+		{[]byte(legacyAlphabet), 4},
+		{identityAlphabet(256), 3},
+	}
+
+	key := make([]byte, 16)
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			fips, err := NewCipherFIPS(test.alphabet, 0, key, nil)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			plain, err := NewCipherWithAlphabet(test.alphabet, 0, key, nil)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+
+			short := make([]byte, test.minLen-1)
+			for i := range short {
+				short[i] = test.alphabet[0]
+			}
+			if _, err := plain.Encrypt(short); err != nil {
+				t.Fatalf("NewCipher rejects %d numerals: %v", len(short), err)
+			}
+			var fipsErr *FIPSError
+			if _, err := fips.Encrypt(short); !errors.As(err, &fipsErr) || fipsErr.Constraint != FIPSDomainSize {
+				t.Fatalf("Encrypt of %d numerals: got %v, expected a FIPSError for %s", len(short), err, FIPSDomainSize)
+			}
+			if _, err := fips.Decrypt(short); !errors.As(err, &fipsErr) || fipsErr.Constraint != FIPSDomainSize {
+				t.Fatalf("Decrypt of %d numerals: got %v, expected a FIPSError for %s", len(short), err, FIPSDomainSize)
+			}
+
+			long := append(short, test.alphabet[0])
+			ciphertext, err := fips.Encrypt(long)
+			if err != nil {
+				t.Fatalf("Encrypt of %d numerals: %v", len(long), err)
+			}
+			if expected, _ := plain.Encrypt(long); !reflect.DeepEqual(ciphertext, expected) {
+				t.Fatalf("Encrypt: got %v expected %v", ciphertext, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package fpetestutil provides a fuzz target and seed corpus for code that uses the
+// ff1 package, so that `go test -fuzz` in other modules checks the same
+// invariants this module checks for itself.
+package fpetestutil
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// MaxMessageLen bounds the messages FuzzRoundTrip builds, to keep each run fast.
+const MaxMessageLen = 4096
+
+// SeedCorpus returns fuzz inputs known to stress FF1: messages of the minimum
+// length and one below for radix 2, 10 and 256, binary alphabets, long messages
+// past the 64-bit arithmetic, and tweaks from empty to 255 bytes.
+//
+// An input is read as: one byte selecting the radix, 2 + b%255; one byte giving the
+// tweak length n, with n bytes of tweak after it; then one byte per numeral of the
+// message, reduced modulo the radix.
+func SeedCorpus() [][]byte {
+	seed := func(radix int, tweak []byte, numerals ...byte) []byte {
+		s := append([]byte{byte(radix - 2), byte(len(tweak))}, tweak...)
+		return append(s, numerals...)
+	}
+	repeat := func(n int, b byte) []byte {
+		return bytes.Repeat([]byte{b}, n)
+	}
+	counting := func(n int) []byte {
+		s := make([]byte, n)
+		for i := range s {
+			s[i] = byte(i)
+		}
+		return s
+	}
+	return [][]byte{
+		// Radix 2 at, below and past its minimum length of 7
+		seed(2, nil, 0, 1, 1, 0, 1, 0, 0),
+		seed(2, nil, 0, 1, 1, 0, 1, 0),
+		seed(2, nil, repeat(64, 1)...),
+		seed(2, nil, repeat(65, 1)...),
+		// Radix 10 at and below its minimum of 2, and across the uint64 path
+		seed(10, nil, 0, 0),
+		seed(10, nil, 9),
+		seed(10, []byte("tweak"), counting(19)...),
+		seed(10, []byte("tweak"), counting(40)...),
+		// Binary alphabets, a single byte is a message
+		seed(256, nil, 0xff),
+		seed(256, nil),
+		seed(256, repeat(16, 0xAA), counting(256)...),
+		// Odd lengths and radices
+		seed(3, nil, counting(5)...),
+		seed(255, repeat(1, 0), counting(7)...),
+		seed(62, nil, counting(11)...),
+		// Tweaks of the maximum length an input can give
+		seed(36, repeat(255, 0x5A), counting(32)...),
+		seed(10, repeat(255, 0xFF), repeat(1000, 7)...),
+		// Truncated inputs
+		{},
+		{8},
+	}
+}
+
+// FuzzRoundTrip adds SeedCorpus to f and fuzzes the ciphers that newCipher returns
+// for a radix between 2 and 256. It checks that encryption and decryption do not
+// panic, that the ciphertext has the length of the plaintext and stays in the
+// alphabet, that it decrypts to the plaintext, and that messages of an allowed
+// length are accepted. newCipher may fail for radices it does not support, those
+// inputs are skipped. Tweaks the cipher rejects are skipped too.
+func FuzzRoundTrip(f *testing.F, newCipher func(radix int) (*ff1.Cipher, error)) {
+	for _, seed := range SeedCorpus() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		if len(input) < 2 {
+			return
+		}
+		radix := 2 + int(input[0])%255
+		c, err := newCipher(radix)
+		if err != nil || c == nil {
+			return
+		}
+		alphabet := c.Alphabet()
+		if len(alphabet) != radix {
+			t.Fatalf("newCipher(%d) returned a cipher of radix %d", radix, len(alphabet))
+		}
+
+		n := int(input[1])
+		if n > len(input)-2 {
+			n = len(input) - 2
+		}
+		tweak, numerals := input[2:2+n], input[2+n:]
+		if len(numerals) > MaxMessageLen {
+			numerals = numerals[:MaxMessageLen]
+		}
+		X := make([]byte, len(numerals))
+		for i, v := range numerals {
+			X[i] = alphabet[int(v)%radix]
+		}
+		CheckRoundTrip(t, c, X, tweak)
+	})
+}
+
+// CheckRoundTrip encrypts X with tweak and checks the invariants of FuzzRoundTrip.
+func CheckRoundTrip(t *testing.T, c *ff1.Cipher, X, tweak []byte) {
+	t.Helper()
+	Y, err := c.EncryptWithTweak(X, tweak)
+	if errors.Is(err, ff1.ErrTweakLengthInvalid) {
+		return
+	}
+	if len(X) < c.MinLen() {
+		if err == nil {
+			t.Fatalf("%d numerals, below the minimum of %d, were encrypted", len(X), c.MinLen())
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Encrypt(%x) with tweak %x: %v", X, tweak, err)
+	}
+	if len(Y) != len(X) {
+		t.Fatalf("Encrypt(%x) = %x, %d bytes instead of %d", X, Y, len(Y), len(X))
+	}
+	alphabet := c.Alphabet()
+	for i, b := range Y {
+		if bytes.IndexByte(alphabet, b) < 0 {
+			t.Fatalf("Encrypt(%x) = %x, byte %d (0x%02x) is not in the alphabet", X, Y, i, b)
+		}
+	}
+	back, err := c.DecryptWithTweak(Y, tweak)
+	if err != nil {
+		t.Fatalf("Decrypt(%x) with tweak %x: %v", Y, tweak, err)
+	}
+	if !bytes.Equal(back, X) {
+		t.Fatalf("Decrypt(Encrypt(%x)) = %x with tweak %x", X, back, tweak)
+	}
+}
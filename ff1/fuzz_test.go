@@ -0,0 +1,50 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1_test
+
+import (
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+	"github.com/Tensai75/go-fpe-bytes/ff1/fpetestutil"
+)
+
+// This is in an external package, as fpetestutil imports ff1
+
+func FuzzRoundTrip(f *testing.F) {
+	key := []byte("0123456789abcdef")
+	alphabets := make(map[int]*ff1.Cipher)
+	fpetestutil.FuzzRoundTrip(f, func(radix int) (*ff1.Cipher, error) {
+		if c, ok := alphabets[radix]; ok {
+			return c, nil
+		}
+		// Alphabets of the highest byte values, so that they are not in order
+		alphabet := make([]byte, radix)
+		for i := range alphabet {
+			alphabet[i] = byte(255 - i)
+		}
+		c, err := ff1.NewCipherWithAlphabet(alphabet, 255, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		alphabets[radix] = &c
+		return &c, nil
+	})
+}
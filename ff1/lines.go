@@ -0,0 +1,229 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLineLen is the longest line, with its line ending, a LineEncrypter
+// reads unless WithMaxLineLen sets another limit.
+const DefaultMaxLineLen = 64 * 1024
+
+// ErrLineTooLong is returned, in a LineError, by LineEncrypter.Run for a line
+// longer than its limit. Such lines are never cut short.
+var ErrLineTooLong = errors.New("line too long")
+
+// A LineError is the failure of one line of a LineEncrypter.
+type LineError struct {
+	Line int // line number, starting at 1
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// LineStats counts the lines a LineEncrypter has read.
+type LineStats struct {
+	Lines  int // all lines read
+	Done   int // lines encrypted or decrypted
+	Empty  int // empty lines, copied as they are
+	Failed int // lines left out of the output, with an error sink
+}
+
+// A LineOption configures a LineEncrypter.
+type LineOption func(*LineEncrypter)
+
+// WithEOL sets the line ending. Without it lines end in "\n" or "\r\n", and each
+// is written back with the ending it was read with. With it lines end in eol
+// only, so a "\r" before the "\n" of WithEOL("\n") belongs to the value. The last
+// line may lack its ending, and then lacks it in the output too. An empty eol is
+// ignored.
+func WithEOL(eol string) LineOption {
+	return func(le *LineEncrypter) {
+		if eol != "" {
+			le.eol = []byte(eol)
+		}
+	}
+}
+
+// WithMaxLineLen sets the longest line, with its line ending, to n bytes. Longer
+// lines fail with ErrLineTooLong, which ends Run even with an error sink, as the
+// rest of the line cannot be told apart from the next one without reading it.
+// An n below 1 is ignored.
+func WithMaxLineLen(n int) LineOption {
+	return func(le *LineEncrypter) {
+		if n > 0 {
+			le.maxLineLen = n
+		}
+	}
+}
+
+// WithErrorSink makes Run go on after a line that cannot be encrypted or
+// decrypted. The line is left out of the output and its LineError is written to
+// sink, one per line. Without it Run stops at the first such line.
+func WithErrorSink(sink io.Writer) LineOption {
+	return func(le *LineEncrypter) {
+		le.sink = sink
+	}
+}
+
+// WithLineDecrypt makes the LineEncrypter decrypt the lines instead.
+func WithLineDecrypt() LineOption {
+	return func(le *LineEncrypter) {
+		le.decrypt = true
+	}
+}
+
+// A LineEncrypter encrypts a stream of lines with one value each, such as a list
+// of account numbers, line by line and in constant memory. Each line is one
+// message for the Cipher, with its default tweak.
+type LineEncrypter struct {
+	c          *Cipher
+	r          io.Reader
+	w          io.Writer
+	eol        []byte // nil for "\n" or "\r\n"
+	maxLineLen int
+	sink       io.Writer
+	decrypt    bool
+}
+
+// NewLineEncrypter returns a LineEncrypter that reads lines from r and writes
+// them, encrypted with c, to w.
+func NewLineEncrypter(c *Cipher, r io.Reader, w io.Writer, opts ...LineOption) *LineEncrypter {
+	le := &LineEncrypter{c: c, r: r, w: w, maxLineLen: DefaultMaxLineLen}
+	for _, opt := range opts {
+		opt(le)
+	}
+	return le
+}
+
+// Run processes lines until the input ends, a line fails without an error sink,
+// or ctx is cancelled, and returns the counts so far. Failures of lines are
+// returned as *LineError, cancellation as ctx.Err(). ctx is checked between
+// lines, it does not interrupt a Read of the input that blocks, and it is passed
+// to the TraceHook of the Cipher for each line. Whatever was processed before Run
+// returns is written to w, also on errors.
+func (le *LineEncrypter) Run(ctx context.Context) (LineStats, error) {
+	var stats LineStats
+	if le.c == nil || le.c.codec == nil {
+		return stats, ErrNotInitialized
+	}
+
+	sc := bufio.NewScanner(le.r)
+	initial := 4096
+	if le.maxLineLen < initial {
+		initial = le.maxLineLen
+	}
+	sc.Buffer(make([]byte, 0, initial), le.maxLineLen)
+	sc.Split(le.split)
+	bw := bufio.NewWriter(le.w)
+
+	err := func() error {
+		var dst []byte
+		for sc.Scan() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			stats.Lines++
+			line, eol := le.trim(sc.Bytes())
+			if len(line) == 0 {
+				stats.Empty++
+				if _, err := bw.Write(eol); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var err error
+			dst, err = le.c.crypt(ctx, nil, dst, line, le.c.tweak, !le.decrypt)
+			if err != nil {
+				lerr := &LineError{Line: stats.Lines, Err: err}
+				if le.sink == nil {
+					return lerr
+				}
+				stats.Failed++
+				if _, err := fmt.Fprintln(le.sink, lerr); err != nil {
+					return err
+				}
+				continue
+			}
+			stats.Done++
+			if _, err := bw.Write(dst); err != nil {
+				return err
+			}
+			if _, err := bw.Write(eol); err != nil {
+				return err
+			}
+		}
+		if err := sc.Err(); err == bufio.ErrTooLong {
+			return &LineError{Line: stats.Lines + 1, Err: fmt.Errorf("%w: more than %d bytes", ErrLineTooLong, le.maxLineLen)}
+		} else if err != nil {
+			return err
+		}
+		return ctx.Err()
+	}()
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return stats, err
+}
+
+// split is a bufio.SplitFunc that returns lines with their ending.
+func (le *LineEncrypter) split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	eol := le.eol
+	if eol == nil {
+		eol = []byte{'\n'}
+	}
+	if i := bytes.Index(data, eol); i >= 0 {
+		return i + len(eol), data[:i+len(eol)], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// trim splits a line returned by split into the value and the line ending.
+func (le *LineEncrypter) trim(line []byte) (value, eol []byte) {
+	if le.eol != nil {
+		if bytes.HasSuffix(line, le.eol) {
+			return line[:len(line)-len(le.eol)], le.eol
+		}
+		return line, nil
+	}
+	switch {
+	case bytes.HasSuffix(line, []byte("\r\n")):
+		return line[:len(line)-2], line[len(line)-2:]
+	case bytes.HasSuffix(line, []byte("\n")):
+		return line[:len(line)-1], line[len(line)-1:]
+	}
+	return line, nil
+}
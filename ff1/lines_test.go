@@ -0,0 +1,189 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newLineCipher(t *testing.T) Cipher {
+	t.Helper()
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	return c
+}
+
+func runLines(t *testing.T, c *Cipher, in string, opts ...LineOption) (string, LineStats, error) {
+	t.Helper()
+	var out bytes.Buffer
+	stats, err := NewLineEncrypter(c, strings.NewReader(in), &out, opts...).Run(context.Background())
+	return out.String(), stats, err
+}
+
+func TestLineEncrypter(t *testing.T) {
+	c := newLineCipher(t)
+	enc := func(s string) string {
+		Y, err := c.Encrypt([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(Y)
+	}
+
+	for idx, test := range []struct {
+		in    string
+		opts  []LineOption
+		want  string
+		stats LineStats
+	}{
+		{"0123456789\n555\n", nil, enc("0123456789") + "\n" + enc("555") + "\n", LineStats{Lines: 2, Done: 2}},
+		{"0123456789\r\n555\n\r\n\n42", nil, enc("0123456789") + "\r\n" + enc("555") + "\n\r\n\n" + enc("42"), LineStats{Lines: 5, Done: 3, Empty: 2}},
+		{"0123456789\r\n\r\n555", []LineOption{WithEOL("\r\n")}, enc("0123456789") + "\r\n\r\n" + enc("555"), LineStats{Lines: 3, Done: 2, Empty: 1}},
+		{"12|34|", []LineOption{WithEOL("|")}, enc("12") + "|" + enc("34") + "|", LineStats{Lines: 2, Done: 2}},
+		{"", nil, "", LineStats{}},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			out, stats, err := runLines(t, &c, test.in, test.opts...)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if out != test.want || stats != test.stats {
+				t.Fatalf("got %q, %+v, want %q, %+v", out, stats, test.want, test.stats)
+			}
+
+			back, _, err := runLines(t, &c, out, append(test.opts, WithLineDecrypt())...)
+			if err != nil || back != test.in {
+				t.Fatalf("decrypted %q, %v, want %q", back, err, test.in)
+			}
+		})
+	}
+}
+
+func TestLineEncrypterError(t *testing.T) {
+	c := newLineCipher(t)
+	in := "123\n456\n45x\n789\n1\n000\n"
+
+	// Without an error sink Run stops at the first failing line
+	out, stats, err := runLines(t, &c, in)
+	var lerr *LineError
+	if !errors.As(err, &lerr) || lerr.Line != 3 || !errors.Is(err, ErrStringNotInRadix) {
+		t.Fatalf("got %v, want an error on line 3", err)
+	}
+	if strings.Count(out, "\n") != 2 || stats != (LineStats{Lines: 3, Done: 2}) {
+		t.Fatalf("got %q, %+v", out, stats)
+	}
+
+	// With one, failing lines are left out and reported
+	var sink bytes.Buffer
+	out, stats, err = runLines(t, &c, in, WithErrorSink(&sink))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if strings.Count(out, "\n") != 4 || stats != (LineStats{Lines: 6, Done: 4, Failed: 2}) {
+		t.Fatalf("got %q, %+v", out, stats)
+	}
+	lines := strings.Split(strings.TrimSuffix(sink.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "line 3: ") || !strings.HasPrefix(lines[1], "line 5: ") {
+		t.Fatalf("error sink got %q", sink.String())
+	}
+
+	// With WithEOL("\n") a "\r" is part of the value
+	if _, _, err := runLines(t, &c, "123\r\n", WithEOL("\n")); !errors.Is(err, ErrStringNotInRadix) {
+		t.Fatalf("got %v, want %v", err, ErrStringNotInRadix)
+	}
+
+	if _, _, err := runLines(t, nil, in); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("nil cipher: got %v, want %v", err, ErrNotInitialized)
+	}
+}
+
+func TestLineEncrypterLongLine(t *testing.T) {
+	c := newLineCipher(t)
+
+	// A line of exactly the limit, with its ending, is fine
+	if _, stats, err := runLines(t, &c, "1234567\n12\n", WithMaxLineLen(8)); err != nil || stats.Done != 2 {
+		t.Fatalf("got %+v, %v", stats, err)
+	}
+
+	// A longer one ends Run, even with an error sink
+	in := "1234567\n12345678\n12\n"
+	out, stats, err := runLines(t, &c, in, WithMaxLineLen(8), WithErrorSink(io.Discard))
+	var lerr *LineError
+	if !errors.As(err, &lerr) || lerr.Line != 2 || !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("got %v, want %v on line 2", err, ErrLineTooLong)
+	}
+	if strings.Count(out, "\n") != 1 || stats.Lines != 1 {
+		t.Fatalf("got %q, %+v", out, stats)
+	}
+
+	// The default limit applies too
+	long := strings.Repeat("1", DefaultMaxLineLen) + "\n"
+	if _, _, err := runLines(t, &c, long); !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("got %v, want %v", err, ErrLineTooLong)
+	}
+}
+
+// cancelReader returns first, then cancels and returns the rest.
+type cancelReader struct {
+	first, rest string
+	cancel      context.CancelFunc
+	reads       int
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	r.reads++
+	switch r.reads {
+	case 1:
+		return copy(p, r.first), nil
+	case 2:
+		r.cancel()
+		return copy(p, r.rest), nil
+	}
+	return 0, io.EOF
+}
+
+func TestLineEncrypterCancel(t *testing.T) {
+	c := newLineCipher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out bytes.Buffer
+	r := &cancelReader{first: "123\n456\n", rest: "789\n", cancel: cancel}
+	stats, err := NewLineEncrypter(&c, r, &out).Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if stats.Lines != 2 || strings.Count(out.String(), "\n") != 2 {
+		t.Fatalf("got %q, %+v after cancellation", out.String(), stats)
+	}
+
+	out.Reset()
+	stats, err = NewLineEncrypter(&c, strings.NewReader("123\n"), &out).Run(ctx)
+	if !errors.Is(err, context.Canceled) || stats.Lines != 0 || out.Len() != 0 {
+		t.Fatalf("cancelled context: got %q, %+v, %v", out.String(), stats, err)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// Events passed to a Logger.
+const (
+	// EventCreated is logged once a constructor has applied the options, with the
+	// parameters of the Cipher.
+	EventCreated = "ff1.created"
+
+	// EventKeySwapped and EventKeySwapFailed are logged by SwapKey.
+	EventKeySwapped    = "ff1.key_swapped"
+	EventKeySwapFailed = "ff1.key_swap_failed"
+
+	// EventWiped is logged by Wipe.
+	EventWiped = "ff1.wiped"
+
+	// EventFailed is logged for every encryption or decryption that fails, with the
+	// category of the error. A category of "roundtrip" is a failed check of
+	// WithVerifyRoundTrip.
+	EventFailed = "ff1.failed"
+)
+
+// A LogField is one item of a log record. Value is an int, a bool, or a string
+// that is either a fixed word of this package or a hex fingerprint.
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// A Logger receives the records of a Cipher created with WithLogger. It must be
+// safe for concurrent use. An adapter to log/slog or another logging library
+// passes event as the message and the fields as attributes.
+//
+// No record ever holds a plaintext, a ciphertext, a key or a tweak, or anything
+// computed from them but their lengths and the fingerprints of AlphabetFingerprint
+// and SealedValue. Errors are only given by category for the same reason, so that
+// no error text can carry the input into a log.
+type Logger interface {
+	Log(event string, fields ...LogField)
+}
+
+// LoggerFunc adapts a function to Logger.
+type LoggerFunc func(event string, fields ...LogField)
+
+// Log calls f.
+func (f LoggerFunc) Log(event string, fields ...LogField) {
+	f(event, fields...)
+}
+
+// WithLogger makes the Cipher log its creation, SwapKey, Wipe and failed
+// operations to l. Successful operations are not logged, use WithMetrics for them.
+func WithLogger(l Logger) Option {
+	return func(c *Cipher) {
+		c.logger = l
+	}
+}
+
+// The fields are only made here, from lengths, flags, fingerprints and fixed words.
+
+func intField(key string, v int) LogField {
+	return LogField{key, v}
+}
+
+func boolField(key string, v bool) LogField {
+	return LogField{key, v}
+}
+
+func (c Cipher) fingerprintField() LogField {
+	fp := c.AlphabetFingerprint()
+	return LogField{"alphabet_fingerprint", hex.EncodeToString(fp[:])}
+}
+
+// errorCategory sorts err into a fixed word for logging.
+func errorCategory(err error) string {
+	var fipsErr *FIPSError
+	switch {
+	case errors.Is(err, ErrStringNotInRadix):
+		return "alphabet"
+	case errors.Is(err, ErrInputTooLong), errors.Is(err, errLengthBounds):
+		return "length"
+	case errors.As(err, &fipsErr):
+		return "fips"
+	case errors.Is(err, ErrTweakLengthInvalid):
+		return "tweak"
+	case errors.Is(err, ErrKeyUsageExceeded):
+		return "usage"
+	case errors.Is(err, ErrWiped), errors.Is(err, ErrNotInitialized):
+		return "key"
+	case errors.Is(err, ErrRoundTripMismatch):
+		return "roundtrip"
+	}
+	return "other"
+}
+
+// logCreated logs the parameters of the Cipher, not its tweak or key.
+func (c *Cipher) logCreated() {
+	c.logger.Log(EventCreated,
+		intField("radix", c.codec.Radix()),
+		c.fingerprintField(),
+		intField("min_len", int(c.minLen)),
+		intField("max_len", int(c.maxLen)),
+		intField("max_tweak_len", c.maxTLen),
+		intField("tweak_len", len(c.tweak)),
+		boolField("fips", c.fips),
+		boolField("verify_round_trip", c.verifyRoundTrip),
+		boolField("zeroize", c.zeroize),
+		boolField("usage_limit", c.usage != nil),
+		boolField("decrypt_cache", c.dcache != nil),
+	)
+}
+
+// logSwap logs the outcome *err of a SwapKey with a key of keyLen bytes.
+func (c Cipher) logSwap(keyLen int, err *error) {
+	if *err != nil {
+		c.logger.Log(EventKeySwapFailed, c.fingerprintField(), intField("key_bits", 8*keyLen),
+			LogField{"category", errorCategory(*err)})
+		return
+	}
+	c.logger.Log(EventKeySwapped, c.fingerprintField(), intField("key_bits", 8*keyLen))
+}
+
+// logFailure logs the failure *err of an operation on a message of n symbols.
+func logFailure(l Logger, encrypt bool, n int, err *error) {
+	if *err == nil {
+		return
+	}
+	l.Log(EventFailed, LogField{"op", opName(encrypt)}, intField("symbols", n),
+		LogField{"category", errorCategory(*err)})
+}
@@ -0,0 +1,176 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// redactingLogger fails the test if a record contains any of the secrets, as they
+// are or hex-encoded in either case.
+type redactingLogger struct {
+	t       *testing.T
+	mu      sync.Mutex
+	secrets [][]byte
+	records []string
+}
+
+func (l *redactingLogger) addSecret(secret []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.secrets = append(l.secrets, secret,
+		[]byte(hex.EncodeToString(secret)), []byte(strings.ToUpper(hex.EncodeToString(secret))))
+}
+
+func (l *redactingLogger) Log(event string, fields ...LogField) {
+	var b strings.Builder
+	b.WriteString(event)
+	for _, f := range fields {
+		switch f.Value.(type) {
+		case int, bool, string:
+		default:
+			l.t.Errorf("field %s of %s has a value of type %T", f.Key, event, f.Value)
+		}
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+	record := b.String()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, secret := range l.secrets {
+		if bytes.Contains([]byte(record), secret) {
+			l.t.Errorf("record %q contains the secret %q", record, secret)
+		}
+	}
+	l.records = append(l.records, record)
+}
+
+// find returns the records of event.
+func (l *redactingLogger) find(event string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found []string
+	for _, r := range l.records {
+		if strings.HasPrefix(r, event+" ") {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+func TestLogger(t *testing.T) {
+	key := mustHex("EF4359D8D580AA4F7F036D6F04FC6A94")
+	newKey := mustHex("2B7E151628AED2A6ABF7158809CF4F3C")
+	tweak := []byte("tweak-31415926")
+	plaintext := []byte("8675309271828")
+	invalid := []byte("86753x9271828")
+
+	l := &redactingLogger{t: t}
+	for _, secret := range [][]byte{key, newKey, tweak, plaintext, invalid} {
+		l.addSecret(secret)
+	}
+
+	c, err := NewCipher(10, 16, key, tweak, WithLogger(l), WithVerifyRoundTrip(), WithUsageLimit(2))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	ciphertext, err := c.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.addSecret(ciphertext)
+	if _, err := c.Decrypt(ciphertext); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Encrypt(invalid); !errors.Is(err, ErrStringNotInRadix) {
+		t.Fatalf("got %v, want %v", err, ErrStringNotInRadix)
+	}
+	c.Decrypt([]byte("1"))
+	c.DecryptWithTweak(ciphertext, make([]byte, 17))
+	c.Encrypt(plaintext)
+	c.Encrypt(plaintext)
+	if err := c.SwapKey(newKey[:15]); err == nil {
+		t.Fatal("SwapKey accepted a short key")
+	}
+	if err := c.SwapKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	c.Wipe()
+	c.Decrypt(ciphertext)
+	if err := c.SwapKey(key); !errors.Is(err, ErrWiped) {
+		t.Fatalf("got %v, want %v", err, ErrWiped)
+	}
+
+	fp := c.AlphabetFingerprint()
+	fpField := "alphabet_fingerprint=" + hex.EncodeToString(fp[:])
+	for idx, test := range []struct {
+		event string
+		want  []string
+	}{
+		{EventCreated, []string{"ff1.created radix=10 " + fpField + " min_len=2 max_len=4294967295 max_tweak_len=16 tweak_len=14 fips=false verify_round_trip=true zeroize=false usage_limit=true decrypt_cache=false"}},
+		{EventFailed, []string{
+			"ff1.failed op=encrypt symbols=13 category=alphabet",
+			"ff1.failed op=decrypt symbols=1 category=length",
+			"ff1.failed op=decrypt symbols=13 category=tweak",
+			"ff1.failed op=encrypt symbols=13 category=usage",
+			"ff1.failed op=decrypt symbols=13 category=key",
+		}},
+		{EventKeySwapFailed, []string{
+			"ff1.key_swap_failed " + fpField + " key_bits=120 category=other",
+			"ff1.key_swap_failed " + fpField + " key_bits=128 category=key",
+		}},
+		{EventKeySwapped, []string{"ff1.key_swapped " + fpField + " key_bits=128"}},
+		{EventWiped, []string{"ff1.wiped " + fpField}},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			got := l.find(test.event)
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+// The check of WithVerifyRoundTrip failing is logged by category too
+func TestLoggerRoundTrip(t *testing.T) {
+	var events []string
+	l := LoggerFunc(func(event string, fields ...LogField) {
+		events = append(events, fmt.Sprint(event, fields))
+	})
+	c, err := NewCipher(10, 16, mustHex(testVectors[0].key), nil, WithLogger(l), WithVerifyRoundTrip())
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	corruptHook = func(ciphertext []byte) { ciphertext[0] ^= 1 }
+	defer func() { corruptHook = nil }()
+	if _, err := c.Encrypt([]byte("0123456789")); !errors.Is(err, ErrRoundTripMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrRoundTripMismatch)
+	}
+	if len(events) != 2 || events[1] != "ff1.failed[{op encrypt} {symbols 10} {category roundtrip}]" {
+		t.Fatalf("events %q", events)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import "time"
+
+// Operation names passed to a MetricsHook.
+const (
+	OpEncrypt = "encrypt"
+	OpDecrypt = "decrypt"
+)
+
+// A MetricsHook is told about every encryption and decryption of a Cipher created
+// with WithMetrics, for example to feed counters and latency histograms of a
+// metrics library. op is OpEncrypt or OpDecrypt, dur the time the call took and
+// err its error, nil on success, which can be told apart with errors.Is against
+// the errors of this package.
+//
+// Observe is called from the goroutine of the call, after the result is ready and
+// before it is returned, so it should be quick and must be safe for concurrent use.
+type MetricsHook interface {
+	Observe(op string, dur time.Duration, err error)
+}
+
+// WithMetrics makes the Cipher call h once for each Encrypt, Decrypt and their
+// variants, including those of an Encryptor, EncryptParallel and the stages of a
+// Cascade. Calls that fail before reaching the cipher, such as DecryptVerified with
+// a wrong tag, are not observed. Observing does not allocate, and a Cipher without
+// a hook only pays for one comparison per call.
+func WithMetrics(h MetricsHook) Option {
+	return func(c *Cipher) {
+		c.metrics = h
+	}
+}
+
+// observe reports a call that started at start and ended with *err to h.
+func observe(h MetricsHook, encrypt bool, start time.Time, err *error) {
+	h.Observe(opName(encrypt), time.Since(start), *err)
+}
+
+// opName returns OpEncrypt or OpDecrypt.
+func opName(encrypt bool) string {
+	if encrypt {
+		return OpEncrypt
+	}
+	return OpDecrypt
+}
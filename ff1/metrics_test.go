@@ -0,0 +1,169 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1/metricstest"
+)
+
+// hookFunc adapts a function to MetricsHook.
+type hookFunc func(op string, dur time.Duration, err error)
+
+func (f hookFunc) Observe(op string, dur time.Duration, err error) {
+	f(op, dur, err)
+}
+
+func TestMetrics(t *testing.T) {
+	var m metricstest.Counter
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak), WithMetrics(&m), WithUsageLimit(3))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	Y, err := c.Encrypt([]byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decrypt(Y); err != nil {
+		t.Fatal(err)
+	}
+	e := c.NewEncryptor()
+	if _, err := e.Encrypt(nil, []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if m.Calls(OpEncrypt) != 2 || m.Calls(OpDecrypt) != 1 || len(m.Errors(OpEncrypt)) != 0 {
+		t.Fatalf("observed %d encryptions, %d decryptions, errors %v", m.Calls(OpEncrypt), m.Calls(OpDecrypt), m.Errors(OpEncrypt))
+	}
+	if m.Duration(OpEncrypt) <= 0 {
+		t.Fatalf("encryptions took %v", m.Duration(OpEncrypt))
+	}
+
+	// Each error reaches the hook as it is returned
+	for idx, test := range []struct {
+		call func() error
+		op   string
+		want error
+	}{
+		{func() error { _, err := c.Encrypt([]byte("01234x")); return err }, OpEncrypt, ErrStringNotInRadix},
+		{func() error { _, err := c.DecryptWithTweak([]byte("012345"), make([]byte, 17)); return err }, OpDecrypt, ErrTweakLengthInvalid},
+		{func() error { _, err := c.Encrypt([]byte("0123456789")); return err }, OpEncrypt, nil},
+		{func() error { _, err := c.Encrypt([]byte("0123456789")); return err }, OpEncrypt, ErrKeyUsageExceeded},
+		{func() error { c.Wipe(); _, err := c.Decrypt(Y); return err }, OpDecrypt, ErrWiped},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			before := m.Errors(test.op)
+			err := test.call()
+			errs := m.Errors(test.op)
+			if test.want == nil {
+				if err != nil || len(errs) != len(before) {
+					t.Fatalf("got %v, observed %v", err, errs)
+				}
+				return
+			}
+			if len(errs) != len(before)+1 || errs[len(errs)-1] != err || !errors.Is(err, test.want) {
+				t.Fatalf("got %v, observed %v, want %v", err, errs, test.want)
+			}
+		})
+	}
+	if m.Total() != 8 {
+		t.Fatalf("observed %d calls, want 8", m.Total())
+	}
+}
+
+func TestMetricsParallel(t *testing.T) {
+	var m metricstest.Counter
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak), WithMetrics(&m))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	in := make(chan []byte)
+	out := make(chan Result)
+	go func() {
+		for i := 0; i < 20; i++ {
+			in <- []byte(fmt.Sprintf("%06d", i))
+		}
+		close(in)
+	}()
+	done := make(chan error)
+	go func() { done <- c.EncryptParallel(context.Background(), in, out, 4) }()
+	for i := 0; i < 20; i++ {
+		<-out
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if m.Calls(OpEncrypt) != 20 {
+		t.Fatalf("observed %d encryptions, want 20", m.Calls(OpEncrypt))
+	}
+}
+
+// Observing does not allocate
+func TestMetricsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not meaningful under the race detector")
+	}
+	calls := 0
+	hook := hookFunc(func(op string, dur time.Duration, err error) { calls++ })
+	c, err := NewCipher(10, 16, mustHex(testVectors[0].key), nil, WithMetrics(hook))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	e := c.NewEncryptor()
+	dst := make([]byte, len(testVectors[0].plaintext))
+	if _, err := e.Encrypt(dst, testVectors[0].plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if n := testing.AllocsPerRun(100, func() { e.Encrypt(dst, testVectors[0].plaintext) }); n != 0 {
+		t.Fatalf("Encrypt: %v allocs per call", n)
+	}
+	if calls != 102 {
+		t.Fatalf("hook called %d times, want 102", calls)
+	}
+}
+
+func BenchmarkMetrics(b *testing.B) {
+	testVector := testVectors[0]
+	dst := make([]byte, len(testVector.plaintext))
+	for _, hook := range []struct {
+		name string
+		opts []Option
+	}{
+		{"NoHook", nil},
+		{"Hook", []Option{WithMetrics(hookFunc(func(string, time.Duration, error) {}))}},
+	} {
+		c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak), hook.opts...)
+		if err != nil {
+			b.Fatalf("Unable to create cipher: %v", err)
+		}
+		b.Run(hook.name, func(b *testing.B) {
+			e := c.NewEncryptor()
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				e.Encrypt(dst, testVector.plaintext)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package metricstest provides a MetricsHook for the ff1 package that counts the
+// calls it observes, for tests.
+package metricstest
+
+import (
+	"sync"
+	"time"
+)
+
+// Counter counts observed calls by operation. Its zero value is ready to use, and
+// it is safe for concurrent use.
+type Counter struct {
+	mu     sync.Mutex
+	calls  map[string]int
+	errs   map[string][]error
+	total  map[string]time.Duration
+	events int
+}
+
+// Observe counts one call, see ff1.MetricsHook.
+func (c *Counter) Observe(op string, dur time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+		c.errs = make(map[string][]error)
+		c.total = make(map[string]time.Duration)
+	}
+	c.calls[op]++
+	c.total[op] += dur
+	c.events++
+	if err != nil {
+		c.errs[op] = append(c.errs[op], err)
+	}
+}
+
+// Calls returns the number of calls of op observed.
+func (c *Counter) Calls(op string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[op]
+}
+
+// Errors returns the errors of the failed calls of op, in the order observed.
+func (c *Counter) Errors(op string) []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]error{}, c.errs[op]...)
+}
+
+// Duration returns the total time of the calls of op.
+func (c *Counter) Duration(op string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total[op]
+}
+
+// Total returns the number of calls observed, of any operation.
+func (c *Counter) Total() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.events
+}
@@ -0,0 +1,88 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+// The Must functions are for ciphers built once from fixed parameters, such as in
+// the initialization of package-level variables. Like regexp.MustCompile, they
+// panic if the constructor they wrap returns an error, with a message that names
+// the constructor and includes the error. They never include the key.
+
+// MustNewCipher is like NewCipher but panics if it returns an error.
+func MustNewCipher(radix int, maxTLen int, key []byte, tweak []byte, opts ...Option) Cipher {
+	c, err := NewCipher(radix, maxTLen, key, tweak, opts...)
+	if err != nil {
+		panic(`ff1: NewCipher: ` + err.Error())
+	}
+	return c
+}
+
+// MustNewCipherWithAlphabet is like NewCipherWithAlphabet but panics if it returns an error.
+func MustNewCipherWithAlphabet(alphabet []byte, maxTLen int, key []byte, tweak []byte, opts ...Option) Cipher {
+	c, err := NewCipherWithAlphabet(alphabet, maxTLen, key, tweak, opts...)
+	if err != nil {
+		panic(`ff1: NewCipherWithAlphabet: ` + err.Error())
+	}
+	return c
+}
+
+// MustNewCipherWithSecret is like NewCipherWithSecret but panics if it returns an error.
+func MustNewCipherWithSecret(sk SecretKey, alphabet []byte, maxTLen int, tweak []byte, opts ...Option) Cipher {
+	c, err := NewCipherWithSecret(sk, alphabet, maxTLen, tweak, opts...)
+	if err != nil {
+		panic(`ff1: NewCipherWithSecret: ` + err.Error())
+	}
+	return c
+}
+
+// MustNewCipherFIPS is like NewCipherFIPS but panics if it returns an error.
+func MustNewCipherFIPS(alphabet []byte, maxTLen int, key []byte, tweak []byte, opts ...Option) Cipher {
+	c, err := NewCipherFIPS(alphabet, maxTLen, key, tweak, opts...)
+	if err != nil {
+		panic(`ff1: NewCipherFIPS: ` + err.Error())
+	}
+	return c
+}
+
+// MustNewCipherFamily is like NewCipherFamily but panics if it returns an error.
+func MustNewCipherFamily(key []byte) *Family {
+	f, err := NewCipherFamily(key)
+	if err != nil {
+		panic(`ff1: NewCipherFamily: ` + err.Error())
+	}
+	return f
+}
+
+// MustNewCascadeCipher is like NewCascadeCipher but panics if it returns an error.
+func MustNewCascadeCipher(c1, c2 *Cipher) *Cascade {
+	cc, err := NewCascadeCipher(c1, c2)
+	if err != nil {
+		panic(`ff1: NewCascadeCipher: ` + err.Error())
+	}
+	return cc
+}
+
+// MustNewPseudonymizer is like NewPseudonymizer but panics if it returns an error.
+func MustNewPseudonymizer(masterKey, salt, alphabet []byte, opts ...Option) *Pseudonymizer {
+	p, err := NewPseudonymizer(masterKey, salt, alphabet, opts...)
+	if err != nil {
+		panic(`ff1: NewPseudonymizer: ` + err.Error())
+	}
+	return p
+}
@@ -0,0 +1,133 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mustPanic calls f and returns the message it panics with, or fails the test if
+// it does not panic.
+func mustPanic(t *testing.T, f func()) (msg string) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("no panic")
+		}
+		msg = fmt.Sprint(r)
+	}()
+	f()
+	return ""
+}
+
+func TestMustPanics(t *testing.T) {
+	key := mustHex(testVectors[0].key)
+	badKey := make([]byte, 17)
+	digits := []byte("0123456789")
+
+	_, cipherErr := NewCipher(1, 0, key, nil)
+	_, alphabetErr := NewCipherWithAlphabet(digits, 0, badKey, nil)
+	_, secretErr := NewCipherWithSecret(RawKey(key), digits, 0, []byte("x"))
+	_, fipsErr := NewCipherFIPS(digits, -1, key, nil)
+	_, familyErr := NewCipherFamily(badKey)
+	_, cascadeErr := NewCascadeCipher(nil, nil)
+	_, pseudonymErr := NewPseudonymizer(key, nil, digits)
+
+	testMusts := []struct {
+		f      func()
+		prefix string
+		err    error
+	}{
+		{func() { MustNewCipher(1, 0, key, nil) }, "ff1: NewCipher: ", cipherErr},
+		{func() { MustNewCipherWithAlphabet(digits, 0, badKey, nil) }, "ff1: NewCipherWithAlphabet: ", alphabetErr},
+		{func() { MustNewCipherWithSecret(RawKey(key), digits, 0, []byte("x")) }, "ff1: NewCipherWithSecret: ", secretErr},
+		{func() { MustNewCipherFIPS(digits, -1, key, nil) }, "ff1: NewCipherFIPS: ", fipsErr},
+		{func() { MustNewCipherFamily(badKey) }, "ff1: NewCipherFamily: ", familyErr},
+		{func() { MustNewCascadeCipher(nil, nil) }, "ff1: NewCascadeCipher: ", cascadeErr},
+		{func() { MustNewPseudonymizer(key, nil, digits) }, "ff1: NewPseudonymizer: ", pseudonymErr},
+	}
+	for idx, testMust := range testMusts {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if testMust.err == nil {
+				t.Fatal("constructor did not fail")
+			}
+			msg := mustPanic(t, testMust.f)
+			if !strings.HasPrefix(msg, testMust.prefix) || !strings.Contains(msg, testMust.err.Error()) {
+				t.Fatalf("panic %q, want %q followed by %q", msg, testMust.prefix, testMust.err)
+			}
+		})
+	}
+}
+
+func TestMustSuccess(t *testing.T) {
+	for idx, testVector := range testVectors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			key := mustHex(testVector.key)
+			tweak := mustHex(testVector.tweak)
+
+			want, err := NewCipher(testVector.radix, 16, key, tweak, WithTagLen(8))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := MustNewCipher(testVector.radix, 16, key, tweak, WithTagLen(8))
+			if !reflect.DeepEqual(got, want) {
+				t.Fatal("MustNewCipher returned a different cipher than NewCipher")
+			}
+
+			alphabet, err := legacyAlphabetFor(testVector.radix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err = NewCipherWithAlphabet(alphabet, 16, key, tweak)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = MustNewCipherWithAlphabet(alphabet, 16, key, tweak)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatal("MustNewCipherWithAlphabet returned a different cipher than NewCipherWithAlphabet")
+			}
+			got = MustNewCipherWithSecret(RawKey(key), alphabet, 16, tweak)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatal("MustNewCipherWithSecret returned a different cipher than NewCipherWithAlphabet")
+			}
+
+			ciphertext, err := got.Encrypt(testVector.plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+				t.Fatalf("ciphertext %s, want %s", ciphertext, testVector.ciphertext)
+			}
+		})
+	}
+
+	key := mustHex(testVectors[0].key)
+	wantFamily, err := NewCipherFamily(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := MustNewCipherFamily(key); !reflect.DeepEqual(got, wantFamily) {
+		t.Fatal("MustNewCipherFamily returned a different Family than NewCipherFamily")
+	}
+}
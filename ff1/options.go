@@ -0,0 +1,133 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+// An Option configures optional behaviour of a Cipher at construction time.
+type Option func(*Cipher)
+
+// WithZeroize makes Encrypt and Decrypt overwrite the intermediate buffers and
+// big.Int values that held plaintext-derived data before returning, including
+// the pooled scratch memory before it is put back and the scratch of an Encryptor.
+//
+// Some copies are out of reach: the temporaries math/big allocates internally
+// while dividing, the old backing array of a big.Int that had to grow, the uint64
+// values of the round fast path held in registers and on the stack, and whatever
+// the caller passes in or gets back. Go gives no guarantee for these, so this
+// narrows the window rather than closing it.
+func WithZeroize() Option {
+	return func(c *Cipher) {
+		c.zeroize = true
+	}
+}
+
+// WithParallelExpansion spreads the generation of the extra PRF output blocks of
+// each Feistel round (NIST SP 800-38G, Algorithm 7, step 6iii) over up to
+// GOMAXPROCS goroutines, for messages that need at least minBlocks of them.
+// Only very long messages need more than a handful of blocks per round, so
+// shorter messages are unaffected. The output does not change. A minBlocks
+// below 1 is treated as 1.
+func WithParallelExpansion(minBlocks int) Option {
+	return func(c *Cipher) {
+		if minBlocks < 1 {
+			minBlocks = 1
+		}
+		c.parallelMinBlocks = minBlocks
+	}
+}
+
+// WithGenericArithmetic turns off the conversions specialized for radix 10 and
+// radix 62, so every radix uses the same code. The results are the same either
+// way, this is meant for differential testing.
+func WithGenericArithmetic() Option {
+	return func(c *Cipher) {
+		c.genericArithmetic = true
+	}
+}
+
+// WithUsageLimit allows the Cipher maxOps encryptions. Further calls to Encrypt
+// and its variants fail with ErrKeyUsageExceeded, while Decrypt keeps working so
+// that data encrypted before stays readable. Calls rejected for invalid input
+// do not count.
+//
+// All copies of the Cipher share one count, so copying a Cipher does not reset
+// it. Ciphers from separate constructor calls count separately, even ciphers
+// of one Family, which share the key.
+func WithUsageLimit(maxOps uint64) Option {
+	return func(c *Cipher) {
+		c.usage = &usageCounter{limit: maxOps}
+	}
+}
+
+// WithVerifyRoundTrip makes Encrypt and its variants decrypt every ciphertext
+// again, with separate working memory, and compare the result with the plaintext
+// in constant time before returning it. A mismatch, which can only come from a
+// fault in the implementation or the machine, is reported as ErrRoundTripMismatch
+// instead of a ciphertext that cannot be decrypted. Encryption takes about twice
+// as long and allocates the intermediate buffers.
+func WithVerifyRoundTrip() Option {
+	return func(c *Cipher) {
+		c.verifyRoundTrip = true
+	}
+}
+
+// WithMaxInputLen makes Encrypt and Decrypt reject messages longer than n numerals
+// with ErrInputTooLong, before doing any work that grows with the length. Without
+// it the limit is the 2^32-1 numerals allowed by FF1, which is also the largest
+// limit that can be set. Creating the Cipher fails if n is below MinLen.
+func WithMaxInputLen(n int) Option {
+	return func(c *Cipher) {
+		switch {
+		case n < 0:
+			c.maxLen = 0
+		case uint64(n) < uint64(c.maxLen):
+			c.maxLen = uint32(n)
+		}
+	}
+}
+
+// WithTagLen sets the length in bytes of the tags of EncryptWithTag, which is 16
+// without it. It must be between 8 and 32, shorter tags are easier to forge by
+// chance and longer ones are not possible.
+func WithTagLen(n int) Option {
+	return func(c *Cipher) {
+		c.tagLen = n
+	}
+}
+
+// WithDecryptCache makes Decrypt and its variants remember the plaintexts of the
+// last maxEntries ciphertexts they decrypted, per tweak, and return them without
+// decrypting again. A maxEntries below 1 leaves the cache off. PurgeCache empties
+// it, and SwapKey and Wipe do so too.
+//
+// This trades memory and exposure for speed: the plaintexts stay in memory until
+// they are evicted or purged, where WithZeroize cannot reach them, and whether a
+// call is answered from the cache shows in its timing, revealing that the same
+// ciphertext was decrypted recently. Only enable it where both are acceptable.
+// Lookups themselves do not depend on the ciphertexts in the cache in any other
+// way an observer could see.
+func WithDecryptCache(maxEntries int) Option {
+	return func(c *Cipher) {
+		if maxEntries < 1 {
+			c.dcache = nil
+			return
+		}
+		c.dcache = newDecryptCache(maxEntries)
+	}
+}
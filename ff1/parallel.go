@@ -0,0 +1,118 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// A Result is the outcome of encrypting or decrypting one value received by
+// EncryptParallel or DecryptParallel. Index is the position of the value in the
+// input channel, counting from 0.
+type Result struct {
+	Index int
+	Value []byte
+	Err   error
+}
+
+// EncryptParallel encrypts every value received from in with workers goroutines
+// and sends one Result per value to out, until in is closed. Results are sent in
+// completion order, use Index to restore the input order. A value that cannot be
+// encrypted produces a Result with Err set and does not stop the others.
+//
+// Both channels may be unbuffered, a slow reader of out simply holds back the
+// workers. workers <= 0 means GOMAXPROCS. Each worker reuses pooled scratch
+// buffers, so the Cipher itself is shared without any locking.
+//
+// EncryptParallel returns nil once all results are sent, or ctx.Err() if ctx is
+// cancelled before that, after all of its goroutines have stopped. It never
+// closes out.
+func (c Cipher) EncryptParallel(ctx context.Context, in <-chan []byte, out chan<- Result, workers int) error {
+	return c.cryptParallel(ctx, in, out, workers, true)
+}
+
+// DecryptParallel is the same as EncryptParallel, for decryption.
+func (c Cipher) DecryptParallel(ctx context.Context, in <-chan []byte, out chan<- Result, workers int) error {
+	return c.cryptParallel(ctx, in, out, workers, false)
+}
+
+func (c Cipher) cryptParallel(ctx context.Context, in <-chan []byte, out chan<- Result, workers int, encrypt bool) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type job struct {
+		index int
+		value []byte
+	}
+	jobs := make(chan job)
+
+	// Set if a result was dropped because ctx was cancelled
+	var dropped int32
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				value, err := c.crypt(ctx, nil, nil, j.value, c.tweak, encrypt)
+				select {
+				case out <- Result{Index: j.index, Value: value, Err: err}:
+				case <-ctx.Done():
+					atomic.StoreInt32(&dropped, 1)
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+	index := 0
+dispatch:
+	for {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		case value, ok := <-in:
+			if !ok {
+				break dispatch
+			}
+			select {
+			case jobs <- job{index: index, value: value}:
+				index++
+			case <-ctx.Done():
+				err = ctx.Err()
+				break dispatch
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err == nil && atomic.LoadInt32(&dropped) != 0 {
+		err = ctx.Err()
+	}
+	return err
+}
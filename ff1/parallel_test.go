@@ -0,0 +1,205 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sixteenDigits returns the i-th 16 digit value
+func sixteenDigits(i int) []byte {
+	return []byte(fmt.Sprintf("%016d", i*7919))
+}
+
+func TestEncryptParallel(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[0].key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	const numValues = 1000
+	values := make([][]byte, numValues)
+	for i := range values {
+		values[i] = sixteenDigits(i)
+	}
+	// Not in the alphabet, must be reported without stopping the other values
+	values[500] = []byte("not a number!!!!")
+
+	for _, workers := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("Workers%d", workers), func(t *testing.T) {
+			in := make(chan []byte)
+			out := make(chan Result)
+			go func() {
+				for _, v := range values {
+					in <- v
+				}
+				close(in)
+			}()
+
+			errc := make(chan error, 1)
+			go func() {
+				errc <- c.EncryptParallel(context.Background(), in, out, workers)
+				close(out)
+			}()
+
+			ciphertexts := make([][]byte, numValues)
+			seen := 0
+			for r := range out {
+				if r.Index < 0 || r.Index >= numValues || ciphertexts[r.Index] != nil {
+					t.Fatalf("unexpected or duplicate index %d", r.Index)
+				}
+				if r.Index == 500 {
+					if r.Err == nil {
+						t.Fatalf("expected an error for value 500")
+					}
+					ciphertexts[r.Index] = []byte{}
+					seen++
+					continue
+				}
+				if r.Err != nil {
+					t.Fatalf("value %d: %v", r.Index, r.Err)
+				}
+				ciphertexts[r.Index] = r.Value
+				seen++
+			}
+			if err := <-errc; err != nil {
+				t.Fatalf("EncryptParallel: %v", err)
+			}
+			if seen != numValues {
+				t.Fatalf("got %d results, expected %d", seen, numValues)
+			}
+
+			for i, v := range values {
+				if i == 500 {
+					continue
+				}
+				expected, _ := c.Encrypt(v)
+				if !reflect.DeepEqual(ciphertexts[i], expected) {
+					t.Fatalf("value %d: got %s expected %s", i, ciphertexts[i], expected)
+				}
+			}
+
+			// And back again
+			in = make(chan []byte, numValues)
+			for i, ct := range ciphertexts {
+				if i != 500 {
+					in <- ct
+				}
+			}
+			close(in)
+			out = make(chan Result, numValues)
+			if err := c.DecryptParallel(context.Background(), in, out, workers); err != nil {
+				t.Fatalf("DecryptParallel: %v", err)
+			}
+			close(out)
+			for r := range out {
+				i := r.Index
+				if i >= 500 {
+					// The failed value was left out of the input
+					i++
+				}
+				if r.Err != nil || !reflect.DeepEqual(r.Value, values[i]) {
+					t.Fatalf("value %d: decrypted to %s, %v", i, r.Value, r.Err)
+				}
+			}
+		})
+	}
+}
+
+// Cancelling the context stops EncryptParallel even when nobody reads the results
+// or closes the input
+func TestEncryptParallelCancel(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[0].key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	in := make(chan []byte)
+	out := make(chan Result)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case in <- sixteenDigits(i):
+			case <-time.After(time.Second):
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.EncryptParallel(ctx, in, out, 4)
+	}()
+
+	// Take a few results, then stop reading
+	for i := 0; i < 10; i++ {
+		<-out
+	}
+	cancel()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, expected %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("EncryptParallel did not return after cancellation")
+	}
+}
+
+// BenchmarkEncryptParallel encrypts 1M 16 digit values per operation
+func BenchmarkEncryptParallel(b *testing.B) {
+	const numValues = 1000000
+	c, err := NewCipher(10, 16, mustHex(testVectors[0].key), nil)
+	if err != nil {
+		b.Fatalf("Unable to create cipher: %v", err)
+	}
+	values := make([][]byte, 1000)
+	for i := range values {
+		values[i] = sixteenDigits(i)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				in := make(chan []byte, 256)
+				out := make(chan Result, 256)
+				go func() {
+					for i := 0; i < numValues; i++ {
+						in <- values[i%len(values)]
+					}
+					close(in)
+				}()
+				go func() {
+					c.EncryptParallel(context.Background(), in, out, workers)
+					close(out)
+				}()
+				for range out {
+				}
+			}
+		})
+	}
+}
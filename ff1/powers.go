@@ -0,0 +1,74 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+// maxCachedPowers bounds the number of exponents for which radix^e is kept.
+// Every message length needs two exponents, and neighbouring lengths share one.
+const maxCachedPowers = 2 * maxCachedLengths
+
+// powerTable holds the powers of the radix computed so far. It is safe for
+// concurrent use and the values it returns are never modified.
+type powerTable struct {
+	// Number of powers computed with Exp, for tests and benchmarks.
+	// First so that it is 64-bit aligned for the atomic operations on 32-bit platforms.
+	computed uint64
+
+	radix big.Int
+
+	mu     sync.RWMutex
+	powers map[uint32]*big.Int
+}
+
+func newPowerTable(radix uint64) *powerTable {
+	pt := &powerTable{powers: make(map[uint32]*big.Int)}
+	pt.radix.SetUint64(radix)
+	return pt
+}
+
+// pow returns radix^e. The result is shared and must not be modified.
+func (pt *powerTable) pow(e uint32) *big.Int {
+	pt.mu.RLock()
+	p, ok := pt.powers[e]
+	pt.mu.RUnlock()
+	if ok {
+		return p
+	}
+
+	var exp big.Int
+	exp.SetUint64(uint64(e))
+	p = new(big.Int).Exp(&pt.radix, &exp, nil)
+	atomic.AddUint64(&pt.computed, 1)
+
+	pt.mu.Lock()
+	if q, ok := pt.powers[e]; ok {
+		// Another goroutine got there first, keep a single value per exponent
+		p = q
+	} else if len(pt.powers) < maxCachedPowers {
+		pt.powers[e] = p
+	}
+	pt.mu.Unlock()
+	return p
+}
@@ -0,0 +1,132 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPowerTable(t *testing.T) {
+	for _, radix := range []uint64{2, 10, 36, 256} {
+		pt := newPowerTable(radix)
+		for e := uint32(0); e < 200; e++ {
+			expected := new(big.Int).Exp(new(big.Int).SetUint64(radix), big.NewInt(int64(e)), nil)
+			p := pt.pow(e)
+			if p.Cmp(expected) != 0 {
+				t.Fatalf("radix %d: pow(%d) = %s, expected %s", radix, e, p, expected)
+			}
+			if e < maxCachedPowers && pt.pow(e) != p {
+				t.Fatalf("radix %d: pow(%d) returned a different value the second time", radix, e)
+			}
+		}
+		if len(pt.powers) != maxCachedPowers {
+			t.Fatalf("radix %d: %d powers kept, expected at most %d", radix, len(pt.powers), maxCachedPowers)
+		}
+		if pt.computed != 200 {
+			t.Fatalf("radix %d: %d powers computed", radix, pt.computed)
+		}
+	}
+}
+
+// Many goroutines share the table while encrypting messages of different lengths; run with -race
+func TestPowerTableConcurrent(t *testing.T) {
+	c, err := NewCipher(36, 16, mustHex(testVectors[2].key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	// No length cache, so every call goes to the power table
+	c.cache = nil
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for iter := 0; iter < 40; iter++ {
+				n := 2 + (g*13+iter*7)%150
+				X := make([]byte, n)
+				for i := range X {
+					X[i] = "0123456789abcdefghijklmnopqrstuvwxyz"[(i*g+iter)%36]
+				}
+				ciphertext, err := c.Encrypt(X)
+				if err != nil {
+					errs <- err
+					return
+				}
+				plaintext, err := c.Decrypt(ciphertext)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if string(plaintext) != string(X) {
+					errs <- fmt.Errorf("length %d does not round trip", n)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkPowerTable encrypts messages of one length without the length cache, with
+// and without the power table, and reports the number of Exp calls per Encrypt
+func BenchmarkPowerTable(b *testing.B) {
+	X := []byte("xs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwd")
+	for _, table := range []bool{false, true} {
+		c, err := NewCipher(36, 16, mustHex(testVectors[2].key), nil)
+		if err != nil {
+			b.Fatalf("Unable to create cipher: %v", err)
+		}
+		c.cache = nil
+		if !table {
+			c.powers = nil
+		}
+
+		name := "NoTable"
+		if table {
+			name = "Table"
+		}
+		b.Run(name, func(b *testing.B) {
+			var before uint64
+			if c.powers != nil {
+				before = atomic.LoadUint64(&c.powers.computed)
+			}
+			for n := 0; n < b.N; n++ {
+				c.Encrypt(X)
+			}
+
+			exps := float64(2 * b.N)
+			if c.powers != nil {
+				exps = float64(atomic.LoadUint64(&c.powers.computed) - before)
+			}
+			b.ReportMetric(exps/float64(b.N), "exps/op")
+		})
+	}
+}
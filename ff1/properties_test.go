@@ -0,0 +1,61 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+	"github.com/Tensai75/go-fpe-bytes/fpetest"
+)
+
+// This is in an external package, as the tests of fpetest import ff1
+
+func TestProperties(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	for idx, testCase := range []struct {
+		radix  int
+		opts   []ff1.Option
+		inputs [][]byte
+	}{
+		{10, nil, [][]byte{[]byte("00"), []byte("0123456789"), []byte("01234567890123456789012345678901234567890")}},
+		{10, []ff1.Option{ff1.WithGenericArithmetic()}, [][]byte{[]byte("00"), []byte("0123456789")}},
+		{2, nil, [][]byte{[]byte("0000000"), []byte("0101010101010101010101010101010101010101010101010101010101010101")}},
+		{36, []ff1.Option{ff1.WithZeroize(), ff1.WithVerifyRoundTrip()}, [][]byte{[]byte("zz"), []byte("hello0world")}},
+		{62, nil, [][]byte{[]byte("Az"), []byte("HelloWorld0123456789")}},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := ff1.NewCipher(testCase.radix, 8, key, []byte("tweak"), testCase.opts...)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			fpetest.RoundTrip(t, c, testCase.inputs)
+			fpetest.BijectionSample(t, c, 1000, c.MinLen())
+			fpetest.BijectionSample(t, c, 1000, 12)
+
+			other, err := ff1.NewCipher(testCase.radix, 8, []byte("fedcba9876543210"), []byte("tweak"), testCase.opts...)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			fpetest.KeySeparation(t, c, other, testCase.inputs)
+		})
+	}
+}
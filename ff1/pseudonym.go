@@ -0,0 +1,134 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// pseudonymInfo separates the keys derived for pseudonymization from any other
+// use of the master key.
+const pseudonymInfo = "go-fpe-bytes ff1 pseudonymizer v1"
+
+// minSaltLen is the shortest salt NewPseudonymizer accepts, and the length NewSalt returns.
+const minSaltLen = 16
+
+// A Pseudonymizer replaces values with pseudonyms from the same alphabet and of the
+// same length. Equal inputs get equal pseudonyms, so they can still be joined and
+// counted, but the Pseudonymizer has no way to map a pseudonym back.
+//
+// The pseudonyms are FF1 encryptions under a key derived with HKDF-SHA256 from a
+// master key and a salt. Anyone holding both can derive the key again and decrypt,
+// so the mapping only becomes one-way once every copy of the salt is destroyed,
+// see DestroySalt. The master key alone, such as the production key of the data,
+// is not enough.
+//
+// Like any deterministic mapping, pseudonyms of a small domain can be reversed by
+// pseudonymizing every possible value while the Pseudonymizer still works, such as
+// all 10^9 nine-digit numbers. Access to Pseudonymize must be limited accordingly.
+type Pseudonymizer struct {
+	c    Cipher
+	salt []byte
+}
+
+// NewSalt returns a random salt for NewPseudonymizer.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, minSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// NewPseudonymizer derives a key from masterKey and salt and returns a Pseudonymizer
+// for values made of bytes of alphabet. The master key must be at least 128 bits
+// long and the salt at least 16 bytes. Options are applied as for NewCipherWithAlphabet.
+//
+// Unlike the other constructors, NewPseudonymizer does not copy the salt: the
+// Pseudonymizer takes it over, and DestroySalt overwrites it. The master key is not kept.
+func NewPseudonymizer(masterKey, salt, alphabet []byte, opts ...Option) (*Pseudonymizer, error) {
+	if len(masterKey) < 16 {
+		return nil, errors.New("master key must be at least 128 bits")
+	}
+	if len(salt) < minSaltLen {
+		return nil, errors.New("salt must be at least 16 bytes")
+	}
+
+	key := hkdfSHA256(masterKey, salt, []byte(pseudonymInfo), 32)
+	c, err := NewCipherWithAlphabet(alphabet, 0, key, nil, opts...)
+	for i := range key {
+		key[i] = 0
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Pseudonymizer{c: c, salt: salt}, nil
+}
+
+// Pseudonymize returns the pseudonym of X. It fails with ErrWiped after DestroySalt.
+func (p *Pseudonymizer) Pseudonymize(X []byte) ([]byte, error) {
+	if p == nil {
+		return nil, ErrNotInitialized
+	}
+	return p.c.Encrypt(X)
+}
+
+// DestroySalt overwrites the salt passed to NewPseudonymizer and wipes the derived
+// key, so the Pseudonymizer cannot be used anymore. Once every other copy of the
+// salt is gone too, nobody can compute or reverse the mapping again, even with
+// the master key. DestroySalt must not be called while Pseudonymize is running.
+func (p *Pseudonymizer) DestroySalt() {
+	if p == nil {
+		return
+	}
+	for i := range p.salt {
+		p.salt[i] = 0
+	}
+	p.c.Wipe()
+}
+
+// hkdfSHA256 implements HKDF (RFC 5869) with SHA-256 for outputs of up to 255 hash lengths.
+func hkdfSHA256(secret, salt, info []byte, n int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	out := make([]byte, 0, n+sha256.Size)
+	var t []byte
+	for i := byte(1); len(out) < n; i++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{i})
+		t = expand.Sum(t[:0])
+		out = append(out, t...)
+	}
+	for i := range prk {
+		prk[i] = 0
+	}
+	for i := range t {
+		t[i] = 0
+	}
+	return out[:n]
+}
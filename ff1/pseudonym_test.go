@@ -0,0 +1,147 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestHKDFSHA256(t *testing.T) {
+	// RFC 5869, test case 1
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt := mustHex("000102030405060708090a0b0c")
+	info := mustHex("f0f1f2f3f4f5f6f7f8f9")
+	want := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+
+	if got := hex.EncodeToString(hkdfSHA256(secret, salt, info, 42)); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestPseudonymizer(t *testing.T) {
+	masterKey := mustHex("2B7E151628AED2A6ABF7158809CF4F3C")
+	digits := []byte("0123456789")
+	inputs := [][]byte{
+		[]byte("078051120"),
+		[]byte("219099999"),
+		[]byte("457555462"),
+	}
+
+	p1, err := NewPseudonymizer(masterKey, bytes.Repeat([]byte{1}, 16), digits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := NewPseudonymizer(masterKey, bytes.Repeat([]byte{2}, 16), digits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewCipherWithAlphabet(digits, 0, masterKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, input := range inputs {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			first, err := p1.Pseudonymize(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := p1.Pseudonymize(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first, again) {
+				t.Fatalf("pseudonyms of %s differ: %s and %s", input, first, again)
+			}
+
+			if len(first) != len(input) {
+				t.Fatalf("pseudonym %s has length %d, want %d", first, len(first), len(input))
+			}
+			for _, b := range first {
+				if bytes.IndexByte(digits, b) < 0 {
+					t.Fatalf("pseudonym %s is not all digits", first)
+				}
+			}
+
+			other, err := p2.Pseudonymize(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(first, other) {
+				t.Fatalf("different salts give the same pseudonym %s", first)
+			}
+
+			direct, err := plain.Encrypt(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(first, direct) {
+				t.Fatalf("pseudonym %s is the encryption under the master key", first)
+			}
+		})
+	}
+}
+
+func TestPseudonymizerDestroySalt(t *testing.T) {
+	masterKey := mustHex("2B7E151628AED2A6ABF7158809CF4F3C")
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPseudonymizer(masterKey, salt, []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Pseudonymize([]byte("078051120")); err != nil {
+		t.Fatal(err)
+	}
+
+	p.DestroySalt()
+	if !bytes.Equal(salt, make([]byte, len(salt))) {
+		t.Fatalf("salt not overwritten: %x", salt)
+	}
+	if _, err := p.Pseudonymize([]byte("078051120")); !errors.Is(err, ErrWiped) {
+		t.Fatalf("got %v, want %v", err, ErrWiped)
+	}
+	p.DestroySalt()
+}
+
+func TestPseudonymizerError(t *testing.T) {
+	digits := []byte("0123456789")
+	if _, err := NewPseudonymizer(make([]byte, 15), make([]byte, 16), digits); err == nil {
+		t.Fatal("short master key accepted")
+	}
+	if _, err := NewPseudonymizer(make([]byte, 16), make([]byte, 15), digits); err == nil {
+		t.Fatal("short salt accepted")
+	}
+	if _, err := NewPseudonymizer(make([]byte, 16), make([]byte, 16), []byte("0")); err == nil {
+		t.Fatal("alphabet of one byte accepted")
+	}
+
+	var p *Pseudonymizer
+	if _, err := p.Pseudonymize([]byte("1")); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("got %v, want %v", err, ErrNotInitialized)
+	}
+	p.DestroySalt()
+}
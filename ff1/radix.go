@@ -0,0 +1,160 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// A radixConv converts between numeral strings and uint64 values for the uint64
+// rounds. Both methods behave exactly like fpeUtils.Num64 and fpeUtils.Str64 for
+// the radix of the Cipher.
+type radixConv interface {
+	num64(s []uint8) (uint64, bool)
+	str64(v uint64, r []uint8) error
+}
+
+// newRadixConv returns the conversion for the given radix: a specialized one for
+// radix 10 and 62 unless generic is set, and one based on fpeUtils otherwise.
+func newRadixConv(radix uint64, generic bool) radixConv {
+	if !generic {
+		switch radix {
+		case 10:
+			return radix10Conv{}
+		case 62:
+			return radix62Conv{}
+		}
+	}
+	return genericConv{radix}
+}
+
+type genericConv struct {
+	radix uint64
+}
+
+func (g genericConv) num64(s []uint8) (uint64, bool) {
+	return fpeUtils.Num64(s, g.radix)
+}
+
+func (g genericConv) str64(v uint64, r []uint8) error {
+	return fpeUtils.Str64(v, r, g.radix)
+}
+
+// radix10Conv handles two digits per step, multiplying and dividing by the
+// constant 100, which the compiler turns into cheap multiplications.
+type radix10Conv struct{}
+
+// 10^19 is the largest power of 10 below 2^64
+const maxDigits10 = 19
+
+func (radix10Conv) num64(s []uint8) (uint64, bool) {
+	if len(s) > maxDigits10 {
+		// Leading zeros may still make it fit
+		return fpeUtils.Num64(s, 10)
+	}
+
+	var x uint64
+	i := 0
+	if len(s)%2 == 1 {
+		if s[0] > 9 {
+			return 0, false
+		}
+		x = uint64(s[0])
+		i = 1
+	}
+	for ; i < len(s); i += 2 {
+		d0, d1 := s[i], s[i+1]
+		if d0 > 9 || d1 > 9 {
+			return 0, false
+		}
+		x = x*100 + uint64(d0)*10 + uint64(d1)
+	}
+	return x, true
+}
+
+func (radix10Conv) str64(v uint64, r []uint8) error {
+	i := len(r)
+	for ; i >= 2 && v != 0; i -= 2 {
+		q := v / 100
+		pair := v - q*100
+		r[i-1] = uint8(pair % 10)
+		r[i-2] = uint8(pair / 10)
+		v = q
+	}
+	for ; i >= 1; i-- {
+		r[i-1] = uint8(v % 10)
+		v /= 10
+	}
+	if v != 0 {
+		return fmt.Errorf("destination array too small: %d remains after conversion", v)
+	}
+	return nil
+}
+
+// radix62Conv is radix10Conv for radix 62, two numerals per step with the constant 62^2.
+type radix62Conv struct{}
+
+// 62^10 is the largest power of 62 below 2^64
+const maxDigits62 = 10
+
+func (radix62Conv) num64(s []uint8) (uint64, bool) {
+	if len(s) > maxDigits62 {
+		return fpeUtils.Num64(s, 62)
+	}
+
+	var x uint64
+	i := 0
+	if len(s)%2 == 1 {
+		if s[0] > 61 {
+			return 0, false
+		}
+		x = uint64(s[0])
+		i = 1
+	}
+	for ; i < len(s); i += 2 {
+		d0, d1 := s[i], s[i+1]
+		if d0 > 61 || d1 > 61 {
+			return 0, false
+		}
+		x = x*(62*62) + uint64(d0)*62 + uint64(d1)
+	}
+	return x, true
+}
+
+func (radix62Conv) str64(v uint64, r []uint8) error {
+	i := len(r)
+	for ; i >= 2 && v != 0; i -= 2 {
+		q := v / (62 * 62)
+		pair := v - q*(62*62)
+		r[i-1] = uint8(pair % 62)
+		r[i-2] = uint8(pair / 62)
+		v = q
+	}
+	for ; i >= 1; i-- {
+		r[i-1] = uint8(v % 62)
+		v /= 62
+	}
+	if v != 0 {
+		return fmt.Errorf("destination array too small: %d remains after conversion", v)
+	}
+	return nil
+}
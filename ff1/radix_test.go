@@ -0,0 +1,155 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNewRadixConv(t *testing.T) {
+	tests := []struct {
+		radix    uint64
+		generic  bool
+		expected radixConv
+	}{
+		{10, false, radix10Conv{}},
+		{62, false, radix62Conv{}},
+		{36, false, genericConv{36}},
+		{10, true, genericConv{10}},
+		{62, true, genericConv{62}},
+	}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if conv := newRadixConv(test.radix, test.generic); conv != test.expected {
+				t.Fatalf("newRadixConv(%d, %v) = %#v, expected %#v", test.radix, test.generic, conv, test.expected)
+			}
+		})
+	}
+}
+
+// The specialized conversions must agree with the generic ones for every input,
+// including invalid digits, overflowing values and too short destinations
+func FuzzRadixConv(f *testing.F) {
+	f.Add(uint8(0), []byte{1, 2, 3}, uint64(123), uint8(3))
+	f.Add(uint8(1), []byte{61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61}, uint64(1<<63), uint8(11))
+	f.Add(uint8(0), []byte{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9}, ^uint64(0), uint8(19))
+	f.Add(uint8(0), []byte{0, 0, 10}, uint64(0), uint8(0))
+
+	f.Fuzz(func(t *testing.T, which uint8, s []byte, v uint64, rLen uint8) {
+		radix := uint64(10)
+		if which%2 == 1 {
+			radix = 62
+		}
+		specialized := newRadixConv(radix, false)
+		generic := newRadixConv(radix, true)
+
+		x1, ok1 := specialized.num64(s)
+		x2, ok2 := generic.num64(s)
+		if ok1 != ok2 || (ok1 && x1 != x2) {
+			t.Fatalf("radix %d: num64(%v) = %d, %v, generic gives %d, %v", radix, s, x1, ok1, x2, ok2)
+		}
+
+		r1 := make([]uint8, int(rLen)%24)
+		r2 := make([]uint8, len(r1))
+		err1 := specialized.str64(v, r1)
+		err2 := generic.str64(v, r2)
+		if (err1 == nil) != (err2 == nil) || (err1 == nil && !reflect.DeepEqual(r1, r2)) {
+			t.Fatalf("radix %d: str64(%d) into %d numerals = %v, %v, generic gives %v, %v", radix, v, len(r1), r1, err1, r2, err2)
+		}
+	})
+}
+
+func FuzzSpecializedEncrypt(f *testing.F) {
+	f.Add(uint8(0), []byte("0123456789"), []byte{})
+	f.Add(uint8(1), []byte("0123456789abcdefghi"), []byte("tweak"))
+	f.Add(uint8(0), []byte{0xff, 0x00}, []byte{0x01})
+
+	key := mustHex(testVectors[0].key)
+	f.Fuzz(func(t *testing.T, which uint8, digits, tweak []byte) {
+		radix := 10
+		if which%2 == 1 {
+			radix = 62
+		}
+		if len(tweak) > 16 {
+			tweak = tweak[:16]
+		}
+		// Up to 40 numerals, past the uint64 rounds for both radices
+		X := make([]byte, 2+int(which)%39)
+		for i := range X {
+			if len(digits) > 0 {
+				X[i] = byte(int(digits[i%len(digits)]) % radix)
+			}
+		}
+
+		specialized, err := NewCipherWithAlphabet(identityAlphabet(radix), 16, key, tweak)
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		generic, err := NewCipherWithAlphabet(identityAlphabet(radix), 16, key, tweak, WithGenericArithmetic())
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+
+		c1, err1 := specialized.Encrypt(X)
+		c2, err2 := generic.Encrypt(X)
+		if (err1 == nil) != (err2 == nil) || !reflect.DeepEqual(c1, c2) {
+			t.Fatalf("radix %d: Encrypt(%v) = %v, %v, generic gives %v, %v", radix, X, c1, err1, c2, err2)
+		}
+		if err1 != nil {
+			return
+		}
+		p1, err := specialized.Decrypt(c1)
+		if err != nil || !reflect.DeepEqual(p1, X) {
+			t.Fatalf("radix %d: Decrypt = %v, %v, expected %v", radix, p1, err, X)
+		}
+	})
+}
+
+// BenchmarkRadixConv converts a 19 digit radix 10 and a 10 numeral radix 62 value
+// back and forth
+func BenchmarkRadixConv(b *testing.B) {
+	for _, radix := range []uint64{10, 62} {
+		n := maxDigits10
+		if radix == 62 {
+			n = maxDigits62
+		}
+		s := make([]uint8, n)
+		for i := range s {
+			s[i] = uint8((i*7 + 1) % int(radix))
+		}
+
+		for _, generic := range []bool{true, false} {
+			conv := newRadixConv(radix, generic)
+			name := "Specialized"
+			if generic {
+				name = "Generic"
+			}
+			b.Run(fmt.Sprintf("Radix%d/%s", radix, name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					v, _ := conv.num64(s)
+					conv.str64(v, s)
+				}
+			})
+		}
+	}
+}
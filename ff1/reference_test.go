@@ -0,0 +1,304 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// referenceFF1 is a direct transcription of NIST SP 800-38G Algorithms 7 and 8,
+// written for clarity rather than speed and sharing no code with the package.
+// X holds numerals in [0, radix).
+func referenceFF1(key, tweak []byte, radix int, X []uint8, encrypt bool) []uint8 {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+
+	num := func(s []uint8) *big.Int {
+		x := new(big.Int)
+		for _, d := range s {
+			x.Mul(x, big.NewInt(int64(radix)))
+			x.Add(x, big.NewInt(int64(d)))
+		}
+		return x
+	}
+	str := func(x *big.Int, m int) []uint8 {
+		s := make([]uint8, m)
+		x = new(big.Int).Set(x)
+		mod := new(big.Int)
+		for i := m - 1; i >= 0; i-- {
+			x.DivMod(x, big.NewInt(int64(radix)), mod)
+			s[i] = uint8(mod.Int64())
+		}
+		return s
+	}
+	pow := func(m int) *big.Int {
+		return new(big.Int).Exp(big.NewInt(int64(radix)), big.NewInt(int64(m)), nil)
+	}
+	prf := func(x []byte) []byte {
+		y := make([]byte, 16)
+		for j := 0; j < len(x); j += 16 {
+			for k := 0; k < 16; k++ {
+				y[k] ^= x[j+k]
+			}
+			block.Encrypt(y, y)
+		}
+		return y
+	}
+
+	n, t := len(X), len(tweak)
+	u := n / 2
+	v := n - u
+	A := append([]uint8{}, X[:u]...)
+	B := append([]uint8{}, X[u:]...)
+
+	// b = ceil(ceil(v*log2(radix))/8), with ceil(v*log2(radix)) the bit length of radix^v-1
+	b := (new(big.Int).Sub(pow(v), big.NewInt(1)).BitLen() + 7) / 8
+	d := 4*((b+3)/4) + 4
+
+	P := []byte{1, 2, 1, 0, byte(radix >> 8), byte(radix), 10, byte(u),
+		byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n),
+		byte(t >> 24), byte(t >> 16), byte(t >> 8), byte(t)}
+
+	for round := 0; round < 10; round++ {
+		i, fed := round, B
+		if !encrypt {
+			i, fed = 9-round, A
+		}
+
+		pad := ((-t-b-1)%16 + 16) % 16
+		Q := append([]byte{}, tweak...)
+		Q = append(Q, make([]byte, pad)...)
+		Q = append(Q, byte(i))
+		numBytes := num(fed).Bytes()
+		Q = append(Q, make([]byte, b-len(numBytes))...)
+		Q = append(Q, numBytes...)
+
+		R := prf(append(append([]byte{}, P...), Q...))
+		S := append([]byte{}, R...)
+		for j := 1; len(S) < d; j++ {
+			x := make([]byte, 16)
+			binary.BigEndian.PutUint64(x[8:], uint64(j))
+			for k := range x {
+				x[k] ^= R[k]
+			}
+			block.Encrypt(x, x)
+			S = append(S, x...)
+		}
+		y := new(big.Int).SetBytes(S[:d])
+
+		m := v
+		if i%2 == 0 {
+			m = u
+		}
+
+		c := new(big.Int)
+		if encrypt {
+			c.Add(num(A), y)
+		} else {
+			c.Sub(num(B), y)
+		}
+		c.Mod(c, pow(m))
+
+		if encrypt {
+			A, B = B, str(c, m)
+		} else {
+			A, B = str(c, m), A
+		}
+	}
+
+	return append(A, B...)
+}
+
+// identityAlphabet returns an alphabet whose bytes equal their numeral values.
+func identityAlphabet(radix int) []byte {
+	alphabet := make([]byte, radix)
+	for i := range alphabet {
+		alphabet[i] = byte(i)
+	}
+	return alphabet
+}
+
+// referenceCase is a random input for comparison with referenceFF1.
+type referenceCase struct {
+	key, tweak []byte
+	radix      int
+	plaintext  []byte
+}
+
+func randomReferenceCase(rng *rand.Rand, radices []int, maxLen int) referenceCase {
+	keyLens := []int{16, 24, 32}
+	key := make([]byte, keyLens[rng.Intn(len(keyLens))])
+	rng.Read(key)
+	tweak := make([]byte, rng.Intn(33))
+	rng.Read(tweak)
+
+	radix := radices[rng.Intn(len(radices))]
+	minLen := 1
+	for p := radix; p < feistelMin; p *= radix {
+		minLen++
+	}
+	plaintext := make([]byte, minLen+rng.Intn(maxLen-minLen+1))
+	for i := range plaintext {
+		plaintext[i] = byte(rng.Intn(radix))
+	}
+	return referenceCase{key, tweak, radix, plaintext}
+}
+
+func TestReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	radices := []int{2, 3, 10, 26, 36, 62, 100, 255, 256}
+
+	for iter := 0; iter < 300; iter++ {
+		tc := randomReferenceCase(rng, radices, 70)
+		t.Run(fmt.Sprintf("Case%d", iter), func(t *testing.T) {
+			c, err := NewCipherWithAlphabet(identityAlphabet(tc.radix), 32, tc.key, tc.tweak)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+
+			expected := referenceFF1(tc.key, tc.tweak, tc.radix, tc.plaintext, true)
+			ciphertext, err := c.Encrypt(tc.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, expected) {
+				t.Fatalf("radix %d, length %d: Encrypt = %v, reference = %v", tc.radix, len(tc.plaintext), ciphertext, expected)
+			}
+
+			if back := referenceFF1(tc.key, tc.tweak, tc.radix, ciphertext, false); !reflect.DeepEqual(back, tc.plaintext) {
+				t.Fatalf("reference decrypt = %v, expected %v", back, tc.plaintext)
+			}
+			plaintext, err := c.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(plaintext, tc.plaintext) {
+				t.Fatalf("Decrypt = %v, expected %v", plaintext, tc.plaintext)
+			}
+		})
+	}
+}
+
+// The reference must itself reproduce the NIST samples
+func TestReferenceNIST(t *testing.T) {
+	for idx, testVector := range testVectors {
+		key, tweak := mustHex(testVector.key), mustHex(testVector.tweak)
+		codec := []byte(legacyAlphabet[:testVector.radix])
+		X := make([]uint8, len(testVector.plaintext))
+		for i, ch := range testVector.plaintext {
+			for j, a := range codec {
+				if a == ch {
+					X[i] = uint8(j)
+				}
+			}
+		}
+		Y := referenceFF1(key, tweak, testVector.radix, X, true)
+		ciphertext := make([]byte, len(Y))
+		for i, y := range Y {
+			ciphertext[i] = codec[y]
+		}
+		if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+			t.Fatalf("Sample%d: reference = %s, expected %s", idx+1, ciphertext, testVector.ciphertext)
+		}
+	}
+}
+
+// Long messages need several PRF output blocks per round
+func TestReferenceLong(t *testing.T) {
+	rng := rand.New(rand.NewSource(120))
+
+	for _, radix := range []int{2, 10, 36, 62, 256} {
+		for _, n := range []int{100, 257, 600} {
+			t.Run(fmt.Sprintf("Radix%dLen%d", radix, n), func(t *testing.T) {
+				tc := randomReferenceCase(rng, []int{radix}, n)
+				tc.plaintext = make([]byte, n)
+				for i := range tc.plaintext {
+					tc.plaintext[i] = byte(rng.Intn(radix))
+				}
+
+				c, err := NewCipherWithAlphabet(identityAlphabet(radix), 32, tc.key, tc.tweak)
+				if err != nil {
+					t.Fatalf("Unable to create cipher: %v", err)
+				}
+				ciphertext, err := c.Encrypt(tc.plaintext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if expected := referenceFF1(tc.key, tc.tweak, radix, tc.plaintext, true); !reflect.DeepEqual(ciphertext, expected) {
+					t.Fatalf("Encrypt differs from the reference")
+				}
+			})
+		}
+	}
+}
+
+// naiveExpand is step 6iii as written in the specification, one big-endian
+// 16 byte [j]^16 per block
+func naiveExpand(key, R []byte, maxJ int) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	var out []byte
+	for j := 1; j < maxJ; j++ {
+		var x [16]byte
+		new(big.Int).SetInt64(int64(j)).FillBytes(x[:])
+		for i := range x {
+			x[i] ^= R[i]
+		}
+		block.Encrypt(x[:], x[:])
+		out = append(out, x[:]...)
+	}
+	return out
+}
+
+func FuzzExpand(f *testing.F) {
+	f.Add([]byte("0123456789abcdef"), uint16(2))
+	f.Add([]byte("\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff"), uint16(300))
+	f.Add([]byte{}, uint16(0))
+
+	key := make([]byte, 16)
+	c, err := NewCipher(10, 0, key, nil)
+	if err != nil {
+		f.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	f.Fuzz(func(t *testing.T, r []byte, blocks uint16) {
+		R := append(append([]byte(nil), r...), make([]byte, blockSize)...)[:blockSize]
+		maxJ := 1 + int(blocks)%1024
+
+		xored := make([]byte, (maxJ-1)*blockSize)
+		if err := c.expand(R, make([]byte, blockSize), xored, 1, maxJ); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if expected := naiveExpand(key, R, maxJ); !bytes.Equal(xored, expected) {
+			t.Fatalf("maxJ %d: expand differs from the specification", maxJ)
+		}
+	})
+}
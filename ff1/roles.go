@@ -0,0 +1,81 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+// An Encrypter can only encrypt. See Cipher.EncryptOnly.
+type Encrypter interface {
+	Encrypt(X []byte) ([]byte, error)
+	EncryptWithTweak(X []byte, tweak []byte) ([]byte, error)
+	EncryptInto(dst []byte, X []byte) ([]byte, error)
+}
+
+// A Decrypter can only decrypt. See Cipher.DecryptOnly.
+type Decrypter interface {
+	Decrypt(X []byte) ([]byte, error)
+	DecryptWithTweak(X []byte, tweak []byte) ([]byte, error)
+	DecryptInto(dst []byte, X []byte) ([]byte, error)
+}
+
+// encryptOnly and decryptOnly hide the Cipher in an unexported field, so neither
+// the other direction nor the Cipher can be reached from outside the package,
+// not even with a type assertion.
+type encryptOnly struct {
+	c Cipher
+}
+
+type decryptOnly struct {
+	c Cipher
+}
+
+// EncryptOnly returns the encrypting half of c, for code that must not be able
+// to decrypt. It is safe for concurrent use, as c is, and shares c's key,
+// caches and usage count.
+func (c Cipher) EncryptOnly() Encrypter {
+	return encryptOnly{c}
+}
+
+// DecryptOnly returns the decrypting half of c, in the same way as EncryptOnly.
+func (c Cipher) DecryptOnly() Decrypter {
+	return decryptOnly{c}
+}
+
+func (e encryptOnly) Encrypt(X []byte) ([]byte, error) {
+	return e.c.Encrypt(X)
+}
+
+func (e encryptOnly) EncryptWithTweak(X []byte, tweak []byte) ([]byte, error) {
+	return e.c.EncryptWithTweak(X, tweak)
+}
+
+func (e encryptOnly) EncryptInto(dst []byte, X []byte) ([]byte, error) {
+	return e.c.EncryptInto(dst, X)
+}
+
+func (d decryptOnly) Decrypt(X []byte) ([]byte, error) {
+	return d.c.Decrypt(X)
+}
+
+func (d decryptOnly) DecryptWithTweak(X []byte, tweak []byte) ([]byte, error) {
+	return d.c.DecryptWithTweak(X, tweak)
+}
+
+func (d decryptOnly) DecryptInto(dst []byte, X []byte) ([]byte, error) {
+	return d.c.DecryptInto(dst, X)
+}
@@ -0,0 +1,127 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// These tests are in an external package, so they see the wrappers as users do
+
+func TestEncryptOnlyDecryptOnly(t *testing.T) {
+	c, err := ff1.NewCipher(10, 16, []byte("0123456789abcdef"), []byte("tweak"))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	encrypter, decrypter := c.EncryptOnly(), c.DecryptOnly()
+
+	plaintext := []byte("0123456789")
+	expected, err := c.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Used concurrently, as the Cipher may be
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				ciphertext, err := encrypter.Encrypt(plaintext)
+				if err != nil || !reflect.DeepEqual(ciphertext, expected) {
+					errs <- fmt.Errorf("Encrypt: got %s, %v expected %s", ciphertext, err, expected)
+					return
+				}
+				decrypted, err := decrypter.Decrypt(ciphertext)
+				if err != nil || !reflect.DeepEqual(decrypted, plaintext) {
+					errs <- fmt.Errorf("Decrypt: got %s, %v expected %s", decrypted, err, plaintext)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	other, err := encrypter.EncryptWithTweak(plaintext, nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if decrypted, err := decrypter.DecryptWithTweak(other, nil); err != nil || !reflect.DeepEqual(decrypted, plaintext) {
+		t.Fatalf("DecryptWithTweak: got %s, %v expected %s", decrypted, err, plaintext)
+	}
+	buf := append([]byte(nil), plaintext...)
+	if ciphertext, err := encrypter.EncryptInto(buf, buf); err != nil || !reflect.DeepEqual(ciphertext, expected) {
+		t.Fatalf("EncryptInto: got %s, %v expected %s", ciphertext, err, expected)
+	}
+	if decrypted, err := decrypter.DecryptInto(buf, buf); err != nil || !reflect.DeepEqual(decrypted, plaintext) {
+		t.Fatalf("DecryptInto: got %s, %v expected %s", decrypted, err, plaintext)
+	}
+}
+
+// Neither wrapper can be turned back into the Cipher or into the other direction
+func TestEncryptOnlyAssertions(t *testing.T) {
+	c, err := ff1.NewCipher(10, 16, []byte("0123456789abcdef"), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	roles := []interface{}{c.EncryptOnly(), c.DecryptOnly()}
+	for idx, role := range roles {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, ok := role.(ff1.Cipher); ok {
+				t.Fatalf("%T asserts to ff1.Cipher", role)
+			}
+			if _, ok := role.(*ff1.Cipher); ok {
+				t.Fatalf("%T asserts to *ff1.Cipher", role)
+			}
+			if _, ok := role.(ff1.Encrypter); ok == (idx == 1) {
+				t.Fatalf("%T: assertion to ff1.Encrypter gives %v", role, ok)
+			}
+			if _, ok := role.(ff1.Decrypter); ok == (idx == 0) {
+				t.Fatalf("%T: assertion to ff1.Decrypter gives %v", role, ok)
+			}
+
+			// Nothing reachable through reflection on exported members either
+			v := reflect.ValueOf(role)
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					t.Fatalf("%T has the exported field %s", role, v.Type().Field(i).Name)
+				}
+			}
+			for i := 0; i < v.NumMethod(); i++ {
+				name := v.Type().Method(i).Name
+				if (idx == 0) != (name[:7] == "Encrypt") {
+					t.Fatalf("%T has the method %s", role, name)
+				}
+			}
+		})
+	}
+}
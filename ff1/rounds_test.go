@@ -0,0 +1,277 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// withBigRounds returns a copy of c that runs the big.Int rounds for messages of
+// n numerals even where the uint64 rounds would be used
+func withBigRounds(tb testing.TB, c Cipher, n int) Cipher {
+	e, err := c.params(uint32(n))
+	if err != nil {
+		tb.Fatalf("params(%d): %v", n, err)
+	}
+	forced := *e
+	forced.fits64 = false
+
+	c.cache = newLengthCache()
+	c.cache.put(uint32(n), &forced)
+	return c
+}
+
+// checkRounds64 compares the uint64 rounds with the big.Int rounds and the reference
+// implementation for one message
+func checkRounds64(t *testing.T, key, tweak []byte, radix int, X []uint8) {
+	c, err := NewCipherWithAlphabet(identityAlphabet(radix), 32, key, tweak)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	big := withBigRounds(t, c, len(X))
+
+	ciphertext, err := c.Encrypt(X)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	bigCiphertext, err := big.Encrypt(X)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !reflect.DeepEqual(ciphertext, bigCiphertext) {
+		t.Fatalf("radix %d, length %d: uint64 rounds = %v, big.Int rounds = %v", radix, len(X), ciphertext, bigCiphertext)
+	}
+	if expected := referenceFF1(key, tweak, radix, X, true); !reflect.DeepEqual(ciphertext, expected) {
+		t.Fatalf("radix %d, length %d: Encrypt = %v, reference = %v", radix, len(X), ciphertext, expected)
+	}
+
+	plaintext, err := c.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	bigPlaintext, err := big.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !reflect.DeepEqual(plaintext, X) || !reflect.DeepEqual(bigPlaintext, X) {
+		t.Fatalf("radix %d, length %d: Decrypt = %v, big.Int Decrypt = %v, expected %v", radix, len(X), plaintext, bigPlaintext, X)
+	}
+}
+
+// Lengths around the largest halves that fit in 64 bits, such as 19 digits in radix 10
+// and 12 in radix 36, plus radices where radix^v is exactly 2^64
+var rounds64Boundaries = []struct {
+	radix   int
+	lengths []int
+}{
+	{2, []int{126, 127, 128, 129}},
+	{10, []int{36, 37, 38, 39, 40}},
+	{16, []int{30, 31, 32, 33}},
+	{36, []int{22, 23, 24, 25, 26}},
+	{62, []int{20, 21, 22, 23}},
+	{256, []int{14, 15, 16, 17}},
+}
+
+func TestRounds64Boundary(t *testing.T) {
+	rng := rand.New(rand.NewSource(116))
+
+	for _, boundary := range rounds64Boundaries {
+		for _, n := range boundary.lengths {
+			t.Run(fmt.Sprintf("Radix%dLen%d", boundary.radix, n), func(t *testing.T) {
+				for iter := 0; iter < 20; iter++ {
+					key := make([]byte, 16+8*rng.Intn(3))
+					rng.Read(key)
+					tweak := make([]byte, rng.Intn(33))
+					rng.Read(tweak)
+
+					X := make([]uint8, n)
+					for i := range X {
+						X[i] = uint8(rng.Intn(boundary.radix))
+					}
+					// The extremes of the domain
+					switch iter {
+					case 0:
+						X = make([]uint8, n)
+					case 1:
+						for i := range X {
+							X[i] = uint8(boundary.radix - 1)
+						}
+					}
+
+					checkRounds64(t, key, tweak, boundary.radix, X)
+				}
+			})
+		}
+	}
+}
+
+func FuzzRounds64(f *testing.F) {
+	f.Add([]byte("2B7E151628AED2A6"), []byte{}, uint8(1), uint8(38), []byte("0123456789"))
+	f.Add([]byte("2B7E151628AED2A6ABF71588"), []byte("tweak"), uint8(3), uint8(24), []byte{0xff})
+	f.Add([]byte{}, []byte{0x39}, uint8(5), uint8(16), []byte{0, 1, 2})
+
+	radices := []int{2, 10, 16, 36, 62, 256}
+	f.Fuzz(func(t *testing.T, key, tweak []byte, radixIdx, length uint8, digits []byte) {
+		// Any key is padded or cut to an AES-128 key, the tweak to maxTLen
+		key = append(append([]byte(nil), key...), make([]byte, 16)...)[:16]
+		if len(tweak) > 32 {
+			tweak = tweak[:32]
+		}
+		radix := radices[int(radixIdx)%len(radices)]
+
+		// Lengths up to a few numerals past the 64 bit boundary of each radix
+		minLen := 2
+		for p := radix * radix; p < feistelMin; p *= radix {
+			minLen++
+		}
+		n := minLen + int(length)%130
+		X := make([]uint8, n)
+		for i := range X {
+			if len(digits) > 0 {
+				X[i] = uint8(int(digits[i%len(digits)]) % radix)
+			}
+		}
+
+		checkRounds64(t, key, tweak, radix, X)
+	})
+}
+
+// Q is laid out once per call: the rounds only write the round number and the numeral
+// into its last b+1 bytes, and leave the tweak and padding before them as they were
+func TestQFixedPart(t *testing.T) {
+	key := mustHex(testVectors[0].key)
+	tweak := []byte("fifteen bytes!!")
+
+	tests := []struct {
+		radix int
+		n     int
+		big   bool
+	}{
+		{10, 10, false},
+		{10, 10, true},
+		{36, 19, false},
+		{36, 133, true},
+		{256, 40, true},
+	}
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := NewCipherWithAlphabet(identityAlphabet(test.radix), 16, key, tweak)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			params, err := c.params(uint32(test.n))
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !test.big && !params.fits64 {
+				t.Fatalf("radix %d, length %d: too long for the uint64 rounds", test.radix, test.n)
+			}
+
+			X := make([]uint8, test.n)
+			for i := range X {
+				X[i] = uint8((i*5 + 3) % test.radix)
+			}
+			expected, err := c.Encrypt(X)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			b := params.b
+			lenQ := len(tweak) + b + 1 + padLen(len(tweak), b)
+			qOff := (lenQ - b - 1) / blockSize * blockSize
+			Q := make([]byte, lenQ)
+			copy(Q, tweak)
+			fixed := append([]byte(nil), Q[:lenQ-b-1]...)
+			Y := make([]byte, (params.maxJ+1)*blockSize)
+			state := params.state
+
+			out := append([]uint8(nil), X...)
+			A, B := out[:params.u], out[params.u:]
+			if test.big {
+				sc := getScratch(lenQ, int(ceilDiv(uint64(params.d), wordBytes))+2)
+				defer sc.release(false)
+				err = c.roundsBig(params, sc, Q, qOff, Y, &state, A, B, true)
+			} else {
+				err = c.rounds64(params, Q, qOff, Y, &state, A, B, true)
+			}
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(out, expected) {
+				t.Fatalf("rounds = %v, Encrypt = %v", out, expected)
+			}
+
+			if !reflect.DeepEqual(Q[:lenQ-b-1], fixed) {
+				t.Fatalf("the tweak and padding of Q changed in the rounds")
+			}
+			if Q[lenQ-b-1] != numRounds-1 {
+				t.Fatalf("round number %d, expected %d", Q[lenQ-b-1], numRounds-1)
+			}
+			// The last round feeds the B that becomes the final A
+			numA, err := fpeUtils.Num(A, uint64(test.radix))
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(Q[lenQ-b:], numA.FillBytes(make([]byte, b))) {
+				t.Fatalf("numeral in Q = %x, expected the final A %x", Q[lenQ-b:], numA.FillBytes(make([]byte, b)))
+			}
+		})
+	}
+}
+
+// BenchmarkRounds compares the uint64 and big.Int rounds on the NIST samples that use
+// the uint64 rounds. Neither allocates in the rounds, or anywhere else with EncryptInto.
+func BenchmarkRounds(b *testing.B) {
+	for idx, testVector := range testVectors {
+		c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak))
+		if err != nil {
+			b.Fatalf("Unable to create cipher: %v", err)
+		}
+		e, err := c.params(uint32(len(testVector.plaintext)))
+		if err != nil {
+			b.Fatalf("%v", err)
+		}
+		if !e.fits64 {
+			continue
+		}
+
+		variants := []struct {
+			name string
+			c    Cipher
+		}{
+			{"Uint64", c},
+			{"BigInt", withBigRounds(b, c, len(testVector.plaintext))},
+		}
+		dst := make([]byte, len(testVector.plaintext))
+		for _, variant := range variants {
+			b.Run(fmt.Sprintf("Sample%d/%s", idx+1, variant.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					variant.c.EncryptInto(dst, testVector.plaintext)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// A SampleOption configures AlphabetFromSamples.
+type SampleOption func(*sampleConfig)
+
+type sampleConfig struct {
+	sorted bool
+}
+
+// SortedAlphabet makes AlphabetFromSamples return the bytes of the alphabet in
+// ascending order, rather than in the order they first appear in the samples.
+func SortedAlphabet() SampleOption {
+	return func(c *sampleConfig) {
+		c.sorted = true
+	}
+}
+
+// CoverageReport describes the samples an alphabet was inferred from.
+type CoverageReport struct {
+	// Radix is the number of distinct bytes in the samples.
+	Radix int
+
+	// Counts holds how often each byte value occurs in the samples.
+	Counts [256]int
+
+	// Samples is the number of samples, ShortestSample and LongestSample
+	// their smallest and largest length.
+	Samples        int
+	ShortestSample int
+	LongestSample  int
+
+	// MinLen is the shortest message a Cipher with the inferred alphabet accepts,
+	// and TooShort the number of samples shorter than that, which it would reject.
+	MinLen   int
+	TooShort int
+
+	// FullByteRange is set if all 256 byte values occur, as is typical of binary
+	// data. Radix 256 is allowed, but the alphabet then constrains nothing.
+	FullByteRange bool
+
+	// Warnings describes the findings above that need attention, empty if none do.
+	Warnings []string
+}
+
+// AlphabetFromSamples collects the distinct bytes of samples into an alphabet for
+// NewCipherWithAlphabet, in the order they first appear unless SortedAlphabet is
+// given, and reports on how well the samples fit a Cipher with that alphabet.
+//
+// It is an error if the samples contain fewer than two distinct bytes, since FF1
+// needs a radix of at least 2. A byte alphabet never has more than the 256 symbols
+// FF1 allows, so a sample of any bytes gives a usable alphabet.
+func AlphabetFromSamples(samples [][]byte, opts ...SampleOption) (alphabet []byte, report CoverageReport, err error) {
+	var cfg sampleConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var seen [256]bool
+	report.Samples = len(samples)
+	for i, sample := range samples {
+		if i == 0 || len(sample) < report.ShortestSample {
+			report.ShortestSample = len(sample)
+		}
+		if len(sample) > report.LongestSample {
+			report.LongestSample = len(sample)
+		}
+		for _, b := range sample {
+			report.Counts[b]++
+			if !seen[b] {
+				seen[b] = true
+				alphabet = append(alphabet, b)
+			}
+		}
+	}
+	if cfg.sorted {
+		sort.Slice(alphabet, func(i, j int) bool { return alphabet[i] < alphabet[j] })
+	}
+
+	report.Radix = len(alphabet)
+	if report.Radix < 2 {
+		return nil, report, fmt.Errorf("samples contain %d distinct bytes, at least 2 are needed", report.Radix)
+	}
+	report.FullByteRange = report.Radix == 256
+
+	minLen, err := fpeUtils.MinLengthForDomain(uint64(report.Radix), big.NewInt(feistelMin))
+	if err != nil {
+		return nil, report, err
+	}
+	report.MinLen = minLen
+	for _, sample := range samples {
+		if len(sample) < minLen {
+			report.TooShort++
+		}
+	}
+
+	if report.TooShort > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"%d of %d samples are shorter than %d bytes, the minimum length for radix %d; the shortest has %d",
+			report.TooShort, report.Samples, minLen, report.Radix, report.ShortestSample))
+	}
+	if report.FullByteRange {
+		report.Warnings = append(report.Warnings,
+			"samples contain all 256 byte values, the alphabet does not restrict the input")
+	}
+	return alphabet, report, nil
+}
+
+// AlphabetDiff compares an inferred alphabet with a configured one. missing holds
+// the bytes of inferred that configured lacks, which a Cipher with the configured
+// alphabet would reject, and unused the bytes of configured that inferred lacks.
+// Both are in ascending order.
+func AlphabetDiff(inferred, configured []byte) (missing, unused []byte) {
+	var in, conf [256]bool
+	for _, b := range inferred {
+		in[b] = true
+	}
+	for _, b := range configured {
+		conf[b] = true
+	}
+	for b := 0; b < 256; b++ {
+		switch {
+		case in[b] && !conf[b]:
+			missing = append(missing, byte(b))
+		case conf[b] && !in[b]:
+			unused = append(unused, byte(b))
+		}
+	}
+	return missing, unused
+}
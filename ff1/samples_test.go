@@ -0,0 +1,159 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var testSamples = []struct {
+	samples  [][]byte
+	sorted   bool
+	alphabet []byte
+	minLen   int
+	tooShort int
+}{
+	{
+		[][]byte{[]byte("90210"), []byte("10001"), []byte("33139"), []byte("12")},
+		false,
+		[]byte("90213"),
+		3,
+		1,
+	},
+	{
+		[][]byte{[]byte("90210"), []byte("10001"), []byte("33139"), []byte("12")},
+		true,
+		[]byte("01239"),
+		3,
+		1,
+	},
+	{
+		[][]byte{[]byte("AB12CD34"), []byte("zz99yy88"), []byte("q1"), []byte("7")},
+		false,
+		[]byte("AB12CD34z9y8q7"),
+		2,
+		1,
+	},
+	{
+		[][]byte{[]byte("AB12CD34"), []byte("zz99yy88"), []byte("q1"), []byte("7")},
+		true,
+		[]byte("1234789ABCDqyz"),
+		2,
+		1,
+	},
+}
+
+func TestAlphabetFromSamples(t *testing.T) {
+	for idx, testSample := range testSamples {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			var opts []SampleOption
+			if testSample.sorted {
+				opts = append(opts, SortedAlphabet())
+			}
+			alphabet, report, err := AlphabetFromSamples(testSample.samples, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(alphabet, testSample.alphabet) {
+				t.Fatalf("alphabet %q, want %q", alphabet, testSample.alphabet)
+			}
+			if report.Radix != len(testSample.alphabet) || report.MinLen != testSample.minLen || report.TooShort != testSample.tooShort {
+				t.Fatalf("radix %d, minLen %d, tooShort %d, want %d, %d, %d",
+					report.Radix, report.MinLen, report.TooShort, len(testSample.alphabet), testSample.minLen, testSample.tooShort)
+			}
+			if len(report.Warnings) != 1 {
+				t.Fatalf("warnings %q, want one about the short samples", report.Warnings)
+			}
+
+			// The alphabet is accepted by NewCipherWithAlphabet, and so are the
+			// samples that are long enough
+			c, err := NewCipherWithAlphabet(alphabet, 0, make([]byte, 16), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.MinLen() != report.MinLen {
+				t.Fatalf("MinLen() = %d, report says %d", c.MinLen(), report.MinLen)
+			}
+			for _, sample := range testSample.samples {
+				_, err := c.Encrypt(sample)
+				if tooShort := len(sample) < report.MinLen; (err != nil) != tooShort {
+					t.Fatalf("Encrypt(%q): %v", sample, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAlphabetFromSamplesBinary(t *testing.T) {
+	binary := make([]byte, 512)
+	for i := range binary {
+		binary[i] = byte(255 - i)
+	}
+	alphabet, report, err := AlphabetFromSamples([][]byte{binary[:3], binary}, SortedAlphabet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alphabet) != 256 || alphabet[0] != 0 || alphabet[255] != 255 {
+		t.Fatalf("alphabet of %d bytes from %d to %d, want all 256 in order", len(alphabet), alphabet[0], alphabet[len(alphabet)-1])
+	}
+	if !report.FullByteRange || report.MinLen != 1 || report.TooShort != 0 {
+		t.Fatalf("report %+v", report)
+	}
+	if report.Counts[0] != 2 || report.Counts[255] != 3 {
+		t.Fatalf("counts of 0x00 and 0xff are %d and %d, want 2 and 3", report.Counts[0], report.Counts[255])
+	}
+	if report.ShortestSample != 3 || report.LongestSample != 512 {
+		t.Fatalf("sample lengths from %d to %d, want 3 to 512", report.ShortestSample, report.LongestSample)
+	}
+	if len(report.Warnings) != 1 {
+		t.Fatalf("warnings %q, want one about the byte range", report.Warnings)
+	}
+
+	noWarnings := [][]byte{[]byte("0110100110"), []byte("1001011001")}
+	if _, report, err := AlphabetFromSamples(noWarnings); err != nil || len(report.Warnings) != 0 {
+		t.Fatalf("warnings %q, err %v", report.Warnings, err)
+	}
+}
+
+func TestAlphabetFromSamplesError(t *testing.T) {
+	for _, samples := range [][][]byte{nil, {}, {[]byte("")}, {[]byte("aaaa"), []byte("aa")}} {
+		if _, _, err := AlphabetFromSamples(samples); err == nil {
+			t.Fatalf("samples %q accepted", samples)
+		}
+	}
+}
+
+func TestAlphabetDiff(t *testing.T) {
+	missing, unused := AlphabetDiff([]byte("0123456789-"), []byte("9876543210abcdef"))
+	if !reflect.DeepEqual(missing, []byte("-")) {
+		t.Fatalf("missing %q, want %q", missing, "-")
+	}
+	if !reflect.DeepEqual(unused, []byte("abcdef")) {
+		t.Fatalf("unused %q, want %q", unused, "abcdef")
+	}
+
+	missing, unused = AlphabetDiff([]byte("abc"), []byte("cba"))
+	if missing != nil || unused != nil {
+		t.Fatalf("missing %q, unused %q, want none", missing, unused)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"math/big"
+	"math/bits"
+	"runtime"
+	"sync"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// Scratch buffers are pooled by size class: class k holds buffers of 1<<k bytes.
+// Calls needing more than 1<<maxScratchClass bytes allocate and are not pooled,
+// so one very long message does not keep a large buffer alive.
+const (
+	minScratchClass = 6
+	maxScratchClass = 16
+
+	// Number of big.Int temporaries of the Feistel rounds
+	scratchNums = 6
+)
+
+var scratchPools [maxScratchClass + 1]sync.Pool
+
+// scratch holds the per call working memory of crypt. It is never shared between
+// calls that run at the same time and nothing in it is returned to the caller.
+type scratch struct {
+	// The numeral string, split into the A and B halves
+	numerals []uint8
+
+	// Q, followed by the PRF output blocks
+	buf []byte
+
+	// The big.Int temporaries of the Feistel rounds and their shared word storage
+	nums  [scratchNums]big.Int
+	words []big.Word
+
+	// Single word temporaries for the numeral conversions
+	small [2]big.Int
+}
+
+// scratchClass returns the size class for a buffer of size bytes and
+// whether buffers of that size are pooled.
+func scratchClass(size int) (int, bool) {
+	class := bits.Len(uint(size - 1))
+	if class < minScratchClass {
+		class = minScratchClass
+	}
+	return class, class <= maxScratchClass
+}
+
+// getScratch returns a scratch from the pool, prepared for bufLen and wordsPer.
+func getScratch(bufLen, wordsPer int) *scratch {
+	var s *scratch
+	class, pooled := scratchClass(bufLen)
+	if pooled {
+		s, _ = scratchPools[class].Get().(*scratch)
+	}
+	if s == nil {
+		s = new(scratch)
+	}
+	s.prepare(bufLen, wordsPer)
+	return s
+}
+
+// prepare makes buf bufLen bytes long and all zero, and gives the big.Ints room
+// for wordsPer words each without growing. Buffers are only reallocated if they
+// are too small, rounded up to the size class so that nearby sizes fit as well.
+func (s *scratch) prepare(bufLen, wordsPer int) {
+	if cap(s.buf) < bufLen {
+		size := bufLen
+		if class, pooled := scratchClass(bufLen); pooled {
+			size = 1 << class
+		}
+		s.buf = make([]byte, size)
+	}
+
+	s.buf = s.buf[:bufLen]
+	// Pooled buffers may hold values from an earlier call, which would end up in
+	// the zero padding of Q
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+
+	if cap(s.words) < len(s.nums)*wordsPer {
+		s.words = make([]big.Word, len(s.nums)*wordsPer)
+	}
+	for k := range s.nums {
+		s.nums[k].SetBits(s.words[k*wordsPer : k*wordsPer : (k+1)*wordsPer])
+	}
+}
+
+// wipe overwrites everything in s that was derived from the message.
+func (s *scratch) wipe() {
+	fpeUtils.ZeroNumerals(s.numerals)
+	fpeUtils.ZeroBytes(s.buf)
+	for k := range s.nums {
+		fpeUtils.ZeroBig(&s.nums[k])
+	}
+	for k := range s.small {
+		fpeUtils.ZeroBig(&s.small[k])
+	}
+	zeroWords(s.words[:cap(s.words)])
+}
+
+// release returns s to its pool, after wiping it if zeroize is set.
+// s must not be used afterwards.
+func (s *scratch) release(zeroize bool) {
+	if zeroize {
+		s.wipe()
+	}
+
+	class, pooled := scratchClass(cap(s.buf))
+	if pooled {
+		scratchPools[class].Put(s)
+	}
+}
+
+// zeroWords overwrites the word storage shared by the big.Ints of a scratch.
+func zeroWords(words []big.Word) {
+	for i := range words {
+		words[i] = 0
+	}
+	runtime.KeepAlive(words)
+}
@@ -0,0 +1,281 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestScratchClass(t *testing.T) {
+	tests := []struct {
+		size   int
+		class  int
+		pooled bool
+	}{
+		{1, minScratchClass, true},
+		{1 << minScratchClass, minScratchClass, true},
+		{1<<minScratchClass + 1, minScratchClass + 1, true},
+		{1000, 10, true},
+		{1 << maxScratchClass, maxScratchClass, true},
+		{1<<maxScratchClass + 1, maxScratchClass + 1, false},
+	}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			class, pooled := scratchClass(test.size)
+			if class != test.class || pooled != test.pooled {
+				t.Fatalf("scratchClass(%d) = %d, %v expected %d, %v", test.size, class, pooled, test.class, test.pooled)
+			}
+		})
+	}
+}
+
+// Many goroutines share the pools with messages of different lengths, default and
+// explicit tweaks, and with and without zeroize; run with -race
+func TestScratchConcurrent(t *testing.T) {
+	const radix = 36
+	rng := rand.New(rand.NewSource(115))
+	key := make([]byte, 32)
+	rng.Read(key)
+
+	type sample struct {
+		tweak      []byte
+		plaintext  []byte
+		ciphertext []byte
+	}
+	var samples []sample
+	// Lengths are chosen so Q and the PRF output land in several size classes
+	for _, n := range []int{2, 9, 40, 133, 400, 1500} {
+		for _, tweakLen := range []int{0, 11} {
+			tweak := make([]byte, tweakLen)
+			rng.Read(tweak)
+			plaintext := make([]byte, n)
+			for i := range plaintext {
+				plaintext[i] = byte(rng.Intn(radix))
+			}
+			samples = append(samples, sample{tweak, plaintext, referenceFF1(key, tweak, radix, plaintext, true)})
+		}
+	}
+
+	var ciphers []Cipher
+	for _, opts := range [][]Option{nil, {WithZeroize()}} {
+		c, err := NewCipherWithAlphabet(identityAlphabet(radix), 16, key, nil, opts...)
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		ciphers = append(ciphers, c)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			c := ciphers[g%len(ciphers)]
+			for iter := 0; iter < 30; iter++ {
+				s := samples[(g*7+iter)%len(samples)]
+
+				ciphertext, err := c.EncryptWithTweak(s.plaintext, s.tweak)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(ciphertext, s.ciphertext) {
+					errs <- fmt.Errorf("length %d: Encrypt does not match the reference", len(s.plaintext))
+					return
+				}
+
+				plaintext, err := c.DecryptWithTweak(ciphertext, s.tweak)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(plaintext, s.plaintext) {
+					errs <- fmt.Errorf("length %d: Decrypt does not round trip", len(s.plaintext))
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkScratch shows the steady state allocations of repeated calls with the
+// same length, once the pool holds a scratch of the right size class
+func BenchmarkScratch(b *testing.B) {
+	key := make([]byte, 16)
+	c, err := NewCipher(36, 16, key, nil)
+	if err != nil {
+		b.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	for _, n := range []int{10, 40, 133, 400} {
+		plaintext := make([]byte, n)
+		for i := range plaintext {
+			plaintext[i] = "0123456789abcdefghijklmnopqrstuvwxyz"[i%36]
+		}
+		b.Run(fmt.Sprintf("Len%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.Encrypt(plaintext)
+			}
+		})
+	}
+}
+
+// scratchDirt returns the name of the first part of s that is not zero, up to its
+// capacity, or "" if s is clean.
+func scratchDirt(s *scratch) string {
+	for _, v := range s.numerals[:cap(s.numerals)] {
+		if v != 0 {
+			return "numerals"
+		}
+	}
+	for _, v := range s.buf[:cap(s.buf)] {
+		if v != 0 {
+			return "buf"
+		}
+	}
+	for _, v := range s.words[:cap(s.words)] {
+		if v != 0 {
+			return "words"
+		}
+	}
+	for k := range s.nums {
+		for _, v := range s.nums[k].Bits()[:cap(s.nums[k].Bits())] {
+			if v != 0 {
+				return fmt.Sprintf("nums[%d]", k)
+			}
+		}
+	}
+	for k := range s.small {
+		for _, v := range s.small[k].Bits()[:cap(s.small[k].Bits())] {
+			if v != 0 {
+				return fmt.Sprintf("small[%d]", k)
+			}
+		}
+	}
+	return ""
+}
+
+// drainScratchPools empties the pools, so a later Get only finds scratch put back
+// since.
+func drainScratchPools() {
+	for class := range scratchPools {
+		for scratchPools[class].Get() != nil {
+		}
+	}
+}
+
+// pooledScratch returns the scratch found in the pools, putting them back.
+func pooledScratch() []*scratch {
+	var found []*scratch
+	for class := range scratchPools {
+		if s, ok := scratchPools[class].Get().(*scratch); ok {
+			found = append(found, s)
+			scratchPools[class].Put(s)
+		}
+	}
+	return found
+}
+
+// With WithZeroize, nothing derived from the message is left in the pooled scratch
+// or in the scratch of an Encryptor after a call. The uint64 and the math/big round
+// paths are both covered. Without it, the same check finds the leftovers.
+func TestZeroizeScratch(t *testing.T) {
+	const radix = 36
+	rng := rand.New(rand.NewSource(127))
+	key := make([]byte, 16)
+	rng.Read(key)
+
+	for idx, n := range []int{9, 40, 133} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			plaintext := make([]byte, n)
+			for i := range plaintext {
+				plaintext[i] = byte(1 + rng.Intn(radix-1))
+			}
+			ciphertext := referenceFF1(key, nil, radix, plaintext, true)
+
+			for _, zeroize := range []bool{false, true} {
+				var opts []Option
+				if zeroize {
+					opts = append(opts, WithZeroize())
+				}
+				c, err := NewCipherWithAlphabet(identityAlphabet(radix), 0, key, nil, opts...)
+				if err != nil {
+					t.Fatalf("Unable to create cipher: %v", err)
+				}
+
+				for _, encrypt := range []bool{true, false} {
+					in, out := plaintext, ciphertext
+					if !encrypt {
+						in, out = ciphertext, plaintext
+					}
+
+					// The pool may drop what is put back, notably under -race, so
+					// try a few times before giving up
+					var found []*scratch
+					for attempt := 0; attempt < 20 && len(found) == 0; attempt++ {
+						drainScratchPools()
+						got, err := c.crypt(context.Background(), nil, nil, in, c.tweak, encrypt)
+						if err != nil {
+							t.Fatalf("%v", err)
+						}
+						if !reflect.DeepEqual(got, out) {
+							t.Fatalf("crypt(encrypt=%v): got %v expected %v", encrypt, got, out)
+						}
+						found = pooledScratch()
+					}
+					if len(found) == 0 {
+						t.Fatalf("no scratch was put back into the pools")
+					}
+					for _, s := range found {
+						if dirt := scratchDirt(s); zeroize && dirt != "" {
+							t.Fatalf("encrypt=%v: pooled scratch %s not wiped", encrypt, dirt)
+						} else if !zeroize && dirt == "" {
+							t.Fatalf("encrypt=%v: pooled scratch is clean without WithZeroize", encrypt)
+						}
+					}
+
+					e := c.NewEncryptor()
+					if _, err := e.c.crypt(context.Background(), &e.sc, nil, in, e.c.tweak, encrypt); err != nil {
+						t.Fatalf("%v", err)
+					}
+					if dirt := scratchDirt(&e.sc); zeroize && dirt != "" {
+						t.Fatalf("encrypt=%v: Encryptor scratch %s not wiped", encrypt, dirt)
+					} else if !zeroize && dirt == "" {
+						t.Fatalf("encrypt=%v: Encryptor scratch is clean without WithZeroize", encrypt)
+					}
+				}
+			}
+		})
+	}
+}
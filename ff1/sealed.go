@@ -0,0 +1,188 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// AlgorithmFF1 is the AlgorithmID of values sealed by this package.
+const AlgorithmFF1 = 1
+
+// sealVersion is the version of the binary encoding of a SealedValue. Later
+// versions may append fields after the ciphertext, which this version skips.
+const sealVersion = 1
+
+// sealHeaderLen is the length of the fixed part of the binary encoding: version,
+// algorithm, alphabet fingerprint and tweak hint.
+const sealHeaderLen = 1 + 1 + len(Fingerprint{}) + 4
+
+var (
+	// ErrUnknownAlgorithm is returned by Open for a SealedValue of another algorithm
+	ErrUnknownAlgorithm = errors.New("sealed value has an unknown algorithm")
+
+	// ErrSealedValueInvalid is returned, wrapped with the reason, by UnmarshalBinary
+	// for data that is not a SealedValue
+	ErrSealedValueInvalid = errors.New("invalid sealed value")
+)
+
+// A Fingerprint identifies an alphabet: the first 16 bytes of a SHA-256 hash of
+// its bytes in order. Alphabets with the same bytes in another order have
+// different fingerprints, as they map to different numerals.
+type Fingerprint [16]byte
+
+// AlphabetFingerprint returns the fingerprint of the alphabet of the Cipher, the
+// zero Fingerprint for a Cipher that was not made by a constructor.
+func (c Cipher) AlphabetFingerprint() Fingerprint {
+	var fp Fingerprint
+	if c.codec == nil {
+		return fp
+	}
+	h := sha256.New()
+	h.Write([]byte("go-fpe-bytes alphabet\x00"))
+	h.Write(c.codec.Alphabet())
+	copy(fp[:], h.Sum(nil))
+	return fp
+}
+
+// tweakHint returns the first 4 bytes of a SHA-256 hash of tweak.
+func tweakHint(tweak []byte) uint32 {
+	h := sha256.New()
+	h.Write([]byte("go-fpe-bytes tweak\x00"))
+	h.Write(tweak)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+// A SealedValue is a ciphertext together with what is needed to tell which cipher
+// it belongs to, for storing values long after the configuration that produced
+// them is forgotten. It implements encoding.BinaryMarshaler, which encoding/gob
+// uses for it.
+type SealedValue struct {
+	AlgorithmID         uint8
+	AlphabetFingerprint Fingerprint
+
+	// TweakHint is a 4-byte hash of the tweak, enough to notice a wrong tweak.
+	// It hides only tweaks of high entropy: one of few possible values, such as
+	// a date or a small ID, can be found by hashing each candidate.
+	TweakHint  uint32
+	Ciphertext []byte
+}
+
+// A FingerprintMismatchError is returned by Open if the cipher from the resolver
+// does not match the SealedValue. Field is "alphabet" or "tweak".
+type FingerprintMismatchError struct {
+	Field string
+}
+
+func (e *FingerprintMismatchError) Error() string {
+	return fmt.Sprintf("sealed value has another %s than the cipher", e.Field)
+}
+
+// A Resolver returns the cipher for an alphabet fingerprint, typically from a
+// table of the ciphers an application has had over time.
+type Resolver func(fp Fingerprint) (*Cipher, error)
+
+// Seal encrypts plaintext with the default tweak of c and records the alphabet and
+// the tweak in the SealedValue.
+func Seal(c *Cipher, plaintext []byte) (SealedValue, error) {
+	if c == nil || c.codec == nil {
+		return SealedValue{}, ErrNotInitialized
+	}
+	Y, err := c.Encrypt(plaintext)
+	if err != nil {
+		return SealedValue{}, err
+	}
+	return SealedValue{
+		AlgorithmID:         AlgorithmFF1,
+		AlphabetFingerprint: c.AlphabetFingerprint(),
+		TweakHint:           tweakHint(c.tweak),
+		Ciphertext:          Y,
+	}, nil
+}
+
+// Open decrypts sealed with the cipher resolve returns for its alphabet
+// fingerprint. If that cipher has another alphabet or default tweak than the one
+// that sealed the value, Open returns a *FingerprintMismatchError instead of
+// decrypting it into a wrong value. A cipher with the same alphabet and tweak but
+// another key cannot be detected this way, use EncryptWithTag for that.
+func Open(resolve Resolver, sealed SealedValue) ([]byte, error) {
+	if sealed.AlgorithmID != AlgorithmFF1 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownAlgorithm, sealed.AlgorithmID)
+	}
+	c, err := resolve(sealed.AlphabetFingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || c.codec == nil {
+		return nil, ErrNotInitialized
+	}
+	if c.AlphabetFingerprint() != sealed.AlphabetFingerprint {
+		return nil, &FingerprintMismatchError{Field: "alphabet"}
+	}
+	if tweakHint(c.tweak) != sealed.TweakHint {
+		return nil, &FingerprintMismatchError{Field: "tweak"}
+	}
+	return c.Decrypt(sealed.Ciphertext)
+}
+
+// MarshalBinary encodes s as a version byte, the algorithm, the fingerprint, the
+// tweak hint in big-endian order, and the ciphertext prefixed with its length as
+// a uvarint.
+func (s SealedValue) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, sealHeaderLen, sealHeaderLen+binary.MaxVarintLen64+len(s.Ciphertext))
+	buf[0] = sealVersion
+	buf[1] = s.AlgorithmID
+	copy(buf[2:], s.AlphabetFingerprint[:])
+	binary.BigEndian.PutUint32(buf[2+len(s.AlphabetFingerprint):], s.TweakHint)
+	var n [binary.MaxVarintLen64]byte
+	buf = append(buf, n[:binary.PutUvarint(n[:], uint64(len(s.Ciphertext)))]...)
+	return append(buf, s.Ciphertext...), nil
+}
+
+// UnmarshalBinary decodes the encoding of MarshalBinary. It accepts later versions
+// as well and skips the fields they add after the ciphertext.
+func (s *SealedValue) UnmarshalBinary(data []byte) error {
+	if len(data) < sealHeaderLen {
+		return fmt.Errorf("%w: %d bytes", ErrSealedValueInvalid, len(data))
+	}
+	version := data[0]
+	if version < 1 {
+		return fmt.Errorf("%w: version %d", ErrSealedValueInvalid, version)
+	}
+	r := bytes.NewReader(data[sealHeaderLen:])
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return fmt.Errorf("%w: truncated ciphertext", ErrSealedValueInvalid)
+	}
+	if version == sealVersion && uint64(r.Len()) != n {
+		return fmt.Errorf("%w: %d bytes after the ciphertext", ErrSealedValueInvalid, uint64(r.Len())-n)
+	}
+	start := len(data) - r.Len()
+
+	s.AlgorithmID = data[1]
+	copy(s.AlphabetFingerprint[:], data[2:])
+	s.TweakHint = binary.BigEndian.Uint32(data[2+len(s.AlphabetFingerprint):])
+	s.Ciphertext = append([]byte{}, data[start:start+int(n)]...)
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSealedValue(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	hex, err := NewCipher(16, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	ciphers := map[Fingerprint]*Cipher{c.AlphabetFingerprint(): &c, hex.AlphabetFingerprint(): &hex}
+	resolve := func(fp Fingerprint) (*Cipher, error) {
+		if c, ok := ciphers[fp]; ok {
+			return c, nil
+		}
+		return nil, errors.New("unknown alphabet")
+	}
+
+	// Sealed values survive gob as part of some job state
+	type jobState struct {
+		Step   int
+		Values []SealedValue
+	}
+	var state jobState
+	for _, v := range []struct {
+		c         *Cipher
+		plaintext string
+	}{{&c, "0123456789"}, {&hex, "00ff00ff"}} {
+		sealed, err := Seal(v.c, []byte(v.plaintext))
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+		state.Values = append(state.Values, sealed)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state); err != nil {
+		t.Fatalf("gob: %v", err)
+	}
+	var back jobState
+	if err := gob.NewDecoder(&buf).Decode(&back); err != nil {
+		t.Fatalf("gob: %v", err)
+	}
+	if !reflect.DeepEqual(back, state) {
+		t.Fatalf("gob gave %+v, want %+v", back, state)
+	}
+
+	for i, want := range []string{"0123456789", "00ff00ff"} {
+		got, err := Open(resolve, back.Values[i])
+		if err != nil || string(got) != want {
+			t.Fatalf("Open: got %q, %v, want %q", got, err, want)
+		}
+	}
+}
+
+func TestSealedValueMismatch(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	sealed, err := Seal(&c, []byte("0123456789"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	reversed, _ := NewCipherWithAlphabet([]byte("9876543210"), 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	otherTweak, _ := NewCipher(10, 16, mustHex(testVectors[1].key), []byte("other"))
+	for idx, test := range []struct {
+		c     *Cipher
+		field string
+	}{
+		{&reversed, "alphabet"},
+		{&otherTweak, "tweak"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := Open(func(Fingerprint) (*Cipher, error) { return test.c, nil }, sealed)
+			var mismatch *FingerprintMismatchError
+			if !errors.As(err, &mismatch) || mismatch.Field != test.field {
+				t.Fatalf("got %v, want a mismatch of the %s", err, test.field)
+			}
+		})
+	}
+
+	resolveErr := errors.New("no such key")
+	if _, err := Open(func(Fingerprint) (*Cipher, error) { return nil, resolveErr }, sealed); err != resolveErr {
+		t.Fatalf("got %v, want %v", err, resolveErr)
+	}
+	if _, err := Open(func(Fingerprint) (*Cipher, error) { return nil, nil }, sealed); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("got %v, want %v", err, ErrNotInitialized)
+	}
+	sealed.AlgorithmID = 7
+	if _, err := Open(func(Fingerprint) (*Cipher, error) { return &c, nil }, sealed); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownAlgorithm)
+	}
+	if _, err := Seal(nil, []byte("0123456789")); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("got %v, want %v", err, ErrNotInitialized)
+	}
+}
+
+func TestSealedValueBinary(t *testing.T) {
+	sealed := SealedValue{AlgorithmID: AlgorithmFF1, TweakHint: 0x01020304, Ciphertext: []byte("12345")}
+	sealed.AlphabetFingerprint[0] = 0xAA
+	data, err := sealed.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{1, 1, 0xAA}, make([]byte, 15)...)
+	want = append(want, 1, 2, 3, 4, 5, '1', '2', '3', '4', '5')
+	if !bytes.Equal(data, want) {
+		t.Fatalf("MarshalBinary = %x, want %x", data, want)
+	}
+
+	// A later version with a field after the ciphertext
+	future := append([]byte{2}, data[1:]...)
+	future = append(future, 0x03, 'n', 'e', 'w')
+	var back SealedValue
+	if err := back.UnmarshalBinary(future); err != nil {
+		t.Fatalf("UnmarshalBinary of version 2: %v", err)
+	}
+	if !reflect.DeepEqual(back, sealed) {
+		t.Fatalf("got %+v, want %+v", back, sealed)
+	}
+
+	for idx, bad := range [][]byte{
+		nil,
+		data[:sealHeaderLen],
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+		append([]byte{0}, data[1:]...),
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if err := new(SealedValue).UnmarshalBinary(bad); !errors.Is(err, ErrSealedValueInvalid) {
+				t.Fatalf("UnmarshalBinary(%x) = %v, want %v", bad, err, ErrSealedValueInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// A SecretKey holds an AES key in memory the caller manages, such as locked
+// pages or an enclave, and lends it out only for the duration of a call.
+//
+// WithKey calls f once with the raw key and returns what f returns, or an error
+// of its own if the key cannot be made available. The slice passed to f is only
+// valid until f returns; wiping it afterwards is up to the SecretKey.
+type SecretKey interface {
+	WithKey(f func(key []byte) error) error
+}
+
+// RawKey is a SecretKey for a key held in an ordinary byte slice.
+type RawKey []byte
+
+// WithKey calls f with the key itself.
+func (k RawKey) WithKey(f func(key []byte) error) error {
+	return f(k)
+}
+
+// NewCipherWithSecret is like NewCipherWithAlphabet but takes the key from sk.
+// The raw key is only read inside the WithKey callback, to expand it into the
+// AES key schedule, and no reference to it is kept once the callback returns.
+//
+// The key schedule itself is ordinary Go memory and is not protected by sk.
+func NewCipherWithSecret(sk SecretKey, alphabet []byte, maxTLen int, tweak []byte, opts ...Option) (Cipher, error) {
+	if sk == nil {
+		return Cipher{}, errors.New("secret key must not be nil")
+	}
+
+	newCipher, err := buildCipher(alphabet, maxTLen, tweak)
+	if err != nil {
+		return newCipher, err
+	}
+
+	var aesBlock cipher.Block
+	err = sk.WithKey(func(key []byte) error {
+		var err error
+		aesBlock, err = newAESBlock(key)
+		return err
+	})
+	if err != nil {
+		return Cipher{}, err
+	}
+	newCipher.setBlock(aesBlock)
+
+	if err := newCipher.init(opts); err != nil {
+		return Cipher{}, err
+	}
+	return newCipher, nil
+}
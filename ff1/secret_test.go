@@ -0,0 +1,152 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// countingSecret lends out a copy of its key, counts the calls, and wipes the
+// copy as soon as the callback returns.
+type countingSecret struct {
+	key   []byte
+	calls int
+	lent  []byte
+	err   error
+}
+
+func (s *countingSecret) WithKey(f func(key []byte) error) error {
+	s.calls++
+	if s.err != nil {
+		return s.err
+	}
+	s.lent = append([]byte(nil), s.key...)
+	defer func() {
+		for i := range s.lent {
+			s.lent[i] = 0
+		}
+	}()
+	return f(s.lent)
+}
+
+func TestNewCipherWithSecret(t *testing.T) {
+	for idx, testVector := range testVectors {
+		sampleNumber := idx + 1
+		t.Run(fmt.Sprintf("Sample%d", sampleNumber), func(t *testing.T) {
+			alphabet, err := legacyAlphabetFor(testVector.radix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sk := &countingSecret{key: mustHex(testVector.key)}
+
+			ff1, err := NewCipherWithSecret(sk, alphabet, 16, mustHex(testVector.tweak))
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+			if sk.calls != 1 {
+				t.Fatalf("WithKey called %d times, want 1", sk.calls)
+			}
+			for i, b := range sk.lent {
+				if b != 0 {
+					t.Fatalf("lent key byte %d not wiped", i)
+				}
+			}
+
+			// The lent slice is zero now, so this only works if the cipher
+			// did not keep it
+			ciphertext, err := ff1.Encrypt(testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+				t.Fatalf("Encrypt = %s, want %s", ciphertext, testVector.ciphertext)
+			}
+			plaintext, err := ff1.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+				t.Fatalf("Decrypt = %s, want %s", plaintext, testVector.plaintext)
+			}
+			if sk.calls != 1 {
+				t.Fatalf("WithKey called %d times after use, want 1", sk.calls)
+			}
+		})
+	}
+}
+
+func TestNewCipherWithSecretRawKey(t *testing.T) {
+	testVector := testVectors[2]
+	alphabet, err := legacyAlphabetFor(testVector.radix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := mustHex(testVector.key)
+	fromSecret, err := NewCipherWithSecret(RawKey(key), alphabet, 16, mustHex(testVector.tweak))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromKey, err := NewCipherWithAlphabet(alphabet, 16, key, mustHex(testVector.tweak))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := fromKey.Encrypt(testVector.plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := fromSecret.Encrypt(testVector.plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("RawKey cipher = %s, want %s", got, want)
+	}
+}
+
+func TestNewCipherWithSecretError(t *testing.T) {
+	errLocked := errors.New("enclave locked")
+	alphabet := []byte("0123456789")
+
+	if _, err := NewCipherWithSecret(nil, alphabet, 16, nil); err == nil {
+		t.Fatal("nil SecretKey accepted")
+	}
+
+	sk := &countingSecret{err: errLocked}
+	if _, err := NewCipherWithSecret(sk, alphabet, 16, nil); !errors.Is(err, errLocked) {
+		t.Fatalf("got %v, want %v", err, errLocked)
+	}
+
+	sk = &countingSecret{key: make([]byte, 17)}
+	if _, err := NewCipherWithSecret(sk, alphabet, 16, nil); err == nil {
+		t.Fatal("17-byte key accepted")
+	}
+
+	// Parameters are checked before the key is asked for
+	sk = &countingSecret{key: make([]byte, 16)}
+	if _, err := NewCipherWithSecret(sk, alphabet, 16, make([]byte, 17)); err == nil {
+		t.Fatal("tweak longer than maxTLen accepted")
+	}
+	if sk.calls != 0 {
+		t.Fatalf("WithKey called %d times for invalid parameters, want 0", sk.calls)
+	}
+}
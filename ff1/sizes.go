@@ -0,0 +1,140 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// BlockSizes returns the FF1 byte lengths b and d (NIST SP 800-38G, Algorithm 7, steps 3 and 4)
+// for a message in the given radix whose second half B is halfLen numerals long, i.e.
+// halfLen = n - n/2 for a message of n numerals.
+//
+// b is the number of bytes needed to hold any numeral string of length halfLen, and
+// d is the number of PRF output bytes consumed per Feistel round, so each round performs
+// ceil(d/16) AES block operations in addition to the CBC-MAC over P||Q.
+//
+// The values are computed with integer arithmetic only. An error is returned if the
+// radix or length are out of range or if the sizes cannot be represented in an int.
+func BlockSizes(radix uint64, halfLen int) (b, d int, err error) {
+	if err := fpeUtils.CheckRadix(radix); err != nil {
+		return 0, 0, err
+	}
+	if halfLen < 0 {
+		return 0, 0, fmt.Errorf("half length must not be negative: %d supplied", halfLen)
+	}
+
+	// For power of 2 radices, radix^v - 1 is exactly v*log2(radix) one bits
+	if radix&(radix-1) == 0 {
+		hi, numBits := bits.Mul64(uint64(halfLen), uint64(bits.TrailingZeros64(radix)))
+		if hi != 0 {
+			return 0, 0, fmt.Errorf("half length %d too large", halfLen)
+		}
+		return sizesForBits(numBits)
+	}
+
+	domain, err := fpeUtils.DomainSize(radix, halfLen)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sizesForDomain(domain)
+}
+
+// sizesForDomain returns b and d given radix^v, the domain of the second half.
+// b = ceil(ceil(v*log2(radix))/8), and ceil(log2(radix^v)) is the bit length of radix^v - 1.
+func sizesForDomain(domain *big.Int) (b, d int, err error) {
+	var maxValue big.Int
+	maxValue.Sub(domain, big.NewInt(1))
+	return sizesForBits(uint64(maxValue.BitLen()))
+}
+
+// sizesForBits returns b and d given ceil(v*log2(radix)).
+func sizesForBits(numBits uint64) (b, d int, err error) {
+	b64 := ceilDiv(numBits, 8)
+	d64 := 4*ceilDiv(b64, 4) + 4
+	if d64 > uint64(maxInt) {
+		return 0, 0, fmt.Errorf("block sizes for %d bits exceed the platform int range", numBits)
+	}
+	return int(b64), int(d64), nil
+}
+
+// maxInt is the largest value of the platform dependent int type.
+const maxInt = int(^uint(0) >> 1)
+
+// ceilDiv returns ceil(x/y) for y > 0 without overflowing for any x.
+func ceilDiv(x, y uint64) uint64 {
+	return x/y + (x%y+y-1)/y
+}
+
+// layoutTooLarge is the error of layoutFor for sizes that do not fit in an int.
+func layoutTooLarge(t, b, d int) error {
+	return fmt.Errorf("buffers for t=%d b=%d d=%d exceed the platform int range", t, b, d)
+}
+
+// bufLayout holds the sizes of the working memory of one call, see layoutFor.
+type bufLayout struct {
+	// Q is lenQ bytes long, of which the first qOff only hold the tweak and padding
+	lenQ, qOff int
+
+	// The scratch buffer holds Q followed by the maxJ PRF output blocks and the
+	// counter block
+	bufLen int
+
+	// Words of room for each big.Int of the scratch
+	wordsPer int
+}
+
+// layoutFor returns the buffer sizes for a tweak of t bytes and a message with the
+// byte lengths b and d. The sums are done in uint64 and checked as they go, and an
+// error is returned rather than a size that does not fit in an int, which on 32-bit
+// platforms is possible for legal message lengths.
+func layoutFor(t, b, d int) (bufLayout, error) {
+	if t < 0 || b < 0 || d < 0 {
+		return bufLayout{}, fmt.Errorf("negative size: t=%d b=%d d=%d", t, b, d)
+	}
+
+	// Each sum is checked before it is added to, so none can pass 2^64
+	numPad := uint64(padLen(t, b))
+	if uint64(t)+uint64(b) > uint64(maxInt) {
+		return bufLayout{}, layoutTooLarge(t, b, d)
+	}
+	lenQ := uint64(t) + uint64(b) + 1 + numPad
+	if lenQ > uint64(maxInt) {
+		return bufLayout{}, layoutTooLarge(t, b, d)
+	}
+	qOff := (uint64(t) + numPad) / blockSize * blockSize
+	blocks := (ceilDiv(uint64(d), blockSize) + 1) * blockSize
+	if blocks > uint64(maxInt)-lenQ {
+		return bufLayout{}, layoutTooLarge(t, b, d)
+	}
+	bufLen := lenQ + blocks
+
+	// The d-byte y plus a carry word and the extra word math/big uses when dividing
+	wordsPer := ceilDiv(uint64(d), wordBytes) + 2
+	if wordsPer > uint64(maxInt)/scratchNums {
+		return bufLayout{}, layoutTooLarge(t, b, d)
+	}
+
+	return bufLayout{lenQ: int(lenQ), qOff: int(qOff), bufLen: int(bufLen), wordsPer: int(wordsPer)}, nil
+}
@@ -0,0 +1,311 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"testing"
+	"unsafe"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+func TestBlockSizes(t *testing.T) {
+	testSpec := []struct {
+		radix   uint64
+		halfLen int
+		b       int
+		d       int
+	}{
+		// NIST samples 1, 2, 4, 5, 7, 8: radix 10, 10 digits
+		{10, 5, 3, 8},
+		// NIST samples 3, 6, 9: radix 36, 19 characters
+		{36, 10, 7, 12},
+		{2, 4, 1, 8},
+		{2, 8, 1, 8},
+		{2, 9, 2, 8},
+		{256, 128, 128, 132},
+		{10, 0, 0, 4},
+		{10, 100000, 41525, 41532},
+		// Second halves near the maximum message length of math.MaxUint32 numerals,
+		// kept within the 32-bit int range
+		{2, 1 << 30, 1 << 27, 1<<27 + 4},
+		{16, 1 << 30, 1 << 29, 1<<29 + 4},
+		{256, math.MaxInt32 - 8, math.MaxInt32 - 8, math.MaxInt32 - 3},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			b, d, err := BlockSizes(spec.radix, spec.halfLen)
+			if err != nil {
+				t.Fatalf("BlockSizes(%d, %d): %s", spec.radix, spec.halfLen, err)
+			}
+			if b != spec.b || d != spec.d {
+				t.Fatalf("BlockSizes(%d, %d) = (%d, %d), expected (%d, %d)", spec.radix, spec.halfLen, b, d, spec.b, spec.d)
+			}
+		})
+	}
+}
+
+// For short inputs the float formula from the specification is exact, so both must agree
+func TestBlockSizesMatchesSpecFormula(t *testing.T) {
+	for radix := uint64(2); radix <= 256; radix++ {
+		for v := 1; v <= 64; v++ {
+			b, d, err := BlockSizes(radix, v)
+			if err != nil {
+				t.Fatalf("BlockSizes(%d, %d): %s", radix, v, err)
+			}
+			fb := int(math.Ceil(math.Ceil(float64(v)*math.Log2(float64(radix))) / 8))
+			fd := int(4*math.Ceil(float64(fb)/4) + 4)
+			if b != fb || d != fd {
+				t.Fatalf("BlockSizes(%d, %d) = (%d, %d), spec formula gives (%d, %d)", radix, v, b, d, fb, fd)
+			}
+		}
+	}
+}
+
+// Cases where the specification's formula, evaluated in float64 as ceil(v*ln(radix)/ln(2)),
+// rounds radix^v = 2^k up to k+1 bits and so overestimates b by one byte
+func TestBlockSizesFloatBoundaries(t *testing.T) {
+	testSpec := []struct {
+		radix   uint64
+		halfLen int
+		b       int
+		d       int
+	}{
+		{2, 232, 29, 36},
+		{4, 116, 29, 36},
+		{16, 58, 29, 36},
+		{256, 29, 29, 36},
+		{256, 31, 31, 36},
+		{256, 62, 62, 68},
+		{128, 1160, 1015, 1020},
+		// radix 256 around 128 bytes
+		{256, 127, 127, 132},
+		{256, 129, 129, 136},
+		// radix 10 around the 2^53 limit of exact float64 integers
+		{10, 15, 7, 12},
+		{10, 16, 7, 12},
+		{10, 17, 8, 12},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			b, d, err := BlockSizes(spec.radix, spec.halfLen)
+			if err != nil {
+				t.Fatalf("BlockSizes(%d, %d): %s", spec.radix, spec.halfLen, err)
+			}
+			if b != spec.b || d != spec.d {
+				t.Fatalf("BlockSizes(%d, %d) = (%d, %d), expected (%d, %d)", spec.radix, spec.halfLen, b, d, spec.b, spec.d)
+			}
+		})
+	}
+}
+
+// b is the smallest number of bytes that holds every value below radix^v,
+// i.e. 256^(b-1) < radix^v <= 256^b
+func TestBlockSizesExact(t *testing.T) {
+	one := big.NewInt(1)
+	for radix := uint64(2); radix <= 256; radix++ {
+		for v := 1; v <= 300; v++ {
+			b, _, err := BlockSizes(radix, v)
+			if err != nil {
+				t.Fatalf("BlockSizes(%d, %d): %s", radix, v, err)
+			}
+			domain, _ := fpeUtils.DomainSize(radix, v)
+			upper := new(big.Int).Lsh(one, uint(8*b))
+			lower := new(big.Int).Lsh(one, uint(8*(b-1)))
+			if domain.Cmp(upper) > 0 || domain.Cmp(lower) <= 0 {
+				t.Fatalf("BlockSizes(%d, %d): b = %d does not fit radix^v", radix, v, b)
+			}
+		}
+	}
+}
+
+// minLen is the smallest length with radix^minLen >= 100
+func TestMinLen(t *testing.T) {
+	for radix := 2; radix <= 256; radix++ {
+		c, err := NewCipherWithAlphabet(identityAlphabet(radix), 0, make([]byte, 16), nil)
+		if err != nil {
+			t.Fatalf("radix %d: %s", radix, err)
+		}
+		domain, _ := fpeUtils.DomainSize(uint64(radix), int(c.minLen))
+		smaller, _ := fpeUtils.DomainSize(uint64(radix), int(c.minLen)-1)
+		if domain.Cmp(big.NewInt(feistelMin)) < 0 || smaller.Cmp(big.NewInt(feistelMin)) >= 0 {
+			t.Fatalf("radix %d: minLen %d is not the smallest length with a domain of at least %d", radix, c.minLen, feistelMin)
+		}
+	}
+}
+
+func TestBlockSizesError(t *testing.T) {
+	testSpec := []struct {
+		radix   uint64
+		halfLen int
+	}{
+		{1, 10},
+		{257, 10},
+		{10, -1},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, _, err := BlockSizes(spec.radix, spec.halfLen); err == nil {
+				t.Fatalf("expected error for radix %d half length %d", spec.radix, spec.halfLen)
+			}
+		})
+	}
+}
+
+func TestPadLen(t *testing.T) {
+	for tl := 0; tl < 40; tl++ {
+		for b := 0; b < 40; b++ {
+			numPad := padLen(tl, b)
+			if numPad < 0 || numPad >= blockSize || (tl+b+1+numPad)%blockSize != 0 {
+				t.Fatalf("padLen(%d, %d) = %d", tl, b, numPad)
+			}
+		}
+	}
+
+	// No overflow for the largest ints
+	var sum big.Int
+	sum.SetInt64(int64(maxInt))
+	sum.Mul(&sum, big.NewInt(2))
+	sum.Add(&sum, big.NewInt(1))
+	expected := (blockSize - sum.Mod(&sum, big.NewInt(blockSize)).Int64()) % blockSize
+	if numPad := padLen(maxInt, maxInt); int64(numPad) != expected {
+		t.Fatalf("padLen(maxInt, maxInt) = %d expected %d", numPad, expected)
+	}
+}
+
+func TestLayoutFor(t *testing.T) {
+	testSpec := []struct {
+		t, b, d int
+		layout  bufLayout
+	}{
+		// NIST sample 1: no tweak, radix 10, 10 digits
+		{0, 3, 8, bufLayout{lenQ: 16, qOff: 0, bufLen: 48, wordsPer: int(ceilDiv(8, wordBytes)) + 2}},
+		// NIST sample 2: 10 byte tweak, so Q takes two blocks
+		{10, 3, 8, bufLayout{lenQ: 16, qOff: 0, bufLen: 48, wordsPer: int(ceilDiv(8, wordBytes)) + 2}},
+		{11, 8, 12, bufLayout{lenQ: 32, qOff: 16, bufLen: 64, wordsPer: int(ceilDiv(12, wordBytes)) + 2}},
+		// 133 numerals in radix 36: d = 44, three PRF output blocks
+		{0, 44, 48, bufLayout{lenQ: 48, qOff: 0, bufLen: 112, wordsPer: int(ceilDiv(48, wordBytes)) + 2}},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			layout, err := layoutFor(spec.t, spec.b, spec.d)
+			if err != nil {
+				t.Fatalf("layoutFor(%d, %d, %d): %s", spec.t, spec.b, spec.d, err)
+			}
+			if layout != spec.layout {
+				t.Fatalf("layoutFor(%d, %d, %d) = %+v expected %+v", spec.t, spec.b, spec.d, layout, spec.layout)
+			}
+		})
+	}
+}
+
+// layoutFor agrees with the same sizes computed in big.Int arithmetic, and fails
+// exactly when they do not fit in an int. Sizes near the int limit are covered
+// on every platform, no buffer is allocated.
+func TestLayoutForLarge(t *testing.T) {
+	rng := rand.New(rand.NewSource(130))
+	maxIntBig := big.NewInt(int64(maxInt))
+	sizes := []int{0, 1, 15, 16, 17, 1 << 20, maxInt/scratchNums*4 - 8, maxInt/scratchNums*4 - 4, maxInt / scratchNums * 4, maxInt / 2, maxInt - 48, maxInt - 47, maxInt - 32, maxInt - 31, maxInt - 1, maxInt}
+
+	check := func(tl, b, d int) {
+		t.Helper()
+		numPad := padLen(tl, b)
+		qOff := new(big.Int).SetInt64(int64(tl))
+		qOff.Add(qOff, big.NewInt(int64(numPad)))
+		lenQ := new(big.Int).Add(qOff, big.NewInt(int64(b)))
+		lenQ.Add(lenQ, big.NewInt(1))
+		qOff.Rsh(qOff, 4).Lsh(qOff, 4)
+		blocks := int64(ceilDiv(uint64(d), blockSize)) + 1
+		bufLen := new(big.Int).Mul(big.NewInt(blocks), big.NewInt(blockSize))
+		bufLen.Add(bufLen, lenQ)
+		wordsPer := int64(ceilDiv(uint64(d), wordBytes)) + 2
+		allWords := new(big.Int).Mul(big.NewInt(wordsPer), big.NewInt(scratchNums))
+		fits := bufLen.Cmp(maxIntBig) <= 0 && allWords.Cmp(maxIntBig) <= 0
+
+		layout, err := layoutFor(tl, b, d)
+		if fits != (err == nil) {
+			t.Fatalf("layoutFor(%d, %d, %d): error %v, expected it to fit: %v", tl, b, d, err, fits)
+		}
+		if err != nil {
+			return
+		}
+		if int64(layout.lenQ) != lenQ.Int64() || int64(layout.qOff) != qOff.Int64() ||
+			int64(layout.bufLen) != bufLen.Int64() || int64(layout.wordsPer) != wordsPer {
+			t.Fatalf("layoutFor(%d, %d, %d) = %+v", tl, b, d, layout)
+		}
+	}
+
+	for _, tl := range sizes {
+		for _, b := range sizes {
+			for _, d := range sizes {
+				check(tl, b, d)
+			}
+		}
+	}
+	for i := 0; i < 10000; i++ {
+		check(rng.Intn(maxInt), rng.Intn(maxInt), rng.Intn(maxInt))
+	}
+
+	if _, err := layoutFor(-1, 0, 0); err == nil {
+		t.Fatalf("expected an error for a negative size")
+	}
+}
+
+func TestTweakLenValid(t *testing.T) {
+	if !tweakLenValid(0, 0) || !tweakLenValid(10, 10) || tweakLenValid(11, 10) {
+		t.Fatalf("tweakLenValid does not compare with maxTLen")
+	}
+	// FF1 encodes the tweak length in 32 bits, whatever maxTLen allows
+	if tweakLenValid(maxInt, maxInt) != (uint64(maxInt) <= math.MaxUint32) {
+		t.Fatalf("tweakLenValid(maxInt, maxInt) = %v", tweakLenValid(maxInt, maxInt))
+	}
+}
+
+// A message of more than 2^32 numerals must be rejected, not wrapped around to a
+// short length. The slice header claims the length without the memory behind it,
+// which is safe because the length is checked before anything is read.
+func TestLengthPast32Bits(t *testing.T) {
+	if bits.UintSize == 32 {
+		t.Skip("lengths past 2^32 cannot be represented")
+	}
+	if raceEnabled {
+		t.Skip("checkptr rejects the fake slice")
+	}
+	c, err := NewCipher(10, 0, make([]byte, 16), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	backing := []byte("0123456789")
+	n := uint64(1)<<32 + uint64(len(backing))
+	X := unsafe.Slice(&backing[0], int(n))
+	if _, err := c.Encrypt(X); err == nil {
+		t.Fatalf("a message of %d numerals was accepted", n)
+	}
+}
@@ -0,0 +1,287 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+// DefaultTableCap is the largest number of entries ExportTable and WriteTableCSV
+// produce unless WithTableCap sets another limit.
+const DefaultTableCap = 1000000
+
+// ErrDomainTooLarge is returned, wrapped with the sizes, by ExportTable and
+// WriteTableCSV if the table would have more entries than the cap.
+var ErrDomainTooLarge = errors.New("domain too large for a table")
+
+// maxReportedMismatches bounds the mismatches VerifyTable describes one by one.
+const maxReportedMismatches = 100
+
+// A TableOption configures ExportTable and WriteTableCSV.
+type TableOption func(*tableConfig)
+
+type tableConfig struct {
+	maxEntries int
+}
+
+// WithTableCap sets the largest number of entries a table may have.
+func WithTableCap(maxEntries int) TableOption {
+	return func(cfg *tableConfig) {
+		cfg.maxEntries = maxEntries
+	}
+}
+
+// ExportTable encrypts every message of the given length and returns the
+// ciphertexts by plaintext, for systems that can look values up but cannot run
+// FF1. It fails with ErrDomainTooLarge if there are more than DefaultTableCap such
+// messages, or than the cap set with WithTableCap.
+//
+// As the table is built, each ciphertext is checked to be different from all
+// earlier ones, so a table returned without error also shows that the Cipher is a
+// permutation of the messages of that length. Every entry is one encryption, so
+// they count against WithUsageLimit. The table holds the whole mapping in the clear
+// and needs the same protection as the key.
+func ExportTable(c *Cipher, length int, opts ...TableOption) (map[string]string, error) {
+	table := make(map[string]string)
+	err := enumerateTable(c, length, opts, func(plaintext, ciphertext []byte) error {
+		table[string(plaintext)] = string(ciphertext)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// WriteTableCSV is like ExportTable but writes the table to w as CSV, with a header
+// row "plaintext,ciphertext" and the entries in the order of the plaintexts in the
+// alphabet. It does not keep the table in memory.
+func WriteTableCSV(w io.Writer, c *Cipher, length int, opts ...TableOption) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"plaintext", "ciphertext"}); err != nil {
+		return err
+	}
+	err := enumerateTable(c, length, opts, func(plaintext, ciphertext []byte) error {
+		return cw.Write([]string{string(plaintext), string(ciphertext)})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// enumerateTable calls emit with every message of the given length, in order,
+// and its ciphertext. Both slices are reused between calls.
+func enumerateTable(c *Cipher, length int, opts []TableOption, emit func(plaintext, ciphertext []byte) error) error {
+	cfg := tableConfig{maxEntries: DefaultTableCap}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if c == nil || c.codec == nil {
+		return ErrNotInitialized
+	}
+	if length < 0 || uint64(length) < uint64(c.minLen) || uint64(length) > uint64(c.maxLen) {
+		return c.lengthError(length)
+	}
+	radix := uint64(c.codec.Radix())
+	size, err := fpeUtils.DomainSize(radix, length)
+	if err != nil {
+		return err
+	}
+	if cfg.maxEntries < 0 || size.Cmp(big.NewInt(int64(cfg.maxEntries))) > 0 {
+		return fmt.Errorf("%w: %d numerals of radix %d give %s entries, the cap is %d", ErrDomainTooLarge, length, radix, size, cfg.maxEntries)
+	}
+	n := size.Uint64()
+
+	alphabet := c.codec.Alphabet()
+	digits := make([]uint8, length)
+	plaintext := make([]byte, length)
+	ciphertext := make([]byte, length)
+	numerals := make([]uint8, length)
+	seen := make([]uint64, (n+63)/64)
+	for i := uint64(0); i < n; i++ {
+		for j, d := range digits {
+			plaintext[j] = alphabet[d]
+		}
+		ciphertext, err = c.EncryptInto(ciphertext, plaintext)
+		if err != nil {
+			return err
+		}
+
+		if numerals, err = c.codec.EncodeInto(numerals, ciphertext); err != nil {
+			return err
+		}
+		v, _ := fpeUtils.Num64(numerals, radix)
+		if seen[v/64]&(1<<(v%64)) != 0 {
+			return fmt.Errorf("ciphertext %q of %q repeats an earlier one, the cipher is not a permutation", ciphertext, plaintext)
+		}
+		seen[v/64] |= 1 << (v % 64)
+
+		if err := emit(plaintext, ciphertext); err != nil {
+			return err
+		}
+
+		// Next plaintext, counting in the radix with the last numeral changing fastest
+		for j := length - 1; j >= 0; j-- {
+			if uint64(digits[j])+1 < radix {
+				digits[j]++
+				break
+			}
+			digits[j] = 0
+		}
+	}
+	return nil
+}
+
+// TableMismatch describes a row of a table that does not match the Cipher.
+type TableMismatch struct {
+	// Line is the line of the row in the table, counting from 1.
+	Line int
+
+	// Plaintext and Ciphertext are the values of the row, Want is the encryption
+	// of Plaintext, or empty if Err is set.
+	Plaintext, Ciphertext, Want string
+
+	// Err is set if Plaintext cannot be encrypted at all.
+	Err error
+}
+
+// TableReport is the result of VerifyTable.
+type TableReport struct {
+	// Rows is the number of rows checked, not counting a header, and Matches the
+	// number of them that match the Cipher.
+	Rows, Matches int
+
+	// Mismatches describes the rows that do not match, the first 100 of them if
+	// there are more. MismatchCount is their total number.
+	Mismatches    []TableMismatch
+	MismatchCount int
+
+	// Duplicates is the number of rows whose plaintext appeared in an earlier row.
+	// It is only counted for tables that could be complete, see Complete.
+	Duplicates int
+
+	// Complete is set if every row matches and every message of one length
+	// appears exactly once, as in a table from ExportTable. This is only checked
+	// for lengths that have at most DefaultTableCap messages, and is false for
+	// longer ones.
+	Complete bool
+}
+
+// VerifyTable reads a table in the format of WriteTableCSV and checks each row
+// against the encryption of its plaintext by c. The header row is optional. The
+// table is read as a stream, so the memory used does not grow with its size beyond
+// a bitmap of the messages of one length, if there are at most DefaultTableCap.
+//
+// Rows that do not match are reported, with their line numbers, in the TableReport.
+// The error is only set if the table cannot be read, for example because a row
+// does not have two fields; the report then covers the rows before.
+func VerifyTable(c *Cipher, table io.Reader) (TableReport, error) {
+	var rep TableReport
+	if c == nil || c.codec == nil {
+		return rep, ErrNotInitialized
+	}
+	radix := uint64(c.codec.Radix())
+
+	r := csv.NewReader(table)
+	r.FieldsPerRecord = 2
+	r.ReuseRecord = true
+
+	var (
+		ciphertext []byte
+		numerals   []uint8
+		seen       []uint64
+		domain     uint64
+		length     = -1
+		trackable  = true
+	)
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rep, err
+		}
+		if first && record[0] == "plaintext" && record[1] == "ciphertext" {
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		plaintext := record[0]
+		rep.Rows++
+
+		var encErr error
+		ciphertext, encErr = c.EncryptInto(ciphertext, []byte(plaintext))
+		switch {
+		case encErr != nil:
+			rep.addMismatch(TableMismatch{Line: line, Plaintext: plaintext, Ciphertext: record[1], Err: encErr})
+			trackable = false
+			continue
+		case string(ciphertext) != record[1]:
+			rep.addMismatch(TableMismatch{Line: line, Plaintext: plaintext, Ciphertext: record[1], Want: string(ciphertext)})
+		default:
+			rep.Matches++
+		}
+
+		// Track which messages of the length of the first row appeared
+		if !trackable {
+			continue
+		}
+		if length < 0 {
+			length = len(plaintext)
+			size, err := fpeUtils.DomainSize(radix, length)
+			if err != nil || size.Cmp(big.NewInt(DefaultTableCap)) > 0 {
+				trackable = false
+				continue
+			}
+			domain = size.Uint64()
+			seen = make([]uint64, (domain+63)/64)
+		}
+		if len(plaintext) != length {
+			trackable = false
+			continue
+		}
+		numerals, _ = c.codec.EncodeInto(numerals, []byte(plaintext))
+		v, _ := fpeUtils.Num64(numerals, radix)
+		if seen[v/64]&(1<<(v%64)) != 0 {
+			rep.Duplicates++
+			continue
+		}
+		seen[v/64] |= 1 << (v % 64)
+	}
+
+	rep.Complete = trackable && length >= 0 && rep.MismatchCount == 0 && rep.Duplicates == 0 && uint64(rep.Rows) == domain
+	return rep, nil
+}
+
+func (rep *TableReport) addMismatch(m TableMismatch) {
+	rep.MismatchCount++
+	if len(rep.Mismatches) < maxReportedMismatches {
+		rep.Mismatches = append(rep.Mismatches, m)
+	}
+}
@@ -0,0 +1,268 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExportTable(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	table, err := ExportTable(&c, 3)
+	if err != nil {
+		t.Fatalf("ExportTable: %v", err)
+	}
+	if len(table) != 1000 {
+		t.Fatalf("%d entries, want 1000", len(table))
+	}
+
+	inverse := make(map[string]string, len(table))
+	for plaintext, ciphertext := range table {
+		if len(plaintext) != 3 || len(ciphertext) != 3 {
+			t.Fatalf("entry %q -> %q has the wrong length", plaintext, ciphertext)
+		}
+		if other, ok := inverse[ciphertext]; ok {
+			t.Fatalf("%q and %q both encrypt to %q", other, plaintext, ciphertext)
+		}
+		inverse[ciphertext] = plaintext
+
+		want, err := c.Encrypt([]byte(plaintext))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if string(want) != ciphertext {
+			t.Fatalf("table has %q -> %q, Encrypt gives %q", plaintext, ciphertext, want)
+		}
+		back, err := c.Decrypt([]byte(ciphertext))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if string(back) != plaintext {
+			t.Fatalf("Decrypt(%q) = %q, table has %q", ciphertext, back, plaintext)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		plaintext := []byte{'0' + byte(i/100), '0' + byte(i/10%10), '0' + byte(i%10)}
+		if _, ok := table[string(plaintext)]; !ok {
+			t.Fatalf("%s missing from the table", plaintext)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTableCSV(&buf, &c, 3); err != nil {
+		t.Fatalf("WriteTableCSV: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(records) != 1001 || records[0][0] != "plaintext" || records[0][1] != "ciphertext" {
+		t.Fatalf("%d records, header %q", len(records), records[0])
+	}
+	for i, record := range records[1:] {
+		if i == 0 && record[0] != "000" || i == 999 && record[0] != "999" {
+			t.Fatalf("record %d is for %q, not in order", i, record[0])
+		}
+		if table[record[0]] != record[1] {
+			t.Fatalf("CSV has %q -> %q, table has %q", record[0], record[1], table[record[0]])
+		}
+	}
+}
+
+func TestExportTableBinary(t *testing.T) {
+	alphabet := make([]byte, 256)
+	for i := range alphabet {
+		alphabet[i] = byte(i)
+	}
+	c, err := NewCipherWithAlphabet(alphabet, 0, mustHex(testVectors[0].key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	table, err := ExportTable(&c, 2)
+	if err != nil {
+		t.Fatalf("ExportTable: %v", err)
+	}
+	if len(table) != 65536 {
+		t.Fatalf("%d entries, want 65536", len(table))
+	}
+}
+
+func TestExportTableCap(t *testing.T) {
+	c, err := NewCipher(10, 0, mustHex(testVectors[0].key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	if _, err := ExportTable(&c, 7); !errors.Is(err, ErrDomainTooLarge) {
+		t.Fatalf("length 7: got %v, want %v", err, ErrDomainTooLarge)
+	}
+	if _, err := ExportTable(&c, 3, WithTableCap(999)); !errors.Is(err, ErrDomainTooLarge) {
+		t.Fatalf("cap 999: got %v, want %v", err, ErrDomainTooLarge)
+	}
+	if err := WriteTableCSV(&bytes.Buffer{}, &c, 3, WithTableCap(999)); !errors.Is(err, ErrDomainTooLarge) {
+		t.Fatalf("cap 999: got %v, want %v", err, ErrDomainTooLarge)
+	}
+	if _, err := ExportTable(&c, 3, WithTableCap(-1)); !errors.Is(err, ErrDomainTooLarge) {
+		t.Fatalf("cap -1: got %v, want %v", err, ErrDomainTooLarge)
+	}
+	if table, err := ExportTable(&c, 3, WithTableCap(1000)); err != nil || len(table) != 1000 {
+		t.Fatalf("cap 1000: %d entries, %v", len(table), err)
+	}
+
+	if _, err := ExportTable(&c, 1); err == nil {
+		t.Fatal("length below MinLen accepted")
+	}
+	if _, err := ExportTable(&c, -1); err == nil {
+		t.Fatal("negative length accepted")
+	}
+	if _, err := ExportTable(nil, 3); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("nil cipher: got %v, want %v", err, ErrNotInitialized)
+	}
+}
+
+// exportCSV returns the table of c for the given length as written by WriteTableCSV
+func exportCSV(t *testing.T, c *Cipher, length int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteTableCSV(&buf, c, length); err != nil {
+		t.Fatalf("WriteTableCSV: %v", err)
+	}
+	return buf.String()
+}
+
+func TestVerifyTable(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	table := exportCSV(t, &c, 3)
+
+	rep, err := VerifyTable(&c, strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("VerifyTable: %v", err)
+	}
+	if rep.Rows != 1000 || rep.Matches != 1000 || rep.MismatchCount != 0 || !rep.Complete {
+		t.Fatalf("report %+v for a correct table", rep)
+	}
+
+	// The header is optional
+	noHeader := table[strings.IndexByte(table, '\n')+1:]
+	if rep, err := VerifyTable(&c, strings.NewReader(noHeader)); err != nil || !rep.Complete {
+		t.Fatalf("without header: %+v, %v", rep, err)
+	}
+
+	// Another key gives a different mapping
+	other, err := NewCipher(10, 16, mustHex(testVectors[3].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	rep, err = VerifyTable(&other, strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("VerifyTable: %v", err)
+	}
+	if rep.Complete || rep.MismatchCount < 900 || len(rep.Mismatches) != maxReportedMismatches {
+		t.Fatalf("other key: %d mismatches, %d reported, complete %v", rep.MismatchCount, len(rep.Mismatches), rep.Complete)
+	}
+}
+
+func TestVerifyTableCorrupted(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	lines := strings.Split(exportCSV(t, &c, 3), "\n")
+
+	// Line 501 holds plaintext 499
+	want := lines[500][4:]
+	corrupted := []byte(want)
+	corrupted[0] = '0' + (corrupted[0]-'0'+1)%10
+	lines[500] = "499," + string(corrupted)
+
+	rep, err := VerifyTable(&c, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("VerifyTable: %v", err)
+	}
+	if rep.Rows != 1000 || rep.Matches != 999 || rep.MismatchCount != 1 || rep.Complete {
+		t.Fatalf("report %+v for a corrupted table", rep)
+	}
+	m := rep.Mismatches[0]
+	if m.Line != 501 || m.Plaintext != "499" || m.Ciphertext != string(corrupted) || m.Want != want || m.Err != nil {
+		t.Fatalf("mismatch %+v, want line 501, 499 -> %s instead of %s", m, corrupted, want)
+	}
+
+	// A plaintext that cannot be encrypted, and a duplicated row
+	lines[500] = "49x,123"
+	lines[501] = lines[2]
+	rep, err = VerifyTable(&c, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("VerifyTable: %v", err)
+	}
+	if rep.MismatchCount != 1 || rep.Mismatches[0].Line != 501 || rep.Mismatches[0].Err == nil || rep.Complete {
+		t.Fatalf("report %+v for an invalid plaintext", rep)
+	}
+}
+
+func TestVerifyTableTruncated(t *testing.T) {
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	table := exportCSV(t, &c, 3)
+	end := strings.Index(table, "\n500,")
+
+	// Cut after a full row: all rows match, but the table is incomplete
+	rep, err := VerifyTable(&c, strings.NewReader(table[:end+1]))
+	if err != nil {
+		t.Fatalf("VerifyTable: %v", err)
+	}
+	if rep.Rows != 500 || rep.Matches != 500 || rep.Complete {
+		t.Fatalf("report %+v for a table cut after a row", rep)
+	}
+
+	// Cut in the ciphertext: the last row does not match
+	rep, err = VerifyTable(&c, strings.NewReader(table[:end+6]))
+	if err != nil {
+		t.Fatalf("VerifyTable: %v", err)
+	}
+	if rep.Rows != 501 || rep.MismatchCount != 1 || rep.Mismatches[0].Line != 502 || rep.Complete {
+		t.Fatalf("report %+v for a table cut in a ciphertext", rep)
+	}
+
+	// Cut in the plaintext: the last row lacks a field
+	rep, err = VerifyTable(&c, strings.NewReader(table[:end+3]))
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 502 {
+		t.Fatalf("got %v, want a parse error on line 502", err)
+	}
+	if rep.Rows != 500 || rep.Matches != 500 {
+		t.Fatalf("report %+v before the parse error", rep)
+	}
+
+	if _, err := VerifyTable(nil, strings.NewReader(table)); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("nil cipher: got %v, want %v", err, ErrNotInitialized)
+	}
+}
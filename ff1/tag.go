@@ -0,0 +1,113 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrTagMismatch is returned by DecryptVerified if the tag does not belong to the
+// ciphertext, because either of them was changed or they come from different keys
+// or tweaks.
+var ErrTagMismatch = errors.New("tag does not match ciphertext")
+
+const (
+	defaultTagLen = 16
+	minTagLen     = 8
+)
+
+// tagInfo and tagLabel separate the tag key from the key of the cipher and from
+// any other key derived from it.
+const tagInfo = "go-fpe-bytes ff1 tag v1"
+
+var tagLabel = [2][blockSize]byte{
+	{'f', 'f', '1', ' ', 't', 'a', 'g', ' ', 'k', 'e', 'y', 0, 0, 0, 0, 1},
+	{'f', 'f', '1', ' ', 't', 'a', 'g', ' ', 'k', 'e', 'y', 0, 0, 0, 0, 2},
+}
+
+// tagKey returns the key of the tags. The Cipher does not keep the AES key itself,
+// so the input to HKDF is the encryption of two fixed blocks under it, which only
+// the holder of the key can compute.
+func (st *keyState) tagKey() []byte {
+	st.tagOnce.Do(func() {
+		var ikm [2 * blockSize]byte
+		st.block.Encrypt(ikm[:blockSize], tagLabel[0][:])
+		st.block.Encrypt(ikm[blockSize:], tagLabel[1][:])
+		st.tag = hkdfSHA256(ikm[:], nil, []byte(tagInfo), sha256.Size)
+		for i := range ikm {
+			ikm[i] = 0
+		}
+	})
+	return st.tag
+}
+
+// EncryptWithTag is like Encrypt, and also returns a tag that DecryptVerified uses
+// to detect a changed ciphertext. The tag is an HMAC-SHA256 of the tweak and the
+// ciphertext, under a key derived from the key of the Cipher, truncated to 16 bytes
+// or the length set with WithTagLen. The ciphertext is the same as from Encrypt.
+//
+// FF1 maps every string of the right length and alphabet to some plaintext, so
+// without the tag a corrupted ciphertext decrypts to a wrong value without error.
+func (c Cipher) EncryptWithTag(X []byte) (ciphertext, tag []byte, err error) {
+	pinned, st, err := c.pin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer st.release()
+
+	ciphertext, err = pinned.Encrypt(X)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ciphertext, pinned.tag(st, ciphertext), nil
+}
+
+// DecryptVerified checks in constant time that tag was returned by EncryptWithTag
+// together with ciphertext, under the same key and tweak, and only then decrypts
+// it. It returns ErrTagMismatch otherwise, also if the tag has the wrong length.
+func (c Cipher) DecryptVerified(ciphertext, tag []byte) ([]byte, error) {
+	pinned, st, err := c.pin()
+	if err != nil {
+		return nil, err
+	}
+	defer st.release()
+
+	if !hmac.Equal(tag, pinned.tag(st, ciphertext)) {
+		return nil, ErrTagMismatch
+	}
+	return pinned.Decrypt(ciphertext)
+}
+
+// tag computes the tag of ciphertext under the tweak of the Cipher and the key st.
+// The tweak is prefixed with its length, so no other split of the input gives the
+// same MAC.
+func (c Cipher) tag(st *keyState, ciphertext []byte) []byte {
+	var tLen [4]byte
+	binary.BigEndian.PutUint32(tLen[:], uint32(len(c.tweak)))
+
+	mac := hmac.New(sha256.New, st.tagKey())
+	mac.Write(tLen[:])
+	mac.Write(c.tweak)
+	mac.Write(ciphertext)
+	return mac.Sum(nil)[:c.tagLen]
+}
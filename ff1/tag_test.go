@@ -0,0 +1,170 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEncryptWithTag(t *testing.T) {
+	for idx, testVector := range testVectors {
+		sampleNumber := idx + 1
+		t.Run(fmt.Sprintf("Sample%d", sampleNumber), func(t *testing.T) {
+			key := mustHex(testVector.key)
+			tweak := mustHex(testVector.tweak)
+			ff1, err := NewCipher(testVector.radix, 16, key, tweak)
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+
+			ciphertext, tag, err := ff1.EncryptWithTag(testVector.plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ciphertext, testVector.ciphertext) {
+				t.Fatalf("ciphertext %s, want %s", ciphertext, testVector.ciphertext)
+			}
+			if len(tag) != defaultTagLen {
+				t.Fatalf("tag length %d, want %d", len(tag), defaultTagLen)
+			}
+
+			// A cipher rebuilt from the same key and tweak accepts the tag
+			rebuilt, err := NewCipher(testVector.radix, 16, key, tweak)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext, err := rebuilt.DecryptVerified(ciphertext, tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plaintext, testVector.plaintext) {
+				t.Fatalf("plaintext %s, want %s", plaintext, testVector.plaintext)
+			}
+
+			for i := 0; i < len(ciphertext)*8; i++ {
+				flipped := append([]byte{}, ciphertext...)
+				flipped[i/8] ^= 1 << (i % 8)
+				if _, err := rebuilt.DecryptVerified(flipped, tag); !errors.Is(err, ErrTagMismatch) {
+					t.Fatalf("ciphertext bit %d flipped: got %v, want %v", i, err, ErrTagMismatch)
+				}
+			}
+			for i := 0; i < len(tag)*8; i++ {
+				flipped := append([]byte{}, tag...)
+				flipped[i/8] ^= 1 << (i % 8)
+				if _, err := rebuilt.DecryptVerified(ciphertext, flipped); !errors.Is(err, ErrTagMismatch) {
+					t.Fatalf("tag bit %d flipped: got %v, want %v", i, err, ErrTagMismatch)
+				}
+			}
+			if _, err := rebuilt.DecryptVerified(ciphertext, tag[:len(tag)-1]); !errors.Is(err, ErrTagMismatch) {
+				t.Fatalf("short tag: got %v, want %v", err, ErrTagMismatch)
+			}
+
+			otherTweak, err := NewCipher(testVector.radix, 16, key, append(tweak, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := otherTweak.DecryptVerified(ciphertext, tag); !errors.Is(err, ErrTagMismatch) {
+				t.Fatalf("other tweak: got %v, want %v", err, ErrTagMismatch)
+			}
+		})
+	}
+}
+
+func TestTagLen(t *testing.T) {
+	key := mustHex(testVectors[0].key)
+	plaintext := testVectors[0].plaintext
+
+	full, err := NewCipher(10, 0, key, nil, WithTagLen(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fullTag, err := full.EncryptWithTag(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{8, 12, 16, 32} {
+		c, err := NewCipher(10, 0, key, nil, WithTagLen(n))
+		if err != nil {
+			t.Fatalf("WithTagLen(%d): %v", n, err)
+		}
+		ciphertext, tag, err := c.EncryptWithTag(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tag, fullTag[:n]) {
+			t.Fatalf("WithTagLen(%d): tag %x is not a prefix of %x", n, tag, fullTag)
+		}
+		if _, err := c.DecryptVerified(ciphertext, tag); err != nil {
+			t.Fatalf("WithTagLen(%d): %v", n, err)
+		}
+		if n != 32 {
+			if _, err := c.DecryptVerified(ciphertext, fullTag); !errors.Is(err, ErrTagMismatch) {
+				t.Fatalf("WithTagLen(%d) with full tag: got %v, want %v", n, err, ErrTagMismatch)
+			}
+		}
+	}
+
+	for _, n := range []int{-1, 0, 7, 33} {
+		if _, err := NewCipher(10, 0, key, nil, WithTagLen(n)); err == nil {
+			t.Fatalf("WithTagLen(%d) accepted", n)
+		}
+	}
+}
+
+func TestEncryptWithTagFamily(t *testing.T) {
+	key := mustHex(testVectors[0].key)
+	family, err := NewCipherFamily(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := family.Cipher(10, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, tag, err := child.EncryptWithTag(testVectors[0].plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	standalone, err := NewCipher(10, 0, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := standalone.DecryptVerified(ciphertext, tag); err != nil {
+		t.Fatalf("tag of Family cipher rejected by standalone cipher: %v", err)
+	}
+
+	standalone.Wipe()
+	if _, _, err := standalone.EncryptWithTag(testVectors[0].plaintext); !errors.Is(err, ErrWiped) {
+		t.Fatalf("got %v, want %v", err, ErrWiped)
+	}
+	if _, err := standalone.DecryptVerified(ciphertext, tag); !errors.Is(err, ErrWiped) {
+		t.Fatalf("got %v, want %v", err, ErrWiped)
+	}
+
+	var zero Cipher
+	if _, err := zero.DecryptVerified(ciphertext, tag); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("got %v, want %v", err, ErrNotInitialized)
+	}
+}
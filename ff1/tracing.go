@@ -0,0 +1,66 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import "context"
+
+// A TraceHook is told when each encryption and decryption of a Cipher created with
+// WithTracing starts and ends, so that an adapter can record them as spans of a
+// tracing system such as OpenTelemetry, without this package depending on it.
+//
+// StartOp is called with the context of the call, the operation, OpEncrypt or
+// OpDecrypt, and the number of symbols of the message. It returns the context
+// that EndOp then gets together with the error of the call, nil on success. An
+// adapter would start a span as a child of the span in ctx, return the context
+// holding the new span, and end that span in EndOp. Both are called from the
+// goroutine of the call and must be safe for concurrent use.
+//
+// The context of the call is the one given to EncryptContext, DecryptContext,
+// EncryptParallel, DecryptParallel or LineEncrypter.Run, so the operations show
+// up under the span of the caller. The other methods use context.Background().
+type TraceHook interface {
+	StartOp(ctx context.Context, op string, symbols int) context.Context
+	EndOp(ctx context.Context, err error)
+}
+
+// WithTracing makes the Cipher report each encryption and decryption to h, in the
+// same way as WithMetrics does. A Cipher without a TraceHook only pays for one
+// comparison per call.
+func WithTracing(h TraceHook) Option {
+	return func(c *Cipher) {
+		c.tracer = h
+	}
+}
+
+// EncryptContext is the same as Encrypt, passing ctx to the TraceHook. The
+// encryption itself does not stop when ctx is cancelled.
+func (c Cipher) EncryptContext(ctx context.Context, X []byte) ([]byte, error) {
+	return c.crypt(ctx, nil, nil, X, c.tweak, true)
+}
+
+// DecryptContext is the same as Decrypt, passing ctx to the TraceHook.
+func (c Cipher) DecryptContext(ctx context.Context, X []byte) ([]byte, error) {
+	return c.crypt(ctx, nil, nil, X, c.tweak, false)
+}
+
+// traceEnd reports the end of a call with *err to h.
+func traceEnd(h TraceHook, ctx context.Context, err *error) {
+	h.EndOp(ctx, *err)
+}
@@ -0,0 +1,195 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+// fakeSpan is an operation recorded by fakeTracer.
+type fakeSpan struct {
+	id, parent int
+	op         string
+	symbols    int
+	ended      bool
+	err        error
+}
+
+// fakeTracer records spans, with the span in the context as their parent.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (f *fakeTracer) StartOp(ctx context.Context, op string, symbols int) context.Context {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parent, _ := ctx.Value(spanKey{}).(int)
+	s := &fakeSpan{id: len(f.spans) + 1, parent: parent, op: op, symbols: symbols}
+	f.spans = append(f.spans, s)
+	return context.WithValue(ctx, spanKey{}, s.id)
+}
+
+func (f *fakeTracer) EndOp(ctx context.Context, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.spans[ctx.Value(spanKey{}).(int)-1]
+	if s.ended {
+		panic("span ended twice")
+	}
+	s.ended, s.err = true, err
+}
+
+// take returns the spans recorded so far and forgets them.
+func (f *fakeTracer) take() []*fakeSpan {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	spans := f.spans
+	f.spans = nil
+	return spans
+}
+
+// checkSpans checks that spans are ended, of op and children of parent.
+func checkSpans(t *testing.T, spans []*fakeSpan, n int, op string, parent int) {
+	t.Helper()
+	if len(spans) != n {
+		t.Fatalf("%d spans, want %d", len(spans), n)
+	}
+	for _, s := range spans {
+		if !s.ended || s.op != op || s.parent != parent {
+			t.Fatalf("span %+v, want an ended %s below %d", *s, op, parent)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	var tracer fakeTracer
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak), WithTracing(&tracer))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), spanKey{}, 1000)
+
+	Y, err := c.EncryptContext(ctx, []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracer.take()
+	checkSpans(t, spans, 1, OpEncrypt, 1000)
+	if spans[0].symbols != 10 || spans[0].err != nil {
+		t.Fatalf("span %+v", *spans[0])
+	}
+
+	if _, err := c.DecryptContext(ctx, Y); err != nil {
+		t.Fatal(err)
+	}
+	checkSpans(t, tracer.take(), 1, OpDecrypt, 1000)
+
+	_, err = c.DecryptContext(ctx, []byte("01234x"))
+	spans = tracer.take()
+	checkSpans(t, spans, 1, OpDecrypt, 1000)
+	if spans[0].err != err || !errors.Is(err, ErrStringNotInRadix) {
+		t.Fatalf("span has error %v, call returned %v", spans[0].err, err)
+	}
+
+	// Without a context the spans have no parent
+	if _, err := c.Encrypt([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	checkSpans(t, tracer.take(), 1, OpEncrypt, 0)
+}
+
+func TestTracingParallel(t *testing.T) {
+	var tracer fakeTracer
+	c, err := NewCipher(10, 16, mustHex(testVectors[1].key), mustHex(testVectors[1].tweak), WithTracing(&tracer))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), spanKey{}, 1000)
+
+	in := make(chan []byte)
+	out := make(chan Result)
+	go func() {
+		for i := 0; i < 20; i++ {
+			in <- []byte(fmt.Sprintf("%06d", i))
+		}
+		close(in)
+	}()
+	done := make(chan error)
+	go func() { done <- c.EncryptParallel(ctx, in, out, 4) }()
+	for i := 0; i < 20; i++ {
+		<-out
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	checkSpans(t, tracer.take(), 20, OpEncrypt, 1000)
+
+	// Lines too
+	var sink bytes.Buffer
+	le := NewLineEncrypter(&c, strings.NewReader("123\n4x6\n789\n"), &bytes.Buffer{}, WithErrorSink(&sink))
+	if _, err := le.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	spans := tracer.take()
+	checkSpans(t, spans, 3, OpEncrypt, 1000)
+	if spans[0].err != nil || !errors.Is(spans[1].err, ErrStringNotInRadix) || spans[2].symbols != 3 {
+		t.Fatalf("spans %+v %+v %+v", *spans[0], *spans[1], *spans[2])
+	}
+}
+
+// nopTracer is a TraceHook that does nothing.
+type nopTracer struct{}
+
+func (nopTracer) StartOp(ctx context.Context, op string, symbols int) context.Context { return ctx }
+
+func (nopTracer) EndOp(ctx context.Context, err error) {}
+
+func BenchmarkTracing(b *testing.B) {
+	testVector := testVectors[0]
+	dst := make([]byte, len(testVector.plaintext))
+	for _, hook := range []struct {
+		name string
+		opts []Option
+	}{
+		{"NoTracer", nil},
+		{"Tracer", []Option{WithTracing(nopTracer{})}},
+	} {
+		c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak), hook.opts...)
+		if err != nil {
+			b.Fatalf("Unable to create cipher: %v", err)
+		}
+		b.Run(hook.name, func(b *testing.B) {
+			e := c.NewEncryptor()
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				e.Encrypt(dst, testVector.plaintext)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Layout of the tweaks built by TweakBuilder
+const (
+	// First byte of a tweak that holds the components themselves
+	tweakPlain = 0x01
+	// First byte of a tweak that holds a hash of the plain encoding
+	tweakHashed = 0x02
+
+	// Component tags
+	tagDomain   = 0x01
+	tagField    = 0x02
+	tagRecordID = 0x03
+	tagUint64   = 0x04
+
+	// Shortest tweak a hash is truncated to, the marker byte and 128 bits
+	minHashedTweakLen = 1 + 16
+)
+
+// A TweakBuilder builds tweaks from named parts, so that tweaks for different
+// purposes cannot be confused however their parts are chosen. The zero value is
+// an empty builder. Each method returns a new builder and leaves its receiver
+// unchanged, so a common prefix can be built once and extended per record, also
+// from several goroutines:
+//
+//	users := ff1.TweakBuilder{}.Domain("users").Field("ssn")
+//	tweak, err := users.RecordID(id).Build(maxTLen)
+//
+// The encoding is the byte 0x01 followed by the parts in the order they were
+// added, each as a one byte tag, the length of the value as a 4 byte big-endian
+// integer, and the value:
+//
+//	Domain    0x01  len  UTF-8 bytes of the string
+//	Field     0x02  len  UTF-8 bytes of the string
+//	RecordID  0x03  len  the bytes
+//	Uint64    0x04  8    the value as 8 bytes big-endian
+//
+// If the encoding is longer than the maximum tweak length, Build returns the byte
+// 0x02 followed by its SHA-256 hash instead, truncated to maxTLen-1 bytes if that is
+// shorter. The layout is fixed, so other implementations can build the same tweaks.
+type TweakBuilder struct {
+	buf []byte
+	err error
+}
+
+// Domain adds the name of the data set or application the tweak is for.
+func (b TweakBuilder) Domain(name string) TweakBuilder {
+	return b.add(tagDomain, []byte(name))
+}
+
+// Field adds the name of the field the tweak is for.
+func (b TweakBuilder) Field(name string) TweakBuilder {
+	return b.add(tagField, []byte(name))
+}
+
+// RecordID adds the identifier of a record.
+func (b TweakBuilder) RecordID(id []byte) TweakBuilder {
+	return b.add(tagRecordID, id)
+}
+
+// Uint64 adds a number, such as a version or a tenant number.
+func (b TweakBuilder) Uint64(v uint64) TweakBuilder {
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], v)
+	return b.add(tagUint64, value[:])
+}
+
+// add returns a builder with the part appended. The buffer is never shared with
+// b, so builders derived from the same prefix do not overwrite each other.
+func (b TweakBuilder) add(tag byte, value []byte) TweakBuilder {
+	if b.err != nil {
+		return b
+	}
+	if uint64(len(value)) > math.MaxUint32 {
+		return TweakBuilder{err: fmt.Errorf("tweak part of %d bytes is too long", len(value))}
+	}
+
+	start := b.buf
+	if len(start) == 0 {
+		start = []byte{tweakPlain}
+	}
+	buf := make([]byte, len(start), len(start)+5+len(value))
+	copy(buf, start)
+	buf = append(buf, tag, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(len(value)))
+	buf = append(buf, value...)
+	return TweakBuilder{buf: buf}
+}
+
+// Build returns the tweak, at most maxTLen bytes long. A longer encoding is
+// replaced by its hash truncated to maxTLen bytes, and an error is returned if
+// maxTLen is too short to hold at least 16 bytes of the hash.
+func (b TweakBuilder) Build(maxTLen int) ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	tweak := b.buf
+	if len(tweak) == 0 {
+		tweak = []byte{tweakPlain}
+	}
+	if len(tweak) <= maxTLen {
+		return append([]byte(nil), tweak...), nil
+	}
+
+	if maxTLen < minHashedTweakLen {
+		return nil, fmt.Errorf("tweak of %d bytes does not fit in %d bytes, and a hash needs at least %d", len(tweak), maxTLen, minHashedTweakLen)
+	}
+	sum := sha256.Sum256(tweak)
+	n := maxTLen
+	if n > 1+len(sum) {
+		n = 1 + len(sum)
+	}
+	hashed := make([]byte, n)
+	hashed[0] = tweakHashed
+	copy(hashed[1:], sum[:])
+	return hashed, nil
+}
+
+// BuildFor is Build with the maximum tweak length of c, for use with
+// EncryptWithTweak and DecryptWithTweak.
+func (b TweakBuilder) BuildFor(c Cipher) ([]byte, error) {
+	return b.Build(c.maxTLen)
+}
@@ -0,0 +1,201 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var userSSN = TweakBuilder{}.Domain("users").Field("ssn").RecordID([]byte{1, 2, 3}).Uint64(7)
+
+// The layout is part of the API, so the outputs are pinned
+func TestTweakBuilder(t *testing.T) {
+	tests := []struct {
+		builder TweakBuilder
+		maxTLen int
+		tweak   string
+	}{
+		{TweakBuilder{}, 0, ""},
+		{TweakBuilder{}, 1, "01"},
+		{TweakBuilder{}.Domain(""), 8, "010100000000"},
+		{TweakBuilder{}.Domain("users"), 16, "01010000000575736572 73"},
+		{TweakBuilder{}.Uint64(1 << 40), 16, "01 04 00000008 0000010000000000"},
+		{userSSN, 40, "01 0100000005 7573657273 0200000003 73736e 0300000003 010203 0400000008 0000000000000007"},
+		{userSSN, 39, "02 9228e2a14b38de4577daebc85a9f0581e40962288079fff4066574a511b570d4"},
+		{userSSN, 20, "02 9228e2a14b38de4577daebc85a9f0581e40962"},
+		{userSSN, 17, "02 9228e2a14b38de4577daebc85a9f0581"},
+	}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			expected := mustHex(string(bytes.ReplaceAll([]byte(test.tweak), []byte(" "), nil)))
+			tweak, err := test.builder.Build(test.maxTLen)
+			if test.tweak == "" {
+				if err == nil {
+					t.Fatalf("Build(%d) = %x, expected an error", test.maxTLen, tweak)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build(%d): %v", test.maxTLen, err)
+			}
+			if !bytes.Equal(tweak, expected) {
+				t.Fatalf("Build(%d) = %x expected %x", test.maxTLen, tweak, expected)
+			}
+		})
+	}
+}
+
+// Structures that plain concatenation would run together all give different tweaks,
+// whole and hashed
+func TestTweakBuilderAmbiguity(t *testing.T) {
+	builders := []TweakBuilder{
+		{},
+		TweakBuilder{}.Domain(""),
+		TweakBuilder{}.Domain("").Domain(""),
+		TweakBuilder{}.Domain("users").Field("ssn"),
+		TweakBuilder{}.Domain("user").Field("sssn"),
+		TweakBuilder{}.Domain("usersssn"),
+		TweakBuilder{}.Field("users").Domain("ssn"),
+		TweakBuilder{}.Field("ssn").Domain("users"),
+		TweakBuilder{}.Domain("users").RecordID([]byte("ssn")),
+		TweakBuilder{}.RecordID([]byte{}),
+		TweakBuilder{}.RecordID([]byte{0, 0, 0, 0, 0, 0, 0, 7}),
+		TweakBuilder{}.Uint64(7),
+		TweakBuilder{}.Domain("a").Domain("b"),
+		TweakBuilder{}.Domain("a\x02\x00\x00\x00\x01b"),
+	}
+
+	for _, maxTLen := range []int{1 << 10, minHashedTweakLen} {
+		seen := make(map[string]int)
+		for idx, builder := range builders {
+			tweak, err := builder.Build(maxTLen)
+			if err != nil {
+				t.Fatalf("builder %d: Build(%d): %v", idx, maxTLen, err)
+			}
+			if other, ok := seen[string(tweak)]; ok {
+				t.Fatalf("builders %d and %d both give %x", other, idx, tweak)
+			}
+			seen[string(tweak)] = idx
+		}
+	}
+
+	// A hashed tweak never equals a whole one
+	hashed, err := userSSN.Build(minHashedTweakLen)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if hashed[0] == tweakPlain {
+		t.Fatalf("hashed tweak %x starts like a whole one", hashed)
+	}
+}
+
+// Builders derived from one prefix do not affect each other or the prefix
+func TestTweakBuilderPrefix(t *testing.T) {
+	users := TweakBuilder{}.Domain("users")
+	before, _ := users.Build(64)
+
+	// Room to grow in place, which must not be used
+	a := users.RecordID([]byte("a"))
+	b := users.RecordID([]byte("b"))
+	after, _ := users.Build(64)
+	if !bytes.Equal(before, after) {
+		t.Fatalf("prefix changed from %x to %x", before, after)
+	}
+
+	tweakA, _ := a.Build(64)
+	tweakB, _ := b.Build(64)
+	expectedA, _ := TweakBuilder{}.Domain("users").RecordID([]byte("a")).Build(64)
+	if !bytes.Equal(tweakA, expectedA) || bytes.Equal(tweakA, tweakB) {
+		t.Fatalf("derived builders overwrote each other: %x, %x", tweakA, tweakB)
+	}
+
+	// The result is a copy
+	tweakA[0] = 0xff
+	if again, _ := a.Build(64); !bytes.Equal(again, expectedA) {
+		t.Fatalf("changing a built tweak changed the builder")
+	}
+}
+
+func TestTweakBuilderTruncation(t *testing.T) {
+	long := TweakBuilder{}.RecordID(make([]byte, 100))
+	whole, err := long.Build(1 << 10)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	sum := sha256.Sum256(whole)
+
+	for _, maxTLen := range []int{17, 20, 33, 34, 100} {
+		tweak, err := long.Build(maxTLen)
+		if err != nil {
+			t.Fatalf("Build(%d): %v", maxTLen, err)
+		}
+		n := maxTLen
+		if n > 1+sha256.Size {
+			n = 1 + sha256.Size
+		}
+		expected := append([]byte{tweakHashed}, sum[:n-1]...)
+		if !bytes.Equal(tweak, expected) {
+			t.Fatalf("Build(%d) = %x expected %x", maxTLen, tweak, expected)
+		}
+	}
+	for _, maxTLen := range []int{-1, 0, 1, 16} {
+		if tweak, err := long.Build(maxTLen); err == nil {
+			t.Fatalf("Build(%d) = %x, expected an error", maxTLen, tweak)
+		}
+	}
+}
+
+// A built tweak works with the per call tweak methods
+func TestTweakBuilderEncrypt(t *testing.T) {
+	key := mustHex(testVectors[0].key)
+	c, err := NewCipher(10, 32, key, nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	tweak, err := userSSN.BuildFor(c)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if hex.EncodeToString(tweak[:1]) != "02" {
+		t.Fatalf("expected the 40 byte tweak to be hashed to fit in 32 bytes, got %x", tweak)
+	}
+
+	withTweak, err := NewCipher(10, 32, key, tweak)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	plaintext := []byte("123456789")
+	ciphertext, err := c.EncryptWithTweak(plaintext, tweak)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if expected, _ := withTweak.Encrypt(plaintext); !reflect.DeepEqual(ciphertext, expected) {
+		t.Fatalf("EncryptWithTweak: got %s expected %s", ciphertext, expected)
+	}
+	if decrypted, err := c.DecryptWithTweak(ciphertext, tweak); err != nil || !reflect.DeepEqual(decrypted, plaintext) {
+		t.Fatalf("DecryptWithTweak: got %s, %v expected %s", decrypted, err, plaintext)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+)
+
+// ErrKeyUsageExceeded is returned by Encrypt once a Cipher created with
+// WithUsageLimit has used up its encryptions.
+var ErrKeyUsageExceeded = errors.New("key usage limit exceeded")
+
+// usageCounter counts the encryptions of a Cipher against its limit. It is
+// shared by all copies of the Cipher.
+type usageCounter struct {
+	// First so that it is 64-bit aligned for the atomic operations on 32-bit platforms.
+	used uint64
+
+	limit uint64
+}
+
+// take counts one encryption, or reports false if the limit has been reached.
+// The count never goes past the limit, however many goroutines race for the
+// last uses.
+func (u *usageCounter) take() bool {
+	for {
+		used := atomic.LoadUint64(&u.used)
+		if used >= u.limit {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&u.used, used, used+1) {
+			return true
+		}
+	}
+}
+
+// RemainingUses returns the number of encryptions left before the limit set with
+// WithUsageLimit is reached, or math.MaxUint64 for a Cipher without a limit.
+func (c Cipher) RemainingUses() uint64 {
+	if c.usage == nil {
+		return math.MaxUint64
+	}
+	return c.usage.limit - atomic.LoadUint64(&c.usage.used)
+}
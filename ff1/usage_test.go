@@ -0,0 +1,133 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUsageLimit(t *testing.T) {
+	const limit = 5
+	testVector := testVectors[0]
+	c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak), WithUsageLimit(limit))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	copied := c
+
+	if _, err := c.Encrypt([]byte("012345678x")); err != ErrStringNotInRadix {
+		t.Fatalf("got %v, expected %v", err, ErrStringNotInRadix)
+	}
+	for i := 0; i < limit; i++ {
+		if remaining := c.RemainingUses(); remaining != limit-uint64(i) {
+			t.Fatalf("RemainingUses() = %d expected %d", remaining, limit-i)
+		}
+		// Copies share the count, so alternate between them
+		x := c
+		if i%2 == 1 {
+			x = copied
+		}
+		ciphertext, err := x.Encrypt(testVector.plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt %d: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+			t.Fatalf("Encrypt: got %s expected %s", ciphertext, testVector.ciphertext)
+		}
+	}
+	if remaining := c.RemainingUses(); remaining != 0 {
+		t.Fatalf("RemainingUses() = %d expected 0", remaining)
+	}
+
+	if _, err := c.Encrypt(testVector.plaintext); err != ErrKeyUsageExceeded {
+		t.Fatalf("Encrypt: got %v, expected %v", err, ErrKeyUsageExceeded)
+	}
+	if _, err := copied.EncryptInto(nil, testVector.plaintext); err != ErrKeyUsageExceeded {
+		t.Fatalf("EncryptInto: got %v, expected %v", err, ErrKeyUsageExceeded)
+	}
+	if _, err := c.NewEncryptor().Encrypt(nil, testVector.plaintext); err != ErrKeyUsageExceeded {
+		t.Fatalf("Encryptor.Encrypt: got %v, expected %v", err, ErrKeyUsageExceeded)
+	}
+
+	// Decrypt is not limited
+	for i := 0; i < 2*limit; i++ {
+		plaintext, err := c.Decrypt(testVector.ciphertext)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if !reflect.DeepEqual(plaintext, testVector.plaintext) {
+			t.Fatalf("Decrypt: got %s expected %s", plaintext, testVector.plaintext)
+		}
+	}
+	if remaining := c.RemainingUses(); remaining != 0 {
+		t.Fatalf("RemainingUses() = %d expected 0", remaining)
+	}
+
+	unlimited, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	if remaining := unlimited.RemainingUses(); remaining != math.MaxUint64 {
+		t.Fatalf("RemainingUses() = %d expected %d", remaining, uint64(math.MaxUint64))
+	}
+}
+
+// Exactly limit encryptions succeed, however many goroutines compete; run with -race
+func TestUsageLimitConcurrent(t *testing.T) {
+	const limit = 1000
+	testVector := testVectors[0]
+	c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak), WithUsageLimit(limit))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	var succeeded, exceeded int64
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_, err := c.Encrypt(testVector.plaintext)
+				switch err {
+				case nil:
+					atomic.AddInt64(&succeeded, 1)
+				case ErrKeyUsageExceeded:
+					atomic.AddInt64(&exceeded, 1)
+				default:
+					t.Errorf("Encrypt: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != limit || exceeded != 16*100-limit {
+		t.Fatalf("%d encryptions succeeded and %d were refused, expected %d and %d", succeeded, exceeded, limit, 16*100-limit)
+	}
+	if remaining := c.RemainingUses(); remaining != 0 {
+		t.Fatalf("RemainingUses() = %d expected 0", remaining)
+	}
+}
@@ -0,0 +1,375 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/Tensai75/go-fpe-bytes/fpeUtils"
+)
+
+var (
+	// ErrVectorMismatch is returned by Vector.Check if the Cipher does not give the
+	// ciphertext of the vector, or rejects it for another reason than the vector's.
+	ErrVectorMismatch = errors.New("result does not match the vector")
+
+	// ErrVectorAccepted is returned by Vector.Check if the Cipher accepts the
+	// inputs of a vector that must be rejected.
+	ErrVectorAccepted = errors.New("inputs of an invalid vector are accepted")
+)
+
+// The expected results of a Vector.
+const (
+	// ResultValid vectors must be accepted and give their ciphertext.
+	ResultValid = "valid"
+	// ResultInvalid vectors must be rejected for their reason.
+	ResultInvalid = "invalid"
+	// ResultAcceptable vectors may be rejected for their reason or accepted, in
+	// which case they must give their ciphertext.
+	ResultAcceptable = "acceptable"
+)
+
+// The reasons a Vector is rejected for.
+const (
+	ReasonKeyLength     = "key-length"      // the key is not 16, 24 or 32 bytes
+	ReasonRadix         = "radix"           // the alphabet has fewer than 2 unique bytes
+	ReasonTweakTooLong  = "tweak-too-long"  // the tweak is longer than MaxTLen
+	ReasonNotInAlphabet = "not-in-alphabet" // the message has a byte outside the alphabet
+	ReasonInputTooShort = "input-too-short" // the message is shorter than the minimum length
+
+	// ReasonDuplicateSymbol is for alphabets that repeat a byte, which this package
+	// ignores rather than rejects, so vectors with it can only be acceptable.
+	ReasonDuplicateSymbol = "duplicate-symbol"
+)
+
+// reasonMatches tells whether an error is one a vector may be rejected for.
+var reasonMatches = map[string]func(error) bool{
+	ReasonKeyLength:       func(err error) bool { return errors.Is(err, ErrKeyLengthInvalid) },
+	ReasonRadix:           func(err error) bool { return errors.Is(err, fpeUtils.ErrRadixOutOfRange) },
+	ReasonTweakTooLong:    func(err error) bool { return errors.Is(err, ErrTweakLengthInvalid) },
+	ReasonNotInAlphabet:   func(err error) bool { return errors.Is(err, ErrStringNotInRadix) },
+	ReasonInputTooShort:   func(err error) bool { return errors.Is(err, errLengthBounds) },
+	ReasonDuplicateSymbol: func(error) bool { return false },
+}
+
+// A Vector is a test vector: a key, alphabet and tweak, a plaintext, and the
+// ciphertext FF1 gives for them. In JSON all byte strings are hex encoded, so
+// that binary alphabets survive:
+//
+//	{"name": "...", "radix": 10, "alphabet": "3031...", "key": "2b7e...",
+//	 "tweak": "", "plaintext": "3031...", "ciphertext": "3234..."}
+//
+// Radix is the number of unique bytes in the alphabet and only informative.
+//
+// Vectors can also describe inputs that must be rejected, as negative vectors:
+// "result" is then "invalid" or "acceptable", and "reason" one of the Reason
+// constants. "maxTweakLen", "result" and "reason" are left out for valid vectors
+// whose maximum tweak length is that of their tweak.
+type Vector struct {
+	Name       string
+	Radix      int
+	Alphabet   []byte
+	Key        []byte
+	Tweak      []byte
+	Plaintext  []byte
+	Ciphertext []byte
+
+	// MaxTLen is the maximum tweak length of the Cipher, the length of Tweak if 0.
+	MaxTLen int
+
+	// Result is one of ResultValid, ResultInvalid and ResultAcceptable, an empty
+	// Result is valid. Reason is one of the Reason constants for the others.
+	Result string
+	Reason string
+}
+
+type jsonVector struct {
+	Name       string `json:"name"`
+	Radix      int    `json:"radix"`
+	Alphabet   string `json:"alphabet"`
+	Key        string `json:"key"`
+	Tweak      string `json:"tweak"`
+	Plaintext  string `json:"plaintext"`
+	Ciphertext string `json:"ciphertext"`
+	MaxTLen    int    `json:"maxTweakLen,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// MarshalJSON encodes the vector in the schema described for Vector.
+func (v Vector) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonVector{
+		Name:       v.Name,
+		Radix:      v.Radix,
+		Alphabet:   hex.EncodeToString(v.Alphabet),
+		Key:        hex.EncodeToString(v.Key),
+		Tweak:      hex.EncodeToString(v.Tweak),
+		Plaintext:  hex.EncodeToString(v.Plaintext),
+		Ciphertext: hex.EncodeToString(v.Ciphertext),
+		MaxTLen:    v.MaxTLen,
+		Result:     v.Result,
+		Reason:     v.Reason,
+	})
+}
+
+// UnmarshalJSON decodes a vector in the schema described for Vector.
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var j jsonVector
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var out Vector
+	out.Name, out.Radix = j.Name, j.Radix
+	out.MaxTLen, out.Result, out.Reason = j.MaxTLen, j.Result, j.Reason
+	for _, field := range []struct {
+		name string
+		src  string
+		dst  *[]byte
+	}{
+		{"alphabet", j.Alphabet, &out.Alphabet},
+		{"key", j.Key, &out.Key},
+		{"tweak", j.Tweak, &out.Tweak},
+		{"plaintext", j.Plaintext, &out.Plaintext},
+		{"ciphertext", j.Ciphertext, &out.Ciphertext},
+	} {
+		b, err := hex.DecodeString(field.src)
+		if err != nil {
+			return fmt.Errorf("vector %q: %s: %w", j.Name, field.name, err)
+		}
+		*field.dst = b
+	}
+	*v = out
+	return nil
+}
+
+// WriteVectors writes vectors to w as a JSON array, one vector per line, so that
+// files of vectors give readable diffs.
+func WriteVectors(w io.Writer, vectors []Vector) error {
+	var buf bytes.Buffer
+	buf.WriteString("[\n")
+	for i, v := range vectors {
+		line, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		if i < len(vectors)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("]\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ReadVectors reads a JSON array of vectors from r, such as WriteVectors writes.
+func ReadVectors(r io.Reader) ([]Vector, error) {
+	var vectors []Vector
+	if err := json.NewDecoder(r).Decode(&vectors); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// Check runs the vector. For a valid vector it encrypts the plaintext and
+// decrypts the ciphertext, and returns ErrVectorMismatch if either does not give
+// the other. For an invalid vector creating the Cipher, or else both encrypting
+// the plaintext and decrypting the ciphertext, must fail with an error of the
+// vector's reason: Check returns ErrVectorAccepted if one of them succeeds and
+// ErrVectorMismatch if one fails for another reason. An acceptable vector passes
+// either way, as long as a rejection is for its reason and an acceptance gives
+// its ciphertext.
+func (v Vector) Check() error {
+	var matches func(error) bool
+	switch v.Result {
+	case "", ResultValid:
+	case ResultInvalid, ResultAcceptable:
+		if matches = reasonMatches[v.Reason]; matches == nil {
+			return fmt.Errorf("vector %q: unknown reason %q", v.Name, v.Reason)
+		}
+	default:
+		return fmt.Errorf("vector %q: unknown result %q", v.Name, v.Result)
+	}
+
+	// rejected reports whether err rejects the vector as expected, and returns
+	// the error of Check otherwise
+	rejected := func(err error) (bool, error) {
+		switch {
+		case err == nil:
+			return false, nil
+		case matches == nil:
+			return false, fmt.Errorf("vector %q: %w", v.Name, err)
+		case !matches(err):
+			return false, fmt.Errorf("vector %q: %w: rejected with %q, expected %s", v.Name, ErrVectorMismatch, err, v.Reason)
+		}
+		return true, nil
+	}
+
+	maxTLen := v.MaxTLen
+	if maxTLen == 0 {
+		maxTLen = len(v.Tweak)
+	}
+	c, err := NewCipherWithAlphabet(v.Alphabet, maxTLen, v.Key, v.Tweak)
+	if ok, err := rejected(err); ok || err != nil {
+		return err
+	}
+
+	Y, err := c.Encrypt(v.Plaintext)
+	encRejected, err := rejected(err)
+	if err != nil {
+		return err
+	}
+	if !encRejected && v.Result != ResultInvalid && !bytes.Equal(Y, v.Ciphertext) {
+		return fmt.Errorf("vector %q: %w: encrypting gives %x", v.Name, ErrVectorMismatch, Y)
+	}
+	X, err := c.Decrypt(v.Ciphertext)
+	decRejected, err := rejected(err)
+	if err != nil {
+		return err
+	}
+	if !decRejected && v.Result != ResultInvalid && !bytes.Equal(X, v.Plaintext) {
+		return fmt.Errorf("vector %q: %w: decrypting gives %x", v.Name, ErrVectorMismatch, X)
+	}
+
+	if v.Result == ResultInvalid && (!encRejected || !decRejected) {
+		return fmt.Errorf("vector %q: %w, expected %s", v.Name, ErrVectorAccepted, v.Reason)
+	}
+	return nil
+}
+
+// GenSpec describes the vectors GenerateVectors makes: one for each combination
+// of alphabet, key length, message length and tweak length, in that order of
+// nesting. Message lengths below the minimum of an alphabet are left out.
+type GenSpec struct {
+	Alphabets [][]byte
+	KeyLens   []int
+	Lengths   []int
+	TweakLens []int
+}
+
+// DefaultGenSpec returns the spec of the vectors in testdata/vectors.json: radix
+// 2, 10, 36, the legacy radix 62 alphabet and all 256 byte values, AES-128, 192
+// and 256 keys, messages of 1 to 300 numerals, and tweaks up to 256 bytes.
+func DefaultGenSpec() GenSpec {
+	allBytes := make([]byte, 256)
+	for i := range allBytes {
+		allBytes[i] = byte(i)
+	}
+	return GenSpec{
+		Alphabets: [][]byte{
+			[]byte("01"),
+			[]byte("0123456789"),
+			[]byte(legacyAlphabet[:36]),
+			[]byte(legacyAlphabet),
+			allBytes,
+		},
+		KeyLens:   []int{16, 24, 32},
+		Lengths:   []int{1, 7, 19, 64, 300},
+		TweakLens: []int{0, 13, 256},
+	}
+}
+
+// GenerateVectors makes the vectors of spec, deriving their inputs from seed, and
+// computes their ciphertexts. The same seed and spec always give the same vectors,
+// and other implementations can derive the same inputs:
+//
+// The vectors are numbered from 0 in the order given by GenSpec. The input named
+// label of vector n is the start of the concatenated blocks
+//
+//	SHA-256(seed || n || label || i),  i = 0, 1, 2, ...
+//
+// where seed is 8 bytes, n and i 4 bytes, all big-endian, and label is "key",
+// "tweak" or "plaintext" in ASCII. Key and tweak are taken as they are, numeral j
+// of the plaintext is the byte at position j of its stream modulo the radix.
+// Alphabets are reduced to their unique bytes first.
+func GenerateVectors(seed int64, spec GenSpec) ([]Vector, error) {
+	var vectors []Vector
+	for _, alphabet := range spec.Alphabets {
+		codec, err := fpeUtils.NewCodec(alphabet)
+		if err != nil {
+			return nil, err
+		}
+		alphabet = codec.Alphabet()
+		radix := len(alphabet)
+		minLen, err := fpeUtils.MinLengthForDomain(uint64(radix), big.NewInt(feistelMin))
+		if err != nil {
+			return nil, err
+		}
+		for _, keyLen := range spec.KeyLens {
+			for _, length := range spec.Lengths {
+				if length < minLen {
+					continue
+				}
+				for _, tweakLen := range spec.TweakLens {
+					n := uint32(len(vectors))
+					v := Vector{
+						Name:      fmt.Sprintf("radix%d-aes%d-len%d-tweak%d", radix, keyLen*8, length, tweakLen),
+						Radix:     radix,
+						Alphabet:  alphabet,
+						Key:       vectorStream(seed, n, "key", keyLen),
+						Tweak:     vectorStream(seed, n, "tweak", tweakLen),
+						Plaintext: vectorStream(seed, n, "plaintext", length),
+					}
+					for j, b := range v.Plaintext {
+						v.Plaintext[j] = alphabet[int(b)%radix]
+					}
+					c, err := NewCipherWithAlphabet(alphabet, tweakLen, v.Key, v.Tweak)
+					if err != nil {
+						return nil, fmt.Errorf("vector %q: %w", v.Name, err)
+					}
+					if v.Ciphertext, err = c.Encrypt(v.Plaintext); err != nil {
+						return nil, fmt.Errorf("vector %q: %w", v.Name, err)
+					}
+					vectors = append(vectors, v)
+				}
+			}
+		}
+	}
+	return vectors, nil
+}
+
+// vectorStream returns the first length bytes of the stream described for
+// GenerateVectors.
+func vectorStream(seed int64, n uint32, label string, length int) []byte {
+	prefix := make([]byte, 12, 12+len(label))
+	binary.BigEndian.PutUint64(prefix, uint64(seed))
+	binary.BigEndian.PutUint32(prefix[8:], n)
+	prefix = append(prefix, label...)
+
+	out := make([]byte, 0, length+sha256.Size)
+	var counter [4]byte
+	for i := uint32(0); len(out) < length; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+		h := sha256.New()
+		h.Write(prefix)
+		h.Write(counter[:])
+		out = h.Sum(out)
+	}
+	return out[:length]
+}
@@ -0,0 +1,186 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateVectors = flag.Bool("update", false, "regenerate testdata/vectors.json")
+
+// vectorSeed is the seed of testdata/vectors.json
+const vectorSeed = 20170101
+
+func TestGenerateVectorsGolden(t *testing.T) {
+	vectors, err := GenerateVectors(vectorSeed, DefaultGenSpec())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteVectors(&buf, vectors); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	golden := filepath.Join("testdata", "vectors.json")
+	if *updateVectors {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("Vectors generated from seed %d differ from %s, run the test with -update if the change is intended", vectorSeed, golden)
+	}
+}
+
+func TestVectorsFile(t *testing.T) {
+	checkVectorsFile(t, "vectors.json")
+}
+
+// The negative vectors cover every check of NewCipherWithAlphabet, Encrypt and
+// Decrypt apart from ErrInputTooLong, which needs messages of 2^32 numerals
+func TestNegativeVectors(t *testing.T) {
+	vectors := checkVectorsFile(t, "negative.json")
+	reasons := make(map[string]bool)
+	for _, v := range vectors {
+		if v.Result == ResultValid || v.Result == "" {
+			t.Errorf("Vector %q of negative.json is valid", v.Name)
+		}
+		reasons[v.Reason] = true
+	}
+	for reason := range reasonMatches {
+		if !reasons[reason] {
+			t.Errorf("No vector of negative.json has reason %s", reason)
+		}
+	}
+}
+
+// checkVectorsFile checks all vectors of a file in testdata and returns them
+func checkVectorsFile(t *testing.T, name string) []Vector {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer f.Close()
+	vectors, err := ReadVectors(f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(vectors) == 0 {
+		t.Fatalf("No vectors read")
+	}
+	for _, v := range vectors {
+		if err := v.Check(); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+	return vectors
+}
+
+func TestVectorCheck(t *testing.T) {
+	for _, testVector := range testVectors {
+		v := Vector{
+			Alphabet:   []byte(legacyAlphabet[:testVector.radix]),
+			Key:        mustHex(testVector.key),
+			Tweak:      mustHex(testVector.tweak),
+			Plaintext:  testVector.plaintext,
+			Ciphertext: testVector.ciphertext,
+		}
+		if err := v.Check(); err != nil {
+			t.Errorf("%v", err)
+		}
+		v.Ciphertext = append([]byte{}, v.Ciphertext...)
+		v.Ciphertext[0] ^= 1
+		if err := v.Check(); !errors.Is(err, ErrVectorMismatch) {
+			t.Errorf("Changed ciphertext: got %v, expected ErrVectorMismatch", err)
+		}
+	}
+}
+
+func TestVectorCheckNegative(t *testing.T) {
+	valid := Vector{
+		Alphabet:   []byte("0123456789"),
+		Key:        mustHex(testVectors[0].key),
+		Plaintext:  testVectors[0].plaintext,
+		Ciphertext: testVectors[0].ciphertext,
+	}
+	for idx, testCase := range []struct {
+		change   func(v *Vector)
+		expected error
+	}{
+		// Accepted although it must be rejected
+		{func(v *Vector) { v.Result, v.Reason = ResultInvalid, ReasonNotInAlphabet }, ErrVectorAccepted},
+		{func(v *Vector) { v.Result, v.Reason = ResultInvalid, ReasonKeyLength }, ErrVectorAccepted},
+		// Rejected for another reason
+		{func(v *Vector) { v.Result, v.Reason, v.Key = ResultInvalid, ReasonRadix, v.Key[:8] }, ErrVectorMismatch},
+		{func(v *Vector) { v.Result, v.Reason, v.Plaintext = ResultInvalid, ReasonNotInAlphabet, v.Plaintext[:1] }, ErrVectorMismatch},
+		{func(v *Vector) { v.Plaintext = v.Plaintext[:1] }, errLengthBounds},
+		// Only one direction rejected
+		{func(v *Vector) {
+			v.Result, v.Reason, v.Plaintext = ResultInvalid, ReasonNotInAlphabet, []byte("012345678a")
+		}, ErrVectorAccepted},
+		// Acceptable either way
+		{func(v *Vector) { v.Result, v.Reason = ResultAcceptable, ReasonInputTooShort }, nil},
+		{func(v *Vector) {
+			v.Result, v.Reason, v.Plaintext, v.Ciphertext = ResultAcceptable, ReasonInputTooShort, v.Plaintext[:1], v.Ciphertext[:1]
+		}, nil},
+		{func(v *Vector) {
+			v.Result, v.Reason, v.Ciphertext = ResultAcceptable, ReasonInputTooShort, []byte("0000000000")
+		}, ErrVectorMismatch},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			v := valid
+			testCase.change(&v)
+			err := v.Check()
+			if testCase.expected == nil && err != nil || !errors.Is(err, testCase.expected) {
+				t.Fatalf("Got %v, expected %v", err, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateVectorsSeed(t *testing.T) {
+	spec := GenSpec{Alphabets: [][]byte{[]byte("0123456789")}, KeyLens: []int{16}, Lengths: []int{1, 10}, TweakLens: []int{4}}
+	a, err := GenerateVectors(1, spec)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	b, err := GenerateVectors(2, spec)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// Length 1 is below the minimum for radix 10
+	if len(a) != 1 || a[0].Name != "radix10-aes128-len10-tweak4" {
+		t.Fatalf("Got %d vectors, expected one of length 10", len(a))
+	}
+	if bytes.Equal(a[0].Key, b[0].Key) || bytes.Equal(a[0].Plaintext, b[0].Plaintext) {
+		t.Fatalf("Seeds 1 and 2 give the same inputs")
+	}
+}
@@ -0,0 +1,64 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// ErrRoundTripMismatch is returned by Encrypt, for a Cipher created with
+// WithVerifyRoundTrip, if decrypting the ciphertext does not give back the plaintext.
+// It means an internal fault, the ciphertext is withheld.
+var ErrRoundTripMismatch = errors.New("ciphertext does not decrypt to the plaintext")
+
+// corruptHook, if set, is called with each ciphertext that encryptVerified is about
+// to check. Tests use it to inject a fault.
+var corruptHook func(ciphertext []byte)
+
+// encryptVerified encrypts X into a buffer of its own, decrypts that with separate
+// working memory, and only copies the ciphertext to dst if the result equals X.
+// X is still intact for the comparison even if dst is X itself.
+func (c Cipher) encryptVerified(sc *scratch, dst, X, tweak []byte) ([]byte, error) {
+	ciphertext, err := c.cryptOnce(sc, nil, X, tweak, true)
+	if err != nil {
+		return nil, err
+	}
+	if corruptHook != nil {
+		corruptHook(ciphertext)
+	}
+
+	// A nil scratch takes a different one from the pool, as sc is not released yet
+	plaintext, err := c.cryptOnce(nil, nil, ciphertext, tweak, false)
+	match := err == nil && subtle.ConstantTimeCompare(plaintext, X) == 1
+	for i := range plaintext {
+		plaintext[i] = 0
+	}
+	if !match {
+		return nil, ErrRoundTripMismatch
+	}
+
+	if cap(dst) < len(ciphertext) {
+		return ciphertext, nil
+	}
+	dst = dst[:len(ciphertext)]
+	copy(dst, ciphertext)
+	return dst, nil
+}
@@ -0,0 +1,126 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package ff1
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyRoundTrip(t *testing.T) {
+	for idx, testVector := range testVectors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), mustHex(testVector.tweak), WithVerifyRoundTrip())
+			if err != nil {
+				t.Fatalf("Unable to create cipher: %v", err)
+			}
+
+			ciphertext, err := c.Encrypt(testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+				t.Fatalf("Encrypt: got %s expected %s", ciphertext, testVector.ciphertext)
+			}
+
+			// In place, where X is overwritten by the ciphertext
+			buf := append([]byte(nil), testVector.plaintext...)
+			ciphertext, err = c.EncryptInto(buf, buf)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) || &ciphertext[0] != &buf[0] {
+				t.Fatalf("EncryptInto: got %s expected %s in dst", ciphertext, testVector.ciphertext)
+			}
+
+			ciphertext, err = c.NewEncryptor().Encrypt(nil, testVector.plaintext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !reflect.DeepEqual(ciphertext, testVector.ciphertext) {
+				t.Fatalf("Encryptor.Encrypt: got %s expected %s", ciphertext, testVector.ciphertext)
+			}
+		})
+	}
+}
+
+// A ciphertext corrupted between encryption and the check is not returned
+func TestVerifyRoundTripMismatch(t *testing.T) {
+	testVector := testVectors[0]
+	c, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), nil, WithVerifyRoundTrip())
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+
+	corruptHook = func(ciphertext []byte) {
+		// Still a valid numeral string, so the decryption itself succeeds
+		if ciphertext[0] == '0' {
+			ciphertext[0] = '1'
+		} else {
+			ciphertext[0] = '0'
+		}
+	}
+	defer func() { corruptHook = nil }()
+
+	if _, err := c.Encrypt(testVector.plaintext); err != ErrRoundTripMismatch {
+		t.Fatalf("Encrypt: got %v, expected %v", err, ErrRoundTripMismatch)
+	}
+	buf := append([]byte(nil), testVector.plaintext...)
+	if _, err := c.EncryptInto(buf, buf); err != ErrRoundTripMismatch {
+		t.Fatalf("EncryptInto: got %v, expected %v", err, ErrRoundTripMismatch)
+	}
+	if !reflect.DeepEqual(buf, testVector.plaintext) {
+		t.Fatalf("EncryptInto wrote %s to dst despite the mismatch", buf)
+	}
+
+	// Without the option the corruption goes unnoticed
+	unverified, err := NewCipher(testVector.radix, 16, mustHex(testVector.key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	if _, err := unverified.Encrypt(testVector.plaintext); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+}
+
+func BenchmarkVerifyRoundTrip(b *testing.B) {
+	key := mustHex(testVectors[0].key)
+	X := []byte("xs8a0azh2avyalyzuwdxs8a0azh2avyalyzuwd")
+
+	for _, verify := range []bool{false, true} {
+		var opts []Option
+		name := "Off"
+		if verify {
+			opts = append(opts, WithVerifyRoundTrip())
+			name = "On"
+		}
+		c, err := NewCipher(36, 0, key, nil, opts...)
+		if err != nil {
+			b.Fatalf("Unable to create cipher: %v", err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				c.Encrypt(X)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package fixedwidth encrypts fields of fixed-width records, such as mainframe
+// extracts described by a copybook, in place.
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// Trim tells where the padding of a field is.
+type Trim int
+
+const (
+	// TrimNone encrypts the whole field.
+	TrimNone Trim = iota
+	// TrimRight strips padding after the content, as of left-aligned text.
+	TrimRight
+	// TrimLeft strips padding before the content, as of zero-padded numbers.
+	TrimLeft
+)
+
+// maxWalk bounds the re-encryptions of a padded field, see cryptField. Each is
+// needed with a probability of 1/radix, so the bound is only reached by a fault.
+const maxWalk = 1000
+
+var (
+	// ErrFieldTooShort is returned, in a FieldError, for a field whose content
+	// without padding is shorter than the MinLen of its cipher
+	ErrFieldTooShort = errors.New("field content is shorter than the minimum length")
+
+	// ErrRecordLength is returned for a record of another length than the layout's
+	ErrRecordLength = errors.New("record length does not match the layout")
+)
+
+// A Field is a field of a record.
+type Field struct {
+	Name   string
+	Offset int
+	Length int
+	Cipher *ff1.Cipher
+
+	// Pad is the padding byte, such as ' ' or '0', stripped as Trim says before
+	// encrypting and kept where it was. Fields that only hold padding are left as
+	// they are.
+	Pad  byte
+	Trim Trim
+}
+
+// A Layout describes the records of a file.
+type Layout struct {
+	// RecordLen is the length of a record, not counting Terminator
+	RecordLen int
+
+	// Terminator follows every record in the files of Process, such as "\n", and
+	// is left as it is. It may be empty.
+	Terminator []byte
+
+	Fields []Field
+}
+
+// A FieldError is the failure of one field.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that the fields of l have ciphers, lie within the record and do
+// not overlap.
+func (l Layout) Validate() error {
+	fields := append([]Field{}, l.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	for i, f := range fields {
+		switch {
+		case f.Cipher == nil:
+			return fmt.Errorf("fixedwidth: field %s has no cipher", f.Name)
+		case f.Offset < 0 || f.Length <= 0 || f.Offset > l.RecordLen-f.Length:
+			return fmt.Errorf("fixedwidth: field %s at %d with length %d is not within the record of %d bytes", f.Name, f.Offset, f.Length, l.RecordLen)
+		case f.Trim != TrimNone && f.Trim != TrimLeft && f.Trim != TrimRight:
+			return fmt.Errorf("fixedwidth: field %s has an unknown trim %d", f.Name, f.Trim)
+		case i > 0 && fields[i-1].Offset+fields[i-1].Length > f.Offset:
+			return fmt.Errorf("fixedwidth: fields %s and %s overlap", fields[i-1].Name, f.Name)
+		}
+	}
+	return nil
+}
+
+// EncryptRecord encrypts the fields of layout in record, which must be
+// layout.RecordLen bytes long, in place. The layout must be valid, see Validate.
+// Errors of a field are returned as a *FieldError, the record is then partly
+// encrypted.
+func EncryptRecord(layout Layout, record []byte) error {
+	return cryptRecord(layout, record, true)
+}
+
+// DecryptRecord is the inverse of EncryptRecord.
+func DecryptRecord(layout Layout, record []byte) error {
+	return cryptRecord(layout, record, false)
+}
+
+func cryptRecord(layout Layout, record []byte, encrypt bool) error {
+	if len(record) != layout.RecordLen {
+		return fmt.Errorf("fixedwidth: %w: %d bytes instead of %d", ErrRecordLength, len(record), layout.RecordLen)
+	}
+	for _, f := range layout.Fields {
+		if err := cryptField(f, record[f.Offset:f.Offset+f.Length], encrypt); err != nil {
+			return &FieldError{Field: f.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// cryptField encrypts or decrypts the content of field in place.
+//
+// The ciphertext of padded content may itself start or end with the padding byte,
+// if it is in the alphabet, and would then lose bytes when stripped on decryption.
+// Encryption is repeated on the ciphertext until it does not, which is a
+// permutation of the contents without padding at that end (cycle walking), and
+// decryption likewise until the plaintext does not.
+func cryptField(f Field, field []byte, encrypt bool) error {
+	content := field
+	switch f.Trim {
+	case TrimRight:
+		content = bytes.TrimRight(field, string(f.Pad))
+	case TrimLeft:
+		content = field[len(field)-len(bytes.TrimLeft(field, string(f.Pad))):]
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	if len(content) < f.Cipher.MinLen() {
+		return fmt.Errorf("%w: %d bytes without padding, at least %d are needed", ErrFieldTooShort, len(content), f.Cipher.MinLen())
+	}
+
+	padded := func() bool {
+		switch f.Trim {
+		case TrimRight:
+			return content[len(content)-1] == f.Pad
+		case TrimLeft:
+			return content[0] == f.Pad
+		}
+		return false
+	}
+	for i := 0; i < maxWalk; i++ {
+		var err error
+		if encrypt {
+			_, err = f.Cipher.EncryptInto(content, content)
+		} else {
+			_, err = f.Cipher.DecryptInto(content, content)
+		}
+		if err != nil {
+			return err
+		}
+		if !padded() {
+			return nil
+		}
+	}
+	return fmt.Errorf("content still padded after %d rounds of cycle walking", maxWalk)
+}
+
+// Process reads records of layout from r, each followed by layout.Terminator,
+// encrypts or decrypts their fields, and writes them to w. It works on a buffer
+// of records at a time, so files of any size can be processed. Errors carry the
+// number of the record, starting at 1; a last record that is incomplete is an
+// error wrapping ErrRecordLength.
+func Process(r io.Reader, w io.Writer, layout Layout, decrypt bool) error {
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+	size := layout.RecordLen + len(layout.Terminator)
+	if size <= 0 {
+		return fmt.Errorf("fixedwidth: %w: empty records", ErrRecordLength)
+	}
+	perBuffer := 64 * 1024 / size
+	if perBuffer < 1 {
+		perBuffer = 1
+	}
+	buf := make([]byte, perBuffer*size)
+
+	record := 0
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("fixedwidth: %w", err)
+		}
+		for off := 0; off < n; off += size {
+			record++
+			if n-off < size {
+				return fmt.Errorf("fixedwidth: record %d: %w: %d bytes instead of %d", record, ErrRecordLength, n-off, size)
+			}
+			rec := buf[off : off+layout.RecordLen]
+			if !bytes.Equal(buf[off+layout.RecordLen:off+size], layout.Terminator) {
+				return fmt.Errorf("fixedwidth: record %d: %w: no terminator after %d bytes", record, ErrRecordLength, layout.RecordLen)
+			}
+			if err := cryptRecord(layout, rec, !decrypt); err != nil {
+				return fmt.Errorf("fixedwidth: record %d: %w", record, err)
+			}
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return fmt.Errorf("fixedwidth: %w", err)
+		}
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,241 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+func newCipher(t testing.TB, alphabet string) *ff1.Cipher {
+	t.Helper()
+	c, err := ff1.NewCipherWithAlphabet([]byte(alphabet), 0, []byte("0123456789abcdef"), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	return &c
+}
+
+const (
+	digits  = "0123456789"
+	letters = " ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// testLayout is an account number, a space-padded name and a zero-padded
+// amount, with a field between them left alone
+func testLayout(t testing.TB) Layout {
+	return Layout{
+		RecordLen:  40,
+		Terminator: []byte("\n"),
+		Fields: []Field{
+			{Name: "account", Offset: 0, Length: 10, Cipher: newCipher(t, digits)},
+			{Name: "name", Offset: 10, Length: 20, Cipher: newCipher(t, letters), Pad: ' ', Trim: TrimRight},
+			{Name: "amount", Offset: 32, Length: 8, Cipher: newCipher(t, digits), Pad: '0', Trim: TrimLeft},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := newCipher(t, digits)
+	for idx, testCase := range []struct {
+		fields   []Field
+		expected string
+	}{
+		{[]Field{{Name: "a", Offset: 0, Length: 10, Cipher: c}, {Name: "b", Offset: 10, Length: 10, Cipher: c}}, ""},
+		{[]Field{{Name: "b", Offset: 10, Length: 10, Cipher: c}, {Name: "a", Offset: 0, Length: 11, Cipher: c}}, "fields a and b overlap"},
+		{[]Field{{Name: "a", Offset: 0, Length: 20, Cipher: c}, {Name: "b", Offset: 5, Length: 2, Cipher: c}}, "fields a and b overlap"},
+		{[]Field{{Name: "a", Offset: 15, Length: 10, Cipher: c}}, "not within the record"},
+		{[]Field{{Name: "a", Offset: -1, Length: 10, Cipher: c}}, "not within the record"},
+		{[]Field{{Name: "a", Offset: 0, Length: 0, Cipher: c}}, "not within the record"},
+		{[]Field{{Name: "a", Offset: 0, Length: 10}}, "has no cipher"},
+		{[]Field{{Name: "a", Offset: 0, Length: 10, Cipher: c, Trim: 3}}, "unknown trim"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			err := Layout{RecordLen: 20, Fields: testCase.fields}.Validate()
+			if testCase.expected == "" && err != nil || testCase.expected != "" && (err == nil || !strings.Contains(err.Error(), testCase.expected)) {
+				t.Fatalf("Got %v, expected %q", err, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestEncryptRecord(t *testing.T) {
+	layout := testLayout(t)
+	for idx, record := range []string{
+		"0123456789JOHN SMITH          XX00012345",
+		"9999999999A B                 XX99999999",
+		"0000000000                    XX00000000", // blank padded fields
+		"4111111111ALEXANDRA VON HUMBOLXX10000000", // a full field
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			rec := []byte(record)
+			if err := EncryptRecord(layout, rec); err != nil {
+				t.Fatalf("%v", err)
+			}
+			// The padding and the unencrypted bytes stay where they were
+			name := strings.TrimRight(record[10:30], " ")
+			if encName := string(rec[10:30]); strings.TrimRight(encName, " ") != encName[:len(name)] || len(strings.TrimRight(encName, " ")) != len(name) {
+				t.Fatalf("Name %q encrypted to %q", record[10:30], encName)
+			}
+			amount := strings.TrimLeft(record[32:40], "0")
+			if encAmount := string(rec[32:40]); len(strings.TrimLeft(encAmount, "0")) != len(amount) {
+				t.Fatalf("Amount %q encrypted to %q", record[32:40], encAmount)
+			}
+			if string(rec[30:32]) != "XX" {
+				t.Fatalf("Unencrypted bytes changed to %q", rec[30:32])
+			}
+			if name != "" && string(rec[10:30]) == record[10:30] {
+				t.Fatalf("Name %q not encrypted", name)
+			}
+
+			if err := DecryptRecord(layout, rec); err != nil {
+				t.Fatalf("%v", err)
+			}
+			if string(rec) != record {
+				t.Fatalf("Got %q, expected %q", rec, record)
+			}
+		})
+	}
+}
+
+// Padding bytes in the alphabet are stripped and restored correctly, which needs
+// cycle walking for about one in radix values
+func TestPaddingInAlphabet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for idx, field := range []Field{
+		{Name: "binary", Length: 16, Cipher: newCipher(t, "01"), Pad: '0', Trim: TrimLeft},
+		{Name: "binary", Length: 16, Cipher: newCipher(t, "01"), Pad: '1', Trim: TrimRight},
+		{Name: "digits", Length: 6, Cipher: newCipher(t, digits), Pad: '0', Trim: TrimLeft},
+		{Name: "letters", Length: 6, Cipher: newCipher(t, letters), Pad: ' ', Trim: TrimRight},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			layout := Layout{RecordLen: field.Length, Fields: []Field{field}}
+			alphabet := field.Cipher.Alphabet()
+			for i := 0; i < 300; i++ {
+				rec := make([]byte, field.Length)
+				for j := range rec {
+					rec[j] = alphabet[rng.Intn(len(alphabet))]
+				}
+				original := string(rec)
+				err := EncryptRecord(layout, rec)
+				if errors.Is(err, ErrFieldTooShort) {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				trim := func(s string) string {
+					if field.Trim == TrimLeft {
+						return strings.TrimLeft(s, string(field.Pad))
+					}
+					return strings.TrimRight(s, string(field.Pad))
+				}
+				if len(trim(string(rec))) != len(trim(original)) {
+					t.Fatalf("%q encrypted to %q, which has another length without padding", original, rec)
+				}
+				if err := DecryptRecord(layout, rec); err != nil {
+					t.Fatalf("%v", err)
+				}
+				if string(rec) != original {
+					t.Fatalf("Got %q, expected %q", rec, original)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldTooShort(t *testing.T) {
+	layout := testLayout(t)
+	rec := []byte("0123456789J                   XX00012345")
+	err := EncryptRecord(layout, rec)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "name" || !errors.Is(err, ErrFieldTooShort) {
+		t.Fatalf("Got %v, expected ErrFieldTooShort for name", err)
+	}
+
+	if err := EncryptRecord(layout, rec[:39]); !errors.Is(err, ErrRecordLength) {
+		t.Fatalf("Got %v, expected ErrRecordLength", err)
+	}
+	if err := EncryptRecord(layout, []byte("01234a6789JOHN SMITH          XX00012345")); !errors.As(err, &fieldErr) || fieldErr.Field != "account" {
+		t.Fatalf("Got %v, expected an error for account", err)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	layout := testLayout(t)
+	rng := rand.New(rand.NewSource(1))
+	names := []string{"JOHN SMITH", "JANE DOE", "AL", "MARIA DE LOS ANGELES"}
+
+	// More records than fit in one buffer
+	var input bytes.Buffer
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&input, "%010d%-20sXX%08d\n", rng.Int63n(1e10), names[i%len(names)], rng.Intn(1e6))
+	}
+
+	var encrypted, decrypted bytes.Buffer
+	if err := Process(bytes.NewReader(input.Bytes()), &encrypted, layout, false); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if encrypted.Len() != input.Len() || bytes.Equal(encrypted.Bytes(), input.Bytes()) {
+		t.Fatalf("Encrypted file has %d bytes, expected %d different ones", encrypted.Len(), input.Len())
+	}
+	if err := Process(bytes.NewReader(encrypted.Bytes()), &decrypted, layout, true); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), input.Bytes()) {
+		t.Fatalf("Decrypted file differs from the input")
+	}
+
+	// Errors carry the record number
+	for idx, testCase := range []struct {
+		input    string
+		expected string
+	}{
+		{input.String()[:41*3+20], "record 4"},
+		{strings.Replace(input.String()[:41*3], "\n", "\r", 2), "record 1"},
+		{input.String()[:41] + "0123456789J                   XX00012345\n", "record 2: field name"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			err := Process(strings.NewReader(testCase.input), &bytes.Buffer{}, layout, false)
+			if err == nil || !strings.Contains(err.Error(), testCase.expected) {
+				t.Fatalf("Got %v, expected an error for %s", err, testCase.expected)
+			}
+		})
+	}
+}
+
+func BenchmarkProcess(b *testing.B) {
+	layout := testLayout(b)
+	record := []byte("0123456789JOHN SMITH          XX00012345\n")
+	input := bytes.Repeat(record, 1000)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if err := Process(bytes.NewReader(input), io.Discard, layout, false); err != nil {
+			b.Fatalf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// ErrAmountFormat is returned for a value without digits.
+var ErrAmountFormat = errors.New("formats: not an amount")
+
+// An AmountLengthError is returned for an amount with fewer digits than the
+// cipher needs.
+type AmountLengthError struct {
+	Digits int // digits in the amount
+	MinLen int // the minimum the cipher encrypts
+}
+
+func (e *AmountLengthError) Error() string {
+	return fmt.Sprintf("formats: amount has %d digits, at least %d are needed", e.Digits, e.MinLen)
+}
+
+// An AmountOption configures an AmountCipher.
+type AmountOption func(*AmountCipher)
+
+// WithNonzeroLeading makes the AmountCipher keep whether the first digit of an
+// amount is zero, by cycle walking, so that "1,234.56" does not become "0,123.45"
+// and "0.75" stays below 1.
+func WithNonzeroLeading() AmountOption {
+	return func(a *AmountCipher) {
+		a.nonzeroLeading = true
+	}
+}
+
+// An AmountCipher encrypts decimal amounts to amounts of the same layout. Only
+// digits change, everything else stays in place: grouping separators and the
+// decimal point in either convention, as in "1,234.56" and "1.234,56", signs,
+// currency symbols and codes. An amount therefore keeps its number of integer
+// and fractional digits.
+type AmountCipher struct {
+	cipher         *ff1.Cipher
+	nonzeroLeading bool
+}
+
+// Amount returns an AmountCipher encrypting the digits of amounts with cipher,
+// which must have the alphabet 0123456789.
+func Amount(cipher *ff1.Cipher, opts ...AmountOption) *AmountCipher {
+	a := &AmountCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Encrypt encrypts the amount amount.
+func (a *AmountCipher) Encrypt(amount string) (string, error) {
+	return a.crypt(amount, true)
+}
+
+// Decrypt reverses Encrypt.
+func (a *AmountCipher) Decrypt(amount string) (string, error) {
+	return a.crypt(amount, false)
+}
+
+func (a *AmountCipher) crypt(amount string, encrypt bool) (string, error) {
+	if !bytes.Equal(a.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	out := []byte(amount)
+	var pos []int
+	for i, b := range out {
+		if isDigit(b) {
+			pos = append(pos, i)
+		}
+	}
+	if len(pos) == 0 {
+		return "", ErrAmountFormat
+	}
+	if len(pos) < a.cipher.MinLen() {
+		return "", &AmountLengthError{Digits: len(pos), MinLen: a.cipher.MinLen()}
+	}
+
+	X := make([]byte, len(pos))
+	for i, at := range pos {
+		X[i] = out[at]
+	}
+	leadingZero := X[0] == '0'
+	for i := 0; ; i++ {
+		if i == maxWalk {
+			return "", fmt.Errorf("formats: leading digit still changed after %d rounds of cycle walking", maxWalk)
+		}
+		var err error
+		if encrypt {
+			_, err = a.cipher.EncryptInto(X, X)
+		} else {
+			_, err = a.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return "", fmt.Errorf("formats: %w", err)
+		}
+		if !a.nonzeroLeading || (X[0] == '0') == leadingZero {
+			break
+		}
+	}
+	for i, at := range pos {
+		out[at] = X[i]
+	}
+	return string(out), nil
+}
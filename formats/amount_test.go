@@ -0,0 +1,120 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAmount(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		nonzeroLeading bool
+		amount         string
+		ciphertext     string
+	}{
+		{false, "1,234.56", "1,449.73"},
+		{false, "1.234,56", "1.449,73"},
+		{false, "-$1,234,567.89", "-$3,219,569.35"},
+		{false, "€ 1 234 567,89", "€ 3 219 569,35"},
+		{false, "CHF 12'345.60", "CHF 96'616.19"},
+		{false, "(100,000.00)", "(598,464.28)"},
+		{false, "123456", "144973"},
+		{false, "0.00", "5.32"},
+		{false, "0,000.00", "6,027.39"},
+		{true, "1,234.56", "1,449.73"},
+		{true, "USD 9,999.99", "USD 9,811.43"},
+		{true, "0.00", "0.58"},
+		{true, "0,000.00", "0,244.10"},
+		{true, "000000", "024410"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			var opts []AmountOption
+			if testCase.nonzeroLeading {
+				opts = append(opts, WithNonzeroLeading())
+			}
+			a := Amount(c, opts...)
+			ciphertext, err := a.Encrypt(testCase.amount)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := a.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.amount {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.amount)
+			}
+		})
+	}
+}
+
+func TestAmountNonzeroLeading(t *testing.T) {
+	a := Amount(newFormatCipher(t, "0123456789"), WithNonzeroLeading())
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		amount := fmt.Sprintf("%d.%02d", i/100, i%100)
+		ciphertext, err := a.Encrypt(amount)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(ciphertext) != 4 || ciphertext[1] != '.' || (ciphertext[0] == '0') != (amount[0] == '0') || seen[ciphertext] {
+			t.Fatalf("Got %q for %q", ciphertext, amount)
+		}
+		seen[ciphertext] = true
+		if plaintext, _ := a.Decrypt(ciphertext); plaintext != amount {
+			t.Fatalf("Got %q, expected %q", plaintext, amount)
+		}
+	}
+}
+
+func TestAmountErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		cipherAlphabet string
+		amount         string
+		err            error
+	}{
+		{"0123456789", "", ErrAmountFormat},
+		{"0123456789", "$.", ErrAmountFormat},
+		{"0123456789", "n/a", ErrAmountFormat},
+		{"0123456789", "$5", &AmountLengthError{Digits: 1, MinLen: c.MinLen()}},
+		{"0123456789abcdef", "1,234.56", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := Amount(newFormatCipher(t, testCase.cipherAlphabet)).Encrypt(testCase.amount)
+			if lengthErr, ok := testCase.err.(*AmountLengthError); ok {
+				var got *AmountLengthError
+				if !errors.As(err, &got) || *got != *lengthErr {
+					t.Fatalf("Got %v, expected %v", err, lengthErr)
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+const bicAlphanumerics = "0123456789" + upperLetters
+
+// bicClasses are the characters of the 11 positions of a BIC: the bank code,
+// the country code, which is kept, the location code and the branch code.
+var bicClasses = [11]string{
+	upperLetters, upperLetters, upperLetters, upperLetters,
+	"", "",
+	bicAlphanumerics, bicAlphanumerics,
+	bicAlphanumerics, bicAlphanumerics, bicAlphanumerics,
+}
+
+// ErrBICFormat is returned, in a BICFormatError, for a value that is not a BIC.
+var ErrBICFormat = errors.New("formats: not a BIC")
+
+// A BICFormatError is returned for a value that is not a BIC. Offset is the
+// position of the first unexpected byte, or the length of the value if it has
+// the wrong length.
+type BICFormatError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *BICFormatError) Error() string {
+	return fmt.Sprintf("%v: %s at position %d", ErrBICFormat, e.Msg, e.Offset)
+}
+
+func (e *BICFormatError) Unwrap() error {
+	return ErrBICFormat
+}
+
+// A BICCipher encrypts SWIFT business identifier codes to BICs of the same
+// length and country. Of a BIC such as DEUTDEFF500, the four letters of the
+// bank code are encrypted to letters, and the two characters of the location
+// code and the three of the optional branch code to digits and upper-case
+// letters, while the country code is kept.
+type BICCipher struct {
+	cipher *ff1.Cipher
+}
+
+// BIC returns a BICCipher encrypting BICs with cipher, which must have the
+// alphabet 0123456789.
+func BIC(cipher *ff1.Cipher) *BICCipher {
+	return &BICCipher{cipher: cipher}
+}
+
+// Encrypt encrypts bic.
+func (c *BICCipher) Encrypt(bic string) (string, error) {
+	return c.crypt(bic, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *BICCipher) Decrypt(bic string) (string, error) {
+	return c.crypt(bic, false)
+}
+
+func (c *BICCipher) crypt(bic string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	if len(bic) != 8 && len(bic) != 11 {
+		return "", &BICFormatError{Offset: len(bic), Msg: fmt.Sprintf("length %d instead of 8 or 11", len(bic))}
+	}
+	matched := bicClasses[:len(bic)]
+	for i := 0; i < len(bic); i++ {
+		class := matched[i]
+		if class == "" {
+			// The country code
+			class = upperLetters
+		}
+		if strings.IndexByte(class, bic[i]) < 0 {
+			return "", &BICFormatError{Offset: i, Msg: fmt.Sprintf("%q is not one of %s", bic[i], class)}
+		}
+	}
+	return cryptTemplate(c.cipher, matched, bic, encrypt)
+}
@@ -0,0 +1,111 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+var bicPattern = regexp.MustCompile(`^[A-Z]{6}[0-9A-Z]{2}([0-9A-Z]{3})?$`)
+
+func TestBIC(t *testing.T) {
+	bics := BIC(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		bic        string
+		ciphertext string
+	}{
+		{"DEUTDEFF", "UNHBDEC3"},
+		{"DEUTDEFF500", "TUIZDE12O4L"},
+		{"UBSWCHZH80A", "ORXMCHRN4NI"},
+		{"NEDSZAJJXXX", "ZJJFZADB4C3"},
+		{"BNPAFRPP", "BFGMFRZS"},
+		{"CHASUS33", "VEHAUSR9"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := bics.Encrypt(testCase.bic)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := bics.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.bic {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.bic)
+			}
+		})
+	}
+}
+
+// Chains of encryptions stay BICs of the same length and country
+func TestBICChains(t *testing.T) {
+	bics := BIC(newFormatCipher(t, "0123456789"))
+	for _, bic := range []string{"DEUTDEFF", "UBSWCHZH80A"} {
+		seen := map[string]bool{}
+		for i := 0; i < 200; i++ {
+			ciphertext, err := bics.Encrypt(bic)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !bicPattern.MatchString(ciphertext) || len(ciphertext) != len(bic) || ciphertext[4:6] != bic[4:6] {
+				t.Fatalf("Got %q for %q", ciphertext, bic)
+			}
+			seen[ciphertext] = true
+			bic = ciphertext
+		}
+		if len(seen) < 190 {
+			t.Fatalf("Got %d distinct BICs", len(seen))
+		}
+	}
+}
+
+func TestBICErrors(t *testing.T) {
+	bics := BIC(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		bic    string
+		offset int
+	}{
+		{"DEUTDEF", 7},
+		{"DEUTDEFF50", 10},
+		{"DEUTDEFF5000", 12},
+		{"DEU1DEFF", 3},
+		{"DEUTD3FF", 5},
+		{"deutdeff", 0},
+		{"DEUTDEfF", 6},
+		{"DEUTDEFF50-", 10},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := bics.Encrypt(testCase.bic)
+			var formatErr *BICFormatError
+			if !errors.As(err, &formatErr) || !errors.Is(err, ErrBICFormat) || formatErr.Offset != testCase.offset {
+				t.Fatalf("Got %v, expected an offset of %d", err, testCase.offset)
+			}
+		})
+	}
+	if _, err := BIC(newFormatCipher(t, "0123456789ABCDEF")).Encrypt("DEUTDEFF"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected %v", err, ErrAlphabet)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// maxCoordinatePrecision is the most decimals a CoordinateCipher works with, so
+// that scaled degrees stay far within the integers a float64 holds exactly.
+const maxCoordinatePrecision = 9
+
+var (
+	// ErrCoordinateFormat is returned for a value that is not a latitude and a
+	// longitude in decimal degrees, separated by a comma.
+	ErrCoordinateFormat = errors.New("formats: not a coordinate")
+
+	// ErrCoordinateBox is returned for a bounding box whose maximum is below its
+	// minimum or beyond the range of latitudes and longitudes, a precision that
+	// is negative or above 9, or a box with too many points at the precision.
+	ErrCoordinateBox = errors.New("formats: invalid bounding box")
+)
+
+// A CoordinateRangeError is returned for a point outside the bounding box.
+type CoordinateRangeError struct {
+	Lat, Lon float64
+	Box      BoundingBox
+}
+
+func (e *CoordinateRangeError) Error() string {
+	return fmt.Sprintf("formats: point %g,%g is outside the bounding box %g,%g to %g,%g",
+		e.Lat, e.Lon, e.Box.MinLat, e.Box.MinLon, e.Box.MaxLat, e.Box.MaxLon)
+}
+
+// A BoundingBox is the area of the points a CoordinateCipher encrypts, in
+// decimal degrees. Boxes that cross the antimeridian are not supported.
+type BoundingBox struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// A CoordinateOption configures a CoordinateCipher.
+type CoordinateOption func(*CoordinateCipher)
+
+// WithIndependentAxes makes the CoordinateCipher encrypt the latitude and the
+// longitude separately, with latTweak and lonTweak instead of the tweak of the
+// cipher. The two should differ, or points on the diagonal of the box keep
+// their coordinates equal.
+//
+// Points with the same latitude then encrypt to points with the same latitude,
+// and the same for longitudes, so ciphertexts show which points are on one
+// parallel or meridian. Without this option the point is ranked as a whole and
+// nothing of that kind is preserved, at the price of a domain that is the
+// product of the two and has to fit in 10^18.
+func WithIndependentAxes(latTweak, lonTweak []byte) CoordinateOption {
+	return func(c *CoordinateCipher) {
+		c.latTweak = append([]byte{}, latTweak...)
+		c.lonTweak = append([]byte{}, lonTweak...)
+	}
+}
+
+// A CoordinateCipher encrypts points to other points in a bounding box.
+//
+// Points are written as a latitude and a longitude in decimal degrees,
+// separated by a comma and optional spaces, as in "47.3769, 8.5417". Both are
+// rounded to the precision of the CoordinateCipher, in decimals, and so are the
+// edges of the box. A point is ranked by its steps from the south-west corner of
+// the box, which are encrypted as decimal strings with cycle walking.
+//
+// Ciphertexts are written with as many decimals as the precision, and with the
+// spacing of the input after the comma. Decrypt returns the rounded point.
+type CoordinateCipher struct {
+	cipher             *ff1.Cipher
+	box                BoundingBox
+	precision          int
+	latTweak, lonTweak []byte // nil for a composite domain
+}
+
+// Coordinate returns a CoordinateCipher encrypting points of box, at precision
+// decimals, with cipher, which must have the alphabet 0123456789.
+func Coordinate(cipher *ff1.Cipher, box BoundingBox, precision int, opts ...CoordinateOption) *CoordinateCipher {
+	c := &CoordinateCipher{cipher: cipher, box: box, precision: precision}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the point point.
+func (c *CoordinateCipher) Encrypt(point string) (string, error) {
+	return c.crypt(point, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *CoordinateCipher) Decrypt(point string) (string, error) {
+	return c.crypt(point, false)
+}
+
+func (c *CoordinateCipher) crypt(point string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	if c.precision < 0 || c.precision > maxCoordinatePrecision {
+		return "", ErrCoordinateBox
+	}
+	scale := math.Pow10(c.precision)
+	minLat, maxLat, ok := coordinateAxis(c.box.MinLat, c.box.MaxLat, 90, scale)
+	if !ok {
+		return "", ErrCoordinateBox
+	}
+	minLon, maxLon, ok := coordinateAxis(c.box.MinLon, c.box.MaxLon, 180, scale)
+	if !ok {
+		return "", ErrCoordinateBox
+	}
+	latSize, lonSize := uint64(maxLat-minLat+1), uint64(maxLon-minLon+1)
+	if c.latTweak == nil && latSize > maxRankSize/lonSize {
+		return "", ErrCoordinateBox
+	}
+
+	latText, lonText, found := strings.Cut(point, ",")
+	trimmed := strings.TrimLeft(lonText, " ")
+	space := lonText[:len(lonText)-len(trimmed)]
+	lat, err1 := parseDegrees(latText)
+	lon, err2 := parseDegrees(trimmed)
+	if !found || err1 != nil || err2 != nil {
+		return "", ErrCoordinateFormat
+	}
+	latStep, lonStep := math.Round(lat*scale), math.Round(lon*scale)
+	if latStep < float64(minLat) || latStep > float64(maxLat) || lonStep < float64(minLon) || lonStep > float64(maxLon) {
+		return "", &CoordinateRangeError{Lat: lat, Lon: lon, Box: c.box}
+	}
+	latRank, lonRank := uint64(int64(latStep)-minLat), uint64(int64(lonStep)-minLon)
+
+	if c.latTweak == nil {
+		rank, err := cryptRank(c.cipher, latRank*lonSize+lonRank, latSize*lonSize, encrypt)
+		if err != nil {
+			return "", err
+		}
+		latRank, lonRank = rank/lonSize, rank%lonSize
+	} else {
+		if latRank, err1 = cryptRankWithTweak(c.cipher, c.latTweak, latRank, latSize, encrypt); err1 != nil {
+			return "", err1
+		}
+		if lonRank, err2 = cryptRankWithTweak(c.cipher, c.lonTweak, lonRank, lonSize, encrypt); err2 != nil {
+			return "", err2
+		}
+	}
+	return formatSteps(minLat+int64(latRank), c.precision) + "," + space +
+		formatSteps(minLon+int64(lonRank), c.precision), nil
+}
+
+// coordinateAxis returns the steps of 10^-precision degrees, given as scale, of
+// the edges min and max of an axis that ends at -limit and limit.
+func coordinateAxis(min, max, limit, scale float64) (int64, int64, bool) {
+	if !(min >= -limit && min <= max && max <= limit) {
+		return 0, 0, false
+	}
+	return int64(math.Round(min * scale)), int64(math.Round(max * scale)), true
+}
+
+// parseDegrees parses a latitude or longitude in decimal degrees.
+func parseDegrees(s string) (float64, error) {
+	if s == "" || strings.Trim(s, "+-.0123456789") != "" {
+		return 0, ErrCoordinateFormat
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// formatSteps writes steps of 10^-precision degrees with precision decimals.
+func formatSteps(steps int64, precision int) string {
+	sign := ""
+	if steps < 0 {
+		sign, steps = "-", -steps
+	}
+	s := fmt.Sprintf("%0*d", precision+1, steps)
+	if precision == 0 {
+		return sign + s
+	}
+	return sign + s[:len(s)-precision] + "." + s[len(s)-precision:]
+}
@@ -0,0 +1,157 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var zurich = BoundingBox{MinLat: 47.32, MinLon: 8.44, MaxLat: 47.43, MaxLon: 8.63}
+
+func TestCoordinate(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	independent := WithIndependentAxes([]byte("lat"), []byte("lon"))
+	world := BoundingBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}
+	for idx, testCase := range []struct {
+		coordinates *CoordinateCipher
+		point       string
+		ciphertext  string
+		plaintext   string
+	}{
+		{Coordinate(c, zurich, 4), "47.3769,8.5417", "47.4153,8.4701", "47.3769,8.5417"},
+		{Coordinate(c, zurich, 4), "47.3769, 8.5417", "47.4153, 8.4701", "47.3769, 8.5417"},
+		{Coordinate(c, zurich, 4), "47.32,8.44", "47.3976,8.4946", "47.3200,8.4400"},
+		{Coordinate(c, zurich, 4), "47.43,8.63", "47.3886,8.5867", "47.4300,8.6300"},
+		{Coordinate(c, zurich, 4), "47.32,8.63", "47.4247,8.5049", "47.3200,8.6300"},
+		{Coordinate(c, zurich, 4), "47.43,8.44", "47.4256,8.6032", "47.4300,8.4400"},
+		{Coordinate(c, zurich, 4), "47.37691234,8.54", "47.3565,8.4498", "47.3769,8.5400"},
+		{Coordinate(c, zurich, 4, independent), "47.3769,8.5417", "47.4214,8.6200", "47.3769,8.5417"},
+		{Coordinate(c, zurich, 4, independent), "47.32,8.44", "47.3909,8.5206", "47.3200,8.4400"},
+		{Coordinate(c, zurich, 4, independent), "47.43,8.63", "47.4022,8.4774", "47.4300,8.6300"},
+		{Coordinate(c, zurich, 4, independent), "47.32,8.63", "47.3909,8.4774", "47.3200,8.6300"},
+		{Coordinate(c, zurich, 4, independent), "47.43,8.44", "47.4022,8.5206", "47.4300,8.4400"},
+		{Coordinate(c, world, 2), "47.3769, 8.5417", "-55.90, 79.40", "47.38, 8.54"},
+		{Coordinate(c, world, 2), "47.43,8.63", "35.02,-172.09", "47.43,8.63"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.coordinates.Encrypt(testCase.point)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.coordinates.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.plaintext {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.plaintext)
+			}
+		})
+	}
+}
+
+// Every point of a box of 11 by 11 steps, in both modes. Independent axes keep
+// the points of a parallel on one parallel, a composite domain does not.
+func TestCoordinateModes(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	box := BoundingBox{MinLat: -0.05, MinLon: 179.9, MaxLat: 0.05, MaxLon: 180}
+	for idx, independent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			var opts []CoordinateOption
+			if independent {
+				opts = append(opts, WithIndependentAxes([]byte("lat"), []byte("lon")))
+			}
+			coordinates := Coordinate(c, box, 2, opts...)
+			seen := map[string]bool{}
+			parallels := 0
+			for lat := -5; lat <= 5; lat++ {
+				latitudes := map[string]bool{}
+				for lon := 17990; lon <= 18000; lon++ {
+					point := formatSteps(int64(lat), 2) + "," + formatSteps(int64(lon), 2)
+					ciphertext, err := coordinates.Encrypt(point)
+					if err != nil {
+						t.Fatalf("%v", err)
+					}
+					latText, lonText, _ := strings.Cut(ciphertext, ",")
+					if seen[ciphertext] || len(lonText) != 6 || lonText < "179.90" || lonText > "180.00" {
+						t.Fatalf("Got %q for %q", ciphertext, point)
+					}
+					seen[ciphertext] = true
+					latitudes[latText] = true
+					if plaintext, _ := coordinates.Decrypt(ciphertext); plaintext != point {
+						t.Fatalf("Got %q, expected %q", plaintext, point)
+					}
+				}
+				if len(latitudes) == 1 {
+					parallels++
+				}
+			}
+			if independent && parallels != 11 || !independent && parallels != 0 {
+				t.Fatalf("Got %d parallels kept", parallels)
+			}
+		})
+	}
+}
+
+func TestCoordinateErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		coordinates *CoordinateCipher
+		point       string
+		err         error
+	}{
+		{Coordinate(c, zurich, 4), "47.3769", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), "47.3769;8.5417", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), " 47.3769,8.5417", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), "47.3769,8.5417 ", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), "47.3769,", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), "47.3769,NaN", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), "47°22'N,8°32'E", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), "47.3769,8.5417,1", ErrCoordinateFormat},
+		{Coordinate(c, zurich, 4), "47.3199,8.5417", &CoordinateRangeError{}},
+		{Coordinate(c, zurich, 4), "47.3769,8.6301", &CoordinateRangeError{}},
+		{Coordinate(c, zurich, 4), "-47.3769,8.5417", &CoordinateRangeError{}},
+		{Coordinate(c, zurich, -1), "47.3769,8.5417", ErrCoordinateBox},
+		{Coordinate(c, zurich, 10), "47.3769,8.5417", ErrCoordinateBox},
+		{Coordinate(c, BoundingBox{MinLat: 47.43, MinLon: 8.44, MaxLat: 47.32, MaxLon: 8.63}, 4), "47.3769,8.5417", ErrCoordinateBox},
+		{Coordinate(c, BoundingBox{MinLat: 0, MinLon: 170, MaxLat: 1, MaxLon: 190}, 4), "0.5,175", ErrCoordinateBox},
+		{Coordinate(c, BoundingBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}, 9), "0,0", ErrCoordinateBox},
+		{Coordinate(newFormatCipher(t, "0123456789-."), zurich, 4), "47.3769,8.5417", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.coordinates.Encrypt(testCase.point)
+			if _, ok := testCase.err.(*CoordinateRangeError); ok {
+				var rangeErr *CoordinateRangeError
+				if !errors.As(err, &rangeErr) || rangeErr.Box != testCase.coordinates.box {
+					t.Fatalf("Got %v, expected a CoordinateRangeError", err)
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// secondsPerDay is the length of the days of time.Time in UTC.
+const secondsPerDay = 24 * 60 * 60
+
+// ErrDateBounds is returned for a range whose last date is before its first.
+var ErrDateBounds = errors.New("formats: date range ends before it starts")
+
+// A DateFormatError is returned for a value that does not parse with the layout.
+type DateFormatError struct {
+	Value  string
+	Layout string
+	Err    error // from time.Parse
+}
+
+func (e *DateFormatError) Error() string {
+	return fmt.Sprintf("formats: date %q does not match the layout %q: %v", e.Value, e.Layout, e.Err)
+}
+
+func (e *DateFormatError) Unwrap() error {
+	return e.Err
+}
+
+// A DateRangeError is returned for a date outside the range.
+type DateRangeError struct {
+	Date     time.Time
+	Min, Max time.Time
+}
+
+func (e *DateRangeError) Error() string {
+	return fmt.Sprintf("formats: date %s is outside the range from %s to %s",
+		e.Date.Format("2006-01-02"), e.Min.Format("2006-01-02"), e.Max.Format("2006-01-02"))
+}
+
+// A DateCipher encrypts dates to other dates within a range.
+//
+// A date is ranked as its offset in days from the first of the range, which is
+// encrypted as a string of decimal digits, so every result is a date, leap days
+// included. Ciphertexts beyond the range are encrypted again until one is in it
+// (cycle walking), which keeps it a permutation of the range.
+//
+// Dates are read and written with a layout of the time package, which should
+// only have elements of the date: a time of day would be lost. Layouts with
+// two-digit years read them as years from 1969 to 2068, so they only decrypt
+// dates of ranges within those years.
+type DateCipher struct {
+	cipher   *ff1.Cipher
+	min, max time.Time
+	layout   string
+}
+
+// Date returns a DateCipher encrypting dates from min to max, both included,
+// with cipher, which must have the alphabet 0123456789. Only the dates of min
+// and max count, in their locations.
+func Date(cipher *ff1.Cipher, min, max time.Time, layout string) *DateCipher {
+	return &DateCipher{cipher: cipher, min: min, max: max, layout: layout}
+}
+
+// Encrypt encrypts date, given in the layout of the DateCipher.
+func (c *DateCipher) Encrypt(date string) (string, error) {
+	return c.crypt(date, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *DateCipher) Decrypt(date string) (string, error) {
+	return c.crypt(date, false)
+}
+
+// dayNumber returns the day of the date of t, counted from 1970-01-01.
+func dayNumber(t time.Time) int64 {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay
+}
+
+func (c *DateCipher) crypt(date string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	first, last := dayNumber(c.min), dayNumber(c.max)
+	if last < first {
+		return "", ErrDateBounds
+	}
+	t, err := time.Parse(c.layout, date)
+	if err != nil {
+		return "", &DateFormatError{Value: date, Layout: c.layout, Err: err}
+	}
+	day := dayNumber(t)
+	if day < first || day > last {
+		return "", &DateRangeError{Date: t, Min: c.min, Max: c.max}
+	}
+
+	offset, err := cryptRank(c.cipher, uint64(day-first), uint64(last-first+1), encrypt)
+	if err != nil {
+		return "", err
+	}
+	return time.Unix((first+int64(offset))*secondsPerDay, 0).UTC().Format(c.layout), nil
+}
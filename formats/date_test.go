@@ -0,0 +1,147 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func day(year int, m time.Month, d int) time.Time {
+	return time.Date(year, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDate(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	min, max := day(1900, time.January, 1), day(2099, time.December, 31)
+	for idx, testCase := range []struct {
+		layout     string
+		date       string
+		ciphertext string
+	}{
+		{"2006-01-02", "2023-07-15", "1969-03-09"},
+		{"2006-01-02", "1900-01-01", "1949-04-26"},
+		{"2006-01-02", "2099-12-31", "1913-04-04"},
+		{"2006-01-02", "2000-02-29", "2099-12-28"},
+		{"2006-01-02", "1999-12-31", "1994-02-05"},
+		{"02/01/2006", "15/07/2023", "09/03/1969"},
+		{"02/01/2006", "29/02/2000", "28/12/2099"},
+		{"Jan 2, 2006", "Jul 15, 2023", "Mar 9, 1969"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			d := Date(c, min, max, testCase.layout)
+			ciphertext, err := d.Encrypt(testCase.date)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := d.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.date {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.date)
+			}
+		})
+	}
+}
+
+func TestDateRanges(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		zurich = time.FixedZone("CET", 3600)
+	}
+	for idx, testCase := range []struct {
+		min, max time.Time
+		layout   string
+	}{
+		{day(1899, time.December, 1), day(1900, time.March, 31), "2006-01-02"},
+		{day(1999, time.December, 1), day(2000, time.March, 31), "02/01/2006"},
+		{day(2099, time.December, 1), day(2100, time.March, 31), "20060102"},
+		{day(2024, time.February, 28), day(2024, time.March, 1), "2006-01-02"},
+		{day(2024, time.February, 29), day(2024, time.February, 29), "2006-01-02"},
+		{time.Date(2020, time.January, 1, 23, 30, 0, 0, zurich), time.Date(2020, time.December, 31, 0, 30, 0, 0, zurich), "2 Jan 06"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			d := Date(c, testCase.min, testCase.max, testCase.layout)
+			first, last := dayNumber(testCase.min), dayNumber(testCase.max)
+			seen := map[string]bool{}
+			for n := first; n <= last; n++ {
+				date := time.Unix(n*secondsPerDay, 0).UTC().Format(testCase.layout)
+				ciphertext, err := d.Encrypt(date)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				got, err := time.Parse(testCase.layout, ciphertext)
+				if err != nil || dayNumber(got) < first || dayNumber(got) > last || seen[ciphertext] {
+					t.Fatalf("Got %q for %q", ciphertext, date)
+				}
+				seen[ciphertext] = true
+				if plaintext, _ := d.Decrypt(ciphertext); plaintext != date {
+					t.Fatalf("Got %q, expected %q", plaintext, date)
+				}
+			}
+		})
+	}
+}
+
+func TestDateErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	min, max := day(1990, time.January, 1), day(2009, time.December, 31)
+	for idx, testCase := range []struct {
+		d    *DateCipher
+		date string
+		err  error
+	}{
+		{Date(c, min, max, "2006-01-02"), "2001-02-29", &DateFormatError{}},
+		{Date(c, min, max, "2006-01-02"), "15/07/2003", &DateFormatError{}},
+		{Date(c, min, max, "2006-01-02"), "", &DateFormatError{}},
+		{Date(c, min, max, "2006-01-02"), "1989-12-31", &DateRangeError{}},
+		{Date(c, min, max, "2006-01-02"), "2010-01-01", &DateRangeError{}},
+		{Date(c, max, min, "2006-01-02"), "2000-01-01", ErrDateBounds},
+		{Date(newFormatCipher(t, "0123456789-"), min, max, "2006-01-02"), "2000-01-01", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.d.Encrypt(testCase.date)
+			switch testCase.err.(type) {
+			case *DateFormatError:
+				var formatErr *DateFormatError
+				var parseErr *time.ParseError
+				if !errors.As(err, &formatErr) || !errors.As(err, &parseErr) {
+					t.Fatalf("Got %v, expected a DateFormatError", err)
+				}
+			case *DateRangeError:
+				var rangeErr *DateRangeError
+				if !errors.As(err, &rangeErr) || rangeErr.Date.Format("2006-01-02") != testCase.date {
+					t.Fatalf("Got %v, expected a DateRangeError", err)
+				}
+			default:
+				if !errors.Is(err, testCase.err) {
+					t.Fatalf("Got %v, expected %v", err, testCase.err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// einPrefixes are the prefixes the IRS assigns to its campuses for EINs, in
+// ascending order.
+var einPrefixes = []string{
+	"01", "02", "03", "04", "05", "06",
+	"10", "11", "12", "13", "14", "15", "16",
+	"20", "21", "22", "23", "24", "25", "26", "27",
+	"30", "31", "32", "33", "34", "35", "36", "37", "38", "39",
+	"40", "41", "42", "43", "44", "45", "46", "47", "48",
+	"50", "51", "52", "53", "54", "55", "56", "57", "58", "59",
+	"60", "61", "62", "63", "64", "65", "66", "67", "68",
+	"71", "72", "73", "74", "75", "76", "77",
+	"80", "81", "82", "83", "84", "85", "86", "87", "88",
+	"90", "91", "92", "93", "94", "95", "98", "99",
+}
+
+var (
+	// ErrEINFormat is returned for a value that is not 9 digits, optionally
+	// with a dash after the second.
+	ErrEINFormat = errors.New("formats: not an EIN")
+
+	// ErrEINPrefix is returned for an EIN whose first two digits are not a
+	// prefix the IRS assigns.
+	ErrEINPrefix = errors.New("formats: EIN prefix not assigned by the IRS")
+)
+
+// An EINOption configures an EINCipher.
+type EINOption func(*EINCipher)
+
+// WithEncryptedPrefix makes the EINCipher encrypt the prefix too, to another
+// prefix the IRS assigns. The EIN is ranked as its index among all EINs with
+// such a prefix, the index of the prefix followed by the serial number, and the
+// rank encrypted within them.
+func WithEncryptedPrefix() EINOption {
+	return func(c *EINCipher) {
+		c.prefix = true
+	}
+}
+
+// An EINCipher encrypts Employer Identification Numbers to EINs. The first two
+// digits, the prefix, must be one the IRS assigns to its campuses and are kept,
+// while the 7 digits of the serial number are encrypted. EINs written with a
+// dash, as in 12-3456789, come out with the dash.
+type EINCipher struct {
+	cipher *ff1.Cipher
+	prefix bool
+}
+
+// EIN returns an EINCipher encrypting EINs with cipher, which must have the
+// alphabet 0123456789.
+func EIN(cipher *ff1.Cipher, opts ...EINOption) *EINCipher {
+	c := &EINCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the EIN ein.
+func (c *EINCipher) Encrypt(ein string) (string, error) {
+	return c.crypt(ein, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *EINCipher) Decrypt(ein string) (string, error) {
+	return c.crypt(ein, false)
+}
+
+func (c *EINCipher) crypt(ein string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	dashed := len(ein) == 10 && ein[2] == '-'
+	D := []byte(ein)
+	if dashed {
+		D = append(D[:2:2], ein[3:]...)
+	}
+	if len(D) != 9 || !allDigits(string(D)) {
+		return "", ErrEINFormat
+	}
+	index := einPrefixIndex(string(D[:2]))
+	if index < 0 {
+		return "", fmt.Errorf("%w: %q", ErrEINPrefix, D[:2])
+	}
+
+	if c.prefix {
+		serial, _ := strconv.ParseUint(string(D[2:]), 10, 64)
+		rank, err := cryptRank(c.cipher, uint64(index)*1e7+serial, uint64(len(einPrefixes))*1e7, encrypt)
+		if err != nil {
+			return "", err
+		}
+		copy(D, einPrefixes[rank/1e7])
+		copy(D[2:], fmt.Sprintf("%07d", rank%1e7))
+	} else {
+		X := D[2:]
+		var err error
+		if encrypt {
+			_, err = c.cipher.EncryptInto(X, X)
+		} else {
+			_, err = c.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return "", fmt.Errorf("formats: %w", err)
+		}
+	}
+
+	if dashed {
+		return string(D[:2]) + "-" + string(D[2:]), nil
+	}
+	return string(D), nil
+}
+
+// einPrefixIndex returns the index of prefix in einPrefixes, or -1.
+func einPrefixIndex(prefix string) int {
+	i := sort.SearchStrings(einPrefixes, prefix)
+	if i == len(einPrefixes) || einPrefixes[i] != prefix {
+		return -1
+	}
+	return i
+}
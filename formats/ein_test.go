@@ -0,0 +1,126 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+// unassignedEIN matches the prefixes the IRS does not assign
+var unassignedEIN = regexp.MustCompile(`^(00|0[7-9]|1[7-9]|2[89]|49|69|70|7[89]|89|9[67])`)
+
+func TestEIN(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		eins       *EINCipher
+		ein        string
+		ciphertext string
+	}{
+		{EIN(c), "12-3456789", "12-1044719"},
+		{EIN(c), "123456789", "121044719"},
+		{EIN(c), "01-0000000", "01-8129190"},
+		{EIN(c), "99-9999999", "99-7078238"},
+		{EIN(c, WithEncryptedPrefix()), "12-3456789", "77-4928222"},
+		{EIN(c, WithEncryptedPrefix()), "123456789", "774928222"},
+		{EIN(c, WithEncryptedPrefix()), "01-0000000", "71-9377493"},
+		{EIN(c, WithEncryptedPrefix()), "99-9999999", "92-0058508"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.eins.Encrypt(testCase.ein)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.eins.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.ein {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.ein)
+			}
+		})
+	}
+}
+
+// Every assigned prefix encrypts to assigned prefixes, in both modes, and with
+// WithEncryptedPrefix to all of them
+func TestEINPrefixes(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	prefixes := map[string]bool{}
+	for p := 0; p < 100; p++ {
+		for idx, eins := range []*EINCipher{EIN(c), EIN(c, WithEncryptedPrefix())} {
+			ein := fmt.Sprintf("%02d-%07d", p, p*7919)
+			ciphertext, err := eins.Encrypt(ein)
+			if unassignedEIN.MatchString(ein) {
+				if !errors.Is(err, ErrEINPrefix) {
+					t.Fatalf("Got %v, expected %v", err, ErrEINPrefix)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if unassignedEIN.MatchString(ciphertext) || idx == 0 && ciphertext[:2] != ein[:2] {
+				t.Fatalf("Got %q for %q", ciphertext, ein)
+			}
+			if plaintext, _ := eins.Decrypt(ciphertext); plaintext != ein {
+				t.Fatalf("Got %q, expected %q", plaintext, ein)
+			}
+			if idx == 1 {
+				prefixes[ciphertext[:2]] = true
+			}
+		}
+	}
+	if len(prefixes) < 50 {
+		t.Fatalf("Got %d distinct prefixes", len(prefixes))
+	}
+}
+
+func TestEINErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		ein string
+		err error
+	}{
+		{"12-345678", ErrEINFormat},
+		{"1234567890", ErrEINFormat},
+		{"123-456789", ErrEINFormat},
+		{"12 3456789", ErrEINFormat},
+		{"12-345678A", ErrEINFormat},
+		{"07-3456789", ErrEINPrefix},
+		{"00-0000000", ErrEINPrefix},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			for _, eins := range []*EINCipher{EIN(c), EIN(c, WithEncryptedPrefix())} {
+				if _, err := eins.Encrypt(testCase.ein); !errors.Is(err, testCase.err) {
+					t.Fatalf("Got %v, expected %v", err, testCase.err)
+				}
+			}
+		})
+	}
+	if _, err := EIN(newFormatCipher(t, "0123456789ABCDEF")).Encrypt("12-3456789"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected %v", err, ErrAlphabet)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// EmailAlphabet is an alphabet for the local part of email addresses that mail
+// systems accept without quoting: letters, digits and ".", "_", "-" and "+".
+const EmailAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-+"
+
+// atext holds the characters RFC 5322 allows in an unquoted local part, with the
+// dot that separates its atoms.
+const atext = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&'*+-/=?^_`{|}~."
+
+var (
+	// ErrEmailFormat is returned for a value without an @, with an empty or
+	// quoted local part, with characters in the local part the cipher does not
+	// have, or with dots at either end of the local part or next to each other.
+	ErrEmailFormat = errors.New("formats: not an email address")
+
+	// ErrEmailAlphabet is returned if the cipher has characters that are not
+	// allowed in an unquoted local part.
+	ErrEmailAlphabet = errors.New("formats: cipher alphabet must only have characters allowed in the local part of an email address")
+)
+
+// An EmailLengthError is returned for a local part shorter than the cipher
+// encrypts, unless WithShortPassthrough is set.
+type EmailLengthError struct {
+	Len    int // bytes of the local part
+	MinLen int // the minimum the cipher encrypts
+}
+
+func (e *EmailLengthError) Error() string {
+	return fmt.Sprintf("formats: local part of %d bytes, at least %d are needed", e.Len, e.MinLen)
+}
+
+// An EmailOption configures an EmailCipher.
+type EmailOption func(*EmailCipher)
+
+// WithLowercase makes the EmailCipher lowercase the local part before
+// encrypting it, for ciphers without upper case letters. Decryption then
+// returns the lowercased address.
+func WithLowercase() EmailOption {
+	return func(e *EmailCipher) {
+		e.lowercase = true
+	}
+}
+
+// WithShortPassthrough makes the EmailCipher return addresses whose local part
+// is too short to encrypt as they are, instead of an EmailLengthError. Such
+// addresses then stay readable, which is only acceptable for test data.
+func WithShortPassthrough() EmailOption {
+	return func(e *EmailCipher) {
+		e.passthrough = true
+	}
+}
+
+// An EmailCipher encrypts the local part of email addresses, the part before the
+// last @, and leaves the domain as it is.
+//
+// If the alphabet has the dot, ciphertexts with a dot at either end of the local
+// part or two next to each other, which mail systems reject, are encrypted again
+// until one has none (cycle walking). Plaintexts with such dots are rejected.
+type EmailCipher struct {
+	cipher      *ff1.Cipher
+	lowercase   bool
+	passthrough bool
+}
+
+// Email returns an EmailCipher encrypting local parts with cipher, whose
+// alphabet must only have characters allowed in an unquoted local part, such as
+// EmailAlphabet.
+func Email(cipher *ff1.Cipher, opts ...EmailOption) *EmailCipher {
+	e := &EmailCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Encrypt encrypts the local part of the email address email.
+func (e *EmailCipher) Encrypt(email string) (string, error) {
+	return e.crypt(email, true)
+}
+
+// Decrypt reverses Encrypt.
+func (e *EmailCipher) Decrypt(email string) (string, error) {
+	return e.crypt(email, false)
+}
+
+func (e *EmailCipher) crypt(email string, encrypt bool) (string, error) {
+	alphabet := e.cipher.Alphabet()
+	for _, b := range alphabet {
+		if strings.IndexByte(atext, b) < 0 {
+			return "", ErrEmailAlphabet
+		}
+	}
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 || email[0] == '"' {
+		return "", ErrEmailFormat
+	}
+	local := []byte(email[:at])
+	if e.lowercase {
+		local = bytes.ToLower(local)
+	}
+	dots := bytes.IndexByte(alphabet, '.') >= 0
+	for _, b := range local {
+		if bytes.IndexByte(alphabet, b) < 0 {
+			return "", ErrEmailFormat
+		}
+	}
+	if dots && badDots(local) {
+		return "", ErrEmailFormat
+	}
+	if len(local) < e.cipher.MinLen() {
+		if e.passthrough {
+			return string(local) + email[at:], nil
+		}
+		return "", &EmailLengthError{Len: len(local), MinLen: e.cipher.MinLen()}
+	}
+
+	for i := 0; i < maxWalk; i++ {
+		var err error
+		if encrypt {
+			_, err = e.cipher.EncryptInto(local, local)
+		} else {
+			_, err = e.cipher.DecryptInto(local, local)
+		}
+		if err != nil {
+			return "", fmt.Errorf("formats: %w", err)
+		}
+		if !dots || !badDots(local) {
+			return string(local) + email[at:], nil
+		}
+	}
+	return "", fmt.Errorf("formats: local part still has misplaced dots after %d rounds of cycle walking", maxWalk)
+}
+
+// badDots reports whether local starts or ends with a dot or has two in a row.
+func badDots(local []byte) bool {
+	return local[0] == '.' || local[len(local)-1] == '.' || bytes.Contains(local, []byte(".."))
+}
@@ -0,0 +1,131 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	c := newFormatCipher(t, EmailAlphabet)
+	lower := newFormatCipher(t, "abcdefghijklmnopqrstuvwxyz0123456789._-+")
+	for idx, testCase := range []struct {
+		e          *EmailCipher
+		email      string
+		ciphertext string
+		plaintext  string
+	}{
+		{Email(c), "john.doe@example.com", "dePugpNE@example.com", ""},
+		{Email(c), "jane+newsletter@example.org", "wPn2raz9.ZLfzRA@example.org", ""},
+		{Email(c), "a.b.c.d@sub.example.co.uk", "QBAoi.A@sub.example.co.uk", ""},
+		{Email(c), "user_name-1@müller.de", "GUwJmpVeoeO@müller.de", ""},
+		{Email(c), "ab@例え.jp", "tF@例え.jp", ""},
+		{Email(c, WithShortPassthrough()), "x@example.com", "x@example.com", ""},
+		{Email(lower, WithLowercase()), "John.Doe@Example.com", "", "john.doe@Example.com"},
+		{Email(lower, WithLowercase(), WithShortPassthrough()), "X@example.com", "x@example.com", "x@example.com"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.e.Encrypt(testCase.email)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if testCase.ciphertext != "" && ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.e.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			expected := testCase.plaintext
+			if expected == "" {
+				expected = testCase.email
+			}
+			if plaintext != expected {
+				t.Fatalf("Got %q, expected %q", plaintext, expected)
+			}
+		})
+	}
+}
+
+func TestEmailDots(t *testing.T) {
+	e := Email(newFormatCipher(t, "ab."))
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		local := strings.NewReplacer("0", "a", "1", "b").Replace(fmt.Sprintf("%08b", i))
+		local = local[:3] + "." + local[3:]
+		ciphertext, err := e.Encrypt(local + "@example.com")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		ciphertext = strings.TrimSuffix(ciphertext, "@example.com")
+		if badDots([]byte(ciphertext)) || seen[ciphertext] {
+			t.Fatalf("Got %q for %q", ciphertext, local)
+		}
+		seen[ciphertext] = true
+		plaintext, err := e.Decrypt(ciphertext + "@example.com")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if plaintext != local+"@example.com" {
+			t.Fatalf("Got %q, expected %q", plaintext, local)
+		}
+	}
+}
+
+func TestEmailErrors(t *testing.T) {
+	c := newFormatCipher(t, EmailAlphabet)
+	for idx, testCase := range []struct {
+		e     *EmailCipher
+		email string
+		err   error
+	}{
+		{Email(c), "john.doe", ErrEmailFormat},
+		{Email(c), "@example.com", ErrEmailFormat},
+		{Email(c), "john.doe@", ErrEmailFormat},
+		{Email(c), `"john doe"@example.com`, ErrEmailFormat},
+		{Email(c), `"john@doe"@example.com`, ErrEmailFormat},
+		{Email(c), "john!doe@example.com", ErrEmailFormat},
+		{Email(c), "jöhn@example.com", ErrEmailFormat},
+		{Email(c), ".john@example.com", ErrEmailFormat},
+		{Email(c), "john.@example.com", ErrEmailFormat},
+		{Email(c), "john..doe@example.com", ErrEmailFormat},
+		{Email(newFormatCipher(t, "abc")), "John@example.com", ErrEmailFormat},
+		{Email(c), "x@example.com", &EmailLengthError{Len: 1, MinLen: 2}},
+		{Email(newFormatCipher(t, "abc @")), "abc@example.com", ErrEmailAlphabet},
+		{Email(newFormatCipher(t, "0123456789")), "123@example.com", nil},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.e.Encrypt(testCase.email)
+			if expected, ok := testCase.err.(*EmailLengthError); ok {
+				var lengthErr *EmailLengthError
+				if !errors.As(err, &lengthErr) || *lengthErr != *expected {
+					t.Fatalf("Got %v, expected %v", err, expected)
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
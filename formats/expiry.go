@@ -0,0 +1,162 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrExpiryFormat is returned for a value that is neither MM/YY nor MM/YYYY.
+	ErrExpiryFormat = errors.New("formats: not an expiry date")
+
+	// ErrExpiryWindow is returned for a window whose last month is before its
+	// first, or that spans more than 100 years for an MM/YY date, whose century
+	// would then be ambiguous.
+	ErrExpiryWindow = errors.New("formats: invalid expiry window")
+)
+
+// An ExpiryWindow is the range of months expiry dates are encrypted within. Only
+// the years and months of First and Last count, both months are included.
+type ExpiryWindow struct {
+	First time.Time
+	Last  time.Time
+}
+
+// RelativeWindow returns the window from yearsBefore years before the month of
+// now to yearsAfter years after it. Dates encrypted with a window computed from
+// the current time do not decrypt once the window has moved on, so such a window
+// must be pinned, by storing now, to decrypt later.
+func RelativeWindow(now time.Time, yearsBefore, yearsAfter int) ExpiryWindow {
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return ExpiryWindow{First: month.AddDate(-yearsBefore, 0, 0), Last: month.AddDate(yearsAfter, 0, 0)}
+}
+
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+// An ExpiryRangeError is returned for a date outside the window.
+type ExpiryRangeError struct {
+	Year   int
+	Month  int
+	Window ExpiryWindow
+}
+
+func (e *ExpiryRangeError) Error() string {
+	return fmt.Sprintf("formats: expiry %02d/%d is outside the window from %s to %s",
+		e.Month, e.Year, e.Window.First.Format("01/2006"), e.Window.Last.Format("01/2006"))
+}
+
+// An ExpiryCipher encrypts card expiry dates to other dates within a window,
+// so that they pass validation where the dates are plausible.
+//
+// A date is ranked as its offset in months from the start of the window, which
+// is encrypted as a string of decimal digits. Ciphertexts outside the window are
+// encrypted again until one is inside (cycle walking), which keeps it a
+// permutation of the window.
+type ExpiryCipher struct {
+	cipher *ff1.Cipher
+	window ExpiryWindow
+}
+
+// Expiry returns an ExpiryCipher encrypting dates within window with cipher,
+// which must have the alphabet 0123456789.
+func Expiry(cipher *ff1.Cipher, window ExpiryWindow) *ExpiryCipher {
+	return &ExpiryCipher{cipher: cipher, window: window}
+}
+
+// Encrypt encrypts expiry, given as MM/YY or MM/YYYY, and returns the ciphertext
+// in the same layout. Two-digit years are taken to be in the window.
+func (e *ExpiryCipher) Encrypt(expiry string) (string, error) {
+	return e.crypt(expiry, true)
+}
+
+// Decrypt reverses Encrypt.
+func (e *ExpiryCipher) Decrypt(expiry string) (string, error) {
+	return e.crypt(expiry, false)
+}
+
+func (e *ExpiryCipher) crypt(expiry string, encrypt bool) (string, error) {
+	if !bytes.Equal(e.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	first, last := monthIndex(e.window.First), monthIndex(e.window.Last)
+	size := last - first + 1
+	if size < 1 {
+		return "", ErrExpiryWindow
+	}
+
+	month, year, short, ok := parseExpiry(expiry)
+	if !ok {
+		return "", ErrExpiryFormat
+	}
+	if short {
+		if size > 1200 {
+			return "", ErrExpiryWindow
+		}
+		// The year of the window ending in the two digits, for the month
+		year += e.window.First.Year() / 100 * 100
+		if year*12+month-1 < first {
+			year += 100
+		}
+	}
+	m := year*12 + month - 1
+	if m < first || m > last {
+		return "", &ExpiryRangeError{Year: year, Month: month, Window: e.window}
+	}
+
+	offset, err := cryptRank(e.cipher, uint64(m-first), uint64(size), encrypt)
+	if err != nil {
+		return "", err
+	}
+	m = first + int(offset)
+	if short {
+		return fmt.Sprintf("%02d/%02d", m%12+1, m/12%100), nil
+	}
+	return fmt.Sprintf("%02d/%04d", m%12+1, m/12), nil
+}
+
+// parseExpiry returns the month and year of MM/YY or MM/YYYY, and whether the
+// year has two digits.
+func parseExpiry(expiry string) (month, year int, short, ok bool) {
+	mm, yy, found := strings.Cut(expiry, "/")
+	if !found || len(mm) != 2 || len(yy) != 2 && len(yy) != 4 {
+		return 0, 0, false, false
+	}
+	for _, b := range []byte(mm + yy) {
+		if b < '0' || b > '9' {
+			return 0, 0, false, false
+		}
+	}
+	month, _ = strconv.Atoi(mm)
+	year, _ = strconv.Atoi(yy)
+	if month < 1 || month > 12 {
+		return 0, 0, false, false
+	}
+	return month, year, len(yy) == 2, true
+}
@@ -0,0 +1,154 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestExpiry(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, window := range []ExpiryWindow{
+		{First: month(2020, time.January), Last: month(2039, time.December)},
+		{First: month(2024, time.July), Last: month(2024, time.September)},
+		{First: month(1995, time.March), Last: month(2095, time.February)},
+		{First: month(2000, time.January), Last: month(2149, time.December)},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			e := Expiry(c, window)
+			first, last := monthIndex(window.First), monthIndex(window.Last)
+			seen := map[string]bool{}
+			for m := first; m <= last; m++ {
+				expiry := fmt.Sprintf("%02d/%04d", m%12+1, m/12)
+				ciphertext, err := e.Encrypt(expiry)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				month, year, _, ok := parseExpiry(ciphertext)
+				if !ok || year*12+month-1 < first || year*12+month-1 > last || len(ciphertext) != 7 {
+					t.Fatalf("Got %q for %q, outside the window", ciphertext, expiry)
+				}
+				if seen[ciphertext] {
+					t.Fatalf("Got %q twice", ciphertext)
+				}
+				seen[ciphertext] = true
+				plaintext, err := e.Decrypt(ciphertext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if plaintext != expiry {
+					t.Fatalf("Got %q, expected %q", plaintext, expiry)
+				}
+
+				if last-first >= 1200 {
+					continue
+				}
+				short := expiry[:3] + expiry[5:]
+				ciphertext, err = e.Encrypt(short)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if len(ciphertext) != 5 {
+					t.Fatalf("Got %q for %q", ciphertext, short)
+				}
+				if plaintext, _ := e.Decrypt(ciphertext); plaintext != short {
+					t.Fatalf("Got %q, expected %q", plaintext, short)
+				}
+			}
+		})
+	}
+}
+
+func TestExpiryGolden(t *testing.T) {
+	e := Expiry(newFormatCipher(t, "0123456789"), ExpiryWindow{First: month(2020, time.January), Last: month(2039, time.December)})
+	for idx, testCase := range []struct {
+		expiry     string
+		ciphertext string
+	}{
+		{"01/2020", "08/2032"},
+		{"12/39", "09/36"},
+		{"06/2027", "12/2032"},
+		{"06/27", "12/32"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := e.Encrypt(testCase.expiry)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+		})
+	}
+}
+
+func TestExpiryErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	window := ExpiryWindow{First: month(2020, time.January), Last: month(2039, time.December)}
+	for idx, testCase := range []struct {
+		window ExpiryWindow
+		expiry string
+		err    error
+	}{
+		{window, "13/25", ErrExpiryFormat},
+		{window, "00/2025", ErrExpiryFormat},
+		{window, "1/25", ErrExpiryFormat},
+		{window, "01/025", ErrExpiryFormat},
+		{window, "01-25", ErrExpiryFormat},
+		{window, "0a/25", ErrExpiryFormat},
+		{window, "", ErrExpiryFormat},
+		{window, "12/2019", &ExpiryRangeError{Year: 2019, Month: 12, Window: window}},
+		{window, "01/2040", &ExpiryRangeError{Year: 2040, Month: 1, Window: window}},
+		{window, "05/45", &ExpiryRangeError{Year: 2045, Month: 5, Window: window}},
+		{window, "05/19", &ExpiryRangeError{Year: 2119, Month: 5, Window: window}},
+		{ExpiryWindow{First: window.Last, Last: window.First}, "01/2025", ErrExpiryWindow},
+		{ExpiryWindow{First: month(2000, time.January), Last: month(2100, time.January)}, "01/25", ErrExpiryWindow},
+		{ExpiryWindow{First: month(2000, time.January), Last: month(2100, time.January)}, "01/2025", nil},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := Expiry(c, testCase.window).Encrypt(testCase.expiry)
+			if expected, ok := testCase.err.(*ExpiryRangeError); ok {
+				var rangeErr *ExpiryRangeError
+				if !errors.As(err, &rangeErr) || *rangeErr != *expected {
+					t.Fatalf("Got %v, expected %v", err, expected)
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
+
+func TestRelativeWindow(t *testing.T) {
+	now := time.Date(2026, time.October, 16, 13, 45, 0, 0, time.Local)
+	window := RelativeWindow(now, 5, 15)
+	if window.First.Format("01/2006") != "10/2021" || window.Last.Format("01/2006") != "10/2041" {
+		t.Fatalf("Got %v", window)
+	}
+}
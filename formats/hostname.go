@@ -0,0 +1,174 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// HostnameAlphabet is the alphabet of the labels of host names: lower-case
+// letters, digits and the hyphen.
+const HostnameAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789-"
+
+const (
+	maxLabelLen    = 63
+	maxHostnameLen = 253
+)
+
+// secondLevels are the labels below two-letter country code TLDs that are taken
+// to be public suffixes themselves, as in co.uk and com.au.
+var secondLevels = map[string]bool{
+	"ac": true, "co": true, "com": true, "edu": true, "gov": true,
+	"ne": true, "net": true, "or": true, "org": true,
+}
+
+var (
+	// ErrHostnameFormat is returned for a value with an empty label, a label
+	// longer than 63 bytes or with other characters than letters, digits and
+	// hyphens, or a hyphen at either end, or more than 253 bytes in all.
+	ErrHostnameFormat = errors.New("formats: not a host name")
+
+	// ErrHostnameAlphabet is returned if the cipher has characters that are not
+	// in HostnameAlphabet.
+	ErrHostnameAlphabet = errors.New("formats: cipher alphabet must only have lower-case letters, digits and the hyphen")
+)
+
+// A HostnameOption configures a HostnameCipher.
+type HostnameOption func(*HostnameCipher)
+
+// WithKeptDomain leaves the registrable domain in the clear as well as the
+// TLD, so that www.example.com becomes abc.example.com. The registrable domain
+// is the label before the public suffix, which is taken to be the TLD, or the
+// last two labels for names such as example.co.uk, whose second-level label
+// below a two-letter TLD is one of ac, co, com, edu, gov, ne, net, or and org.
+// This is a simple rule, not the Public Suffix List.
+func WithKeptDomain() HostnameOption {
+	return func(c *HostnameCipher) {
+		c.keepDomain = true
+	}
+}
+
+// A HostnameCipher encrypts host names to host names with as many labels of the
+// same lengths.
+//
+// Names are lowercased, and each label is encrypted on its own, except the TLD,
+// which is kept, so that www.example.com becomes for example 8hb.km4hbdm.com. A
+// name of a single label, such as localhost, has no TLD and is encrypted. If the
+// alphabet has the hyphen, ciphertexts with a hyphen at either end are
+// encrypted again until one has none (cycle walking). A trailing dot stays.
+//
+// Labels shorter than the cipher encrypts, such as the single letters of
+// a.example.com with HostnameAlphabet, are kept as they are.
+type HostnameCipher struct {
+	cipher     *ff1.Cipher
+	keepDomain bool
+}
+
+// Hostname returns a HostnameCipher encrypting host names with cipher, whose
+// alphabet must only have characters of HostnameAlphabet, such as all of them.
+func Hostname(cipher *ff1.Cipher, opts ...HostnameOption) *HostnameCipher {
+	c := &HostnameCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts host.
+func (c *HostnameCipher) Encrypt(host string) (string, error) {
+	return c.crypt(host, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *HostnameCipher) Decrypt(host string) (string, error) {
+	return c.crypt(host, false)
+}
+
+func (c *HostnameCipher) crypt(host string, encrypt bool) (string, error) {
+	alphabet := string(c.cipher.Alphabet())
+	if strings.Trim(alphabet, HostnameAlphabet) != "" {
+		return "", ErrHostnameAlphabet
+	}
+	host = strings.ToLower(host)
+	name := strings.TrimSuffix(host, ".")
+	if name == "" || len(name) > maxHostnameLen {
+		return "", ErrHostnameFormat
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > maxLabelLen || strings.Trim(label, HostnameAlphabet) != "" || badHyphens(label) {
+			return "", ErrHostnameFormat
+		}
+	}
+
+	keep := 0
+	if len(labels) > 1 {
+		keep = 1
+		if c.keepDomain {
+			keep = 2
+			tld := labels[len(labels)-1]
+			if len(labels) > 2 && len(tld) == 2 && secondLevels[labels[len(labels)-2]] {
+				keep = 3
+			}
+		}
+	}
+	if keep > len(labels) {
+		keep = len(labels)
+	}
+
+	hyphens := strings.IndexByte(alphabet, '-') >= 0
+	for i, label := range labels[:len(labels)-keep] {
+		if len(label) < c.cipher.MinLen() {
+			continue
+		}
+		if strings.Trim(label, alphabet) != "" {
+			return "", ErrHostnameFormat
+		}
+		L := []byte(label)
+		for walk := 0; ; walk++ {
+			if walk == maxWalk {
+				return "", fmt.Errorf("formats: label still has a hyphen at an end after %d rounds of cycle walking", maxWalk)
+			}
+			var err error
+			if encrypt {
+				_, err = c.cipher.EncryptInto(L, L)
+			} else {
+				_, err = c.cipher.DecryptInto(L, L)
+			}
+			if err != nil {
+				return "", fmt.Errorf("formats: %w", err)
+			}
+			if !hyphens || !badHyphens(string(L)) {
+				break
+			}
+		}
+		labels[i] = string(L)
+	}
+	return strings.Join(labels, ".") + host[len(name):], nil
+}
+
+// badHyphens reports whether label starts or ends with a hyphen.
+func badHyphens(label string) bool {
+	return label[0] == '-' || label[len(label)-1] == '-'
+}
@@ -0,0 +1,140 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHostname(t *testing.T) {
+	c := newFormatCipher(t, HostnameAlphabet)
+	for idx, testCase := range []struct {
+		keepDomain bool
+		host       string
+		ciphertext string
+		plaintext  string
+	}{
+		{false, "www.example.com", "8hb.km4hbdm.com", "www.example.com"},
+		{false, "WWW.Example.COM", "8hb.km4hbdm.com", "www.example.com"},
+		{false, "www.example.com.", "8hb.km4hbdm.com.", "www.example.com."},
+		{false, "mail.example.co.uk", "oa2j.km4hbdm.3z.uk", "mail.example.co.uk"},
+		{false, "api.eu-west-1.amazonaws.com", "up2.kynpxrfbm.b-w682xdx.com", "api.eu-west-1.amazonaws.com"},
+		{false, "a.b.example.org", "a.b.km4hbdm.org", "a.b.example.org"},
+		{false, "localhost", "azx9xp3hx", "localhost"},
+		{false, "db-01", "e4zxu", "db-01"},
+		{true, "www.example.com", "8hb.example.com", "www.example.com"},
+		{true, "mail.example.co.uk", "oa2j.example.co.uk", "mail.example.co.uk"},
+		{true, "example.co.uk", "example.co.uk", "example.co.uk"},
+		{true, "api.eu-west-1.amazonaws.com", "up2.kynpxrfbm.amazonaws.com", "api.eu-west-1.amazonaws.com"},
+		{true, "x1.example.de", "29.example.de", "x1.example.de"},
+		{true, "localhost", "azx9xp3hx", "localhost"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			var opts []HostnameOption
+			if testCase.keepDomain {
+				opts = append(opts, WithKeptDomain())
+			}
+			h := Hostname(c, opts...)
+			ciphertext, err := h.Encrypt(testCase.host)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := h.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.plaintext {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.plaintext)
+			}
+		})
+	}
+}
+
+// Every label of two characters encrypts to another without a hyphen at
+// either end
+func TestHostnameHyphens(t *testing.T) {
+	h := Hostname(newFormatCipher(t, HostnameAlphabet))
+	seen := map[string]bool{}
+	letters := strings.TrimSuffix(HostnameAlphabet, "-")
+	for _, first := range letters {
+		for _, last := range letters {
+			for _, label := range []string{string(first) + string(last), string(first) + "-" + string(last)} {
+				ciphertext, err := h.Encrypt(label + ".test")
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				encrypted := strings.TrimSuffix(ciphertext, ".test")
+				if len(encrypted) != len(label) || badHyphens(encrypted) || seen[encrypted] {
+					t.Fatalf("Got %q for %q", ciphertext, label)
+				}
+				seen[encrypted] = true
+				if plaintext, _ := h.Decrypt(ciphertext); plaintext != label+".test" {
+					t.Fatalf("Got %q, expected %q", plaintext, label+".test")
+				}
+			}
+		}
+	}
+}
+
+func TestHostnameErrors(t *testing.T) {
+	c := newFormatCipher(t, HostnameAlphabet)
+	for idx, testCase := range []struct {
+		cipherAlphabet string
+		host           string
+		err            error
+	}{
+		{HostnameAlphabet, "", ErrHostnameFormat},
+		{HostnameAlphabet, ".", ErrHostnameFormat},
+		{HostnameAlphabet, "www..example.com", ErrHostnameFormat},
+		{HostnameAlphabet, ".example.com", ErrHostnameFormat},
+		{HostnameAlphabet, "-www.example.com", ErrHostnameFormat},
+		{HostnameAlphabet, "www-.example.com", ErrHostnameFormat},
+		{HostnameAlphabet, "www.example.com-", ErrHostnameFormat},
+		{HostnameAlphabet, "under_score.example.com", ErrHostnameFormat},
+		{HostnameAlphabet, "bücher.example", ErrHostnameFormat},
+		{HostnameAlphabet, strings.Repeat("a", 64) + ".example.com", ErrHostnameFormat},
+		{HostnameAlphabet, strings.Repeat(strings.Repeat("a", 63)+".", 4) + "com", ErrHostnameFormat},
+		{"abcdefghijklmnopqrstuvwxyz", "www-1.example.com", ErrHostnameFormat},
+		{"abcdefghijklmnopqrstuvwxyz.", "www.example.com", ErrHostnameAlphabet},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZ", "www.example.com", ErrHostnameAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			cipher := c
+			if testCase.cipherAlphabet != HostnameAlphabet {
+				cipher = newFormatCipher(t, testCase.cipherAlphabet)
+			}
+			if _, err := Hostname(cipher).Encrypt(testCase.host); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+
+	// Labels of 63 bytes and names of 253 bytes are fine
+	host := strings.Repeat(strings.Repeat("a", 63)+".", 3) + strings.Repeat("b", 61)
+	if ciphertext, err := Hostname(c).Encrypt(host); err != nil || len(ciphertext) != len(host) {
+		t.Fatalf("Got %q, %v", ciphertext, err)
+	}
+}
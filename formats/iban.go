@@ -0,0 +1,285 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrIBANFormat is returned for a value that does not start with a country
+	// code and two check digits, or has other characters than letters, digits
+	// and spaces.
+	ErrIBANFormat = errors.New("formats: not an IBAN")
+
+	// ErrIBANCountry is returned for an IBAN of a country without an IBANCountry.
+	ErrIBANCountry = errors.New("formats: unknown IBAN country")
+
+	// ErrIBANChecksum is returned for an IBAN whose check digits are wrong.
+	ErrIBANChecksum = errors.New("formats: IBAN fails the mod-97 check")
+)
+
+// The alphabets of the BBANs of IBANCountry.
+const (
+	IBANDigits        = "0123456789"
+	IBANAlphanumerics = IBANDigits + upperLetters
+)
+
+// An IBANCountry describes the IBANs of a country.
+type IBANCountry struct {
+	Length      int    // of the IBAN, country code and check digits included
+	BankCodeLen int    // of the bank code at the start of the BBAN
+	Alphabet    string // of the BBAN, IBANDigits if empty, or IBANAlphanumerics
+}
+
+// ibanCountries holds the IBANs of common countries. Those whose BBANs have
+// letters, in the bank code or the account number, have the alphabet
+// IBANAlphanumerics.
+var ibanCountries = map[string]IBANCountry{
+	"AT": {Length: 20, BankCodeLen: 5, Alphabet: IBANDigits},
+	"BE": {Length: 16, BankCodeLen: 3, Alphabet: IBANDigits},
+	"CH": {Length: 21, BankCodeLen: 5, Alphabet: IBANAlphanumerics},
+	"DE": {Length: 22, BankCodeLen: 8, Alphabet: IBANDigits},
+	"DK": {Length: 18, BankCodeLen: 4, Alphabet: IBANDigits},
+	"ES": {Length: 24, BankCodeLen: 4, Alphabet: IBANDigits},
+	"FR": {Length: 27, BankCodeLen: 5, Alphabet: IBANAlphanumerics},
+	"GB": {Length: 22, BankCodeLen: 4, Alphabet: IBANAlphanumerics},
+	"IE": {Length: 22, BankCodeLen: 4, Alphabet: IBANAlphanumerics},
+	"IT": {Length: 27, BankCodeLen: 6, Alphabet: IBANAlphanumerics},
+	"LU": {Length: 20, BankCodeLen: 3, Alphabet: IBANAlphanumerics},
+	"NL": {Length: 18, BankCodeLen: 4, Alphabet: IBANAlphanumerics},
+	"NO": {Length: 15, BankCodeLen: 4, Alphabet: IBANDigits},
+	"PL": {Length: 28, BankCodeLen: 8, Alphabet: IBANDigits},
+	"PT": {Length: 25, BankCodeLen: 4, Alphabet: IBANDigits},
+	"SE": {Length: 24, BankCodeLen: 3, Alphabet: IBANDigits},
+}
+
+// An IBANLengthError is returned for an IBAN of the wrong length for its
+// country.
+type IBANLengthError struct {
+	Country  string
+	Len      int
+	Expected int
+}
+
+func (e *IBANLengthError) Error() string {
+	return fmt.Sprintf("formats: IBAN of %s has %d characters instead of %d", e.Country, e.Len, e.Expected)
+}
+
+// An IBANOption configures an IBANCipher.
+type IBANOption func(*IBANCipher)
+
+// WithKeptBankCode leaves the bank code in the clear.
+func WithKeptBankCode() IBANOption {
+	return func(c *IBANCipher) {
+		c.keepBank = true
+	}
+}
+
+// WithIBANCountry adds a country, given by its two-letter code in upper case, or
+// replaces the description of one.
+func WithIBANCountry(code string, country IBANCountry) IBANOption {
+	return func(c *IBANCipher) {
+		c.countries[code] = country
+	}
+}
+
+// An IBANCipher encrypts international bank account numbers to IBANs of the
+// same country that pass the mod-97 check of ISO 13616.
+//
+// The BBAN, the part after the check digits, is encrypted over the alphabet of
+// its country. Of a BBAN of digits, the digits are encrypted as they are, and
+// letters, which should not be there, are kept. A BBAN of digits and letters,
+// as those of GB and FR, must have its letters in upper case. It is ranked as
+// its index among the strings of its length over IBANAlphanumerics and the
+// rank encrypted, so letters and digits may take each other's places.
+//
+// The check digits are computed for the ciphertext. Those of the plaintext
+// follow from the rest of it, which is why only valid IBANs are accepted and
+// decryption can restore them. Spaces stay in place. National check digits
+// within the BBAN, which countries such as Belgium and Spain have, are not
+// computed.
+type IBANCipher struct {
+	cipher    *ff1.Cipher
+	keepBank  bool
+	countries map[string]IBANCountry
+}
+
+// IBAN returns an IBANCipher encrypting IBANs with cipher, which must have the
+// alphabet 0123456789. It knows the IBANs of AT, BE, CH, DE, DK, ES, FR, GB,
+// IE, IT, LU, NL, NO, PL, PT and SE, WithIBANCountry adds more.
+func IBAN(cipher *ff1.Cipher, opts ...IBANOption) *IBANCipher {
+	c := &IBANCipher{cipher: cipher, countries: make(map[string]IBANCountry, len(ibanCountries))}
+	for code, country := range ibanCountries {
+		c.countries[code] = country
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts iban.
+func (c *IBANCipher) Encrypt(iban string) (string, error) {
+	return c.crypt(iban, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *IBANCipher) Decrypt(iban string) (string, error) {
+	return c.crypt(iban, false)
+}
+
+func (c *IBANCipher) crypt(iban string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	out := []byte(iban)
+	var pos []int
+	for i, b := range out {
+		switch {
+		case b == ' ':
+		case b >= '0' && b <= '9', b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z':
+			pos = append(pos, i)
+		default:
+			return "", ErrIBANFormat
+		}
+	}
+	s := make([]byte, len(pos))
+	for i, at := range pos {
+		s[i] = out[at]
+	}
+	if len(s) < 4 || !isUpper(s[0]) || !isUpper(s[1]) || !isDigit(s[2]) || !isDigit(s[3]) {
+		return "", ErrIBANFormat
+	}
+	country, ok := c.countries[string(s[:2])]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrIBANCountry, s[:2])
+	}
+	if len(s) != country.Length {
+		return "", &IBANLengthError{Country: string(s[:2]), Len: len(s), Expected: country.Length}
+	}
+	if ibanMod97(s) != 1 {
+		return "", ErrIBANChecksum
+	}
+
+	alphabet := country.Alphabet
+	if alphabet == "" {
+		alphabet = IBANDigits
+	}
+	kept := 4
+	if c.keepBank {
+		kept += country.BankCodeLen
+	}
+	var at []int
+	for i := kept; i < len(s); i++ {
+		switch {
+		case strings.IndexByte(alphabet, s[i]) >= 0:
+			at = append(at, i)
+		case alphabet != IBANDigits:
+			return "", ErrIBANFormat
+		}
+	}
+	if len(at) < c.cipher.MinLen() {
+		return "", fmt.Errorf("formats: IBAN has %d characters to encrypt, at least %d are needed", len(at), c.cipher.MinLen())
+	}
+	X := make([]byte, len(at))
+	for i, j := range at {
+		X[i] = s[j]
+	}
+	if err := cryptBBAN(c.cipher, alphabet, X, encrypt); err != nil {
+		return "", err
+	}
+	for i, j := range at {
+		s[j] = X[i]
+	}
+
+	s[2], s[3] = '0', '0'
+	check := 98 - ibanMod97(s)
+	s[2], s[3] = byte('0'+check/10), byte('0'+check%10)
+	for i, at := range pos {
+		out[at] = s[i]
+	}
+	return string(out), nil
+}
+
+// cryptBBAN encrypts or decrypts the characters X of alphabet in place.
+func cryptBBAN(c *ff1.Cipher, alphabet string, X []byte, encrypt bool) error {
+	if alphabet == IBANDigits {
+		var err error
+		if encrypt {
+			_, err = c.EncryptInto(X, X)
+		} else {
+			_, err = c.DecryptInto(X, X)
+		}
+		if err != nil {
+			return fmt.Errorf("formats: %w", err)
+		}
+		return nil
+	}
+
+	radix := big.NewInt(int64(len(alphabet)))
+	rank, size := new(big.Int), big.NewInt(1)
+	for _, b := range X {
+		rank.Mul(rank, radix).Add(rank, big.NewInt(int64(strings.IndexByte(alphabet, b))))
+		size.Mul(size, radix)
+	}
+	rank, err := cryptBigRank(c, rank, size, encrypt)
+	if err != nil {
+		return err
+	}
+	digit := new(big.Int)
+	for i := len(X) - 1; i >= 0; i-- {
+		rank.DivMod(rank, radix, digit)
+		X[i] = alphabet[digit.Int64()]
+	}
+	return nil
+}
+
+// ibanMod97 returns the remainder of iban, with the first four characters moved
+// to the end and letters replaced by 10 to 35, divided by 97.
+func ibanMod97(iban []byte) int {
+	r := 0
+	for i := range iban {
+		b := iban[(i+4)%len(iban)]
+		switch {
+		case isDigit(b):
+			r = (r*10 + int(b-'0')) % 97
+		case isUpper(b):
+			r = (r*100 + int(b-'A') + 10) % 97
+		default:
+			r = (r*100 + int(b-'a') + 10) % 97
+		}
+	}
+	return r
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isUpper(b byte) bool {
+	return b >= 'A' && b <= 'Z'
+}
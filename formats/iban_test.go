@@ -0,0 +1,169 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIBAN(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		ib         *IBANCipher
+		iban       string
+		ciphertext string
+	}{
+		{IBAN(c), "DE89 3704 0044 0532 0130 00", "DE08 2100 5192 8584 4401 52"},
+		{IBAN(c), "DE89370400440532013000", "DE08210051928584440152"},
+		{IBAN(c), "GB29 NWBK 6016 1331 9268 19", "GB77 2DM4 WXQE PCG4 RUQ2 H3"},
+		{IBAN(c), "FR14 2004 1010 0505 0001 3M02 606", "FR24 5VWX IKNR 8XKF BWEK RM03 JI8"},
+		{IBAN(c), "NL91 ABNA 0417 1643 00", "NL20 7DAK LS95 W7XZ 0R"},
+		{IBAN(c), "ES91 2100 0418 4502 0005 1332", "ES77 0068 1546 7973 5628 8197"},
+		{IBAN(c), "IT60 X054 2811 1010 0000 0123 456", "IT02 NPUE 214Y IYBZ FNWY 48N9 QMZ"},
+		{IBAN(c), "BE68 5390 0754 7034", "BE63 0026 5200 6513"},
+		{IBAN(c), "CH93 0076 2011 6238 5295 7", "CH34 PRWP PPWO 3YPF 9GXS 3"},
+		{IBAN(c), "NO93 8601 1117 947", "NO82 7900 5864 572"},
+		{IBAN(c, WithKeptBankCode()), "DE89 3704 0044 0532 0130 00", "DE48 3704 0044 3525 2116 08"},
+		{IBAN(c, WithKeptBankCode()), "FR14 2004 1010 0505 0001 3M02 606", "FR49 2004 1169 LQ7J 50EI K0RE BQL"},
+		{IBAN(c, WithKeptBankCode()), "AT61 1904 3002 3457 3201", "AT02 1904 3114 0113 1134"},
+		{IBAN(c, WithKeptBankCode()), "PL61 1090 1014 0000 0712 1981 2874", "PL42 1090 1014 0173 0146 0686 5604"},
+		{IBAN(c, WithKeptBankCode()), "SE45 5000 0000 0583 9825 7466", "SE34 5009 9580 2033 8137 3677"},
+		{IBAN(c, WithKeptBankCode()), "DK50 0040 0440 1162 43", "DK42 0040 7372 0546 44"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.ib.Encrypt(testCase.iban)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			if ibanMod97([]byte(strings.ReplaceAll(ciphertext, " ", ""))) != 1 {
+				t.Fatalf("%q fails the mod-97 check", ciphertext)
+			}
+			plaintext, err := testCase.ib.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.iban {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.iban)
+			}
+		})
+	}
+}
+
+// The letters of a bank code are encrypted too, unless the bank code is kept
+func TestIBANBankCode(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		iban string
+		bank string
+	}{
+		{"GB29 NWBK 6016 1331 9268 19", "NWBK"},
+		{"GB82 WEST 1234 5698 7654 32", "WEST"},
+		{"NL91 ABNA 0417 1643 00", "ABNA"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := IBAN(c).Encrypt(testCase.iban)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext[5:9] == testCase.bank {
+				t.Fatalf("Got %q, leaking the bank code of %q", ciphertext, testCase.iban)
+			}
+			ciphertext, err = IBAN(c, WithKeptBankCode()).Encrypt(testCase.iban)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext[5:9] != testCase.bank || ciphertext[10:] == testCase.iban[10:] {
+				t.Fatalf("Got %q for %q", ciphertext, testCase.iban)
+			}
+			if ibanMod97([]byte(strings.ReplaceAll(ciphertext, " ", ""))) != 1 {
+				t.Fatalf("%q fails the mod-97 check", ciphertext)
+			}
+			if plaintext, _ := IBAN(c, WithKeptBankCode()).Decrypt(ciphertext); plaintext != testCase.iban {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.iban)
+			}
+		})
+	}
+}
+
+func TestIBANChecksum(t *testing.T) {
+	ib := IBAN(newFormatCipher(t, "0123456789"))
+	iban := []byte("DE00370400440532013000")
+	for i := 0; i < 1000; i++ {
+		copy(iban[12:], fmt.Sprintf("%010d", i*7919))
+		iban[2], iban[3] = '0', '0'
+		check := 98 - ibanMod97(iban)
+		iban[2], iban[3] = byte('0'+check/10), byte('0'+check%10)
+
+		ciphertext, err := ib.Encrypt(string(iban))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if ibanMod97([]byte(ciphertext)) != 1 {
+			t.Fatalf("Got %q for %q, failing the mod-97 check", ciphertext, iban)
+		}
+		if plaintext, _ := ib.Decrypt(ciphertext); plaintext != string(iban) {
+			t.Fatalf("Got %q, expected %q", plaintext, iban)
+		}
+	}
+}
+
+func TestIBANErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		ib   *IBANCipher
+		iban string
+		err  error
+	}{
+		{IBAN(c), "", ErrIBANFormat},
+		{IBAN(c), "DE8", ErrIBANFormat},
+		{IBAN(c), "de89 3704 0044 0532 0130 00", ErrIBANFormat},
+		{IBAN(c), "DEX9 3704 0044 0532 0130 00", ErrIBANFormat},
+		{IBAN(c), "DE89-3704-0044-0532-0130-00", ErrIBANFormat},
+		{IBAN(c), "GB29 nwbk 6016 1331 9268 19", ErrIBANFormat},
+		{IBAN(c), "XX89 3704 0044 0532 0130 00", ErrIBANCountry},
+		{IBAN(c), "DE88 3704 0044 0532 0130 00", ErrIBANChecksum},
+		{IBAN(c), "DE89 3704 0044 0532 0130 01", ErrIBANChecksum},
+		{IBAN(c), "DE89 3704 0044 0532 0130 0", &IBANLengthError{Country: "DE", Len: 21, Expected: 22}},
+		{IBAN(c), "NO93 8601 1117 9470", &IBANLengthError{Country: "NO", Len: 16, Expected: 15}},
+		{IBAN(c, WithIBANCountry("NO", IBANCountry{Length: 16})), "NO93 8601 1117 947", &IBANLengthError{Country: "NO", Len: 15, Expected: 16}},
+		{IBAN(c, WithIBANCountry("MT", IBANCountry{Length: 31, BankCodeLen: 9})), "MT84 MALT 0110 0001 2345 MTLC AST0 01S", nil},
+		{IBAN(newFormatCipher(t, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")), "DE89 3704 0044 0532 0130 00", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.ib.Encrypt(testCase.iban)
+			if expected, ok := testCase.err.(*IBANLengthError); ok {
+				var lengthErr *IBANLengthError
+				if !errors.As(err, &lengthErr) || *lengthErr != *expected {
+					t.Fatalf("Got %v, expected %v", err, expected)
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
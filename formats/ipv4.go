@@ -0,0 +1,126 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrIPv4Format is returned for a value that is not four decimal octets of
+	// 0 to 255 separated by dots, without leading zeros.
+	ErrIPv4Format = errors.New("formats: not an IPv4 address")
+
+	// ErrBinaryAlphabet is returned if the cipher does not have an alphabet of
+	// all 256 bytes, as the ciphers of ff1.NewBinaryCipher have.
+	ErrBinaryAlphabet = errors.New("formats: cipher alphabet must have all 256 bytes")
+)
+
+// An IPv4Option configures an IPv4Cipher.
+type IPv4Option func(*IPv4Cipher)
+
+// WithKeptOctets leaves the first n octets in the clear, so that addresses stay
+// in their subnet. n is limited to 0 to 3, at least one octet is encrypted.
+func WithKeptOctets(n int) IPv4Option {
+	return func(c *IPv4Cipher) {
+		switch {
+		case n < 0:
+			n = 0
+		case n > 3:
+			n = 3
+		}
+		c.keep = n
+	}
+}
+
+// An IPv4Cipher encrypts IPv4 addresses in dotted-quad notation to other
+// addresses. The octets are encrypted as bytes, so every result is a valid
+// address, which encrypting the decimal digits would not give.
+type IPv4Cipher struct {
+	cipher *ff1.Cipher
+	keep   int
+}
+
+// IPv4 returns an IPv4Cipher encrypting addresses with cipher, whose alphabet
+// must have all 256 bytes.
+func IPv4(cipher *ff1.Cipher, opts ...IPv4Option) *IPv4Cipher {
+	c := &IPv4Cipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the address addr, such as "10.1.2.3".
+func (c *IPv4Cipher) Encrypt(addr string) (string, error) {
+	return c.crypt(addr, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *IPv4Cipher) Decrypt(addr string) (string, error) {
+	return c.crypt(addr, false)
+}
+
+func (c *IPv4Cipher) crypt(addr string, encrypt bool) (string, error) {
+	if len(c.cipher.Alphabet()) != 256 {
+		return "", ErrBinaryAlphabet
+	}
+	octets, ok := parseIPv4(addr)
+	if !ok {
+		return "", ErrIPv4Format
+	}
+
+	X := octets[c.keep:]
+	var err error
+	if encrypt {
+		_, err = c.cipher.EncryptInto(X, X)
+	} else {
+		_, err = c.cipher.DecryptInto(X, X)
+	}
+	if err != nil {
+		return "", fmt.Errorf("formats: %w", err)
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", octets[0], octets[1], octets[2], octets[3]), nil
+}
+
+// parseIPv4 returns the four octets of addr.
+func parseIPv4(addr string) ([]byte, bool) {
+	parts := strings.Split(addr, ".")
+	if len(parts) != 4 {
+		return nil, false
+	}
+	octets := make([]byte, 4)
+	for i, part := range parts {
+		if part == "" || len(part) > 3 || len(part) > 1 && part[0] == '0' || strings.Trim(part, "0123456789") != "" {
+			return nil, false
+		}
+		n, _ := strconv.Atoi(part)
+		if n > 255 {
+			return nil, false
+		}
+		octets[i] = byte(n)
+	}
+	return octets, true
+}
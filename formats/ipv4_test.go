@@ -0,0 +1,132 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+func newBinaryCipher(t *testing.T) *ff1.Cipher {
+	t.Helper()
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	c, err := ff1.NewBinaryCipher(8, key, []byte("ipv4"))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	return c
+}
+
+func TestIPv4(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, testCase := range []struct {
+		ip         *IPv4Cipher
+		addr       string
+		ciphertext string
+	}{
+		{IPv4(c), "10.1.2.3", "22.205.206.56"},
+		{IPv4(c), "0.0.0.0", "224.16.229.121"},
+		{IPv4(c), "255.255.255.255", "241.131.192.161"},
+		{IPv4(c), "192.168.1.1", "190.117.179.226"},
+		{IPv4(c, WithKeptOctets(2)), "10.1.2.3", "10.1.198.46"},
+		{IPv4(c, WithKeptOctets(2)), "0.0.0.0", "0.0.2.199"},
+		{IPv4(c, WithKeptOctets(2)), "255.255.255.255", "255.255.150.80"},
+		{IPv4(c, WithKeptOctets(2)), "192.168.1.1", "192.168.0.180"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.ip.Encrypt(testCase.addr)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.ip.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.addr {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.addr)
+			}
+		})
+	}
+}
+
+func TestIPv4KeptOctets(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, n := range []int{-1, 0, 1, 2, 3, 4} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ip := IPv4(c, WithKeptOctets(n))
+			seen := map[string]bool{}
+			for i := 0; i < 256; i++ {
+				addr := fmt.Sprintf("172.16.%d.%d", i/16, i)
+				ciphertext, err := ip.Encrypt(addr)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				octets, ok := parseIPv4(ciphertext)
+				if !ok || seen[ciphertext] {
+					t.Fatalf("Got %q for %q", ciphertext, addr)
+				}
+				seen[ciphertext] = true
+				kept := []byte{172, 16, byte(i / 16)}
+				for j := 0; j < n && j < 3; j++ {
+					if octets[j] != kept[j] {
+						t.Fatalf("Octet %d of %q changed in %q", j+1, addr, ciphertext)
+					}
+				}
+				if plaintext, _ := ip.Decrypt(ciphertext); plaintext != addr {
+					t.Fatalf("Got %q, expected %q", plaintext, addr)
+				}
+			}
+		})
+	}
+}
+
+func TestIPv4Errors(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, testCase := range []struct {
+		ip   *IPv4Cipher
+		addr string
+		err  error
+	}{
+		{IPv4(c), "", ErrIPv4Format},
+		{IPv4(c), "10.1.2", ErrIPv4Format},
+		{IPv4(c), "10.1.2.3.4", ErrIPv4Format},
+		{IPv4(c), "10.1.2.256", ErrIPv4Format},
+		{IPv4(c), "10.1..3", ErrIPv4Format},
+		{IPv4(c), "10.01.2.3", ErrIPv4Format},
+		{IPv4(c), "10.1.2.-3", ErrIPv4Format},
+		{IPv4(c), "10.1.2.3 ", ErrIPv4Format},
+		{IPv4(c), "0x0a.1.2.3", ErrIPv4Format},
+		{IPv4(c), "::1", ErrIPv4Format},
+		{IPv4(newFormatCipher(t, "0123456789")), "10.1.2.3", ErrBinaryAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := testCase.ip.Encrypt(testCase.addr); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// mappedPrefixLen is the length of the prefix ::ffff:0:0/96 of IPv4-mapped
+// addresses.
+const mappedPrefixLen = 96
+
+// ErrIPv6Format is returned for a value that is not an IPv6 address, including
+// IPv4 addresses in dotted-quad notation, which IPv4Cipher encrypts.
+var ErrIPv6Format = errors.New("formats: not an IPv6 address")
+
+// An IPv6Option configures an IPv6Cipher.
+type IPv6Option func(*IPv6Cipher)
+
+// WithPrefixLen leaves the first bits of addresses in the clear, so that they
+// stay in their subnet. bits is limited to 0 to 127, at least one bit is
+// encrypted.
+func WithPrefixLen(bits int) IPv6Option {
+	return func(c *IPv6Cipher) {
+		switch {
+		case bits < 0:
+			bits = 0
+		case bits > 127:
+			bits = 127
+		}
+		c.prefixLen = bits
+	}
+}
+
+// WithMappedIPv4 keeps IPv4-mapped addresses, ::ffff:a.b.c.d, in the mapped
+// range, encrypting only the IPv4 address, and other addresses out of it.
+func WithMappedIPv4() IPv6Option {
+	return func(c *IPv6Cipher) {
+		c.mapped = true
+	}
+}
+
+// An IPv6Cipher encrypts IPv6 addresses to other addresses, written in the
+// canonical form of RFC 5952. The 128 bits are encrypted as 16 bytes.
+//
+// A prefix length that is not a multiple of 8 leaves bits of a byte that is
+// encrypted, which is then encrypted again until the ciphertext has the prefix
+// (cycle walking). The same keeps addresses in or out of the mapped range with
+// WithMappedIPv4. A zone, as in fe80::1%eth0, is passed through.
+type IPv6Cipher struct {
+	cipher    *ff1.Cipher
+	prefixLen int
+	mapped    bool
+}
+
+// IPv6 returns an IPv6Cipher encrypting addresses with cipher, whose alphabet
+// must have all 256 bytes.
+func IPv6(cipher *ff1.Cipher, opts ...IPv6Option) *IPv6Cipher {
+	c := &IPv6Cipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the address addr, such as "2001:db8::1".
+func (c *IPv6Cipher) Encrypt(addr string) (string, error) {
+	return c.crypt(addr, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *IPv6Cipher) Decrypt(addr string) (string, error) {
+	return c.crypt(addr, false)
+}
+
+func (c *IPv6Cipher) crypt(addr string, encrypt bool) (string, error) {
+	if len(c.cipher.Alphabet()) != 256 {
+		return "", ErrBinaryAlphabet
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil || !ip.Is6() {
+		return "", ErrIPv6Format
+	}
+
+	mapped := ip.Is4In6()
+	bits := c.prefixLen
+	if c.mapped && mapped && bits < mappedPrefixLen {
+		bits = mappedPrefixLen
+	}
+	orig := ip.As16()
+	b := orig
+	X := b[bits/8:]
+	inRange := func() bool {
+		if c.mapped && netip.AddrFrom16(b).Is4In6() != mapped {
+			return false
+		}
+		if bits%8 == 0 {
+			return true
+		}
+		mask := byte(0xff) << (8 - bits%8)
+		return b[bits/8]&mask == orig[bits/8]&mask
+	}
+
+	walks := maxWalk << (bits % 8)
+	for i := 0; i < walks; i++ {
+		if encrypt {
+			_, err = c.cipher.EncryptInto(X, X)
+		} else {
+			_, err = c.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return "", fmt.Errorf("formats: %w", err)
+		}
+		if inRange() {
+			return netip.AddrFrom16(b).WithZone(ip.Zone()).String(), nil
+		}
+	}
+	return "", fmt.Errorf("formats: address still outside its range after %d rounds of cycle walking", walks)
+}
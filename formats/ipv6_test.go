@@ -0,0 +1,146 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func TestIPv6(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, testCase := range []struct {
+		ip         *IPv6Cipher
+		addr       string
+		ciphertext string
+		plaintext  string
+	}{
+		{IPv6(c), "2001:db8::1", "baa4:3de2:f2cb:a3f2:9dd4:52bd:ea48:3a4f", ""},
+		{IPv6(c), "2001:0DB8:0000:0000:0000:0000:0000:0001", "baa4:3de2:f2cb:a3f2:9dd4:52bd:ea48:3a4f", "2001:db8::1"},
+		{IPv6(c), "::", "d28d:daeb:476d:ec5e:b990:bc6c:58f0:f321", ""},
+		{IPv6(c), "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "e2a:b26a:87e5:4393:7155:347:802d:94a6", ""},
+		{IPv6(c), "fe80::1%eth0", "c45d:6af3:b86b:1bf9:6fc5:8b0a:4d94:24e3%eth0", ""},
+		{IPv6(c), "::ffff:192.0.2.1", "fc10:8de5:aafe:87dd:593c:f206:4e99:d92e", ""},
+		{IPv6(c, WithPrefixLen(64)), "2001:db8::1", "2001:db8::fc7a:4a5d:6700:e100", ""},
+		{IPv6(c, WithPrefixLen(64)), "::", "::590c:4593:ccb3:5dbd", ""},
+		{IPv6(c, WithPrefixLen(64)), "fe80::1%eth0", "fe80::fc7a:4a5d:6700:e100%eth0", ""},
+		{IPv6(c, WithPrefixLen(52)), "2001:db8::1", "2001:db8:0:89e:7662:7e22:661d:2bf", ""},
+		{IPv6(c, WithPrefixLen(52)), "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "ffff:ffff:ffff:f505:c9c1:987a:e5a6:fa19", ""},
+		{IPv6(c, WithMappedIPv4()), "::ffff:192.0.2.1", "::ffff:136.150.56.53", ""},
+		{IPv6(c, WithMappedIPv4()), "2001:db8::1", "baa4:3de2:f2cb:a3f2:9dd4:52bd:ea48:3a4f", ""},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.ip.Encrypt(testCase.addr)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.ip.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			expected := testCase.plaintext
+			if expected == "" {
+				expected = testCase.addr
+			}
+			if plaintext != expected {
+				t.Fatalf("Got %q, expected %q", plaintext, expected)
+			}
+		})
+	}
+}
+
+func TestIPv6Prefix(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, bits := range []int{0, 1, 7, 48, 61, 96, 120, 127} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ip := IPv6(c, WithPrefixLen(bits))
+			for i := 0; i < 64; i++ {
+				addr := netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 0xff, 0x00, byte(i), 12: 0xc0, 15: byte(i)})
+				ciphertext, err := ip.Encrypt(addr.String())
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				got, err := netip.ParseAddr(ciphertext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				prefix := netip.PrefixFrom(addr, bits).Masked()
+				if !prefix.Contains(got) {
+					t.Fatalf("Got %s for %s, outside %s", got, addr, prefix)
+				}
+				if plaintext, _ := ip.Decrypt(ciphertext); plaintext != addr.String() {
+					t.Fatalf("Got %q, expected %q", plaintext, addr)
+				}
+			}
+		})
+	}
+}
+
+func TestIPv6Mapped(t *testing.T) {
+	ip := IPv6(newBinaryCipher(t), WithMappedIPv4(), WithPrefixLen(8))
+	mapped := netip.MustParsePrefix("::ffff:0:0/96")
+	for i := 0; i < 256; i++ {
+		for _, addr := range []netip.Addr{
+			netip.AddrFrom16([16]byte{10: 0xff, 11: 0xff, 12: 10, 15: byte(i)}),
+			netip.AddrFrom16([16]byte{15: byte(i)}),
+		} {
+			ciphertext, err := ip.Encrypt(addr.String())
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			got := netip.MustParseAddr(ciphertext)
+			if mapped.Contains(got) != mapped.Contains(addr) || got.As16()[0] != 0 {
+				t.Fatalf("Got %s for %s", got, addr)
+			}
+			if plaintext, _ := ip.Decrypt(ciphertext); plaintext != addr.String() {
+				t.Fatalf("Got %q, expected %q", plaintext, addr)
+			}
+		}
+	}
+}
+
+func TestIPv6Errors(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, testCase := range []struct {
+		ip   *IPv6Cipher
+		addr string
+		err  error
+	}{
+		{IPv6(c), "", ErrIPv6Format},
+		{IPv6(c), "10.1.2.3", ErrIPv6Format},
+		{IPv6(c), "2001:db8::1::2", ErrIPv6Format},
+		{IPv6(c), "2001:db8:0:0:0:0:0:0:1", ErrIPv6Format},
+		{IPv6(c), "2001:db8::g", ErrIPv6Format},
+		{IPv6(c), "[2001:db8::1]", ErrIPv6Format},
+		{IPv6(c), "2001:db8::1/64", ErrIPv6Format},
+		{IPv6(newFormatCipher(t, "0123456789abcdef")), "2001:db8::1", ErrBinaryAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := testCase.ip.Encrypt(testCase.addr); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
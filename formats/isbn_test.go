@@ -0,0 +1,122 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestISBN13(t *testing.T) {
+	ib := ISBN13(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		isbn       string
+		ciphertext string
+	}{
+		{"978-0-306-40615-7", "978-4-127-75074-1"},
+		{"9780306406157", "9784127750741"},
+		{"979-10-90636-07-1", "979-14-43649-01-7"},
+		{"978 3 16 148410 0", "978 8 06 029382 2"},
+		{"978-1-4028-9462-6", "978-4-2839-7528-6"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := ib.Encrypt(testCase.isbn)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := ib.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.isbn {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.isbn)
+			}
+		})
+	}
+}
+
+func TestISBN13Checksum(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	ib := ISBN13(c)
+	validating := ISBN13(c, WithISBNValidation())
+	for i := 0; i < 1000; i++ {
+		D := []byte(fmt.Sprintf("97%d%09d0", 8+i%2, i*104729%1000000000))
+		D[12] = byte('0' + (10-eanSum(D))%10)
+		for _, isbn := range []string{string(D), string(D[:3]) + "-" + string(D[3:12]) + "-" + string(D[12:])} {
+			ciphertext, err := validating.Encrypt(isbn)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if eanSum([]byte(strings.ReplaceAll(ciphertext, "-", ""))) != 0 || ciphertext[:3] != isbn[:3] {
+				t.Fatalf("Got %q for %q", ciphertext, isbn)
+			}
+			if plaintext, _ := validating.Decrypt(ciphertext); plaintext != isbn {
+				t.Fatalf("Got %q, expected %q", plaintext, isbn)
+			}
+		}
+
+		// A wrong check digit stays wrong by as much, and is restored
+		D[12] = '0' + (D[12]-'0'+1)%10
+		ciphertext, err := ib.Encrypt(string(D))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if eanSum([]byte(ciphertext)) != eanSum(D) {
+			t.Fatalf("Got %q for %q", ciphertext, D)
+		}
+		if plaintext, _ := ib.Decrypt(ciphertext); plaintext != string(D) {
+			t.Fatalf("Got %q, expected %q", plaintext, D)
+		}
+		if _, err := validating.Encrypt(string(D)); !errors.Is(err, ErrISBNChecksum) {
+			t.Fatalf("Got %v, expected ErrISBNChecksum", err)
+		}
+	}
+}
+
+func TestISBN13Errors(t *testing.T) {
+	ib := ISBN13(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		isbn string
+		err  error
+	}{
+		{"", ErrISBNFormat},
+		{"0-306-40615-2", ErrISBNFormat},
+		{"977-0-306-40615-7", ErrISBNFormat},
+		{"978-0-306-40615", ErrISBNFormat},
+		{"978-0-306-40615-77", ErrISBNFormat},
+		{"978.0.306.40615.7", ErrISBNFormat},
+		{"ISBN 978-0-306-40615-7", ErrISBNFormat},
+		{"978-0-306-40615-X", ErrISBNFormat},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := ib.Encrypt(testCase.isbn); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+	if _, err := ISBN13(newFormatCipher(t, "0123456789X")).Encrypt("978-0-306-40615-7"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected ErrAlphabet", err)
+	}
+}
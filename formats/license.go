@@ -0,0 +1,256 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// checkMark marks the position of the check character in the templates of a
+// DriversLicenseFormat.
+const checkMark = '#'
+
+var (
+	// ErrDriversLicenseState is returned, wrapped with the state, for a state
+	// without a DriversLicenseFormat.
+	ErrDriversLicenseState = errors.New("formats: no driver's license format for the state")
+
+	// ErrDriversLicenseFormat is returned, in a DriversLicenseFormatError, for a
+	// value that matches none of the templates of its state.
+	ErrDriversLicenseFormat = errors.New("formats: not a driver's license number of the state")
+
+	// ErrDriversLicenseChecksum is returned, wrapped with the number, for a
+	// number whose check character is wrong.
+	ErrDriversLicenseChecksum = errors.New("formats: driver's license number fails its check")
+
+	// ErrDriversLicenseTemplate is returned by Register, wrapped with the
+	// template, for a template with more than 10^18 numbers, a class with a
+	// character twice or a # without a Checksum or more than once, and for a
+	// format without templates or with a class for #.
+	ErrDriversLicenseTemplate = errors.New("formats: invalid driver's license template")
+
+	// ErrDriversLicenseRegistered is returned by Register, wrapped with the state,
+	// for a state that is registered already.
+	ErrDriversLicenseRegistered = errors.New("formats: driver's license state already registered")
+)
+
+// A DriversLicenseFormatError is returned for a value that matches none of the
+// templates of its state.
+type DriversLicenseFormatError struct {
+	State     string
+	License   string
+	Templates []string
+}
+
+func (e *DriversLicenseFormatError) Error() string {
+	return fmt.Sprintf("%v: %q for %s, whose numbers are written as %s", ErrDriversLicenseFormat, e.License, e.State, strings.Join(e.Templates, " or "))
+}
+
+func (e *DriversLicenseFormatError) Unwrap() error {
+	return ErrDriversLicenseFormat
+}
+
+// A DriversLicenseFormat describes the driver's license numbers of a state, in
+// templates as for a PostalFormat, such as "A9999999". No number should match
+// more than one template of a state.
+//
+// A # in a template stands for a check character, which Checksum computes from
+// the other characters of a number. It is given the number with a # in place of
+// the check character. Numbers are checked before they are encrypted, and get
+// the check character of the ciphertext after.
+type DriversLicenseFormat struct {
+	Templates []string
+	Classes   map[byte]string
+	Checksum  func(license string) byte
+}
+
+// A DriversLicenseRegistry maps states to the formats of their driver's license
+// numbers. It is safe for concurrent use, and its zero value is an empty
+// DriversLicenseRegistry ready to use.
+type DriversLicenseRegistry struct {
+	mu      sync.RWMutex
+	formats map[string]DriversLicenseFormat
+}
+
+// NewDriversLicenseRegistry returns a DriversLicenseRegistry with the driver's
+// license numbers of the ten most populous states, to which more states may be
+// added:
+//
+//	CA  A9999999
+//	TX  9999999 or 99999999
+//	FL  A999999999999
+//	NY  999999999
+//	PA  99999999
+//	IL  A99999999999
+//	OH  AA999999
+//	GA  7 to 9 digits
+//	NC  1 to 12 digits
+//	MI  A999999999999
+//
+// These cover the numbers issued today. The numbers of some states, such as
+// those of Florida and Illinois, are derived from the name and date of birth of
+// the holder, which ciphertexts do not follow.
+func NewDriversLicenseRegistry() *DriversLicenseRegistry {
+	digitRuns := func(min, max int) []string {
+		var templates []string
+		for n := min; n <= max; n++ {
+			templates = append(templates, strings.Repeat("9", n))
+		}
+		return templates
+	}
+	return &DriversLicenseRegistry{formats: map[string]DriversLicenseFormat{
+		"CA": {Templates: []string{"A9999999"}},
+		"TX": {Templates: digitRuns(7, 8)},
+		"FL": {Templates: []string{"A999999999999"}},
+		"NY": {Templates: []string{"999999999"}},
+		"PA": {Templates: []string{"99999999"}},
+		"IL": {Templates: []string{"A99999999999"}},
+		"OH": {Templates: []string{"AA999999"}},
+		"GA": {Templates: digitRuns(7, 9)},
+		"NC": {Templates: digitRuns(1, 12)},
+		"MI": {Templates: []string{"A999999999999"}},
+	}}
+}
+
+// Register adds the driver's license numbers of state. It fails if the state is
+// registered already or a template of format is invalid. The registry keeps a
+// copy of format.
+func (r *DriversLicenseRegistry) Register(state string, format DriversLicenseFormat) error {
+	if len(format.Templates) == 0 {
+		return fmt.Errorf("%w: no templates for %q", ErrDriversLicenseTemplate, state)
+	}
+	if _, ok := format.Classes[checkMark]; ok {
+		return fmt.Errorf("%w: a class for %q", ErrDriversLicenseTemplate, checkMark)
+	}
+	classes := make(map[byte]string, len(format.Classes))
+	for b, class := range format.Classes {
+		classes[b] = class
+	}
+	for _, template := range format.Templates {
+		marks := strings.Count(template, string(checkMark))
+		if !validTemplate(classes, template) || marks > 1 || marks == 1 && format.Checksum == nil {
+			return fmt.Errorf("%w: %q", ErrDriversLicenseTemplate, template)
+		}
+	}
+	format.Templates = append([]string{}, format.Templates...)
+	format.Classes = classes
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.formats[state]; ok {
+		return fmt.Errorf("%w: %q", ErrDriversLicenseRegistered, state)
+	}
+	if r.formats == nil {
+		r.formats = make(map[string]DriversLicenseFormat)
+	}
+	r.formats[state] = format
+	return nil
+}
+
+// States returns the registered states, sorted.
+func (r *DriversLicenseRegistry) States() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.formats))
+	for name := range r.formats {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (r *DriversLicenseRegistry) lookup(state string) (DriversLicenseFormat, bool) {
+	r.mu.RLock()
+	format, ok := r.formats[state]
+	r.mu.RUnlock()
+	return format, ok
+}
+
+// A DriversLicenseCipher encrypts driver's license numbers to numbers of the
+// same state and layout. Each character stays in the class of its position in
+// the template the number matches, and check characters are computed for the
+// ciphertext, so ciphertexts pass as numbers of their state.
+type DriversLicenseCipher struct {
+	cipher   *ff1.Cipher
+	registry *DriversLicenseRegistry
+}
+
+// DriversLicense returns a DriversLicenseCipher encrypting the driver's license
+// numbers of the states of registry, or of NewDriversLicenseRegistry if it is
+// nil, with cipher, which must have the alphabet 0123456789. States registered
+// later are encrypted too.
+func DriversLicense(cipher *ff1.Cipher, registry *DriversLicenseRegistry) *DriversLicenseCipher {
+	if registry == nil {
+		registry = NewDriversLicenseRegistry()
+	}
+	return &DriversLicenseCipher{cipher: cipher, registry: registry}
+}
+
+// Encrypt encrypts license, a driver's license number of state.
+func (c *DriversLicenseCipher) Encrypt(state, license string) (string, error) {
+	return c.crypt(state, license, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *DriversLicenseCipher) Decrypt(state, license string) (string, error) {
+	return c.crypt(state, license, false)
+}
+
+func (c *DriversLicenseCipher) crypt(state, license string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	format, ok := c.registry.lookup(state)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrDriversLicenseState, state)
+	}
+
+	for _, template := range format.Templates {
+		if len(license) != len(template) {
+			continue
+		}
+		// The check character stands for itself while the rest is encrypted
+		value := license
+		at := strings.IndexByte(template, checkMark)
+		if at >= 0 {
+			value = license[:at] + string(checkMark) + license[at+1:]
+		}
+		matched, ok := matchTemplate(format.Classes, template, value)
+		if !ok {
+			continue
+		}
+		if at >= 0 && format.Checksum(value) != license[at] {
+			return "", fmt.Errorf("%w: %q", ErrDriversLicenseChecksum, license)
+		}
+		out, err := cryptTemplate(c.cipher, matched, value, encrypt)
+		if err != nil || at < 0 {
+			return out, err
+		}
+		return out[:at] + string(format.Checksum(out)) + out[at+1:], nil
+	}
+	return "", &DriversLicenseFormatError{State: state, License: license, Templates: append([]string{}, format.Templates...)}
+}
@@ -0,0 +1,239 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// licensePatterns are the patterns of the numbers of NewDriversLicenseRegistry
+var licensePatterns = map[string]*regexp.Regexp{
+	"CA": regexp.MustCompile(`^[A-Z][0-9]{7}$`),
+	"TX": regexp.MustCompile(`^[0-9]{7,8}$`),
+	"FL": regexp.MustCompile(`^[A-Z][0-9]{12}$`),
+	"NY": regexp.MustCompile(`^[0-9]{9}$`),
+	"PA": regexp.MustCompile(`^[0-9]{8}$`),
+	"IL": regexp.MustCompile(`^[A-Z][0-9]{11}$`),
+	"OH": regexp.MustCompile(`^[A-Z]{2}[0-9]{6}$`),
+	"GA": regexp.MustCompile(`^[0-9]{7,9}$`),
+	"NC": regexp.MustCompile(`^[0-9]{1,12}$`),
+	"MI": regexp.MustCompile(`^[A-Z][0-9]{12}$`),
+}
+
+// testCheckDigit weighs the digits of a number with their positions
+func testCheckDigit(license string) byte {
+	sum := 0
+	for i := 0; i < len(license); i++ {
+		if b := license[i]; isDigit(b) {
+			sum += int(b-'0') * (i + 1)
+		}
+	}
+	return byte('0' + sum%10)
+}
+
+func TestDriversLicense(t *testing.T) {
+	dl := DriversLicense(newFormatCipher(t, "0123456789"), nil)
+	for idx, testCase := range []struct {
+		state      string
+		license    string
+		ciphertext string
+	}{
+		{"CA", "D1234567", "H3848238"},
+		{"TX", "12345678", "14009665"},
+		{"TX", "1234567", "9577202"},
+		{"FL", "S530460720010", "S682056473317"},
+		{"NY", "123456789", "321956935"},
+		{"PA", "12345678", "14009665"},
+		{"IL", "S53046072001", "I03284382231"},
+		{"OH", "AB123456", "FB496548"},
+		{"GA", "1234567", "9577202"},
+		{"GA", "123456789", "321956935"},
+		{"NC", "4", "6"},
+		{"NC", "000000012345", "869265901343"},
+		{"MI", "S530460720010", "S682056473317"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := dl.Encrypt(testCase.state, testCase.license)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := dl.Decrypt(testCase.state, ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.license {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.license)
+			}
+		})
+	}
+}
+
+// Ciphertexts of many numbers follow the grammar of their state
+func TestDriversLicenseGrammar(t *testing.T) {
+	dl := DriversLicense(newFormatCipher(t, "0123456789"), nil)
+	for idx, state := range NewDriversLicenseRegistry().States() {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			format, _ := dl.registry.lookup(state)
+			for _, license := range format.Templates {
+				for i := 0; i < 20; i++ {
+					ciphertext, err := dl.Encrypt(state, license)
+					if err != nil {
+						t.Fatalf("%v", err)
+					}
+					if !licensePatterns[state].MatchString(ciphertext) || len(ciphertext) != len(license) {
+						t.Fatalf("Got %q for %q", ciphertext, license)
+					}
+					if plaintext, _ := dl.Decrypt(state, ciphertext); plaintext != license {
+						t.Fatalf("Got %q, expected %q", plaintext, license)
+					}
+					license = ciphertext
+				}
+			}
+		})
+	}
+}
+
+func TestDriversLicenseChecksum(t *testing.T) {
+	var registry DriversLicenseRegistry
+	err := registry.Register("XX", DriversLicenseFormat{
+		Templates: []string{"A999999#", "AA#-999"},
+		Checksum:  testCheckDigit,
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	dl := DriversLicense(newFormatCipher(t, "0123456789"), &registry)
+	for idx, testCase := range []struct {
+		license    string
+		ciphertext string
+	}{
+		{"K1234562", "K5546241"},
+		{"AB8-123", "AS9-776"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := dl.Encrypt("XX", testCase.license)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := dl.Decrypt("XX", ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.license {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.license)
+			}
+		})
+	}
+
+	license := "Z0000000"
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		license = license[:7] + string(testCheckDigit(license[:7]+"#"))
+		ciphertext, err := dl.Encrypt("XX", license)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if seen[ciphertext] || ciphertext[7] != testCheckDigit(ciphertext[:7]+"#") {
+			t.Fatalf("Got %q for %q", ciphertext, license)
+		}
+		seen[ciphertext] = true
+		license = ciphertext
+	}
+
+	if _, err := dl.Encrypt("XX", "K1234563"); !errors.Is(err, ErrDriversLicenseChecksum) {
+		t.Fatalf("Got %v, expected %v", err, ErrDriversLicenseChecksum)
+	}
+}
+
+func TestDriversLicenseRegister(t *testing.T) {
+	registry := NewDriversLicenseRegistry()
+	for idx, testCase := range []struct {
+		state  string
+		format DriversLicenseFormat
+		err    error
+	}{
+		{"CA", DriversLicenseFormat{Templates: []string{"A9999999"}}, ErrDriversLicenseRegistered},
+		{"WA", DriversLicenseFormat{}, ErrDriversLicenseTemplate},
+		{"WA", DriversLicenseFormat{Templates: []string{"AAAAAAA99#AA"}}, ErrDriversLicenseTemplate},
+		{"WA", DriversLicenseFormat{Templates: []string{"AAAAAAA9#9#"}, Checksum: testCheckDigit}, ErrDriversLicenseTemplate},
+		{"WA", DriversLicenseFormat{Templates: []string{"AAAAAAA99#"}, Classes: map[byte]string{'#': "0123456789"}, Checksum: testCheckDigit}, ErrDriversLicenseTemplate},
+		{"WA", DriversLicenseFormat{Templates: []string{"9999999999999999999"}}, ErrDriversLicenseTemplate},
+		{"WA", DriversLicenseFormat{Templates: []string{"AAAAAAA99#AA"}, Checksum: testCheckDigit}, nil},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if err := registry.Register(testCase.state, testCase.format); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+	states := []string{"CA", "FL", "GA", "IL", "MI", "NC", "NY", "OH", "PA", "TX", "WA"}
+	if got := registry.States(); !reflect.DeepEqual(got, states) {
+		t.Fatalf("Got %q, expected %q", got, states)
+	}
+}
+
+func TestDriversLicenseErrors(t *testing.T) {
+	dl := DriversLicense(newFormatCipher(t, "0123456789"), nil)
+	for idx, testCase := range []struct {
+		state   string
+		license string
+		err     error
+	}{
+		{"CA", "D123456", ErrDriversLicenseFormat},
+		{"CA", "d1234567", ErrDriversLicenseFormat},
+		{"CA", "12345678", ErrDriversLicenseFormat},
+		{"TX", "123456", ErrDriversLicenseFormat},
+		{"NY", "123 456 789", ErrDriversLicenseFormat},
+		{"OH", "A1234567", ErrDriversLicenseFormat},
+		{"NC", "", ErrDriversLicenseFormat},
+		{"NC", "1234567890123", ErrDriversLicenseFormat},
+		{"ca", "D1234567", ErrDriversLicenseState},
+		{"WY", "123456789", ErrDriversLicenseState},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := dl.Encrypt(testCase.state, testCase.license)
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+			var formatErr *DriversLicenseFormatError
+			if errors.As(err, &formatErr) && (formatErr.State != testCase.state || formatErr.License != testCase.license || len(formatErr.Templates) == 0) {
+				t.Fatalf("Got %+v", formatErr)
+			}
+		})
+	}
+
+	if _, err := DriversLicense(newFormatCipher(t, "0123456789ABCDEF"), nil).Encrypt("CA", "D1234567"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected %v", err, ErrAlphabet)
+	}
+	want := `formats: not a driver's license number of the state: "D123" for CA, whose numbers are written as A9999999`
+	if _, err := dl.Encrypt("CA", "D123"); err == nil || err.Error() != want {
+		t.Fatalf("Got %v, expected %s", err, want)
+	}
+}
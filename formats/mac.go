@@ -0,0 +1,176 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// flagBits are the multicast (I/G) and locally administered (U/L) bits of the
+// first octet of a MAC address.
+const flagBits = 0x03
+
+// ErrMACFormat is returned for a value that is not six octets in one of the
+// notations 00:1a:2b:3c:4d:5e, 00-1A-2B-3C-4D-5E and 001a.2b3c.4d5e.
+var ErrMACFormat = errors.New("formats: not a MAC address")
+
+// A MACOption configures a MACCipher.
+type MACOption func(*MACCipher)
+
+// WithFullMAC makes the MACCipher encrypt all six octets instead of keeping the
+// vendor prefix (OUI) in the clear. The multicast and locally administered bits
+// are still kept, see MACCipher.
+func WithFullMAC() MACOption {
+	return func(c *MACCipher) {
+		c.full = true
+	}
+}
+
+// A MACCipher encrypts MAC addresses to other MAC addresses, written in the
+// notation and letter case of the input.
+//
+// By default the first three octets, the OUI, are kept and the other three are
+// encrypted as bytes, so the vendor stays recognizable and so do the multicast
+// and locally administered bits of the first octet. With WithFullMAC these two
+// bits are kept by encrypting again until the ciphertext has them as well
+// (cycle walking), so unicast addresses stay unicast and universal ones
+// universal.
+//
+// Addresses with upper case letters come out in upper case, and are encrypted
+// again until the ciphertext has letters, so that the case is not lost. Others
+// come out in lower case.
+type MACCipher struct {
+	cipher *ff1.Cipher
+	full   bool
+}
+
+// MAC returns a MACCipher encrypting addresses with cipher, whose alphabet must
+// have all 256 bytes.
+func MAC(cipher *ff1.Cipher, opts ...MACOption) *MACCipher {
+	c := &MACCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the address addr.
+func (c *MACCipher) Encrypt(addr string) (string, error) {
+	return c.crypt(addr, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *MACCipher) Decrypt(addr string) (string, error) {
+	return c.crypt(addr, false)
+}
+
+func (c *MACCipher) crypt(addr string, encrypt bool) (string, error) {
+	if len(c.cipher.Alphabet()) != 256 {
+		return "", ErrBinaryAlphabet
+	}
+	octets, ok := parseMAC(addr)
+	if !ok {
+		return "", ErrMACFormat
+	}
+
+	flags := octets[0] & flagBits
+	upper := strings.ToLower(addr) != addr
+	X := octets[3:]
+	if c.full {
+		X = octets
+	}
+	for i := 0; i < maxWalk; i++ {
+		var err error
+		if encrypt {
+			_, err = c.cipher.EncryptInto(X, X)
+		} else {
+			_, err = c.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return "", fmt.Errorf("formats: %w", err)
+		}
+		if octets[0]&flagBits == flags && (!upper || hasLetters(octets)) {
+			return formatMAC(addr, octets, upper), nil
+		}
+	}
+	return "", fmt.Errorf("formats: address still out of its range after %d rounds of cycle walking", maxWalk)
+}
+
+// macGroups returns the separator of addr and the number of hex digits between
+// separators.
+func macGroups(addr string) (sep byte, group int, ok bool) {
+	switch {
+	case len(addr) == 17 && (addr[2] == ':' || addr[2] == '-'):
+		return addr[2], 2, true
+	case len(addr) == 14 && addr[4] == '.':
+		return '.', 4, true
+	}
+	return 0, 0, false
+}
+
+// parseMAC returns the six octets of addr.
+func parseMAC(addr string) ([]byte, bool) {
+	sep, group, ok := macGroups(addr)
+	if !ok {
+		return nil, false
+	}
+	digits := make([]byte, 0, 12)
+	for i := 0; i < len(addr); i++ {
+		if i%(group+1) == group {
+			if addr[i] != sep {
+				return nil, false
+			}
+			continue
+		}
+		digits = append(digits, addr[i])
+	}
+	octets, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return nil, false
+	}
+	return octets, true
+}
+
+// hasLetters reports whether octets have a hex digit from a to f.
+func hasLetters(octets []byte) bool {
+	return strings.Trim(hex.EncodeToString(octets), "0123456789") != ""
+}
+
+// formatMAC writes octets in the notation of addr, in upper or lower case.
+func formatMAC(addr string, octets []byte, upper bool) string {
+	sep, group, _ := macGroups(addr)
+	digits := hex.EncodeToString(octets)
+	if upper {
+		digits = strings.ToUpper(digits)
+	}
+	var b strings.Builder
+	for i := 0; i < len(digits); i += group {
+		if i > 0 {
+			b.WriteByte(sep)
+		}
+		b.WriteString(digits[i : i+group])
+	}
+	return b.String()
+}
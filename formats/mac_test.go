@@ -0,0 +1,116 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMAC(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, testCase := range []struct {
+		mac        *MACCipher
+		addr       string
+		ciphertext string
+	}{
+		{MAC(c), "00:1a:2b:3c:4d:5e", "00:1a:2b:12:b1:27"},
+		{MAC(c), "00-1A-2B-3C-4D-5E", "00-1A-2B-12-B1-27"},
+		{MAC(c), "001a.2b3c.4d5e", "001a.2b12.b127"},
+		{MAC(c), "00:11:22:33:44:55", "00:11:22:1a:87:c2"},
+		{MAC(c), "FF-FF-FF-FF-FF-FF", "FF-FF-FF-07-07-64"},
+		{MAC(c), "02:00:5e:10:00:01", "02:00:5e:98:f3:76"},
+		{MAC(c, WithFullMAC()), "00:1a:2b:3c:4d:5e", "7c:09:3d:ce:7a:cb"},
+		{MAC(c, WithFullMAC()), "00-1A-2B-3C-4D-5E", "7C-09-3D-CE-7A-CB"},
+		{MAC(c, WithFullMAC()), "001a.2b3c.4d5e", "7c09.3dce.7acb"},
+		{MAC(c, WithFullMAC()), "FF-FF-FF-FF-FF-FF", "1B-C0-2B-74-FF-40"},
+		{MAC(c, WithFullMAC()), "02:00:5e:10:00:01", "0a:c1:4e:35:1b:1d"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.mac.Encrypt(testCase.addr)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.mac.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.addr {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.addr)
+			}
+		})
+	}
+}
+
+func TestMACRoundTrip(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, mac := range []*MACCipher{MAC(c), MAC(c, WithFullMAC())} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			for i := 0; i < 512; i++ {
+				// Upper case addresses without letters in the OUI, and some
+				// without any
+				addr := fmt.Sprintf("%02X-%02X-%02X-%02X-%02X-%02X", i%4, 0x10, 0x20, i/2, byte(i), byte(i*7))
+				ciphertext, err := mac.Encrypt(addr)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				octets, ok := parseMAC(ciphertext)
+				upper := strings.ToLower(addr) != addr
+				if !ok || octets[0]&flagBits != byte(i%4) || upper && (strings.ToUpper(ciphertext) != ciphertext || !hasLetters(octets)) {
+					t.Fatalf("Got %q for %q", ciphertext, addr)
+				}
+				if plaintext, _ := mac.Decrypt(ciphertext); plaintext != addr {
+					t.Fatalf("Got %q, expected %q", plaintext, addr)
+				}
+			}
+		})
+	}
+}
+
+func TestMACErrors(t *testing.T) {
+	c := newBinaryCipher(t)
+	for idx, testCase := range []struct {
+		mac  *MACCipher
+		addr string
+		err  error
+	}{
+		{MAC(c), "", ErrMACFormat},
+		{MAC(c), "00:1a:2b:3c:4d", ErrMACFormat},
+		{MAC(c), "00:1a:2b:3c:4d:5e:6f", ErrMACFormat},
+		{MAC(c), "00:1a-2b:3c:4d:5e", ErrMACFormat},
+		{MAC(c), "00:1a:2b:3c:4d:5g", ErrMACFormat},
+		{MAC(c), "001a2b3c4d5e", ErrMACFormat},
+		{MAC(c), "001a:2b3c:4d5e", ErrMACFormat},
+		{MAC(c), "00.1a.2b.3c.4d.5e", ErrMACFormat},
+		{MAC(c), "001a.2b3c.4d5", ErrMACFormat},
+		{MAC(newFormatCipher(t, "0123456789abcdef")), "00:1a:2b:3c:4d:5e", ErrBinaryAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := testCase.mac.Encrypt(testCase.addr); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
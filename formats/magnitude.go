@@ -0,0 +1,144 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// ErrMagnitudeFormat is returned for a value that is not an integer: digits
+// without leading zeros, optionally after a sign.
+var ErrMagnitudeFormat = errors.New("formats: not an integer")
+
+// A MagnitudeOption configures a MagnitudeCipher.
+type MagnitudeOption func(*MagnitudeCipher)
+
+// WithKeptLeadingDigit makes the MagnitudeCipher keep the first digit of an
+// integer and encrypt the others to any digits, so that 7,123,456 stays between
+// 7,000,000 and 7,999,999. Integers of a single digit are then kept.
+func WithKeptLeadingDigit() MagnitudeOption {
+	return func(c *MagnitudeCipher) {
+		c.keepLeading = true
+	}
+}
+
+// A MagnitudeCipher encrypts integers to integers of the same number of digits,
+// and so of the same order of magnitude, such as the figures of a report. All
+// digits are encrypted, encrypting again until the first is not 0 (cycle
+// walking), which takes 1.1 rounds on average. A sign is kept.
+//
+// 0 is kept, being the only integer of one digit below 1, and the integers 1 to
+// 9 are encrypted to each other. Integers with fewer digits than the minimum
+// length of the cipher are ranked among those of their length and the rank
+// encrypted.
+type MagnitudeCipher struct {
+	cipher      *ff1.Cipher
+	keepLeading bool
+}
+
+// Magnitude returns a MagnitudeCipher encrypting integers with cipher, which
+// must have the alphabet 0123456789.
+func Magnitude(cipher *ff1.Cipher, opts ...MagnitudeOption) *MagnitudeCipher {
+	c := &MagnitudeCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the integer number.
+func (c *MagnitudeCipher) Encrypt(number string) (string, error) {
+	return c.crypt(number, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *MagnitudeCipher) Decrypt(number string) (string, error) {
+	return c.crypt(number, false)
+}
+
+func (c *MagnitudeCipher) crypt(number string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	D := []byte(number)
+	if len(D) > 0 && (D[0] == '-' || D[0] == '+') {
+		D = D[1:]
+	}
+	if len(D) == 0 || !allDigits(string(D)) || len(D) > 1 && D[0] == '0' {
+		return "", ErrMagnitudeFormat
+	}
+
+	switch {
+	case c.keepLeading:
+		if len(D) > 1 {
+			if err := c.cryptDigits(D[1:], false, encrypt); err != nil {
+				return "", err
+			}
+		}
+	case D[0] != '0':
+		if err := c.cryptDigits(D, true, encrypt); err != nil {
+			return "", err
+		}
+	}
+	return number[:len(number)-len(D)] + string(D), nil
+}
+
+// cryptDigits encrypts or decrypts the digits X in place. With nonzero, the
+// first of them is not 0 and stays so.
+func (c *MagnitudeCipher) cryptDigits(X []byte, nonzero, encrypt bool) error {
+	if len(X) < c.cipher.MinLen() {
+		size := uint64(1)
+		for range X {
+			size *= 10
+		}
+		var low uint64
+		if nonzero {
+			low = size / 10
+		}
+		value, _ := strconv.ParseUint(string(X), 10, 64)
+		rank, err := cryptRank(c.cipher, value-low, size-low, encrypt)
+		if err != nil {
+			return err
+		}
+		copy(X, fmt.Sprintf("%0*d", len(X), rank+low))
+		return nil
+	}
+
+	for i := 0; i < maxWalk; i++ {
+		var err error
+		if encrypt {
+			_, err = c.cipher.EncryptInto(X, X)
+		} else {
+			_, err = c.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return fmt.Errorf("formats: %w", err)
+		}
+		if !nonzero || X[0] != '0' {
+			return nil
+		}
+	}
+	return fmt.Errorf("formats: leading digit still 0 after %d rounds of cycle walking", maxWalk)
+}
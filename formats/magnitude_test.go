@@ -0,0 +1,123 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMagnitude(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		magnitudes *MagnitudeCipher
+		number     string
+		ciphertext string
+	}{
+		{Magnitude(c), "1234567", "9577202"},
+		{Magnitude(c), "-1234567", "-9577202"},
+		{Magnitude(c), "+42", "+18"},
+		{Magnitude(c), "10", "24"},
+		{Magnitude(c), "7", "2"},
+		{Magnitude(c), "0", "0"},
+		{Magnitude(c), "-0", "-0"},
+		{Magnitude(c), "98765432109876543210987654321", "90982123555066534142014655530"},
+		{Magnitude(c, WithKeptLeadingDigit()), "7123456", "7144973"},
+		{Magnitude(c, WithKeptLeadingDigit()), "1234567", "1394017"},
+		{Magnitude(c, WithKeptLeadingDigit()), "+42", "+40"},
+		{Magnitude(c, WithKeptLeadingDigit()), "7", "7"},
+		{Magnitude(c, WithKeptLeadingDigit()), "98765432109876543210987654321", "91231680975445195499570661099"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.magnitudes.Encrypt(testCase.number)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.magnitudes.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.number {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.number)
+			}
+		})
+	}
+}
+
+// Random integers keep their number of digits and a first digit that is not 0
+func TestMagnitudeSample(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	rng := rand.New(rand.NewSource(1))
+	for idx, magnitudes := range []*MagnitudeCipher{Magnitude(c), Magnitude(c, WithKeptLeadingDigit())} {
+		for i := 0; i < 5000; i++ {
+			number := fmt.Sprint(rng.Int63n(1 << uint(rng.Intn(63))))
+			ciphertext, err := magnitudes.Encrypt(number)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if len(ciphertext) != len(number) || len(ciphertext) > 1 && ciphertext[0] == '0' || idx == 1 && ciphertext[0] != number[0] {
+				t.Fatalf("Got %q for %q", ciphertext, number)
+			}
+			if plaintext, _ := magnitudes.Decrypt(ciphertext); plaintext != number {
+				t.Fatalf("Got %q, expected %q", plaintext, number)
+			}
+		}
+	}
+}
+
+// The integers of one and two digits are permuted among themselves
+func TestMagnitudeShort(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, magnitudes := range []*MagnitudeCipher{Magnitude(c), Magnitude(c, WithKeptLeadingDigit())} {
+		seen := map[string]bool{}
+		for n := 0; n < 100; n++ {
+			ciphertext, err := magnitudes.Encrypt(fmt.Sprint(n))
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if len(ciphertext) != len(fmt.Sprint(n)) || idx == 1 && ciphertext[0] != fmt.Sprint(n)[0] || seen[ciphertext] {
+				t.Fatalf("Got %q for %d", ciphertext, n)
+			}
+			seen[ciphertext] = true
+		}
+	}
+}
+
+func TestMagnitudeErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, number := range []string{"", "-", "+-1", "0123", "-00", "1,234", "12.5", "1e6", " 12", strings.Repeat("١", 3)} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			for _, magnitudes := range []*MagnitudeCipher{Magnitude(c), Magnitude(c, WithKeptLeadingDigit())} {
+				if _, err := magnitudes.Encrypt(number); !errors.Is(err, ErrMagnitudeFormat) {
+					t.Fatalf("Got %v, expected %v", err, ErrMagnitudeFormat)
+				}
+			}
+		})
+	}
+	if _, err := Magnitude(newFormatCipher(t, "0123456789ABCDEF")).Encrypt("1234"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected %v", err, ErrAlphabet)
+	}
+}
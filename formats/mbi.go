@@ -0,0 +1,160 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+const (
+	mbiLetters = "ACDEFGHJKMNPQRTUVWXY" // the letters without S, L, O, I, B and Z
+	mbiDigits  = "0123456789"
+)
+
+// mbiClasses are the alphabets of the 11 positions of an MBI.
+var mbiClasses = [11]string{
+	"123456789",
+	mbiLetters,
+	mbiDigits + mbiLetters,
+	mbiDigits,
+	mbiLetters,
+	mbiDigits + mbiLetters,
+	mbiDigits,
+	mbiLetters,
+	mbiLetters,
+	mbiDigits,
+	mbiDigits,
+}
+
+// ErrMBIFormat is returned, in an MBIFormatError, for a value that is not an MBI.
+var ErrMBIFormat = errors.New("formats: not an MBI")
+
+// An MBIFormatError is returned for a value that is not an MBI. Offset is the
+// position of the first unexpected byte, or the length of the value if it has
+// the wrong length.
+type MBIFormatError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *MBIFormatError) Error() string {
+	return fmt.Sprintf("%v: %s at position %d", ErrMBIFormat, e.Msg, e.Offset)
+}
+
+func (e *MBIFormatError) Unwrap() error {
+	return ErrMBIFormat
+}
+
+// An MBICipher encrypts Medicare Beneficiary Identifiers to MBIs.
+//
+// Each of the 11 positions of an MBI has its own characters: a digit but 0,
+// letters but S, L, O, I, B and Z, either of those, or any digit. An MBI is
+// ranked as its index among all of them, in a mixed radix of the sizes of these
+// alphabets, which is encrypted as 14 decimal digits. Ciphertexts beyond the
+// last MBI are encrypted again until one is not (cycle walking), which takes
+// about 8 rounds on average. MBIs written with dashes, as in 1EG4-TE5-MK73,
+// come out with dashes.
+type MBICipher struct {
+	cipher *ff1.Cipher
+}
+
+// MBI returns an MBICipher encrypting MBIs with cipher, which must have the
+// alphabet 0123456789.
+func MBI(cipher *ff1.Cipher) *MBICipher {
+	return &MBICipher{cipher: cipher}
+}
+
+// Encrypt encrypts mbi.
+func (c *MBICipher) Encrypt(mbi string) (string, error) {
+	return c.crypt(mbi, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *MBICipher) Decrypt(mbi string) (string, error) {
+	return c.crypt(mbi, false)
+}
+
+// mbiSize returns the number of MBIs.
+func mbiSize() uint64 {
+	size := uint64(1)
+	for _, class := range mbiClasses {
+		size *= uint64(len(class))
+	}
+	return size
+}
+
+func (c *MBICipher) crypt(mbi string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	dashed := len(mbi) == 13
+	M, err := parseMBI(mbi)
+	if err != nil {
+		return "", err
+	}
+
+	var rank uint64
+	for i, class := range mbiClasses {
+		rank = rank*uint64(len(class)) + uint64(strings.IndexByte(class, M[i]))
+	}
+	rank, err = cryptRank(c.cipher, rank, mbiSize(), encrypt)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(mbiClasses) - 1; i >= 0; i-- {
+		class := mbiClasses[i]
+		M[i] = class[rank%uint64(len(class))]
+		rank /= uint64(len(class))
+	}
+	if dashed {
+		return string(M[:4]) + "-" + string(M[4:7]) + "-" + string(M[7:]), nil
+	}
+	return string(M), nil
+}
+
+// parseMBI returns the 11 characters of mbi, written without dashes or with
+// dashes after the fourth and seventh.
+func parseMBI(mbi string) ([]byte, error) {
+	if len(mbi) != 11 && len(mbi) != 13 {
+		return nil, &MBIFormatError{Offset: len(mbi), Msg: fmt.Sprintf("length %d instead of 11 or 13", len(mbi))}
+	}
+	M := make([]byte, 0, 11)
+	for i := 0; i < len(mbi); i++ {
+		ch := mbi[i]
+		if len(mbi) == 13 && (i == 4 || i == 8) {
+			if ch != '-' {
+				return nil, &MBIFormatError{Offset: i, Msg: fmt.Sprintf("%q instead of '-'", ch)}
+			}
+			continue
+		}
+		class := mbiClasses[len(M)]
+		if strings.IndexByte(class, ch) < 0 {
+			return nil, &MBIFormatError{Offset: i, Msg: fmt.Sprintf("%q is not one of %s", ch, class)}
+		}
+		M = append(M, ch)
+	}
+	return M, nil
+}
@@ -0,0 +1,126 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+// mbiGrammar checks MBIs independently of mbiClasses.
+var mbiGrammar = regexp.MustCompile(`^[1-9][AC-HJKMNP-RT-Y][AC-HJKMNP-RT-Y0-9][0-9][AC-HJKMNP-RT-Y][AC-HJKMNP-RT-Y0-9][0-9][AC-HJKMNP-RT-Y]{2}[0-9]{2}$`)
+
+func TestMBI(t *testing.T) {
+	m := MBI(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		mbi        string
+		ciphertext string
+	}{
+		{"1EG4TE5MK73", "5DW0H71DA88"},
+		{"1EG4-TE5-MK73", "5DW0-H71-DA88"},
+		{"1AA0AA0AA00", "2P42X90XF62"},
+		{"9YY9YY9YY99", "1K81PU9RW69"},
+		{"5C01D02EF34", "9EJ2MC3TY85"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := m.Encrypt(testCase.mbi)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := m.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.mbi {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.mbi)
+			}
+		})
+	}
+}
+
+func TestMBIRandom(t *testing.T) {
+	m := MBI(newFormatCipher(t, "0123456789"))
+	rng := rand.New(rand.NewSource(1))
+	seen := map[string]string{}
+	for i := 0; i < 5000; i++ {
+		M := make([]byte, len(mbiClasses))
+		for j, class := range mbiClasses {
+			M[j] = class[rng.Intn(len(class))]
+		}
+		mbi := string(M)
+		if !mbiGrammar.MatchString(mbi) {
+			t.Fatalf("Generated %q, not an MBI", mbi)
+		}
+		ciphertext, err := m.Encrypt(mbi)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !mbiGrammar.MatchString(ciphertext) {
+			t.Fatalf("Got %q for %q, not an MBI", ciphertext, mbi)
+		}
+		if other, ok := seen[ciphertext]; ok && other != mbi {
+			t.Fatalf("Got %q for %q and %q", ciphertext, other, mbi)
+		}
+		seen[ciphertext] = mbi
+		if plaintext, _ := m.Decrypt(ciphertext); plaintext != mbi {
+			t.Fatalf("Got %q, expected %q", plaintext, mbi)
+		}
+	}
+}
+
+func TestMBIErrors(t *testing.T) {
+	m := MBI(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		mbi    string
+		offset int
+	}{
+		{"", 0},
+		{"1EG4TE5MK7", 10},
+		{"1EG4TE5MK733", 12},
+		{"0EG4TE5MK73", 0},
+		{"1SG4TE5MK73", 1},
+		{"1EI4TE5MK73", 2},
+		{"1EGATE5MK73", 3},
+		{"1EG4BE5MK73", 4},
+		{"1EG4TE5MO73", 8},
+		{"1EG4TE5MK7A", 10},
+		{"1eg4te5mk73", 1},
+		{"1EG4 TE5 MK73", 4},
+		{"1EG4-TE5-MZ73", 10},
+		{"1EG4-TE5M-K73", 8},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := m.Encrypt(testCase.mbi)
+			var formatErr *MBIFormatError
+			if !errors.Is(err, ErrMBIFormat) || !errors.As(err, &formatErr) || formatErr.Offset != testCase.offset {
+				t.Fatalf("Got %v, expected an error at position %d", err, testCase.offset)
+			}
+		})
+	}
+	if _, err := MBI(newFormatCipher(t, "0123456789A")).Encrypt("1EG4TE5MK73"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected ErrAlphabet", err)
+	}
+}
@@ -0,0 +1,276 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+const (
+	mrzLineLen = 44
+	mrzFiller  = '<'
+
+	// mrzAlphanumerics are the characters of document numbers
+	mrzAlphanumerics = "0123456789" + upperLetters
+
+	// mrzDays is the number of days from 2000-01-01 to 2099-12-31, the dates
+	// two-digit years stand for
+	mrzDays = 36525
+)
+
+// Fields of the second line of a TD3 MRZ, as [start, end) with the check digit
+// at end
+var (
+	mrzDocument = [2]int{0, 9}
+	mrzBirth    = [2]int{13, 19}
+	mrzExpiry   = [2]int{21, 27}
+	mrzOptional = [2]int{28, 42}
+)
+
+var (
+	// ErrMRZFormat is returned, wrapped with the reason, for a value that is not
+	// the two lines of 44 characters of a TD3 machine readable zone.
+	ErrMRZFormat = errors.New("formats: not a passport MRZ")
+
+	// ErrMRZChecksum is returned, wrapped with the field, for an MRZ with a wrong
+	// check digit.
+	ErrMRZChecksum = errors.New("formats: MRZ fails a check digit")
+)
+
+// An MRZOption configures an MRZCipher.
+type MRZOption func(*MRZCipher)
+
+// WithEncryptedNames makes the MRZCipher encrypt the names of the holder too.
+// Each name keeps its length and the fillers between names stay in place.
+func WithEncryptedNames() MRZOption {
+	return func(c *MRZCipher) {
+		c.names = true
+	}
+}
+
+// WithEncryptedNationality makes the MRZCipher encrypt the nationality of the
+// holder too, to three letters that need not be the code of a state.
+func WithEncryptedNationality() MRZOption {
+	return func(c *MRZCipher) {
+		c.nationality = true
+	}
+}
+
+// An MRZCipher encrypts the machine readable zones of passports, the two lines
+// of the TD3 format of ICAO 9303, to MRZs whose check digits are all correct.
+//
+// The document number, the date of birth and the date of expiry are encrypted.
+// The document number keeps its length, the fillers padding it stay, and each
+// of its characters becomes a digit or letter. Dates become other dates of the
+// hundred years their two-digit years stand for; dates with unknown parts,
+// written with fillers, are kept. The check digits of the three fields and the
+// composite check digit are computed for the ciphertext. Everything else is
+// kept unless an option says otherwise.
+//
+// The MRZ is given as its two lines, either one after the other or separated by
+// a newline. Document numbers of more than nine characters, which continue in
+// the optional data, are not supported.
+type MRZCipher struct {
+	cipher      *ff1.Cipher
+	names       bool
+	nationality bool
+}
+
+// MRZ returns an MRZCipher encrypting passport MRZs with cipher, which must have
+// the alphabet 0123456789.
+func MRZ(cipher *ff1.Cipher, opts ...MRZOption) *MRZCipher {
+	c := &MRZCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts mrz.
+func (c *MRZCipher) Encrypt(mrz string) (string, error) {
+	return c.crypt(mrz, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *MRZCipher) Decrypt(mrz string) (string, error) {
+	return c.crypt(mrz, false)
+}
+
+func (c *MRZCipher) crypt(mrz string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	sep := ""
+	if len(mrz) == 2*mrzLineLen+1 && mrz[mrzLineLen] == '\n' {
+		sep = "\n"
+	}
+	if len(mrz) != 2*mrzLineLen+len(sep) {
+		return "", fmt.Errorf("%w: %d characters instead of two lines of %d", ErrMRZFormat, len(mrz), mrzLineLen)
+	}
+	line1 := []byte(mrz[:mrzLineLen])
+	line2 := []byte(mrz[mrzLineLen+len(sep):])
+	for _, line := range [][]byte{line1, line2} {
+		if strings.Trim(string(line), mrzAlphanumerics+string(mrzFiller)) != "" {
+			return "", fmt.Errorf("%w: characters other than digits, upper-case letters and %q", ErrMRZFormat, mrzFiller)
+		}
+	}
+	if line1[0] != 'P' {
+		return "", fmt.Errorf("%w: document code %q is not a passport", ErrMRZFormat, line1[:2])
+	}
+	for _, field := range []struct {
+		name string
+		span [2]int
+	}{{"document number", mrzDocument}, {"date of birth", mrzBirth}, {"date of expiry", mrzExpiry}, {"optional data", mrzOptional}} {
+		check := line2[field.span[1]]
+		if check != mrzCheckDigit(line2[field.span[0]:field.span[1]]) &&
+			!(field.span == mrzOptional && check == mrzFiller && strings.Trim(string(line2[field.span[0]:field.span[1]]), "<") == "") {
+			return "", fmt.Errorf("%w: %s", ErrMRZChecksum, field.name)
+		}
+	}
+	if line2[43] != mrzCheckDigit(mrzComposite(line2)) {
+		return "", fmt.Errorf("%w: composite", ErrMRZChecksum)
+	}
+
+	if err := c.cryptDocument(line2[mrzDocument[0]:mrzDocument[1]], encrypt); err != nil {
+		return "", err
+	}
+	for _, span := range [][2]int{mrzBirth, mrzExpiry} {
+		if err := c.cryptDate(line2[span[0]:span[1]], encrypt); err != nil {
+			return "", err
+		}
+	}
+	if c.nationality {
+		if err := c.cryptLetters(line2[10:13], encrypt); err != nil {
+			return "", err
+		}
+	}
+	if c.names {
+		if err := c.cryptLetters(line1[5:], encrypt); err != nil {
+			return "", err
+		}
+	}
+
+	for _, span := range [][2]int{mrzDocument, mrzBirth, mrzExpiry} {
+		line2[span[1]] = mrzCheckDigit(line2[span[0]:span[1]])
+	}
+	line2[43] = mrzCheckDigit(mrzComposite(line2))
+	return string(line1) + sep + string(line2), nil
+}
+
+// cryptDocument encrypts or decrypts the document number field in place.
+func (c *MRZCipher) cryptDocument(field []byte, encrypt bool) error {
+	n := bytes.IndexByte(field, mrzFiller)
+	if n < 0 {
+		n = len(field)
+	}
+	if n == 0 || strings.Trim(string(field[n:]), string(mrzFiller)) != "" {
+		return fmt.Errorf("%w: document number %q", ErrMRZFormat, field)
+	}
+	matched := make([]string, n)
+	for i := range matched {
+		matched[i] = mrzAlphanumerics
+	}
+	number, err := cryptTemplate(c.cipher, matched, string(field[:n]), encrypt)
+	if err != nil {
+		return err
+	}
+	copy(field, number)
+	return nil
+}
+
+// cryptDate encrypts or decrypts the date field YYMMDD in place, unless it has
+// fillers.
+func (c *MRZCipher) cryptDate(field []byte, encrypt bool) error {
+	if bytes.IndexByte(field, mrzFiller) >= 0 {
+		return nil
+	}
+	t, err := time.Parse("060102", string(field))
+	if err != nil {
+		return fmt.Errorf("%w: date %q", ErrMRZFormat, field)
+	}
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	rank := dayNumber(time.Date(2000+t.Year()%100, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)) - dayNumber(start)
+	offset, err := cryptRank(c.cipher, uint64(rank), mrzDays, encrypt)
+	if err != nil {
+		return err
+	}
+	copy(field, start.AddDate(0, 0, int(offset)).Format("060102"))
+	return nil
+}
+
+// cryptLetters encrypts or decrypts each run of letters of field in place.
+func (c *MRZCipher) cryptLetters(field []byte, encrypt bool) error {
+	for at := 0; at < len(field); {
+		if field[at] == mrzFiller {
+			at++
+			continue
+		}
+		run := at
+		for at < len(field) && field[at] != mrzFiller {
+			at++
+		}
+		if strings.Trim(string(field[run:at]), upperLetters) != "" {
+			return fmt.Errorf("%w: %q is not letters", ErrMRZFormat, field[run:at])
+		}
+		matched := make([]string, at-run)
+		for i := range matched {
+			matched[i] = upperLetters
+		}
+		letters, err := cryptTemplate(c.cipher, matched, string(field[run:at]), encrypt)
+		if err != nil {
+			return err
+		}
+		copy(field[run:], letters)
+	}
+	return nil
+}
+
+// mrzComposite returns the characters of the second line the composite check
+// digit is computed over.
+func mrzComposite(line2 []byte) []byte {
+	composite := append([]byte{}, line2[0:10]...)
+	composite = append(composite, line2[13:20]...)
+	return append(composite, line2[21:43]...)
+}
+
+// mrzCheckDigit returns the check digit of ICAO 9303 of field: its characters,
+// with letters counting as 10 to 35 and fillers as 0, weighted by 7, 3 and 1 in
+// turn, modulo 10.
+func mrzCheckDigit(field []byte) byte {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i, b := range field {
+		v := 0
+		switch {
+		case isDigit(b):
+			v = int(b - '0')
+		case isUpper(b):
+			v = int(b-'A') + 10
+		}
+		sum += v * weights[i%3]
+	}
+	return byte('0' + sum%10)
+}
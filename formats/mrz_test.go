@@ -0,0 +1,223 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The specimen of ICAO 9303 part 4
+const (
+	specimenLine1 = "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<"
+	specimenLine2 = "L898902C36UTO7408122F1204159ZE184226B<<<<<10"
+)
+
+// icaoCheck computes a check digit of ICAO 9303 independently of the code under
+// test
+func icaoCheck(s string) string {
+	sum := 0
+	for i, ch := range s {
+		v := strings.IndexRune("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ", ch)
+		if ch == '<' {
+			v = 0
+		}
+		sum += v * []int{7, 3, 1}[i%3]
+	}
+	return fmt.Sprint(sum % 10)
+}
+
+// mrzLine2 builds a second line with correct check digits
+func mrzLine2(document, nationality, birth, sex, expiry, optional string) string {
+	document += strings.Repeat("<", 9-len(document))
+	optional += strings.Repeat("<", 14-len(optional))
+	line := document + icaoCheck(document) + nationality + birth + icaoCheck(birth) + sex + expiry + icaoCheck(expiry) + optional + icaoCheck(optional)
+	return line + icaoCheck(line[0:10]+line[13:20]+line[21:43])
+}
+
+// validMRZ reports whether all check digits of the second line are correct
+func validMRZ(line2 string) bool {
+	return len(line2) == 44 &&
+		line2[9:10] == icaoCheck(line2[0:9]) &&
+		line2[19:20] == icaoCheck(line2[13:19]) &&
+		line2[27:28] == icaoCheck(line2[21:27]) &&
+		line2[42:43] == icaoCheck(line2[28:42]) &&
+		line2[43:44] == icaoCheck(line2[0:10]+line2[13:20]+line2[21:43])
+}
+
+func TestMRZ(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	all := MRZ(c, WithEncryptedNames(), WithEncryptedNationality())
+	for idx, testCase := range []struct {
+		mrzs       *MRZCipher
+		mrz        string
+		ciphertext string
+	}{
+		{MRZ(c), specimenLine1 + specimenLine2, specimenLine1 + "5GNIBXZGA1UTO7602171F4908159ZE184226B<<<<<12"},
+		{MRZ(c), specimenLine1 + "\n" + specimenLine2, specimenLine1 + "\n5GNIBXZGA1UTO7602171F4908159ZE184226B<<<<<12"},
+		{all, specimenLine1 + specimenLine2, "P<UTOCOSBPRKK<<KHXR<WGZGA<<<<<<<<<<<<<<<<<<<5GNIBXZGA1IGY7602171F4908159ZE184226B<<<<<12"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if !validMRZ(mrzLine2("L898902C3", "UTO", "740812", "F", "120415", "ZE184226B")) || mrzLine2("L898902C3", "UTO", "740812", "F", "120415", "ZE184226B") != specimenLine2 {
+				t.Fatalf("The test routine does not reproduce the specimen")
+			}
+			ciphertext, err := testCase.mrzs.Encrypt(testCase.mrz)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.mrzs.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.mrz {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.mrz)
+			}
+		})
+	}
+}
+
+// Chains of encryptions of MRZs of different shapes stay valid
+func TestMRZChains(t *testing.T) {
+	m := MRZ(newFormatCipher(t, "0123456789"), WithEncryptedNames())
+	fillers := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '<' {
+				return r
+			}
+			return 'A'
+		}, s)
+	}
+	for idx, testCase := range []struct {
+		line1 string
+		line2 string
+	}{
+		{specimenLine1, specimenLine2},
+		{"P<D<<MUSTERMANN<<ERIKA<<<<<<<<<<<<<<<<<<<<<<", mrzLine2("C01X00T47", "D<<", "640812", "F", "270802", "")},
+		{"PPCHEA<<X<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<", mrzLine2("X1", "CHE", "0002<<", "<", "000229", "12345678901234")},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			mrz := testCase.line1 + testCase.line2
+			for i := 0; i < 50; i++ {
+				ciphertext, err := m.Encrypt(mrz)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				line1, line2 := ciphertext[:44], ciphertext[44:]
+				if !validMRZ(line2) || fillers(line1) != fillers(testCase.line1) {
+					t.Fatalf("Got %q for %q", ciphertext, mrz)
+				}
+				// Fillers, the dates with unknown parts and the fields that are kept
+				for _, at := range []int{10, 11, 12, 20, 28, 40, 41} {
+					if line2[at] != testCase.line2[at] {
+						t.Fatalf("Got %q for %q", ciphertext, mrz)
+					}
+				}
+				if strings.Count(line2[:9], "<") != strings.Count(testCase.line2[:9], "<") || strings.Contains(testCase.line2[13:19], "<") && line2[13:19] != testCase.line2[13:19] {
+					t.Fatalf("Got %q for %q", ciphertext, mrz)
+				}
+				if plaintext, _ := m.Decrypt(ciphertext); plaintext != mrz {
+					t.Fatalf("Got %q, expected %q", plaintext, mrz)
+				}
+				mrz = ciphertext
+			}
+		})
+	}
+}
+
+// Names longer than 12 letters have more values than a rank of cryptRank holds
+func TestMRZLongNames(t *testing.T) {
+	m := MRZ(newFormatCipher(t, "0123456789"), WithEncryptedNames())
+	for idx, name := range []string{
+		"ABCDEFGHIJKLM",
+		"AAADWBUQOEDUZDLO",
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZABCDEFGHIJKLM",
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			line1 := "P<UTO" + name
+			line1 += strings.Repeat("<", 44-len(line1))
+			mrz := line1 + specimenLine2
+			firsts := map[byte]bool{}
+			for i := 0; i < 50; i++ {
+				ciphertext, err := m.Encrypt(mrz)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				encrypted := ciphertext[5 : 5+len(name)]
+				if strings.Trim(encrypted, upperLetters) != "" || ciphertext[5+len(name):44] != line1[5+len(name):] {
+					t.Fatalf("Got %q for %q", ciphertext, mrz)
+				}
+				if plaintext, _ := m.Decrypt(ciphertext); plaintext != mrz {
+					t.Fatalf("Got %q, expected %q", plaintext, mrz)
+				}
+				firsts[encrypted[0]] = true
+				mrz = ciphertext
+			}
+			// The leading letters are encrypted too
+			if len(firsts) < 10 {
+				t.Fatalf("Got %d first letters in 50 encryptions", len(firsts))
+			}
+		})
+	}
+}
+
+func TestMRZErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	replace := func(s string, at int, ch string) string { return s[:at] + ch + s[at+1:] }
+	for idx, testCase := range []struct {
+		mrz string
+		err error
+	}{
+		{specimenLine1 + specimenLine2[:43], ErrMRZFormat},
+		{specimenLine1 + "\r\n" + specimenLine2, ErrMRZFormat},
+		{strings.ToLower(specimenLine1) + specimenLine2, ErrMRZFormat},
+		{"I" + specimenLine1[1:] + specimenLine2, ErrMRZFormat},
+		{specimenLine1 + replace(specimenLine2, 9, "7"), ErrMRZChecksum},
+		{specimenLine1 + replace(specimenLine2, 19, "3"), ErrMRZChecksum},
+		{specimenLine1 + replace(specimenLine2, 27, "0"), ErrMRZChecksum},
+		{specimenLine1 + replace(specimenLine2, 42, "2"), ErrMRZChecksum},
+		{specimenLine1 + replace(specimenLine2, 43, "1"), ErrMRZChecksum},
+		{specimenLine1 + replace(specimenLine2, 0, "M"), ErrMRZChecksum},
+		{specimenLine1 + mrzLine2("<L898902C", "UTO", "740812", "F", "120415", ""), ErrMRZFormat},
+		{specimenLine1 + mrzLine2("L89<902C3", "UTO", "740812", "F", "120415", ""), ErrMRZFormat},
+		{specimenLine1 + mrzLine2("L898902C3", "UTO", "741312", "F", "120415", ""), ErrMRZFormat},
+		{specimenLine1 + mrzLine2("L898902C3", "UTO", "740812", "F", "130229", ""), ErrMRZFormat},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := MRZ(c).Encrypt(testCase.mrz); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+
+	// A filler for the check digit of empty optional data is accepted
+	line2 := mrzLine2("L898902C3", "UTO", "740812", "F", "120415", "")
+	line2 = line2[:42] + "<" + icaoCheck(line2[0:10]+line2[13:20]+line2[21:42]+"<")
+	if _, err := MRZ(c).Encrypt(specimenLine1 + line2); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := MRZ(newFormatCipher(t, "0123456789ABCDEF")).Encrypt(specimenLine1 + specimenLine2); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected %v", err, ErrAlphabet)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// nameLetters are the lower-case letters of the words of names: those of ASCII,
+// and the accented ones of Latin-1 and Latin Extended-A whose upper-case letter
+// turns back into them, so that the case of a letter can be applied to any
+// other.
+var nameLetters = [2][]rune{
+	[]rune("abcdefghijklmnopqrstuvwxyz"),
+	func() []rune {
+		var accented []rune
+		for r := rune(0xE0); r <= 0x17F; r++ {
+			if unicode.IsLower(r) && unicode.ToUpper(r) != r && unicode.ToLower(unicode.ToUpper(r)) == r {
+				accented = append(accented, r)
+			}
+		}
+		return accented
+	}(),
+}
+
+// ErrNameFormat is returned, wrapped with the letter, for a name with a letter
+// outside the letters a NameCipher encrypts, and for a name that is not UTF-8.
+var ErrNameFormat = errors.New("formats: name has a letter that cannot be encrypted")
+
+// A NameOption configures a NameCipher.
+type NameOption func(*NameCipher)
+
+// WithKeptShortWords makes the NameCipher keep the words with fewer letters
+// than the minimum length of the cipher, such as the initial of Anna M. Müller,
+// instead of encrypting them like the others.
+func WithKeptShortWords() NameOption {
+	return func(c *NameCipher) {
+		c.keepShort = true
+	}
+}
+
+// WithTransliteration makes the NameCipher replace the lower-case letters that
+// are keys of table by their values, such as ü by u, before encrypting or
+// decrypting. This cannot be undone: Decrypt returns the name transliterated.
+// The NameCipher keeps a copy of table.
+func WithTransliteration(table map[rune]rune) NameOption {
+	return func(c *NameCipher) {
+		c.transliteration = make(map[rune]rune, len(table))
+		for from, to := range table {
+			c.transliteration[from] = to
+		}
+	}
+}
+
+// A NameCipher encrypts personal names to strings with the same structure, for
+// demonstrations and test data. Each word, a run of letters, is encrypted on its
+// own to a word of as many letters, with upper-case letters where the word had
+// them, while everything between the words, such as spaces, hyphens and
+// apostrophes, is kept. Anna-Lena Müller becomes for example Khxr-Fmdi Lłjigh.
+//
+// The letters of ASCII stay letters of ASCII, and accented letters stay
+// accented letters, of those of Latin-1 and Latin Extended-A that have an
+// upper-case letter. A word is ranked as its index among the words with letters
+// of the same kinds, and the rank encrypted as decimal digits, encrypting again
+// until it is below their number (cycle walking), so words of any length are
+// encrypted, single letters among the 26 letters of ASCII.
+type NameCipher struct {
+	cipher          *ff1.Cipher
+	keepShort       bool
+	transliteration map[rune]rune
+}
+
+// Name returns a NameCipher encrypting names with cipher, which must have the
+// alphabet 0123456789.
+func Name(cipher *ff1.Cipher, opts ...NameOption) *NameCipher {
+	c := &NameCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts name.
+func (c *NameCipher) Encrypt(name string) (string, error) {
+	return c.crypt(name, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *NameCipher) Decrypt(name string) (string, error) {
+	return c.crypt(name, false)
+}
+
+func (c *NameCipher) crypt(name string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: %q is not UTF-8", ErrNameFormat, name)
+	}
+	runes := []rune(name)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) {
+			i++
+			continue
+		}
+		word := i
+		for i < len(runes) && unicode.IsLetter(runes[i]) {
+			i++
+		}
+		if err := c.cryptWord(runes[word:i], encrypt); err != nil {
+			return "", err
+		}
+	}
+	return string(runes), nil
+}
+
+// cryptWord encrypts or decrypts the letters of word in place.
+func (c *NameCipher) cryptWord(word []rune, encrypt bool) error {
+	// The word in lower case, with the kinds of its letters and where they
+	// were upper-case
+	kinds := make([]int, len(word))
+	upper := make([]bool, len(word))
+	for i, r := range word {
+		lower := unicode.ToLower(r)
+		if unicode.IsUpper(r) && unicode.ToUpper(lower) != r {
+			return fmt.Errorf("%w: %q", ErrNameFormat, r)
+		}
+		if to, ok := c.transliteration[lower]; ok {
+			lower = to
+		}
+		kind := nameLetterKind(lower)
+		if kind < 0 {
+			return fmt.Errorf("%w: %q", ErrNameFormat, r)
+		}
+		word[i], kinds[i], upper[i] = lower, kind, unicode.IsUpper(r)
+	}
+	if c.keepShort && len(word) < c.cipher.MinLen() {
+		applyCase(word, upper)
+		return nil
+	}
+
+	rank, size := new(big.Int), big.NewInt(1)
+	for i, r := range word {
+		letters := nameLetters[kinds[i]]
+		radix := big.NewInt(int64(len(letters)))
+		rank.Mul(rank, radix).Add(rank, big.NewInt(int64(runeIndex(letters, r))))
+		size.Mul(size, radix)
+	}
+	rank, err := cryptBigRank(c.cipher, rank, size, encrypt)
+	if err != nil {
+		return err
+	}
+	digit := new(big.Int)
+	for i := len(word) - 1; i >= 0; i-- {
+		letters := nameLetters[kinds[i]]
+		rank.DivMod(rank, big.NewInt(int64(len(letters))), digit)
+		word[i] = letters[digit.Int64()]
+	}
+	applyCase(word, upper)
+	return nil
+}
+
+// nameLetterKind returns the index in nameLetters of the letters with r, or -1.
+func nameLetterKind(r rune) int {
+	for kind, letters := range nameLetters {
+		if runeIndex(letters, r) >= 0 {
+			return kind
+		}
+	}
+	return -1
+}
+
+func runeIndex(runes []rune, r rune) int {
+	for i, s := range runes {
+		if s == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyCase turns the letters of word where upper is set to upper case.
+func applyCase(word []rune, upper []bool) {
+	for i := range word {
+		if upper[i] {
+			word[i] = unicode.ToUpper(word[i])
+		}
+	}
+}
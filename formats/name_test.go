@@ -0,0 +1,166 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"unicode"
+)
+
+// nameShape returns the structure of name: x for a lower-case letter of ASCII,
+// X for an upper-case one, a and A for other letters, and the rest as it is
+func nameShape(name string) string {
+	var shape []rune
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r):
+			shape = append(shape, r)
+		case r < unicode.MaxASCII && unicode.IsUpper(r):
+			shape = append(shape, 'X')
+		case r < unicode.MaxASCII:
+			shape = append(shape, 'x')
+		case unicode.IsUpper(r):
+			shape = append(shape, 'A')
+		default:
+			shape = append(shape, 'a')
+		}
+	}
+	return string(shape)
+}
+
+func TestName(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		names      *NameCipher
+		name       string
+		ciphertext string
+	}{
+		{Name(c), "Anna-Lena Müller", "Khxr-Fmdi Lłjigh"},
+		{Name(c), "O'Brien", "B'Uerzq"},
+		{Name(c), "Jean-Luc d’Artagnan", "Lkzw-Ckt u’Ahjgpwyq"},
+		{Name(c), "ZOË SØRENSEN", "FMĻ TŻDSOQUN"},
+		{Name(c), "José Álvarez", "Ixvķ Śmeruxd"},
+		{Name(c), "Łukasz Żółkiewski", "Þxulvs Ìúċhrmrarp"},
+		{Name(c), "Björn Ångström", "Hhÿtk Öhjxwjłr"},
+		{Name(c), "Maximilianus Alexandrinus Wolfeschlegelsteinhausenbergerdorff", "Ijtyqeduzwqk Hyfjdenzphku Jpeywntzdkutfrosysyzkvpirsqgasmvtoi"},
+		{Name(c), "Anna M. Müller", "Khxr G. Lłjigh"},
+		{Name(c, WithKeptShortWords()), "Anna M. Müller", "Khxr M. Lłjigh"},
+		{Name(c, WithKeptShortWords()), "O'Brien", "O'Uerzq"},
+		{Name(c, WithKeptShortWords()), "Jean-Luc d’Artagnan", "Lkzw-Ckt d’Ahjgpwyq"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.names.Encrypt(testCase.name)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			if nameShape(ciphertext) != nameShape(testCase.name) {
+				t.Fatalf("Got %q for %q", ciphertext, testCase.name)
+			}
+			plaintext, err := testCase.names.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.name {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.name)
+			}
+		})
+	}
+}
+
+// Chains of encryptions keep the structure of names
+func TestNameChains(t *testing.T) {
+	names := Name(newFormatCipher(t, "0123456789"))
+	for _, name := range []string{"Anna-Lena Müller", "Ö", "x", "ÉÉ"} {
+		shape := nameShape(name)
+		seen := map[string]bool{}
+		for i := 0; i < 100; i++ {
+			ciphertext, err := names.Encrypt(name)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if nameShape(ciphertext) != shape {
+				t.Fatalf("Got %q for %q", ciphertext, name)
+			}
+			if plaintext, _ := names.Decrypt(ciphertext); plaintext != name {
+				t.Fatalf("Got %q, expected %q", plaintext, name)
+			}
+			seen[ciphertext] = true
+			name = ciphertext
+		}
+		if len(seen) < 15 {
+			t.Fatalf("Got %d distinct names", len(seen))
+		}
+	}
+}
+
+func TestNameTransliteration(t *testing.T) {
+	table := map[rune]rune{'ü': 'u', 'é': 'e', 'ø': 'o'}
+	names := Name(newFormatCipher(t, "0123456789"), WithTransliteration(table))
+	table['ü'] = 'x'
+	for idx, testCase := range []struct {
+		name       string
+		ciphertext string
+		plaintext  string
+	}{
+		{"Anna-Lena Müller", "Khxr-Fmdi Acmzhb", "Anna-Lena Muller"},
+		{"José Álvarez", "Kpbm Śmeruxd", "Jose Álvarez"},
+		{"ZOË SØRENSEN", "FMĻ EPRWEKCP", "ZOË SORENSEN"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := names.Encrypt(testCase.name)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := names.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.plaintext {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.plaintext)
+			}
+		})
+	}
+}
+
+func TestNameErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, name := range []string{"Иван", "Straße", "İlker", "ǅemal", "Müller\xff"} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := Name(c).Encrypt(name); !errors.Is(err, ErrNameFormat) {
+				t.Fatalf("Got %v, expected %v", err, ErrNameFormat)
+			}
+		})
+	}
+	// Everything but letters is kept
+	if out, err := Name(c).Encrypt(" -'’. 123 "); err != nil || out != " -'’. 123 " {
+		t.Fatalf("Got %q, %v", out, err)
+	}
+	if _, err := Name(newFormatCipher(t, "0123456789ABCDEF")).Encrypt("Anna"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected %v", err, ErrAlphabet)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// npiPrefix is prefixed to NPIs for their Luhn check, making them card numbers
+// of the health industry (80) in the United States (840).
+const npiPrefix = "80840"
+
+var (
+	// ErrNPIFormat is returned for a value that is not 10 digits.
+	ErrNPIFormat = errors.New("formats: not an NPI")
+
+	// ErrNPIChecksum is returned, with WithNPIValidation, for an NPI whose check
+	// digit is wrong.
+	ErrNPIChecksum = errors.New("formats: NPI fails the Luhn check")
+
+	// ErrNPIPrefix is returned, with WithNPIEntityDigit, for an NPI that does not
+	// start with 1 or 2.
+	ErrNPIPrefix = errors.New("formats: NPI does not start with 1 or 2")
+)
+
+// An NPIOption configures an NPICipher.
+type NPIOption func(*NPICipher)
+
+// WithNPIValidation makes the NPICipher reject NPIs failing the Luhn check with
+// ErrNPIChecksum, instead of keeping them failing.
+func WithNPIValidation() NPIOption {
+	return func(c *NPICipher) {
+		c.validate = true
+	}
+}
+
+// WithNPIEntityDigit makes the NPICipher cycle-walk, encrypting again until the
+// first digit of the ciphertext is 1 or 2, as that of every NPI issued, which
+// takes 5 rounds on average. Plaintexts starting with another digit are then
+// rejected with ErrNPIPrefix. The digit is not kept, NPIs of individuals, which
+// start with 1, and of organizations, which start with 2, are encrypted to
+// either.
+func WithNPIEntityDigit() NPIOption {
+	return func(c *NPICipher) {
+		c.entity = true
+	}
+}
+
+// An NPICipher encrypts National Provider Identifiers, the 10 digit numbers of
+// health care providers in the United States. The first 9 digits are encrypted
+// and the tenth, the check digit, is chosen so that the Luhn checksum of the
+// NPI prefixed by 80840 is that of the input: valid NPIs stay valid, and any
+// NPI decrypts to itself.
+type NPICipher struct {
+	cipher   *ff1.Cipher
+	validate bool
+	entity   bool
+}
+
+// NPI returns an NPICipher encrypting NPIs with cipher, which must have the
+// alphabet 0123456789.
+func NPI(cipher *ff1.Cipher, opts ...NPIOption) *NPICipher {
+	c := &NPICipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the NPI npi.
+func (c *NPICipher) Encrypt(npi string) (string, error) {
+	return c.crypt(npi, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *NPICipher) Decrypt(npi string) (string, error) {
+	return c.crypt(npi, false)
+}
+
+func (c *NPICipher) crypt(npi string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	if len(npi) != 10 || !allDigits(npi) {
+		return "", ErrNPIFormat
+	}
+	D := []byte(npiPrefix + npi)
+	residue := luhnSum(D)
+	if c.validate && residue != 0 {
+		return "", ErrNPIChecksum
+	}
+	X := D[len(npiPrefix) : len(D)-1]
+	if c.entity && !npiEntity(X) {
+		return "", ErrNPIPrefix
+	}
+
+	for i := 0; ; i++ {
+		if i == maxWalk {
+			return "", fmt.Errorf("formats: first digit still not 1 or 2 after %d rounds of cycle walking", maxWalk)
+		}
+		var err error
+		if encrypt {
+			_, err = c.cipher.EncryptInto(X, X)
+		} else {
+			_, err = c.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return "", fmt.Errorf("formats: %w", err)
+		}
+		if !c.entity || npiEntity(X) {
+			break
+		}
+	}
+	D[len(D)-1] = '0'
+	D[len(D)-1] = luhnDigit((residue-luhnSum(D)+10)%10, false)
+	return string(D[len(npiPrefix):]), nil
+}
+
+// npiEntity reports whether the digits X start with 1 or 2.
+func npiEntity(X []byte) bool {
+	return X[0] == '1' || X[0] == '2'
+}
@@ -0,0 +1,129 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// validNPI runs the Luhn check over the NPI prefixed by 80840 independently of
+// the code under test
+func validNPI(npi string) bool {
+	number := "80840" + npi
+	sum := 0
+	for i := range number {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d = d*2/10 + d*2%10
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func TestNPI(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		npis       *NPICipher
+		npi        string
+		ciphertext string
+	}{
+		// The example of CMS
+		{NPI(c), "1234567893", "3219569357"},
+		{NPI(c), "1245319599", "4108321884"},
+		{NPI(c), "1003000126", "3880767066"},
+		{NPI(c, WithNPIEntityDigit()), "1234567893", "2744519796"},
+		{NPI(c, WithNPIEntityDigit()), "1245319599", "1430130967"},
+		{NPI(c, WithNPIValidation(), WithNPIEntityDigit()), "1003000126", "1912767484"},
+		// Invalid NPIs stay invalid without WithNPIValidation
+		{NPI(c), "2000000001", "8055473910"},
+		{NPI(c, WithNPIEntityDigit()), "1234567890", "2744519793"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.npis.Encrypt(testCase.npi)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			if validNPI(ciphertext) != validNPI(testCase.npi) {
+				t.Fatalf("Got %q for %q", ciphertext, testCase.npi)
+			}
+			plaintext, err := testCase.npis.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.npi {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.npi)
+			}
+		})
+	}
+}
+
+// Chains of encryptions stay valid and start with 1 or 2 with WithNPIEntityDigit
+func TestNPIChains(t *testing.T) {
+	npis := NPI(newFormatCipher(t, "0123456789"), WithNPIValidation(), WithNPIEntityDigit())
+	npi := "1234567893"
+	seen := map[string]bool{}
+	for i := 0; i < 500; i++ {
+		ciphertext, err := npis.Encrypt(npi)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !validNPI(ciphertext) || ciphertext[0] != '1' && ciphertext[0] != '2' {
+			t.Fatalf("Got %q for %q", ciphertext, npi)
+		}
+		if plaintext, _ := npis.Decrypt(ciphertext); plaintext != npi {
+			t.Fatalf("Got %q, expected %q", plaintext, npi)
+		}
+		seen[ciphertext] = true
+		npi = ciphertext
+	}
+	if len(seen) < 490 {
+		t.Fatalf("Got %d distinct NPIs", len(seen))
+	}
+}
+
+func TestNPIErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		npis *NPICipher
+		npi  string
+		err  error
+	}{
+		{NPI(c), "123456789", ErrNPIFormat},
+		{NPI(c), "12345678931", ErrNPIFormat},
+		{NPI(c), "123456789A", ErrNPIFormat},
+		{NPI(c), "1234-56789", ErrNPIFormat},
+		{NPI(c, WithNPIValidation()), "1234567890", ErrNPIChecksum},
+		{NPI(c, WithNPIValidation()), "2000000001", ErrNPIChecksum},
+		{NPI(c, WithNPIEntityDigit()), "3219569357", ErrNPIPrefix},
+		{NPI(newFormatCipher(t, "0123456789ABCDEF")), "1234567893", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := testCase.npis.Encrypt(testCase.npi); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrPANFormat is returned for a value that is not 13 to 19 digits,
+	// optionally separated by spaces or dashes.
+	ErrPANFormat = errors.New("formats: not a card number")
+
+	// ErrLuhn is returned, with WithLuhnValidation, for a card number whose Luhn
+	// check digit is wrong.
+	ErrLuhn = errors.New("formats: card number fails the Luhn check")
+)
+
+// A PANLengthError is returned for a card number with too few digits between
+// the ones kept in the clear to encrypt.
+type PANLengthError struct {
+	Digits    int // digits of the card number
+	KeepFirst int
+	KeepLast  int
+	MinLen    int // the minimum the cipher encrypts
+}
+
+func (e *PANLengthError) Error() string {
+	return fmt.Sprintf("formats: card number of %d digits keeping %d and %d leaves %d to encrypt, at least %d are needed",
+		e.Digits, e.KeepFirst, e.KeepLast, e.Digits-e.KeepFirst-e.KeepLast-1, e.MinLen)
+}
+
+// A PANOption configures a PANCipher.
+type PANOption func(*PANCipher)
+
+// WithKeptDigits sets how many leading and trailing digits are left in the
+// clear, 6 (the BIN) and 4 without it. Negative counts are treated as 0.
+func WithKeptDigits(first, last int) PANOption {
+	return func(p *PANCipher) {
+		if first < 0 {
+			first = 0
+		}
+		if last < 0 {
+			last = 0
+		}
+		p.keepFirst, p.keepLast = first, last
+	}
+}
+
+// WithLuhnValidation makes the PANCipher reject card numbers failing the Luhn
+// check with ErrLuhn, instead of keeping them failing.
+func WithLuhnValidation() PANOption {
+	return func(p *PANCipher) {
+		p.validate = true
+	}
+}
+
+// A PANCipher encrypts card numbers (primary account numbers) to card numbers
+// of the same layout.
+//
+// The digits between the kept ones are encrypted except for the last, which is
+// chosen so that the Luhn checksum of the result is that of the input: valid
+// numbers stay valid. The digit replaced carries no information of a valid
+// number, and decryption computes it back the same way, so any number of digits
+// and separators decrypts to itself. The last kept digit is usually the check
+// digit, which is why the checksum is fixed up in the middle.
+type PANCipher struct {
+	cipher    *ff1.Cipher
+	keepFirst int
+	keepLast  int
+	validate  bool
+}
+
+// PAN returns a PANCipher encrypting the middle digits of card numbers with
+// cipher, which must have the alphabet 0123456789.
+func PAN(cipher *ff1.Cipher, opts ...PANOption) *PANCipher {
+	p := &PANCipher{cipher: cipher, keepFirst: 6, keepLast: 4}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Encrypt encrypts the card number pan. Spaces and dashes stay where they are.
+func (p *PANCipher) Encrypt(pan string) (string, error) {
+	return p.crypt(pan, true)
+}
+
+// Decrypt reverses Encrypt.
+func (p *PANCipher) Decrypt(pan string) (string, error) {
+	return p.crypt(pan, false)
+}
+
+func (p *PANCipher) crypt(pan string, encrypt bool) (string, error) {
+	if !bytes.Equal(p.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	out := []byte(pan)
+	var pos []int
+	for i, b := range out {
+		switch {
+		case b >= '0' && b <= '9':
+			pos = append(pos, i)
+		case b != ' ' && b != '-':
+			return "", ErrPANFormat
+		}
+	}
+	if len(pos) < 13 || len(pos) > 19 {
+		return "", ErrPANFormat
+	}
+	D := make([]byte, len(pos))
+	for i, at := range pos {
+		D[i] = out[at]
+	}
+
+	fix := len(D) - p.keepLast - 1
+	if fix-p.keepFirst < p.cipher.MinLen() {
+		return "", &PANLengthError{Digits: len(D), KeepFirst: p.keepFirst, KeepLast: p.keepLast, MinLen: p.cipher.MinLen()}
+	}
+	residue := luhnSum(D)
+	if p.validate && residue != 0 {
+		return "", ErrLuhn
+	}
+
+	X := D[p.keepFirst:fix]
+	var err error
+	if encrypt {
+		_, err = p.cipher.EncryptInto(X, X)
+	} else {
+		_, err = p.cipher.DecryptInto(X, X)
+	}
+	if err != nil {
+		return "", fmt.Errorf("formats: %w", err)
+	}
+	D[fix] = '0'
+	D[fix] = luhnDigit((residue-luhnSum(D)+10)%10, (len(D)-fix)%2 == 0)
+
+	for i, at := range pos {
+		out[at] = D[i]
+	}
+	return string(out), nil
+}
+
+// luhnSum returns the Luhn checksum of the digits D modulo 10, which is 0 for
+// a valid number. Every second digit from the right, the check digit excluded,
+// is doubled, and the digits of the products are added.
+func luhnSum(D []byte) int {
+	sum := 0
+	for i := range D {
+		d := int(D[len(D)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum % 10
+}
+
+// luhnDigit returns the digit adding c to the Luhn checksum, at a position that
+// is doubled or not.
+func luhnDigit(c int, doubled bool) byte {
+	if !doubled {
+		return byte('0' + c)
+	}
+	if c%2 == 0 {
+		return byte('0' + c/2)
+	}
+	return byte('0' + (c+9)/2)
+}
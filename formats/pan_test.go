@@ -0,0 +1,168 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func digitsOf(s string) []byte {
+	return []byte(strings.NewReplacer(" ", "", "-", "").Replace(s))
+}
+
+func TestPAN(t *testing.T) {
+	p := PAN(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		pan        string
+		ciphertext string
+	}{
+		{"4222222222222", "4222227192222"},
+		{"378282246310005", "378282612110005"},
+		{"4111111111111111", "4111111643671111"},
+		{"4111 1111 1111 1111", "4111 1116 4367 1111"},
+		{"5555-5555-5555-4444", "5555-5575-5577-4444"},
+		{"6011000990139424", "6011005098709424"},
+		{"4111111111111111110", "4111116016901861110"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := p.Encrypt(testCase.pan)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			if luhnSum(digitsOf(ciphertext)) != 0 {
+				t.Fatalf("%q fails the Luhn check", ciphertext)
+			}
+			plaintext, err := p.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.pan {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.pan)
+			}
+		})
+	}
+}
+
+func TestPANLuhn(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	p := PAN(c)
+	validating := PAN(c, WithLuhnValidation())
+
+	// Invalid numbers keep their checksum and decrypt to themselves
+	for _, last := range "023456789" {
+		pan := "411111111111111" + string(last)
+		ciphertext, err := p.Encrypt(pan)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if luhnSum(digitsOf(ciphertext)) != luhnSum(digitsOf(pan)) {
+			t.Fatalf("Checksum of %q differs from %q", ciphertext, pan)
+		}
+		if plaintext, _ := p.Decrypt(ciphertext); plaintext != pan {
+			t.Fatalf("Got %q, expected %q", plaintext, pan)
+		}
+
+		if _, err := validating.Encrypt(pan); !errors.Is(err, ErrLuhn) {
+			t.Fatalf("Got %v, expected ErrLuhn", err)
+		}
+		if _, err := validating.Decrypt(pan); !errors.Is(err, ErrLuhn) {
+			t.Fatalf("Got %v, expected ErrLuhn", err)
+		}
+	}
+	if _, err := validating.Encrypt("4111-1111-1111-1111"); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestPANKeptDigits(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		first, last int
+		pan         string
+	}{
+		{0, 0, "4111111111111111"},
+		{0, 4, "4111111111111111"},
+		{8, 4, "4111111111111111"},
+		{6, 1, "378282246310005"},
+		{-1, 2, "4222222222222"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			p := PAN(c, WithKeptDigits(testCase.first, testCase.last))
+			ciphertext, err := p.Encrypt(testCase.pan)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			first := testCase.first
+			if first < 0 {
+				first = 0
+			}
+			n := len(testCase.pan)
+			if ciphertext[:first] != testCase.pan[:first] || ciphertext[n-testCase.last:] != testCase.pan[n-testCase.last:] {
+				t.Fatalf("Kept digits of %q changed in %q", testCase.pan, ciphertext)
+			}
+			if ciphertext[first:n-testCase.last] == testCase.pan[first:n-testCase.last] {
+				t.Fatalf("Middle of %q not encrypted", testCase.pan)
+			}
+			if plaintext, _ := p.Decrypt(ciphertext); plaintext != testCase.pan {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.pan)
+			}
+		})
+	}
+}
+
+func TestPANErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		p   *PANCipher
+		pan string
+		err error
+	}{
+		{PAN(c), "411111111111", ErrPANFormat},
+		{PAN(c), "41111111111111111111", ErrPANFormat},
+		{PAN(c), "4111.1111.1111.1111", ErrPANFormat},
+		{PAN(c), "4111 1111 1111 111x", ErrPANFormat},
+		{PAN(c), "", ErrPANFormat},
+		{PAN(c, WithKeptDigits(8, 4)), "4222222222222", &PANLengthError{}},
+		{PAN(c, WithKeptDigits(8, 6)), "4111111111111111", &PANLengthError{}},
+		{PAN(newFormatCipher(t, "01234567890a")), "4111111111111111", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.p.Encrypt(testCase.pan)
+			if lengthErr, ok := testCase.err.(*PANLengthError); ok {
+				if !errors.As(err, &lengthErr) {
+					t.Fatalf("Got %v, expected a PANLengthError", err)
+				}
+				if lengthErr.MinLen != 2 || lengthErr.Digits != len(testCase.pan) {
+					t.Fatalf("Got %+v", lengthErr)
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// PathAlphabet is an alphabet for the names in file paths that all common file
+// systems accept: letters, digits, "-" and "_".
+const PathAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+// ErrPathAlphabet is returned if the cipher has a slash, backslash or dot.
+var ErrPathAlphabet = errors.New("formats: cipher alphabet must not have path separators or dots")
+
+// A PathOption configures a PathCipher.
+type PathOption func(*PathCipher)
+
+// WithKeptComponents leaves the components of paths that are one of names,
+// such as "home" or "var", as they are.
+func WithKeptComponents(names ...string) PathOption {
+	return func(c *PathCipher) {
+		for _, name := range names {
+			c.keep[name] = true
+		}
+	}
+}
+
+// WithKeptExtensions sets how many extensions of the file name are left in the
+// clear, 1 without it: "report.tar.gz" keeps ".gz" by default and ".tar.gz"
+// with 2. With 0 they are encrypted like the rest of the name. Negative counts
+// are treated as 0.
+func WithKeptExtensions(n int) PathOption {
+	return func(c *PathCipher) {
+		if n < 0 {
+			n = 0
+		}
+		c.extensions = n
+	}
+}
+
+// A PathCipher encrypts file paths to paths with the same structure.
+//
+// Paths are split into components at slashes and backslashes, which stay in
+// place, as does a drive letter such as C: at the start. In each component,
+// the runs of characters of the alphabet are encrypted and everything else is
+// kept, dots and spaces for example, so that "/home/alice/q3-2024.xlsx" becomes
+// for example "/ZMnT/FSphe/k0xbFKx.xlsx". The extensions of the file name, the
+// last component, are kept as WithKeptExtensions says. Leading dots, as in
+// ".bashrc", do not start an extension, and "." and ".." are kept.
+//
+// Runs shorter than the cipher encrypts are kept. Components that would
+// encrypt to a name of WithKeptComponents are encrypted again until they do not
+// (cycle walking), so that decryption can tell them apart.
+type PathCipher struct {
+	cipher     *ff1.Cipher
+	keep       map[string]bool
+	extensions int
+}
+
+// Path returns a PathCipher encrypting file paths with cipher, whose alphabet
+// must not have a slash, backslash or dot, such as PathAlphabet.
+func Path(cipher *ff1.Cipher, opts ...PathOption) *PathCipher {
+	c := &PathCipher{cipher: cipher, keep: make(map[string]bool), extensions: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts path.
+func (c *PathCipher) Encrypt(path string) (string, error) {
+	return c.crypt(path, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *PathCipher) Decrypt(path string) (string, error) {
+	return c.crypt(path, false)
+}
+
+func (c *PathCipher) crypt(path string, encrypt bool) (string, error) {
+	alphabet := string(c.cipher.Alphabet())
+	if strings.ContainsAny(alphabet, `/\.`) {
+		return "", ErrPathAlphabet
+	}
+	out := []byte(path)
+	start := 0
+	if len(path) >= 2 && (isUpper(path[0]) || path[0] >= 'a' && path[0] <= 'z') && path[1] == ':' {
+		start = 2
+	}
+	for start <= len(path) {
+		end := strings.IndexAny(path[start:], `/\`)
+		last := end < 0
+		if last {
+			end = len(path)
+		} else {
+			end += start
+		}
+		if err := c.cryptComponent(out[start:end], alphabet, last, encrypt); err != nil {
+			return "", err
+		}
+		start = end + 1
+	}
+	return string(out), nil
+}
+
+// cryptComponent encrypts or decrypts the path component name in place.
+func (c *PathCipher) cryptComponent(name []byte, alphabet string, last, encrypt bool) error {
+	if len(name) == 0 || c.keep[string(name)] || string(name) == "." || string(name) == ".." {
+		return nil
+	}
+	end := len(name)
+	if last {
+		stem := len(name) - len(strings.TrimLeft(string(name), "."))
+		for i := 0; i < c.extensions; i++ {
+			dot := strings.LastIndexByte(string(name[stem:end]), '.')
+			if dot < 0 {
+				break
+			}
+			end = stem + dot
+		}
+	}
+
+	for walk := 0; walk < maxWalk; walk++ {
+		for at := 0; at < end; {
+			if strings.IndexByte(alphabet, name[at]) < 0 {
+				at++
+				continue
+			}
+			run := at
+			for at < end && strings.IndexByte(alphabet, name[at]) >= 0 {
+				at++
+			}
+			if at-run < c.cipher.MinLen() {
+				continue
+			}
+			var err error
+			if encrypt {
+				_, err = c.cipher.EncryptInto(name[run:at], name[run:at])
+			} else {
+				_, err = c.cipher.DecryptInto(name[run:at], name[run:at])
+			}
+			if err != nil {
+				return fmt.Errorf("formats: %w", err)
+			}
+		}
+		if !c.keep[string(name)] {
+			return nil
+		}
+	}
+	return fmt.Errorf("formats: path component still a kept name after %d rounds of cycle walking", maxWalk)
+}
@@ -0,0 +1,130 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	c := newFormatCipher(t, PathAlphabet)
+	kept := Path(c, WithKeptComponents("home", "var", "log"), WithKeptExtensions(2))
+	for idx, testCase := range []struct {
+		paths      *PathCipher
+		path       string
+		ciphertext string
+	}{
+		{Path(c), "/home/alice/reports/q3-2024.xlsx", "/ZMnT/FSphe/o1hc-yU/k0xbFKx.xlsx"},
+		{Path(c), "reports/q3-2024.xlsx", "o1hc-yU/k0xbFKx.xlsx"},
+		{Path(c), "./a/../b/file.txt", "./a/../b/z5yu.txt"},
+		{Path(c), "/home/alice/.bashrc", "/ZMnT/FSphe/.bMlOYF"},
+		{Path(c), "/home/alice/.config/app.settings.json", "/ZMnT/FSphe/.JVtWrz/79u.D_NGdmWb.json"},
+		{Path(c), "/var/log/nginx/access.log.1.gz", "/KBP/7Gf/3UyS8/yysuCW.7Gf.1.gz"},
+		{Path(c), "backup.tar.gz", "m6UDrf.99v.gz"},
+		{Path(c), `C:\Users\Alice\My Documents\notes.txt`, `C:\o9KAK\dytGT\oM gm3NwJ7P9\Qi9Ix.txt`},
+		{Path(c), `\\fileserver\share\x.doc`, `\\efZ-qkW3YR\Y70kQ\x.doc`},
+		{Path(c), "/home/alice/", "/ZMnT/FSphe/"},
+		{Path(c), "Makefile", "8eRhDouW"},
+		{kept, "/home/alice/reports/q3-2024.xlsx", "/home/FSphe/o1hc-yU/k0xbFKx.xlsx"},
+		{kept, "/home/alice/.config/app.settings.json", "/home/FSphe/.JVtWrz/79u.settings.json"},
+		{kept, "/var/log/nginx/access.log.1.gz", "/var/log/3UyS8/yysuCW.7Gf.1.gz"},
+		{kept, "backup.tar.gz", "m6UDrf.tar.gz"},
+		{Path(c, WithKeptExtensions(0)), "backup.tar.gz", "m6UDrf.99v.Mw"},
+		{Path(c, WithKeptExtensions(0)), "/home/alice/.bashrc", "/ZMnT/FSphe/.bMlOYF"},
+		{Path(c, WithKeptExtensions(0)), `\\fileserver\share\x.doc`, `\\efZ-qkW3YR\Y70kQ\x.rLH`},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.paths.Encrypt(testCase.path)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.paths.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.path {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.path)
+			}
+		})
+	}
+}
+
+// Every component of two characters, with the 64 starting with a kept
+func TestPathKeptComponents(t *testing.T) {
+	var names []string
+	for _, b := range PathAlphabet {
+		names = append(names, "a"+string(b))
+	}
+	p := Path(newFormatCipher(t, PathAlphabet), WithKeptComponents(names...))
+	seen := map[string]bool{}
+	for _, first := range PathAlphabet {
+		for _, second := range PathAlphabet {
+			component := string(first) + string(second)
+			ciphertext, err := p.Encrypt("/" + component)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if first == 'a' {
+				if ciphertext != "/"+component {
+					t.Fatalf("Got %q for the kept %q", ciphertext, component)
+				}
+				continue
+			}
+			if ciphertext[1] == 'a' || seen[ciphertext] {
+				t.Fatalf("Got %q for %q", ciphertext, component)
+			}
+			seen[ciphertext] = true
+			if plaintext, _ := p.Decrypt(ciphertext); plaintext != "/"+component {
+				t.Fatalf("Got %q, expected %q", plaintext, "/"+component)
+			}
+		}
+	}
+}
+
+func TestPathErrors(t *testing.T) {
+	for idx, alphabet := range []string{PathAlphabet + ".", PathAlphabet + "/", `0123456789\`} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := Path(newFormatCipher(t, alphabet)).Encrypt("/home/alice"); !errors.Is(err, ErrPathAlphabet) {
+				t.Fatalf("Got %v, expected %v", err, ErrPathAlphabet)
+			}
+		})
+	}
+
+	// Characters outside the alphabet stay in place
+	path := "/home/ålice/naïve résumé (1).pdf"
+	ciphertext, err := Path(newFormatCipher(t, PathAlphabet)).Encrypt(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(ciphertext) != len(path) {
+		t.Fatalf("Got %q for %q", ciphertext, path)
+	}
+	for i := 0; i < len(path); i++ {
+		if strings.IndexByte(PathAlphabet, path[i]) < 0 && ciphertext[i] != path[i] {
+			t.Fatalf("Got %q for %q", ciphertext, path)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// ErrPhoneFormat is returned for a value with other characters than digits,
+// a leading +, the punctuation " ()-./" and an extension.
+var ErrPhoneFormat = errors.New("formats: not a phone number")
+
+// A PhoneLengthError is returned for a phone number with fewer digits to
+// encrypt than the cipher needs.
+type PhoneLengthError struct {
+	Digits int // digits to encrypt
+	MinLen int // the minimum the cipher encrypts
+}
+
+func (e *PhoneLengthError) Error() string {
+	return fmt.Sprintf("formats: phone number has %d digits to encrypt, at least %d are needed", e.Digits, e.MinLen)
+}
+
+// A PhoneOption configures a PhoneCipher.
+type PhoneOption func(*PhoneCipher)
+
+// WithKeptPrefix sets how many leading digits of every number are left in the
+// clear, instead of the country code of numbers starting with +. Negative counts
+// are treated as 0.
+func WithKeptPrefix(n int) PhoneOption {
+	return func(p *PhoneCipher) {
+		if n < 0 {
+			n = 0
+		}
+		p.keep = n
+	}
+}
+
+// WithExtensionEncryption makes the PhoneCipher encrypt the digits of an
+// extension together with the subscriber digits, instead of leaving them as
+// they are.
+func WithExtensionEncryption() PhoneOption {
+	return func(p *PhoneCipher) {
+		p.extension = true
+	}
+}
+
+// A PhoneCipher encrypts phone numbers to phone numbers of the same layout.
+// Only digits change, the leading +, punctuation and spacing stay in place.
+//
+// Without WithKeptPrefix the country code of numbers starting with + is kept,
+// which is taken to be the digits up to the first separator if there are at most
+// three of them, as in "+41 79 123 45 67". Numbers without a separator there,
+// such as "+41791234567", or without a +, have all digits encrypted.
+//
+// An extension starts with x or ext, in any case and optionally followed by a
+// dot, as in "(212) 555-0187 ext. 12".
+type PhoneCipher struct {
+	cipher    *ff1.Cipher
+	keep      int // -1 for the country code
+	extension bool
+}
+
+// Phone returns a PhoneCipher encrypting the subscriber digits of phone numbers
+// with cipher, which must have the alphabet 0123456789.
+func Phone(cipher *ff1.Cipher, opts ...PhoneOption) *PhoneCipher {
+	p := &PhoneCipher{cipher: cipher, keep: -1}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Encrypt encrypts the phone number phone.
+func (p *PhoneCipher) Encrypt(phone string) (string, error) {
+	return p.crypt(phone, true)
+}
+
+// Decrypt reverses Encrypt.
+func (p *PhoneCipher) Decrypt(phone string) (string, error) {
+	return p.crypt(phone, false)
+}
+
+func (p *PhoneCipher) crypt(phone string, encrypt bool) (string, error) {
+	if !bytes.Equal(p.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	out := []byte(phone)
+	number, ext, ok := splitExtension(phone)
+	if !ok {
+		return "", ErrPhoneFormat
+	}
+
+	var pos []int
+	for i, b := range []byte(number) {
+		switch {
+		case b >= '0' && b <= '9':
+			pos = append(pos, i)
+		case b == '+' && i == 0:
+		case strings.IndexByte(" ()-./", b) < 0:
+			return "", ErrPhoneFormat
+		}
+	}
+	if len(pos) == 0 {
+		return "", ErrPhoneFormat
+	}
+
+	keep := p.keep
+	if keep < 0 {
+		keep = 0
+		if number[0] == '+' {
+			cc := len(number[1:]) - len(strings.TrimLeft(number[1:], "0123456789"))
+			if cc <= 3 && cc < len(pos) {
+				keep = cc
+			}
+		}
+	}
+	if keep > len(pos) {
+		keep = len(pos)
+	}
+	pos = pos[keep:]
+
+	if p.extension {
+		for i := len(phone) - len(ext); i < len(phone); i++ {
+			pos = append(pos, i)
+		}
+	}
+
+	if len(pos) < p.cipher.MinLen() {
+		return "", &PhoneLengthError{Digits: len(pos), MinLen: p.cipher.MinLen()}
+	}
+	X := make([]byte, len(pos))
+	for i, at := range pos {
+		X[i] = out[at]
+	}
+	var err error
+	if encrypt {
+		_, err = p.cipher.EncryptInto(X, X)
+	} else {
+		_, err = p.cipher.DecryptInto(X, X)
+	}
+	if err != nil {
+		return "", fmt.Errorf("formats: %w", err)
+	}
+	for i, at := range pos {
+		out[at] = X[i]
+	}
+	return string(out), nil
+}
+
+// splitExtension splits phone into the number and the digits of its extension,
+// if it has a valid one.
+func splitExtension(phone string) (number, ext string, ok bool) {
+	lower := strings.ToLower(phone)
+	at := strings.Index(lower, "ext")
+	if at < 0 {
+		at = strings.IndexByte(lower, 'x')
+	}
+	if at < 0 {
+		return phone, "", true
+	}
+	rest := lower[at:]
+	for _, marker := range []string{"ext.", "ext", "x"} {
+		if strings.HasPrefix(rest, marker) {
+			rest = strings.TrimLeft(rest[len(marker):], " ")
+			break
+		}
+	}
+	if rest == "" || strings.Trim(rest, "0123456789") != "" {
+		return "", "", false
+	}
+	return phone[:at], phone[len(phone)-len(rest):], true
+}
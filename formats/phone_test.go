@@ -0,0 +1,116 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPhone(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		p          *PhoneCipher
+		phone      string
+		ciphertext string
+	}{
+		{Phone(c), "+41 79 123 45 67", "+41 82 913 32 66"},
+		{Phone(c), "+44 20 7946 0958", "+44 48 8065 8617"},
+		{Phone(c), "+1 415 555 2671", "+1 475 947 0331"},
+		{Phone(c), "+14155552671", "+98041207829"},
+		{Phone(c), "(212) 555-0187", "(231) 661-3865"},
+		{Phone(c), "2125550187", "2316613865"},
+		{Phone(c), "(212) 555-0187 x123", "(231) 661-3865 x123"},
+		{Phone(c), "212.555.0187 Ext. 9", "231.661.3865 Ext. 9"},
+		{Phone(c, WithExtensionEncryption()), "(212) 555-0187 x123", "(782) 704-3422 x070"},
+		{Phone(c, WithExtensionEncryption()), "212.555.0187 Ext. 9", "611.977.4556 Ext. 4"},
+		{Phone(c, WithKeptPrefix(3)), "+41 79 123 45 67", "+41 78 317 76 93"},
+		{Phone(c, WithKeptPrefix(3)), "+14155552671", "+14106942163"},
+		{Phone(c, WithKeptPrefix(3)), "(212) 555-0187", "(212) 163-2767"},
+		{Phone(c, WithKeptPrefix(0)), "+41 79 123 45 67", "+01 66 056 20 99"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.p.Encrypt(testCase.phone)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.p.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.phone {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.phone)
+			}
+		})
+	}
+}
+
+func TestPhoneErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		p     *PhoneCipher
+		phone string
+		err   error
+	}{
+		{Phone(c), "", ErrPhoneFormat},
+		{Phone(c), "+", ErrPhoneFormat},
+		{Phone(c), "x123", ErrPhoneFormat},
+		{Phone(c), "212 555 01 87 +", ErrPhoneFormat},
+		{Phone(c), "212-555-CALL", ErrPhoneFormat},
+		{Phone(c), "212-555-0187 x", ErrPhoneFormat},
+		{Phone(c), "212-555-0187 x12a", ErrPhoneFormat},
+		{Phone(c), "212-555-0187 ext 1 2", ErrPhoneFormat},
+		{Phone(c), "+41 7", &PhoneLengthError{Digits: 1, MinLen: 2}},
+		{Phone(c, WithKeptPrefix(9)), "212-555-0187", &PhoneLengthError{Digits: 1, MinLen: 2}},
+		{Phone(c, WithKeptPrefix(12)), "212-555-0187 x1", &PhoneLengthError{Digits: 0, MinLen: 2}},
+		{Phone(newFormatCipher(t, "abcdefghij")), "212-555-0187", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.p.Encrypt(testCase.phone)
+			if expected, ok := testCase.err.(*PhoneLengthError); ok {
+				var lengthErr *PhoneLengthError
+				if !errors.As(err, &lengthErr) || *lengthErr != *expected {
+					t.Fatalf("Got %v, expected %v", err, expected)
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+
+	// A one-digit extension is enough when encrypted with the rest
+	p := Phone(c, WithKeptPrefix(9), WithExtensionEncryption())
+	ciphertext, err := p.Encrypt("212-555-0187 x1")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if ciphertext[:11] != "212-555-018" {
+		t.Fatalf("Got %q", ciphertext)
+	}
+	if plaintext, _ := p.Decrypt(ciphertext); plaintext != "212-555-0187 x1" {
+		t.Fatalf("Got %q", plaintext)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrPlateJurisdiction is returned, wrapped with the jurisdiction, for a
+	// jurisdiction without a PlateFormat.
+	ErrPlateJurisdiction = errors.New("formats: no plate format for the jurisdiction")
+
+	// ErrPlateFormat is returned, wrapped with the plate and the jurisdiction,
+	// for a plate that matches none of the templates of its jurisdiction.
+	ErrPlateFormat = errors.New("formats: not a plate of the jurisdiction")
+
+	// ErrPlateTemplate is returned by Register, wrapped with the template, for a
+	// template with more than 10^18 plates or a class with a character twice,
+	// and for a format without templates.
+	ErrPlateTemplate = errors.New("formats: invalid plate template")
+
+	// ErrPlateRegistered is returned by Register, wrapped with the jurisdiction,
+	// for a jurisdiction that is registered already.
+	ErrPlateRegistered = errors.New("formats: plate jurisdiction already registered")
+)
+
+// A PlateFormat describes the license plates of a jurisdiction, in templates as
+// for a PostalFormat, such as "AAA-9999". No plate should match more than one
+// template of a jurisdiction.
+type PlateFormat struct {
+	Templates []string
+	Classes   map[byte]string
+}
+
+// A PlateRegistry maps jurisdictions to the formats of their license plates.
+// It is safe for concurrent use, and its zero value is an empty PlateRegistry
+// ready to use.
+type PlateRegistry struct {
+	mu      sync.RWMutex
+	formats map[string]PlateFormat
+}
+
+// NewPlateRegistry returns a PlateRegistry with the plates of these
+// jurisdictions, to which more may be added:
+//
+//	US-CA  California, 9AAA999
+//	US-NY  New York, AAA-9999
+//	CH-ZH  the canton of Zurich, ZH followed by a space and up to six digits
+//	FR     France, AA-999-AA without the letters I, O and U
+func NewPlateRegistry() *PlateRegistry {
+	zurich := make([]string, 6)
+	for i := range zurich {
+		zurich[i] = "ZH 1" + strings.Repeat("9", i)
+	}
+	return &PlateRegistry{formats: map[string]PlateFormat{
+		"US-CA": {Templates: []string{"9AAA999"}},
+		"US-NY": {Templates: []string{"AAA-9999"}},
+		"CH-ZH": {
+			Templates: zurich,
+			Classes:   map[byte]string{'1': "123456789"},
+		},
+		"FR": {
+			Templates: []string{"AA-999-AA"},
+			Classes:   map[byte]string{'A': "ABCDEFGHJKLMNPQRSTVWXYZ"},
+		},
+	}}
+}
+
+// Register adds the plates of jurisdiction. It fails if the jurisdiction is
+// registered already or a template of format is invalid. The registry keeps a
+// copy of format.
+func (r *PlateRegistry) Register(jurisdiction string, format PlateFormat) error {
+	if len(format.Templates) == 0 {
+		return fmt.Errorf("%w: no templates for %q", ErrPlateTemplate, jurisdiction)
+	}
+	classes := make(map[byte]string, len(format.Classes))
+	for b, class := range format.Classes {
+		classes[b] = class
+	}
+	for _, template := range format.Templates {
+		if !validTemplate(classes, template) {
+			return fmt.Errorf("%w: %q", ErrPlateTemplate, template)
+		}
+	}
+	format = PlateFormat{Templates: append([]string{}, format.Templates...), Classes: classes}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.formats[jurisdiction]; ok {
+		return fmt.Errorf("%w: %q", ErrPlateRegistered, jurisdiction)
+	}
+	if r.formats == nil {
+		r.formats = make(map[string]PlateFormat)
+	}
+	r.formats[jurisdiction] = format
+	return nil
+}
+
+// Jurisdictions returns the registered jurisdictions, sorted.
+func (r *PlateRegistry) Jurisdictions() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.formats))
+	for name := range r.formats {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (r *PlateRegistry) lookup(jurisdiction string) (PlateFormat, bool) {
+	r.mu.RLock()
+	format, ok := r.formats[jurisdiction]
+	r.mu.RUnlock()
+	return format, ok
+}
+
+// A PlateCipher encrypts license plates to plates of the same jurisdiction and
+// layout. Letters stay letters and digits stay digits, within the classes of
+// the template the plate matches, and separators stay in place, so ciphertexts
+// match the patterns of their jurisdiction.
+type PlateCipher struct {
+	cipher   *ff1.Cipher
+	registry *PlateRegistry
+}
+
+// Plate returns a PlateCipher encrypting the plates of the jurisdictions of
+// registry, or of NewPlateRegistry if it is nil, with cipher, which must have
+// the alphabet 0123456789. Jurisdictions registered later are encrypted too.
+func Plate(cipher *ff1.Cipher, registry *PlateRegistry) *PlateCipher {
+	if registry == nil {
+		registry = NewPlateRegistry()
+	}
+	return &PlateCipher{cipher: cipher, registry: registry}
+}
+
+// Encrypt encrypts plate, a license plate of jurisdiction.
+func (c *PlateCipher) Encrypt(jurisdiction, plate string) (string, error) {
+	return c.crypt(jurisdiction, plate, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *PlateCipher) Decrypt(jurisdiction, plate string) (string, error) {
+	return c.crypt(jurisdiction, plate, false)
+}
+
+func (c *PlateCipher) crypt(jurisdiction, plate string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	format, ok := c.registry.lookup(jurisdiction)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrPlateJurisdiction, jurisdiction)
+	}
+	for _, template := range format.Templates {
+		if matched, ok := matchTemplate(format.Classes, template, plate); ok {
+			return cryptTemplate(c.cipher, matched, plate, encrypt)
+		}
+	}
+	return "", fmt.Errorf("%w: %q for %s", ErrPlateFormat, plate, jurisdiction)
+}
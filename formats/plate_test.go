@@ -0,0 +1,214 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// platePatterns are the patterns of the plates of NewPlateRegistry
+var platePatterns = map[string]*regexp.Regexp{
+	"US-CA": regexp.MustCompile(`^[0-9][A-Z]{3}[0-9]{3}$`),
+	"US-NY": regexp.MustCompile(`^[A-Z]{3}-[0-9]{4}$`),
+	"CH-ZH": regexp.MustCompile(`^ZH [1-9][0-9]{0,5}$`),
+	"FR":    regexp.MustCompile(`^[A-HJ-NP-TV-Z]{2}-[0-9]{3}-[A-HJ-NP-TV-Z]{2}$`),
+}
+
+func TestPlate(t *testing.T) {
+	p := Plate(newFormatCipher(t, "0123456789"), nil)
+	for idx, testCase := range []struct {
+		jurisdiction string
+		plate        string
+		ciphertext   string
+	}{
+		{"US-CA", "7ABC123", "2SQT472"},
+		{"US-CA", "8XYZ999", "6WFM141"},
+		{"US-NY", "ABC-1234", "TGQ-7494"},
+		{"US-NY", "KZX-0001", "BOE-8480"},
+		{"CH-ZH", "ZH 123456", "ZH 133614"},
+		{"CH-ZH", "ZH 999", "ZH 366"},
+		{"CH-ZH", "ZH 12", "ZH 85"},
+		{"CH-ZH", "ZH 1", "ZH 9"},
+		{"FR", "AB-123-CD", "TR-161-PL"},
+		{"FR", "GZ-007-XY", "NH-838-YG"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := p.Encrypt(testCase.jurisdiction, testCase.plate)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := p.Decrypt(testCase.jurisdiction, ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.plate {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.plate)
+			}
+		})
+	}
+}
+
+// Ciphertexts of many plates match the pattern of their jurisdiction
+func TestPlatePatterns(t *testing.T) {
+	p := Plate(newFormatCipher(t, "0123456789"), nil)
+	for idx, jurisdiction := range []string{"US-CA", "US-NY", "CH-ZH", "FR"} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			plate := map[string]string{"US-CA": "7ABC123", "US-NY": "ABC-1234", "CH-ZH": "ZH 1", "FR": "AB-123-CD"}[jurisdiction]
+			for i := 0; i < 200; i++ {
+				ciphertext, err := p.Encrypt(jurisdiction, plate)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !platePatterns[jurisdiction].MatchString(ciphertext) {
+					t.Fatalf("Got %q for %q", ciphertext, plate)
+				}
+				if plaintext, _ := p.Decrypt(jurisdiction, ciphertext); plaintext != plate {
+					t.Fatalf("Got %q, expected %q", plaintext, plate)
+				}
+				plate = ciphertext
+			}
+		})
+	}
+}
+
+func TestPlateRegister(t *testing.T) {
+	var registry PlateRegistry
+	p := Plate(newFormatCipher(t, "0123456789"), &registry)
+	if _, err := p.Encrypt("DE-B", "B-AB 123"); !errors.Is(err, ErrPlateJurisdiction) {
+		t.Fatalf("Got %v, expected %v", err, ErrPlateJurisdiction)
+	}
+
+	format := PlateFormat{
+		Templates: []string{"B-A 1", "B-A 19", "B-A 199", "B-A 1999", "B-AA 1", "B-AA 19", "B-AA 199", "B-AA 1999"},
+		Classes:   map[byte]string{'1': "123456789"},
+	}
+	if err := registry.Register("DE-B", format); err != nil {
+		t.Fatalf("%v", err)
+	}
+	// The registry has a copy
+	format.Templates[0] = "9"
+	format.Classes['A'] = "AB"
+
+	pattern := regexp.MustCompile(`^B-[A-Z]{1,2} [1-9][0-9]{0,3}$`)
+	seen := map[string]bool{}
+	for i := 1; i < 1000; i++ {
+		plate := fmt.Sprintf("B-%c %d", 'A'+i%26, i)
+		ciphertext, err := p.Encrypt("DE-B", plate)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !pattern.MatchString(ciphertext) || len(ciphertext) != len(plate) || seen[ciphertext] {
+			t.Fatalf("Got %q for %q", ciphertext, plate)
+		}
+		seen[ciphertext] = true
+		if plaintext, _ := p.Decrypt("DE-B", ciphertext); plaintext != plate {
+			t.Fatalf("Got %q, expected %q", plaintext, plate)
+		}
+	}
+
+	for idx, testCase := range []struct {
+		jurisdiction string
+		format       PlateFormat
+		err          error
+	}{
+		{"DE-B", PlateFormat{Templates: []string{"B-A 9"}}, ErrPlateRegistered},
+		{"DE-M", PlateFormat{}, ErrPlateTemplate},
+		{"DE-M", PlateFormat{Templates: []string{"M-AA 9999", "M-AAAAAAAAAAAAAAAAAAAA"}}, ErrPlateTemplate},
+		{"DE-M", PlateFormat{Templates: []string{"M-AA 9999"}, Classes: map[byte]string{'A': "ABCA"}}, ErrPlateTemplate},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if err := registry.Register(testCase.jurisdiction, testCase.format); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+	if names := registry.Jurisdictions(); !reflect.DeepEqual(names, []string{"DE-B"}) {
+		t.Fatalf("Got %q", names)
+	}
+	if names := NewPlateRegistry().Jurisdictions(); !reflect.DeepEqual(names, []string{"CH-ZH", "FR", "US-CA", "US-NY"}) {
+		t.Fatalf("Got %q", names)
+	}
+}
+
+// Jurisdictions can be registered while plates are encrypted
+func TestPlateConcurrent(t *testing.T) {
+	registry := NewPlateRegistry()
+	p := Plate(newFormatCipher(t, "0123456789"), registry)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if err := registry.Register(fmt.Sprintf("XX-%d", i), PlateFormat{Templates: []string{"AA 99"}}); err != nil {
+				t.Errorf("%v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Encrypt("US-NY", "ABC-1234"); err != nil {
+				t.Errorf("%v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if ciphertext, err := p.Encrypt("XX-3", "AB 12"); err != nil || len(ciphertext) != 5 {
+		t.Fatalf("Got %q, %v", ciphertext, err)
+	}
+}
+
+func TestPlateErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		cipherAlphabet string
+		jurisdiction   string
+		plate          string
+		err            error
+	}{
+		{"0123456789", "US-CA", "7ABC12", ErrPlateFormat},
+		{"0123456789", "US-CA", "7abc123", ErrPlateFormat},
+		{"0123456789", "US-NY", "ABC 1234", ErrPlateFormat},
+		{"0123456789", "CH-ZH", "ZH 012345", ErrPlateFormat},
+		{"0123456789", "CH-ZH", "ZH 1234567", ErrPlateFormat},
+		{"0123456789", "CH-ZH", "BE 123456", ErrPlateFormat},
+		{"0123456789", "FR", "AI-123-CD", ErrPlateFormat},
+		{"0123456789", "fr", "AB-123-CD", ErrPlateJurisdiction},
+		{"0123456789", "US-TX", "ABC-1234", ErrPlateJurisdiction},
+		{"0123456789ABCDEF", "US-CA", "7ABC123", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			cipher := c
+			if testCase.cipherAlphabet != "0123456789" {
+				cipher = newFormatCipher(t, testCase.cipherAlphabet)
+			}
+			_, err := Plate(cipher, nil).Encrypt(testCase.jurisdiction, testCase.plate)
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
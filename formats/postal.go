@@ -0,0 +1,187 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+const lowerLetters = "abcdefghijklmnopqrstuvwxyz"
+
+var (
+	// ErrPostalCountry is returned, wrapped with the country, for a country
+	// without a PostalFormat.
+	ErrPostalCountry = errors.New("formats: no postal code format for the country")
+
+	// ErrPostalFormat is returned, wrapped with the code and the country, for a
+	// code that matches none of the templates of its country.
+	ErrPostalFormat = errors.New("formats: not a postal code of the country")
+
+	// ErrPostalTemplate is returned, wrapped with the template, for a template
+	// with more than 10^18 codes or a class with a character twice.
+	ErrPostalTemplate = errors.New("formats: invalid postal code template")
+)
+
+// A PostalFormat describes the postal codes of a country.
+//
+// Each template is one layout of the codes. A byte of a template stands for one
+// character of a class: '9' for a digit, 'A' for an upper-case letter, or the
+// characters Classes has for it, which may also replace those two. Other bytes,
+// such as spaces and hyphens, stand for themselves. No code should match more
+// than one template of a country.
+type PostalFormat struct {
+	Templates []string
+	Classes   map[byte]string
+}
+
+// A PostalRegistry maps countries, by their two-letter codes in upper case, to
+// the formats of their postal codes.
+type PostalRegistry map[string]PostalFormat
+
+// DefaultPostalRegistry returns a new PostalRegistry with the postal codes of
+// CA, DE, GB, JP, NL and US, to which more countries may be added.
+//
+// The templates follow the layouts the postal services write, with the space of
+// CA, GB and NL codes and the hyphens of JP and US ZIP+4 codes. Letters that are
+// never used at a position of CA codes and in the last two of GB codes are left
+// out of their classes, as is a leading zero of NL codes.
+func DefaultPostalRegistry() PostalRegistry {
+	return PostalRegistry{
+		"CA": {
+			Templates: []string{"X9Y 9Y9"},
+			Classes: map[byte]string{
+				'X': "ABCEGHJKLMNPRSTVXY",
+				'Y': "ABCEGHJKLMNPRSTVWXYZ",
+			},
+		},
+		"DE": {Templates: []string{"99999"}},
+		"GB": {
+			Templates: []string{"A9 9ZZ", "A99 9ZZ", "A9A 9ZZ", "AA9 9ZZ", "AA99 9ZZ", "AA9A 9ZZ"},
+			Classes:   map[byte]string{'Z': "ABDEFGHJLNPQRSTUWXYZ"},
+		},
+		"JP": {Templates: []string{"999-9999"}},
+		"NL": {
+			Templates: []string{"1999 AA"},
+			Classes:   map[byte]string{'1': "123456789"},
+		},
+		"US": {Templates: []string{"99999", "99999-9999"}},
+	}
+}
+
+// A PostalOption configures a PostalCodeCipher.
+type PostalOption func(*PostalCodeCipher)
+
+// WithGenericPostalCodes makes the PostalCodeCipher encrypt the codes of
+// countries without a PostalFormat too, by their own shape: digits stay digits,
+// upper-case letters stay upper-case letters and lower-case letters stay
+// lower-case letters, other characters are kept.
+func WithGenericPostalCodes() PostalOption {
+	return func(c *PostalCodeCipher) {
+		c.generic = true
+	}
+}
+
+// A PostalCodeCipher encrypts postal codes to codes of the same country and
+// layout.
+//
+// The code is matched against the templates of its country, and ranked as its
+// index among the codes of the template that matches, in a mixed radix of the
+// sizes of the classes of its positions. The rank is encrypted as a decimal
+// string, with cycle walking, so every character stays in its class.
+type PostalCodeCipher struct {
+	cipher   *ff1.Cipher
+	registry PostalRegistry
+	generic  bool
+}
+
+// PostalCode returns a PostalCodeCipher encrypting the postal codes of the
+// countries of registry, or of DefaultPostalRegistry if it is nil, with cipher,
+// which must have the alphabet 0123456789.
+func PostalCode(cipher *ff1.Cipher, registry PostalRegistry, opts ...PostalOption) *PostalCodeCipher {
+	if registry == nil {
+		registry = DefaultPostalRegistry()
+	}
+	c := &PostalCodeCipher{cipher: cipher, registry: registry}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts code, a postal code of country.
+func (c *PostalCodeCipher) Encrypt(country, code string) (string, error) {
+	return c.crypt(country, code, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *PostalCodeCipher) Decrypt(country, code string) (string, error) {
+	return c.crypt(country, code, false)
+}
+
+func (c *PostalCodeCipher) crypt(country, code string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	format, ok := c.registry[strings.ToUpper(country)]
+	switch {
+	case ok:
+	case c.generic && code != "":
+		format = genericPostalFormat(code)
+	default:
+		return "", fmt.Errorf("%w: %q", ErrPostalCountry, country)
+	}
+
+	for _, template := range format.Templates {
+		matched, ok := matchTemplate(format.Classes, template, code)
+		if !ok {
+			continue
+		}
+		if !validTemplate(format.Classes, template) {
+			return "", fmt.Errorf("%w: %q", ErrPostalTemplate, template)
+		}
+		return cryptTemplate(c.cipher, matched, code, encrypt)
+	}
+	return "", fmt.Errorf("%w: %q for %s", ErrPostalFormat, code, country)
+}
+
+// genericPostalFormat returns the format with the shape of code as its only
+// template.
+func genericPostalFormat(code string) PostalFormat {
+	template := []byte(code)
+	for i, b := range template {
+		switch {
+		case isDigit(b):
+			template[i] = '9'
+		case isUpper(b):
+			template[i] = 'A'
+		case b >= 'a' && b <= 'z':
+			template[i] = 'a'
+		}
+	}
+	return PostalFormat{
+		Templates: []string{string(template)},
+		Classes:   map[byte]string{'a': lowerLetters},
+	}
+}
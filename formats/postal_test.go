@@ -0,0 +1,187 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPostalCode(t *testing.T) {
+	p := PostalCode(newFormatCipher(t, "0123456789"), nil, WithGenericPostalCodes())
+	for idx, testCase := range []struct {
+		country    string
+		code       string
+		ciphertext string
+	}{
+		{"US", "90210", "96123"},
+		{"US", "20500-0003", "80663-6241"},
+		{"us", "10001", "15785"},
+		{"DE", "10115", "10773"},
+		{"DE", "01067", "64214"},
+		{"JP", "100-0001", "018-8794"},
+		{"NL", "1012 AB", "6224 MJ"},
+		{"CA", "K1A 0B1", "H5T 5P4"},
+		{"CA", "M5V 3L9", "B6B 8T7"},
+		{"GB", "SW1A 1AA", "FT9Z 6DX"},
+		{"GB", "M1 1AE", "N5 3EW"},
+		{"GB", "B33 8TH", "Q85 3XW"},
+		{"GB", "W1A 0AX", "K9Y 3QR"},
+		{"GB", "CR2 6XH", "HZ8 0ZN"},
+		{"GB", "DN55 1PT", "NX13 7PY"},
+		{"CH", "8001", "9423"},
+		{"BR", "01310-100", "21401-988"},
+		{"MT", "vlt 1117", "zij 7847"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := p.Encrypt(testCase.country, testCase.code)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := p.Decrypt(testCase.country, ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.code {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.code)
+			}
+		})
+	}
+}
+
+func TestPostalCodeFormats(t *testing.T) {
+	p := PostalCode(newFormatCipher(t, "0123456789"), nil)
+	for idx, testCase := range []struct {
+		country string
+		code    string
+		err     error
+	}{
+		{"US", "02134", nil},
+		{"US", "02134-1234", nil},
+		{"US", "2134", ErrPostalFormat},
+		{"US", "02134 1234", ErrPostalFormat},
+		{"US", "0213A", ErrPostalFormat},
+		{"DE", "80331", nil},
+		{"DE", "803310", ErrPostalFormat},
+		{"DE", "D-80331", ErrPostalFormat},
+		{"JP", "150-0002", nil},
+		{"JP", "1500002", ErrPostalFormat},
+		{"NL", "9999 ZZ", nil},
+		{"NL", "0123 AB", ErrPostalFormat},
+		{"NL", "1012AB", ErrPostalFormat},
+		{"NL", "1012 ab", ErrPostalFormat},
+		{"CA", "V6B 4Y8", nil},
+		{"CA", "W6B 4Y8", ErrPostalFormat},
+		{"CA", "V6D 4Y8", ErrPostalFormat},
+		{"CA", "V6B4Y8", ErrPostalFormat},
+		{"GB", "EC1A 1BB", nil},
+		{"GB", "SW1A 1CA", ErrPostalFormat},
+		{"GB", "SW1A1AA", ErrPostalFormat},
+		{"GB", "1W1A 1AA", ErrPostalFormat},
+		{"GB", "SWA 1AA", ErrPostalFormat},
+		{"FR", "75001", ErrPostalCountry},
+		{"", "75001", ErrPostalCountry},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := p.Encrypt(testCase.country, testCase.code)
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+			if err != nil {
+				return
+			}
+			// The ciphertext is a valid code of the same country
+			if _, err := p.Decrypt(testCase.country, ciphertext); err != nil {
+				t.Fatalf("%v", err)
+			}
+			if len(ciphertext) != len(testCase.code) {
+				t.Fatalf("Got %q for %q", ciphertext, testCase.code)
+			}
+		})
+	}
+}
+
+// Every code of a template with 120 codes, in a registry of its own
+func TestPostalCodeTemplate(t *testing.T) {
+	registry := PostalRegistry{"XX": {
+		Templates: []string{"9-AB", "99"},
+		Classes:   map[byte]string{'A': "abcd", 'B': "XYZ"},
+	}}
+	p := PostalCode(newFormatCipher(t, "0123456789"), registry)
+	seen := map[string]bool{}
+	for i := 0; i < 10*4*3; i++ {
+		code := fmt.Sprintf("%d-%c%c", i/12, "abcd"[i/3%4], "XYZ"[i%3])
+		ciphertext, err := p.Encrypt("xx", code)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if _, ok := matchTemplate(registry["XX"].Classes, "9-AB", ciphertext); !ok || seen[ciphertext] {
+			t.Fatalf("Got %q for %q", ciphertext, code)
+		}
+		seen[ciphertext] = true
+		if plaintext, _ := p.Decrypt("XX", ciphertext); plaintext != code {
+			t.Fatalf("Got %q, expected %q", plaintext, code)
+		}
+	}
+	if ciphertext, _ := p.Encrypt("XX", "42"); len(ciphertext) != 2 || !allDigits(ciphertext) {
+		t.Fatalf("Got %q for the second template", ciphertext)
+	}
+}
+
+func TestPostalCodeErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		postal  *PostalCodeCipher
+		country string
+		code    string
+		err     error
+	}{
+		{PostalCode(c, nil), "MT", "VLT 1117", ErrPostalCountry},
+		{PostalCode(c, nil, WithGenericPostalCodes()), "MT", "", ErrPostalCountry},
+		{PostalCode(c, PostalRegistry{}), "US", "90210", ErrPostalCountry},
+		{PostalCode(c, PostalRegistry{"XX": {Templates: []string{"9999999999 9999999999"}}}), "XX", "0123456789 0123456789", ErrPostalTemplate},
+		{PostalCode(c, nil, WithGenericPostalCodes()), "XX", "0123456789-0123456789", ErrPostalTemplate},
+		{PostalCode(c, PostalRegistry{"XX": {Templates: []string{"9B"}, Classes: map[byte]string{'B': "XYZZY"}}}), "XX", "1X", ErrPostalTemplate},
+		{PostalCode(newFormatCipher(t, "0123456789ABCDEF"), nil), "US", "90210", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.postal.Encrypt(testCase.country, testCase.code)
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+
+	// Codes without a class keep the code, the generic format has no other choice
+	p := PostalCode(c, nil, WithGenericPostalCodes())
+	if ciphertext, err := p.Encrypt("XX", "--"); err != nil || ciphertext != "--" {
+		t.Fatalf("Got %q, %v", ciphertext, err)
+	}
+
+	// Every call returns a registry of its own
+	DefaultPostalRegistry()["US"] = PostalFormat{}
+	if _, err := PostalCode(c, nil).Encrypt("US", "90210"); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// maxRankSize bounds the size of the domains of cryptRank, so that the powers of
+// 10 it needs fit in a uint64.
+const maxRankSize = 1e18
+
+// maxRankWalks bounds the re-encryptions of cycle walking of a rank, however
+// small its domain is next to the values of the digits it is encrypted as.
+const maxRankWalks = 1 << 30
+
+// cryptRank encrypts or decrypts rank, a value in [0, size), to another value
+// in it with c, which must have the alphabet 0123456789.
+//
+// The rank is encrypted as a string of as many decimal digits as size-1 has, or
+// the minimum length of c if that is more. Ciphertexts of size or more are
+// encrypted again until one is below (cycle walking), which keeps it a
+// permutation of [0, size).
+func cryptRank(c *ff1.Cipher, rank, size uint64, encrypt bool) (uint64, error) {
+	return cryptRankWithTweak(c, nil, rank, size, encrypt)
+}
+
+// cryptRankWithTweak is cryptRank with tweak in place of the tweak of c, unless
+// it is nil.
+func cryptRankWithTweak(c *ff1.Cipher, tweak []byte, rank, size uint64, encrypt bool) (uint64, error) {
+	if size == 0 || size > maxRankSize || rank >= size {
+		return 0, fmt.Errorf("formats: rank %d not in a domain of %d values", rank, size)
+	}
+	width := len(strconv.FormatUint(size-1, 10))
+	if width < c.MinLen() {
+		width = c.MinLen()
+	}
+	// The digits are parsed as a uint64, which holds 19 of them. size-1 has at
+	// most 18, so only a longer minimum length of c could exceed it
+	if width > 19 {
+		return 0, fmt.Errorf("formats: minimum length %d of the cipher too long for a rank", c.MinLen())
+	}
+	// Each round lands in the domain with a probability of size/10^width
+	domain := uint64(1)
+	for i := 0; i < width; i++ {
+		domain *= 10
+	}
+	walks := rankWalks((domain + size - 1) / size)
+
+	X := []byte(fmt.Sprintf("%0*d", width, rank))
+	for i := 0; i < walks; i++ {
+		var err error
+		switch {
+		case tweak != nil && encrypt:
+			X, err = c.EncryptWithTweak(X, tweak)
+		case tweak != nil:
+			X, err = c.DecryptWithTweak(X, tweak)
+		case encrypt:
+			_, err = c.EncryptInto(X, X)
+		default:
+			_, err = c.DecryptInto(X, X)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("formats: %w", err)
+		}
+		rank, err = strconv.ParseUint(string(X), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("formats: %w", err)
+		}
+		if rank < size {
+			return rank, nil
+		}
+	}
+	return 0, fmt.Errorf("formats: rank still out of range after %d rounds of cycle walking", walks)
+}
+
+// cryptBigRank is cryptRank for domains of any size.
+func cryptBigRank(c *ff1.Cipher, rank, size *big.Int, encrypt bool) (*big.Int, error) {
+	if size.Sign() <= 0 || rank.Sign() < 0 || rank.Cmp(size) >= 0 {
+		return nil, fmt.Errorf("formats: rank %s not in a domain of %s values", rank, size)
+	}
+	width := len(new(big.Int).Sub(size, big.NewInt(1)).String())
+	if width < c.MinLen() {
+		width = c.MinLen()
+	}
+	// Each round lands in the domain with a probability of size/10^width
+	domain := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(width)), nil)
+	rounds := domain.Add(domain, size).Sub(domain, big.NewInt(1)).Div(domain, size)
+	walks := maxRankWalks
+	if rounds.IsUint64() {
+		walks = rankWalks(rounds.Uint64())
+	}
+
+	text := rank.String()
+	X := []byte(strings.Repeat("0", width-len(text)) + text)
+	for i := 0; i < walks; i++ {
+		var err error
+		if encrypt {
+			_, err = c.EncryptInto(X, X)
+		} else {
+			_, err = c.DecryptInto(X, X)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("formats: %w", err)
+		}
+		if _, ok := rank.SetString(string(X), 10); !ok {
+			return nil, fmt.Errorf("formats: ciphertext %q of a rank is not a number", X)
+		}
+		if rank.Cmp(size) < 0 {
+			return rank, nil
+		}
+	}
+	return nil, fmt.Errorf("formats: rank still out of range after %d rounds of cycle walking", walks)
+}
+
+// rankWalks returns the bound of cycle walking for ranks that land in their
+// domain once in rounds encryptions on average, at most maxRankWalks.
+func rankWalks(rounds uint64) int {
+	if rounds > maxRankWalks/maxWalk {
+		return maxRankWalks
+	}
+	return int(rounds) * maxWalk
+}
@@ -0,0 +1,77 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// Both ways of ranking reject ranks outside their domain
+func TestRankErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		rank int64
+		size int64
+	}{
+		{10, 10},
+		{11, 10},
+		{0, 0},
+		{-1, 10},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if testCase.rank >= 0 && testCase.size >= 0 {
+				if _, err := cryptRank(c, uint64(testCase.rank), uint64(testCase.size), true); err == nil {
+					t.Fatalf("Rank %d encrypted in a domain of %d values", testCase.rank, testCase.size)
+				}
+			}
+			if _, err := cryptBigRank(c, big.NewInt(testCase.rank), big.NewInt(testCase.size), true); err == nil {
+				t.Fatalf("Rank %d encrypted in a domain of %d values", testCase.rank, testCase.size)
+			}
+		})
+	}
+}
+
+// The bound of cycle walking does not overflow for tiny domains
+func TestRankWalks(t *testing.T) {
+	for idx, testCase := range []struct {
+		rounds uint64
+		walks  int
+	}{
+		{1, maxWalk},
+		{1000, 1000 * maxWalk},
+		{maxRankWalks / maxWalk, maxRankWalks / maxWalk * maxWalk},
+		{maxRankWalks/maxWalk + 1, maxRankWalks},
+		{1e19, maxRankWalks},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if walks := rankWalks(testCase.rounds); walks != testCase.walks {
+				t.Fatalf("Got %d, expected %d", walks, testCase.walks)
+			}
+		})
+	}
+
+	// A domain of 1 value is encrypted as a string of MinLen digits
+	rank, err := cryptBigRank(newFormatCipher(t, "0123456789"), big.NewInt(0), big.NewInt(1), true)
+	if err != nil || rank.Sign() != 0 {
+		t.Fatalf("Got %v and %v, expected 0", rank, err)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrRoutingFormat is returned for a value that is not 9 digits.
+	ErrRoutingFormat = errors.New("formats: not a routing number")
+
+	// ErrRoutingChecksum is returned for a routing number whose check digit is
+	// wrong.
+	ErrRoutingChecksum = errors.New("formats: routing number fails the ABA check")
+
+	// ErrRoutingPrefix is returned, with WithFedPrefixes, for a routing number
+	// whose first two digits are not of a Federal Reserve range.
+	ErrRoutingPrefix = errors.New("formats: routing number prefix not in a Federal Reserve range")
+)
+
+// A RoutingOption configures a RoutingCipher.
+type RoutingOption func(*RoutingCipher)
+
+// WithFedPrefixes makes the RoutingCipher cycle-walk, encrypting again until the
+// first two digits of the ciphertext are in one of the ranges assigned to the
+// Federal Reserve districts: 00 to 12, 21 to 32, 61 to 72 and 80. Plaintexts
+// outside them are then rejected with ErrRoutingPrefix.
+func WithFedPrefixes() RoutingOption {
+	return func(c *RoutingCipher) {
+		c.fed = true
+	}
+}
+
+// A RoutingCipher encrypts ABA routing transit numbers to routing numbers that
+// pass the ABA check. The first 8 digits are encrypted and the ninth, the check
+// digit, is computed for them. That of the plaintext follows from the others,
+// which is why only valid routing numbers are accepted and decryption can
+// restore it.
+type RoutingCipher struct {
+	cipher *ff1.Cipher
+	fed    bool
+}
+
+// RoutingNumber returns a RoutingCipher encrypting routing numbers with cipher,
+// which must have the alphabet 0123456789.
+func RoutingNumber(cipher *ff1.Cipher, opts ...RoutingOption) *RoutingCipher {
+	c := &RoutingCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts the routing number rtn.
+func (c *RoutingCipher) Encrypt(rtn string) (string, error) {
+	return c.crypt(rtn, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *RoutingCipher) Decrypt(rtn string) (string, error) {
+	return c.crypt(rtn, false)
+}
+
+func (c *RoutingCipher) crypt(rtn string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	if len(rtn) != 9 || !allDigits(rtn) {
+		return "", ErrRoutingFormat
+	}
+	D := []byte(rtn)
+	if abaCheckDigit(D) != D[8] {
+		return "", ErrRoutingChecksum
+	}
+	if err := c.cryptPrefixed(D[:8], encrypt); err != nil {
+		return "", err
+	}
+	D[8] = abaCheckDigit(D)
+	return string(D), nil
+}
+
+// cryptPrefixed encrypts or decrypts the digits X in place, keeping their first
+// two in the Federal Reserve ranges with WithFedPrefixes.
+func (c *RoutingCipher) cryptPrefixed(X []byte, encrypt bool) error {
+	if c.fed && !fedPrefix(X) {
+		return ErrRoutingPrefix
+	}
+	for i := 0; i < maxWalk; i++ {
+		var err error
+		if encrypt {
+			_, err = c.cipher.EncryptInto(X, X)
+		} else {
+			_, err = c.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return fmt.Errorf("formats: %w", err)
+		}
+		if !c.fed || fedPrefix(X) {
+			return nil
+		}
+	}
+	return fmt.Errorf("formats: prefix still outside the Federal Reserve ranges after %d rounds of cycle walking", maxWalk)
+}
+
+// fedPrefix reports whether the first two digits of X are in a Federal Reserve
+// range.
+func fedPrefix(X []byte) bool {
+	p := int(X[0]-'0')*10 + int(X[1]-'0')
+	return p <= 12 || p >= 21 && p <= 32 || p >= 61 && p <= 72 || p == 80
+}
+
+// abaCheckDigit returns the check digit for the first 8 digits of D, with
+// which 3 times the sum of digits 1, 4 and 7, plus 7 times that of digits 2, 5
+// and 8, plus that of digits 3, 6 and 9 is a multiple of 10.
+func abaCheckDigit(D []byte) byte {
+	weights := [8]int{3, 7, 1, 3, 7, 1, 3, 7}
+	sum := 0
+	for i, w := range weights {
+		sum += w * int(D[i]-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRoutingNumber(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		rc         *RoutingCipher
+		rtn        string
+		ciphertext string
+	}{
+		{RoutingNumber(c), "011000015", "519866722"},
+		{RoutingNumber(c), "021000021", "246041771"},
+		{RoutingNumber(c), "121000358", "901808291"},
+		{RoutingNumber(c), "322271627", "933211924"},
+		{RoutingNumber(c, WithFedPrefixes()), "011000015", "036780873"},
+		{RoutingNumber(c, WithFedPrefixes()), "121000358", "025598092"},
+		{RoutingNumber(c, WithFedPrefixes()), "322271627", "702135422"},
+		{RoutingNumber(c, WithFedPrefixes()), "011401533", "122524655"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.rc.Encrypt(testCase.rtn)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.rc.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.rtn {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.rtn)
+			}
+		})
+	}
+}
+
+func TestRoutingNumberChecksum(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, rc := range []*RoutingCipher{RoutingNumber(c), RoutingNumber(c, WithFedPrefixes())} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			for i := 0; i < 1000; i++ {
+				D := []byte(fmt.Sprintf("%02d%06d0", []int{1, 12, 21, 32, 61, 72, 80}[i%7], i*7919%1000000))
+				D[8] = abaCheckDigit(D)
+				ciphertext, err := rc.Encrypt(string(D))
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if abaCheckDigit([]byte(ciphertext)) != ciphertext[8] {
+					t.Fatalf("Got %q for %q, failing the ABA check", ciphertext, D)
+				}
+				if rc.fed && !fedPrefix([]byte(ciphertext)) {
+					t.Fatalf("Got %q for %q, outside the Federal Reserve ranges", ciphertext, D)
+				}
+				if plaintext, _ := rc.Decrypt(ciphertext); plaintext != string(D) {
+					t.Fatalf("Got %q, expected %q", plaintext, D)
+				}
+			}
+		})
+	}
+}
+
+// On three digits all prefixes can be tried, which shows that cycle walking
+// permutes them.
+func TestRoutingNumberBijection(t *testing.T) {
+	rc := RoutingNumber(newFormatCipher(t, "0123456789"), WithFedPrefixes())
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		X := []byte(fmt.Sprintf("%03d", i))
+		if !fedPrefix(X) {
+			if err := rc.cryptPrefixed(X, true); !errors.Is(err, ErrRoutingPrefix) {
+				t.Fatalf("Got %v, expected ErrRoutingPrefix", err)
+			}
+			continue
+		}
+		if err := rc.cryptPrefixed(X, true); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !fedPrefix(X) || seen[string(X)] {
+			t.Fatalf("Got %s for %03d", X, i)
+		}
+		seen[string(X)] = true
+		if err := rc.cryptPrefixed(X, false); err != nil || string(X) != fmt.Sprintf("%03d", i) {
+			t.Fatalf("Got %s, expected %03d", X, i)
+		}
+	}
+	if len(seen) != 380 {
+		t.Fatalf("Got %d ciphertexts, expected 380", len(seen))
+	}
+}
+
+func TestRoutingNumberErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		rc  *RoutingCipher
+		rtn string
+		err error
+	}{
+		{RoutingNumber(c), "", ErrRoutingFormat},
+		{RoutingNumber(c), "01100001", ErrRoutingFormat},
+		{RoutingNumber(c), "0110000150", ErrRoutingFormat},
+		{RoutingNumber(c), "01100001X", ErrRoutingFormat},
+		{RoutingNumber(c), "011-000-015", ErrRoutingFormat},
+		{RoutingNumber(c), "011000016", ErrRoutingChecksum},
+		{RoutingNumber(c), "101000015", ErrRoutingChecksum},
+		{RoutingNumber(c), "500000005", nil},
+		{RoutingNumber(c, WithFedPrefixes()), "500000005", ErrRoutingPrefix},
+		{RoutingNumber(newFormatCipher(t, "0123456789a")), "011000015", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := testCase.rc.Encrypt(testCase.rtn); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrSchemeDescriptor is returned by NewScheme, wrapped with the reason, for
+	// an invalid SchemeDescriptor.
+	ErrSchemeDescriptor = errors.New("formats: invalid scheme descriptor")
+
+	// ErrSchemeFormat is returned, in a SchemeFormatError, for a value that does
+	// not follow the segments of the scheme.
+	ErrSchemeFormat = errors.New("formats: value does not follow the scheme")
+
+	// ErrSchemeChecksum is returned, wrapped with the value, for a value whose
+	// check characters are wrong.
+	ErrSchemeChecksum = errors.New("formats: value fails the check of the scheme")
+)
+
+// A SchemeFormatError is returned for a value that does not follow the segments
+// of a scheme. Offset is the position of the first unexpected byte, or the
+// length of the value if it has the wrong length.
+type SchemeFormatError struct {
+	Scheme string
+	Offset int
+	Msg    string
+}
+
+func (e *SchemeFormatError) Error() string {
+	return fmt.Sprintf("formats: value does not follow the scheme %s: %s at position %d", e.Scheme, e.Msg, e.Offset)
+}
+
+func (e *SchemeFormatError) Unwrap() error {
+	return ErrSchemeFormat
+}
+
+// A SegmentKind says what a Segment holds and whether it is encrypted.
+type SegmentKind int
+
+const (
+	// SegmentDigits are encrypted digits.
+	SegmentDigits SegmentKind = iota
+	// SegmentLetters are encrypted upper-case letters.
+	SegmentLetters
+	// SegmentAlphabet are encrypted characters of the Alphabet of the segment.
+	SegmentAlphabet
+	// SegmentLiteral is the Literal of the segment, such as a separator.
+	SegmentLiteral
+	// SegmentClear are characters left in the clear, of the Alphabet of the
+	// segment unless it is empty.
+	SegmentClear
+	// SegmentCheck are the check characters the Checksum computes.
+	SegmentCheck
+)
+
+// A Segment is a run of characters of a scheme.
+type Segment struct {
+	Kind     SegmentKind
+	Len      int    // in bytes, implied by the Literal of a SegmentLiteral
+	Alphabet string // of a SegmentAlphabet or SegmentClear
+	Literal  string // of a SegmentLiteral
+}
+
+// A SchemeChecksum computes the check characters of a value from the characters
+// of the positions the checksum covers, in order.
+type SchemeChecksum func(data string) string
+
+// A SchemeDescriptor describes a scheme of identifiers, such as a national
+// identity number, as segments that together are Length bytes long.
+//
+// A scheme with a SegmentCheck, of which there may be one, has a Checksum. It
+// covers the positions in ChecksumOver, or if that is empty all positions that
+// are not in a SegmentLiteral or the SegmentCheck.
+type SchemeDescriptor struct {
+	Name         string
+	Length       int
+	Segments     []Segment
+	Checksum     SchemeChecksum
+	ChecksumOver []int
+}
+
+// A Scheme encrypts the identifiers of a SchemeDescriptor to identifiers of it.
+//
+// The characters of the encrypted segments are ranked as one index among all
+// their values, in a mixed radix of the sizes of the classes of their
+// positions, which is encrypted as a decimal string with cycle walking. The
+// other segments are kept and the check characters are computed again for the
+// ciphertext. Those of the plaintext follow from the rest of it, which is why
+// only valid identifiers are accepted and decryption can restore them.
+type Scheme struct {
+	cipher   *ff1.Cipher
+	desc     SchemeDescriptor
+	kinds    []SegmentKind // of the segments of the positions
+	classes  []string      // of the encrypted positions, "" for the others
+	allowed  []string      // characters of the other positions, "" for any
+	checkAt  int           // -1 without a SegmentCheck
+	checkLen int
+}
+
+// NewScheme returns a Scheme encrypting the identifiers of desc with cipher,
+// which must have the alphabet 0123456789. The encrypted segments may have at
+// most 10^18 values together.
+func NewScheme(cipher *ff1.Cipher, desc SchemeDescriptor) (*Scheme, error) {
+	s := &Scheme{cipher: cipher, desc: desc, checkAt: -1}
+	s.desc.Segments = append([]Segment{}, desc.Segments...)
+	s.desc.ChecksumOver = append([]int{}, desc.ChecksumOver...)
+
+	size := uint64(1)
+	for i, seg := range s.desc.Segments {
+		var class, allowed string
+		switch seg.Kind {
+		case SegmentDigits:
+			class = "0123456789"
+		case SegmentLetters:
+			class = upperLetters
+		case SegmentAlphabet:
+			class = seg.Alphabet
+			if len(class) < 2 || !distinct(class) {
+				return nil, fmt.Errorf("%w: segment %d has an alphabet of %q", ErrSchemeDescriptor, i, class)
+			}
+		case SegmentLiteral:
+			if seg.Len != 0 && seg.Len != len(seg.Literal) {
+				return nil, fmt.Errorf("%w: segment %d has a literal of %d bytes and a length of %d", ErrSchemeDescriptor, i, len(seg.Literal), seg.Len)
+			}
+			seg.Len = len(seg.Literal)
+		case SegmentClear:
+			allowed = seg.Alphabet
+		case SegmentCheck:
+			if s.checkAt >= 0 {
+				return nil, fmt.Errorf("%w: segment %d is a second check segment", ErrSchemeDescriptor, i)
+			}
+			s.checkAt, s.checkLen = len(s.classes), seg.Len
+		default:
+			return nil, fmt.Errorf("%w: segment %d has the kind %d", ErrSchemeDescriptor, i, seg.Kind)
+		}
+		if seg.Len < 1 {
+			return nil, fmt.Errorf("%w: segment %d is empty", ErrSchemeDescriptor, i)
+		}
+		for j := 0; j < seg.Len; j++ {
+			if class != "" {
+				if size > maxRankSize/uint64(len(class)) {
+					return nil, fmt.Errorf("%w: the encrypted segments have more than 10^18 values", ErrSchemeDescriptor)
+				}
+				size *= uint64(len(class))
+			}
+			if seg.Kind == SegmentLiteral {
+				allowed = seg.Literal[j : j+1]
+			}
+			s.kinds = append(s.kinds, seg.Kind)
+			s.classes = append(s.classes, class)
+			s.allowed = append(s.allowed, allowed)
+		}
+	}
+	if len(s.classes) != desc.Length {
+		return nil, fmt.Errorf("%w: the segments are %d bytes long instead of %d", ErrSchemeDescriptor, len(s.classes), desc.Length)
+	}
+	if size == 1 {
+		return nil, fmt.Errorf("%w: no segment is encrypted", ErrSchemeDescriptor)
+	}
+	if (s.checkAt >= 0) != (desc.Checksum != nil) {
+		return nil, fmt.Errorf("%w: a checksum needs one check segment", ErrSchemeDescriptor)
+	}
+
+	if s.checkAt >= 0 && len(s.desc.ChecksumOver) == 0 {
+		for i, kind := range s.kinds {
+			if kind != SegmentLiteral && kind != SegmentCheck {
+				s.desc.ChecksumOver = append(s.desc.ChecksumOver, i)
+			}
+		}
+	}
+	for _, at := range s.desc.ChecksumOver {
+		if at < 0 || at >= desc.Length || s.kinds[at] == SegmentCheck {
+			return nil, fmt.Errorf("%w: the checksum covers position %d", ErrSchemeDescriptor, at)
+		}
+	}
+	return s, nil
+}
+
+// Encrypt encrypts value.
+func (s *Scheme) Encrypt(value string) (string, error) {
+	return s.crypt(value, true)
+}
+
+// Decrypt reverses Encrypt.
+func (s *Scheme) Decrypt(value string) (string, error) {
+	return s.crypt(value, false)
+}
+
+func (s *Scheme) crypt(value string, encrypt bool) (string, error) {
+	if !bytes.Equal(s.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	if len(value) != s.desc.Length {
+		return "", &SchemeFormatError{Scheme: s.desc.Name, Offset: len(value), Msg: fmt.Sprintf("length %d instead of %d", len(value), s.desc.Length)}
+	}
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch chars := s.classes[i] + s.allowed[i]; {
+		case s.kinds[i] == SegmentCheck || chars == "":
+		case strings.IndexByte(chars, ch) < 0:
+			return "", &SchemeFormatError{Scheme: s.desc.Name, Offset: i, Msg: fmt.Sprintf("%q is not one of %s", ch, chars)}
+		}
+	}
+	if s.checkAt >= 0 {
+		check, err := s.check(value)
+		if err != nil {
+			return "", err
+		}
+		if check != value[s.checkAt:s.checkAt+s.checkLen] {
+			return "", fmt.Errorf("%w: %q", ErrSchemeChecksum, value)
+		}
+	}
+
+	out, err := cryptTemplate(s.cipher, s.classes, value, encrypt)
+	if err != nil || s.checkAt < 0 {
+		return out, err
+	}
+	check, err := s.check(out)
+	if err != nil {
+		return "", err
+	}
+	return out[:s.checkAt] + check + out[s.checkAt+s.checkLen:], nil
+}
+
+// check returns the check characters of value.
+func (s *Scheme) check(value string) (string, error) {
+	data := make([]byte, len(s.desc.ChecksumOver))
+	for i, at := range s.desc.ChecksumOver {
+		data[i] = value[at]
+	}
+	check := s.desc.Checksum(string(data))
+	if len(check) != s.checkLen {
+		return "", fmt.Errorf("formats: checksum of the scheme %s returned %d characters instead of %d", s.desc.Name, len(check), s.checkLen)
+	}
+	return check, nil
+}
+
+// LuhnCheck is a SchemeChecksum computing the Luhn check digit of the digits of
+// data, for a check segment after them.
+func LuhnCheck(data string) string {
+	D := []byte(data + "0")
+	n := 0
+	for _, b := range D {
+		if isDigit(b) {
+			D[n] = b
+			n++
+		}
+	}
+	return string(luhnDigit((10-luhnSum(D[:n]))%10, false))
+}
+
+// Mod97Check is a SchemeChecksum computing the two check digits of ISO 7064
+// MOD 97-10, as IBANs have them, of the digits and upper-case letters of data.
+// Letters count as 10 to 35.
+func Mod97Check(data string) string {
+	r := 0
+	for i := 0; i < len(data); i++ {
+		switch b := data[i]; {
+		case isDigit(b):
+			r = (r*10 + int(b-'0')) % 97
+		case isUpper(b):
+			r = (r*100 + int(b-'A') + 10) % 97
+		}
+	}
+	return fmt.Sprintf("%02d", 98-r*100%97)
+}
+
+// Mod11Check returns a SchemeChecksum computing a check digit modulo 11 of the
+// digits of data: 11 minus their sum, weighted by weights from the left and
+// repeated as needed, modulo 11, with X for 10. Without weights the digits
+// are weighted by their distance from the check digit, as in ISBN-10.
+func Mod11Check(weights ...int) SchemeChecksum {
+	weights = append([]int{}, weights...)
+	return func(data string) string {
+		var D []int
+		for i := 0; i < len(data); i++ {
+			if isDigit(data[i]) {
+				D = append(D, int(data[i]-'0'))
+			}
+		}
+		sum := 0
+		for i, d := range D {
+			w := len(D) + 1 - i
+			if len(weights) > 0 {
+				w = weights[i%len(weights)]
+			}
+			sum += d * w
+		}
+		c := (11 - sum%11) % 11
+		if c == 10 {
+			return "X"
+		}
+		return strconv.Itoa(c)
+	}
+}
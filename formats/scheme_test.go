@@ -0,0 +1,262 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// ahvDescriptor describes the Swiss social security number, 756 and nine digits
+// followed by an EAN-13 check digit over all twelve
+func ahvDescriptor() SchemeDescriptor {
+	return SchemeDescriptor{
+		Name:   "AHV",
+		Length: 16,
+		Segments: []Segment{
+			{Kind: SegmentLiteral, Literal: "756."},
+			{Kind: SegmentDigits, Len: 4},
+			{Kind: SegmentLiteral, Literal: "."},
+			{Kind: SegmentDigits, Len: 4},
+			{Kind: SegmentLiteral, Literal: "."},
+			{Kind: SegmentDigits, Len: 1},
+			{Kind: SegmentCheck, Len: 1},
+		},
+		Checksum: func(data string) string {
+			return strconv.Itoa((10 - eanSum([]byte(data+"0"))) % 10)
+		},
+		ChecksumOver: []int{0, 1, 2, 4, 5, 6, 7, 9, 10, 11, 12, 14},
+	}
+}
+
+// dniDescriptor describes the Spanish identity number, eight digits followed by
+// a letter for their value modulo 23
+func dniDescriptor() SchemeDescriptor {
+	return SchemeDescriptor{
+		Name:   "DNI",
+		Length: 9,
+		Segments: []Segment{
+			{Kind: SegmentDigits, Len: 8},
+			{Kind: SegmentCheck, Len: 1},
+		},
+		Checksum: func(data string) string {
+			n, _ := strconv.Atoi(data)
+			return string("TRWAGMYFPDXBNJZSQVHLCKE"[n%23])
+		},
+	}
+}
+
+func TestScheme(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	ahv, err := NewScheme(c, ahvDescriptor())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	dni, err := NewScheme(c, dniDescriptor())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for idx, testCase := range []struct {
+		scheme     *Scheme
+		value      string
+		ciphertext string
+	}{
+		{ahv, "756.9217.0769.85", "756.0528.3277.59"},
+		{ahv, "756.1234.5678.97", "756.3219.5693.53"},
+		{ahv, "756.0000.0000.02", "756.5993.7749.30"},
+		{dni, "12345678Z", "14009665C"},
+		{dni, "00000000T", "61260351N"},
+		{dni, "99999999R", "04252346Z"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.scheme.Encrypt(testCase.value)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.scheme.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.value {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.value)
+			}
+		})
+	}
+}
+
+// Ciphertexts of chains of encryptions are valid identifiers
+func TestSchemeChains(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		desc    SchemeDescriptor
+		value   string
+		pattern string
+	}{
+		{ahvDescriptor(), "756.9217.0769.85", `^756\.[0-9]{4}\.[0-9]{4}\.[0-9]{2}$`},
+		{dniDescriptor(), "12345678Z", `^[0-9]{8}[A-Z]$`},
+		{SchemeDescriptor{
+			Name:   "mixed",
+			Length: 12,
+			Segments: []Segment{
+				{Kind: SegmentClear, Len: 2, Alphabet: "ABC"},
+				{Kind: SegmentLetters, Len: 2},
+				{Kind: SegmentLiteral, Literal: "-"},
+				{Kind: SegmentAlphabet, Len: 3, Alphabet: "XYZ"},
+				{Kind: SegmentDigits, Len: 2},
+				{Kind: SegmentCheck, Len: 2},
+			},
+			Checksum: Mod97Check,
+		}, "CAQR-XYZ1286", `^CA[A-Z]{2}-[XYZ]{3}[0-9]{4}$`},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			scheme, err := NewScheme(c, testCase.desc)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			pattern := regexp.MustCompile(testCase.pattern)
+			value := testCase.value
+			if testCase.desc.Name == "mixed" {
+				value = value[:10] + Mod97Check(value[:4]+value[5:10])
+			}
+			seen := map[string]bool{}
+			for i := 0; i < 100; i++ {
+				ciphertext, err := scheme.Encrypt(value)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !pattern.MatchString(ciphertext) || seen[ciphertext] {
+					t.Fatalf("Got %q for %q", ciphertext, value)
+				}
+				seen[ciphertext] = true
+				if plaintext, _ := scheme.Decrypt(ciphertext); plaintext != value {
+					t.Fatalf("Got %q, expected %q", plaintext, value)
+				}
+				value = ciphertext
+			}
+		})
+	}
+}
+
+func TestSchemeChecksums(t *testing.T) {
+	for idx, testCase := range []struct {
+		checksum SchemeChecksum
+		data     string
+		check    string
+	}{
+		{LuhnCheck, "7992739871", "3"},
+		{LuhnCheck, "4111 1111 1111 111", "1"},
+		{Mod97Check, "WEST12345698765432GB", "82"},
+		{Mod97Check, "", "98"},
+		{Mod11Check(), "030640615", "2"},
+		{Mod11Check(), "080442957", "X"},
+		{Mod11Check(2, 7, 6, 5, 4, 3, 2), "1-234567", "4"},
+		{Mod11Check(1, 3), "12345", "6"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if check := testCase.checksum(testCase.data); check != testCase.check {
+				t.Fatalf("Got %q, expected %q", check, testCase.check)
+			}
+		})
+	}
+}
+
+func TestSchemeDescriptorErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	digits := func(n int) Segment { return Segment{Kind: SegmentDigits, Len: n} }
+	for idx, desc := range []SchemeDescriptor{
+		{Length: 9, Segments: []Segment{digits(8)}},
+		{Length: 7, Segments: []Segment{digits(8)}},
+		{Length: 0},
+		{Length: 3, Segments: []Segment{digits(3), {Kind: SegmentDigits}}},
+		{Length: 3, Segments: []Segment{{Kind: SegmentLiteral, Literal: "ABC"}}},
+		{Length: 3, Segments: []Segment{{Kind: SegmentClear, Len: 3}}},
+		{Length: 4, Segments: []Segment{digits(2), {Kind: SegmentLiteral, Literal: "-", Len: 2}}},
+		{Length: 4, Segments: []Segment{digits(2), {Kind: SegmentAlphabet, Len: 2, Alphabet: "AA"}}},
+		{Length: 4, Segments: []Segment{digits(2), {Kind: SegmentAlphabet, Len: 2, Alphabet: "A"}}},
+		{Length: 4, Segments: []Segment{digits(2), {Kind: SegmentKind(9), Len: 2}}},
+		{Length: 19, Segments: []Segment{digits(19)}},
+		{Length: 9, Segments: []Segment{digits(8), {Kind: SegmentCheck, Len: 1}}},
+		{Length: 8, Segments: []Segment{digits(8)}, Checksum: LuhnCheck},
+		{Length: 10, Segments: []Segment{digits(8), {Kind: SegmentCheck, Len: 1}, {Kind: SegmentCheck, Len: 1}}, Checksum: LuhnCheck},
+		{Length: 9, Segments: []Segment{digits(8), {Kind: SegmentCheck, Len: 1}}, Checksum: LuhnCheck, ChecksumOver: []int{8}},
+		{Length: 9, Segments: []Segment{digits(8), {Kind: SegmentCheck, Len: 1}}, Checksum: LuhnCheck, ChecksumOver: []int{9}},
+		{Length: 9, Segments: []Segment{digits(8), {Kind: SegmentCheck, Len: 1}}, Checksum: LuhnCheck, ChecksumOver: []int{-1}},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := NewScheme(c, desc); !errors.Is(err, ErrSchemeDescriptor) {
+				t.Fatalf("Got %v, expected %v", err, ErrSchemeDescriptor)
+			}
+		})
+	}
+}
+
+func TestSchemeErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	ahv, _ := NewScheme(c, ahvDescriptor())
+	dni, _ := NewScheme(c, dniDescriptor())
+	wrongLen, _ := NewScheme(c, SchemeDescriptor{
+		Length:   9,
+		Segments: []Segment{{Kind: SegmentDigits, Len: 8}, {Kind: SegmentCheck, Len: 1}},
+		Checksum: Mod97Check,
+	})
+	for idx, testCase := range []struct {
+		scheme *Scheme
+		value  string
+		err    error
+		offset int
+	}{
+		{ahv, "756.9217.0769.8", ErrSchemeFormat, 15},
+		{ahv, "757.9217.0769.85", ErrSchemeFormat, 2},
+		{ahv, "756-9217-0769-85", ErrSchemeFormat, 3},
+		{ahv, "756.92I7.0769.85", ErrSchemeFormat, 6},
+		{ahv, "756.9217.0769.86", ErrSchemeChecksum, 0},
+		{dni, "12345678A", ErrSchemeChecksum, 0},
+		{dni, "1234567AZ", ErrSchemeFormat, 7},
+		{wrongLen, "123456781", nil, 0},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.scheme.Encrypt(testCase.value)
+			if testCase.err == nil {
+				if err == nil {
+					t.Fatalf("Expected an error for a checksum of the wrong length")
+				}
+				return
+			}
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+			var formatErr *SchemeFormatError
+			if errors.As(err, &formatErr) && formatErr.Offset != testCase.offset {
+				t.Fatalf("Got offset %d, expected %d", formatErr.Offset, testCase.offset)
+			}
+		})
+	}
+
+	scheme, _ := NewScheme(newFormatCipher(t, "0123456789AB"), dniDescriptor())
+	if _, err := scheme.Encrypt("12345678Z"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected %v", err, ErrAlphabet)
+	}
+}
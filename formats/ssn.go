@@ -0,0 +1,158 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package formats encrypts values of well-known formats, keeping their layout.
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// maxWalk bounds the re-encryptions of cycle walking. Ciphertexts outside the
+// format are rare, an all-zero segment of a social security number for example
+// comes up about once in 90 numbers, so the bound is only reached by a fault.
+const maxWalk = 1000
+
+var digits = []byte("0123456789")
+
+var (
+	// ErrSSNFormat is returned for a value that is neither nine digits nor
+	// nine digits in the layout 123-45-6789.
+	ErrSSNFormat = errors.New("formats: not a social security number")
+
+	// ErrSSNSegment is returned, with WithValidSegments, for a number whose area,
+	// group or serial is all zeros.
+	ErrSSNSegment = errors.New("formats: social security number has an all-zero segment")
+
+	// ErrAlphabet is returned if the cipher does not have the alphabet 0123456789.
+	ErrAlphabet = errors.New("formats: cipher alphabet must be the digits 0123456789")
+)
+
+// An SSNOption configures an SSNCipher.
+type SSNOption func(*SSNCipher)
+
+// WithValidSegments makes the SSNCipher cycle-walk, encrypting again until the
+// ciphertext has no all-zero area, group or serial, which validators reject.
+// Plaintexts with such a segment are then rejected with ErrSSNSegment, as they
+// would not decrypt to themselves. Other rules, such as the areas 666 and 900 to
+// 999 never being issued, are not applied.
+func WithValidSegments() SSNOption {
+	return func(s *SSNCipher) {
+		s.validSegments = true
+	}
+}
+
+// An SSNCipher encrypts US social security numbers to other social security
+// numbers of the same layout.
+type SSNCipher struct {
+	cipher        *ff1.Cipher
+	validSegments bool
+}
+
+// SSN returns an SSNCipher encrypting the nine digits of a number with cipher,
+// which must have the alphabet 0123456789.
+func SSN(cipher *ff1.Cipher, opts ...SSNOption) *SSNCipher {
+	s := &SSNCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Encrypt encrypts ssn, given as 123-45-6789 or 123456789, and returns the
+// ciphertext in the same layout.
+func (s *SSNCipher) Encrypt(ssn string) (string, error) {
+	return s.crypt(ssn, true)
+}
+
+// Decrypt reverses Encrypt.
+func (s *SSNCipher) Decrypt(ssn string) (string, error) {
+	return s.crypt(ssn, false)
+}
+
+func (s *SSNCipher) crypt(ssn string, encrypt bool) (string, error) {
+	if !bytes.Equal(s.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	dashed := len(ssn) == 11
+	X, ok := parseSSN(ssn)
+	if !ok {
+		return "", ErrSSNFormat
+	}
+	if s.validSegments && zeroSegment(X) {
+		return "", ErrSSNSegment
+	}
+
+	for i := 0; i < maxWalk; i++ {
+		var err error
+		if encrypt {
+			_, err = s.cipher.EncryptInto(X, X)
+		} else {
+			_, err = s.cipher.DecryptInto(X, X)
+		}
+		if err != nil {
+			return "", fmt.Errorf("formats: %w", err)
+		}
+		if !s.validSegments || !zeroSegment(X) {
+			return formatSSN(X, dashed), nil
+		}
+	}
+	return "", fmt.Errorf("formats: segment still all zeros after %d rounds of cycle walking", maxWalk)
+}
+
+// parseSSN returns the nine digits of ssn.
+func parseSSN(ssn string) ([]byte, bool) {
+	var X []byte
+	switch len(ssn) {
+	case 9:
+		X = []byte(ssn)
+	case 11:
+		if ssn[3] != '-' || ssn[6] != '-' {
+			return nil, false
+		}
+		X = []byte(ssn[:3] + ssn[4:6] + ssn[7:])
+	default:
+		return nil, false
+	}
+	for _, b := range X {
+		if b < '0' || b > '9' {
+			return nil, false
+		}
+	}
+	return X, true
+}
+
+func formatSSN(X []byte, dashed bool) string {
+	if !dashed {
+		return string(X)
+	}
+	return string(X[:3]) + "-" + string(X[3:5]) + "-" + string(X[5:])
+}
+
+// zeroSegment reports whether the area, group or serial of X is all zeros.
+func zeroSegment(X []byte) bool {
+	allZero := func(seg []byte) bool {
+		return len(bytes.Trim(seg, "0")) == 0
+	}
+	return allZero(X[:3]) || allZero(X[3:5]) || allZero(X[5:])
+}
@@ -0,0 +1,150 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+func newFormatCipher(t *testing.T, alphabet string) *ff1.Cipher {
+	t.Helper()
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	c, err := ff1.NewCipherWithAlphabet([]byte(alphabet), 8, key, []byte("ssn"))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	return &c
+}
+
+func TestSSN(t *testing.T) {
+	s := SSN(newFormatCipher(t, "0123456789"))
+	for idx, testCase := range []struct {
+		ssn  string
+		err  error
+		dash bool
+	}{
+		{"123-45-6789", nil, true},
+		{"123456789", nil, false},
+		{"000-00-0000", nil, true},
+		{"078051120", nil, false},
+		{"12345678", ErrSSNFormat, false},
+		{"1234567890", ErrSSNFormat, false},
+		{"123-456-789", ErrSSNFormat, false},
+		{"123 45 6789", ErrSSNFormat, false},
+		{"12345678X", ErrSSNFormat, false},
+		{"", ErrSSNFormat, false},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := s.Encrypt(testCase.ssn)
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+			if err != nil {
+				if _, err := s.Decrypt(testCase.ssn); !errors.Is(err, testCase.err) {
+					t.Fatalf("Decrypt got %v, expected %v", err, testCase.err)
+				}
+				return
+			}
+			if ciphertext == testCase.ssn || len(ciphertext) != len(testCase.ssn) {
+				t.Fatalf("Got %q for %q", ciphertext, testCase.ssn)
+			}
+			if testCase.dash && (ciphertext[3] != '-' || ciphertext[6] != '-') {
+				t.Fatalf("Separators not kept in %q", ciphertext)
+			}
+			plaintext, err := s.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.ssn {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.ssn)
+			}
+		})
+	}
+
+	// Both layouts encrypt the same digits
+	dashed, _ := s.Encrypt("123-45-6789")
+	plain, _ := s.Encrypt("123456789")
+	if dashed[:3]+dashed[4:6]+dashed[7:] != plain {
+		t.Fatalf("Got %q and %q", dashed, plain)
+	}
+}
+
+func TestSSNValidSegments(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	plain := SSN(c)
+	valid := SSN(c, WithValidSegments())
+
+	// Find numbers whose plain ciphertext has an all-zero segment
+	walked := 0
+	for i := 0; i < 20000 && walked < 5; i++ {
+		ssn := fmt.Sprintf("%03d-%02d-%04d", 1+i%899, 1+i%99, 1+i)
+		ciphertext, err := plain.Encrypt(ssn)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		X, _ := parseSSN(ciphertext)
+		if !zeroSegment(X) {
+			continue
+		}
+		walked++
+
+		ciphertext, err = valid.Encrypt(ssn)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		X, _ = parseSSN(ciphertext)
+		if zeroSegment(X) {
+			t.Fatalf("Got %q for %q", ciphertext, ssn)
+		}
+		plaintext, err := valid.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if plaintext != ssn {
+			t.Fatalf("Got %q, expected %q", plaintext, ssn)
+		}
+	}
+	if walked == 0 {
+		t.Fatalf("No ciphertext with an all-zero segment found")
+	}
+
+	for idx, ssn := range []string{"000-12-3456", "123-00-4567", "123450000", "000000000"} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := valid.Encrypt(ssn); !errors.Is(err, ErrSSNSegment) {
+				t.Fatalf("Got %v, expected ErrSSNSegment", err)
+			}
+			if _, err := valid.Decrypt(ssn); !errors.Is(err, ErrSSNSegment) {
+				t.Fatalf("Got %v, expected ErrSSNSegment", err)
+			}
+		})
+	}
+}
+
+func TestSSNAlphabet(t *testing.T) {
+	s := SSN(newFormatCipher(t, "9876543210"))
+	if _, err := s.Encrypt("123-45-6789"); !errors.Is(err, ErrAlphabet) {
+		t.Fatalf("Got %v, expected ErrAlphabet", err)
+	}
+}
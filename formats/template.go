@@ -0,0 +1,160 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// Templates describe the layouts of values such as postal codes and license
+// plates. A byte of a template stands for one character of a class: '9' for a
+// digit, 'A' for an upper-case letter, or the characters a map of classes has
+// for it, which may also replace those two. Other bytes, such as spaces and
+// hyphens, stand for themselves.
+
+const upperLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// templateClass returns the characters of the class of the template byte b, or
+// "" if b stands for itself.
+func templateClass(classes map[byte]string, b byte) string {
+	if class, ok := classes[b]; ok {
+		return class
+	}
+	switch b {
+	case '9':
+		return "0123456789"
+	case 'A':
+		return upperLetters
+	}
+	return ""
+}
+
+// matchTemplate returns the classes of the positions of value if it matches
+// template, "" for those that stand for themselves.
+func matchTemplate(classes map[byte]string, template, value string) ([]string, bool) {
+	if len(value) != len(template) {
+		return nil, false
+	}
+	matched := make([]string, len(template))
+	for i := 0; i < len(template); i++ {
+		class := templateClass(classes, template[i])
+		if class == "" && value[i] != template[i] || class != "" && strings.IndexByte(class, value[i]) < 0 {
+			return nil, false
+		}
+		matched[i] = class
+	}
+	return matched, true
+}
+
+// validTemplate reports whether template has at most maxRankSize values and
+// none of its classes has a character twice.
+func validTemplate(classes map[byte]string, template string) bool {
+	size := uint64(1)
+	for i := 0; i < len(template); i++ {
+		class := templateClass(classes, template[i])
+		if class == "" {
+			continue
+		}
+		if size > maxRankSize/uint64(len(class)) || !distinct(class) {
+			return false
+		}
+		size *= uint64(len(class))
+	}
+	return true
+}
+
+// distinct reports whether no character of class is there twice.
+func distinct(class string) bool {
+	var seen [256]bool
+	for i := 0; i < len(class); i++ {
+		if seen[class[i]] {
+			return false
+		}
+		seen[class[i]] = true
+	}
+	return true
+}
+
+// cryptTemplate encrypts or decrypts value with c, which must have the alphabet
+// 0123456789. matched holds the classes of its positions, from matchTemplate of
+// a valid template.
+//
+// The value is ranked as its index among the values of the template, in a mixed
+// radix of the sizes of the classes, and the rank encrypted with cryptRank, so
+// every character stays in its class. Values with more than maxRankSize of them,
+// such as long runs of letters, are ranked with cryptBigRank instead.
+func cryptTemplate(c *ff1.Cipher, matched []string, value string, encrypt bool) (string, error) {
+	var rank, size uint64 = 0, 1
+	for i, class := range matched {
+		if class != "" {
+			if size > maxRankSize/uint64(len(class)) {
+				return cryptBigTemplate(c, matched, value, encrypt)
+			}
+			rank = rank*uint64(len(class)) + uint64(strings.IndexByte(class, value[i]))
+			size *= uint64(len(class))
+		}
+	}
+	if size == 1 {
+		return value, nil
+	}
+	rank, err := cryptRank(c, rank, size, encrypt)
+	if err != nil {
+		return "", err
+	}
+
+	out := []byte(value)
+	for i := len(matched) - 1; i >= 0; i-- {
+		if class := matched[i]; class != "" {
+			out[i] = class[rank%uint64(len(class))]
+			rank /= uint64(len(class))
+		}
+	}
+	return string(out), nil
+}
+
+// cryptBigTemplate is cryptTemplate for values with more than maxRankSize of
+// them.
+func cryptBigTemplate(c *ff1.Cipher, matched []string, value string, encrypt bool) (string, error) {
+	rank, size := new(big.Int), big.NewInt(1)
+	for i, class := range matched {
+		if class != "" {
+			radix := big.NewInt(int64(len(class)))
+			rank.Mul(rank, radix).Add(rank, big.NewInt(int64(strings.IndexByte(class, value[i]))))
+			size.Mul(size, radix)
+		}
+	}
+	rank, err := cryptBigRank(c, rank, size, encrypt)
+	if err != nil {
+		return "", err
+	}
+
+	out := []byte(value)
+	digit := new(big.Int)
+	for i := len(matched) - 1; i >= 0; i-- {
+		if class := matched[i]; class != "" {
+			rank.DivMod(rank, big.NewInt(int64(len(class))), digit)
+			out[i] = class[digit.Int64()]
+		}
+	}
+	return string(out), nil
+}
@@ -0,0 +1,178 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrTimestampWindow is returned for a granularity that is not positive, or
+	// a window that ends before it starts or spans more than 292 years.
+	ErrTimestampWindow = errors.New("formats: invalid timestamp window")
+
+	// ErrTimestampPrecision is returned for a timestamp with too few fractional
+	// digits to write the instants of the granularity.
+	ErrTimestampPrecision = errors.New("formats: timestamp has too few fractional digits for the granularity")
+)
+
+// A TimestampFormatError is returned for a value that is not an RFC 3339
+// timestamp.
+type TimestampFormatError struct {
+	Value string
+	Err   error // from time.Parse
+}
+
+func (e *TimestampFormatError) Error() string {
+	return fmt.Sprintf("formats: %q is not an RFC 3339 timestamp: %v", e.Value, e.Err)
+}
+
+func (e *TimestampFormatError) Unwrap() error {
+	return e.Err
+}
+
+// A TimestampRangeError is returned for a timestamp outside the window.
+type TimestampRangeError struct {
+	Time     time.Time
+	Min, Max time.Time
+}
+
+func (e *TimestampRangeError) Error() string {
+	return fmt.Sprintf("formats: timestamp %s is outside the window from %s to %s",
+		e.Time.Format(time.RFC3339Nano), e.Min.Format(time.RFC3339Nano), e.Max.Format(time.RFC3339Nano))
+}
+
+// A TimestampAlignmentError is returned, without WithTruncation, for a timestamp
+// that is not a whole number of granularities after the start of the window.
+type TimestampAlignmentError struct {
+	Time        time.Time
+	Granularity time.Duration
+}
+
+func (e *TimestampAlignmentError) Error() string {
+	return fmt.Sprintf("formats: timestamp %s is not aligned to %v", e.Time.Format(time.RFC3339Nano), e.Granularity)
+}
+
+// A TimestampOption configures a TimestampCipher.
+type TimestampOption func(*TimestampCipher)
+
+// WithTruncation makes the TimestampCipher truncate timestamps that are not
+// aligned to the granularity, instead of rejecting them. Decryption then returns
+// the truncated timestamp.
+func WithTruncation() TimestampOption {
+	return func(c *TimestampCipher) {
+		c.truncate = true
+	}
+}
+
+// A TimestampCipher encrypts RFC 3339 timestamps to other timestamps within a
+// window.
+//
+// A timestamp is ranked as the number of granularities from the start of the
+// window to it, which is encrypted as a string of decimal digits. Ciphertexts
+// beyond the window are encrypted again until one is in it (cycle walking),
+// which keeps it a permutation of the aligned instants of the window.
+//
+// The ciphertext is written with the UTC offset of the plaintext, exactly as
+// it was, so Z stays Z and +00:00 stays +00:00, and with as many fractional
+// digits. Only the instant is encrypted, the offset is not, and the instant may
+// come out on the other side of a change to daylight saving time.
+type TimestampCipher struct {
+	cipher      *ff1.Cipher
+	min, max    time.Time
+	granularity time.Duration
+	truncate    bool
+}
+
+// Timestamp returns a TimestampCipher encrypting the instants from min to max,
+// both included, at steps of granularity from min, with cipher, which must have
+// the alphabet 0123456789.
+func Timestamp(cipher *ff1.Cipher, min, max time.Time, granularity time.Duration, opts ...TimestampOption) *TimestampCipher {
+	c := &TimestampCipher{cipher: cipher, min: min, max: max, granularity: granularity}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts ts.
+func (c *TimestampCipher) Encrypt(ts string) (string, error) {
+	return c.crypt(ts, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *TimestampCipher) Decrypt(ts string) (string, error) {
+	return c.crypt(ts, false)
+}
+
+func (c *TimestampCipher) crypt(ts string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	span := c.max.Sub(c.min)
+	if c.granularity <= 0 || span < 0 || c.max.After(c.min.Add(span)) {
+		return "", ErrTimestampWindow
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return "", &TimestampFormatError{Value: ts, Err: err}
+	}
+	if t.Before(c.min) || t.After(c.max) {
+		return "", &TimestampRangeError{Time: t, Min: c.min, Max: c.max}
+	}
+
+	// The seconds are followed by the fraction, if any, and the offset
+	zone := strings.LastIndexAny(ts, "Zz+-")
+	fraction := 0
+	if dot := strings.IndexByte(ts, '.'); dot >= 0 {
+		fraction = zone - dot - 1
+	}
+	unit := time.Duration(1)
+	for i := fraction; i < 9; i++ {
+		unit *= 10
+	}
+	if c.granularity%unit != 0 || time.Duration(c.min.Nanosecond())%unit != 0 {
+		return "", fmt.Errorf("%w: %d for %v", ErrTimestampPrecision, fraction, c.granularity)
+	}
+
+	diff := t.Sub(c.min)
+	if diff%c.granularity != 0 && !c.truncate {
+		return "", &TimestampAlignmentError{Time: t, Granularity: c.granularity}
+	}
+	size := uint64(span/c.granularity) + 1
+	rank, err := cryptRank(c.cipher, uint64(diff/c.granularity), size, encrypt)
+	if err != nil {
+		return "", err
+	}
+
+	layout := "2006-01-02T15:04:05"
+	if fraction > 0 {
+		layout += "." + strings.Repeat("0", fraction)
+	}
+	_, offset := t.Zone()
+	out := c.min.Add(time.Duration(rank) * c.granularity).In(time.FixedZone("", offset))
+	return out.Format(layout) + ts[zone:], nil
+}
@@ -0,0 +1,174 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	min := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	max := time.Date(2024, time.December, 31, 23, 59, 59, 0, time.UTC)
+	for idx, testCase := range []struct {
+		granularity time.Duration
+		ts          string
+		ciphertext  string
+	}{
+		{time.Second, "2024-07-15T12:34:00Z", "2024-01-11T20:19:09Z"},
+		{time.Second, "2024-07-15T14:34:00+02:00", "2024-01-11T22:19:09+02:00"},
+		{time.Second, "2024-07-15T12:34:00+00:00", "2024-01-11T20:19:09+00:00"},
+		{time.Second, "2024-07-15T12:34:00.000Z", "2024-01-11T20:19:09.000Z"},
+		{time.Second, "2024-11-03T01:30:00-05:00", "2024-09-03T02:20:53-05:00"},
+		{time.Minute, "2024-07-15T12:34:00Z", "2024-12-10T11:40:00Z"},
+		{time.Minute, "2024-03-31T01:30:00+01:00", "2024-02-10T17:12:00+01:00"},
+		{time.Minute, "2024-03-31T03:30:00+02:00", "2024-05-13T22:51:00+02:00"},
+		{time.Minute, "2024-01-01T00:00:00Z", "2024-04-18T16:53:00Z"},
+		{time.Minute, "2024-12-31T23:59:00Z", "2024-02-14T20:55:00Z"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ts := Timestamp(c, min, max, testCase.granularity)
+			ciphertext, err := ts.Encrypt(testCase.ts)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := ts.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.ts {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.ts)
+			}
+		})
+	}
+}
+
+// The instants of a window around the change to daylight saving time in Zurich,
+// written with the offset in effect at each
+func TestTimestampDST(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Skipf("No time zone database: %v", err)
+	}
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		min, max    time.Time
+		granularity time.Duration
+		layout      string
+	}{
+		{time.Date(2024, time.March, 31, 1, 0, 0, 0, zurich), time.Date(2024, time.March, 31, 4, 0, 0, 0, zurich), time.Minute, time.RFC3339},
+		{time.Date(2024, time.October, 27, 1, 0, 0, 0, zurich), time.Date(2024, time.October, 27, 4, 0, 0, 0, zurich), 5 * time.Minute, time.RFC3339},
+		{time.Date(2024, time.October, 27, 2, 0, 0, 0, zurich), time.Date(2024, time.October, 27, 2, 1, 0, 0, zurich), 100 * time.Millisecond, "2006-01-02T15:04:05.0Z07:00"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ts := Timestamp(c, testCase.min, testCase.max, testCase.granularity)
+			seen := map[time.Time]bool{}
+			for at := testCase.min; !at.After(testCase.max); at = at.Add(testCase.granularity) {
+				plain := at.In(zurich).Format(testCase.layout)
+				ciphertext, err := ts.Encrypt(plain)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				got, err := time.Parse(time.RFC3339Nano, ciphertext)
+				if err != nil || got.Before(testCase.min) || got.After(testCase.max) || seen[got.UTC()] {
+					t.Fatalf("Got %q for %q", ciphertext, plain)
+				}
+				if got.Sub(testCase.min)%testCase.granularity != 0 || ciphertext[len(ciphertext)-6:] != plain[len(plain)-6:] {
+					t.Fatalf("Got %q for %q", ciphertext, plain)
+				}
+				seen[got.UTC()] = true
+				if plaintext, _ := ts.Decrypt(ciphertext); plaintext != plain {
+					t.Fatalf("Got %q, expected %q", plaintext, plain)
+				}
+			}
+		})
+	}
+}
+
+func TestTimestampTruncation(t *testing.T) {
+	min := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	max := min.Add(24 * time.Hour)
+	ts := Timestamp(newFormatCipher(t, "0123456789"), min, max, time.Minute, WithTruncation())
+	ciphertext, err := ts.Encrypt("2024-01-01T12:34:56+01:00")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if plaintext, _ := ts.Decrypt(ciphertext); plaintext != "2024-01-01T12:34:00+01:00" {
+		t.Fatalf("Got %q, expected the truncated timestamp", plaintext)
+	}
+}
+
+func TestTimestampErrors(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	min := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	max := min.Add(24 * time.Hour)
+	for idx, testCase := range []struct {
+		ts  *TimestampCipher
+		in  string
+		err error
+	}{
+		{Timestamp(c, min, max, time.Minute), "2024-01-01 12:00:00Z", &TimestampFormatError{}},
+		{Timestamp(c, min, max, time.Minute), "2024-01-01T12:00:00", &TimestampFormatError{}},
+		{Timestamp(c, min, max, time.Minute), "", &TimestampFormatError{}},
+		{Timestamp(c, min, max, time.Minute), "2023-12-31T23:59:00Z", &TimestampRangeError{}},
+		{Timestamp(c, min, max, time.Minute), "2024-01-01T00:30:00+01:00", &TimestampRangeError{}},
+		{Timestamp(c, min, max, time.Minute), "2024-01-02T00:01:00Z", &TimestampRangeError{}},
+		{Timestamp(c, min, max, time.Minute), "2024-01-01T12:00:30Z", &TimestampAlignmentError{}},
+		{Timestamp(c, min, max, time.Second), "2024-01-01T12:00:00.5Z", &TimestampAlignmentError{}},
+		{Timestamp(c, min, max, time.Millisecond), "2024-01-01T12:00:00.5Z", ErrTimestampPrecision},
+		{Timestamp(c, min, max, time.Millisecond), "2024-01-01T12:00:00.500Z", nil},
+		{Timestamp(c, min.Add(time.Millisecond), max, time.Second), "2024-01-01T12:00:00Z", ErrTimestampPrecision},
+		{Timestamp(c, min, max, 0), "2024-01-01T12:00:00Z", ErrTimestampWindow},
+		{Timestamp(c, max, min, time.Minute), "2024-01-01T12:00:00Z", ErrTimestampWindow},
+		{Timestamp(c, time.Time{}, max, time.Minute), "2024-01-01T12:00:00Z", ErrTimestampWindow},
+		{Timestamp(newFormatCipher(t, "0123456789:"), min, max, time.Minute), "2024-01-01T12:00:00Z", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := testCase.ts.Encrypt(testCase.in)
+			switch testCase.err.(type) {
+			case *TimestampFormatError:
+				var formatErr *TimestampFormatError
+				if !errors.As(err, &formatErr) {
+					t.Fatalf("Got %v, expected a TimestampFormatError", err)
+				}
+			case *TimestampRangeError:
+				var rangeErr *TimestampRangeError
+				if !errors.As(err, &rangeErr) {
+					t.Fatalf("Got %v, expected a TimestampRangeError", err)
+				}
+			case *TimestampAlignmentError:
+				var alignErr *TimestampAlignmentError
+				if !errors.As(err, &alignErr) || alignErr.Granularity != testCase.ts.granularity {
+					t.Fatalf("Got %v, expected a TimestampAlignmentError", err)
+				}
+			default:
+				if !errors.Is(err, testCase.err) {
+					t.Fatalf("Got %v, expected %v", err, testCase.err)
+				}
+			}
+		})
+	}
+}
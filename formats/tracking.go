@@ -0,0 +1,376 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	// ErrTrackingCarrier is returned, wrapped with the carrier, for a carrier
+	// without a TrackingFormat.
+	ErrTrackingCarrier = errors.New("formats: no tracking number format for the carrier")
+
+	// ErrTrackingFormat is returned, wrapped with the number, for a number that
+	// matches none of the templates of its carrier, or when the carrier is
+	// detected, the templates of no carrier or of several.
+	ErrTrackingFormat = errors.New("formats: not a tracking number of the carrier")
+
+	// ErrTrackingChecksum is returned, wrapped with the number, for a number
+	// whose check digit is wrong.
+	ErrTrackingChecksum = errors.New("formats: tracking number fails its check")
+
+	// ErrTrackingTemplate is returned by Register, wrapped with the template, for
+	// a template with more than 10^18 encrypted numbers, a class with a character
+	// twice or a # without a Checksum or more than once, and for a format without
+	// templates, with a class for # or with kept bytes without a class.
+	ErrTrackingTemplate = errors.New("formats: invalid tracking number template")
+
+	// ErrTrackingRegistered is returned by Register, wrapped with the carrier, for
+	// a carrier that is registered already.
+	ErrTrackingRegistered = errors.New("formats: tracking number carrier already registered")
+)
+
+// A TrackingFormat describes the tracking numbers of a carrier, in templates as
+// for a DriversLicenseFormat, with a # for the check digit, such as
+// "1ZXXXXXXSS9999999#". The characters of the template bytes in Kept, such as
+// those of a service code, are kept, while the others of their classes are
+// encrypted.
+//
+// When the carrier of a number is detected, it is the one with a template the
+// number matches, so the templates of different carriers should not match the
+// same numbers.
+type TrackingFormat struct {
+	Templates []string
+	Classes   map[byte]string
+	Kept      string
+	Checksum  func(number string) byte
+}
+
+// A TrackingRegistry maps carriers to the formats of their tracking numbers. It
+// is safe for concurrent use, and its zero value is an empty TrackingRegistry
+// ready to use.
+type TrackingRegistry struct {
+	mu      sync.RWMutex
+	formats map[string]TrackingFormat
+}
+
+// NewTrackingRegistry returns a TrackingRegistry with the tracking numbers of
+// these carriers, to which more may be added:
+//
+//	UPS   1Z, a shipper number of six digits and letters, a service code of two
+//	      digits, seven digits and a check digit
+//	USPS  22 digits starting with 9, of which the first five are kept, or 20
+//	      digits of which the first two are kept, each ending in a check digit
+//
+// The check digits are those of the carriers, weighted by 1 and 2 for UPS, with
+// letters counting as their position in the alphabet plus one, and by 3 and 1
+// for USPS.
+func NewTrackingRegistry() *TrackingRegistry {
+	return &TrackingRegistry{formats: map[string]TrackingFormat{
+		"UPS": {
+			Templates: []string{"1ZXXXXXXSS9999999#"},
+			Classes:   map[byte]string{'X': "0123456789" + upperLetters, 'S': "0123456789"},
+			Kept:      "S",
+			Checksum:  upsCheckDigit,
+		},
+		"USPS": {
+			Templates: []string{"NSSSS" + strings.Repeat("9", 16) + "#", "SS" + strings.Repeat("9", 17) + "#"},
+			Classes:   map[byte]string{'N': "9", 'S': "0123456789"},
+			Kept:      "S",
+			Checksum:  uspsCheckDigit,
+		},
+	}}
+}
+
+// Register adds the tracking numbers of carrier. It fails if the carrier is
+// registered already or a template of format is invalid. The registry keeps a
+// copy of format.
+func (r *TrackingRegistry) Register(carrier string, format TrackingFormat) error {
+	if len(format.Templates) == 0 {
+		return fmt.Errorf("%w: no templates for %q", ErrTrackingTemplate, carrier)
+	}
+	if _, ok := format.Classes[checkMark]; ok {
+		return fmt.Errorf("%w: a class for %q", ErrTrackingTemplate, checkMark)
+	}
+	classes := make(map[byte]string, len(format.Classes))
+	for b, class := range format.Classes {
+		classes[b] = class
+	}
+	// Kept characters are not encrypted, so they do not count for the size
+	encrypted := make(map[byte]string, len(classes))
+	for b, class := range classes {
+		encrypted[b] = class
+	}
+	for i := 0; i < len(format.Kept); i++ {
+		if templateClass(classes, format.Kept[i]) == "" {
+			return fmt.Errorf("%w: no class for kept %q", ErrTrackingTemplate, format.Kept[i])
+		}
+		encrypted[format.Kept[i]] = ""
+	}
+	for _, template := range format.Templates {
+		marks := strings.Count(template, string(checkMark))
+		if !validTemplate(encrypted, template) || marks > 1 || marks == 1 && format.Checksum == nil {
+			return fmt.Errorf("%w: %q", ErrTrackingTemplate, template)
+		}
+	}
+	format.Templates = append([]string{}, format.Templates...)
+	format.Classes = classes
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.formats[carrier]; ok {
+		return fmt.Errorf("%w: %q", ErrTrackingRegistered, carrier)
+	}
+	if r.formats == nil {
+		r.formats = make(map[string]TrackingFormat)
+	}
+	r.formats[carrier] = format
+	return nil
+}
+
+// Carriers returns the registered carriers, sorted.
+func (r *TrackingRegistry) Carriers() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.formats))
+	for name := range r.formats {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (r *TrackingRegistry) lookup(carrier string) (TrackingFormat, bool) {
+	r.mu.RLock()
+	format, ok := r.formats[carrier]
+	r.mu.RUnlock()
+	return format, ok
+}
+
+// detect returns the carriers with a template number matches, sorted, and
+// whether a template of any carrier has the length of number.
+func (r *TrackingRegistry) detect(number string) ([]string, bool) {
+	var carriers []string
+	sameLength := false
+	for _, carrier := range r.Carriers() {
+		format, ok := r.lookup(carrier)
+		if !ok {
+			continue
+		}
+		for _, template := range format.Templates {
+			if len(template) != len(number) {
+				continue
+			}
+			sameLength = true
+			if _, _, ok := matchTracking(format, template, number); ok {
+				carriers = append(carriers, carrier)
+				break
+			}
+		}
+	}
+	return carriers, sameLength
+}
+
+// matchTracking returns the classes of the encrypted positions of number if it
+// matches template of format, and the position of the check digit or -1.
+func matchTracking(format TrackingFormat, template, number string) ([]string, int, bool) {
+	if len(number) != len(template) {
+		return nil, 0, false
+	}
+	// The check digit stands for itself while the rest is encrypted
+	at := strings.IndexByte(template, checkMark)
+	if at >= 0 {
+		number = number[:at] + string(checkMark) + number[at+1:]
+	}
+	matched, ok := matchTemplate(format.Classes, template, number)
+	if !ok {
+		return nil, 0, false
+	}
+	for i := range matched {
+		if strings.IndexByte(format.Kept, template[i]) >= 0 {
+			matched[i] = ""
+		}
+	}
+	return matched, at, true
+}
+
+// TrackingOption is an option for the Tracking function.
+type TrackingOption func(*TrackingCipher)
+
+// WithGenericTracking makes the TrackingCipher encrypt the numbers of carriers
+// without a TrackingFormat too, and when the carrier is detected, numbers of a
+// length no template has. Their digits are encrypted as one message of the
+// cipher, so such numbers must consist of at least MinLen digits and nothing
+// else, and have no check digit.
+func WithGenericTracking() TrackingOption {
+	return func(c *TrackingCipher) {
+		c.generic = true
+	}
+}
+
+// A TrackingCipher encrypts shipment tracking numbers to numbers of the same
+// carrier and layout. The prefix of the carrier and its service codes are kept,
+// the rest of the number is encrypted, and the check digit is computed for the
+// ciphertext, so ciphertexts pass as numbers of their carrier.
+type TrackingCipher struct {
+	cipher   *ff1.Cipher
+	registry *TrackingRegistry
+	generic  bool
+}
+
+// Tracking returns a TrackingCipher encrypting the tracking numbers of the
+// carriers of registry, or of NewTrackingRegistry if it is nil, with cipher,
+// which must have the alphabet 0123456789. Carriers registered later are
+// encrypted too.
+func Tracking(cipher *ff1.Cipher, registry *TrackingRegistry, opts ...TrackingOption) *TrackingCipher {
+	if registry == nil {
+		registry = NewTrackingRegistry()
+	}
+	c := &TrackingCipher{cipher: cipher, registry: registry}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Detect returns the carrier of number, the only one with a template number
+// matches. The check digit is not verified. Ciphertexts are detected as the
+// carrier of their plaintext.
+func (c *TrackingCipher) Detect(number string) (string, error) {
+	carriers, _ := c.registry.detect(number)
+	switch len(carriers) {
+	case 0:
+		return "", fmt.Errorf("%w: %q matches no carrier", ErrTrackingFormat, number)
+	case 1:
+		return carriers[0], nil
+	}
+	return "", fmt.Errorf("%w: %q matches %s", ErrTrackingFormat, number, strings.Join(carriers, " and "))
+}
+
+// Encrypt encrypts number, a tracking number of carrier, or of the carrier
+// Detect returns for it if carrier is "".
+func (c *TrackingCipher) Encrypt(carrier, number string) (string, error) {
+	return c.crypt(carrier, number, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *TrackingCipher) Decrypt(carrier, number string) (string, error) {
+	return c.crypt(carrier, number, false)
+}
+
+func (c *TrackingCipher) crypt(carrier, number string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), digits) {
+		return "", ErrAlphabet
+	}
+	if carrier == "" {
+		carriers, sameLength := c.registry.detect(number)
+		if len(carriers) == 0 && !sameLength && c.generic {
+			return c.cryptGeneric(number, encrypt)
+		}
+		detected, err := c.Detect(number)
+		if err != nil {
+			return "", err
+		}
+		carrier = detected
+	}
+	format, ok := c.registry.lookup(carrier)
+	switch {
+	case ok:
+	case c.generic:
+		return c.cryptGeneric(number, encrypt)
+	default:
+		return "", fmt.Errorf("%w: %q", ErrTrackingCarrier, carrier)
+	}
+
+	for _, template := range format.Templates {
+		matched, at, ok := matchTracking(format, template, number)
+		if !ok {
+			continue
+		}
+		value := number
+		if at >= 0 {
+			value = number[:at] + string(checkMark) + number[at+1:]
+			if format.Checksum(value) != number[at] {
+				return "", fmt.Errorf("%w: %q", ErrTrackingChecksum, number)
+			}
+		}
+		out, err := cryptTemplate(c.cipher, matched, value, encrypt)
+		if err != nil || at < 0 {
+			return out, err
+		}
+		return out[:at] + string(format.Checksum(out)) + out[at+1:], nil
+	}
+	return "", fmt.Errorf("%w: %q for %s", ErrTrackingFormat, number, carrier)
+}
+
+// cryptGeneric encrypts or decrypts number, which must consist of digits.
+func (c *TrackingCipher) cryptGeneric(number string, encrypt bool) (string, error) {
+	if !allDigits(number) || len(number) < c.cipher.MinLen() {
+		return "", fmt.Errorf("%w: %q", ErrTrackingFormat, number)
+	}
+	X := []byte(number)
+	var err error
+	if encrypt {
+		_, err = c.cipher.EncryptInto(X, X)
+	} else {
+		_, err = c.cipher.DecryptInto(X, X)
+	}
+	if err != nil {
+		return "", fmt.Errorf("formats: %w", err)
+	}
+	return string(X), nil
+}
+
+// upsCheckDigit returns the check digit of a UPS tracking number, computed over
+// the characters after the 1Z. Digits count as themselves and letters as their
+// position in the alphabet plus one, modulo 10, and every second character
+// counts twice.
+func upsCheckDigit(number string) byte {
+	sum := 0
+	for i := 2; i < 17; i++ {
+		v := int(number[i] - '0')
+		if isUpper(number[i]) {
+			v = int(number[i]-'A'+2) % 10
+		}
+		if i%2 == 1 {
+			v *= 2
+		}
+		sum += v
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// uspsCheckDigit returns the check digit of a USPS tracking number, the last
+// one. The other digits are weighted by 3 and 1 from the right.
+func uspsCheckDigit(number string) byte {
+	sum := 0
+	for i, weight := len(number)-2, 3; i >= 0; i, weight = i-1, 4-weight {
+		sum += int(number[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,259 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// validTracking checks the check digit of a UPS or USPS number independently of
+// the code under test
+func validTracking(carrier, number string) bool {
+	sum := 0
+	switch carrier {
+	case "UPS":
+		// Letters count as (byte - 63) mod 10, every second character twice
+		for i, ch := range number[2:17] {
+			v := int(ch - '0')
+			if ch >= 'A' {
+				v = int(ch-63) % 10
+			}
+			sum += v * (1 + i%2)
+		}
+	case "USPS":
+		for i := 0; i < len(number)-1; i++ {
+			v, _ := strconv.Atoi(number[i : i+1])
+			if (len(number)-i)%2 == 0 {
+				v *= 3
+			}
+			sum += v
+		}
+	}
+	return strconv.Itoa((10-sum%10)%10) == number[len(number)-1:]
+}
+
+func TestTracking(t *testing.T) {
+	tr := Tracking(newFormatCipher(t, "0123456789"), nil)
+	for idx, testCase := range []struct {
+		carrier    string
+		number     string
+		ciphertext string
+	}{
+		// The examples of the carriers
+		{"UPS", "1Z999AA10123456784", "1ZK67VKX0135788087"},
+		{"UPS", "1Z12345E6605272234", "1ZRO6QQ96687939785"},
+		{"USPS", "9405511899223197428490", "9405539778842220786097"},
+		{"USPS", "9400109699938860246573", "9400175993515902734762"},
+		{"USPS", "03071790000523483741", "03211631660731324427"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if !validTracking(testCase.carrier, testCase.number) {
+				t.Fatalf("%q is not a valid test case", testCase.number)
+			}
+			for _, carrier := range []string{testCase.carrier, ""} {
+				ciphertext, err := tr.Encrypt(carrier, testCase.number)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if ciphertext != testCase.ciphertext {
+					t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+				}
+				plaintext, err := tr.Decrypt(carrier, ciphertext)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if plaintext != testCase.number {
+					t.Fatalf("Got %q, expected %q", plaintext, testCase.number)
+				}
+			}
+			if detected, err := tr.Detect(testCase.ciphertext); err != nil || detected != testCase.carrier {
+				t.Fatalf("Got %q, expected %q", detected, testCase.carrier)
+			}
+		})
+	}
+}
+
+// Chains of encryptions keep the prefixes and have valid check digits
+func TestTrackingChains(t *testing.T) {
+	tr := Tracking(newFormatCipher(t, "0123456789"), nil)
+	for idx, testCase := range []struct {
+		carrier string
+		number  string
+		kept    int
+	}{
+		{"UPS", "1Z999AA10123456784", 2},
+		{"USPS", "9405511899223197428490", 5},
+		{"USPS", "03071790000523483741", 2},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			number := testCase.number
+			seen := map[string]bool{number: true}
+			for i := 0; i < 200; i++ {
+				ciphertext, err := tr.Encrypt(testCase.carrier, number)
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if !validTracking(testCase.carrier, ciphertext) || ciphertext[:testCase.kept] != testCase.number[:testCase.kept] {
+					t.Fatalf("Got %q for %q", ciphertext, number)
+				}
+				// The service code of UPS
+				if testCase.carrier == "UPS" && ciphertext[8:10] != testCase.number[8:10] {
+					t.Fatalf("Got %q for %q", ciphertext, number)
+				}
+				if plaintext, _ := tr.Decrypt(testCase.carrier, ciphertext); plaintext != number {
+					t.Fatalf("Got %q, expected %q", plaintext, number)
+				}
+				seen[ciphertext] = true
+				number = ciphertext
+			}
+			if len(seen) < 150 {
+				t.Fatalf("Got %d distinct numbers", len(seen))
+			}
+		})
+	}
+}
+
+func TestTrackingGeneric(t *testing.T) {
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		carrier    string
+		number     string
+		ciphertext string
+	}{
+		{"DHL", "1234567890", "0001656641"},
+		{"", "123456789012345", "028110103366195"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := Tracking(c, nil).Encrypt(testCase.carrier, testCase.number); err == nil {
+				t.Fatalf("Encrypted %q without WithGenericTracking", testCase.number)
+			}
+			tr := Tracking(c, nil, WithGenericTracking())
+			ciphertext, err := tr.Encrypt(testCase.carrier, testCase.number)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := tr.Decrypt(testCase.carrier, ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.number {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.number)
+			}
+		})
+	}
+}
+
+func TestTrackingRegister(t *testing.T) {
+	var registry TrackingRegistry
+	// A carrier whose numbers are a branch of two letters that is kept and
+	// eight digits checked with the test routine of driver's licenses
+	format := TrackingFormat{
+		Templates: []string{"BB-99999999#"},
+		Classes:   map[byte]string{'B': upperLetters},
+		Kept:      "B",
+		Checksum:  testCheckDigit,
+	}
+	if err := registry.Register("TEST", format); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := registry.Register("TEST", format); !errors.Is(err, ErrTrackingRegistered) {
+		t.Fatalf("Got %v, expected %v", err, ErrTrackingRegistered)
+	}
+	if carriers := registry.Carriers(); !reflect.DeepEqual(carriers, []string{"TEST"}) {
+		t.Fatalf("Got %q", carriers)
+	}
+
+	tr := Tracking(newFormatCipher(t, "0123456789"), &registry)
+	number := "ZH-12345678#"
+	number = number[:11] + string(testCheckDigit(number))
+	ciphertext, err := tr.Encrypt("", number)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	check := ciphertext[:11] + "#"
+	if ciphertext[:3] != "ZH-" || ciphertext[11] != testCheckDigit(check) || ciphertext == number {
+		t.Fatalf("Got %q for %q", ciphertext, number)
+	}
+	if plaintext, _ := tr.Decrypt("TEST", ciphertext); plaintext != number {
+		t.Fatalf("Got %q, expected %q", plaintext, number)
+	}
+
+	for idx, format := range []TrackingFormat{
+		{},
+		{Templates: []string{"99#"}},
+		{Templates: []string{"9#9#"}, Checksum: testCheckDigit},
+		{Templates: []string{"99"}, Classes: map[byte]string{checkMark: "0123456789"}},
+		{Templates: []string{"XX"}, Classes: map[byte]string{'X': "AA"}},
+		{Templates: []string{"99"}, Kept: "K"},
+		{Templates: []string{"9999999999999999999"}},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if err := registry.Register(fmt.Sprint("INVALID", idx), format); !errors.Is(err, ErrTrackingTemplate) {
+				t.Fatalf("Got %v, expected %v", err, ErrTrackingTemplate)
+			}
+		})
+	}
+	// Kept characters do not count for the size
+	if err := registry.Register("KEPT", TrackingFormat{Templates: []string{"KK99999999999999999"}, Classes: map[byte]string{'K': "0123456789"}, Kept: "K"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestTrackingErrors(t *testing.T) {
+	var registry TrackingRegistry
+	for _, carrier := range []string{"A", "B"} {
+		if err := registry.Register(carrier, TrackingFormat{Templates: []string{"999999"}}); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	c := newFormatCipher(t, "0123456789")
+	for idx, testCase := range []struct {
+		tr      *TrackingCipher
+		carrier string
+		number  string
+		err     error
+	}{
+		{Tracking(c, nil), "DHL", "1234567890", ErrTrackingCarrier},
+		{Tracking(c, nil), "UPS", "1Z999AA10123456785", ErrTrackingChecksum},
+		{Tracking(c, nil), "USPS", "9405511899223197428491", ErrTrackingChecksum},
+		{Tracking(c, nil), "UPS", "1Z999aa10123456784", ErrTrackingFormat},
+		{Tracking(c, nil), "UPS", "9405511899223197428490", ErrTrackingFormat},
+		{Tracking(c, nil), "USPS", "8405511899223197428490", ErrTrackingFormat},
+		{Tracking(c, nil), "", "1X999AA10123456784", ErrTrackingFormat},
+		{Tracking(c, nil, WithGenericTracking()), "", "8405511899223197428490", ErrTrackingFormat},
+		{Tracking(c, nil, WithGenericTracking()), "", "12345-6789", ErrTrackingFormat},
+		{Tracking(c, nil, WithGenericTracking()), "DHL", "1", ErrTrackingFormat},
+		{Tracking(c, &registry), "", "123456", ErrTrackingFormat},
+		{Tracking(newFormatCipher(t, "0123456789ABCDEF"), nil), "UPS", "1Z999AA10123456784", ErrAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := testCase.tr.Encrypt(testCase.carrier, testCase.number); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
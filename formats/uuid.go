@@ -0,0 +1,156 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// uuidLen is the length of the canonical form of a UUID,
+// xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx.
+const uuidLen = 36
+
+var (
+	// ErrUUIDFormat is returned, in a UUIDFormatError, for a value that is not a
+	// UUID in canonical form.
+	ErrUUIDFormat = errors.New("formats: not a UUID")
+
+	// ErrBitAlphabet is returned if the cipher does not have an alphabet of two
+	// bytes, as the ciphers of ff1.NewCipher with radix 2 have.
+	ErrBitAlphabet = errors.New("formats: cipher alphabet must have two bytes")
+)
+
+// A UUIDFormatError is returned for a value that is not a UUID in canonical
+// form. Offset is the position of the first unexpected byte, or the length of
+// the value if it has the wrong length.
+type UUIDFormatError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *UUIDFormatError) Error() string {
+	return fmt.Sprintf("%v: %s at position %d", ErrUUIDFormat, e.Msg, e.Offset)
+}
+
+func (e *UUIDFormatError) Unwrap() error {
+	return ErrUUIDFormat
+}
+
+// A UUIDCipher encrypts UUIDs to other UUIDs of the same version and variant.
+//
+// The 4 version bits and the 2 variant bits are kept, the other 122 bits are
+// encrypted as a message of 122 binary numerals. So a version 4 UUID comes out
+// as a version 4 UUID, in lower case.
+type UUIDCipher struct {
+	cipher *ff1.Cipher
+}
+
+// UUID returns a UUIDCipher encrypting UUIDs with cipher, whose alphabet must
+// have two bytes.
+func UUID(cipher *ff1.Cipher) *UUIDCipher {
+	return &UUIDCipher{cipher: cipher}
+}
+
+// Encrypt encrypts uuid, given in canonical form in either case.
+func (c *UUIDCipher) Encrypt(uuid string) (string, error) {
+	return c.crypt(uuid, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *UUIDCipher) Decrypt(uuid string) (string, error) {
+	return c.crypt(uuid, false)
+}
+
+// fixedUUIDBit reports whether bit i, counted from the most significant bit of
+// the first byte, is a version or variant bit.
+func fixedUUIDBit(i int) bool {
+	return i >= 48 && i < 52 || i == 64 || i == 65
+}
+
+func (c *UUIDCipher) crypt(uuid string, encrypt bool) (string, error) {
+	alphabet := c.cipher.Alphabet()
+	if len(alphabet) != 2 {
+		return "", ErrBitAlphabet
+	}
+	b, err := parseUUID(uuid)
+	if err != nil {
+		return "", err
+	}
+
+	X := make([]byte, 0, 122)
+	for i := 0; i < 128; i++ {
+		if !fixedUUIDBit(i) {
+			X = append(X, alphabet[b[i/8]>>(7-i%8)&1])
+		}
+	}
+	if encrypt {
+		_, err = c.cipher.EncryptInto(X, X)
+	} else {
+		_, err = c.cipher.DecryptInto(X, X)
+	}
+	if err != nil {
+		return "", fmt.Errorf("formats: %w", err)
+	}
+	j := 0
+	for i := 0; i < 128; i++ {
+		if fixedUUIDBit(i) {
+			continue
+		}
+		mask := byte(1) << (7 - i%8)
+		b[i/8] &^= mask
+		if X[j] == alphabet[1] {
+			b[i/8] |= mask
+		}
+		j++
+	}
+
+	s := hex.EncodeToString(b[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:], nil
+}
+
+// parseUUID returns the 16 bytes of uuid.
+func parseUUID(uuid string) ([16]byte, error) {
+	var b [16]byte
+	if len(uuid) != uuidLen {
+		return b, &UUIDFormatError{Offset: len(uuid), Msg: fmt.Sprintf("length %d instead of %d", len(uuid), uuidLen)}
+	}
+	digits := make([]byte, 0, 32)
+	for i := 0; i < uuidLen; i++ {
+		ch := uuid[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if ch != '-' {
+				return b, &UUIDFormatError{Offset: i, Msg: fmt.Sprintf("%q instead of '-'", ch)}
+			}
+			continue
+		}
+		if strings.IndexByte("0123456789abcdefABCDEF", ch) < 0 {
+			return b, &UUIDFormatError{Offset: i, Msg: fmt.Sprintf("%q is not a hex digit", ch)}
+		}
+		digits = append(digits, ch)
+	}
+	hex.Decode(b[:], digits)
+	return b, nil
+}
@@ -0,0 +1,128 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+func newBitCipher(t *testing.T) *ff1.Cipher {
+	t.Helper()
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	c, err := ff1.NewCipher(2, 8, key, []byte("uuid"))
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	return &c
+}
+
+func TestUUID(t *testing.T) {
+	u := UUID(newBitCipher(t))
+	for idx, testCase := range []struct {
+		uuid       string
+		ciphertext string
+	}{
+		{"f47ac10b-58cc-4372-a567-0e02b2c3d479", "7f2c907b-0931-4634-b196-10f5371acce5"},
+		{"F47AC10B-58CC-4372-A567-0E02B2C3D479", "7f2c907b-0931-4634-b196-10f5371acce5"},
+		{"00000000-0000-4000-8000-000000000000", "ecb224e8-c732-4748-beb7-d3f17546a55b"},
+		{"ffffffff-ffff-4fff-bfff-ffffffffffff", "1b9bff10-cdc7-442e-a109-ff363197f81d"},
+		{"017f22e2-79b0-7cc3-98c4-dc0c0c07398f", "48015731-e812-7d9f-a8a5-293708547851"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := u.Encrypt(testCase.uuid)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := u.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != strings.ToLower(testCase.uuid) {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.uuid)
+			}
+		})
+	}
+}
+
+func TestUUIDVersion4(t *testing.T) {
+	u := UUID(newBitCipher(t))
+	for i := 0; i < 200; i++ {
+		var b [16]byte
+		rand.Read(b[:])
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		s := hex.EncodeToString(b[:])
+		uuid := s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+
+		ciphertext, err := u.Encrypt(uuid)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if ciphertext == uuid {
+			t.Fatalf("%q not encrypted", uuid)
+		}
+		if ciphertext[14] != '4' || ciphertext[19] < '8' || ciphertext[19] > 'b' {
+			t.Fatalf("Got %q for %q, not a version 4 UUID", ciphertext, uuid)
+		}
+		if plaintext, _ := u.Decrypt(ciphertext); plaintext != uuid {
+			t.Fatalf("Got %q, expected %q", plaintext, uuid)
+		}
+	}
+}
+
+func TestUUIDErrors(t *testing.T) {
+	u := UUID(newBitCipher(t))
+	for idx, testCase := range []struct {
+		uuid   string
+		offset int
+	}{
+		{"", 0},
+		{"f47ac10b-58cc-4372-a567-0e02b2c3d47", 35},
+		{"{f47ac10b-58cc-4372-a567-0e02b2c3d479}", 38},
+		{"f47ac10b58cc4372a5670e02b2c3d479", 32},
+		{"f47ac10b-58cc-4372-a567_0e02b2c3d479", 23},
+		{"f47ac10b:58cc-4372-a567-0e02b2c3d479", 8},
+		{"f47ac10b-58cc-4372-a567-0e02b2c3d47g", 35},
+		{"x47ac10b-58cc-4372-a567-0e02b2c3d479", 0},
+		{"f47ac10b-58cc-43-2-a567-0e02b2c3d479", 16},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := u.Encrypt(testCase.uuid)
+			var formatErr *UUIDFormatError
+			if !errors.Is(err, ErrUUIDFormat) || !errors.As(err, &formatErr) || formatErr.Offset != testCase.offset {
+				t.Fatalf("Got %v, expected an error at position %d", err, testCase.offset)
+			}
+		})
+	}
+
+	if _, err := UUID(newBinaryCipher(t)).Encrypt("f47ac10b-58cc-4372-a567-0e02b2c3d479"); !errors.Is(err, ErrBitAlphabet) {
+		t.Fatalf("Got %v, expected ErrBitAlphabet", err)
+	}
+}
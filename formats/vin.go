@@ -0,0 +1,182 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// VINAlphabet is the alphabet of Vehicle Identification Numbers, the digits and
+// the upper case letters but I, O and Q.
+const VINAlphabet = "0123456789ABCDEFGHJKLMNPRSTUVWXYZ"
+
+// vinCheck is the position of the check digit, counted from 0.
+const vinCheck = 8
+
+// vinWeights are the weights of the positions of a VIN in its checksum.
+var vinWeights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+var (
+	// ErrVINFormat is returned for a value that is not 17 characters of
+	// VINAlphabet.
+	ErrVINFormat = errors.New("formats: not a VIN")
+
+	// ErrVINChecksum is returned for a VIN whose check digit is wrong.
+	ErrVINChecksum = errors.New("formats: VIN fails the check digit")
+
+	// ErrVINAlphabet is returned if the cipher does not have VINAlphabet.
+	ErrVINAlphabet = errors.New("formats: cipher alphabet must be VINAlphabet")
+)
+
+// A VINOption configures a VINCipher.
+type VINOption func(*VINCipher)
+
+// WithKeptWMI leaves the first three characters, the world manufacturer
+// identifier, in the clear.
+func WithKeptWMI() VINOption {
+	return func(c *VINCipher) {
+		c.keepWMI = true
+	}
+}
+
+// WithoutVINCheckDigit makes the VINCipher encrypt the ninth character like the
+// others, for VINs without a check digit, as used outside North America.
+func WithoutVINCheckDigit() VINOption {
+	return func(c *VINCipher) {
+		c.noCheck = true
+	}
+}
+
+// A VINCipher encrypts Vehicle Identification Numbers to VINs.
+//
+// The characters are encrypted over VINAlphabet, except for the ninth, the check
+// digit, which is computed for the ciphertext. That of the plaintext follows
+// from the rest of it, which is why VINs with a wrong check digit are rejected
+// and decryption can restore it.
+type VINCipher struct {
+	cipher  *ff1.Cipher
+	keepWMI bool
+	noCheck bool
+}
+
+// VIN returns a VINCipher encrypting VINs with cipher, which must have the
+// alphabet VINAlphabet.
+func VIN(cipher *ff1.Cipher, opts ...VINOption) *VINCipher {
+	c := &VINCipher{cipher: cipher}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encrypt encrypts vin.
+func (c *VINCipher) Encrypt(vin string) (string, error) {
+	return c.crypt(vin, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *VINCipher) Decrypt(vin string) (string, error) {
+	return c.crypt(vin, false)
+}
+
+func (c *VINCipher) crypt(vin string, encrypt bool) (string, error) {
+	if !bytes.Equal(c.cipher.Alphabet(), []byte(VINAlphabet)) {
+		return "", ErrVINAlphabet
+	}
+	if len(vin) != 17 {
+		return "", ErrVINFormat
+	}
+	for i := 0; i < len(vin); i++ {
+		if strings.IndexByte(VINAlphabet, vin[i]) < 0 {
+			return "", ErrVINFormat
+		}
+	}
+	V := []byte(vin)
+	if !c.noCheck && vinCheckDigit(V) != V[vinCheck] {
+		return "", ErrVINChecksum
+	}
+
+	first := 0
+	if c.keepWMI {
+		first = 3
+	}
+	X := make([]byte, 0, 17)
+	for i := first; i < 17; i++ {
+		if i != vinCheck || c.noCheck {
+			X = append(X, V[i])
+		}
+	}
+	var err error
+	if encrypt {
+		_, err = c.cipher.EncryptInto(X, X)
+	} else {
+		_, err = c.cipher.DecryptInto(X, X)
+	}
+	if err != nil {
+		return "", fmt.Errorf("formats: %w", err)
+	}
+	for i := first; i < 17; i++ {
+		if i != vinCheck || c.noCheck {
+			V[i], X = X[0], X[1:]
+		}
+	}
+
+	if !c.noCheck {
+		V[vinCheck] = vinCheckDigit(V)
+	}
+	return string(V), nil
+}
+
+// vinCheckDigit returns the check digit of V: the sum of the values of its
+// characters times the weights of their positions, modulo 11, with X for 10.
+// Letters have the values of ISO 3779, A to H 1 to 8, J to R 1 to 9 without
+// 6 and 8, and S to Z 2 to 9.
+func vinCheckDigit(V []byte) byte {
+	sum := 0
+	for i, b := range V {
+		sum += vinWeights[i] * vinValue(b)
+	}
+	if sum%11 == 10 {
+		return 'X'
+	}
+	return byte('0' + sum%11)
+}
+
+func vinValue(b byte) int {
+	switch {
+	case b >= '0' && b <= '9':
+		return int(b - '0')
+	case b >= 'A' && b <= 'H':
+		return int(b-'A') + 1
+	case b >= 'J' && b <= 'N':
+		return int(b-'J') + 1
+	case b == 'P':
+		return 7
+	case b == 'R':
+		return 9
+	default:
+		return int(b-'S') + 2
+	}
+}
@@ -0,0 +1,118 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestVIN(t *testing.T) {
+	c := newFormatCipher(t, VINAlphabet)
+	for idx, testCase := range []struct {
+		vc         *VINCipher
+		vin        string
+		ciphertext string
+	}{
+		{VIN(c), "1M8GDM9AXKP042788", "T25ALXRZ5Y6847GTX"},
+		{VIN(c), "11111111111111111", "9U1CRLWZ254DJGRGB"},
+		{VIN(c), "1HGCM82633A004352", "E5WLDE3F2WVYZX59V"},
+		{VIN(c), "JH4KA7561PC008269", "4H1JSW8E07PK2E85V"},
+		{VIN(c, WithKeptWMI()), "1M8GDM9AXKP042788", "1M8GWBC26U9VGMPKV"},
+		{VIN(c, WithKeptWMI()), "1HGCM82633A004352", "1HGCHEFM58RM925CS"},
+		{VIN(c, WithKeptWMI()), "JH4KA7561PC008269", "JH41HTWE6MPDEK1F1"},
+		{VIN(c, WithoutVINCheckDigit()), "WVWZZZ1JZXW000001", "8K4ZEBPB5BXGD81EN"},
+		{VIN(c, WithoutVINCheckDigit()), "1M8GDM9AXKP042788", "KRB19SCB5PAN3B8BR"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphertext, err := testCase.vc.Encrypt(testCase.vin)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if ciphertext != testCase.ciphertext {
+				t.Fatalf("Got %q, expected %q", ciphertext, testCase.ciphertext)
+			}
+			plaintext, err := testCase.vc.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if plaintext != testCase.vin {
+				t.Fatalf("Got %q, expected %q", plaintext, testCase.vin)
+			}
+		})
+	}
+}
+
+func TestVINCheckDigit(t *testing.T) {
+	c := newFormatCipher(t, VINAlphabet)
+	for idx, vc := range []*VINCipher{VIN(c), VIN(c, WithKeptWMI())} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			V := []byte("1M8GDM9AXKP042788")
+			for i := 0; i < 500; i++ {
+				for j := 11; j < 17; j++ {
+					V[j] = VINAlphabet[(i*(j+7)+j)%len(VINAlphabet)]
+				}
+				V[vinCheck] = vinCheckDigit(V)
+				ciphertext, err := vc.Encrypt(string(V))
+				if err != nil {
+					t.Fatalf("%v", err)
+				}
+				if vinCheckDigit([]byte(ciphertext)) != ciphertext[vinCheck] {
+					t.Fatalf("Got %q for %q, failing the check digit", ciphertext, V)
+				}
+				if vc.keepWMI && ciphertext[:3] != string(V[:3]) {
+					t.Fatalf("WMI of %q changed in %q", V, ciphertext)
+				}
+				if plaintext, _ := vc.Decrypt(ciphertext); plaintext != string(V) {
+					t.Fatalf("Got %q, expected %q", plaintext, V)
+				}
+			}
+		})
+	}
+}
+
+func TestVINErrors(t *testing.T) {
+	c := newFormatCipher(t, VINAlphabet)
+	for idx, testCase := range []struct {
+		vc  *VINCipher
+		vin string
+		err error
+	}{
+		{VIN(c), "", ErrVINFormat},
+		{VIN(c), "1M8GDM9AXKP04278", ErrVINFormat},
+		{VIN(c), "1M8GDM9AXKP0427888", ErrVINFormat},
+		{VIN(c), "1M8GDM9AXKP04278O", ErrVINFormat},
+		{VIN(c), "IM8GDM9AXKP042788", ErrVINFormat},
+		{VIN(c), "1M8GDM9AXKQ042788", ErrVINFormat},
+		{VIN(c), "1m8gdm9axkp042788", ErrVINFormat},
+		{VIN(c), "1M8GDM9A1KP042788", ErrVINChecksum},
+		{VIN(c), "5YJSA1E14HF000001", ErrVINChecksum},
+		{VIN(c), "WVWZZZ1JZXW000001", ErrVINChecksum},
+		{VIN(c, WithoutVINCheckDigit()), "WVWZZZ1JZXW00000I", ErrVINFormat},
+		{VIN(newFormatCipher(t, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")), "1M8GDM9AXKP042788", ErrVINAlphabet},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := testCase.vc.Encrypt(testCase.vin); !errors.Is(err, testCase.err) {
+				t.Fatalf("Got %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
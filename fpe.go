@@ -0,0 +1,28 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+// Cipher is implemented by the format-preserving ciphers of the sub-packages,
+// such as ff1.Cipher and ff1.Cascade. The ciphertext has the length and the
+// alphabet of the plaintext.
+type Cipher interface {
+	Encrypt(X []byte) ([]byte, error)
+	Decrypt(X []byte) ([]byte, error)
+}
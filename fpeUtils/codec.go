@@ -22,6 +22,7 @@ See the License for the specific language governing permissions and limitations
 package fpeUtils
 
 import (
+	"crypto/subtle"
 	"fmt"
 )
 
@@ -30,10 +31,21 @@ import (
 // Element 'btu' (byte-to-uint8) supports the mapping from bytes to ordinal values.
 // Element 'utb' (uint8-to-byte) supports the mapping from ordinal values to bytes.
 // Element 'found' tracks which bytes are in the alphabet.
+// Element 'member' holds the same as a bitmap that fits in one cache line, for
+// checking membership without secret-dependent memory accesses.
+// Element 'identity' is set for the alphabet of all 256 bytes in ascending order,
+// whose ordinal values are the bytes themselves, so no translation is needed.
+//
+// Checking input takes the same time wherever an invalid byte appears. The
+// ordinal lookups in Encode and Decode still index tables with the data itself,
+// they are branch-free but not hardened against cache-timing observers.
 type Codec struct {
-	btu   [256]uint8 // maps each byte value to its position in alphabet
-	utb   []byte     // maps ordinal position to byte value
-	found [256]bool  // tracks which bytes are in the alphabet
+	btu    [256]uint8 // maps each byte value to its position in alphabet
+	utb    []byte     // maps ordinal position to byte value
+	found  [256]bool  // tracks which bytes are in the alphabet
+	member [4]uint64  // bit b is set if byte b is in the alphabet
+
+	identity bool // every byte is its own ordinal value
 }
 
 // NewCodec builds a Codec from the set of unique bytes in the alphabet.
@@ -54,10 +66,16 @@ func NewCodec(alphabet []byte) (Codec, error) {
 			ret.utb = append(ret.utb, b)
 			ret.btu[b] = pos
 			ret.found[b] = true
+			ret.member[b>>6] |= 1 << (b & 63)
 			pos++
 		}
 	}
 
+	ret.identity = len(ret.utb) == 256
+	for i, b := range ret.utb {
+		ret.identity = ret.identity && int(b) == i
+	}
+
 	return ret, nil
 }
 
@@ -66,6 +84,12 @@ func (a *Codec) Radix() int {
 	return len(a.utb)
 }
 
+// Alphabet returns the unique bytes of the alphabet, in the order of their
+// ordinal values. The returned slice is a copy.
+func (a *Codec) Alphabet() []byte {
+	return append([]byte{}, a.utb...)
+}
+
 // Encode the supplied byte slice as an array of ordinal values giving the
 // position of each byte in the alphabet.
 // It is an error for the supplied byte slice to contain bytes that are not
@@ -77,23 +101,85 @@ func (a *Codec) Encode(data []byte) ([]uint8, error) {
 		// ensure the numeral array has even-sized capacity for FF3
 		c++
 	}
-	ret := make([]uint8, n, c)
+	return a.EncodeInto(make([]uint8, 0, c), data)
+}
 
+// EncodeInto is like Encode but writes the ordinal values into dst, which is
+// reused if it has enough capacity and grown otherwise. The returned slice
+// has the length of data.
+func (a *Codec) EncodeInto(dst []uint8, data []byte) ([]uint8, error) {
+	ret := dst[:0]
+	if cap(ret) < len(data) {
+		ret = make([]uint8, 0, len(data))
+	}
+	ret = ret[:len(data)]
+	if a.identity {
+		copy(ret, data)
+		return ret, nil
+	}
+
+	// All of data is examined, so the time taken does not depend on where
+	// the first invalid byte is
+	first, bad := 0, 0
 	for i, b := range data {
-		if !a.found[b] { // not found in alphabet
-			return ret, fmt.Errorf("byte at position %d is not in alphabet: 0x%02x", i, b)
-		}
+		miss := 1 ^ a.contains(b)
+		first = subtle.ConstantTimeSelect(miss&^bad, i, first)
+		bad |= miss
 		ret[i] = a.btu[b]
 	}
+	if bad != 0 {
+		return ret, fmt.Errorf("byte at position %d is not in alphabet: 0x%02x", first, data[first])
+	}
 	return ret, nil
 }
 
+// Valid reports whether every byte of data is in the alphabet. It examines all
+// of data and does not branch on or index memory with its contents, so its
+// running time only depends on len(data).
+func (a *Codec) Valid(data []byte) bool {
+	if a.identity {
+		return true
+	}
+	bad := 0
+	for _, b := range data {
+		bad |= 1 ^ a.contains(b)
+	}
+	return bad == 0
+}
+
+// contains returns 1 if b is in the alphabet and 0 otherwise, in constant time.
+// All four words of the bitmap are read and the one holding b is picked with
+// masks, whichever it is.
+func (a *Codec) contains(b byte) int {
+	lo := -uint64(b >> 6 & 1)
+	hi := -uint64(b >> 7)
+	w01 := a.member[0] ^ (a.member[0]^a.member[1])&lo
+	w23 := a.member[2] ^ (a.member[2]^a.member[3])&lo
+	word := w01 ^ (w01^w23)&hi
+	return int(word>>(b&63)) & 1
+}
+
 // Decode constructs a byte slice from an array of ordinal values where each
 // value specifies the position of the byte in the alphabet.
 // It is an error for the array to contain values outside the boundary of the
 // alphabet.
 func (a *Codec) Decode(n []uint8) ([]byte, error) {
-	ret := make([]byte, len(n))
+	return a.DecodeInto(make([]byte, len(n)), n)
+}
+
+// DecodeInto is like Decode but writes the bytes into dst, which is reused if it
+// has enough capacity and grown otherwise. The returned slice has the length of n.
+// dst may share memory with n only if the two start at the same address.
+func (a *Codec) DecodeInto(dst []byte, n []uint8) ([]byte, error) {
+	ret := dst[:0]
+	if cap(ret) < len(n) {
+		ret = make([]byte, 0, len(n))
+	}
+	ret = ret[:len(n)]
+	if a.identity {
+		copy(ret, n)
+		return ret, nil
+	}
 
 	for i, v := range n {
 		if int(v) > len(a.utb)-1 {
@@ -103,3 +189,28 @@ func (a *Codec) Decode(n []uint8) ([]byte, error) {
 	}
 	return ret, nil
 }
+
+// FormatNumerals renders the numeral string n as text, using alphabet[i] as the glyph
+// for digit i. The radix is the number of unique bytes in the alphabet, and it is an
+// error for n to contain a digit without a glyph.
+func FormatNumerals(n []uint8, alphabet []byte) (string, error) {
+	c, err := NewCodec(alphabet)
+	if err != nil {
+		return "", err
+	}
+	b, err := c.Decode(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ParseNumerals is the inverse of FormatNumerals. Every character of s must appear
+// in the alphabet.
+func ParseNumerals(s string, alphabet []byte) ([]uint8, error) {
+	c, err := NewCodec(alphabet)
+	if err != nil {
+		return nil, err
+	}
+	return c.Encode([]byte(s))
+}
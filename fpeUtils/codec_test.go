@@ -90,6 +90,87 @@ func TestCodec(t *testing.T) {
 	}
 }
 
+func TestAlphabet(t *testing.T) {
+	c, err := NewCodec([]byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("helo wrd")
+	got := c.Alphabet()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Alphabet() = %q, want %q", got, want)
+	}
+	got[0] = 'x'
+	if !reflect.DeepEqual(c.Alphabet(), want) {
+		t.Fatal("changing the returned slice changed the codec")
+	}
+}
+
+func TestEncodeInto(t *testing.T) {
+	for idx, spec := range testCodec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			al, err := NewCodec(spec.alphabet)
+			if err != nil {
+				t.Fatalf("Error making codec: %s", err)
+			}
+
+			// Too small: a new slice is allocated
+			small := make([]uint8, 1)
+			es, err := al.EncodeInto(small, spec.input)
+			if err != nil {
+				t.Fatalf("Unable to encode: %s", err)
+			}
+			if !reflect.DeepEqual(spec.output, es) {
+				t.Fatalf("EncodeInto output incorrect: %v", es)
+			}
+
+			// Large enough: dst is reused
+			large := make([]uint8, 3, 64)
+			es, err = al.EncodeInto(large, spec.input)
+			if err != nil {
+				t.Fatalf("Unable to encode: %s", err)
+			}
+			if !reflect.DeepEqual(spec.output, es) {
+				t.Fatalf("EncodeInto output incorrect: %v", es)
+			}
+			if &es[0] != &large[0] {
+				t.Fatalf("EncodeInto did not reuse dst")
+			}
+		})
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	for idx, spec := range testCodec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			al, err := NewCodec(spec.alphabet)
+			if err != nil {
+				t.Fatalf("Error making codec: %s", err)
+			}
+
+			s, err := al.DecodeInto(nil, spec.output)
+			if err != nil {
+				t.Fatalf("Unable to decode: %s", err)
+			}
+			if !reflect.DeepEqual(s, spec.input) {
+				t.Fatalf("DecodeInto error: got %v expected %v", s, spec.input)
+			}
+
+			dst := make([]byte, 0, 64)
+			s, err = al.DecodeInto(dst, spec.output)
+			if err != nil {
+				t.Fatalf("Unable to decode: %s", err)
+			}
+			if !reflect.DeepEqual(s, spec.input) {
+				t.Fatalf("DecodeInto error: got %v expected %v", s, spec.input)
+			}
+			if &s[0] != &dst[:1][0] {
+				t.Fatalf("DecodeInto did not reuse dst")
+			}
+		})
+	}
+}
+
 func TestEncoder(t *testing.T) {
 	tests := []struct {
 		alphabet []byte
@@ -158,6 +239,58 @@ func TestLargeAlphabet(t *testing.T) {
 	}
 }
 
+func TestIdentityAlphabet(t *testing.T) {
+	ascending := make([]byte, 256)
+	for i := range ascending {
+		ascending[i] = byte(i)
+	}
+	descending := make([]byte, 256)
+	for i := range descending {
+		descending[i] = byte(255 - i)
+	}
+
+	for idx, testCase := range []struct {
+		alphabet []byte
+		identity bool
+	}{
+		{ascending, true},
+		{append(ascending, 0, 1, 2), true}, // duplicates are ignored
+		{descending, false},
+		{ascending[:255], false},
+		{ascending[:10], false},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			al, err := NewCodec(testCase.alphabet)
+			if err != nil {
+				t.Fatalf("Error making codec: %s", err)
+			}
+			if al.identity != testCase.identity {
+				t.Fatalf("identity is %v, expected %v", al.identity, testCase.identity)
+			}
+
+			// The fast path gives the same results as the tables
+			data := testCase.alphabet[:10]
+			nml, err := al.Encode(data)
+			if err != nil {
+				t.Fatalf("Unable to encode: %s", err)
+			}
+			for i, b := range data {
+				if nml[i] != al.btu[b] {
+					t.Fatalf("Numeral %d is %d, expected %d", i, nml[i], al.btu[b])
+				}
+			}
+			if !al.Valid(data) {
+				t.Fatalf("Valid data is not valid")
+			}
+			// Decoded in place
+			decoded, err := al.DecodeInto(nml[:0], nml)
+			if err != nil || !reflect.DeepEqual(decoded, data) {
+				t.Fatalf("Round-trip failed: got %v, %v expected %v", decoded, err, data)
+			}
+		})
+	}
+}
+
 func TestAlphabetTooLarge(t *testing.T) {
 	// Create alphabet with duplicates, should still work since duplicates are ignored
 	alphabet := make([]byte, 300) // More than 256 bytes
@@ -194,3 +327,160 @@ func TestMaxAlphabetExceeded(t *testing.T) {
 		t.Fatalf("Incorrect radix %d - expected 256", al.Radix())
 	}
 }
+
+func TestFormatParseNumerals(t *testing.T) {
+	base62 := []byte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+	tests := []struct {
+		alphabet []byte
+		numeral  []uint8
+		text     string
+	}{
+		{base62, []uint8{17, 14, 21}, "hel"},
+		{base62, []uint8{61, 36, 0, 10}, "ZA0a"},
+		{[]byte("01"), []uint8{1, 0, 1, 1}, "1011"},
+		{[]byte("ab"), []uint8{}, ""},
+	}
+
+	for idx, spec := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			s, err := FormatNumerals(spec.numeral, spec.alphabet)
+			if err != nil {
+				t.Fatalf("FormatNumerals: %s", err)
+			}
+			if s != spec.text {
+				t.Fatalf("FormatNumerals = %q, expected %q", s, spec.text)
+			}
+
+			n, err := ParseNumerals(s, spec.alphabet)
+			if err != nil {
+				t.Fatalf("ParseNumerals: %s", err)
+			}
+			if !reflect.DeepEqual(n, spec.numeral) {
+				t.Fatalf("ParseNumerals = %v, expected %v", n, spec.numeral)
+			}
+		})
+	}
+}
+
+func TestFormatParseNumeralsError(t *testing.T) {
+	if _, err := FormatNumerals([]uint8{0, 2}, []byte("01")); err == nil {
+		t.Fatalf("expected error for digit without a glyph")
+	}
+	if _, err := ParseNumerals("1021", []byte("01")); err == nil {
+		t.Fatalf("expected error for character not in alphabet")
+	}
+	if _, err := ParseNumerals("abc-", []byte("abcdefghijklmnopqrstuvwxyz")); err == nil {
+		t.Fatalf("expected error for character not in alphabet")
+	}
+}
+
+func TestValid(t *testing.T) {
+	alphabet := []byte("0123456789")
+	tests := []struct {
+		input []byte
+		valid bool
+		first int
+	}{
+		{[]byte("0123456789"), true, 0},
+		{[]byte(""), true, 0},
+		{[]byte("a123456789"), false, 0},
+		{[]byte("012345678a"), false, 9},
+		{[]byte("0123x5678y"), false, 4},
+		{[]byte{'0', 0x00, '1', 0xff}, false, 1},
+	}
+
+	al, err := NewCodec(alphabet)
+	if err != nil {
+		t.Fatalf("Error making codec: %s", err)
+	}
+	for idx, spec := range tests {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if valid := al.Valid(spec.input); valid != spec.valid {
+				t.Fatalf("Valid(%q) = %v, expected %v", spec.input, valid, spec.valid)
+			}
+
+			_, err := al.Encode(spec.input)
+			if spec.valid {
+				if err != nil {
+					t.Fatalf("Unable to encode: %s", err)
+				}
+				return
+			}
+			expected := fmt.Sprintf("byte at position %d is not in alphabet: 0x%02x", spec.first, spec.input[spec.first])
+			if err == nil || err.Error() != expected {
+				t.Fatalf("Encode(%q) error %v, expected %q", spec.input, err, expected)
+			}
+		})
+	}
+}
+
+// The bitmap agrees with the table for every byte
+func TestContains(t *testing.T) {
+	alphabets := [][]byte{
+		[]byte("0123456789"),
+		[]byte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"),
+		{0x00, 0x3f, 0x40, 0x7f, 0x80, 0xbf, 0xc0, 0xff},
+		{},
+	}
+
+	for idx, alphabet := range alphabets {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			al, err := NewCodec(alphabet)
+			if err != nil {
+				t.Fatalf("Error making codec: %s", err)
+			}
+			for b := 0; b < 256; b++ {
+				expected := 0
+				if al.found[b] {
+					expected = 1
+				}
+				if got := al.contains(byte(b)); got != expected {
+					t.Fatalf("contains(0x%02x) = %d, expected %d", b, got, expected)
+				}
+			}
+		})
+	}
+}
+
+// The cost of rejecting input must not depend on where the invalid byte is,
+// compare the ns/op of the sub-benchmarks
+func BenchmarkValid(b *testing.B) {
+	al, err := NewCodec([]byte("0123456789"))
+	if err != nil {
+		b.Fatalf("Error making codec: %s", err)
+	}
+
+	input := func(invalidAt int) []byte {
+		data := []byte("01234567890123456789012345678901")
+		if invalidAt >= 0 {
+			data[invalidAt] = 'x'
+		}
+		return data
+	}
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"Valid", input(-1)},
+		{"InvalidAt1", input(1)},
+		{"InvalidAt30", input(30)},
+	}
+
+	for _, bc := range cases {
+		b.Run("Valid/"+bc.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				al.Valid(bc.data)
+			}
+		})
+	}
+	dst := make([]uint8, 32)
+	for _, bc := range cases {
+		b.Run("EncodeInto/"+bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				al.EncodeInto(dst, bc.data)
+			}
+		})
+	}
+}
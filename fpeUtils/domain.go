@@ -0,0 +1,103 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpeUtils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// MaxDomainRadix is the largest radix accepted by the domain helpers, matching
+	// the radix bound of the FF1 specification.
+	MaxDomainRadix = 1 << 16
+
+	// MaxDomainLength is the largest numeral string length accepted by the domain helpers,
+	// matching the message length bound of the FF1 specification.
+	MaxDomainLength = math.MaxUint32
+
+	// MaxDomainBits bounds the size of the values DomainSize computes, so that a
+	// large length cannot make it allocate without limit: 2^27 bits are 16 MiB.
+	MaxDomainBits = 1 << 27
+)
+
+// DomainSize returns radix^length, the number of distinct numeral strings of the given
+// length in the given radix. It uses exact integer exponentiation so that boundaries
+// such as 10^6 are never subject to floating point rounding.
+//
+// Lengths for which radix^length could have more than MaxDomainBits bits, as
+// length times the bit length of radix-1, are rejected.
+func DomainSize(radix uint64, length int) (*big.Int, error) {
+	if radix < 2 || radix > MaxDomainRadix {
+		return nil, fmt.Errorf("radix must be between 2 and %d: %d supplied", MaxDomainRadix, radix)
+	}
+	if length < 0 || uint64(length) > MaxDomainLength {
+		return nil, fmt.Errorf("length must be between 0 and %d: %d supplied", uint64(MaxDomainLength), length)
+	}
+	if uint64(length)*uint64(bits.Len64(radix-1)) > MaxDomainBits {
+		return nil, fmt.Errorf("radix %d to the power of %d exceeds %d bits", radix, length, MaxDomainBits)
+	}
+
+	var r, e big.Int
+	r.SetUint64(radix)
+	e.SetInt64(int64(length))
+	return new(big.Int).Exp(&r, &e, nil), nil
+}
+
+// MinLengthForDomain returns the smallest length n such that radix^n >= minDomain.
+// A minDomain of 1 or less is satisfied by the empty string, so 0 is returned.
+func MinLengthForDomain(radix uint64, minDomain *big.Int) (int, error) {
+	if radix < 2 || radix > MaxDomainRadix {
+		return 0, fmt.Errorf("radix must be between 2 and %d: %d supplied", MaxDomainRadix, radix)
+	}
+	if minDomain == nil {
+		return 0, errors.New("minimum domain must not be nil")
+	}
+
+	if minDomain.Cmp(big.NewInt(1)) <= 0 {
+		return 0, nil
+	}
+
+	// With b the bit length of radix, 2^(b-1) <= radix < 2^b. As minDomain has
+	// bitLen bits, radix^n >= minDomain needs n > (bitLen-1)/b numerals, and
+	// n >= bitLen/(b-1) always suffice, which bounds the search without
+	// computing a power larger than about twice minDomain.
+	bitLen, b := minDomain.BitLen(), bits.Len64(radix)
+	lo := (bitLen-1)/b + 1
+	if uint64(lo) > MaxDomainLength {
+		return 0, fmt.Errorf("minimum domain %s requires more than %d digits in radix %d", minDomain, uint64(MaxDomainLength), radix)
+	}
+	hi := (bitLen + b - 2) / (b - 1)
+
+	var r, e, p big.Int
+	r.SetUint64(radix)
+	n := lo + sort.Search(hi-lo, func(i int) bool {
+		e.SetInt64(int64(lo + i))
+		return p.Exp(&r, &e, nil).Cmp(minDomain) >= 0
+	})
+	if uint64(n) > MaxDomainLength {
+		return 0, fmt.Errorf("minimum domain %s requires more than %d digits in radix %d", minDomain, uint64(MaxDomainLength), radix)
+	}
+	return n, nil
+}
@@ -0,0 +1,153 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpeUtils
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestDomainSize(t *testing.T) {
+	testSpec := []struct {
+		radix  uint64
+		length int
+		domain string
+	}{
+		{10, 6, "1000000"},
+		{2, 20, "1048576"},
+		{10, 0, "1"},
+		{256, 8, "18446744073709551616"},
+		{36, 12, "4738381338321616896"},
+		// 10^23 is not exactly representable as a float64
+		{10, 23, "100000000000000000000000"},
+		{65536, 3, "281474976710656"},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			d, err := DomainSize(spec.radix, spec.length)
+			if err != nil {
+				t.Fatalf("DomainSize(%d, %d): %s", spec.radix, spec.length, err)
+			}
+			if d.String() != spec.domain {
+				t.Fatalf("DomainSize(%d, %d) = %s, expected %s", spec.radix, spec.length, d, spec.domain)
+			}
+		})
+	}
+}
+
+func TestDomainSizeError(t *testing.T) {
+	testSpec := []struct {
+		radix  uint64
+		length int
+	}{
+		{0, 1},
+		{1, 1},
+		{65537, 1},
+		{10, -1},
+		{10, MaxDomainBits},
+		{256, MaxDomainBits/8 + 1},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := DomainSize(spec.radix, spec.length); err == nil {
+				t.Fatalf("expected error for radix %d length %d", spec.radix, spec.length)
+			}
+		})
+	}
+}
+
+func TestMinLengthForDomain(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000000", 10)
+	hugePlusOne := new(big.Int).Add(huge, big.NewInt(1))
+	// 2^(2^20), which takes a million multiplications to reach one radix at a time
+	vast := new(big.Int).Lsh(big.NewInt(1), 1<<20)
+	vastPlusOne := new(big.Int).Add(vast, big.NewInt(1))
+
+	testSpec := []struct {
+		radix     uint64
+		minDomain *big.Int
+		length    int
+	}{
+		{10, big.NewInt(1000000), 6},
+		{10, big.NewInt(1000001), 7},
+		{10, big.NewInt(999999), 6},
+		{2, big.NewInt(1048576), 20},
+		{2, big.NewInt(1048577), 21},
+		{10, big.NewInt(100), 2},
+		{36, big.NewInt(100), 2},
+		{256, big.NewInt(100), 1},
+		{10, big.NewInt(1), 0},
+		{10, big.NewInt(0), 0},
+		// in float64, log(10^23)/log(10) is 22.999999999999996 and 10^23+1 rounds to 10^23
+		{10, huge, 23},
+		{10, hugePlusOne, 24},
+		// Just past 2^53, the last integer float64 represents exactly
+		{2, big.NewInt(1<<53 + 1), 54},
+		{10, big.NewInt(10000000000000000), 16},
+		{10, big.NewInt(10000000000000001), 17},
+		{2, vast, 1 << 20},
+		{2, vastPlusOne, 1<<20 + 1},
+		{65536, vast, 1 << 16},
+		{65536, vastPlusOne, 1<<16 + 1},
+		{10, vast, 315653},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			n, err := MinLengthForDomain(spec.radix, spec.minDomain)
+			if err != nil {
+				t.Fatalf("MinLengthForDomain(%d, %s): %s", spec.radix, spec.minDomain, err)
+			}
+			if n != spec.length {
+				t.Fatalf("MinLengthForDomain(%d, %s) = %d, expected %d", spec.radix, spec.minDomain, n, spec.length)
+			}
+		})
+	}
+}
+
+func TestMinLengthForDomainInverse(t *testing.T) {
+	for radix := uint64(2); radix <= 256; radix++ {
+		for length := 0; length < 40; length++ {
+			d, err := DomainSize(radix, length)
+			if err != nil {
+				t.Fatalf("DomainSize: %s", err)
+			}
+			n, err := MinLengthForDomain(radix, d)
+			if err != nil {
+				t.Fatalf("MinLengthForDomain: %s", err)
+			}
+			if n != length {
+				t.Fatalf("radix %d: MinLengthForDomain(radix^%d) = %d", radix, length, n)
+			}
+		}
+	}
+}
+
+func TestMinLengthForDomainError(t *testing.T) {
+	if _, err := MinLengthForDomain(1, big.NewInt(100)); err == nil {
+		t.Fatalf("expected error for radix 1")
+	}
+	if _, err := MinLengthForDomain(10, nil); err == nil {
+		t.Fatalf("expected error for nil domain")
+	}
+}
@@ -22,24 +22,78 @@ See the License for the specific language governing permissions and limitations
 package fpeUtils
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
+	"math/bits"
 )
 
+// ErrRadixOutOfRange is returned (wrapped with the offending radix) when a radix
+// is less than 2 or larger than the digit type can represent.
+var ErrRadixOutOfRange = errors.New("radix out of range")
+
+// ErrDigitOutOfRange is returned when a numeral contains a digit that is not
+// less than the radix.
+type ErrDigitOutOfRange struct {
+	Position int    // index of the offending digit in the numeral
+	Value    uint64 // the offending digit
+	Radix    uint64 // the radix the numeral was interpreted in
+}
+
+func (e ErrDigitOutOfRange) Error() string {
+	return fmt.Sprintf("Value at %d out of range: got %d - expected 0..%d", e.Position, e.Value, e.Radix-1)
+}
+
+// CheckRadix validates that radix is usable with uint8 numerals, i.e. in [2, 256].
+// The error wraps ErrRadixOutOfRange.
+func CheckRadix(radix uint64) error {
+	return checkRadixOf[uint8](radix)
+}
+
+// checkRadixOf validates that radix is in [2, maxRadixOf[T]].
+func checkRadixOf[T Unsigned](radix uint64) error {
+	if radix < 2 || radix > maxRadixOf[T]() {
+		return fmt.Errorf("%w: %d not in [2..%d]", ErrRadixOutOfRange, radix, maxRadixOf[T]())
+	}
+	return nil
+}
+
+// Unsigned is the set of unsigned integer types that may be used as numeral
+// digits. It mirrors golang.org/x/exp/constraints.Unsigned.
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// maxRadixOf returns the largest radix whose digits can all be stored in T.
+// For 64-bit digit types every representable radix is allowed.
+func maxRadixOf[T Unsigned]() uint64 {
+	maxDigit := uint64(^T(0))
+	if maxDigit == ^uint64(0) {
+		return maxDigit
+	}
+	return maxDigit + 1
+}
+
 // Num constructs a big.Int from an array of uint8, where each element represents
 // one digit in the given radix.  The array is arranged with the most significant digit in element 0,
 // down to the least significant digit in element len-1.
 func Num(s []uint8, radix uint64) (big.Int, error) {
+	return NumOf(s, radix)
+}
+
+// NumOf is the generic implementation of Num for any unsigned digit type.
+// The radix may not exceed the number of values representable by T.
+func NumOf[T Unsigned](s []T, radix uint64) (big.Int, error) {
 	var bigRadix, bv, x big.Int
-	if radix > 256 {
-		return x, fmt.Errorf("Radix (%d) too big: max supported radix is 256", radix)
+	if err := checkRadixOf[T](radix); err != nil {
+		return x, err
 	}
 
-	maxv := uint8(radix - 1)
+	maxv := T(radix - 1)
 	bigRadix.SetUint64(uint64(radix))
 	for i, v := range s {
 		if v > maxv {
-			return x, fmt.Errorf("Value at %d out of range: got %d - expected 0..%d", i, v, maxv)
+			return x, ErrDigitOutOfRange{Position: i, Value: uint64(v), Radix: radix}
 		}
 		bv.SetUint64(uint64(v))
 		x.Mul(&x, &bigRadix)
@@ -53,15 +107,15 @@ func Num(s []uint8, radix uint64) (big.Int, error) {
 // down to the most significant digit in element len-1.
 func NumRev(s []uint8, radix uint64) (big.Int, error) {
 	var bigRadix, bv, x big.Int
-	if radix > 256 {
-		return x, fmt.Errorf("Radix (%d) too big: max supported radix is 256", radix)
+	if err := CheckRadix(radix); err != nil {
+		return x, err
 	}
 
 	maxv := uint8(radix - 1)
 	bigRadix.SetUint64(uint64(radix))
 	for i := len(s) - 1; i >= 0; i-- {
 		if s[i] > maxv {
-			return x, fmt.Errorf("Value at %d out of range: got %d - expected 0..%d", i, s[i], maxv)
+			return x, ErrDigitOutOfRange{Position: i, Value: uint64(s[i]), Radix: radix}
 		}
 		bv.SetUint64(uint64(s[i]))
 		x.Mul(&x, &bigRadix)
@@ -70,22 +124,96 @@ func NumRev(s []uint8, radix uint64) (big.Int, error) {
 	return x, nil
 }
 
+// Num64 is the uint64 counterpart of Num for values that fit in a machine word.
+// ok is false if the value overflows 64 bits, if the radix is outside [2, 256]
+// or if any digit is not less than the radix. Callers should fall back to Num
+// in that case, which also reports the reason for the failure.
+func Num64(s []uint8, radix uint64) (uint64, bool) {
+	if radix < 2 || radix > 256 {
+		return 0, false
+	}
+
+	var x uint64
+	for _, v := range s {
+		if uint64(v) >= radix {
+			return 0, false
+		}
+		hi, lo := bits.Mul64(x, radix)
+		if hi != 0 {
+			return 0, false
+		}
+		lo, carry := bits.Add64(lo, uint64(v), 0)
+		if carry != 0 {
+			return 0, false
+		}
+		x = lo
+	}
+	return x, true
+}
+
+// Str64 is the uint64 counterpart of Str. It populates r with the digits of v in
+// the specified radix, most significant digit in element 0, zero-padding on the left.
+// It is an error for r to be too short to hold all the digits of v.
+func Str64(v uint64, r []uint8, radix uint64) error {
+	if err := CheckRadix(radix); err != nil {
+		return err
+	}
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i] = uint8(v % radix)
+		v /= radix
+	}
+	if v != 0 {
+		return fmt.Errorf("destination array too small: %d remains after conversion", v)
+	}
+	return nil
+}
+
+// Fits64 reports whether every numeral string of the given length in the given radix,
+// i.e. every value below radix^length, can be represented in a uint64.
+func Fits64(radix uint64, length int) bool {
+	if radix < 2 {
+		return false
+	}
+	// The largest value is radix^length - 1, which fits iff radix^length <= 2^64.
+	// Track radix^i and stop as soon as it overflows; reaching exactly 2^64 is fine
+	// only on the final multiplication.
+	p := uint64(1)
+	for i := 0; i < length; i++ {
+		hi, lo := bits.Mul64(p, radix)
+		if hi != 0 {
+			return i == length-1 && hi == 1 && lo == 0
+		}
+		p = lo
+	}
+	return true
+}
+
 // Str populates an array of uint8 with digits representing big.Int x in the specified radix.
 // The array is arranged with the most significant digit in element 0.
-// The array is built from big.Int x from the least significant digit upwards.  If the supplied
-// array is too short, the most significant digits of x are quietly lost.
+// The array is built from big.Int x from the least significant digit upwards.
+//
+// Str always writes exactly len(r) digits: if x needs fewer digits, the leading elements
+// are set to zero, so a zero x fills the whole array with zeros. If the supplied array is
+// too short to hold every digit of x, an error is returned. Use StrMinimal to obtain
+// exactly as many digits as x needs.
 func Str(x *big.Int, r []uint8, radix uint64) ([]uint8, error) {
+	return StrOf(x, r, radix)
+}
+
+// StrOf is the generic implementation of Str for any unsigned digit type.
+// The radix may not exceed the number of values representable by T.
+func StrOf[T Unsigned](x *big.Int, r []T, radix uint64) ([]T, error) {
 
 	var bigRadix, mod, v big.Int
-	if radix > 256 {
-		return r, fmt.Errorf("Radix (%d) too big: max supported radix is 256", radix)
+	if err := checkRadixOf[T](radix); err != nil {
+		return r, err
 	}
 	m := len(r)
 	v.Set(x)
 	bigRadix.SetUint64(radix)
 	for i := range r {
 		v.DivMod(&v, &bigRadix, &mod)
-		r[m-i-1] = uint8(mod.Uint64())
+		r[m-i-1] = T(mod.Uint64())
 	}
 	if v.Sign() != 0 {
 		return r, fmt.Errorf("destination array too small: %s remains after conversion", &v)
@@ -95,13 +223,14 @@ func Str(x *big.Int, r []uint8, radix uint64) ([]uint8, error) {
 
 // StrRev populates an array of uint8 with digits representing big.Int x in the specified radix.
 // The array is arranged with the least significant digit in element 0.
-// The array is built from big.Int x from the least significant digit upwards.  If the supplied
-// array is too short, the most significant digits of x are quietly lost.
+// The array is built from big.Int x from the least significant digit upwards, and unused
+// trailing elements are set to zero. If the supplied array is too short to hold every
+// digit of x, an error is returned.
 func StrRev(x *big.Int, r []uint8, radix uint64) ([]uint8, error) {
 
 	var bigRadix, mod, v big.Int
-	if radix > 256 {
-		return r, fmt.Errorf("Radix (%d) too big: max supported radix is 256", radix)
+	if err := CheckRadix(radix); err != nil {
+		return r, err
 	}
 	v.Set(x)
 	bigRadix.SetUint64(radix)
@@ -115,18 +244,81 @@ func StrRev(x *big.Int, r []uint8, radix uint64) ([]uint8, error) {
 	return r, nil
 }
 
+// StrMinimal returns the digits of x in the specified radix, most significant digit first,
+// using exactly as many digits as needed. Zero is represented as a single 0 digit.
+// It is an error for x to be negative.
+func StrMinimal(x *big.Int, radix uint64) ([]uint8, error) {
+	if err := CheckRadix(radix); err != nil {
+		return nil, err
+	}
+	if x.Sign() < 0 {
+		return nil, fmt.Errorf("cannot represent negative value %s", x)
+	}
+
+	// Count the digits needed: the smallest n with radix^n > x
+	var p, r big.Int
+	r.SetUint64(radix)
+	p.Set(&r)
+	n := 1
+	for p.Cmp(x) <= 0 {
+		p.Mul(&p, &r)
+		n++
+	}
+	return Str(x, make([]uint8, n), radix)
+}
+
+// ConvertRadix re-expresses the value of numeral, read in fromRadix, as exactly outLen
+// digits in toRadix, zero-padded on the left. It is an error for the value not to fit
+// in outLen digits of the target radix.
+func ConvertRadix(numeral []uint8, fromRadix, toRadix uint64, outLen int) ([]uint8, error) {
+	if outLen < 0 {
+		return nil, fmt.Errorf("output length must not be negative: %d supplied", outLen)
+	}
+	if err := CheckRadix(toRadix); err != nil {
+		return nil, err
+	}
+	x, err := Num(numeral, fromRadix)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]uint8, outLen)
+	if _, err := Str(&x, r, toRadix); err != nil {
+		return nil, fmt.Errorf("value does not fit in %d digits of radix %d", outLen, toRadix)
+	}
+	return r, nil
+}
+
+// ConvertRadixMinimal re-expresses the value of numeral, read in fromRadix, using as few
+// digits of toRadix as possible. A zero value is returned as a single 0 digit.
+func ConvertRadixMinimal(numeral []uint8, fromRadix, toRadix uint64) ([]uint8, error) {
+	x, err := Num(numeral, fromRadix)
+	if err != nil {
+		return nil, err
+	}
+	return StrMinimal(&x, toRadix)
+}
+
 // DecodeNum constructs a byte slice from indices into the alphabet embedded in the Codec. The indices
 // are encoded in the big Ints a and b.
 // lenA and lenB are the number of bytes that should be built from the corresponding big Ints.
 func DecodeNum(a *big.Int, lenA int, b *big.Int, lenB int, c Codec) ([]byte, error) {
 	ret := make([]uint8, lenA+lenB)
-	_, err := Str(a, ret[:lenA], uint64(c.Radix()))
+	err := strFast(a, ret[:lenA], uint64(c.Radix()))
 	if err != nil {
 		return nil, err
 	}
-	_, err = Str(b, ret[lenA:], uint64(c.Radix()))
+	err = strFast(b, ret[lenA:], uint64(c.Radix()))
 	if err != nil {
 		return nil, err
 	}
 	return c.Decode(ret)
 }
+
+// strFast uses Str64 when x fits in a uint64 and falls back to Str otherwise.
+func strFast(x *big.Int, r []uint8, radix uint64) error {
+	if x.IsUint64() {
+		return Str64(x.Uint64(), r, radix)
+	}
+	_, err := Str(x, r, radix)
+	return err
+}
@@ -22,8 +22,11 @@ See the License for the specific language governing permissions and limitations
 package fpeUtils
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"math/rand"
 	"reflect"
 	"testing"
 )
@@ -127,3 +130,514 @@ func TestDecodeError(t *testing.T) {
 		})
 	}
 }
+
+// maxLen64 returns the largest length for which Fits64 holds, computed with big.Int.
+func maxLen64(radix uint64) int {
+	limit := new(big.Int).Lsh(big.NewInt(1), 64)
+	p := big.NewInt(1)
+	n := 0
+	for {
+		p.Mul(p, new(big.Int).SetUint64(radix))
+		if p.Cmp(limit) > 0 {
+			return n
+		}
+		n++
+	}
+}
+
+func TestFits64(t *testing.T) {
+	for radix := uint64(2); radix <= 256; radix++ {
+		max := maxLen64(radix)
+		if !Fits64(radix, max) {
+			t.Fatalf("radix %d: expected length %d to fit", radix, max)
+		}
+		if Fits64(radix, max+1) {
+			t.Fatalf("radix %d: expected length %d not to fit", radix, max+1)
+		}
+	}
+
+	// 2^64 itself is the boundary: 64 binary digits and 8 radix-256 digits fit exactly
+	if !Fits64(2, 64) || !Fits64(256, 8) || !Fits64(16, 16) {
+		t.Fatalf("exact 2^64 domains must fit")
+	}
+	if Fits64(10, 20) || !Fits64(10, 19) {
+		t.Fatalf("radix 10 boundary is 19 digits")
+	}
+}
+
+func TestNum64Equivalence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, radix := range []uint64{2, 3, 10, 16, 36, 62, 255, 256} {
+		max := maxLen64(radix)
+		for _, length := range []int{0, 1, max - 1, max, max + 1, max + 2} {
+			for iter := 0; iter < 200; iter++ {
+				s := make([]uint8, length)
+				for i := range s {
+					s[i] = uint8(rng.Int63n(int64(radix)))
+				}
+				// Always include the all-maximum numeral, the worst case for overflow
+				if iter == 0 {
+					for i := range s {
+						s[i] = uint8(radix - 1)
+					}
+				}
+
+				want, err := Num(s, radix)
+				if err != nil {
+					t.Fatalf("Num(%v, %d): %s", s, radix, err)
+				}
+
+				got, ok := Num64(s, radix)
+				if ok != want.IsUint64() {
+					t.Fatalf("radix %d length %d: Num64 ok=%v but big value is %s", radix, length, ok, &want)
+				}
+				if ok && got != want.Uint64() {
+					t.Fatalf("radix %d length %d: Num64=%d, Num=%s", radix, length, got, &want)
+				}
+				if !ok {
+					continue
+				}
+
+				r := make([]uint8, length)
+				if err := Str64(got, r, radix); err != nil {
+					t.Fatalf("Str64: %s", err)
+				}
+				if !reflect.DeepEqual(r, s) {
+					t.Fatalf("Str64 round-trip: got %v expected %v", r, s)
+				}
+
+				rb := make([]uint8, length)
+				if _, err := Str(&want, rb, radix); err != nil {
+					t.Fatalf("Str: %s", err)
+				}
+				if !reflect.DeepEqual(r, rb) {
+					t.Fatalf("Str64 %v differs from Str %v", r, rb)
+				}
+			}
+		}
+	}
+}
+
+func TestNum64Invalid(t *testing.T) {
+	if _, ok := Num64([]uint8{1, 10}, 10); ok {
+		t.Fatalf("expected digit out of range to fail")
+	}
+	if _, ok := Num64([]uint8{1}, 257); ok {
+		t.Fatalf("expected radix 257 to fail")
+	}
+	if _, ok := Num64([]uint8{0}, 1); ok {
+		t.Fatalf("expected radix 1 to fail")
+	}
+}
+
+func TestStr64Error(t *testing.T) {
+	r := make([]uint8, 2)
+	if err := Str64(100, r, 10); err == nil {
+		t.Fatalf("expected error for destination too small")
+	}
+	if err := Str64(1, r, 0); err == nil {
+		t.Fatalf("expected error for radix 0")
+	}
+	r = make([]uint8, 20)
+	if err := Str64(math.MaxUint64, r, 10); err != nil {
+		t.Fatalf("Str64(MaxUint64): %s", err)
+	}
+	if fmt.Sprint(r) != "[1 8 4 4 6 7 4 4 0 7 3 7 0 9 5 5 1 6 1 5]" {
+		t.Fatalf("Str64(MaxUint64) = %v", r)
+	}
+}
+
+func benchmarkDigits(length int) []uint8 {
+	s := make([]uint8, length)
+	for i := range s {
+		s[i] = uint8((i*7 + 3) % 10)
+	}
+	return s
+}
+
+func BenchmarkNum(b *testing.B) {
+	for length := 10; length <= 19; length++ {
+		s := benchmarkDigits(length)
+		b.Run(fmt.Sprintf("Len%d", length), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				Num(s, 10)
+			}
+		})
+	}
+}
+
+func BenchmarkNum64(b *testing.B) {
+	for length := 10; length <= 19; length++ {
+		s := benchmarkDigits(length)
+		b.Run(fmt.Sprintf("Len%d", length), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				Num64(s, 10)
+			}
+		})
+	}
+}
+
+func BenchmarkStr(b *testing.B) {
+	for length := 10; length <= 19; length++ {
+		v, _ := Num(benchmarkDigits(length), 10)
+		r := make([]uint8, length)
+		b.Run(fmt.Sprintf("Len%d", length), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				Str(&v, r, 10)
+			}
+		})
+	}
+}
+
+func BenchmarkStr64(b *testing.B) {
+	for length := 10; length <= 19; length++ {
+		v, _ := Num64(benchmarkDigits(length), 10)
+		r := make([]uint8, length)
+		b.Run(fmt.Sprintf("Len%d", length), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				Str64(v, r, 10)
+			}
+		})
+	}
+}
+
+// numStrSpec is shared by the generic tests so every digit type is held to the
+// same validation rules.
+var numStrSpec = []struct {
+	radix   uint64
+	numeral []uint64
+	value   string
+	valid   bool
+}{
+	{10, []uint64{1, 0, 0}, "100", true},
+	{10, []uint64{0, 0, 0}, "0", true},
+	{2, []uint64{1, 1, 1, 1}, "15", true},
+	{256, []uint64{1, 0, 0, 0, 0, 0, 0, 0}, "72057594037927936", true},
+	{256, []uint64{255, 255}, "65535", true},
+	{10, []uint64{10, 0, 0}, "", false},
+	{16, []uint64{0, 16}, "", false},
+}
+
+func testNumStrOf[T Unsigned](t *testing.T) {
+	for idx, spec := range numStrSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			numeral := make([]T, len(spec.numeral))
+			for i, d := range spec.numeral {
+				numeral[i] = T(d)
+			}
+
+			v, err := NumOf(numeral, spec.radix)
+			if !spec.valid {
+				if err == nil {
+					t.Fatalf("expected error in NumOf")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error in NumOf: %s", err)
+			}
+			if v.String() != spec.value {
+				t.Fatalf("expected %s got %s", spec.value, &v)
+			}
+
+			r := make([]T, len(numeral))
+			if _, err := StrOf(&v, r, spec.radix); err != nil {
+				t.Fatalf("error in StrOf: %s", err)
+			}
+			if !reflect.DeepEqual(numeral, r) {
+				t.Fatalf("StrOf numeral incorrect: %v", r)
+			}
+		})
+	}
+}
+
+func TestNumStrOf(t *testing.T) {
+	t.Run("uint8", testNumStrOf[uint8])
+	t.Run("uint16", testNumStrOf[uint16])
+	t.Run("uint32", testNumStrOf[uint32])
+	t.Run("uint64", testNumStrOf[uint64])
+}
+
+func TestNumStrOfRadixBound(t *testing.T) {
+	if _, err := NumOf([]uint8{1}, 257); err == nil {
+		t.Fatalf("expected radix 257 to be rejected for uint8")
+	}
+	if _, err := NumOf([]uint16{256}, 257); err != nil {
+		t.Fatalf("radix 257 should be accepted for uint16: %s", err)
+	}
+	if _, err := NumOf([]uint16{1}, 65537); err == nil {
+		t.Fatalf("expected radix 65537 to be rejected for uint16")
+	}
+	if _, err := StrOf(big.NewInt(1), make([]uint8, 1), 257); err == nil {
+		t.Fatalf("expected radix 257 to be rejected for uint8")
+	}
+	if _, err := StrOf(big.NewInt(300), make([]uint16, 1), 65536); err != nil {
+		t.Fatalf("radix 65536 should be accepted for uint16: %s", err)
+	}
+}
+
+func BenchmarkNumOf(b *testing.B) {
+	s8 := benchmarkDigits(16)
+	s16 := make([]uint16, len(s8))
+	for i, d := range s8 {
+		s16[i] = uint16(d)
+	}
+	b.Run("uint8", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			NumOf(s8, 10)
+		}
+	})
+	b.Run("uint16", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			NumOf(s16, 10)
+		}
+	})
+}
+
+func BenchmarkStrOf(b *testing.B) {
+	v, _ := Num(benchmarkDigits(16), 10)
+	r8 := make([]uint8, 16)
+	r16 := make([]uint16, 16)
+	b.Run("uint8", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			StrOf(&v, r8, 10)
+		}
+	})
+	b.Run("uint16", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			StrOf(&v, r16, 10)
+		}
+	})
+}
+
+func TestConvertRadix(t *testing.T) {
+	testSpec := []struct {
+		numeral   []uint8
+		fromRadix uint64
+		toRadix   uint64
+		outLen    int
+		expected  []uint8
+	}{
+		// 61*62 + 1 = 3783
+		{[]uint8{61, 1}, 62, 10, 4, []uint8{3, 7, 8, 3}},
+		// leading zeros are preserved when outLen exceeds what is needed
+		{[]uint8{61, 1}, 62, 10, 7, []uint8{0, 0, 0, 3, 7, 8, 3}},
+		{[]uint8{1, 1, 1, 1}, 2, 16, 1, []uint8{15}},
+		{[]uint8{0, 0, 0}, 10, 2, 3, []uint8{0, 0, 0}},
+		{[]uint8{255, 255}, 256, 2, 16, []uint8{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			r, err := ConvertRadix(spec.numeral, spec.fromRadix, spec.toRadix, spec.outLen)
+			if err != nil {
+				t.Fatalf("ConvertRadix: %s", err)
+			}
+			if !reflect.DeepEqual(r, spec.expected) {
+				t.Fatalf("ConvertRadix = %v, expected %v", r, spec.expected)
+			}
+
+			// Converting back must be lossless
+			back, err := ConvertRadix(r, spec.toRadix, spec.fromRadix, len(spec.numeral))
+			if err != nil {
+				t.Fatalf("ConvertRadix back: %s", err)
+			}
+			if !reflect.DeepEqual(back, spec.numeral) {
+				t.Fatalf("round-trip = %v, expected %v", back, spec.numeral)
+			}
+		})
+	}
+}
+
+func TestConvertRadixError(t *testing.T) {
+	// 3783 needs 4 decimal digits
+	if _, err := ConvertRadix([]uint8{61, 1}, 62, 10, 3); err == nil {
+		t.Fatalf("expected overflow to be rejected")
+	}
+	if _, err := ConvertRadix([]uint8{10}, 10, 2, 8); err == nil {
+		t.Fatalf("expected invalid digit to be rejected")
+	}
+	if _, err := ConvertRadix([]uint8{1}, 10, 2, -1); err == nil {
+		t.Fatalf("expected negative length to be rejected")
+	}
+	if _, err := ConvertRadixMinimal([]uint8{1}, 10, 1); err == nil {
+		t.Fatalf("expected radix 1 to be rejected")
+	}
+}
+
+func TestConvertRadixMinimal(t *testing.T) {
+	testSpec := []struct {
+		numeral   []uint8
+		fromRadix uint64
+		toRadix   uint64
+		expected  []uint8
+	}{
+		{[]uint8{61, 1}, 62, 10, []uint8{3, 7, 8, 3}},
+		{[]uint8{0, 0, 0, 1, 0}, 10, 10, []uint8{1, 0}},
+		{[]uint8{0, 0}, 10, 2, []uint8{0}},
+		{[]uint8{1, 0, 0, 0, 0, 0, 0, 0, 0}, 2, 256, []uint8{1, 0}},
+		{[]uint8{2, 5, 5}, 10, 256, []uint8{255}},
+		{[]uint8{2, 5, 6}, 10, 256, []uint8{1, 0}},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			r, err := ConvertRadixMinimal(spec.numeral, spec.fromRadix, spec.toRadix)
+			if err != nil {
+				t.Fatalf("ConvertRadixMinimal: %s", err)
+			}
+			if !reflect.DeepEqual(r, spec.expected) {
+				t.Fatalf("ConvertRadixMinimal = %v, expected %v", r, spec.expected)
+			}
+		})
+	}
+}
+
+func TestRadixBounds(t *testing.T) {
+	testSpec := []struct {
+		radix uint64
+		valid bool
+	}{
+		{0, false},
+		{1, false},
+		{2, true},
+		{256, true},
+		{257, false},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, numErr := Num([]uint8{0, 1}, spec.radix)
+			_, strErr := Str(big.NewInt(1), make([]uint8, 2), spec.radix)
+			for name, err := range map[string]error{"Num": numErr, "Str": strErr} {
+				if spec.valid && err != nil {
+					t.Fatalf("%s: unexpected error for radix %d: %s", name, spec.radix, err)
+				}
+				if !spec.valid && !errors.Is(err, ErrRadixOutOfRange) {
+					t.Fatalf("%s: expected ErrRadixOutOfRange for radix %d, got %v", name, spec.radix, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDigitBounds(t *testing.T) {
+	for _, radix := range []uint64{2, 10, 256} {
+		// Largest valid digit
+		if _, err := Num([]uint8{0, uint8(radix - 1)}, radix); err != nil {
+			t.Fatalf("radix %d: unexpected error: %s", radix, err)
+		}
+		if radix == 256 {
+			continue
+		}
+
+		_, err := Num([]uint8{0, uint8(radix)}, radix)
+		var digitErr ErrDigitOutOfRange
+		if !errors.As(err, &digitErr) {
+			t.Fatalf("radix %d: expected ErrDigitOutOfRange, got %v", radix, err)
+		}
+		if digitErr.Position != 1 || digitErr.Value != radix {
+			t.Fatalf("radix %d: unexpected error details %+v", radix, digitErr)
+		}
+	}
+
+	// For Str the boundary is a value of exactly radix^len
+	for _, radix := range []uint64{2, 10, 256} {
+		r := make([]uint8, 2)
+		max := new(big.Int).SetUint64(radix*radix - 1)
+		if _, err := Str(max, r, radix); err != nil {
+			t.Fatalf("radix %d: unexpected error: %s", radix, err)
+		}
+		if _, err := Str(max.Add(max, big.NewInt(1)), r, radix); err == nil {
+			t.Fatalf("radix %d: expected error for value radix^2", radix)
+		}
+	}
+}
+
+func TestStrZeroPadding(t *testing.T) {
+	testSpec := []struct {
+		radix    uint64
+		value    int64
+		width    int
+		expected []uint8
+	}{
+		{10, 0, 4, []uint8{0, 0, 0, 0}},
+		{10, 7, 4, []uint8{0, 0, 0, 7}},
+		{2, 5, 6, []uint8{0, 0, 0, 1, 0, 1}},
+		{256, 256, 3, []uint8{0, 1, 0}},
+		{36, 35, 2, []uint8{0, 35}},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			// Pre-fill to make sure the padding is written, not inherited
+			r := make([]uint8, spec.width)
+			for i := range r {
+				r[i] = 1
+			}
+			v := big.NewInt(spec.value)
+			if _, err := Str(v, r, spec.radix); err != nil {
+				t.Fatalf("Str: %s", err)
+			}
+			if !reflect.DeepEqual(r, spec.expected) {
+				t.Fatalf("Str = %v, expected %v", r, spec.expected)
+			}
+
+			back, err := Num(r, spec.radix)
+			if err != nil {
+				t.Fatalf("Num: %s", err)
+			}
+			if back.Cmp(v) != 0 {
+				t.Fatalf("Num(Str(v)) = %s, expected %s", &back, v)
+			}
+		})
+	}
+}
+
+func TestStrMinimal(t *testing.T) {
+	testSpec := []struct {
+		radix    uint64
+		value    int64
+		expected []uint8
+	}{
+		{10, 0, []uint8{0}},
+		{10, 9, []uint8{9}},
+		{10, 10, []uint8{1, 0}},
+		{10, 1000, []uint8{1, 0, 0, 0}},
+		{2, 1, []uint8{1}},
+		{2, 8, []uint8{1, 0, 0, 0}},
+		{256, 255, []uint8{255}},
+		{256, 65536, []uint8{1, 0, 0}},
+		{62, 3783, []uint8{61, 1}},
+	}
+
+	for idx, spec := range testSpec {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			v := big.NewInt(spec.value)
+			r, err := StrMinimal(v, spec.radix)
+			if err != nil {
+				t.Fatalf("StrMinimal: %s", err)
+			}
+			if !reflect.DeepEqual(r, spec.expected) {
+				t.Fatalf("StrMinimal = %v, expected %v", r, spec.expected)
+			}
+
+			back, err := Num(r, spec.radix)
+			if err != nil {
+				t.Fatalf("Num: %s", err)
+			}
+			if back.Cmp(v) != 0 {
+				t.Fatalf("Num(StrMinimal(v)) = %s, expected %s", &back, v)
+			}
+		})
+	}
+}
+
+func TestStrMinimalError(t *testing.T) {
+	if _, err := StrMinimal(big.NewInt(-1), 10); err == nil {
+		t.Fatalf("expected error for negative value")
+	}
+	if _, err := StrMinimal(big.NewInt(1), 1); !errors.Is(err, ErrRadixOutOfRange) {
+		t.Fatalf("expected ErrRadixOutOfRange, got %v", err)
+	}
+}
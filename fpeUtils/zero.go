@@ -0,0 +1,56 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpeUtils
+
+import (
+	"math/big"
+	"runtime"
+)
+
+// ZeroNumerals overwrites every element of n, up to its capacity, with zero.
+func ZeroNumerals(n []uint8) {
+	ZeroBytes(n)
+}
+
+// ZeroBytes overwrites every element of b, up to its capacity, with zero.
+func ZeroBytes(b []byte) {
+	b = b[:cap(b)]
+	for i := range b {
+		b[i] = 0
+	}
+	// Keep b reachable until the stores are complete so they cannot be discarded as dead
+	runtime.KeepAlive(b)
+}
+
+// ZeroBig sets x to 0 after overwriting its backing words, including any spare capacity
+// left over from earlier, larger values. Temporaries allocated internally by math/big
+// during arithmetic are not reachable from x and are not covered.
+func ZeroBig(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	words = words[:cap(words)]
+	for i := range words {
+		words[i] = 0
+	}
+	x.SetInt64(0)
+	runtime.KeepAlive(words)
+}
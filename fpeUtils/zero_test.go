@@ -0,0 +1,81 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpeUtils
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestZeroNumerals(t *testing.T) {
+	n := []uint8{1, 2, 3, 4, 5}
+	// Spare capacity beyond len must be cleared too
+	ZeroNumerals(n[:2])
+	for i, v := range n {
+		if v != 0 {
+			t.Fatalf("numeral at %d not zeroed: %d", i, v)
+		}
+	}
+}
+
+func TestZeroBytes(t *testing.T) {
+	b := []byte("sensitive")
+	ZeroBytes(b)
+	for i, v := range b {
+		if v != 0 {
+			t.Fatalf("byte at %d not zeroed: %d", i, v)
+		}
+	}
+	ZeroBytes(nil)
+}
+
+func TestZeroBig(t *testing.T) {
+	x, _ := new(big.Int).SetString("123456789012345678901234567890123456789", 10)
+	words := x.Bits()
+	words = words[:cap(words)]
+
+	ZeroBig(x)
+
+	if x.Sign() != 0 {
+		t.Fatalf("value not zero: %s", x)
+	}
+	for i, w := range words {
+		if w != 0 {
+			t.Fatalf("word %d not zeroed: %x", i, w)
+		}
+	}
+	ZeroBig(nil)
+}
+
+func TestZeroBigSpareCapacity(t *testing.T) {
+	x, _ := new(big.Int).SetString("123456789012345678901234567890123456789", 10)
+	words := x.Bits()
+	words = words[:cap(words)]
+
+	// Shrinking the value leaves the old high words in the spare capacity
+	x.SetInt64(7)
+
+	ZeroBig(x)
+	for i, w := range words {
+		if w != 0 {
+			t.Fatalf("word %d not zeroed: %x", i, w)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpeconfig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Presets are the named alphabets that the alphabet setting accepts.
+var presets = map[string]string{
+	"digits":    "0123456789",
+	"hex":       "0123456789abcdef",
+	"hex-upper": "0123456789ABCDEF",
+	"lower":     "abcdefghijklmnopqrstuvwxyz",
+	"upper":     "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alpha":     "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alnum":     "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"base36":    "0123456789abcdefghijklmnopqrstuvwxyz",
+}
+
+// Preset returns the alphabet of the preset with the given name, and whether there is one.
+func Preset(name string) ([]byte, bool) {
+	a, ok := presets[name]
+	if !ok {
+		return nil, false
+	}
+	return []byte(a), true
+}
+
+// PresetNames returns the names of all presets, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseRange builds an alphabet from a range string such as "0-9A-F". The string is a
+// sequence of single bytes and ranges lo-hi, which stand for all bytes from lo to hi.
+// A backslash makes the next byte literal, so "\-" and "\\" are a hyphen and a
+// backslash, and \xNN is the byte with the hexadecimal value NN. A hyphen at the
+// start or end is literal too. The bytes appear in the order given, duplicates
+// are an error.
+func ParseRange(s string) ([]byte, error) {
+	var alphabet []byte
+	var seen [256]bool
+	add := func(b byte) error {
+		if seen[b] {
+			return fmt.Errorf("byte %q appears more than once", b)
+		}
+		seen[b] = true
+		alphabet = append(alphabet, b)
+		return nil
+	}
+
+	// next returns the byte starting at s[i], resolving escapes, and the index after it
+	next := func(i int) (byte, int, error) {
+		if s[i] != '\\' {
+			return s[i], i + 1, nil
+		}
+		if i+1 == len(s) {
+			return 0, 0, fmt.Errorf("escape at end of range")
+		}
+		if s[i+1] != 'x' {
+			return s[i+1], i + 2, nil
+		}
+		if i+4 > len(s) {
+			return 0, 0, fmt.Errorf("incomplete \\x escape at position %d", i)
+		}
+		b, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid \\x escape %q at position %d", s[i:i+4], i)
+		}
+		return byte(b), i + 4, nil
+	}
+
+	for i := 0; i < len(s); {
+		lo, j, err := next(i)
+		if err != nil {
+			return nil, err
+		}
+		// lo-hi, unless the hyphen is the last byte
+		if j+1 < len(s) && s[j] == '-' {
+			hi, k, err := next(j + 1)
+			if err != nil {
+				return nil, err
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("range %q is reversed", s[i:k])
+			}
+			for b := int(lo); b <= int(hi); b++ {
+				if err := add(byte(b)); err != nil {
+					return nil, err
+				}
+			}
+			i = k
+			continue
+		}
+		if err := add(lo); err != nil {
+			return nil, err
+		}
+		i = j
+	}
+	if len(alphabet) == 0 {
+		return nil, fmt.Errorf("empty range")
+	}
+	return alphabet, nil
+}
@@ -0,0 +1,209 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package fpeconfig builds FF1 ciphers from a declarative JSON definition of the
+// protected fields, so that alphabets, tweaks and key references live in
+// configuration rather than code.
+//
+// The schema is an object with a single member "fields", mapping the name of
+// each field to its definition:
+//
+//	{
+//	  "fields": {
+//	    "ssn": {
+//	      "alphabet": "digits",
+//	      "key": "pii-2024",
+//	      "maxTweakLen": 16,
+//	      "tweak": "d8e7920afa330a73",
+//	      "fips": true
+//	    },
+//	    "plate": {
+//	      "range": "0-9A-Z",
+//	      "key": "vehicles"
+//	    }
+//	  }
+//	}
+//
+// A field definition has these members, all others are an error:
+//
+//   - alphabet: the name of a preset, see PresetNames. Exactly one of alphabet
+//     and range must be given.
+//   - range: an alphabet in the syntax of ParseRange, such as "0-9A-F".
+//   - key: the reference passed to the KeyLookup, required. The key must be
+//     128, 192 or 256 bits long.
+//   - maxTweakLen: the maximum tweak length in bytes, 0 if omitted.
+//   - tweak: the default tweak, hex-encoded, empty if omitted.
+//   - maxInputLen: if set, as WithMaxInputLen.
+//   - fips: if true, the cipher is built with NewCipherFIPS.
+//   - verifyRoundTrip: if true, as WithVerifyRoundTrip.
+//   - zeroize: if true, as WithZeroize.
+//
+// Errors name the member they concern with its path, such as
+// "fields.ssn.alphabet: unknown preset \"digit\"". YAML files can be used after
+// converting them to JSON, this package only depends on the standard library.
+package fpeconfig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// KeyLookup resolves the key reference of a field to the key itself, for example
+// by reading it from a key management service.
+type KeyLookup func(ref string) ([]byte, error)
+
+type config struct {
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+type fieldConfig struct {
+	Alphabet        *string `json:"alphabet"`
+	Range           *string `json:"range"`
+	Key             string  `json:"key"`
+	MaxTweakLen     int     `json:"maxTweakLen"`
+	Tweak           string  `json:"tweak"`
+	MaxInputLen     *int    `json:"maxInputLen"`
+	FIPS            bool    `json:"fips"`
+	VerifyRoundTrip bool    `json:"verifyRoundTrip"`
+	Zeroize         bool    `json:"zeroize"`
+}
+
+// Load reads a configuration from r and returns a Cipher for each of its fields,
+// by field name. keys resolves the key references. Nothing is returned unless all
+// of the fields are valid.
+func Load(r io.Reader, keys KeyLookup) (map[string]*ff1.Cipher, error) {
+	var cfg config
+	if err := decodeStrict(r, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, fmt.Errorf("fields: no fields defined")
+	}
+	if keys == nil {
+		return nil, fmt.Errorf("no KeyLookup given")
+	}
+
+	// Go through the fields in a fixed order, so the same file gives the same error
+	names := make([]string, 0, len(cfg.Fields))
+	for name := range cfg.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ciphers := make(map[string]*ff1.Cipher, len(names))
+	for _, name := range names {
+		path := "fields." + name
+		var fc fieldConfig
+		if err := decodeStrict(bytes.NewReader(cfg.Fields[name]), &fc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		c, err := fc.build(path, keys)
+		if err != nil {
+			return nil, err
+		}
+		ciphers[name] = c
+	}
+	return ciphers, nil
+}
+
+// decodeStrict decodes a single JSON value from r into v, rejecting unknown members.
+func decodeStrict(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after the configuration")
+	}
+	return nil
+}
+
+func (fc *fieldConfig) build(path string, keys KeyLookup) (*ff1.Cipher, error) {
+	var alphabet []byte
+	switch {
+	case fc.Alphabet != nil && fc.Range != nil:
+		return nil, fmt.Errorf("%s.range: cannot be combined with alphabet", path)
+	case fc.Alphabet != nil:
+		var ok bool
+		if alphabet, ok = Preset(*fc.Alphabet); !ok {
+			return nil, fmt.Errorf("%s.alphabet: unknown preset %q", path, *fc.Alphabet)
+		}
+	case fc.Range != nil:
+		var err error
+		if alphabet, err = ParseRange(*fc.Range); err != nil {
+			return nil, fmt.Errorf("%s.range: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: one of alphabet and range is required", path)
+	}
+
+	if fc.MaxTweakLen < 0 {
+		return nil, fmt.Errorf("%s.maxTweakLen: must not be negative", path)
+	}
+	tweak, err := hex.DecodeString(fc.Tweak)
+	if err != nil {
+		return nil, fmt.Errorf("%s.tweak: %w", path, err)
+	}
+	if len(tweak) > fc.MaxTweakLen {
+		return nil, fmt.Errorf("%s.tweak: %d bytes, longer than maxTweakLen %d", path, len(tweak), fc.MaxTweakLen)
+	}
+
+	var opts []ff1.Option
+	if fc.MaxInputLen != nil {
+		if *fc.MaxInputLen < 0 {
+			return nil, fmt.Errorf("%s.maxInputLen: must not be negative", path)
+		}
+		opts = append(opts, ff1.WithMaxInputLen(*fc.MaxInputLen))
+	}
+	if fc.VerifyRoundTrip {
+		opts = append(opts, ff1.WithVerifyRoundTrip())
+	}
+	if fc.Zeroize {
+		opts = append(opts, ff1.WithZeroize())
+	}
+
+	if fc.Key == "" {
+		return nil, fmt.Errorf("%s.key: missing", path)
+	}
+	key, err := keys(fc.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%s.key: %w", path, err)
+	}
+	if n := len(key); n != 16 && n != 24 && n != 32 {
+		return nil, fmt.Errorf("%s.key: key %q is %d bytes, must be 16, 24 or 32", path, fc.Key, n)
+	}
+
+	var c ff1.Cipher
+	if fc.FIPS {
+		c, err = ff1.NewCipherFIPS(alphabet, fc.MaxTweakLen, key, tweak, opts...)
+	} else {
+		c, err = ff1.NewCipherWithAlphabet(alphabet, fc.MaxTweakLen, key, tweak, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
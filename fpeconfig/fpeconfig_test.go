@@ -0,0 +1,232 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpeconfig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var errNoKey = errors.New("no such key")
+
+var testKeys = map[string]string{
+	"nist":     "2B7E151628AED2A6ABF7158809CF4F3C",
+	"vehicles": "EF4359D8D580AA4F7F036D6F04FC6A94",
+	"short":    "2B7E151628AED2A6ABF7158809CF4F3C00",
+}
+
+func lookupTestKey(ref string) ([]byte, error) {
+	k, ok := testKeys[ref]
+	if !ok {
+		return nil, errNoKey
+	}
+	return hex.DecodeString(k)
+}
+
+func loadFile(t *testing.T, name string) (map[string]*ff1.Cipher, error) {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return Load(f, lookupTestKey)
+}
+
+func TestLoad(t *testing.T) {
+	ciphers, err := loadFile(t, "fields.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ciphers) != 3 {
+		t.Fatalf("%d ciphers, want 3", len(ciphers))
+	}
+
+	// NIST sample 2
+	ssn := ciphers["ssn"]
+	ciphertext, err := ssn.Encrypt([]byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ciphertext) != "6124200773" {
+		t.Fatalf("ssn: ciphertext %s, want 6124200773", ciphertext)
+	}
+	// fips raises the minimum length to 6 digits
+	if _, err := ssn.Encrypt([]byte("12345")); err == nil {
+		t.Fatal("ssn: 5 digits accepted")
+	}
+
+	key, _ := lookupTestKey("vehicles")
+	plate, err := ff1.NewCipherWithAlphabet([]byte("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"), 0, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := plate.Encrypt([]byte("AB123CD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ciphers["plate"].Encrypt([]byte("AB123CD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("plate: ciphertext %s, want %s", got, want)
+	}
+	if _, err := ciphers["plate"].Encrypt([]byte("AB123CD45678X")); !errors.Is(err, ff1.ErrInputTooLong) {
+		t.Fatalf("plate: got %v, want %v", err, ff1.ErrInputTooLong)
+	}
+
+	binary := []byte{0x00, 0xff, 0x10, 0x80}
+	ciphertext, err = ciphers["token"].Encrypt(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := ciphers["token"].Decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, binary) {
+		t.Fatalf("token: round trip gave %x, want %x", plaintext, binary)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	if _, err := loadFile(t, "unknown_preset.json"); err == nil || err.Error() != `fields.ssn.alphabet: unknown preset "digit"` {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := loadFile(t, "bad_key.json"); err == nil || !strings.HasPrefix(err.Error(), "fields.plate.key: ") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+var testErrors = []struct {
+	config string
+	err    string
+}{
+	{`{}`, "fields: no fields defined"},
+	{`{"fields": {}}`, "fields: no fields defined"},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist"}}, "extra": 1}`, `unknown field "extra"`},
+	{`{"fields": {"a": {"alphabt": "digits", "key": "nist"}}}`, `fields.a: json: unknown field "alphabt"`},
+	{`{"fields": {"a": {"key": "nist"}}}`, "fields.a: one of alphabet and range is required"},
+	{`{"fields": {"a": {"alphabet": "digits", "range": "0-9", "key": "nist"}}}`, "fields.a.range: cannot be combined with alphabet"},
+	{`{"fields": {"a": {"range": "9-0", "key": "nist"}}}`, `fields.a.range: range "9-0" is reversed`},
+	{`{"fields": {"a": {"range": "x", "key": "nist"}}}`, "fields.a: "},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist", "maxTweakLen": -1}}}`, "fields.a.maxTweakLen: must not be negative"},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist", "maxTweakLen": 4, "tweak": "xyz"}}}`, "fields.a.tweak: "},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist", "maxTweakLen": 1, "tweak": "0102"}}}`, "fields.a.tweak: 2 bytes, longer than maxTweakLen 1"},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist", "maxInputLen": -1}}}`, "fields.a.maxInputLen: must not be negative"},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist", "maxInputLen": 1}}}`, "fields.a: maximum input length 1 is below the minimum length 2"},
+	{`{"fields": {"a": {"alphabet": "digits"}}}`, "fields.a.key: missing"},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "missing"}}}`, "fields.a.key: no such key"},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "short"}}}`, `fields.a.key: key "short" is 17 bytes, must be 16, 24 or 32`},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist", "fips": true, "maxInputLen": 5}}}`, "fields.a: FIPS mode: "},
+	{`{"fields": {"b": {"alphabet": "digits", "key": "nist"}, "a": {"alphabet": "nope", "key": "nist"}}}`, `fields.a.alphabet: unknown preset "nope"`},
+	{`{"fields": {"a": {"alphabet": "digits", "key": "nist"}}} {}`, "unexpected data after the configuration"},
+}
+
+func TestLoadErrors(t *testing.T) {
+	for idx, testError := range testErrors {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			ciphers, err := Load(strings.NewReader(testError.config), lookupTestKey)
+			if err == nil {
+				t.Fatalf("accepted, want error %q", testError.err)
+			}
+			if !strings.Contains(err.Error(), testError.err) {
+				t.Fatalf("error %q, want %q", err, testError.err)
+			}
+			if ciphers != nil {
+				t.Fatal("ciphers returned with an error")
+			}
+		})
+	}
+
+	_, err := Load(strings.NewReader(`{"fields": {"a": {"alphabet": "digits", "key": "missing"}}}`), lookupTestKey)
+	if !errors.Is(err, errNoKey) {
+		t.Fatalf("got %v, want it to wrap %v", err, errNoKey)
+	}
+	if _, err := Load(strings.NewReader(`{"fields": {"a": {"alphabet": "digits", "key": "nist"}}}`), nil); err == nil {
+		t.Fatal("nil KeyLookup accepted")
+	}
+}
+
+var testRanges = []struct {
+	input    string
+	alphabet string
+	error    bool
+}{
+	{"0-9", "0123456789", false},
+	{"0-9A-F", "0123456789ABCDEF", false},
+	{"a-cx_", "abcx_", false},
+	{"-a-c", "-abc", false},
+	{"a-c-", "abc-", false},
+	{`a\-c`, "a-c", false},
+	{`\\\x41-\x43`, `\ABC`, false},
+	{"\\x00-\\x01", "\x00\x01", false},
+	{"c-a", "", true},
+	{"a-cb", "", true},
+	{"", "", true},
+	{`ab\`, "", true},
+	{`\x4`, "", true},
+	{`\xzz`, "", true},
+	{`\x+1`, "", true},
+}
+
+func TestParseRange(t *testing.T) {
+	for idx, testRange := range testRanges {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			alphabet, err := ParseRange(testRange.input)
+			if testRange.error {
+				if err == nil {
+					t.Fatalf("ParseRange(%q) = %q, want error", testRange.input, alphabet)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRange(%q): %v", testRange.input, err)
+			}
+			if !reflect.DeepEqual(alphabet, []byte(testRange.alphabet)) {
+				t.Fatalf("ParseRange(%q) = %q, want %q", testRange.input, alphabet, testRange.alphabet)
+			}
+		})
+	}
+}
+
+func TestPresets(t *testing.T) {
+	for _, name := range PresetNames() {
+		alphabet, ok := Preset(name)
+		if !ok {
+			t.Fatalf("Preset(%q) missing", name)
+		}
+		if _, err := ff1.NewCipherWithAlphabet(alphabet, 0, make([]byte, 16), nil); err != nil {
+			t.Fatalf("preset %q: %v", name, err)
+		}
+	}
+	if _, ok := Preset("digit"); ok {
+		t.Fatal("unknown preset found")
+	}
+}
@@ -0,0 +1,458 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package fpecsv encrypts or decrypts selected columns of CSV data with FF1, one
+// row at a time, so that files of any size are processed in constant memory.
+package fpecsv
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// MaxReportedErrors is the number of row errors a Report lists, further errors
+// are only counted.
+const MaxReportedErrors = 100
+
+// tweakLen is the length of the tweaks derived from other columns.
+const tweakLen = 16
+
+// A Ref names a column by its header or, if Name is empty, by its zero-based index.
+type Ref struct {
+	Name  string
+	Index int
+}
+
+// Named refers to the column with the header name.
+func Named(name string) Ref {
+	return Ref{Name: name}
+}
+
+// At refers to the column at the zero-based index.
+func At(index int) Ref {
+	return Ref{Index: index}
+}
+
+func (r Ref) String() string {
+	if r.Name != "" {
+		return fmt.Sprintf("%q", r.Name)
+	}
+	return fmt.Sprintf("#%d", r.Index)
+}
+
+// A Column is processed with Cipher.
+type Column struct {
+	Ref
+	Cipher *ff1.Cipher
+
+	// TweakFrom lists other columns whose values make up the tweak of this column in
+	// each row, such as a customer id, so that equal values in different rows get
+	// different ciphertexts. The tweak is the first 16 bytes of the SHA-256 hash of
+	// the values, each prefixed with its length, and Cipher needs a maxTLen of at
+	// least 16 for it. The columns must not be processed themselves, as their values
+	// have to be the same for encryption and decryption. Without TweakFrom the
+	// default tweak of Cipher is used.
+	TweakFrom []Ref
+}
+
+// ColumnSpec says which columns Process changes and how it reads and writes them.
+type ColumnSpec struct {
+	Columns []Column
+
+	// Header is true if the first record holds the column names. It is copied to
+	// the output unchanged. Columns can only be named with a header.
+	Header bool
+
+	// Decrypt makes Process decrypt the columns instead of encrypting them.
+	Decrypt bool
+
+	// Comma is the field delimiter, ',' if it is 0.
+	Comma rune
+
+	// ContinueOnError makes Process skip rows that fail, leaving them out of the
+	// output, and go on with the next one. The failures are returned together in
+	// a Report at the end. Otherwise Process stops at the first one.
+	ContinueOnError bool
+}
+
+// A RowError is the failure of one row.
+type RowError struct {
+	Line   int    // line of the row in the input, starting at 1
+	Column string // the column concerned, empty if the row as a whole failed
+	Err    error
+}
+
+func (e RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %s: %v", e.Line, e.Column, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// A Report is returned by Process with ContinueOnError if rows failed.
+type Report struct {
+	Rows   int        // data rows read, not counting the header
+	Failed int        // rows left out of the output
+	Errors []RowError // the first MaxReportedErrors failures
+}
+
+func (r *Report) Error() string {
+	return fmt.Sprintf("fpecsv: %d of %d rows failed, the first with %v", r.Failed, r.Rows, r.Errors[0])
+}
+
+// column is a Column with its references resolved to indexes.
+type column struct {
+	Column
+	index int
+	name  string
+	tweak []int
+}
+
+// Process reads CSV data from r, encrypts or decrypts the columns of spec in every
+// row, and writes the result to w. Empty fields are left empty. Everything else
+// is copied from the input as it is, the header, the quotes around fields, line
+// endings, and empty lines included. A processed field is quoted if it was in
+// the input, or if its new value holds the delimiter, quotes or line breaks.
+//
+// Errors of a row carry its line number. With spec.ContinueOnError they are
+// collected and returned as a *Report, otherwise the first is returned as a
+// RowError. Errors in the spec, reading or writing always end Process.
+func Process(r io.Reader, w io.Writer, spec ColumnSpec) error {
+	in := &rawInput{r: r, line: 1}
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	comma := ','
+	if spec.Comma != 0 {
+		cr.Comma, comma = spec.Comma, spec.Comma
+	}
+	out := &rawOutput{w: bufio.NewWriter(w), comma: comma}
+
+	var header []string
+	if spec.Header {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return errors.New("fpecsv: missing header")
+		}
+		if err != nil {
+			return fmt.Errorf("fpecsv: %w", err)
+		}
+		header = append([]string{}, rec...)
+		if err := out.record(in.next(cr, rec, comma), nil, nil); err != nil {
+			return fmt.Errorf("fpecsv: %w", err)
+		}
+	}
+	columns, err := resolve(spec.Columns, header)
+	if err != nil {
+		return err
+	}
+
+	var (
+		report Report
+		buf    []byte
+	)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("fpecsv: %w", err)
+		}
+		report.Rows++
+		line, _ := cr.FieldPos(0)
+		raw := in.next(cr, rec, comma)
+
+		var rowErr *RowError
+		buf, rowErr = processRow(rec, columns, spec.Decrypt, buf)
+		if rowErr != nil {
+			rowErr.Line = line
+			if !spec.ContinueOnError {
+				// The rows before are written
+				out.w.Flush()
+				return fmt.Errorf("fpecsv: %w", *rowErr)
+			}
+			report.Failed++
+			if len(report.Errors) < MaxReportedErrors {
+				report.Errors = append(report.Errors, *rowErr)
+			}
+			continue
+		}
+		if err := out.record(raw, rec, columns); err != nil {
+			return fmt.Errorf("fpecsv: %w", err)
+		}
+	}
+	// Empty lines after the last record
+	out.w.Write(in.buf)
+	if err := out.w.Flush(); err != nil {
+		return fmt.Errorf("fpecsv: %w", err)
+	}
+	if report.Failed > 0 {
+		return &report
+	}
+	return nil
+}
+
+// rawInput passes the input on to a csv.Reader and keeps what it read from the
+// end of the last record taken with next.
+type rawInput struct {
+	r    io.Reader
+	buf  []byte
+	line int // line of buf[0], starting at 1
+}
+
+func (in *rawInput) Read(p []byte) (int, error) {
+	n, err := in.r.Read(p)
+	in.buf = append(in.buf, p[:n]...)
+	return n, err
+}
+
+// A rawRecord is the text of a record in the input, with the empty lines before
+// it and its line ending. fields holds the start and end of each of its fields.
+type rawRecord struct {
+	text   []byte
+	fields [][2]int
+}
+
+// next takes the text of rec, the record cr has just read, from the input. Its
+// end is not reported by cr, so it is found from the start of the last field.
+func (in *rawInput) next(cr *csv.Reader, rec []string, comma rune) rawRecord {
+	raw := rawRecord{fields: make([][2]int, len(rec))}
+	at, line := 0, in.line
+	for i := range rec {
+		fieldLine, col := cr.FieldPos(i)
+		for ; line < fieldLine; line++ {
+			at += bytes.IndexByte(in.buf[at:], '\n') + 1
+		}
+		raw.fields[i][0] = at + col - 1
+		if i > 0 {
+			raw.fields[i-1][1] = raw.fields[i][0] - utf8.RuneLen(comma)
+		}
+	}
+
+	// The last field ends at its closing quote, or before the line ending
+	last := &raw.fields[len(rec)-1]
+	end := last[0]
+	if end < len(in.buf) && in.buf[end] == '"' {
+		// cr has read the closing quote, a quote not doubled
+		for end++; ; end++ {
+			end += bytes.IndexByte(in.buf[end:], '"') + 1
+			if end == len(in.buf) || in.buf[end] != '"' {
+				break
+			}
+		}
+	} else if n := bytes.IndexByte(in.buf[end:], '\n'); n >= 0 {
+		end += n
+		if end > last[0] && in.buf[end-1] == '\r' {
+			end--
+		}
+	} else {
+		end = len(in.buf)
+	}
+	last[1] = end
+	if bytes.HasPrefix(in.buf[end:], []byte("\r\n")) {
+		end += 2
+	} else if bytes.HasPrefix(in.buf[end:], []byte("\n")) {
+		end++
+	}
+
+	raw.text = in.buf[:end]
+	in.buf = in.buf[end:]
+	in.line += bytes.Count(raw.text, []byte("\n"))
+	return raw
+}
+
+// rawOutput writes records as they were in the input, with processed fields
+// replaced.
+type rawOutput struct {
+	w     *bufio.Writer
+	comma rune
+}
+
+// record writes raw with the non-empty fields of columns taken from rec.
+func (out *rawOutput) record(raw rawRecord, rec []string, columns []column) error {
+	from := 0
+	for i, field := range raw.fields {
+		if rec == nil || rec[i] == "" || !processed(columns, i) {
+			continue
+		}
+		out.w.Write(raw.text[from:field[0]])
+		out.field(rec[i], raw.text[field[0]] == '"')
+		from = field[1]
+	}
+	_, err := out.w.Write(raw.text[from:])
+	return err
+}
+
+// field writes a processed field, quoted if quoted is true or it needs quotes.
+func (out *rawOutput) field(field string, quoted bool) {
+	first, _ := utf8.DecodeRuneInString(field)
+	if !quoted && !unicode.IsSpace(first) && !strings.ContainsRune(field, out.comma) && !strings.ContainsAny(field, "\"\r\n") {
+		out.w.WriteString(field)
+		return
+	}
+	out.w.WriteByte('"')
+	out.w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+	out.w.WriteByte('"')
+}
+
+// processed reports whether the field at index is one of columns.
+func processed(columns []column, index int) bool {
+	for _, col := range columns {
+		if col.index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// processRow replaces the fields of rec in place. buf is scratch memory for the
+// tweak and is returned grown.
+func processRow(rec []string, columns []column, decrypt bool, buf []byte) ([]byte, *RowError) {
+	for _, col := range columns {
+		if col.index >= len(rec) {
+			return buf, &RowError{Column: col.name, Err: fmt.Errorf("row has only %d fields", len(rec))}
+		}
+		for _, i := range col.tweak {
+			if i >= len(rec) {
+				return buf, &RowError{Column: col.name, Err: fmt.Errorf("row has only %d fields for the tweak", len(rec))}
+			}
+		}
+	}
+
+	// The fields are only replaced once all of them are done, so that a failing
+	// row is not half processed
+	results := make([]string, len(columns))
+	for k, col := range columns {
+		X := rec[col.index]
+		if X == "" {
+			continue
+		}
+		tweak := []byte(nil)
+		if col.tweak != nil {
+			buf = buf[:0]
+			for _, i := range col.tweak {
+				var n [4]byte
+				binary.BigEndian.PutUint32(n[:], uint32(len(rec[i])))
+				buf = append(append(buf, n[:]...), rec[i]...)
+			}
+			sum := sha256.Sum256(buf)
+			tweak = sum[:tweakLen]
+		}
+
+		var (
+			Y   []byte
+			err error
+		)
+		switch {
+		case decrypt && tweak != nil:
+			Y, err = col.Cipher.DecryptWithTweak([]byte(X), tweak)
+		case decrypt:
+			Y, err = col.Cipher.Decrypt([]byte(X))
+		case tweak != nil:
+			Y, err = col.Cipher.EncryptWithTweak([]byte(X), tweak)
+		default:
+			Y, err = col.Cipher.Encrypt([]byte(X))
+		}
+		if err != nil {
+			return buf, &RowError{Column: col.name, Err: err}
+		}
+		results[k] = string(Y)
+	}
+	for k, col := range columns {
+		if rec[col.index] != "" {
+			rec[col.index] = results[k]
+		}
+	}
+	return buf, nil
+}
+
+// resolve turns the references of columns into indexes, using header for names.
+func resolve(columns []Column, header []string) ([]column, error) {
+	index := func(ref Ref) (int, error) {
+		if ref.Name == "" {
+			if ref.Index < 0 {
+				return 0, fmt.Errorf("fpecsv: column %s: negative index", ref)
+			}
+			return ref.Index, nil
+		}
+		if header == nil {
+			return 0, fmt.Errorf("fpecsv: column %s: names need a header", ref)
+		}
+		found := -1
+		for i, name := range header {
+			if name != ref.Name {
+				continue
+			}
+			if found >= 0 {
+				return 0, fmt.Errorf("fpecsv: column %s: header has it twice", ref)
+			}
+			found = i
+		}
+		if found < 0 {
+			return 0, fmt.Errorf("fpecsv: column %s: not in header %s", ref, strings.Join(header, ","))
+		}
+		return found, nil
+	}
+
+	resolved := make([]column, len(columns))
+	processed := make(map[int]bool)
+	for k, col := range columns {
+		i, err := index(col.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if col.Cipher == nil {
+			return nil, fmt.Errorf("fpecsv: column %s: %w", col.Ref, ff1.ErrNotInitialized)
+		}
+		if processed[i] {
+			return nil, fmt.Errorf("fpecsv: column %s: given twice", col.Ref)
+		}
+		processed[i] = true
+		resolved[k] = column{Column: col, index: i, name: col.Ref.String()}
+	}
+	for k, col := range resolved {
+		for _, ref := range col.TweakFrom {
+			i, err := index(ref)
+			if err != nil {
+				return nil, err
+			}
+			if processed[i] {
+				return nil, fmt.Errorf("fpecsv: column %s: tweak column %s is processed itself", col.Ref, ref)
+			}
+			resolved[k].tweak = append(resolved[k].tweak, i)
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,311 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpecsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var testKey, _ = hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+
+func newCipher(t testing.TB, alphabet string, maxTLen int) *ff1.Cipher {
+	t.Helper()
+	c, err := ff1.NewCipherWithAlphabet([]byte(alphabet), maxTLen, testKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+// readAll parses CSV data for comparisons.
+func readAll(t *testing.T, data string) [][]string {
+	t.Helper()
+	recs, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v\n%s", err, data)
+	}
+	return recs
+}
+
+func process(t *testing.T, in string, spec ColumnSpec) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := Process(strings.NewReader(in), &out, spec)
+	return out.String(), err
+}
+
+func TestProcess(t *testing.T) {
+	digits := newCipher(t, "0123456789", 0)
+	// Ciphertexts of this alphabet contain the delimiter and quotes
+	punct := newCipher(t, "0123456789,\"", 0)
+
+	in := "id,name,ssn,code\n" +
+		"1,\"Doe, Jane\",123456789,\"12,34\"\n" +
+		"2,\"Says \"\"hi\"\"\",987654321,\"5,6\"\"7\"\n" +
+		"3,Nobody,,\n"
+
+	for idx, spec := range []ColumnSpec{
+		{Header: true, Columns: []Column{{Ref: Named("ssn"), Cipher: digits}, {Ref: Named("code"), Cipher: punct}}},
+		{Header: true, Columns: []Column{{Ref: At(2), Cipher: digits}, {Ref: At(3), Cipher: punct}}},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			out, err := process(t, in, spec)
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			plain, got := readAll(t, in), readAll(t, out)
+			if len(got) != len(plain) {
+				t.Fatalf("got %d records, want %d", len(got), len(plain))
+			}
+			for i := range plain {
+				for j := range plain[i] {
+					want := plain[i][j]
+					if i > 0 && j >= 2 && want != "" {
+						c := digits
+						if j == 3 {
+							c = punct
+						}
+						Y, err := c.Encrypt([]byte(want))
+						if err != nil {
+							t.Fatal(err)
+						}
+						want = string(Y)
+					}
+					if got[i][j] != want {
+						t.Errorf("record %d field %d: got %q, want %q", i, j, got[i][j], want)
+					}
+				}
+			}
+
+			spec.Decrypt = true
+			back, err := process(t, out, spec)
+			if err != nil {
+				t.Fatalf("Process to decrypt: %v", err)
+			}
+			if back != in {
+				t.Fatalf("decrypted:\n%s\nwant\n%s", back, in)
+			}
+		})
+	}
+}
+
+// Quotes, line endings and empty lines are kept as they are in the input
+func TestProcessQuoting(t *testing.T) {
+	digits := newCipher(t, "0123456789", 0)
+	punct := newCipher(t, "0123456789,\"", 0)
+	enc := func(c *ff1.Cipher, X string) string {
+		Y, err := c.Encrypt([]byte(X))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(Y)
+	}
+	quote := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
+
+	for idx, testCase := range []struct {
+		in   string
+		spec ColumnSpec
+		out  string
+	}{
+		{
+			"\"id\",\"ssn\"\r\n\"1\",\"123456789\"\r\n\"2\",555555555\r\n",
+			ColumnSpec{Header: true, Columns: []Column{{Ref: Named("ssn"), Cipher: digits}}},
+			"\"id\",\"ssn\"\r\n\"1\"," + quote(enc(digits, "123456789")) + "\r\n\"2\"," + enc(digits, "555555555") + "\r\n",
+		},
+		{
+			"\"note\nover lines\";123456\n\n\"x\";\"\"\n",
+			ColumnSpec{Comma: ';', Columns: []Column{{Ref: At(1), Cipher: digits}}},
+			"\"note\nover lines\";" + enc(digits, "123456") + "\n\n\"x\";\"\"\n",
+		},
+		{
+			"a,\"12,34\",b\nc,1234,\"d\"\n\n",
+			ColumnSpec{Columns: []Column{{Ref: At(1), Cipher: punct}}},
+			"a," + quote(enc(punct, "12,34")) + ",b\nc," + enc(punct, "1234") + ",\"d\"\n\n",
+		},
+		{
+			"a,\"1\"\"2\"\"3\"",
+			ColumnSpec{Columns: []Column{{Ref: At(1), Cipher: punct}}},
+			"a," + quote(enc(punct, "1\"2\"3")),
+		},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			out, err := process(t, testCase.in, testCase.spec)
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if out != testCase.out {
+				t.Fatalf("Got %q, expected %q", out, testCase.out)
+			}
+			testCase.spec.Decrypt = true
+			back, err := process(t, out, testCase.spec)
+			if err != nil || back != testCase.in {
+				t.Fatalf("decrypted %q, %v", back, err)
+			}
+		})
+	}
+
+	// A ciphertext with the delimiter is quoted although its plaintext was not
+	out, err := process(t, "c,5678\n", ColumnSpec{Columns: []Column{{Ref: At(1), Cipher: punct}}})
+	if expected := "c," + quote(enc(punct, "5678")) + "\n"; err != nil || out != expected || !strings.Contains(out, ",\"") {
+		t.Fatalf("Got %q and %v, expected %q", out, err, expected)
+	}
+}
+
+func TestProcessHeaderless(t *testing.T) {
+	digits := newCipher(t, "0123456789", 0)
+	in := "a;0123456789\nb;5555\n"
+	spec := ColumnSpec{Comma: ';', Columns: []Column{{Ref: At(1), Cipher: digits}}}
+
+	out, err := process(t, in, spec)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	Y1, _ := digits.Encrypt([]byte("0123456789"))
+	Y2, _ := digits.Encrypt([]byte("5555"))
+	if want := "a;" + string(Y1) + "\nb;" + string(Y2) + "\n"; out != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+
+	spec.Columns[0].Ref = Named("ssn")
+	if _, err := process(t, in, spec); err == nil || !strings.Contains(err.Error(), "names need a header") {
+		t.Fatalf("name without header: %v", err)
+	}
+}
+
+func TestProcessTweak(t *testing.T) {
+	digits := newCipher(t, "0123456789", 16)
+	in := "customer,ssn\nalice,123456789\nbob,123456789\nalice,123456789\n"
+	spec := ColumnSpec{Header: true, Columns: []Column{
+		{Ref: Named("ssn"), Cipher: digits, TweakFrom: []Ref{Named("customer")}},
+	}}
+
+	out, err := process(t, in, spec)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	got := readAll(t, out)
+	if got[1][1] == got[2][1] || got[1][1] != got[3][1] || got[1][1] == "123456789" {
+		t.Fatalf("tweaked ciphertexts %q, %q, %q", got[1][1], got[2][1], got[3][1])
+	}
+
+	spec.Decrypt = true
+	back, err := process(t, out, spec)
+	if err != nil || back != in {
+		t.Fatalf("decrypted %q, %v", back, err)
+	}
+
+	// A tweak column cannot be processed itself
+	spec.Columns = append(spec.Columns, Column{Ref: Named("customer"), Cipher: digits})
+	if _, err := process(t, in, spec); err == nil || !strings.Contains(err.Error(), "processed itself") {
+		t.Fatalf("processed tweak column: %v", err)
+	}
+}
+
+func TestProcessError(t *testing.T) {
+	digits := newCipher(t, "0123456789", 0)
+	in := "id,ssn\n1,123456789\n2,12x456789\n3\n4,444444444\n"
+	spec := ColumnSpec{Header: true, Columns: []Column{{Ref: Named("ssn"), Cipher: digits}}}
+
+	// The first failing row ends Process
+	out, err := process(t, in, spec)
+	var rowErr RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 3 || rowErr.Column != `"ssn"` || !errors.Is(err, ff1.ErrStringNotInRadix) {
+		t.Fatalf("got %v, want an error on line 3", err)
+	}
+	Y, _ := digits.Encrypt([]byte("123456789"))
+	if out != "id,ssn\n1,"+string(Y)+"\n" {
+		t.Fatalf("output %q", out)
+	}
+
+	// Or the failing rows are left out and reported
+	spec.ContinueOnError = true
+	out, err = process(t, in, spec)
+	var report *Report
+	if !errors.As(err, &report) {
+		t.Fatalf("got %v, want a report", err)
+	}
+	if report.Rows != 4 || report.Failed != 2 || len(report.Errors) != 2 ||
+		report.Errors[0].Line != 3 || report.Errors[1].Line != 4 {
+		t.Fatalf("report %+v", report)
+	}
+	if got := readAll(t, out); len(got) != 3 || got[1][0] != "1" || got[2][0] != "4" {
+		t.Fatalf("output %q", out)
+	}
+
+	for idx, spec := range []ColumnSpec{
+		{Header: true, Columns: []Column{{Ref: Named("dob"), Cipher: digits}}},
+		{Header: true, Columns: []Column{{Ref: Named("ssn")}}},
+		{Header: true, Columns: []Column{{Ref: At(-1), Cipher: digits}}},
+		{Header: true, Columns: []Column{{Ref: Named("ssn"), Cipher: digits}, {Ref: At(1), Cipher: digits}}},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if _, err := process(t, in, spec); err == nil {
+				t.Fatal("Process succeeded")
+			}
+		})
+	}
+
+	if _, err := process(t, "", spec); err == nil {
+		t.Fatal("Process succeeded without header")
+	}
+	if _, err := process(t, "id,ssn\n1,\"12\n", spec); err == nil {
+		t.Fatal("Process succeeded with an unterminated quote")
+	}
+}
+
+// rowReader generates rows of CSV data without holding them in memory.
+type rowReader struct {
+	rows, next int
+	pending    []byte
+}
+
+func (r *rowReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.next == r.rows {
+			return 0, io.EOF
+		}
+		r.pending = []byte(fmt.Sprintf("%d,\"Name, %d\",%09d\n", r.next, r.next, r.next*7919))
+		r.next++
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Each iteration is one row of a stream of b.N rows, so B/op staying flat as b.N
+// grows shows that memory does not grow with the file.
+func BenchmarkProcess(b *testing.B) {
+	digits := newCipher(b, "0123456789", 16)
+	spec := ColumnSpec{Columns: []Column{{Ref: At(2), Cipher: digits, TweakFrom: []Ref{At(0)}}}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	if err := Process(&rowReader{rows: b.N}, io.Discard, spec); err != nil {
+		b.Fatal(err)
+	}
+}
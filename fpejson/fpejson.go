@@ -0,0 +1,346 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package fpejson encrypts selected string fields of a JSON document in place with
+// format-preserving ciphers from the registry of package fpe, leaving the rest of
+// the document as it is.
+//
+// The fields are named by JSON pointers (RFC 6901), such as "/customer/ssn". In
+// addition a segment "*" matches every element of an array, so "/orders/*/card"
+// reaches the card of each order. Only array elements match "*", an object member
+// named "*" is still reached with "/*".
+//
+// The document is not decoded and marshalled again: the bytes of every value
+// that no rule targets, and all whitespace, are copied unchanged, so numbers keep
+// their formatting and members their order. Targeted strings are written back
+// with the escaping of encoding/json, except that <, > and & are not escaped.
+package fpejson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	fpe "github.com/Tensai75/go-fpe-bytes"
+)
+
+var (
+	// ErrNotString is returned, qualified with the path, when a rule targets a value
+	// that is not a string
+	ErrNotString = errors.New("value is not a string")
+
+	// ErrPathNotFound is returned, qualified with the path, for a rule that matches
+	// no value
+	ErrPathNotFound = errors.New("path not found")
+
+	// ErrTemplateMismatch is returned, qualified with the path, for a value that
+	// does not fit the template of its rule
+	ErrTemplateMismatch = errors.New("value does not match template")
+)
+
+// TemplateDigit marks the positions of a FieldRule.Template that are encrypted.
+const TemplateDigit = '#'
+
+// A FieldRule says how to encrypt the string at one path.
+type FieldRule struct {
+	// Cipher is the name of the cipher in the registry of package fpe.
+	Cipher string
+
+	// Passthrough lists bytes that are left in place, such as the dashes of
+	// "123-45-6789". The other bytes of the value are encrypted together.
+	Passthrough string
+
+	// Template, if set, gives the exact shape of the value: each TemplateDigit is
+	// a byte that is encrypted and every other byte must appear as it is, such as
+	// "###-##-####". Values of another length or with other bytes are rejected.
+	// It cannot be combined with Passthrough.
+	Template string
+}
+
+// EncryptFields returns a copy of the JSON document doc with the string at the
+// path of each rule encrypted. The rules are keyed by path. A rule without "*"
+// must match a value, one with "*" may match none, for an empty array.
+//
+// Errors name the path of the value concerned, such as
+// "fpejson: /orders/1/card: value is not a string".
+func EncryptFields(doc []byte, rules map[string]FieldRule) ([]byte, error) {
+	return transform(doc, rules, true)
+}
+
+// DecryptFields is the inverse of EncryptFields with the same rules.
+func DecryptFields(doc []byte, rules map[string]FieldRule) ([]byte, error) {
+	return transform(doc, rules, false)
+}
+
+// rule is a FieldRule with its path split into segments and its cipher resolved.
+type rule struct {
+	FieldRule
+	path     string
+	segments []string
+	wildcard bool
+	matched  bool
+	cipher   fpe.Cipher
+}
+
+// frame is an open object or array of the document.
+type frame struct {
+	array bool
+	index int
+	key   string
+	isKey bool // the next string is a member name
+}
+
+func transform(doc []byte, rules map[string]FieldRule, encrypt bool) ([]byte, error) {
+	parsed, err := parseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var (
+		out   []byte
+		done  int // doc[:done] is in out already
+		stack []frame
+		ended bool // the top-level value is complete
+	)
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF && ended {
+			break
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fpejson: %w", err)
+		}
+		if ended {
+			return nil, errors.New("fpejson: data after the top-level value")
+		}
+
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.isKey {
+				if d, ok := tok.(json.Delim); !ok || d != '}' {
+					top.key, top.isKey = tok.(string), false
+					continue
+				}
+			}
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			next(stack)
+			ended = len(stack) == 0
+			continue
+		}
+
+		r, err := match(parsed, stack)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			s, ok := tok.(string)
+			if !ok {
+				return nil, fmt.Errorf("fpejson: %s: %w", pointer(stack), ErrNotString)
+			}
+			repl, err := r.apply(s, encrypt)
+			if err != nil {
+				return nil, fmt.Errorf("fpejson: %s: %w", pointer(stack), err)
+			}
+			// Between tokens there is only whitespace and the separators
+			at := int(start) + bytes.IndexByte(doc[start:], '"')
+			out = append(append(out, doc[done:at]...), repl...)
+			done = int(dec.InputOffset())
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, frame{isKey: true})
+		case json.Delim('['):
+			stack = append(stack, frame{array: true})
+		default:
+			next(stack)
+			ended = len(stack) == 0
+		}
+	}
+
+	for _, r := range parsed {
+		if !r.matched && !r.wildcard {
+			return nil, fmt.Errorf("fpejson: %s: %w", r.path, ErrPathNotFound)
+		}
+	}
+	return append(out, doc[done:]...), nil
+}
+
+// next moves the innermost open container past the value that just ended.
+func next(stack []frame) {
+	if len(stack) == 0 {
+		return
+	}
+	top := &stack[len(stack)-1]
+	if top.array {
+		top.index++
+	} else {
+		top.isKey = true
+	}
+}
+
+// pointer returns the JSON pointer of the value at the position of stack.
+func pointer(stack []frame) string {
+	var b strings.Builder
+	for _, f := range stack {
+		b.WriteByte('/')
+		if f.array {
+			b.WriteString(strconv.Itoa(f.index))
+		} else {
+			b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(f.key))
+		}
+	}
+	return b.String()
+}
+
+// match returns the rule for the value at the position of stack, or nil. It is
+// an error if several rules match.
+func match(rules []*rule, stack []frame) (*rule, error) {
+	var found *rule
+	for _, r := range rules {
+		if len(r.segments) != len(stack) {
+			continue
+		}
+		ok := true
+		for i, f := range stack {
+			seg := r.segments[i]
+			if f.array {
+				ok = seg == "*" || seg == strconv.Itoa(f.index)
+			} else {
+				ok = seg == f.key
+			}
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("fpejson: %s: matched by both %s and %s", pointer(stack), found.path, r.path)
+		}
+		r.matched = true
+		found = r
+	}
+	return found, nil
+}
+
+func parseRules(rules map[string]FieldRule) ([]*rule, error) {
+	parsed := make([]*rule, 0, len(rules))
+	for path, fr := range rules {
+		if path != "" && path[0] != '/' {
+			return nil, fmt.Errorf("fpejson: %s: JSON pointer must start with /", path)
+		}
+		if fr.Template != "" && fr.Passthrough != "" {
+			return nil, fmt.Errorf("fpejson: %s: template and passthrough cannot be combined", path)
+		}
+		c, ok := fpe.Get(fr.Cipher)
+		if !ok {
+			return nil, fmt.Errorf("fpejson: %s: %w: %q", path, fpe.ErrNotRegistered, fr.Cipher)
+		}
+		r := &rule{FieldRule: fr, path: path, cipher: c}
+		if path != "" {
+			r.segments = strings.Split(path[1:], "/")
+		}
+		for i, seg := range r.segments {
+			r.wildcard = r.wildcard || seg == "*"
+			r.segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(seg)
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// apply encrypts or decrypts s by r and returns it as a JSON string.
+func (r *rule) apply(s string, encrypt bool) ([]byte, error) {
+	value := []byte(s)
+
+	// positions holds the indexes of the bytes that are encrypted
+	var positions []int
+	switch {
+	case r.Template != "":
+		if len(value) != len(r.Template) {
+			return nil, ErrTemplateMismatch
+		}
+		for i := range value {
+			if r.Template[i] == TemplateDigit {
+				positions = append(positions, i)
+			} else if value[i] != r.Template[i] {
+				return nil, ErrTemplateMismatch
+			}
+		}
+	case r.Passthrough != "":
+		for i, b := range value {
+			if strings.IndexByte(r.Passthrough, b) < 0 {
+				positions = append(positions, i)
+			}
+		}
+	}
+
+	masked := r.Template != "" || r.Passthrough != ""
+	X := value
+	if masked {
+		X = make([]byte, len(positions))
+		for j, i := range positions {
+			X[j] = value[i]
+		}
+	}
+	var (
+		Y   []byte
+		err error
+	)
+	if encrypt {
+		Y, err = r.cipher.Encrypt(X)
+	} else {
+		Y, err = r.cipher.Decrypt(X)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if masked {
+		for j, i := range positions {
+			value[i] = Y[j]
+		}
+		Y = value
+	}
+	if !utf8.Valid(Y) {
+		return nil, errors.New("result is not valid UTF-8")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(string(Y)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
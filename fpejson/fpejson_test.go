@@ -0,0 +1,214 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpejson
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	fpe "github.com/Tensai75/go-fpe-bytes"
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+var (
+	registerOnce sync.Once
+	digits       *ff1.Cipher
+	alnum        *ff1.Cipher
+)
+
+// registerCiphers registers "fpejson-digits" and "fpejson-alnum" with package fpe.
+func registerCiphers(t *testing.T) {
+	t.Helper()
+	registerOnce.Do(func() {
+		key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+		d := ff1.MustNewCipher(10, 0, key, nil)
+		a := ff1.MustNewCipherWithAlphabet([]byte("0123456789abcdefghijklmnopqrstuvwxyz"), 0, key, nil)
+		digits, alnum = &d, &a
+		if err := fpe.Register("fpejson-digits", digits); err != nil {
+			panic(err)
+		}
+		if err := fpe.Register("fpejson-alnum", alnum); err != nil {
+			panic(err)
+		}
+	})
+}
+
+const testDoc = `{
+  "customer": {"name": "Zoë", "ssn": "123-45-6789", "score": 1.50e+2, "tags": ["a", "b"]},
+  "orders": [
+    {"id": 1, "card": "4111111111111111", "total": 0.10},
+    {"id": 2, "card": "5500005555555559", "total": 12.00, "notes": "<none> & more"}
+  ],
+  "ключ": {"cuenta/año": "abc123def"},
+  "empty": []
+}`
+
+func TestEncryptFields(t *testing.T) {
+	registerCiphers(t)
+	rules := map[string]FieldRule{
+		"/customer/ssn":     {Cipher: "fpejson-digits", Template: "###-##-####"},
+		"/orders/*/card":    {Cipher: "fpejson-digits"},
+		"/ключ/cuenta~1año": {Cipher: "fpejson-alnum"},
+		"/empty/*/nothing":  {Cipher: "fpejson-alnum"},
+	}
+
+	enc, err := EncryptFields([]byte(testDoc), rules)
+	if err != nil {
+		t.Fatalf("EncryptFields: %v", err)
+	}
+
+	// Everything but the targeted strings is byte-identical
+	mustEncrypt := func(c *ff1.Cipher, s string) string {
+		Y, err := c.Encrypt([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(Y)
+	}
+	ssn := mustEncrypt(digits, "123456789")
+	want := strings.NewReplacer(
+		`"123-45-6789"`, fmt.Sprintf(`"%s-%s-%s"`, ssn[:3], ssn[3:5], ssn[5:]),
+		`"4111111111111111"`, `"`+mustEncrypt(digits, "4111111111111111")+`"`,
+		`"5500005555555559"`, `"`+mustEncrypt(digits, "5500005555555559")+`"`,
+		`"abc123def"`, `"`+mustEncrypt(alnum, "abc123def")+`"`,
+	).Replace(testDoc)
+	if string(enc) != want {
+		t.Fatalf("EncryptFields:\n%s\nwant\n%s", enc, want)
+	}
+
+	dec, err := DecryptFields(enc, rules)
+	if err != nil {
+		t.Fatalf("DecryptFields: %v", err)
+	}
+	if !bytes.Equal(dec, []byte(testDoc)) {
+		t.Fatalf("DecryptFields:\n%s\nwant\n%s", dec, testDoc)
+	}
+}
+
+func TestEncryptFieldsRules(t *testing.T) {
+	registerCiphers(t)
+
+	for idx, test := range []struct {
+		doc  string
+		rule FieldRule
+		path string
+		want func(Y string) string
+	}{
+		// Passthrough bytes stay in place
+		{`{"p": "+1 (555) 010-9999"}`, FieldRule{Cipher: "fpejson-digits", Passthrough: "+ ()-"}, "/p",
+			func(Y string) string { return "+" + Y[:1] + " (" + Y[1:4] + ") " + Y[4:7] + "-" + Y[7:] }},
+		// The top-level value
+		{` "12345" `, FieldRule{Cipher: "fpejson-digits"}, "", func(Y string) string { return Y }},
+		// A member named "*" and escapes in the input
+		{`{"*": "123"}`, FieldRule{Cipher: "fpejson-digits"}, "/*", func(Y string) string { return Y }},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			rules := map[string]FieldRule{test.path: test.rule}
+			enc, err := EncryptFields([]byte(test.doc), rules)
+			if err != nil {
+				t.Fatalf("EncryptFields: %v", err)
+			}
+			var plain, got interface{}
+			if err := json.Unmarshal([]byte(test.doc), &plain); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(enc, &got); err != nil {
+				t.Fatalf("result %s is not JSON: %v", enc, err)
+			}
+			pick := func(v interface{}) string {
+				if m, ok := v.(map[string]interface{}); ok {
+					for _, s := range m {
+						return s.(string)
+					}
+				}
+				return v.(string)
+			}
+			X := strings.Map(func(r rune) rune {
+				if strings.ContainsRune(test.rule.Passthrough, r) {
+					return -1
+				}
+				return r
+			}, pick(plain))
+			Y, err := digits.Encrypt([]byte(X))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w := test.want(string(Y)); pick(got) != w {
+				t.Fatalf("got %q, want %q", pick(got), w)
+			}
+
+			dec, err := DecryptFields(enc, rules)
+			if err != nil {
+				t.Fatalf("DecryptFields: %v", err)
+			}
+			var back interface{}
+			if err := json.Unmarshal(dec, &back); err != nil || pick(back) != pick(plain) {
+				t.Fatalf("DecryptFields gave %s, %v", dec, err)
+			}
+		})
+	}
+}
+
+func TestEncryptFieldsError(t *testing.T) {
+	registerCiphers(t)
+	digitsRule := FieldRule{Cipher: "fpejson-digits"}
+
+	for idx, test := range []struct {
+		doc   string
+		rules map[string]FieldRule
+		want  error
+		msg   string
+	}{
+		{testDoc, map[string]FieldRule{"/orders/*/total": digitsRule}, ErrNotString, "/orders/0/total"},
+		{testDoc, map[string]FieldRule{"/customer": digitsRule}, ErrNotString, "/customer"},
+		{testDoc, map[string]FieldRule{"/customer/tags": digitsRule}, ErrNotString, "/customer/tags"},
+		{`{"a": null}`, map[string]FieldRule{"/a": digitsRule}, ErrNotString, "/a"},
+		{testDoc, map[string]FieldRule{"/customer/dob": digitsRule}, ErrPathNotFound, "/customer/dob"},
+		{testDoc, map[string]FieldRule{"/orders/2/card": digitsRule}, ErrPathNotFound, "/orders/2/card"},
+		{testDoc, map[string]FieldRule{"/customer/ssn": {Cipher: "fpejson-digits", Template: "#########"}}, ErrTemplateMismatch, "/customer/ssn"},
+		{testDoc, map[string]FieldRule{"/customer/ssn": {Cipher: "fpejson-digits", Template: "###/##/####"}}, ErrTemplateMismatch, "/customer/ssn"},
+		{testDoc, map[string]FieldRule{"/customer/ssn": digitsRule}, ff1.ErrStringNotInRadix, "/customer/ssn"},
+		{testDoc, map[string]FieldRule{"/customer/ssn": {Cipher: "nope"}}, fpe.ErrNotRegistered, "/customer/ssn"},
+		{testDoc, map[string]FieldRule{"orders": digitsRule}, nil, "must start with /"},
+		{testDoc, map[string]FieldRule{"/orders/0/card": digitsRule, "/orders/*/card": digitsRule}, nil, "/orders/0/card: matched by both"},
+		{`{"a": "123"`, map[string]FieldRule{"/a": digitsRule}, nil, "fpejson: unexpected EOF"},
+		{``, map[string]FieldRule{"": digitsRule}, nil, "fpejson: unexpected EOF"},
+		{`{"a": "123"} {}`, map[string]FieldRule{"/a": digitsRule}, nil, "after the top-level value"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			_, err := EncryptFields([]byte(test.doc), test.rules)
+			if err == nil {
+				t.Fatal("EncryptFields succeeded")
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+			if !strings.Contains(err.Error(), test.msg) {
+				t.Fatalf("error %q does not mention %q", err, test.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package fpetest provides property checks for format-preserving ciphers, for use
+// in the tests of code that configures them: that ciphertexts decrypt to their
+// plaintexts, that no two plaintexts share a ciphertext, and that ciphers with
+// different keys disagree.
+//
+// The checks take a testing.TB, so a *testing.T or *testing.B, and report
+// failures with the index of the input, hex dumps and the first differing position.
+package fpetest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	fpe "github.com/Tensai75/go-fpe-bytes"
+)
+
+// alphabetOf returns the alphabet of c if it reports one, as ff1.Cipher does.
+func alphabetOf(c fpe.Cipher) []byte {
+	if a, ok := c.(interface{ Alphabet() []byte }); ok {
+		return a.Alphabet()
+	}
+	return nil
+}
+
+// dump formats b as hex, and as text too if it is printable.
+func dump(b []byte) string {
+	for _, v := range b {
+		if v < 0x20 || v > 0x7e {
+			return hex.EncodeToString(b)
+		}
+	}
+	return fmt.Sprintf("%s (%q)", hex.EncodeToString(b), b)
+}
+
+// firstDiff returns the first position where a and b differ, or -1.
+func firstDiff(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return len(a)
+		}
+		return len(b)
+	}
+	return -1
+}
+
+// RoundTrip encrypts every input with c and checks that the ciphertext has the
+// length of the input, that it only holds bytes of the alphabet if c reports one
+// with an Alphabet method, and that it decrypts to the input.
+func RoundTrip(t testing.TB, c fpe.Cipher, inputs [][]byte) {
+	t.Helper()
+	alphabet := alphabetOf(c)
+	for idx, X := range inputs {
+		Y, err := c.Encrypt(X)
+		if err != nil {
+			t.Fatalf("input %d: Encrypt(%s): %v", idx, dump(X), err)
+		}
+		if len(Y) != len(X) {
+			t.Fatalf("input %d: Encrypt(%s) = %s, length %d instead of %d", idx, dump(X), dump(Y), len(Y), len(X))
+		}
+		if alphabet != nil {
+			for i, b := range Y {
+				if bytes.IndexByte(alphabet, b) < 0 {
+					t.Fatalf("input %d: Encrypt(%s) = %s, byte 0x%02x at position %d is not in the alphabet", idx, dump(X), dump(Y), b, i)
+				}
+			}
+		}
+		back, err := c.Decrypt(Y)
+		if err != nil {
+			t.Fatalf("input %d: Decrypt(%s) of the ciphertext of %s: %v", idx, dump(Y), dump(X), err)
+		}
+		if pos := firstDiff(back, X); pos >= 0 {
+			t.Fatalf("input %d: Decrypt(Encrypt(%s)) = %s, differs at position %d", idx, dump(X), dump(back), pos)
+		}
+	}
+}
+
+// BijectionSample encrypts n random plaintexts of length numerals, all different,
+// and checks that no two of them share a ciphertext. If there are no more than n
+// plaintexts of that length, all of them are encrypted, which proves c is a
+// permutation of them. c must report its alphabet with an Alphabet method, as
+// ff1.Cipher does. The random seed is part of the failure message.
+func BijectionSample(t testing.TB, c fpe.Cipher, n int, length int) {
+	t.Helper()
+	alphabet := alphabetOf(c)
+	if len(alphabet) == 0 {
+		t.Fatalf("BijectionSample: the cipher does not report its alphabet")
+	}
+	seed := time.Now().UnixNano()
+	rng := rand.New(rand.NewSource(seed))
+
+	// All plaintexts if there are few enough, random ones otherwise
+	var next func(X []byte) bool
+	if domain := math.Pow(float64(len(alphabet)), float64(length)); domain <= float64(n) {
+		n = int(domain)
+		i := 0
+		next = func(X []byte) bool {
+			v := i
+			for j := len(X) - 1; j >= 0; j-- {
+				X[j] = alphabet[v%len(alphabet)]
+				v /= len(alphabet)
+			}
+			i++
+			return true
+		}
+	} else {
+		seen := make(map[string]struct{}, n)
+		next = func(X []byte) bool {
+			for j := range X {
+				X[j] = alphabet[rng.Intn(len(alphabet))]
+			}
+			if _, dup := seen[string(X)]; dup {
+				return false
+			}
+			seen[string(X)] = struct{}{}
+			return true
+		}
+	}
+
+	ciphertexts := make(map[string][]byte, n)
+	for count := 0; count < n; {
+		X := make([]byte, length)
+		if !next(X) {
+			continue
+		}
+		count++
+		Y, err := c.Encrypt(X)
+		if err != nil {
+			t.Fatalf("Encrypt(%s) (seed %d): %v", dump(X), seed, err)
+		}
+		if other, ok := ciphertexts[string(Y)]; ok {
+			t.Fatalf("Encrypt(%s) = Encrypt(%s) = %s, after %d plaintexts (seed %d)", dump(other), dump(X), dump(Y), count, seed)
+		}
+		ciphertexts[string(Y)] = X
+	}
+}
+
+// KeySeparationThreshold is the probability below which KeySeparation considers
+// the ciphertexts two ciphers agree on too many to be chance.
+const KeySeparationThreshold = 1e-9
+
+// KeySeparation encrypts every input with c1 and c2, which should have different
+// keys, and checks that the ciphertexts differ. For short inputs of a small
+// alphabet two keys agree by chance, with a probability of one in the number of
+// possible ciphertexts, so some agreement is allowed: the check fails if the number
+// of inputs the two ciphers agree on is less likely than KeySeparationThreshold for
+// independent keys. The alphabet is taken from c1 if it reports one, and assumed
+// to be all 256 bytes otherwise.
+func KeySeparation(t testing.TB, c1, c2 fpe.Cipher, inputs [][]byte) {
+	t.Helper()
+	radix := float64(256)
+	if alphabet := alphabetOf(c1); len(alphabet) > 0 {
+		radix = float64(len(alphabet))
+	}
+
+	var chance []float64
+	var same []int
+	for idx, X := range inputs {
+		Y1, err := c1.Encrypt(X)
+		if err != nil {
+			t.Fatalf("input %d: first cipher: Encrypt(%s): %v", idx, dump(X), err)
+		}
+		Y2, err := c2.Encrypt(X)
+		if err != nil {
+			t.Fatalf("input %d: second cipher: Encrypt(%s): %v", idx, dump(X), err)
+		}
+		chance = append(chance, math.Pow(radix, -float64(len(X))))
+		if bytes.Equal(Y1, Y2) {
+			same = append(same, idx)
+		}
+	}
+	if len(same) == 0 {
+		return
+	}
+	if p := atLeast(chance, len(same)); p < KeySeparationThreshold {
+		var expected float64
+		for _, q := range chance {
+			expected += q
+		}
+		idx := same[0]
+		Y, _ := c1.Encrypt(inputs[idx])
+		t.Fatalf("the ciphers agree on %d of %d inputs, %.3g were expected by chance (p = %.3g), first input %d: Encrypt(%s) = %s",
+			len(same), len(inputs), expected, p, idx, dump(inputs[idx]), dump(Y))
+	}
+}
+
+// atLeast returns the probability that at least k of independent events with the
+// probabilities p happen.
+func atLeast(p []float64, k int) float64 {
+	// dist[j] is the probability that j of the events so far happened, and
+	// dist[k] that k or more did. Only adding keeps tiny probabilities accurate.
+	dist := make([]float64, k+1)
+	dist[0] = 1
+	for _, q := range p {
+		dist[k] += dist[k-1] * q
+		for j := k - 1; j > 0; j-- {
+			dist[j] = dist[j]*(1-q) + dist[j-1]*q
+		}
+		dist[0] *= 1 - q
+	}
+	return dist[k]
+}
@@ -0,0 +1,159 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpetest
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	fpe "github.com/Tensai75/go-fpe-bytes"
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// recorder is a testing.TB that records a failure instead of failing the test
+type recorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// failure runs check and returns the message it failed with, if any
+func failure(t *testing.T, check func(testing.TB)) string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		check(r)
+	}()
+	<-done
+	return r.failure
+}
+
+// broken ciphers, each breaking one property
+type (
+	// identity does not encrypt, so every key agrees
+	identity struct{}
+	// lossy maps every plaintext to the same ciphertext
+	lossy struct{ fpe.Cipher }
+	// garbling decrypts wrongly
+	garbling struct{ fpe.Cipher }
+)
+
+func (identity) Encrypt(X []byte) ([]byte, error) { return append([]byte{}, X...), nil }
+func (identity) Decrypt(X []byte) ([]byte, error) { return append([]byte{}, X...), nil }
+func (identity) Alphabet() []byte                 { return []byte("0123456789") }
+
+func (lossy) Encrypt(X []byte) ([]byte, error) { return []byte(strings.Repeat("7", len(X))), nil }
+func (lossy) Alphabet() []byte                 { return []byte("0123456789") }
+
+func (g garbling) Decrypt(X []byte) ([]byte, error) {
+	Y, err := g.Cipher.Decrypt(X)
+	if err == nil && len(Y) > 3 {
+		Y[3] = '0' + (Y[3]-'0'+1)%10
+	}
+	return Y, err
+}
+
+func newCipher(t *testing.T, key string) ff1.Cipher {
+	t.Helper()
+	c, err := ff1.NewCipher(10, 0, mustHex(key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	return c
+}
+
+func mustHex(s string) []byte {
+	b := make([]byte, len(s)/2)
+	if _, err := fmt.Sscanf(s, "%x", &b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var inputs = [][]byte{
+	[]byte("00"),
+	[]byte("0123456789"),
+	[]byte("99999999999999999999999999999999999999999"),
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := newCipher(t, "2B7E151628AED2A6ABF7158809CF4F3C")
+	RoundTrip(t, c, inputs)
+	RoundTrip(t, &c, inputs)
+
+	msg := failure(t, func(tb testing.TB) { RoundTrip(tb, garbling{c}, inputs) })
+	if !strings.Contains(msg, "input 1") || !strings.Contains(msg, "position 3") {
+		t.Errorf("Broken decryption reported as %q", msg)
+	}
+	msg = failure(t, func(tb testing.TB) { RoundTrip(tb, c, [][]byte{[]byte("12a")}) })
+	if !strings.Contains(msg, "input 0: Encrypt(") {
+		t.Errorf("Invalid input reported as %q", msg)
+	}
+}
+
+func TestBijectionSample(t *testing.T) {
+	c := newCipher(t, "2B7E151628AED2A6ABF7158809CF4F3C")
+	for idx, testCase := range []struct{ n, length int }{
+		{100, 2}, // every plaintext
+		{1000, 2},
+		{1000, 3},
+		{500, 20},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			BijectionSample(t, c, testCase.n, testCase.length)
+		})
+	}
+
+	msg := failure(t, func(tb testing.TB) { BijectionSample(tb, lossy{c}, 10, 6) })
+	if !strings.Contains(msg, "after 2 plaintexts") || !strings.Contains(msg, "seed") {
+		t.Errorf("Collision reported as %q", msg)
+	}
+}
+
+func TestKeySeparation(t *testing.T) {
+	c1 := newCipher(t, "2B7E151628AED2A6ABF7158809CF4F3C")
+	c2 := newCipher(t, "2B7E151628AED2A6ABF7158809CF4F3D")
+	KeySeparation(t, c1, c2, inputs)
+
+	// Two-numeral inputs agree on about one in a hundred by chance
+	var short [][]byte
+	for i := 0; i < 100; i++ {
+		short = append(short, []byte(fmt.Sprintf("%02d", i)))
+	}
+	KeySeparation(t, c1, c2, short)
+
+	msg := failure(t, func(tb testing.TB) { KeySeparation(tb, c1, c1, inputs) })
+	if !strings.Contains(msg, "agree on 3 of 3") {
+		t.Errorf("Same key reported as %q", msg)
+	}
+	msg = failure(t, func(tb testing.TB) { KeySeparation(tb, identity{}, identity{}, short) })
+	if !strings.Contains(msg, "agree on 100 of 100") {
+		t.Errorf("Identity reported as %q", msg)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/Tensai75/go-fpe-bytes
 
-go 1.16
+go 1.18
@@ -0,0 +1,315 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+// Package migrate re-encrypts columns of a database with a new key, in batches
+// that can be interrupted and resumed.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// MaxReportedErrors is the number of row failures a Report keeps.
+const MaxReportedErrors = 100
+
+// DefaultBatchSize is the number of rows per transaction if ColumnConfig.BatchSize
+// is not set.
+const DefaultBatchSize = 1000
+
+// KeyTweakLen is the length of the tweaks of KeyTweak.
+const KeyTweakLen = 16
+
+// KeyTweak derives a tweak from the primary key of a row: the first KeyTweakLen
+// bytes of its SHA-256 hash. Ciphers used with it need a maximum tweak length of
+// at least KeyTweakLen.
+func KeyTweak(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:KeyTweakLen]
+}
+
+// QuestionPlaceholder numbers query parameters as ?, as MySQL and SQLite do.
+func QuestionPlaceholder(int) string {
+	return "?"
+}
+
+// DollarPlaceholder numbers query parameters as $1, $2, ..., as PostgreSQL does.
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// ColumnConfig describes a column to re-encrypt. Table, Key and Column are put into
+// the queries as they are, so they must be trusted and quoted as the database needs.
+type ColumnConfig struct {
+	Table  string
+	Key    string // the primary key, which orders the rows
+	Column string
+
+	// Old decrypts the values, New encrypts them again
+	Old, New *ff1.Cipher
+
+	// Tweak derives the tweak of a row from its key, such as KeyTweak. Old and New
+	// use it for both decrypting and encrypting. Without it the default tweaks of
+	// the ciphers are used.
+	Tweak func(key []byte) []byte
+
+	// BatchSize is the number of rows read and updated in one transaction,
+	// DefaultBatchSize if 0
+	BatchSize int
+
+	// Placeholder returns the parameter marker for the nth parameter of a query,
+	// QuestionPlaceholder if nil
+	Placeholder func(n int) string
+
+	// Resume is the Checkpoint of an earlier run: rows with keys up to it are
+	// left alone. Nil starts from the first row.
+	Resume interface{}
+
+	// Progress is called after each batch is committed, with the totals so far
+	Progress func(Progress)
+
+	// ContinueOnError leaves rows that cannot be re-encrypted unchanged and goes
+	// on, instead of ending the migration with the batch rolled back
+	ContinueOnError bool
+}
+
+// Progress counts the rows of a migration.
+type Progress struct {
+	Rows    int64 // rows read
+	Updated int64 // rows re-encrypted
+	Skipped int64 // rows whose value is NULL
+	Failed  int64 // rows left unchanged because they could not be re-encrypted
+
+	// Checkpoint is the key of the last row of the last committed batch, for
+	// ColumnConfig.Resume
+	Checkpoint interface{}
+}
+
+// A RowError is the failure of one row.
+type RowError struct {
+	Key interface{} // the primary key of the row
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %v: %v", e.Key, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// A Report is returned by Column with ContinueOnError if rows failed.
+type Report struct {
+	Progress
+	Errors []RowError // the first MaxReportedErrors failures
+}
+
+func (r *Report) Error() string {
+	return fmt.Sprintf("migrate: %d of %d rows failed, the first with %v", r.Failed, r.Rows, r.Errors[0])
+}
+
+// row is a row of a batch.
+type row struct {
+	key   interface{}
+	value []byte
+}
+
+// Column re-encrypts the column of cfg in every row, decrypting with cfg.Old and
+// encrypting with cfg.New, in transactions of cfg.BatchSize rows taken in the
+// order of the key. NULL values are left alone. It runs
+//
+//	SELECT key, column FROM table WHERE key > ? ORDER BY key LIMIT ?
+//	UPDATE table SET column = ? WHERE key = ?
+//
+// without the WHERE clause of the first query for the first batch, unless
+// cfg.Resume is set. The key values are passed back to the database as they were
+// scanned, apart from bytes, which are passed as strings, and converted to their
+// decimal or text form for cfg.Tweak.
+//
+// The returned Progress covers the committed batches, and its Checkpoint can
+// resume the migration after an error. A row that fails ends Column with a
+// RowError, after rolling back its batch, unless cfg.ContinueOnError is set: then
+// failures are collected and returned as a *Report once all rows are done.
+func Column(ctx context.Context, db *sql.DB, cfg ColumnConfig) (Progress, error) {
+	if cfg.Old == nil || cfg.New == nil {
+		return Progress{}, errors.New("migrate: Old and New ciphers are needed")
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	ph := cfg.Placeholder
+	if ph == nil {
+		ph = QuestionPlaceholder
+	}
+	first := fmt.Sprintf("SELECT %s, %s FROM %s ORDER BY %s LIMIT %s",
+		cfg.Key, cfg.Column, cfg.Table, cfg.Key, ph(1))
+	next := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s > %s ORDER BY %s LIMIT %s",
+		cfg.Key, cfg.Column, cfg.Table, cfg.Key, ph(1), cfg.Key, ph(2))
+	update := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s",
+		cfg.Table, cfg.Column, ph(1), cfg.Key, ph(2))
+
+	var report Report
+	report.Checkpoint = cfg.Resume
+	for {
+		query, args := first, []interface{}{batchSize}
+		if report.Checkpoint != nil {
+			query, args = next, []interface{}{report.Checkpoint, batchSize}
+		}
+		batch, err := runBatch(ctx, db, cfg, &report, query, args, update)
+		if err != nil {
+			return report.Progress, err
+		}
+		if batch < batchSize {
+			break
+		}
+	}
+	if report.Failed > 0 {
+		return report.Progress, &report
+	}
+	return report.Progress, nil
+}
+
+// runBatch migrates the rows query returns in one transaction, adds them to report
+// once it is committed, and returns their number.
+func runBatch(ctx context.Context, db *sql.DB, cfg ColumnConfig, report *Report, query string, args []interface{}, update string) (n int, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	batch, err := readBatch(ctx, tx, query, args)
+	if err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, tx.Commit()
+	}
+	stmt, err := tx.PrepareContext(ctx, update)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: %w", err)
+	}
+	defer stmt.Close()
+
+	progress := report.Progress
+	var failures []RowError
+	for _, r := range batch {
+		progress.Rows++
+		if r.value == nil {
+			progress.Skipped++
+			continue
+		}
+		Y, err := reencrypt(cfg, r)
+		if err != nil {
+			if !cfg.ContinueOnError {
+				return 0, RowError{Key: r.key, Err: err}
+			}
+			progress.Failed++
+			failures = append(failures, RowError{Key: r.key, Err: err})
+			continue
+		}
+		if _, err := stmt.ExecContext(ctx, string(Y), r.key); err != nil {
+			return 0, fmt.Errorf("migrate: updating row %v: %w", r.key, err)
+		}
+		progress.Updated++
+	}
+	progress.Checkpoint = batch[len(batch)-1].key
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("migrate: %w", err)
+	}
+
+	report.Progress = progress
+	for _, f := range failures {
+		if len(report.Errors) < MaxReportedErrors {
+			report.Errors = append(report.Errors, f)
+		}
+	}
+	if cfg.Progress != nil {
+		cfg.Progress(progress)
+	}
+	return len(batch), nil
+}
+
+// readBatch returns the rows of query.
+func readBatch(ctx context.Context, tx *sql.Tx, query string, args []interface{}) ([]row, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	defer rows.Close()
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.key, &r.value); err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		// Drivers return text as bytes, which databases compare as blobs
+		if b, ok := r.key.([]byte); ok {
+			r.key = string(b)
+		}
+		batch = append(batch, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return batch, nil
+}
+
+// reencrypt decrypts the value of r with the old cipher and encrypts it with the
+// new one.
+func reencrypt(cfg ColumnConfig, r row) ([]byte, error) {
+	if cfg.Tweak == nil {
+		X, err := cfg.Old.Decrypt(r.value)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.New.Encrypt(X)
+	}
+	tweak := cfg.Tweak(keyBytes(r.key))
+	X, err := cfg.Old.DecryptWithTweak(r.value, tweak)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.New.EncryptWithTweak(X, tweak)
+}
+
+// keyBytes returns the text form of a key as scanned from the database.
+func keyBytes(key interface{}) []byte {
+	switch k := key.(type) {
+	case []byte:
+		return k
+	case string:
+		return []byte(k)
+	case int64:
+		return strconv.AppendInt(nil, k, 10)
+	}
+	return []byte(fmt.Sprint(key))
+}
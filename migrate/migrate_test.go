@@ -0,0 +1,429 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// fakeDriver is a database/sql driver with one table per data source name, of an
+// integer key and a text value. It understands the queries of Column for any
+// table and column names, and applies updates when their transaction commits.
+type fakeDriver struct {
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+}
+
+type fakeTable struct {
+	mu      sync.Mutex
+	rows    map[int64]driver.Value
+	queries []string
+	commits int
+	// failCommit makes the commit of this batch, counted from 1, fail
+	failCommit int
+}
+
+type fakeConn struct {
+	t       *fakeTable
+	pending map[int64]driver.Value
+}
+
+type fakeTx struct{ c *fakeConn }
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+type fakeRows struct {
+	keys   []int64
+	values []driver.Value
+	i      int
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.tables == nil {
+		d.tables = make(map[string]*fakeTable)
+	}
+	if d.tables[name] == nil {
+		d.tables[name] = &fakeTable{rows: make(map[int64]driver.Value)}
+	}
+	return &fakeConn{t: d.tables[name]}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if !strings.HasPrefix(query, "SELECT ") && !strings.HasPrefix(query, "UPDATE ") {
+		return nil, errors.New("fake driver: unknown query " + query)
+	}
+	c.t.mu.Lock()
+	c.t.queries = append(c.t.queries, query)
+	c.t.mu.Unlock()
+	return &fakeStmt{c, query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.pending = make(map[int64]driver.Value)
+	return &fakeTx{c}, nil
+}
+
+func (tx *fakeTx) Commit() error {
+	t := tx.c.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.commits++
+	if t.commits == t.failCommit {
+		return errors.New("fake driver: commit failed")
+	}
+	for k, v := range tx.c.pending {
+		t.rows[k] = v
+	}
+	tx.c.pending = nil
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.c.pending = nil
+	return nil
+}
+
+func (s *fakeStmt) Close() error { return nil }
+
+func (s *fakeStmt) NumInput() int {
+	if strings.HasPrefix(s.query, "SELECT ") && !strings.Contains(s.query, " WHERE ") {
+		return 1
+	}
+	return 2
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.c.pending == nil {
+		return nil, errors.New("fake driver: update outside a transaction")
+	}
+	s.c.pending[args[1].(int64)] = args[0]
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.t.mu.Lock()
+	defer s.c.t.mu.Unlock()
+	var after int64 = -1 << 63
+	limit := args[len(args)-1].(int64)
+	if len(args) == 2 {
+		after = args[0].(int64)
+	}
+	rows := &fakeRows{}
+	for k := range s.c.t.rows {
+		if k > after {
+			rows.keys = append(rows.keys, k)
+		}
+	}
+	sort.Slice(rows.keys, func(i, j int) bool { return rows.keys[i] < rows.keys[j] })
+	if int64(len(rows.keys)) > limit {
+		rows.keys = rows.keys[:limit]
+	}
+	for _, k := range rows.keys {
+		rows.values = append(rows.values, s.c.t.rows[k])
+	}
+	return rows, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id", "value"} }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i == len(r.keys) {
+		return io.EOF
+	}
+	dest[0], dest[1] = r.keys[r.i], r.values[r.i]
+	r.i++
+	return nil
+}
+
+var testDriver = &fakeDriver{}
+
+func init() {
+	sql.Register("migrate-fake", testDriver)
+}
+
+var (
+	oldKey = "2B7E151628AED2A6ABF7158809CF4F3C"
+	newKey = "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F"
+)
+
+func newCipher(t *testing.T, key string) *ff1.Cipher {
+	t.Helper()
+	c, err := ff1.NewCipher(10, KeyTweakLen, mustHex(key), nil)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %v", err)
+	}
+	return &c
+}
+
+func mustHex(s string) []byte {
+	b := make([]byte, len(s)/2)
+	if _, err := fmt.Sscanf(s, "%x", &b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// openTable fills a table of n rows, with keys 10, 20, ... and values encrypted
+// with old, and returns the database, the table and the plaintexts by key. Every
+// seventh row is NULL.
+func openTable(t *testing.T, n int, old *ff1.Cipher, tweak func([]byte) []byte) (*sql.DB, *fakeTable, map[int64]string) {
+	t.Helper()
+	db, err := sql.Open("migrate-fake", t.Name())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	table := testDriver.tables[t.Name()]
+
+	plaintexts := make(map[int64]string)
+	for i := 1; i <= n; i++ {
+		key := int64(10 * i)
+		if i%7 == 0 {
+			table.rows[key] = nil
+			continue
+		}
+		X := fmt.Sprintf("%09d", i*7919)
+		var Y []byte
+		if tweak != nil {
+			Y, err = old.EncryptWithTweak([]byte(X), tweak([]byte(fmt.Sprint(key))))
+		} else {
+			Y, err = old.Encrypt([]byte(X))
+		}
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		table.rows[key] = string(Y)
+		plaintexts[key] = X
+	}
+	return db, table, plaintexts
+}
+
+// checkTable checks that the values of the rows with keys in keys decrypt with c
+func checkTable(t *testing.T, table *fakeTable, c *ff1.Cipher, tweak func([]byte) []byte, plaintexts map[int64]string, keys func(int64) bool) {
+	t.Helper()
+	for key, X := range plaintexts {
+		if !keys(key) {
+			continue
+		}
+		Y := []byte(table.rows[key].(string))
+		var got []byte
+		var err error
+		if tweak != nil {
+			got, err = c.DecryptWithTweak(Y, tweak([]byte(fmt.Sprint(key))))
+		} else {
+			got, err = c.Decrypt(Y)
+		}
+		if err != nil || string(got) != X {
+			t.Fatalf("Row %d decrypts to %s, %v expected %s", key, got, err, X)
+		}
+	}
+}
+
+func all(int64) bool { return true }
+
+func TestColumn(t *testing.T) {
+	for idx, testCase := range []struct {
+		rows, batchSize int
+		tweak           func([]byte) []byte
+	}{
+		{0, 10, nil},
+		{1, 10, nil},
+		{9, 10, nil},
+		{10, 10, nil}, // the last batch is empty
+		{11, 10, nil},
+		{95, 10, KeyTweak},
+		{95, 0, nil},
+		{30, 1, KeyTweak},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			old, next := newCipher(t, oldKey), newCipher(t, newKey)
+			db, table, plaintexts := openTable(t, testCase.rows, old, testCase.tweak)
+
+			var calls []Progress
+			progress, err := Column(context.Background(), db, ColumnConfig{
+				Table:     "people",
+				Key:       "id",
+				Column:    "ssn",
+				Old:       old,
+				New:       next,
+				Tweak:     testCase.tweak,
+				BatchSize: testCase.batchSize,
+				Progress:  func(p Progress) { calls = append(calls, p) },
+			})
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			nulls := int64(testCase.rows / 7)
+			if progress.Rows != int64(testCase.rows) || progress.Updated != int64(testCase.rows)-nulls || progress.Skipped != nulls {
+				t.Fatalf("Got %+v for %d rows", progress, testCase.rows)
+			}
+			checkTable(t, table, next, testCase.tweak, plaintexts, all)
+
+			// One call per non-empty batch, with growing totals
+			batchSize := testCase.batchSize
+			if batchSize == 0 {
+				batchSize = DefaultBatchSize
+			}
+			if expected := (testCase.rows + batchSize - 1) / batchSize; len(calls) != expected {
+				t.Fatalf("Progress called %d times, expected %d", len(calls), expected)
+			}
+			for i, p := range calls {
+				rows := int64((i + 1) * batchSize)
+				if rows > int64(testCase.rows) {
+					rows = int64(testCase.rows)
+				}
+				if p.Rows != rows || p.Checkpoint != 10*rows {
+					t.Fatalf("Progress call %d: got %+v, expected %d rows", i, p, rows)
+				}
+			}
+		})
+	}
+}
+
+func TestColumnResume(t *testing.T) {
+	old, next := newCipher(t, oldKey), newCipher(t, newKey)
+	db, table, plaintexts := openTable(t, 50, old, KeyTweak)
+	cfg := ColumnConfig{Table: "t", Key: "id", Column: "v", Old: old, New: next, Tweak: KeyTweak, BatchSize: 8}
+
+	// The third batch fails to commit, the first two stay
+	table.failCommit = 3
+	progress, err := Column(context.Background(), db, cfg)
+	if err == nil {
+		t.Fatalf("The failed commit was not reported")
+	}
+	if progress.Rows != 16 || progress.Checkpoint != int64(160) {
+		t.Fatalf("Got %+v, expected 16 rows up to key 160", progress)
+	}
+	checkTable(t, table, next, KeyTweak, plaintexts, func(key int64) bool { return key <= 160 })
+	checkTable(t, table, old, KeyTweak, plaintexts, func(key int64) bool { return key > 160 })
+
+	// Resumed from the checkpoint, the rows before it are not re-encrypted again
+	cfg.Resume = progress.Checkpoint
+	progress, err = Column(context.Background(), db, cfg)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if progress.Rows != 34 || progress.Checkpoint != int64(500) {
+		t.Fatalf("Got %+v, expected 34 rows up to key 500", progress)
+	}
+	checkTable(t, table, next, KeyTweak, plaintexts, all)
+}
+
+func TestColumnRowErrors(t *testing.T) {
+	old, next := newCipher(t, oldKey), newCipher(t, newKey)
+	db, table, plaintexts := openTable(t, 30, old, nil)
+	table.rows[40] = "not digits"
+	table.rows[250] = "1"
+	delete(plaintexts, 40)
+	delete(plaintexts, 250)
+	cfg := ColumnConfig{Table: "t", Key: "id", Column: "v", Old: old, New: next, BatchSize: 10}
+
+	// The first failure rolls back its batch
+	progress, err := Column(context.Background(), db, cfg)
+	var rowErr RowError
+	if !errors.As(err, &rowErr) || rowErr.Key != int64(40) || !errors.Is(err, ff1.ErrStringNotInRadix) {
+		t.Fatalf("Got %v, expected a RowError for row 40", err)
+	}
+	if progress.Rows != 0 || progress.Checkpoint != nil {
+		t.Fatalf("Got %+v, expected nothing committed", progress)
+	}
+	checkTable(t, table, old, nil, plaintexts, all)
+
+	// Failures are collected, and the other rows re-encrypted
+	cfg.ContinueOnError = true
+	progress, err = Column(context.Background(), db, cfg)
+	var report *Report
+	if !errors.As(err, &report) {
+		t.Fatalf("Got %v, expected a Report", err)
+	}
+	if progress.Failed != 2 || len(report.Errors) != 2 || report.Errors[0].Key != int64(40) || report.Errors[1].Key != int64(250) {
+		t.Fatalf("Got %+v, %v expected rows 40 and 250 to fail", progress, report.Errors)
+	}
+	if progress.Updated != 30-4-2 {
+		t.Fatalf("Got %+v, expected 24 rows updated", progress)
+	}
+	checkTable(t, table, next, nil, plaintexts, all)
+	if table.rows[40] != "not digits" {
+		t.Fatalf("A failed row was changed to %v", table.rows[40])
+	}
+}
+
+func TestColumnQueries(t *testing.T) {
+	old := newCipher(t, oldKey)
+	db, table, _ := openTable(t, 3, old, nil)
+	cfg := ColumnConfig{Table: "t", Key: "id", Column: "v", Old: old, New: old, Placeholder: DollarPlaceholder, BatchSize: 2}
+	if _, err := Column(context.Background(), db, cfg); err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := []string{
+		"SELECT id, v FROM t ORDER BY id LIMIT $1",
+		"UPDATE t SET v = $1 WHERE id = $2",
+		"SELECT id, v FROM t WHERE id > $1 ORDER BY id LIMIT $2",
+		"UPDATE t SET v = $1 WHERE id = $2",
+	}
+	if strings.Join(table.queries, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Got queries\n%s\nexpected\n%s", strings.Join(table.queries, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if _, err := Column(context.Background(), db, ColumnConfig{Table: "t", Key: "id", Column: "v"}); err == nil {
+		t.Fatalf("A config without ciphers was accepted")
+	}
+}
+
+func TestKeyBytes(t *testing.T) {
+	for idx, testCase := range []struct {
+		key      interface{}
+		expected string
+	}{
+		{int64(-42), "-42"},
+		{"abc", "abc"},
+		{[]byte("abc"), "abc"},
+		{3.5, "3.5"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			if got := string(keyBytes(testCase.key)); got != testCase.expected {
+				t.Fatalf("Got %s, expected %s", got, testCase.expected)
+			}
+		})
+	}
+}
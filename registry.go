@@ -0,0 +1,137 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrAlreadyRegistered is returned, wrapped with the name, by Register for a name
+	// that is taken
+	ErrAlreadyRegistered = errors.New("cipher name already registered")
+
+	// ErrNotRegistered is returned, wrapped with the name, by Replace for a name
+	// that is not taken
+	ErrNotRegistered = errors.New("cipher name not registered")
+)
+
+// A Registry maps names to ciphers, so that the parts of an application can share
+// "the SSN cipher" without passing it around. It is safe for concurrent use, and
+// its zero value is an empty Registry ready to use.
+type Registry struct {
+	mu      sync.RWMutex
+	ciphers map[string]Cipher
+}
+
+// Register adds c under name. It fails if the name is empty, c is nil or a nil
+// pointer, or the name is taken already.
+func (r *Registry) Register(name string, c Cipher) error {
+	if err := checkEntry(name, c); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ciphers[name]; ok {
+		return fmt.Errorf("%w: %q", ErrAlreadyRegistered, name)
+	}
+	if r.ciphers == nil {
+		r.ciphers = make(map[string]Cipher)
+	}
+	r.ciphers[name] = c
+	return nil
+}
+
+// Replace puts c under name in place of the cipher registered before, for example
+// after a key rotation, and returns that cipher. Calls to Get that return before
+// Replace does get the old cipher, later ones the new one. It fails if the name is
+// not taken yet.
+func (r *Registry) Replace(name string, c Cipher) (Cipher, error) {
+	if err := checkEntry(name, c); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.ciphers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotRegistered, name)
+	}
+	r.ciphers[name] = c
+	return old, nil
+}
+
+// Get returns the cipher registered under name, and whether there is one.
+func (r *Registry) Get(name string) (Cipher, bool) {
+	r.mu.RLock()
+	c, ok := r.ciphers[name]
+	r.mu.RUnlock()
+	return c, ok
+}
+
+// Names returns the registered names, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.ciphers))
+	for name := range r.ciphers {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func checkEntry(name string, c Cipher) error {
+	if name == "" {
+		return errors.New("cipher name must not be empty")
+	}
+	// A nil pointer in the interface, such as a (*ff1.Cipher)(nil), is nil too
+	if c == nil || reflect.ValueOf(c).Kind() == reflect.Ptr && reflect.ValueOf(c).IsNil() {
+		return fmt.Errorf("cipher %q must not be nil", name)
+	}
+	return nil
+}
+
+// defaultRegistry backs the package-level functions.
+var defaultRegistry Registry
+
+// Register adds c under name to the registry of the application, see Registry.Register.
+func Register(name string, c Cipher) error {
+	return defaultRegistry.Register(name, c)
+}
+
+// Replace replaces the cipher under name in the registry of the application, see
+// Registry.Replace.
+func Replace(name string, c Cipher) (Cipher, error) {
+	return defaultRegistry.Replace(name, c)
+}
+
+// Get returns the cipher registered under name in the registry of the application.
+func Get(name string) (Cipher, bool) {
+	return defaultRegistry.Get(name)
+}
+
+// Names returns the names in the registry of the application, sorted.
+func Names() []string {
+	return defaultRegistry.Names()
+}
@@ -0,0 +1,172 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// reverseCipher is a stand-in Cipher that reverses its input, with an id to tell
+// instances apart.
+type reverseCipher struct {
+	id int
+}
+
+func (r *reverseCipher) Encrypt(X []byte) ([]byte, error) {
+	Y := make([]byte, len(X))
+	for i, b := range X {
+		Y[len(X)-1-i] = b
+	}
+	return Y, nil
+}
+
+func (r *reverseCipher) Decrypt(X []byte) ([]byte, error) {
+	return r.Encrypt(X)
+}
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	if names := r.Names(); len(names) != 0 {
+		t.Fatalf("empty registry has names %q", names)
+	}
+	if _, ok := r.Get("ssn"); ok {
+		t.Fatal("Get on empty registry found a cipher")
+	}
+
+	ssn, pan := &reverseCipher{1}, &reverseCipher{2}
+	if err := r.Register("ssn", ssn); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("pan", pan); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("ssn", pan); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("got %v, want %v", err, ErrAlreadyRegistered)
+	}
+	if c, ok := r.Get("ssn"); !ok || c != ssn {
+		t.Fatalf("Get(ssn) = %v, %v after a rejected Register", c, ok)
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"pan", "ssn"}) {
+		t.Fatalf("Names() = %q", names)
+	}
+
+	rotated := &reverseCipher{3}
+	old, err := r.Replace("ssn", rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != ssn {
+		t.Fatalf("Replace returned %v, want the old cipher", old)
+	}
+	if c, _ := r.Get("ssn"); c != rotated {
+		t.Fatalf("Get(ssn) = %v after Replace", c)
+	}
+	if _, err := r.Replace("iban", rotated); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("got %v, want %v", err, ErrNotRegistered)
+	}
+
+	if err := r.Register("", ssn); err == nil {
+		t.Fatal("empty name accepted")
+	}
+	if err := r.Register("nil", nil); err == nil {
+		t.Fatal("nil cipher accepted")
+	}
+	if _, err := r.Replace("pan", nil); err == nil {
+		t.Fatal("nil cipher accepted by Replace")
+	}
+	if err := r.Register("nil", (*ff1.Cipher)(nil)); err == nil {
+		t.Fatal("nil *ff1.Cipher accepted")
+	}
+	if err := r.Register("nil", (*ff1.Cascade)(nil)); err == nil {
+		t.Fatal("nil *ff1.Cascade accepted")
+	}
+	if _, err := r.Replace("pan", (*ff1.Cipher)(nil)); err == nil {
+		t.Fatal("nil *ff1.Cipher accepted by Replace")
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	c := &reverseCipher{1}
+	if err := Register("TestDefaultRegistry", c); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := Get("TestDefaultRegistry"); !ok || got != c {
+		t.Fatalf("Get = %v, %v", got, ok)
+	}
+	if _, err := Replace("TestDefaultRegistry", &reverseCipher{2}); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, name := range Names() {
+		found = found || name == "TestDefaultRegistry"
+	}
+	if !found {
+		t.Fatalf("Names() = %q", Names())
+	}
+}
+
+// Concurrent Register, Get and Replace; run with -race
+func TestRegistryConcurrent(t *testing.T) {
+	var r Registry
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				name := fmt.Sprintf("field%d", i)
+				err := r.Register(name, &reverseCipher{g})
+				if err != nil && !errors.Is(err, ErrAlreadyRegistered) {
+					errs <- err
+					return
+				}
+				if _, err := r.Replace(name, &reverseCipher{g}); err != nil {
+					errs <- err
+					return
+				}
+				c, ok := r.Get(name)
+				if !ok {
+					errs <- fmt.Errorf("%s missing after Register", name)
+					return
+				}
+				if got, err := c.Encrypt([]byte("abc")); err != nil || string(got) != "cba" {
+					errs <- fmt.Errorf("Encrypt = %s, %v", got, err)
+					return
+				}
+				r.Names()
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if n := len(r.Names()); n != 100 {
+		t.Fatalf("%d names, want 100", n)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnbound is returned by the methods of an FPEString or EncryptedValue that
+	// is not bound to a cipher
+	ErrUnbound = errors.New("value is not bound to a cipher")
+
+	// ErrValueTooShort is returned, wrapped with the lengths, for a non-empty value
+	// shorter than the cipher accepts
+	ErrValueTooShort = errors.New("value too short for cipher")
+)
+
+// FPEString is a nullable string column that is encrypted with a format-preserving
+// cipher on its way into the database and decrypted on its way out. It implements
+// driver.Valuer and sql.Scanner, so a struct field of this type can be passed to
+// Exec and Scan like a sql.NullString. The plaintext is in String, and Valid is
+// false for NULL.
+//
+// Before use the FPEString must be bound, either to a cipher with Bind or to the
+// name of a cipher in the registry of the application with BindName. A name is
+// looked up on every call, so a Replace in the registry takes effect at once.
+//
+// NULL stays NULL and the empty string stays empty, neither is encrypted. Other
+// values shorter than the MinLen of the cipher, if it has that method, fail with
+// ErrValueTooShort rather than being passed on.
+type FPEString struct {
+	String string
+	Valid  bool // Valid is true if String is not NULL
+
+	binding
+}
+
+// Value encrypts String, see driver.Valuer.
+func (s FPEString) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	c, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+	Y, err := cryptValue(c, []byte(s.String), true)
+	if err != nil {
+		return nil, err
+	}
+	return Y, nil
+}
+
+// Scan decrypts a string or []byte from the database into String, see sql.Scanner.
+// NULL sets Valid to false, whether s is bound or not. On error s is set to NULL.
+func (s *FPEString) Scan(src interface{}) error {
+	s.String, s.Valid = "", false
+	// NULL needs no cipher, so it scans even if s is not bound
+	if src == nil {
+		return nil
+	}
+	c, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	var X []byte
+	switch v := src.(type) {
+	case string:
+		X = []byte(v)
+	case []byte:
+		X = v
+	default:
+		return fmt.Errorf("fpe: cannot scan %T into FPEString", src)
+	}
+	Y, err := cryptValue(c, X, false)
+	if err != nil {
+		return err
+	}
+	s.String, s.Valid = Y, true
+	return nil
+}
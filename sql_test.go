@@ -0,0 +1,318 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+// fakeDriver is a database/sql driver with one single-column table per data source
+// name. "INSERT" appends its argument, "SELECT" returns all rows.
+type fakeDriver struct {
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+}
+
+type fakeTable struct {
+	mu   sync.Mutex
+	rows []driver.Value
+}
+
+type fakeConn struct{ t *fakeTable }
+
+type fakeStmt struct {
+	t     *fakeTable
+	query string
+}
+
+type fakeRows struct {
+	rows []driver.Value
+	i    int
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.tables == nil {
+		d.tables = make(map[string]*fakeTable)
+	}
+	if d.tables[name] == nil {
+		d.tables[name] = &fakeTable{}
+	}
+	return &fakeConn{d.tables[name]}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if query != "INSERT" && query != "SELECT" {
+		return nil, errors.New("fake driver: unknown query " + query)
+	}
+	return &fakeStmt{c.t, query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake driver: no transactions")
+}
+
+func (s *fakeStmt) Close() error { return nil }
+
+func (s *fakeStmt) NumInput() int {
+	if s.query == "INSERT" {
+		return 1
+	}
+	return 0
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.t.mu.Lock()
+	s.t.rows = append(s.t.rows, args[0])
+	s.t.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	return &fakeRows{rows: append([]driver.Value{}, s.t.rows...)}, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"v"} }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i == len(r.rows) {
+		return io.EOF
+	}
+	dest[0] = r.rows[r.i]
+	r.i++
+	return nil
+}
+
+var testDriver = &fakeDriver{}
+
+func init() {
+	sql.Register("fpe-fake", testDriver)
+}
+
+// openFake opens an empty table of the fake driver for t.
+func openFake(t *testing.T) (*sql.DB, *fakeTable) {
+	t.Helper()
+	db, err := sql.Open("fpe-fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	testDriver.mu.Lock()
+	defer testDriver.mu.Unlock()
+	return db, testDriver.tables[t.Name()]
+}
+
+func newDigitsCipher(t *testing.T) *ff1.Cipher {
+	t.Helper()
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	c, err := ff1.NewCipher(10, 0, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+// selectAll scans all rows of db into FPEStrings bound by bind.
+func selectAll(t *testing.T, db *sql.DB, bind func(*FPEString)) ([]FPEString, error) {
+	t.Helper()
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []FPEString
+	for rows.Next() {
+		var s FPEString
+		bind(&s)
+		if err := rows.Scan(&s); err != nil {
+			return got, err
+		}
+		got = append(got, s)
+	}
+	return got, rows.Err()
+}
+
+func TestFPEString(t *testing.T) {
+	db, table := openFake(t)
+	c := newDigitsCipher(t)
+
+	values := []FPEString{
+		{String: "123456789", Valid: true},
+		{String: "", Valid: true},
+		{},
+		{String: "0123456789012345", Valid: true},
+	}
+	for _, v := range values {
+		v.Bind(c)
+		if _, err := db.Exec("INSERT", v); err != nil {
+			t.Fatalf("insert %q: %v", v.String, err)
+		}
+	}
+
+	// The table holds ciphertexts, NULL and the empty string
+	for i, stored := range table.rows {
+		switch {
+		case !values[i].Valid:
+			if stored != nil {
+				t.Errorf("row %d: NULL stored as %v", i, stored)
+			}
+		case values[i].String == "":
+			if stored != "" {
+				t.Errorf("row %d: empty string stored as %v", i, stored)
+			}
+		default:
+			want, _ := c.Encrypt([]byte(values[i].String))
+			if stored != string(want) {
+				t.Errorf("row %d: stored %v, want %s", i, stored, want)
+			}
+		}
+	}
+
+	got, err := selectAll(t, db, func(s *FPEString) { s.Bind(c) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(values) {
+		t.Fatalf("got %d rows, want %d", len(got), len(values))
+	}
+	for i := range values {
+		if got[i].String != values[i].String || got[i].Valid != values[i].Valid {
+			t.Errorf("row %d: got %q/%v, want %q/%v", i, got[i].String, got[i].Valid, values[i].String, values[i].Valid)
+		}
+	}
+}
+
+func TestFPEStringBindName(t *testing.T) {
+	db, table := openFake(t)
+	c := newDigitsCipher(t)
+	if err := Register("TestFPEStringBindName", c); err != nil {
+		t.Fatal(err)
+	}
+
+	v := FPEString{String: "555123456", Valid: true}
+	v.BindName("TestFPEStringBindName")
+	if _, err := db.Exec("INSERT", v); err != nil {
+		t.Fatal(err)
+	}
+	if table.rows[0] == v.String {
+		t.Fatal("value stored in the clear")
+	}
+	// A []byte from the database decrypts too
+	if _, err := db.Exec("INSERT", []byte(table.rows[0].(string))); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := selectAll(t, db, func(s *FPEString) { s.BindName("TestFPEStringBindName") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range got {
+		if s.String != v.String || !s.Valid {
+			t.Errorf("row %d: got %q/%v, want %q", i, s.String, s.Valid, v.String)
+		}
+	}
+}
+
+func TestFPEStringError(t *testing.T) {
+	db, _ := openFake(t)
+	c := newDigitsCipher(t)
+
+	// Too short, not in the alphabet, unbound and unregistered values fail on insert
+	for _, test := range []struct {
+		v    FPEString
+		bind func(*FPEString)
+		want error
+	}{
+		{FPEString{String: "1", Valid: true}, func(s *FPEString) { s.Bind(c) }, ErrValueTooShort},
+		{FPEString{String: "123456x", Valid: true}, func(s *FPEString) { s.Bind(c) }, nil},
+		{FPEString{String: "123456", Valid: true}, func(s *FPEString) {}, ErrUnbound},
+		{FPEString{String: "123456", Valid: true}, func(s *FPEString) { s.BindName("TestFPEStringError") }, ErrNotRegistered},
+	} {
+		test.bind(&test.v)
+		_, err := db.Exec("INSERT", test.v)
+		if err == nil || (test.want != nil && !errors.Is(err, test.want)) {
+			t.Errorf("insert %q: got %v, want %v", test.v.String, err, test.want)
+		}
+	}
+
+	// A short or invalid value in the database fails on scan
+	if _, err := db.Exec("INSERT", "7"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := selectAll(t, db, func(s *FPEString) { s.Bind(c) })
+	if !errors.Is(err, ErrValueTooShort) {
+		t.Fatalf("scan of short value: got %v, want %v", err, ErrValueTooShort)
+	}
+	if !strings.Contains(err.Error(), "Scan error") {
+		t.Fatalf("scan error %q not reported by database/sql", err)
+	}
+
+	var s FPEString
+	s.Bind(c)
+	if err := s.Scan(42); err == nil || s.Valid {
+		t.Fatalf("Scan(42) = %v, valid %v", err, s.Valid)
+	}
+	if err := s.Scan("abcdefgh"); err == nil || s.Valid {
+		t.Fatalf("Scan of invalid ciphertext = %v, valid %v", err, s.Valid)
+	}
+}
+
+func TestFPEStringNullUnbound(t *testing.T) {
+	db, _ := openFake(t)
+	if _, err := db.Exec("INSERT", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// NULL needs no cipher
+	got, err := selectAll(t, db, func(s *FPEString) { s.String, s.Valid = "stale", true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Valid || got[0].String != "" {
+		t.Fatalf("got %+v, want one NULL", got)
+	}
+
+	// Other values do
+	if _, err := db.Exec("INSERT", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := selectAll(t, db, func(s *FPEString) {}); !errors.Is(err, ErrUnbound) {
+		t.Fatalf("got %v, want %v", err, ErrUnbound)
+	}
+}
@@ -0,0 +1,390 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A FieldError is the failure of one field of EncryptStruct or DecryptStruct.
+// Path names the field from the outermost struct, such as
+// Customer.Accounts[2].Number.
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// A StructError is returned by EncryptStruct and DecryptStruct if fields failed.
+// The other fields are encrypted or decrypted regardless.
+type StructError struct {
+	Fields []FieldError
+}
+
+func (e *StructError) Error() string {
+	if len(e.Fields) == 1 {
+		return fmt.Sprintf("fpe: field %v", e.Fields[0])
+	}
+	return fmt.Sprintf("fpe: %d fields failed, the first %v", len(e.Fields), e.Fields[0])
+}
+
+// EncryptStruct encrypts the fields of the struct ptr points to that have an fpe
+// tag, with the ciphers of the registry of the application, see
+// Registry.EncryptStruct.
+func EncryptStruct(ptr interface{}) error {
+	return defaultRegistry.EncryptStruct(ptr)
+}
+
+// DecryptStruct is the inverse of EncryptStruct.
+func DecryptStruct(ptr interface{}) error {
+	return defaultRegistry.DecryptStruct(ptr)
+}
+
+// EncryptStruct encrypts the fields of the struct ptr points to that have an fpe
+// tag, in place, with the ciphers of r:
+//
+//	type Customer struct {
+//		SSN      string    `fpe:"cipher=ssn"`
+//		Card     []byte    `fpe:"cipher=pan,keep_last=4"`
+//		Accounts []Account // fields of nested structs are found too
+//	}
+//
+// The tag names the cipher and optionally a number of bytes to leave in the clear
+// at the start, keep_first, or end, keep_last. Tagged fields must be exported and
+// of type string, []byte, *string or []string; empty values, values no longer
+// than the bytes to keep, and nil pointers are left as they are. Untagged fields that are structs, pointers to them, or slices
+// and arrays of either are searched for tagged fields, maps and interfaces are not.
+// A struct reached through several pointers is encrypted once.
+//
+// Tags that cannot be parsed, tags on fields of other types, and cipher names
+// that are not registered are errors before any field is changed, even if the
+// struct has no value they would apply to. Failures of single values, such as
+// ones outside the alphabet or too short, are collected in a *StructError with the
+// paths of the fields, and the other fields are encrypted anyway.
+func (r *Registry) EncryptStruct(ptr interface{}) error {
+	return r.cryptStruct(ptr, true)
+}
+
+// DecryptStruct is the inverse of EncryptStruct.
+func (r *Registry) DecryptStruct(ptr interface{}) error {
+	return r.cryptStruct(ptr, false)
+}
+
+// fieldTag is a parsed fpe tag.
+type fieldTag struct {
+	cipher    string
+	keepFirst int
+	keepLast  int
+}
+
+// fieldPlan is a field of a struct that has an fpe tag or may contain fields that do.
+type fieldPlan struct {
+	index int
+	name  string
+	tag   *fieldTag // nil for fields that are searched
+}
+
+// structPlan is what EncryptStruct does with a struct type.
+type structPlan struct {
+	fields []fieldPlan
+	err    error
+	// search is set if the type holds fpe tags, or may, while it is being planned
+	search bool
+}
+
+var (
+	plansMu sync.Mutex
+	plans   = make(map[reflect.Type]*structPlan)
+)
+
+// planFor returns the plan of the struct type t.
+func planFor(t reflect.Type) *structPlan {
+	plansMu.Lock()
+	defer plansMu.Unlock()
+	return buildPlan(t)
+}
+
+// buildPlan returns the plan of t, building it if needed. plansMu must be held.
+// A type that refers to itself sees its plan while it is built, with search set,
+// so that it is searched in any case.
+func buildPlan(t reflect.Type) *structPlan {
+	if p, ok := plans[t]; ok {
+		return p
+	}
+	p := &structPlan{search: true}
+	plans[t] = p
+
+	search := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("fpe")
+		if ok && tag != "-" {
+			parsed, err := parseTag(tag)
+			if err == nil {
+				err = checkTaggedType(f)
+			}
+			if err != nil {
+				p.err = fmt.Errorf("fpe: field %s.%s: %w", t.Name(), f.Name, err)
+				return p
+			}
+			p.fields = append(p.fields, fieldPlan{index: i, name: f.Name, tag: parsed})
+			search = true
+			continue
+		}
+		// Exported fields of embedded structs can be set even if the struct type is
+		// not exported
+		embedded := f.Anonymous && f.Type.Kind() == reflect.Struct
+		if ok || !f.IsExported() && !embedded || !searchable(f.Type) {
+			continue
+		}
+		p.fields = append(p.fields, fieldPlan{index: i, name: f.Name})
+		search = true
+	}
+	p.search = search
+	return p
+}
+
+// searchable reports whether values of t may contain fields with fpe tags.
+// plansMu must be held.
+func searchable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		p := buildPlan(t)
+		return p.search || p.err != nil
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return searchable(t.Elem())
+	}
+	return false
+}
+
+// parseTag parses the value of an fpe tag.
+func parseTag(tag string) (*fieldTag, error) {
+	var parsed fieldTag
+	for _, option := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return nil, fmt.Errorf("tag option %q is not key=value", option)
+		}
+		switch key {
+		case "cipher":
+			parsed.cipher = value
+		case "keep_first", "keep_last":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("tag option %s=%s is not a count of bytes", key, value)
+			}
+			if key == "keep_first" {
+				parsed.keepFirst = n
+			} else {
+				parsed.keepLast = n
+			}
+		default:
+			return nil, fmt.Errorf("unknown tag option %q, expected cipher, keep_first or keep_last", key)
+		}
+	}
+	if parsed.cipher == "" {
+		return nil, fmt.Errorf("tag %q names no cipher, add cipher=<name>", tag)
+	}
+	return &parsed, nil
+}
+
+// checkTaggedType checks that a field with an fpe tag can be encrypted.
+func checkTaggedType(f reflect.StructField) error {
+	if !f.IsExported() {
+		return fmt.Errorf("has an fpe tag but is not exported")
+	}
+	t := f.Type
+	switch {
+	case t.Kind() == reflect.String,
+		t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8,
+		t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.String,
+		t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		return nil
+	}
+	return fmt.Errorf("has an fpe tag but type %s, only string, []byte, *string and []string can be encrypted", t)
+}
+
+// structCrypt is the state of one EncryptStruct or DecryptStruct.
+type structCrypt struct {
+	ciphers map[string]Cipher
+	encrypt bool
+	seen    map[uintptr]map[reflect.Type]bool
+	errs    []FieldError
+}
+
+func (r *Registry) cryptStruct(ptr interface{}, encrypt bool) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("fpe: a non-nil pointer to a struct is needed, got %T", ptr)
+	}
+	t := v.Elem().Type()
+
+	// All problems of the types first, then the ciphers all of them name
+	plansMu.Lock()
+	buildPlan(t)
+	names := make(map[string]bool)
+	err := collectNames(t, names, make(map[reflect.Type]bool))
+	plansMu.Unlock()
+	if err != nil {
+		return err
+	}
+	sc := &structCrypt{
+		ciphers: make(map[string]Cipher, len(names)),
+		encrypt: encrypt,
+		seen:    make(map[uintptr]map[reflect.Type]bool),
+	}
+	for _, name := range sortedKeys(names) {
+		c, ok := r.Get(name)
+		if !ok {
+			return fmt.Errorf("fpe: %w: %q, used by an fpe tag of %s or a struct it contains", ErrNotRegistered, name, t)
+		}
+		sc.ciphers[name] = c
+	}
+
+	sc.walk(v, t.Name())
+	if len(sc.errs) > 0 {
+		return &StructError{Fields: sc.errs}
+	}
+	return nil
+}
+
+// collectNames adds the cipher names of the tags of t and the types it contains to
+// names, and returns the first error of their plans. plansMu must be held.
+func collectNames(t reflect.Type, names map[string]bool, done map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || done[t] {
+		return nil
+	}
+	done[t] = true
+	p := buildPlan(t)
+	if p.err != nil {
+		return p.err
+	}
+	for _, f := range p.fields {
+		if f.tag != nil {
+			names[f.tag.cipher] = true
+			continue
+		}
+		if err := collectNames(t.Field(f.index).Type, names, done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// walk encrypts or decrypts the tagged fields within v, a value of a searchable
+// type, whose path is path.
+func (sc *structCrypt) walk(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		// The same struct may be reached through several pointers, and must only
+		// be encrypted once. Pointers to a struct and to its first field share the
+		// address, so the type is part of the key.
+		addr, t := v.Pointer(), v.Type().Elem()
+		if sc.seen[addr][t] {
+			return
+		}
+		if sc.seen[addr] == nil {
+			sc.seen[addr] = make(map[reflect.Type]bool)
+		}
+		sc.seen[addr][t] = true
+		sc.walk(v.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			sc.walk(v.Index(i), path+"["+strconv.Itoa(i)+"]")
+		}
+	case reflect.Struct:
+		plansMu.Lock()
+		p := buildPlan(v.Type())
+		plansMu.Unlock()
+		for _, f := range p.fields {
+			fv := v.Field(f.index)
+			if f.tag == nil {
+				sc.walk(fv, path+"."+f.name)
+				continue
+			}
+			sc.field(fv, path+"."+f.name, f.tag)
+		}
+	}
+}
+
+// field encrypts or decrypts the value of a tagged field.
+func (sc *structCrypt) field(v reflect.Value, path string, tag *fieldTag) {
+	switch {
+	case v.Kind() == reflect.String:
+		if s, ok := sc.value(path, tag, []byte(v.String())); ok {
+			v.SetString(s)
+		}
+	case v.Kind() == reflect.Ptr:
+		if !v.IsNil() {
+			sc.field(v.Elem(), path, tag)
+		}
+	case v.Type().Elem().Kind() == reflect.Uint8:
+		// The result has the same length, so the slice is updated in place
+		if s, ok := sc.value(path, tag, v.Bytes()); ok {
+			reflect.Copy(v, reflect.ValueOf(s))
+		}
+	default: // []string
+		for i := 0; i < v.Len(); i++ {
+			sc.field(v.Index(i), path+"["+strconv.Itoa(i)+"]", tag)
+		}
+	}
+}
+
+// value returns X encrypted or decrypted apart from the bytes tag keeps, and
+// whether that succeeded.
+func (sc *structCrypt) value(path string, tag *fieldTag, X []byte) (string, bool) {
+	if tag.keepFirst+tag.keepLast >= len(X) {
+		return "", false
+	}
+	middle := X[tag.keepFirst : len(X)-tag.keepLast]
+	Y, err := cryptValue(sc.ciphers[tag.cipher], middle, sc.encrypt)
+	if err != nil {
+		sc.errs = append(sc.errs, FieldError{Path: path, Err: err})
+		return "", false
+	}
+	return string(X[:tag.keepFirst]) + Y + string(X[len(X)-tag.keepLast:]), true
+}
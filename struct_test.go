@@ -0,0 +1,265 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+type Address struct {
+	Street string `fpe:"cipher=name"`
+	City   string // not encrypted
+}
+
+type Account struct {
+	Number  string `fpe:"cipher=digits"`
+	Balance int
+}
+
+type contact struct {
+	Phone string `fpe:"cipher=digits,keep_first=3"`
+}
+
+type Customer struct {
+	ID       int
+	Name     string  `fpe:"cipher=name"`
+	SSN      string  `fpe:"cipher=digits"`
+	Card     []byte  `fpe:"cipher=digits,keep_last=4"`
+	Nickname *string `fpe:"cipher=name"`
+	Emails   []string
+	Codes    []string `fpe:"cipher=digits"`
+	Ignored  string   `fpe:"-"`
+	Home     Address
+	Work     *Address
+	Accounts []Account
+	Previous []*Account
+	Pairs    [2]Account
+	contact
+}
+
+func newStructRegistry(t *testing.T) *Registry {
+	t.Helper()
+	var r Registry
+	for name, alphabet := range map[string]string{
+		"digits": "0123456789",
+		"name":   " ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	} {
+		c, err := ff1.NewCipherWithAlphabet([]byte(alphabet), 0, mustHexKey, nil)
+		if err != nil {
+			t.Fatalf("Unable to create cipher: %v", err)
+		}
+		if err := r.Register(name, c); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	return &r
+}
+
+var mustHexKey = []byte{0x2B, 0x7E, 0x15, 0x16, 0x28, 0xAE, 0xD2, 0xA6, 0xAB, 0xF7, 0x15, 0x88, 0x09, 0xCF, 0x4F, 0x3C}
+
+func newCustomer() *Customer {
+	nickname := "Bobby"
+	shared := &Account{Number: "5555555555"}
+	return &Customer{
+		ID:       7,
+		Name:     "Robert Smith",
+		SSN:      "123456789",
+		Card:     []byte("4111111111111111"),
+		Nickname: &nickname,
+		Emails:   []string{"bob@example.com"},
+		Codes:    []string{"1234", "", "98765"},
+		Ignored:  "0000000000",
+		Home:     Address{Street: "Main Street", City: "Springfield"},
+		Accounts: []Account{{Number: "0001112222", Balance: 10}, {Number: "0003334444"}},
+		Previous: []*Account{shared, nil, shared},
+		Pairs:    [2]Account{{Number: "77"}, {}},
+		contact:  contact{Phone: "5551234567"},
+	}
+}
+
+func TestEncryptStruct(t *testing.T) {
+	r := newStructRegistry(t)
+	c := newCustomer()
+	original := newCustomer()
+	if err := r.EncryptStruct(c); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for idx, testCase := range []struct {
+		path      string
+		got, orig string
+		encrypted bool
+	}{
+		{"Name", c.Name, original.Name, true},
+		{"SSN", c.SSN, original.SSN, true},
+		{"Card", string(c.Card[:12]), string(original.Card[:12]), true},
+		{"Card kept", string(c.Card[12:]), string(original.Card[12:]), false},
+		{"Nickname", *c.Nickname, *original.Nickname, true},
+		{"Emails", c.Emails[0], original.Emails[0], false},
+		{"Codes[0]", c.Codes[0], original.Codes[0], true},
+		{"Codes[1]", c.Codes[1], original.Codes[1], false},
+		{"Ignored", c.Ignored, original.Ignored, false},
+		{"Home.Street", c.Home.Street, original.Home.Street, true},
+		{"Home.City", c.Home.City, original.Home.City, false},
+		{"Accounts[1].Number", c.Accounts[1].Number, original.Accounts[1].Number, true},
+		{"Previous[0].Number", c.Previous[0].Number, original.Previous[0].Number, true},
+		{"Pairs[0].Number", c.Pairs[0].Number, original.Pairs[0].Number, true},
+		{"Phone kept", c.Phone[:3], original.Phone[:3], false},
+		{"Phone", c.Phone[3:], original.Phone[3:], true},
+	} {
+		if (testCase.got != testCase.orig) != testCase.encrypted || len(testCase.got) != len(testCase.orig) {
+			t.Errorf("Sample%d: %s is %q, from %q", idx+1, testCase.path, testCase.got, testCase.orig)
+		}
+	}
+
+	// The shared account is encrypted once, so it decrypts
+	if err := r.DecryptStruct(c); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(c, original) {
+		t.Fatalf("Got %+v, expected %+v", c, original)
+	}
+}
+
+func TestEncryptStructFieldErrors(t *testing.T) {
+	r := newStructRegistry(t)
+	c := newCustomer()
+	c.SSN = "12345678X"
+	c.Accounts[1].Number = "7"
+	c.Previous = append(c.Previous, &Account{Number: "12-34"})
+
+	err := r.EncryptStruct(c)
+	var structErr *StructError
+	if !errors.As(err, &structErr) {
+		t.Fatalf("Got %v, expected a StructError", err)
+	}
+	var paths []string
+	for _, f := range structErr.Fields {
+		paths = append(paths, f.Path)
+	}
+	expected := "Customer.SSN Customer.Accounts[1].Number Customer.Previous[3].Number"
+	if strings.Join(paths, " ") != expected {
+		t.Fatalf("Failed fields %v, expected %s", paths, expected)
+	}
+	if !errors.Is(structErr.Fields[1], ErrValueTooShort) || !errors.Is(structErr.Fields[0], ff1.ErrStringNotInRadix) {
+		t.Fatalf("Got %v", err)
+	}
+
+	// The other fields are encrypted anyway
+	if c.Name == newCustomer().Name || c.Accounts[0].Number == newCustomer().Accounts[0].Number {
+		t.Fatalf("Fields without errors were not encrypted")
+	}
+}
+
+type badKind struct {
+	Age int `fpe:"cipher=digits"`
+}
+
+type badOption struct {
+	SSN string `fpe:"cipher=digits,mask=4"`
+}
+
+type noCipher struct {
+	SSN string `fpe:"keep_last=4"`
+}
+
+type unexportedTag struct {
+	ssn string `fpe:"cipher=digits"`
+}
+
+type unknownName struct {
+	SSN string `fpe:"cipher=ssn"`
+}
+
+// Errors of nested types are found although the slice is empty
+type nestedUnknown struct {
+	Name  string `fpe:"cipher=name"`
+	Items []unknownName
+}
+
+type Node struct {
+	Value string `fpe:"cipher=digits"`
+	Next  *Node
+}
+
+func TestEncryptStructConfigErrors(t *testing.T) {
+	r := newStructRegistry(t)
+	for idx, testCase := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{&badKind{Age: 42}, "field badKind.Age: has an fpe tag but type int"},
+		{&badOption{}, `unknown tag option "mask"`},
+		{&noCipher{}, "names no cipher"},
+		{&unexportedTag{}, "is not exported"},
+		{&unknownName{SSN: "123456789"}, `cipher name not registered: "ssn"`},
+		{&nestedUnknown{Name: "Robert"}, `cipher name not registered: "ssn"`},
+		{newCustomer(), ""},
+		{*newCustomer(), "a non-nil pointer to a struct is needed, got fpe.Customer"},
+		{(*Customer)(nil), "a non-nil pointer to a struct is needed"},
+		{new(string), "a non-nil pointer to a struct is needed"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			err := r.EncryptStruct(testCase.value)
+			if testCase.expected == "" && err != nil || testCase.expected != "" && (err == nil || !strings.Contains(err.Error(), testCase.expected)) {
+				t.Fatalf("Got %v, expected %q", err, testCase.expected)
+			}
+		})
+	}
+
+	// Nothing is changed before the error
+	v := &nestedUnknown{Name: "Robert"}
+	r.EncryptStruct(v)
+	if v.Name != "Robert" {
+		t.Fatalf("Name changed to %q", v.Name)
+	}
+}
+
+func TestEncryptStructRecursive(t *testing.T) {
+	r := newStructRegistry(t)
+	list := &Node{Value: "1111", Next: &Node{Value: "2222", Next: &Node{Value: "3333"}}}
+	// A cycle is walked once
+	list.Next.Next.Next = list
+	if err := r.EncryptStruct(list); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if list.Value == "1111" || list.Next.Value == "2222" || list.Next.Next.Value == "3333" {
+		t.Fatalf("Not every node was encrypted")
+	}
+	if err := r.DecryptStruct(list); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if list.Value != "1111" || list.Next.Value != "2222" || list.Next.Next.Value != "3333" {
+		t.Fatalf("Got %s %s %s", list.Value, list.Next.Value, list.Next.Next.Value)
+	}
+}
+
+func TestEncryptStructDefaultRegistry(t *testing.T) {
+	v := &unknownName{SSN: "123456789"}
+	if err := EncryptStruct(v); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("Got %v, expected ErrNotRegistered", err)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"text/template"
+)
+
+// TemplateFuncs returns functions for text/template and html/template that encrypt
+// and decrypt with the ciphers of r, or of the registry of the application if r is
+// nil:
+//
+//	{{ fpeEncrypt "ssn" .SSN }}
+//	{{ fpeDecrypt "ssn" .MaskedSSN }}
+//	{{ fpe "ssn" .SSN }}
+//
+// fpe is short for fpeEncrypt. The cipher is looked up by name on every call. The
+// value may be a string or []byte, or an integer or floating-point number, which
+// is formatted in decimal without exponent, such as 1234 or 12.5. Failures, such
+// as an unknown name or a value outside the alphabet, end the execution of the
+// template with an error, as for any template function.
+func TemplateFuncs(r *Registry) template.FuncMap {
+	if r == nil {
+		r = &defaultRegistry
+	}
+	encrypt := func(name string, value interface{}) (string, error) {
+		return templateCrypt(r, name, value, true)
+	}
+	return template.FuncMap{
+		"fpe":        encrypt,
+		"fpeEncrypt": encrypt,
+		"fpeDecrypt": func(name string, value interface{}) (string, error) {
+			return templateCrypt(r, name, value, false)
+		},
+	}
+}
+
+func templateCrypt(r *Registry, name string, value interface{}, encrypt bool) (string, error) {
+	c, ok := r.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNotRegistered, name)
+	}
+	s, err := templateString(value)
+	if err != nil {
+		return "", err
+	}
+	return cryptValue(c, []byte(s), encrypt)
+}
+
+// templateString formats value for templateCrypt.
+func templateString(value interface{}) (string, error) {
+	if b, ok := value.([]byte); ok {
+		return string(b), nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("fpe: cannot encrypt a value of type %T", value)
+}
@@ -0,0 +1,122 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/Tensai75/go-fpe-bytes/ff1"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	c := newDigitsCipher(t)
+	var r Registry
+	if err := r.Register("digits", c); err != nil {
+		t.Fatal(err)
+	}
+	enc := func(s string) string {
+		Y, err := c.Encrypt([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(Y)
+	}
+	data := map[string]interface{}{
+		"SSN":   "123456789",
+		"Bytes": []byte("4444"),
+		"Int":   1234567,
+		"Uint":  uint8(42),
+		"Float": 1e6,
+		"Empty": "",
+	}
+
+	for idx, test := range []struct {
+		tmpl string
+		want string
+	}{
+		{`{{ fpeEncrypt "digits" .SSN }}`, enc("123456789")},
+		{`{{ fpe "digits" .SSN }}`, enc("123456789")},
+		{`{{ fpe "digits" .Bytes }}`, enc("4444")},
+		{`{{ fpe "digits" .Int }}/{{ fpe "digits" .Uint }}/{{ fpe "digits" .Float }}`, enc("1234567") + "/" + enc("42") + "/" + enc("1000000")},
+		{`{{ fpe "digits" 31337 }}`, enc("31337")},
+		{`{{ fpeEncrypt "digits" .SSN | fpeDecrypt "digits" }}`, "123456789"},
+		{`[{{ fpe "digits" .Empty }}]`, "[]"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(&r)).Parse(test.tmpl))
+			var out strings.Builder
+			if err := tmpl.Execute(&out, data); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if out.String() != test.want {
+				t.Fatalf("got %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFuncsError(t *testing.T) {
+	var r Registry
+	if err := r.Register("digits", newDigitsCipher(t)); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"SSN": "12a456789", "Float": 12.5, "Flag": true, "Short": "1"}
+
+	for idx, test := range []struct {
+		tmpl string
+		want error
+		msg  string
+	}{
+		{`{{ fpe "ssn" "123456" }}`, ErrNotRegistered, `"ssn"`},
+		{`{{ fpeEncrypt "digits" .SSN }}`, ff1.ErrStringNotInRadix, "fpeEncrypt"},
+		{`{{ fpeDecrypt "digits" .SSN }}`, ff1.ErrStringNotInRadix, "fpeDecrypt"},
+		{`{{ fpe "digits" .Float }}`, ff1.ErrStringNotInRadix, ""},
+		{`{{ fpe "digits" .Short }}`, ErrValueTooShort, ""},
+		{`{{ fpe "digits" .Flag }}`, nil, "type bool"},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(&r)).Parse(test.tmpl))
+			var out strings.Builder
+			err := tmpl.Execute(&out, data)
+			if err == nil {
+				t.Fatalf("Execute succeeded with %q", out.String())
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+			if !strings.Contains(err.Error(), test.msg) {
+				t.Fatalf("error %q does not mention %q", err, test.msg)
+			}
+		})
+	}
+
+	// Without a registry the one of the application is used
+	if err := Register("TestTemplateFuncsError", newDigitsCipher(t)); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(nil)).Parse(`{{ fpe "TestTemplateFuncsError" "123" }}`))
+	if err := tmpl.Execute(&strings.Builder{}, nil); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+}
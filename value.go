@@ -0,0 +1,87 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+// EncryptedValue holds a plaintext string in memory and presents it as its
+// ciphertext to everything that uses the encoding.TextMarshaler and
+// encoding.TextUnmarshaler interfaces, such as encoding/json, encoding/xml,
+// YAML encoders and the flag package. A configuration struct can thus keep a
+// field in the clear while its files only ever hold the ciphertext.
+//
+// The value must be bound to a cipher, with Bind or BindName as for FPEString,
+// before it is marshalled or unmarshalled into. Unmarshalling into a struct keeps
+// the bindings of its fields, so bind them first and decode after. Without a
+// binding MarshalText, UnmarshalText and Ciphertext return ErrUnbound, they do
+// not panic. The empty string is not encrypted, and other plaintexts shorter
+// than the MinLen of the cipher fail with ErrValueTooShort.
+type EncryptedValue struct {
+	plaintext string
+	binding
+}
+
+// NewEncryptedValue returns an EncryptedValue holding plaintext, bound to c.
+func NewEncryptedValue(c Cipher, plaintext string) EncryptedValue {
+	v := EncryptedValue{plaintext: plaintext}
+	v.Bind(c)
+	return v
+}
+
+// Plaintext returns the plaintext.
+func (v EncryptedValue) Plaintext() string {
+	return v.plaintext
+}
+
+// SetPlaintext replaces the plaintext.
+func (v *EncryptedValue) SetPlaintext(plaintext string) {
+	v.plaintext = plaintext
+}
+
+// Ciphertext encrypts the plaintext.
+func (v EncryptedValue) Ciphertext() (string, error) {
+	c, err := v.resolve()
+	if err != nil {
+		return "", err
+	}
+	return cryptValue(c, []byte(v.plaintext), true)
+}
+
+// MarshalText returns the ciphertext, see encoding.TextMarshaler.
+func (v EncryptedValue) MarshalText() ([]byte, error) {
+	Y, err := v.Ciphertext()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(Y), nil
+}
+
+// UnmarshalText decrypts text and keeps the plaintext, see
+// encoding.TextUnmarshaler. On error the plaintext is left as it was.
+func (v *EncryptedValue) UnmarshalText(text []byte) error {
+	c, err := v.resolve()
+	if err != nil {
+		return err
+	}
+	X, err := cryptValue(c, text, false)
+	if err != nil {
+		return err
+	}
+	v.plaintext = X
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+
+SPDX-Copyright: Copyright (c) Capital One Services, LLC
+SPDX-License-Identifier: Apache-2.0
+Copyright 2017 Capital One Services, LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and limitations under the License.
+
+*/
+
+package fpe
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type valueConfig struct {
+	XMLName xml.Name       `json:"-" xml:"config"`
+	Name    string         `json:"name" xml:"name"`
+	Account EncryptedValue `json:"account" xml:"account"`
+	PIN     EncryptedValue `json:"pin" xml:"pin,attr"`
+}
+
+// bind binds the encrypted fields of cfg to c.
+func (cfg *valueConfig) bind(c Cipher) *valueConfig {
+	cfg.Account.Bind(c)
+	cfg.PIN.Bind(c)
+	return cfg
+}
+
+// marshalLines is a minimal text format in the style of YAML, "key: value" per
+// line, that only uses the encoding.Text interfaces.
+func marshalLines(fields map[string]encoding.TextMarshaler, keys []string) (string, error) {
+	var b strings.Builder
+	for _, k := range keys {
+		text, err := fields[k].MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", k, err)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", k, text)
+	}
+	return b.String(), nil
+}
+
+func unmarshalLines(data string, fields map[string]encoding.TextUnmarshaler) error {
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ": ")
+		if !ok || fields[k] == nil {
+			return fmt.Errorf("bad line %q", sc.Text())
+		}
+		if err := fields[k].UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return sc.Err()
+}
+
+func TestEncryptedValue(t *testing.T) {
+	c := newDigitsCipher(t)
+	account, _ := c.Encrypt([]byte("0123456789"))
+	pin, _ := c.Encrypt([]byte("1234"))
+
+	cfg := valueConfig{
+		Name:    "test",
+		Account: NewEncryptedValue(c, "0123456789"),
+		PIN:     NewEncryptedValue(c, "1234"),
+	}
+	if got, err := cfg.Account.Ciphertext(); err != nil || got != string(account) {
+		t.Fatalf("Ciphertext() = %q, %v, want %q", got, err, account)
+	}
+
+	for idx, codec := range []struct {
+		marshal   func(interface{}) ([]byte, error)
+		unmarshal func([]byte, interface{}) error
+		want      string
+	}{
+		{json.Marshal, json.Unmarshal, fmt.Sprintf(`{"name":"test","account":"%s","pin":"%s"}`, account, pin)},
+		{xml.Marshal, xml.Unmarshal, fmt.Sprintf(`<config pin="%s"><name>test</name><account>%s</account></config>`, pin, account)},
+	} {
+		t.Run(fmt.Sprintf("Sample%d", idx+1), func(t *testing.T) {
+			data, err := codec.marshal(cfg)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(data) != codec.want {
+				t.Fatalf("got %s, want %s", data, codec.want)
+			}
+			back := new(valueConfig).bind(c)
+			if err := codec.unmarshal(data, back); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if back.Account.Plaintext() != "0123456789" || back.PIN.Plaintext() != "1234" || back.Name != "test" {
+				t.Fatalf("unmarshalled %+v", back)
+			}
+		})
+	}
+
+	text, err := marshalLines(map[string]encoding.TextMarshaler{"account": cfg.Account, "pin": cfg.PIN}, []string{"account", "pin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("account: %s\npin: %s\n", account, pin); text != want {
+		t.Fatalf("got %q, want %q", text, want)
+	}
+	back := new(valueConfig).bind(c)
+	if err := unmarshalLines(text, map[string]encoding.TextUnmarshaler{"account": &back.Account, "pin": &back.PIN}); err != nil {
+		t.Fatal(err)
+	}
+	if back.Account.Plaintext() != "0123456789" || back.PIN.Plaintext() != "1234" {
+		t.Fatalf("unmarshalled %+v", back)
+	}
+
+	// The empty string stays empty
+	var empty EncryptedValue
+	empty.Bind(c)
+	if data, err := json.Marshal(empty); err != nil || string(data) != `""` {
+		t.Fatalf("empty value marshals as %s, %v", data, err)
+	}
+}
+
+func TestEncryptedValueError(t *testing.T) {
+	c := newDigitsCipher(t)
+
+	// Without a binding
+	var v EncryptedValue
+	v.SetPlaintext("123456")
+	if _, err := json.Marshal(v); !errors.Is(err, ErrUnbound) {
+		t.Fatalf("marshal unbound: got %v, want %v", err, ErrUnbound)
+	}
+	if err := json.Unmarshal([]byte(`"123456"`), &v); !errors.Is(err, ErrUnbound) {
+		t.Fatalf("unmarshal unbound: got %v, want %v", err, ErrUnbound)
+	}
+	if _, err := v.Ciphertext(); !errors.Is(err, ErrUnbound) {
+		t.Fatalf("Ciphertext unbound: got %v, want %v", err, ErrUnbound)
+	}
+
+	// A bad ciphertext leaves the plaintext as it was
+	v.Bind(c)
+	if err := json.Unmarshal([]byte(`"12x456"`), &v); err == nil || v.Plaintext() != "123456" {
+		t.Fatalf("bad ciphertext: %v, plaintext %q", err, v.Plaintext())
+	}
+	if err := v.UnmarshalText([]byte("1")); !errors.Is(err, ErrValueTooShort) {
+		t.Fatalf("short ciphertext: got %v, want %v", err, ErrValueTooShort)
+	}
+
+	// Bound by a name that is not registered
+	v.BindName("TestEncryptedValueError")
+	if _, err := v.MarshalText(); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("got %v, want %v", err, ErrNotRegistered)
+	}
+}